@@ -1,6 +1,7 @@
 package assertion
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -12,7 +13,7 @@ import (
 
 // queryer abstracts the minimal query capability needed for assertions.
 type queryer interface {
-	Query(sql string) (*sql.Rows, error)
+	Query(ctx context.Context, sql string) (*sql.Rows, error)
 }
 
 // Result captures the outcome of a single assertion check.
@@ -48,23 +49,23 @@ func (e *Engine) HasRules() bool {
 
 // RunPreCheck runs all assertions against the source query (wrappedSQL).
 // It returns a slice of results, one per rule.
-func (e *Engine) RunPreCheck(db queryer, dbType, wrappedSQL string) []Result {
+func (e *Engine) RunPreCheck(ctx context.Context, db queryer, dbType, wrappedSQL string) []Result {
 	fromClause := BuildFromClause(dbType, wrappedSQL)
-	return e.runChecks(db, dbType, fromClause)
+	return e.runChecks(ctx, db, dbType, fromClause)
 }
 
 // RunPostCheck runs all assertions against the target table.
 // It returns a slice of results, one per rule.
-func (e *Engine) RunPostCheck(db queryer, dbType, tableName string) []Result {
+func (e *Engine) RunPostCheck(ctx context.Context, db queryer, dbType, tableName string) []Result {
 	fromClause := BuildTableFromClause(dbType, tableName)
-	return e.runChecks(db, dbType, fromClause)
+	return e.runChecks(ctx, db, dbType, fromClause)
 }
 
-func (e *Engine) runChecks(db queryer, dbType, fromClause string) []Result {
+func (e *Engine) runChecks(ctx context.Context, db queryer, dbType, fromClause string) []Result {
 	results := make([]Result, len(e.rules))
 	for i, rule := range e.rules {
 		sqlText := rule.CheckSQL(dbType, fromClause)
-		count, err := executeCount(db, sqlText)
+		count, err := executeCount(ctx, db, sqlText)
 		results[i] = Result{
 			Rule:           rule,
 			ViolationCount: count,
@@ -122,7 +123,7 @@ func (e *Engine) HandleResults(results []Result, columns []database.ColumnMetada
 
 // FetchViolations queries violating rows for a given result and writes them to DLQ.
 // This should be called separately after HandleResults for DLQ rules.
-func (e *Engine) FetchViolations(db queryer, dbType, fromClause string, result Result, columns []database.ColumnMetadata, writeDLQ func(row []any, errMsg string) error) error {
+func (e *Engine) FetchViolations(ctx context.Context, db queryer, dbType, fromClause string, result Result, columns []database.ColumnMetadata, writeDLQ func(row []any, errMsg string) error) error {
 	if result.Passed() || result.Err != nil {
 		return nil
 	}
@@ -135,7 +136,7 @@ func (e *Engine) FetchViolations(db queryer, dbType, fromClause string, result R
 		colNames[i] = c.Name
 	}
 	sqlText := result.Rule.FetchSQL(dbType, fromClause, colNames)
-	rows, err := db.Query(sqlText)
+	rows, err := db.Query(ctx, sqlText)
 	if err != nil {
 		return fmt.Errorf("failed to query violations for '%s': %w", result.Rule.Description(), err)
 	}
@@ -179,8 +180,8 @@ func (e *Engine) FetchViolations(db queryer, dbType, fromClause string, result R
 	return rows.Err()
 }
 
-func executeCount(db queryer, sqlText string) (int64, error) {
-	rows, err := db.Query(sqlText)
+func executeCount(ctx context.Context, db queryer, sqlText string) (int64, error) {
+	rows, err := db.Query(ctx, sqlText)
 	if err != nil {
 		return 0, err
 	}
@@ -1,6 +1,7 @@
 package assertion
 
 import (
+	"context"
 	"database/sql"
 	"testing"
 
@@ -225,7 +226,7 @@ func TestResultPassed(t *testing.T) {
 // dbQueryer wraps *sql.DB to match the queryer interface.
 type dbQueryer struct{ db *sql.DB }
 
-func (d *dbQueryer) Query(sql string) (*sql.Rows, error) {
+func (d *dbQueryer) Query(_ context.Context, sql string) (*sql.Rows, error) {
 	return d.db.Query(sql)
 }
 
@@ -249,7 +250,7 @@ func TestEngineRunCheck(t *testing.T) {
 		{Column: "amount", Rule: config.AssertionRuleRange, Min: floatPtr(0), Max: floatPtr(250), OnFail: config.AssertionActionWarn},
 	})
 
-	results := engine.RunPostCheck(q, config.DatabaseTypeSQLite, "test")
+	results := engine.RunPostCheck(context.Background(), q, config.DatabaseTypeSQLite, "test")
 	if len(results) != 2 {
 		t.Fatalf("expected 2 results, got %d", len(results))
 	}
@@ -280,7 +281,7 @@ func TestEngineRunPreCheck(t *testing.T) {
 		{Column: "status", Rule: config.AssertionRuleInSet, Values: []string{"active", "inactive"}, OnFail: config.AssertionActionWarn},
 	})
 
-	results := engine.RunPreCheck(q, config.DatabaseTypeSQLite, "SELECT * FROM src")
+	results := engine.RunPreCheck(context.Background(), q, config.DatabaseTypeSQLite, "SELECT * FROM src")
 	if len(results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(results))
 	}
@@ -308,7 +309,7 @@ func TestFetchViolations(t *testing.T) {
 		{Column: "name", Rule: config.AssertionRuleNotNull, OnFail: config.AssertionActionDLQ},
 	})
 
-	results := engine.RunPostCheck(q, config.DatabaseTypeSQLite, "test")
+	results := engine.RunPostCheck(context.Background(), q, config.DatabaseTypeSQLite, "test")
 	if len(results) != 1 {
 		t.Fatalf("expected 1 result, got %d", len(results))
 	}
@@ -319,7 +320,7 @@ func TestFetchViolations(t *testing.T) {
 		return nil
 	}
 
-	if err := engine.FetchViolations(q, config.DatabaseTypeSQLite, "test", results[0], []database.ColumnMetadata{{Name: "id"}, {Name: "name"}}, writeFn); err != nil {
+	if err := engine.FetchViolations(context.Background(), q, config.DatabaseTypeSQLite, "test", results[0], []database.ColumnMetadata{{Name: "id"}, {Name: "name"}}, writeFn); err != nil {
 		t.Fatalf("FetchViolations failed: %v", err)
 	}
 	if len(captured) != 1 {
@@ -344,8 +345,8 @@ func TestFetchViolationsSkipsPassed(t *testing.T) {
 		t.Fatalf("failed to create table: %v", err)
 	}
 
-	results := engine.RunPostCheck(q, config.DatabaseTypeSQLite, "test")
-	if err := engine.FetchViolations(q, config.DatabaseTypeSQLite, "test", results[0], nil, nil); err != nil {
+	results := engine.RunPostCheck(context.Background(), q, config.DatabaseTypeSQLite, "test")
+	if err := engine.FetchViolations(context.Background(), q, config.DatabaseTypeSQLite, "test", results[0], nil, nil); err != nil {
 		t.Errorf("expected no error for passed result, got: %v", err)
 	}
 }
@@ -368,7 +369,7 @@ func TestExecuteCountError(t *testing.T) {
 	defer db.Close()
 	q := &dbQueryer{db: db}
 
-	_, err = executeCount(q, "SELECT COUNT(*) FROM nonexistent")
+	_, err = executeCount(context.Background(), q, "SELECT COUNT(*) FROM nonexistent")
 	if err == nil {
 		t.Error("expected error for invalid table")
 	}
@@ -518,9 +519,9 @@ func TestFetchViolationsNoDLQConfig(t *testing.T) {
 		{Column: "name", Rule: config.AssertionRuleNotNull, OnFail: config.AssertionActionAbort},
 	})
 
-	results := engine.RunPostCheck(q, config.DatabaseTypeSQLite, "test")
+	results := engine.RunPostCheck(context.Background(), q, config.DatabaseTypeSQLite, "test")
 	// abort rule should not trigger FetchViolations
-	if err := engine.FetchViolations(q, config.DatabaseTypeSQLite, "test", results[0], nil, nil); err != nil {
+	if err := engine.FetchViolations(context.Background(), q, config.DatabaseTypeSQLite, "test", results[0], nil, nil); err != nil {
 		t.Errorf("expected no error when on_fail is not DLQ, got: %v", err)
 	}
 }
@@ -538,7 +539,7 @@ func TestFetchViolationsWithErrorResult(t *testing.T) {
 	})
 
 	res := Result{Rule: NewRule(config.AssertionConfig{Column: "name", Rule: config.AssertionRuleNotNull, OnFail: config.AssertionActionDLQ}), ViolationCount: 0, Err: sql.ErrConnDone}
-	if err := engine.FetchViolations(q, config.DatabaseTypeSQLite, "test", res, nil, nil); err != nil {
+	if err := engine.FetchViolations(context.Background(), q, config.DatabaseTypeSQLite, "test", res, nil, nil); err != nil {
 		t.Errorf("expected no error for result with Err, got: %v", err)
 	}
 }
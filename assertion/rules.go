@@ -206,6 +206,9 @@ func regexMatchExpr(dbType, col, pattern string) string {
 		return fmt.Sprintf("%s ~ %s", col, quoteSQLString(pattern))
 	case config.DatabaseTypeOracle:
 		return fmt.Sprintf("REGEXP_LIKE(%s, %s)", col, quoteSQLString(pattern))
+	case config.DatabaseTypeClickHouse:
+		// ClickHouse string literals treat backslash as an escape character.
+		return fmt.Sprintf("match(%s, %s)", col, quoteSQLString(strings.ReplaceAll(pattern, `\`, `\\`)))
 	case config.DatabaseTypeSQLServer:
 		// SQL Server does not support native regex; fall back to LIKE with simple wildcard
 		return fmt.Sprintf("%s LIKE %s", col, quoteSQLString(pattern))
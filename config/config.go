@@ -19,8 +19,39 @@ const (
 	DatabaseTypeDuckDB     = "duckdb"
 	DatabaseTypePostgreSQL = "postgresql"
 	DatabaseTypeSQLServer  = "sqlserver"
+	DatabaseTypeClickHouse = "clickhouse"
+
+	// File-based source types read local files (or globs) through an embedded DuckDB.
+	DatabaseTypeCSV     = "csv"
+	DatabaseTypeJSONL   = "jsonl"
+	DatabaseTypeParquet = "parquet"
+)
+
+// Supported compression codecs for file targets.
+const (
+	FileCompressionNone   = "none"
+	FileCompressionGzip   = "gzip"
+	FileCompressionZstd   = "zstd"
+	FileCompressionSnappy = "snappy"
 )
 
+// Supported ClickHouse upsert strategies for merge mode.
+const (
+	ClickHouseUpsertReplacing    = "replacing"
+	ClickHouseUpsertDeleteInsert = "delete_insert"
+)
+
+// Dialects of MySQL- and PostgreSQL-compatible engines. A dialect adjusts type
+// mapping, upsert and index DDL for the engine behind a mysql or postgresql database.
+const (
+	DialectMariaDB     = "mariadb"
+	DialectTiDB        = "tidb"
+	DialectCockroachDB = "cockroachdb"
+)
+
+// DefaultMaxRowsPerFile bounds how many rows a file target buffers before writing a file.
+const DefaultMaxRowsPerFile = 1000000
+
 // Supported task modes.
 const (
 	TaskModeReplace = "replace"
@@ -37,6 +68,25 @@ const (
 	TaskValidateSample   = "sample"
 )
 
+// Supported state backends for state_file documents.
+const (
+	StateBackendFile     = "file"
+	StateBackendDatabase = "database"
+	StateBackendS3       = "s3"
+)
+
+// DefaultStateLockTTL is how long a state lock is held without being renewed.
+const DefaultStateLockTTL = "5m"
+
+// DefaultLeaderLeaseTTL is how long a daemon leader lease lasts without renewal.
+const DefaultLeaderLeaseTTL = "30s"
+
+// Supported task load methods.
+const (
+	LoadMethodInsert = "insert"
+	LoadMethodBulk   = "bulk"
+)
+
 // Supported DLQ formats.
 const (
 	DLQFormatJSONL = "jsonl"
@@ -80,6 +130,39 @@ const (
 	MaskRuleRandomDate    = "random_date"
 	MaskRuleFixedValue    = "fixed_value"
 	MaskRuleHash          = "hash"
+	MaskRuleHMAC          = "hmac"
+	MaskRuleFPE           = "fpe"
+	MaskRuleToken         = "token"
+	MaskRuleFakeName      = "fake_name"
+	MaskRuleFakeEmail     = "fake_email"
+	MaskRuleFakeAddress   = "fake_address"
+	MaskRuleFakeCompany   = "fake_company"
+	MaskRuleFakePhone     = "fake_phone"
+)
+
+// Supported locales of the fake_* masking rules.
+const (
+	MaskLocaleEnUS = "en_US"
+	MaskLocaleZhCN = "zh_CN"
+)
+
+// Supported CDC capture modes.
+const (
+	CDCModePolling = "polling"
+	CDCModeLogical = "logical"
+	CDCModeBinlog  = "binlog"
+)
+
+// Supported CDC delete detection strategies.
+const (
+	CDCDeleteStrategyKeySet     = "key_set"
+	CDCDeleteStrategySoftDelete = "soft_delete"
+)
+
+// Supported CDC delete actions.
+const (
+	CDCDeleteActionDelete = "delete"
+	CDCDeleteActionFlag   = "flag"
 )
 
 // Supported plugin engines.
@@ -98,6 +181,30 @@ var supportedMaskRules = map[string]struct{}{
 	MaskRuleRandomDate:    {},
 	MaskRuleFixedValue:    {},
 	MaskRuleHash:          {},
+	MaskRuleHMAC:          {},
+	MaskRuleFPE:           {},
+	MaskRuleToken:         {},
+	MaskRuleFakeName:      {},
+	MaskRuleFakeEmail:     {},
+	MaskRuleFakeAddress:   {},
+	MaskRuleFakeCompany:   {},
+	MaskRuleFakePhone:     {},
+}
+
+// fakeMaskRules 生成仿真数据的脱敏规则，使用 locale 与 seed。
+var fakeMaskRules = map[string]struct{}{
+	MaskRuleFakeName:    {},
+	MaskRuleFakeEmail:   {},
+	MaskRuleFakeAddress: {},
+	MaskRuleFakeCompany: {},
+	MaskRuleFakePhone:   {},
+}
+
+// keyedMaskRules 需要密钥的脱敏规则。
+var keyedMaskRules = map[string]struct{}{
+	MaskRuleHMAC:  {},
+	MaskRuleFPE:   {},
+	MaskRuleToken: {},
 }
 
 var supportedPluginEngines = map[string]struct{}{
@@ -105,10 +212,28 @@ var supportedPluginEngines = map[string]struct{}{
 	PluginEngineJavaScript: {},
 }
 
+// Supported shard boundary strategies.
+const (
+	ShardStrategyRange    = "range"
+	ShardStrategyQuantile = "quantile"
+	ShardStrategyNTile    = "ntile"
+)
+
+// DefaultShardSampleSize is the number of resume_key values sampled by the
+// quantile strategy when shard.sample_size is not set.
+const DefaultShardSampleSize = 10000
+
 // ShardConfig defines range-based sharding for a single table.
 type ShardConfig struct {
 	Enabled bool `toml:"enabled"`
 	Shards  int  `toml:"shards"`
+	// Strategy chooses how shard boundaries are computed: "range" splits
+	// [min, max] into equal-width ranges, "quantile" cuts at quantiles of a
+	// random sample and "ntile" cuts at NTILE buckets computed by the source.
+	Strategy   string `toml:"strategy,omitempty"`
+	SampleSize int    `toml:"sample_size,omitempty"`
+	// Rebalance lets idle workers split the remaining range of a slow shard.
+	Rebalance bool `toml:"rebalance,omitempty"`
 }
 
 // ReplicaConfig describes a read-only replica connection.
@@ -144,6 +269,17 @@ type DatabaseConfig struct {
 
 	// Encryption for file-based databases (e.g., SQLCipher)
 	EncryptionKey string `toml:"encryption_key,omitempty"`
+
+	// Output settings when a csv/jsonl/parquet database is a target; path is then the output directory.
+	Compression    string   `toml:"compression,omitempty"`
+	MaxRowsPerFile int      `toml:"max_rows_per_file,omitempty"`
+	PartitionBy    []string `toml:"partition_by,omitempty"`
+
+	// How merge mode writes to a ClickHouse target: "replacing" (ReplacingMergeTree) or "delete_insert".
+	UpsertStrategy string `toml:"upsert_strategy,omitempty"`
+
+	// Compatible engine behind a mysql ("mariadb", "tidb") or postgresql ("cockroachdb") database.
+	Dialect string `toml:"dialect,omitempty"`
 }
 
 // IndexColumn represents a column definition for index creation with order information.
@@ -195,6 +331,44 @@ type MaskingConfig struct {
 	Rule   string    `toml:"rule"`
 	Range  []float64 `toml:"range,omitempty"`
 	Value  string    `toml:"value,omitempty"`
+	// Salt 参与 hmac/fpe/token 计算；相同密钥与 salt 的列可以跨表关联，不同 salt 则不能
+	Salt string `toml:"salt,omitempty"`
+	// KeyEnv / KeyFile 覆盖全局 [masking] 的密钥来源
+	KeyEnv  string `toml:"key_env,omitempty"`
+	KeyFile string `toml:"key_file,omitempty"`
+	// Locale / Seed 用于 fake_* 规则，未配置时使用全局 [masking]；设置 seed 后同一输入总是生成同一仿真值
+	Locale string `toml:"locale,omitempty"`
+	Seed   *int64 `toml:"seed,omitempty"`
+}
+
+// MaskingDefaults holds the global [masking] settings shared by all
+// masking rules.
+type MaskingDefaults struct {
+	// KeyEnv names the environment variable holding the key of the
+	// hmac, fpe and token rules.
+	KeyEnv string `toml:"key_env"`
+	// KeyFile is read instead of KeyEnv when set; surrounding whitespace is
+	// trimmed.
+	KeyFile string `toml:"key_file"`
+	// Locale selects the data set of the fake_* rules (default en_US).
+	Locale string `toml:"locale"`
+	// Seed makes the fake_* rules repeatable: the same input always gets
+	// the same fake value. Without it every run generates new values.
+	Seed *int64 `toml:"seed"`
+}
+
+// normalizeMaskLocale 将 zh-cn、ZH_CN 等写法统一为 zh_CN，空值返回默认 en_US。
+func normalizeMaskLocale(locale string) (string, error) {
+	locale = strings.TrimSpace(locale)
+	if locale == "" {
+		return MaskLocaleEnUS, nil
+	}
+	for _, supported := range []string{MaskLocaleEnUS, MaskLocaleZhCN} {
+		if strings.EqualFold(strings.ReplaceAll(locale, "-", "_"), supported) {
+			return supported, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported masking locale '%s' (supported: %s, %s)", locale, MaskLocaleEnUS, MaskLocaleZhCN)
 }
 
 // PluginConfig defines a row-level transformation plugin for a task.
@@ -213,6 +387,24 @@ type AdaptiveBatchConfig struct {
 	MemoryLimitMB   int  `toml:"memory_limit_mb"`
 }
 
+// TransactionConfig groups a task's batch writes into explicit target transactions.
+type TransactionConfig struct {
+	Enabled bool `toml:"enabled"`
+	// CommitEvery 每写入 N 个批次提交一次；0 表示整个任务在单个事务内完成，失败时全部回滚。
+	CommitEvery int `toml:"commit_every"`
+}
+
+// PipelineConfig splits a task's read, transform and write work into concurrent stages.
+type PipelineConfig struct {
+	Enabled bool `toml:"enabled"`
+	// TransformWorkers 并发执行脱敏、插件与列映射的 worker 数，默认 2。
+	TransformWorkers int `toml:"transform_workers"`
+	// Writers 并发写入目标库的 worker 数（各占一个连接），默认 2。
+	Writers int `toml:"writers"`
+	// QueueSize 各阶段之间缓冲的批次数，队列满时上游阻塞，默认 4。
+	QueueSize int `toml:"queue_size"`
+}
+
 // AssertionConfig defines a data quality assertion rule for a task.
 type AssertionConfig struct {
 	Column  string   `toml:"column,omitempty"`
@@ -249,23 +441,45 @@ type JoinConfig struct {
 
 // CDCConfig configures change-data-capture polling for a task.
 type CDCConfig struct {
-	Enabled         bool   `toml:"enabled"`
+	Enabled bool `toml:"enabled"`
+	// Mode 捕获方式：polling 按游标列轮询，logical 消费 PostgreSQL 逻辑复制槽（pgoutput），
+	// binlog 读取 MySQL 行格式 binlog。
+	Mode            string `toml:"mode"`
 	CursorColumn    string `toml:"cursor_column"`
 	PollInterval    string `toml:"poll_interval"`
 	InitialCursor   string `toml:"initial_cursor"`
 	DeleteDetection bool   `toml:"delete_detection"`
+	// DeleteStrategy 删除检测方式：key_set 比对 merge_keys 键集，soft_delete 读取软删除列。
+	DeleteStrategy string `toml:"delete_strategy"`
+	// SoftDeleteColumn 源端软删除标记列，soft_delete 策略必填。
+	SoftDeleteColumn string `toml:"soft_delete_column"`
+	// SoftDeleteValue 表示已删除的 SQL 字面量；为空时非 NULL 即视为已删除。
+	SoftDeleteValue string `toml:"soft_delete_value"`
+	// DeleteAction 对目标端已删除行的处理方式：delete 物理删除，flag 写入标记列。
+	DeleteAction string `toml:"delete_action"`
+	// DeleteFlagColumn flag 动作写入的目标列，缺失时自动添加。
+	DeleteFlagColumn string `toml:"delete_flag_column"`
+	// SlotName logical 模式使用的复制槽名称，不存在时自动创建。
+	SlotName string `toml:"slot_name"`
+	// Publication logical 模式订阅的 publication 名称。
+	Publication string `toml:"publication"`
+	// SourceTable logical/binlog 模式捕获的源表，可带 schema 前缀
+	// （logical 默认 public，binlog 默认源库 database）。
+	SourceTable string `toml:"source_table"`
 }
 
 // TaskConfig defines a single migration job.
 type TaskConfig struct {
-	TableName          string              `toml:"table_name"`
-	SQL                string              `toml:"sql"`
-	SourceDB           string              `toml:"source_db"`
-	TargetDB           string              `toml:"target_db"`
-	Ignore             bool                `toml:"ignore"`
-	Mode               string              `toml:"mode"`
-	BatchSize          int                 `toml:"batch_size"`
-	MaxRetries         int                 `toml:"max_retries"`
+	TableName  string `toml:"table_name"`
+	SQL        string `toml:"sql"`
+	SourceDB   string `toml:"source_db"`
+	TargetDB   string `toml:"target_db"`
+	Ignore     bool   `toml:"ignore"`
+	Mode       string `toml:"mode"`
+	BatchSize  int    `toml:"batch_size"`
+	MaxRetries int    `toml:"max_retries"`
+	// Timeout 单个任务（CDC 任务为每一轮）的最长执行时间，如 "30m"；超时后中断查询与写入。
+	Timeout            string              `toml:"timeout,omitempty"`
 	Validate           string              `toml:"validate"`
 	ValidateSampleSize int                 `toml:"validate_sample_size"`
 	MergeKeys          []string            `toml:"merge_keys"`
@@ -273,6 +487,8 @@ type TaskConfig struct {
 	ResumeFrom         string              `toml:"resume_from"`
 	StateFile          string              `toml:"state_file"`
 	AdaptiveBatch      AdaptiveBatchConfig `toml:"adaptive_batch"`
+	Transaction        TransactionConfig   `toml:"transaction"`
+	Pipeline           PipelineConfig      `toml:"pipeline"`
 	Columns            []ColumnMapping     `toml:"columns,omitempty"`
 	Sources            []SourceConfig      `toml:"sources,omitempty"`
 	Join               JoinConfig          `toml:"join,omitempty"`
@@ -282,6 +498,8 @@ type TaskConfig struct {
 	SkipCreateTable bool `toml:"skip_create_table"`
 	// SchemaEvolution 在 append/merge 模式下自动为目标表添加源端新增列。
 	SchemaEvolution bool `toml:"schema_evolution"`
+	// LoadMethod 写入方式：insert 逐行参数化 INSERT，bulk 使用目标库原生批量装载（不可用时回退到 insert）。
+	LoadMethod string `toml:"load_method,omitempty"`
 	// DLQPath 死信队列输出路径，用于保存插入失败的行。
 	// 支持本地文件路径（如 ./dlq/failed.jsonl）、S3（s3://bucket/path）和 GCS（gs://bucket/path）。
 	// 可使用 {{.Date}} 模板按日期分片，如 s3://bucket/dlq/{{.Date}}/failed.jsonl。
@@ -297,6 +515,9 @@ type TaskConfig struct {
 	DependsOn  []string          `toml:"depends_on"`
 	Shard      ShardConfig       `toml:"shard,omitempty"`
 	CDC        CDCConfig         `toml:"cdc,omitempty"`
+	// Schedule 任务级定时调度：daemon 模式下 cron 与时区相同的任务组成一个独立的 cron 条目，
+	// 未配置时沿用全局 [schedule]。
+	Schedule ScheduleConfig `toml:"schedule,omitempty"`
 }
 
 // MetricsConfig configures metrics collection and export.
@@ -312,12 +533,105 @@ func (t TaskConfig) IsFederated() bool {
 	return len(t.Sources) > 0
 }
 
+// ResumeKeys returns the columns of resume_key; a composite key is written as
+// a comma-separated list such as "tenant_id, id".
+func (t TaskConfig) ResumeKeys() []string {
+	if strings.TrimSpace(t.ResumeKey) == "" {
+		return nil
+	}
+	keys := strings.Split(t.ResumeKey, ",")
+	for i, key := range keys {
+		keys[i] = strings.TrimSpace(key)
+	}
+	return keys
+}
+
 // HistoryConfig controls migration audit logging.
 type HistoryConfig struct {
 	Enabled   bool   `toml:"enabled"`
 	TableName string `toml:"table_name"`
 }
 
+// StateConfig selects where state_file documents are kept. The file backend
+// writes local JSON files; the database backend stores each document as a row
+// of a table in one of the configured databases; the s3 backend stores objects
+// in an S3-compatible bucket. Every backend updates documents with
+// compare-and-swap and holds a lock while a run uses them.
+type StateConfig struct {
+	Backend   string `toml:"backend,omitempty"`
+	Database  string `toml:"database,omitempty"`
+	TableName string `toml:"table_name,omitempty"`
+	Bucket    string `toml:"bucket,omitempty"`
+	Prefix    string `toml:"prefix,omitempty"`
+	Region    string `toml:"region,omitempty"`
+	Endpoint  string `toml:"endpoint,omitempty"`
+	PathStyle bool   `toml:"path_style,omitempty"`
+	LockTTL   string `toml:"lock_ttl,omitempty"`
+}
+
+// Table returns the configured state table name or the default.
+func (s *StateConfig) Table() string {
+	if s.TableName == "" {
+		return "db_ferry_state"
+	}
+	return s.TableName
+}
+
+// LockTTLDuration returns lock_ttl as a duration, falling back to the default.
+func (s *StateConfig) LockTTLDuration() time.Duration {
+	ttl := s.LockTTL
+	if ttl == "" {
+		ttl = DefaultStateLockTTL
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 {
+		d, _ = time.ParseDuration(DefaultStateLockTTL)
+	}
+	return d
+}
+
+// LeaderConfig enables leader election between daemon instances. The lease is
+// a row of a table in one of the configured databases, or a local lock file
+// for single-host setups and tests. Only the leader runs migration rounds.
+type LeaderConfig struct {
+	Enabled   bool   `toml:"enabled"`
+	Database  string `toml:"database,omitempty"`
+	TableName string `toml:"table_name,omitempty"`
+	LockFile  string `toml:"lock_file,omitempty"`
+	Name      string `toml:"name,omitempty"`
+	LeaseTTL  string `toml:"lease_ttl,omitempty"`
+}
+
+// Table returns the configured lease table name or the default.
+func (l *LeaderConfig) Table() string {
+	if l.TableName == "" {
+		return "db_ferry_leader"
+	}
+	return l.TableName
+}
+
+// LeaseName returns the key identifying the lease, so that several daemon
+// groups can share one lease table.
+func (l *LeaderConfig) LeaseName() string {
+	if l.Name == "" {
+		return "db-ferry"
+	}
+	return l.Name
+}
+
+// LeaseTTLDuration returns lease_ttl as a duration, falling back to the default.
+func (l *LeaderConfig) LeaseTTLDuration() time.Duration {
+	ttl := l.LeaseTTL
+	if ttl == "" {
+		ttl = DefaultLeaderLeaseTTL
+	}
+	d, err := time.ParseDuration(ttl)
+	if err != nil || d <= 0 {
+		d, _ = time.ParseDuration(DefaultLeaderLeaseTTL)
+	}
+	return d
+}
+
 // NotifyConfig defines webhook notification URLs and behavior.
 type NotifyConfig struct {
 	OnSuccess []string      `toml:"on_success"`
@@ -356,9 +670,12 @@ type Config struct {
 	Tasks              []TaskConfig     `toml:"tasks"`
 	MaxConcurrentTasks int              `toml:"max_concurrent_tasks"`
 	History            HistoryConfig    `toml:"history"`
+	State              StateConfig      `toml:"state"`
+	Leader             LeaderConfig     `toml:"leader"`
 	Metrics            MetricsConfig    `toml:"metrics"`
 	Notify             NotifyConfig     `toml:"notify"`
 	Schedule           ScheduleConfig   `toml:"schedule"`
+	Masking            MaskingDefaults  `toml:"masking"`
 
 	databaseMap map[string]DatabaseConfig
 }
@@ -395,6 +712,7 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("database definition %d: duplicate database name '%s'", i+1, db.Name)
 		}
 		db.Type = strings.ToLower(db.Type)
+		db.Dialect = strings.ToLower(strings.TrimSpace(db.Dialect))
 		switch db.Type {
 		case DatabaseTypeOracle:
 			if db.Port == "" {
@@ -403,15 +721,25 @@ func (c *Config) Validate() error {
 		case DatabaseTypeMySQL:
 			if db.Port == "" {
 				db.Port = "3306"
+				if db.Dialect == DialectTiDB {
+					db.Port = "4000"
+				}
 			}
 		case DatabaseTypePostgreSQL:
 			if db.Port == "" {
 				db.Port = "5432"
+				if db.Dialect == DialectCockroachDB {
+					db.Port = "26257"
+				}
 			}
 		case DatabaseTypeSQLServer:
 			if db.Port == "" {
 				db.Port = "1433"
 			}
+		case DatabaseTypeClickHouse:
+			if db.Port == "" {
+				db.Port = "8123"
+			}
 		}
 
 		if err := validateDatabaseConfig(&db); err != nil {
@@ -421,7 +749,20 @@ func (c *Config) Validate() error {
 		c.databaseMap[db.Name] = db
 	}
 
+	if c.Masking.KeyEnv != "" && c.Masking.KeyFile != "" {
+		return fmt.Errorf("masking.key_env and masking.key_file are mutually exclusive")
+	}
+	if c.Masking.Locale != "" {
+		locale, err := normalizeMaskLocale(c.Masking.Locale)
+		if err != nil {
+			return fmt.Errorf("masking.locale: %w", err)
+		}
+		c.Masking.Locale = locale
+	}
+
 	indexNames := make(map[string]string)
+	// 文件库作为源时 path 是要读取的文件或 glob，作为目标时是输出目录，同一别名不能兼任两者
+	fileTargets := make(map[string]struct{})
 
 	for i, task := range c.Tasks {
 		if task.TableName == "" {
@@ -552,9 +893,30 @@ func (c *Config) Validate() error {
 		if task.BatchSize < 0 {
 			return fmt.Errorf("task %d: batch_size must be >= 0", i+1)
 		}
+		task.LoadMethod = strings.ToLower(strings.TrimSpace(task.LoadMethod))
+		if task.LoadMethod == "" {
+			task.LoadMethod = LoadMethodInsert
+		}
+		switch task.LoadMethod {
+		case LoadMethodInsert, LoadMethodBulk:
+		default:
+			return fmt.Errorf("task %d: load_method must be %q or %q", i+1, LoadMethodInsert, LoadMethodBulk)
+		}
+		if task.LoadMethod == LoadMethodBulk && task.Mode == TaskModeMerge {
+			return fmt.Errorf("task %d: load_method %q is only valid in %q or %q mode", i+1, LoadMethodBulk, TaskModeReplace, TaskModeAppend)
+		}
 		if task.MaxRetries < 0 {
 			return fmt.Errorf("task %d: max_retries must be >= 0", i+1)
 		}
+		if task.Timeout != "" {
+			timeout, err := time.ParseDuration(task.Timeout)
+			if err != nil {
+				return fmt.Errorf("task %d: invalid timeout %q: %w", i+1, task.Timeout, err)
+			}
+			if timeout <= 0 {
+				return fmt.Errorf("task %d: timeout must be > 0", i+1)
+			}
+		}
 
 		if task.AdaptiveBatch.Enabled {
 			if task.AdaptiveBatch.MinSize <= 0 {
@@ -580,7 +942,14 @@ func (c *Config) Validate() error {
 		}
 
 		if task.CDC.Enabled {
-			if task.CDC.CursorColumn == "" {
+			task.CDC.Mode = strings.ToLower(strings.TrimSpace(task.CDC.Mode))
+			if task.CDC.Mode == "" {
+				task.CDC.Mode = CDCModePolling
+			}
+			if task.CDC.Mode != CDCModePolling && task.CDC.Mode != CDCModeLogical && task.CDC.Mode != CDCModeBinlog {
+				return fmt.Errorf("task %d: cdc.mode must be %q, %q or %q", i+1, CDCModePolling, CDCModeLogical, CDCModeBinlog)
+			}
+			if task.CDC.Mode == CDCModePolling && task.CDC.CursorColumn == "" {
 				return fmt.Errorf("task %d: cdc.cursor_column is required when cdc.enabled is true", i+1)
 			}
 			if task.IsFederated() {
@@ -601,20 +970,49 @@ func (c *Config) Validate() error {
 			if _, err := time.ParseDuration(task.CDC.PollInterval); err != nil {
 				return fmt.Errorf("task %d: invalid cdc.poll_interval %q: %w", i+1, task.CDC.PollInterval, err)
 			}
-			if task.ResumeKey != "" && !strings.EqualFold(task.ResumeKey, task.CDC.CursorColumn) {
-				return fmt.Errorf("task %d: resume_key and cdc.cursor_column must match when both are set", i+1)
-			}
-			// CDC automatically uses cursor_column as resume_key.
-			task.ResumeKey = task.CDC.CursorColumn
-			if task.CDC.InitialCursor != "" {
-				task.ResumeFrom = task.CDC.InitialCursor
+			switch task.CDC.Mode {
+			case CDCModeLogical:
+				if err := validateCDCLogical(&task.CDC, task.Mode, task.ResumeKey, sourceDB.Type); err != nil {
+					return fmt.Errorf("task %d: %w", i+1, err)
+				}
+			case CDCModeBinlog:
+				if err := validateCDCBinlog(&task.CDC, task.Mode, task.ResumeKey, sourceDB.Type); err != nil {
+					return fmt.Errorf("task %d: %w", i+1, err)
+				}
+			default:
+				if task.ResumeKey != "" && !strings.EqualFold(task.ResumeKey, task.CDC.CursorColumn) {
+					return fmt.Errorf("task %d: resume_key and cdc.cursor_column must match when both are set", i+1)
+				}
+				// CDC automatically uses cursor_column as resume_key.
+				task.ResumeKey = task.CDC.CursorColumn
+				if task.CDC.InitialCursor != "" {
+					task.ResumeFrom = task.CDC.InitialCursor
+				}
+				if err := validateCDCDeleteDetection(&task.CDC, task.Mode, task.SQL); err != nil {
+					return fmt.Errorf("task %d: %w", i+1, err)
+				}
 			}
 		}
 
 		task.ResumeKey = strings.TrimSpace(task.ResumeKey)
+		if strings.Contains(task.ResumeKey, ",") {
+			keys := strings.Split(task.ResumeKey, ",")
+			for k, key := range keys {
+				keys[k] = strings.TrimSpace(key)
+				if keys[k] == "" {
+					return fmt.Errorf("task %d: resume_key contains an empty column", i+1)
+				}
+			}
+			// 复合 resume_key 的断点是多个值，无法代入 {{.LastValue}} 模板。
+			if strings.Contains(task.SQL, "{{.LastValue}}") {
+				return fmt.Errorf("task %d: composite resume_key does not support {{.LastValue}} in sql", i+1)
+			}
+			task.ResumeKey = strings.Join(keys, ", ")
+		}
 		task.ResumeFrom = strings.TrimSpace(task.ResumeFrom)
 		task.StateFile = strings.TrimSpace(task.StateFile)
-		if task.StateFile != "" && task.ResumeKey == "" {
+		// logical/binlog 模式的 state_file 保存复制位置，而非 resume_key 游标。
+		if task.StateFile != "" && task.ResumeKey == "" && !task.CDC.IsLogical() && !task.CDC.IsBinlog() {
 			return fmt.Errorf("task %d: state_file requires resume_key", i+1)
 		}
 		if task.ResumeKey != "" && task.StateFile == "" && task.ResumeFrom == "" {
@@ -625,14 +1023,74 @@ func (c *Config) Validate() error {
 			if task.ResumeKey == "" {
 				return fmt.Errorf("task %d: shard requires resume_key", i+1)
 			}
+			if len(task.ResumeKeys()) > 1 {
+				return fmt.Errorf("task %d: shard requires a single-column resume_key", i+1)
+			}
 			if task.Shard.Shards <= 1 {
 				return fmt.Errorf("task %d: shard.shards must be > 1", i+1)
 			}
 			if task.Mode == TaskModeReplace {
 				return fmt.Errorf("task %d: shard is not supported in replace mode; use append or merge", i+1)
 			}
-			if task.StateFile != "" {
-				return fmt.Errorf("task %d: state_file is not supported with shard", i+1)
+			task.Shard.Strategy = strings.ToLower(strings.TrimSpace(task.Shard.Strategy))
+			switch task.Shard.Strategy {
+			case "":
+				task.Shard.Strategy = ShardStrategyRange
+			case ShardStrategyRange, ShardStrategyQuantile, ShardStrategyNTile:
+			default:
+				return fmt.Errorf("task %d: unsupported shard.strategy '%s' (must be %q, %q, or %q)", i+1, task.Shard.Strategy, ShardStrategyRange, ShardStrategyQuantile, ShardStrategyNTile)
+			}
+			if task.Shard.SampleSize < 0 {
+				return fmt.Errorf("task %d: shard.sample_size must be >= 0", i+1)
+			}
+			if task.Shard.SampleSize > 0 && task.Shard.Strategy != ShardStrategyQuantile {
+				return fmt.Errorf("task %d: shard.sample_size requires shard.strategy %q", i+1, ShardStrategyQuantile)
+			}
+			if task.Shard.Strategy == ShardStrategyQuantile && task.Shard.SampleSize == 0 {
+				task.Shard.SampleSize = DefaultShardSampleSize
+			}
+		}
+
+		if task.Pipeline.Enabled {
+			if task.Pipeline.TransformWorkers < 0 || task.Pipeline.Writers < 0 || task.Pipeline.QueueSize < 0 {
+				return fmt.Errorf("task %d: pipeline.transform_workers, pipeline.writers and pipeline.queue_size must be >= 0", i+1)
+			}
+			if task.Pipeline.TransformWorkers == 0 {
+				task.Pipeline.TransformWorkers = 2
+			}
+			// merge 模式下并发写入的批次可能乱序落库，同一键的旧行会覆盖新行，因此只允许一个 writer
+			if task.Mode == TaskModeMerge && task.Pipeline.Writers > 1 {
+				return fmt.Errorf("task %d: pipeline.writers must be 1 in merge mode so that later rows of a key are written last", i+1)
+			}
+			if task.Pipeline.Writers == 0 {
+				task.Pipeline.Writers = 2
+				if task.Mode == TaskModeMerge {
+					task.Pipeline.Writers = 1
+				}
+			}
+			if task.Pipeline.QueueSize == 0 {
+				task.Pipeline.QueueSize = 4
+			}
+			if task.IsFederated() {
+				return fmt.Errorf("task %d: pipeline is not supported in federated mode", i+1)
+			}
+			if task.CDC.IsLogical() || task.CDC.IsBinlog() {
+				return fmt.Errorf("task %d: pipeline is not supported with cdc.mode %q", i+1, task.CDC.Mode)
+			}
+			if task.Transaction.Enabled {
+				return fmt.Errorf("task %d: pipeline is not supported with transaction", i+1)
+			}
+		}
+
+		if task.Transaction.CommitEvery < 0 {
+			return fmt.Errorf("task %d: transaction.commit_every must be >= 0", i+1)
+		}
+		if task.Transaction.Enabled {
+			if task.Shard.Enabled {
+				return fmt.Errorf("task %d: transaction is not supported with shard", i+1)
+			}
+			if task.CDC.IsLogical() || task.CDC.IsBinlog() {
+				return fmt.Errorf("task %d: transaction is not supported with cdc.mode %q", i+1, task.CDC.Mode)
 			}
 		}
 
@@ -666,6 +1124,17 @@ func (c *Config) Validate() error {
 		if err := ensureDatabaseSupportsTarget(&targetDB); err != nil {
 			return fmt.Errorf("task %d: %w", i+1, err)
 		}
+		if targetDB.IsFileDatabase() {
+			if err := validateFileTargetTask(task); err != nil {
+				return fmt.Errorf("task %d: %w", i+1, err)
+			}
+			fileTargets[targetDB.Name] = struct{}{}
+		}
+		if targetDB.Type == DatabaseTypeClickHouse {
+			if err := validateClickHouseTargetTask(task); err != nil {
+				return fmt.Errorf("task %d: %w", i+1, err)
+			}
+		}
 
 		if task.Plugin.Engine != "" {
 			task.Plugin.Engine = strings.ToLower(strings.TrimSpace(task.Plugin.Engine))
@@ -678,6 +1147,10 @@ func (c *Config) Validate() error {
 			if task.Plugin.TimeoutMs <= 0 {
 				task.Plugin.TimeoutMs = 5
 			}
+			// 日志型 CDC 的变更行可能只带部分列（未变更的 TOAST 列、删除的旧行镜像），插件无法一致地处理。
+			if task.CDC.IsLogical() || task.CDC.IsBinlog() {
+				return fmt.Errorf("task %d: plugin is not supported with cdc.mode %q; streamed changes are applied without it", i+1, task.CDC.Mode)
+			}
 		}
 
 		for j, index := range task.Indexes {
@@ -729,6 +1202,31 @@ func (c *Config) Validate() error {
 			if m.Rule == MaskRuleFixedValue && m.Value == "" {
 				return fmt.Errorf("task %d, masking %d: rule '%s' requires value", i+1, j+1, m.Rule)
 			}
+			if m.KeyEnv != "" && m.KeyFile != "" {
+				return fmt.Errorf("task %d, masking %d: key_env and key_file are mutually exclusive", i+1, j+1)
+			}
+			if _, keyed := keyedMaskRules[m.Rule]; keyed {
+				// 规则未指定密钥来源时使用全局 [masking]
+				if m.KeyEnv == "" && m.KeyFile == "" {
+					m.KeyEnv, m.KeyFile = c.Masking.KeyEnv, c.Masking.KeyFile
+				}
+				if m.KeyEnv == "" && m.KeyFile == "" {
+					return fmt.Errorf("task %d, masking %d: rule '%s' requires key_env or key_file (on the rule or in [masking])", i+1, j+1, m.Rule)
+				}
+			}
+			if _, fake := fakeMaskRules[m.Rule]; fake {
+				if m.Locale == "" {
+					m.Locale = c.Masking.Locale
+				}
+				locale, err := normalizeMaskLocale(m.Locale)
+				if err != nil {
+					return fmt.Errorf("task %d, masking %d: %w", i+1, j+1, err)
+				}
+				m.Locale = locale
+				if m.Seed == nil {
+					m.Seed = c.Masking.Seed
+				}
+			}
 			task.Masking[j] = m
 		}
 
@@ -797,6 +1295,18 @@ func (c *Config) Validate() error {
 		c.Tasks[i] = task
 	}
 
+	for i, task := range c.Tasks {
+		sources := []string{task.SourceDB}
+		for _, src := range task.Sources {
+			sources = append(sources, src.DB)
+		}
+		for _, name := range sources {
+			if _, ok := fileTargets[name]; ok {
+				return fmt.Errorf("task %d: database '%s' is written as a file target and cannot also be read as a source", i+1, name)
+			}
+		}
+	}
+
 	if err := validateTaskDependencies(c.Tasks); err != nil {
 		return err
 	}
@@ -819,7 +1329,195 @@ func (c *Config) Validate() error {
 	if err := validateScheduleConfig(&c.Schedule); err != nil {
 		return err
 	}
+	if err := validateTaskSchedules(c.Tasks); err != nil {
+		return err
+	}
+	if err := c.validateStateConfig(); err != nil {
+		return err
+	}
+	if err := c.validateLeaderConfig(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *Config) validateStateConfig() error {
+	s := &c.State
+	s.Backend = strings.ToLower(strings.TrimSpace(s.Backend))
+	switch s.Backend {
+	case "":
+		s.Backend = StateBackendFile
+	case StateBackendFile:
+	case StateBackendDatabase:
+		if s.Database == "" {
+			return fmt.Errorf("state.database is required for state.backend %q", StateBackendDatabase)
+		}
+		dbCfg, ok := c.GetDatabase(s.Database)
+		if !ok {
+			return fmt.Errorf("state.database '%s' is not defined", s.Database)
+		}
+		if !canHoldState(dbCfg.Type) {
+			return fmt.Errorf("state.database '%s' has type '%s', which cannot hold state (use sqlite, mysql, postgresql, sqlserver, oracle or duckdb)", s.Database, dbCfg.Type)
+		}
+	case StateBackendS3:
+		if s.Bucket == "" {
+			return fmt.Errorf("state.bucket is required for state.backend %q", StateBackendS3)
+		}
+		if s.Endpoint != "" {
+			if u, err := url.Parse(s.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("invalid state.endpoint %q", s.Endpoint)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported state.backend '%s' (must be %q, %q, or %q)", s.Backend, StateBackendFile, StateBackendDatabase, StateBackendS3)
+	}
+	if s.LockTTL != "" {
+		if d, err := time.ParseDuration(s.LockTTL); err != nil || d <= 0 {
+			return fmt.Errorf("invalid state.lock_ttl %q: must be a positive duration", s.LockTTL)
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateLeaderConfig() error {
+	l := &c.Leader
+	if !l.Enabled {
+		return nil
+	}
+	l.Database = strings.TrimSpace(l.Database)
+	l.LockFile = strings.TrimSpace(l.LockFile)
+	switch {
+	case l.Database == "" && l.LockFile == "":
+		return fmt.Errorf("leader election requires leader.database or leader.lock_file")
+	case l.Database != "" && l.LockFile != "":
+		return fmt.Errorf("leader.database and leader.lock_file are mutually exclusive")
+	case l.Database != "":
+		dbCfg, ok := c.GetDatabase(l.Database)
+		if !ok {
+			return fmt.Errorf("leader.database '%s' is not defined", l.Database)
+		}
+		if !canHoldState(dbCfg.Type) {
+			return fmt.Errorf("leader.database '%s' has type '%s', which cannot hold a lease (use sqlite, mysql, postgresql, sqlserver, oracle or duckdb)", l.Database, dbCfg.Type)
+		}
+	}
+	if l.LeaseTTL != "" {
+		if d, err := time.ParseDuration(l.LeaseTTL); err != nil || d <= 0 {
+			return fmt.Errorf("invalid leader.lease_ttl %q: must be a positive duration", l.LeaseTTL)
+		}
+	}
+	return nil
+}
+
+// canHoldState reports whether databases of the type can store state rows and leases.
+func canHoldState(dbType string) bool {
+	switch dbType {
+	case DatabaseTypeSQLite, DatabaseTypeMySQL, DatabaseTypePostgreSQL, DatabaseTypeSQLServer, DatabaseTypeOracle, DatabaseTypeDuckDB:
+		return true
+	}
+	return false
+}
+
+// IsLogical reports whether the task captures changes from a PostgreSQL logical replication slot.
+func (c CDCConfig) IsLogical() bool {
+	return c.Enabled && c.Mode == CDCModeLogical
+}
+
+func validateCDCLogical(c *CDCConfig, mode, resumeKey, sourceType string) error {
+	if !strings.EqualFold(sourceType, DatabaseTypePostgreSQL) {
+		return fmt.Errorf("cdc.mode %q requires a %s source_db", CDCModeLogical, DatabaseTypePostgreSQL)
+	}
+	if mode != TaskModeMerge {
+		return fmt.Errorf("cdc.mode %q requires mode %q with merge_keys", CDCModeLogical, TaskModeMerge)
+	}
+	if resumeKey != "" {
+		return fmt.Errorf("cdc.mode %q does not use resume_key; the slot position is kept in state_file", CDCModeLogical)
+	}
+	if c.DeleteDetection {
+		return fmt.Errorf("cdc.delete_detection is not needed with cdc.mode %q; deletes are read from the replication slot", CDCModeLogical)
+	}
+	c.SlotName = strings.TrimSpace(c.SlotName)
+	c.Publication = strings.TrimSpace(c.Publication)
+	c.SourceTable = strings.TrimSpace(c.SourceTable)
+	if c.SlotName == "" {
+		return fmt.Errorf("cdc.slot_name is required when cdc.mode is %q", CDCModeLogical)
+	}
+	if c.Publication == "" {
+		return fmt.Errorf("cdc.publication is required when cdc.mode is %q", CDCModeLogical)
+	}
+	if c.SourceTable == "" {
+		return fmt.Errorf("cdc.source_table is required when cdc.mode is %q", CDCModeLogical)
+	}
+	return nil
+}
 
+// IsBinlog reports whether the task captures changes from the MySQL binlog.
+func (c CDCConfig) IsBinlog() bool {
+	return c.Enabled && c.Mode == CDCModeBinlog
+}
+
+func validateCDCBinlog(c *CDCConfig, mode, resumeKey, sourceType string) error {
+	if !strings.EqualFold(sourceType, DatabaseTypeMySQL) {
+		return fmt.Errorf("cdc.mode %q requires a %s source_db", CDCModeBinlog, DatabaseTypeMySQL)
+	}
+	if mode != TaskModeMerge {
+		return fmt.Errorf("cdc.mode %q requires mode %q with merge_keys", CDCModeBinlog, TaskModeMerge)
+	}
+	if resumeKey != "" {
+		return fmt.Errorf("cdc.mode %q does not use resume_key; the binlog position is kept in state_file", CDCModeBinlog)
+	}
+	if c.DeleteDetection {
+		return fmt.Errorf("cdc.delete_detection is not needed with cdc.mode %q; deletes are read from the binlog", CDCModeBinlog)
+	}
+	c.SourceTable = strings.TrimSpace(c.SourceTable)
+	if c.SourceTable == "" {
+		return fmt.Errorf("cdc.source_table is required when cdc.mode is %q", CDCModeBinlog)
+	}
+	return nil
+}
+
+func validateCDCDeleteDetection(c *CDCConfig, mode, sql string) error {
+	if !c.DeleteDetection {
+		return nil
+	}
+	if mode != TaskModeMerge {
+		return fmt.Errorf("cdc.delete_detection requires mode %q with merge_keys", TaskModeMerge)
+	}
+	// 删除检测需要读取任务负责的全部源端键；{{.LastValue}} 模板会把游标条件固化进 SQL，
+	// 导致游标之前的行被误判为已删除。
+	if strings.Contains(sql, "{{.LastValue}}") {
+		return fmt.Errorf("cdc.delete_detection does not support {{.LastValue}} in sql; cdc.cursor_column already filters incremental rows")
+	}
+
+	c.DeleteStrategy = strings.ToLower(strings.TrimSpace(c.DeleteStrategy))
+	if c.DeleteStrategy == "" {
+		c.DeleteStrategy = CDCDeleteStrategyKeySet
+	}
+	switch c.DeleteStrategy {
+	case CDCDeleteStrategyKeySet:
+	case CDCDeleteStrategySoftDelete:
+		c.SoftDeleteColumn = strings.TrimSpace(c.SoftDeleteColumn)
+		if c.SoftDeleteColumn == "" {
+			return fmt.Errorf("cdc.soft_delete_column is required when cdc.delete_strategy is %q", CDCDeleteStrategySoftDelete)
+		}
+	default:
+		return fmt.Errorf("cdc.delete_strategy must be %q or %q", CDCDeleteStrategyKeySet, CDCDeleteStrategySoftDelete)
+	}
+
+	c.DeleteAction = strings.ToLower(strings.TrimSpace(c.DeleteAction))
+	if c.DeleteAction == "" {
+		c.DeleteAction = CDCDeleteActionDelete
+	}
+	switch c.DeleteAction {
+	case CDCDeleteActionDelete:
+	case CDCDeleteActionFlag:
+		c.DeleteFlagColumn = strings.TrimSpace(c.DeleteFlagColumn)
+		if c.DeleteFlagColumn == "" {
+			return fmt.Errorf("cdc.delete_flag_column is required when cdc.delete_action is %q", CDCDeleteActionFlag)
+		}
+	default:
+		return fmt.Errorf("cdc.delete_action must be %q or %q", CDCDeleteActionDelete, CDCDeleteActionFlag)
+	}
 	return nil
 }
 
@@ -859,6 +1557,53 @@ func validateScheduleConfig(s *ScheduleConfig) error {
 	return nil
 }
 
+// validateTaskSchedules checks task-level schedules. Tasks sharing a cron
+// expression and timezone run as one cron entry, so the rest of their schedule
+// settings must agree.
+func validateTaskSchedules(tasks []TaskConfig) error {
+	first := make(map[string]int)
+	for i := range tasks {
+		task := &tasks[i]
+		if task.Ignore || task.Schedule.Cron == "" {
+			continue
+		}
+		if err := validateScheduleConfig(&task.Schedule); err != nil {
+			return fmt.Errorf("task %d: %w", i+1, err)
+		}
+		key := task.Schedule.EntryKey()
+		j, ok := first[key]
+		if !ok {
+			first[key] = i
+			continue
+		}
+		if tasks[j].Schedule != task.Schedule {
+			return fmt.Errorf("task %d: schedule shares cron %q with task '%s' but differs in other schedule settings", i+1, task.Schedule.Cron, tasks[j].TableName)
+		}
+	}
+	return nil
+}
+
+// EntryKey identifies the cron entry a task-level schedule belongs to.
+func (s ScheduleConfig) EntryKey() string {
+	if s.Timezone == "" {
+		return s.Cron
+	}
+	return s.Cron + " @ " + s.Timezone
+}
+
+// HasSchedule reports whether the global schedule or any task schedule is set.
+func (c *Config) HasSchedule() bool {
+	if c.Schedule.Cron != "" {
+		return true
+	}
+	for _, task := range c.Tasks {
+		if !task.Ignore && task.Schedule.Cron != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func parseScheduleTime(v string) (time.Time, error) {
 	if t, err := time.Parse(time.RFC3339, v); err == nil {
 		return t, nil
@@ -931,7 +1676,13 @@ func validateDatabaseConfig(db *DatabaseConfig) error {
 		if db.User == "" {
 			return fmt.Errorf("user is required for MySQL database")
 		}
-		if db.Password == "" {
+		switch db.Dialect {
+		case "", DialectMariaDB, DialectTiDB:
+		default:
+			return fmt.Errorf("unsupported dialect '%s' for mysql database (must be %q or %q)", db.Dialect, DialectMariaDB, DialectTiDB)
+		}
+		// TiDB 的 root 用户默认没有密码
+		if db.Password == "" && db.Dialect != DialectTiDB {
 			return fmt.Errorf("password is required for MySQL database")
 		}
 		if db.Database == "" {
@@ -944,7 +1695,13 @@ func validateDatabaseConfig(db *DatabaseConfig) error {
 		if db.User == "" {
 			return fmt.Errorf("user is required for PostgreSQL database")
 		}
-		if db.Password == "" {
+		switch db.Dialect {
+		case "", DialectCockroachDB:
+		default:
+			return fmt.Errorf("unsupported dialect '%s' for postgresql database (must be %q)", db.Dialect, DialectCockroachDB)
+		}
+		// CockroachDB 以证书认证（或 insecure 模式）时不使用密码
+		if db.Password == "" && db.Dialect != DialectCockroachDB {
 			return fmt.Errorf("password is required for PostgreSQL database")
 		}
 		if db.Database == "" {
@@ -963,14 +1720,53 @@ func validateDatabaseConfig(db *DatabaseConfig) error {
 		if db.Database == "" {
 			return fmt.Errorf("database is required for SQL Server database")
 		}
+	case DatabaseTypeClickHouse:
+		// ClickHouse 的 default 用户默认没有密码，因此 password 可以为空
+		if db.Host == "" {
+			return fmt.Errorf("host is required for ClickHouse database")
+		}
+		if db.User == "" {
+			db.User = "default"
+		}
+		if db.Database == "" {
+			db.Database = "default"
+		}
+		db.UpsertStrategy = strings.ToLower(strings.TrimSpace(db.UpsertStrategy))
+		switch db.UpsertStrategy {
+		case "":
+			db.UpsertStrategy = ClickHouseUpsertReplacing
+		case ClickHouseUpsertReplacing, ClickHouseUpsertDeleteInsert:
+		default:
+			return fmt.Errorf("unsupported upsert_strategy '%s' (must be %q or %q)", db.UpsertStrategy, ClickHouseUpsertReplacing, ClickHouseUpsertDeleteInsert)
+		}
 	case DatabaseTypeSQLite, DatabaseTypeDuckDB:
 		if db.Path == "" {
 			return fmt.Errorf("path is required for %s database", db.Type)
 		}
+	case DatabaseTypeCSV, DatabaseTypeJSONL, DatabaseTypeParquet:
+		if db.Path == "" {
+			return fmt.Errorf("path is required for %s database (a file or glob to read, or an output directory)", db.Type)
+		}
+		if len(db.Replicas) > 0 {
+			return fmt.Errorf("replicas are not supported for %s database", db.Type)
+		}
+		if err := validateFileOutput(db); err != nil {
+			return err
+		}
 	default:
 		return fmt.Errorf("unsupported database type '%s'", db.Type)
 	}
 
+	if !db.IsFileDatabase() && (db.Compression != "" || db.MaxRowsPerFile != 0 || len(db.PartitionBy) > 0) {
+		return fmt.Errorf("compression, max_rows_per_file and partition_by are only supported for csv, jsonl and parquet databases")
+	}
+	if db.Type != DatabaseTypeClickHouse && db.UpsertStrategy != "" {
+		return fmt.Errorf("upsert_strategy is only supported for clickhouse databases")
+	}
+	if db.Type != DatabaseTypeMySQL && db.Type != DatabaseTypePostgreSQL && db.Dialect != "" {
+		return fmt.Errorf("dialect is only supported for mysql and postgresql databases")
+	}
+
 	for i, r := range db.Replicas {
 		if r.Host == "" {
 			return fmt.Errorf("replica %d: host is required", i+1)
@@ -984,6 +1780,64 @@ func validateDatabaseConfig(db *DatabaseConfig) error {
 	return nil
 }
 
+// validateFileTargetTask rejects task features that need SQL on the target, which file targets cannot run.
+func validateFileTargetTask(task TaskConfig) error {
+	switch {
+	case task.Mode == TaskModeMerge:
+		return fmt.Errorf("file target '%s' supports only %q and %q modes", task.TargetDB, TaskModeReplace, TaskModeAppend)
+	case len(task.Indexes) > 0:
+		return fmt.Errorf("indexes are not supported for file target '%s'", task.TargetDB)
+	case len(task.PreSQL) > 0 || len(task.PostSQL) > 0:
+		return fmt.Errorf("pre_sql/post_sql are not supported for file target '%s'", task.TargetDB)
+	case task.SchemaEvolution:
+		return fmt.Errorf("schema_evolution is not supported for file target '%s'", task.TargetDB)
+	case task.Transaction.Enabled:
+		return fmt.Errorf("transaction is not supported for file target '%s'", task.TargetDB)
+	case task.CDC.Enabled:
+		return fmt.Errorf("cdc is not supported for file target '%s'", task.TargetDB)
+	}
+	return nil
+}
+
+// validateClickHouseTargetTask rejects task features ClickHouse cannot provide as a target.
+func validateClickHouseTargetTask(task TaskConfig) error {
+	if task.Transaction.Enabled {
+		return fmt.Errorf("transaction is not supported for clickhouse target '%s'", task.TargetDB)
+	}
+	for _, idx := range task.Indexes {
+		if idx.Unique {
+			return fmt.Errorf("index '%s': unique indexes are not supported for clickhouse target '%s'", idx.Name, task.TargetDB)
+		}
+	}
+	return nil
+}
+
+func validateFileOutput(db *DatabaseConfig) error {
+	db.Compression = strings.ToLower(strings.TrimSpace(db.Compression))
+	switch db.Compression {
+	case "", FileCompressionNone, FileCompressionGzip, FileCompressionZstd:
+	case FileCompressionSnappy:
+		if db.Type != DatabaseTypeParquet {
+			return fmt.Errorf("compression %q is only supported for parquet", db.Compression)
+		}
+	default:
+		return fmt.Errorf("unsupported compression '%s' (must be %q, %q, %q, or %q for parquet)", db.Compression, FileCompressionNone, FileCompressionGzip, FileCompressionZstd, FileCompressionSnappy)
+	}
+	if db.MaxRowsPerFile < 0 {
+		return fmt.Errorf("max_rows_per_file must be >= 0")
+	}
+	if db.MaxRowsPerFile == 0 {
+		db.MaxRowsPerFile = DefaultMaxRowsPerFile
+	}
+	for i, col := range db.PartitionBy {
+		db.PartitionBy[i] = strings.TrimSpace(col)
+		if db.PartitionBy[i] == "" {
+			return fmt.Errorf("partition_by contains an empty column name")
+		}
+	}
+	return nil
+}
+
 func validateTLSConfig(db *DatabaseConfig) error {
 	db.SSLMode = strings.ToLower(strings.TrimSpace(db.SSLMode))
 	if db.SSLMode == "" {
@@ -1020,7 +1874,8 @@ func validateTLSConfig(db *DatabaseConfig) error {
 
 func ensureDatabaseSupportsSource(db *DatabaseConfig) error {
 	switch strings.ToLower(db.Type) {
-	case DatabaseTypeOracle, DatabaseTypeMySQL, DatabaseTypeSQLite, DatabaseTypeDuckDB, DatabaseTypePostgreSQL, DatabaseTypeSQLServer:
+	case DatabaseTypeOracle, DatabaseTypeMySQL, DatabaseTypeSQLite, DatabaseTypeDuckDB, DatabaseTypePostgreSQL, DatabaseTypeSQLServer,
+		DatabaseTypeClickHouse, DatabaseTypeCSV, DatabaseTypeJSONL, DatabaseTypeParquet:
 		return nil
 	default:
 		return fmt.Errorf("database '%s' of type '%s' cannot be used as source", db.Name, db.Type)
@@ -1029,13 +1884,34 @@ func ensureDatabaseSupportsSource(db *DatabaseConfig) error {
 
 func ensureDatabaseSupportsTarget(db *DatabaseConfig) error {
 	switch strings.ToLower(db.Type) {
-	case DatabaseTypeOracle, DatabaseTypeMySQL, DatabaseTypeSQLite, DatabaseTypeDuckDB, DatabaseTypePostgreSQL, DatabaseTypeSQLServer:
+	case DatabaseTypeOracle, DatabaseTypeMySQL, DatabaseTypeSQLite, DatabaseTypeDuckDB, DatabaseTypePostgreSQL, DatabaseTypeSQLServer,
+		DatabaseTypeClickHouse, DatabaseTypeCSV, DatabaseTypeJSONL, DatabaseTypeParquet:
 		return nil
 	default:
 		return fmt.Errorf("database '%s' of type '%s' cannot be used as target", db.Name, db.Type)
 	}
 }
 
+// IsFileDatabase reports whether the database reads or writes local csv/jsonl/parquet files
+// rather than connecting to a server.
+func (db DatabaseConfig) IsFileDatabase() bool {
+	switch strings.ToLower(db.Type) {
+	case DatabaseTypeCSV, DatabaseTypeJSONL, DatabaseTypeParquet:
+		return true
+	default:
+		return false
+	}
+}
+
+// SQLDialect returns the dialect of queries run against the database.
+// File databases are queried through an embedded DuckDB.
+func (db DatabaseConfig) SQLDialect() string {
+	if db.IsFileDatabase() {
+		return DatabaseTypeDuckDB
+	}
+	return db.Type
+}
+
 // GetDatabase retrieves a database configuration by name.
 func (c *Config) GetDatabase(name string) (DatabaseConfig, bool) {
 	db, ok := c.databaseMap[name]
@@ -1131,6 +2007,63 @@ func detectTaskCycle(tasks []TaskConfig) error {
 	return nil
 }
 
+// SelectTasks keeps only the named tasks and marks every other task as
+// ignored. With upstream the tasks they depend on are kept as well, with
+// downstream the tasks depending on them. Names must refer to tasks that
+// are not ignored.
+func (c *Config) SelectTasks(names []string, upstream, downstream bool) error {
+	deps := make(map[string][]string)
+	dependents := make(map[string][]string)
+	for _, task := range c.Tasks {
+		if task.Ignore {
+			continue
+		}
+		if _, ok := deps[task.TableName]; !ok {
+			deps[task.TableName] = nil
+		}
+		deps[task.TableName] = append(deps[task.TableName], task.DependsOn...)
+		for _, dep := range task.DependsOn {
+			dependents[dep] = append(dependents[dep], task.TableName)
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := deps[name]; !ok {
+			return fmt.Errorf("task '%s' not found", name)
+		}
+		selected[name] = true
+	}
+	// 上游与下游分别沿依赖边扩展，避免经由上游再扩展到无关的下游
+	if upstream {
+		collectTaskClosure(names, deps, selected)
+	}
+	if downstream {
+		collectTaskClosure(names, dependents, selected)
+	}
+
+	for i := range c.Tasks {
+		if !selected[c.Tasks[i].TableName] {
+			c.Tasks[i].Ignore = true
+		}
+	}
+	return nil
+}
+
+func collectTaskClosure(names []string, edges map[string][]string, selected map[string]bool) {
+	queue := append([]string(nil), names...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, next := range edges[name] {
+			if !selected[next] {
+				selected[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+}
+
 func normalizeKeys(keys []string) ([]string, error) {
 	if len(keys) == 0 {
 		return nil, nil
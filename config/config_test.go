@@ -131,6 +131,54 @@ func TestValidateRejectsInvalidTaskConstraints(t *testing.T) {
 		}
 	})
 
+	t.Run("composite resume_key normalized", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].ResumeKey = " tenant_id ,id "
+		cfg.Tasks[0].ResumeFrom = "'acme', 0"
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected composite resume_key to pass, got %v", err)
+		}
+		if got := cfg.Tasks[0].ResumeKey; got != "tenant_id, id" {
+			t.Fatalf("expected normalized resume_key, got %q", got)
+		}
+		if keys := cfg.Tasks[0].ResumeKeys(); len(keys) != 2 || keys[0] != "tenant_id" || keys[1] != "id" {
+			t.Fatalf("unexpected resume keys %v", keys)
+		}
+	})
+
+	t.Run("composite resume_key rejects empty column", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].ResumeKey = "tenant_id,,id"
+		cfg.Tasks[0].ResumeFrom = "'acme', 0"
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "resume_key contains an empty column") {
+			t.Fatalf("expected empty column error, got %v", err)
+		}
+	})
+
+	t.Run("composite resume_key rejects LastValue template", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].SQL = "SELECT * FROM users WHERE id > {{.LastValue}}"
+		cfg.Tasks[0].ResumeKey = "tenant_id, id"
+		cfg.Tasks[0].ResumeFrom = "'acme', 0"
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "composite resume_key does not support {{.LastValue}}") {
+			t.Fatalf("expected LastValue error, got %v", err)
+		}
+	})
+
+	t.Run("shard requires single-column resume_key", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeAppend
+		cfg.Tasks[0].ResumeKey = "tenant_id, id"
+		cfg.Tasks[0].ResumeFrom = "'acme', 0"
+		cfg.Tasks[0].Shard = ShardConfig{Enabled: true, Shards: 4}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "shard requires a single-column resume_key") {
+			t.Fatalf("expected single-column shard error, got %v", err)
+		}
+	})
+
 	t.Run("shard requires resume_key", func(t *testing.T) {
 		cfg := baseConfig(t)
 		cfg.Tasks[0].Shard = ShardConfig{Enabled: true, Shards: 4}
@@ -163,15 +211,14 @@ func TestValidateRejectsInvalidTaskConstraints(t *testing.T) {
 		}
 	})
 
-	t.Run("shard rejected with state_file", func(t *testing.T) {
+	t.Run("shard allowed with state_file", func(t *testing.T) {
 		cfg := baseConfig(t)
 		cfg.Tasks[0].Mode = TaskModeAppend
 		cfg.Tasks[0].ResumeKey = "id"
 		cfg.Tasks[0].StateFile = "state.json"
 		cfg.Tasks[0].Shard = ShardConfig{Enabled: true, Shards: 4}
-		err := cfg.Validate()
-		if err == nil || !strings.Contains(err.Error(), "state_file is not supported with shard") {
-			t.Fatalf("expected shard state_file error, got %v", err)
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected shard with state_file to pass, got %v", err)
 		}
 	})
 
@@ -184,6 +231,44 @@ func TestValidateRejectsInvalidTaskConstraints(t *testing.T) {
 		if err := cfg.Validate(); err != nil {
 			t.Fatalf("expected shard append mode to pass, got %v", err)
 		}
+		if cfg.Tasks[0].Shard.Strategy != ShardStrategyRange {
+			t.Fatalf("expected default shard.strategy %q, got %q", ShardStrategyRange, cfg.Tasks[0].Shard.Strategy)
+		}
+	})
+
+	t.Run("shard quantile defaults sample_size", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeAppend
+		cfg.Tasks[0].ResumeKey = "id"
+		cfg.Tasks[0].ResumeFrom = "0"
+		cfg.Tasks[0].Shard = ShardConfig{Enabled: true, Shards: 4, Strategy: " Quantile ", Rebalance: true}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected quantile shard to pass, got %v", err)
+		}
+		if got := cfg.Tasks[0].Shard; got.Strategy != ShardStrategyQuantile || got.SampleSize != DefaultShardSampleSize {
+			t.Fatalf("unexpected normalized shard config: %+v", got)
+		}
+	})
+
+	t.Run("shard strategy and sample_size rules", func(t *testing.T) {
+		tests := []struct {
+			shard ShardConfig
+			want  string
+		}{
+			{ShardConfig{Enabled: true, Shards: 4, Strategy: "hash"}, "unsupported shard.strategy"},
+			{ShardConfig{Enabled: true, Shards: 4, Strategy: ShardStrategyQuantile, SampleSize: -1}, "shard.sample_size must be >= 0"},
+			{ShardConfig{Enabled: true, Shards: 4, Strategy: ShardStrategyNTile, SampleSize: 100}, "shard.sample_size requires shard.strategy"},
+		}
+		for _, tt := range tests {
+			cfg := baseConfig(t)
+			cfg.Tasks[0].Mode = TaskModeAppend
+			cfg.Tasks[0].ResumeKey = "id"
+			cfg.Tasks[0].ResumeFrom = "0"
+			cfg.Tasks[0].Shard = tt.shard
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("shard %+v: expected %q error, got %v", tt.shard, tt.want, err)
+			}
+		}
 	})
 }
 
@@ -344,6 +429,66 @@ func TestValidateDatabaseConfig(t *testing.T) {
 			db:      DatabaseConfig{Type: DatabaseTypeDuckDB, Path: "x.duckdb"},
 			wantErr: false,
 		},
+		{
+			name:    "mysql mariadb dialect",
+			db:      DatabaseConfig{Type: DatabaseTypeMySQL, Host: "h", User: "u", Password: "p", Database: "d", Dialect: DialectMariaDB},
+			wantErr: false,
+		},
+		{
+			name:    "mysql tidb without password",
+			db:      DatabaseConfig{Type: DatabaseTypeMySQL, Host: "h", User: "root", Database: "d", Dialect: DialectTiDB},
+			wantErr: false,
+		},
+		{
+			name:    "mysql cockroachdb dialect",
+			db:      DatabaseConfig{Type: DatabaseTypeMySQL, Host: "h", User: "u", Password: "p", Database: "d", Dialect: DialectCockroachDB},
+			wantErr: true,
+		},
+		{
+			name:    "postgresql cockroachdb without password",
+			db:      DatabaseConfig{Type: DatabaseTypePostgreSQL, Host: "h", User: "root", Database: "d", Dialect: DialectCockroachDB},
+			wantErr: false,
+		},
+		{
+			name:    "dialect on sqlite",
+			db:      DatabaseConfig{Type: DatabaseTypeSQLite, Path: "x.db", Dialect: DialectTiDB},
+			wantErr: true,
+		},
+		{
+			name:    "clickhouse valid without password",
+			db:      DatabaseConfig{Type: DatabaseTypeClickHouse, Host: "h"},
+			wantErr: false,
+		},
+		{
+			name:    "clickhouse delete_insert strategy",
+			db:      DatabaseConfig{Type: DatabaseTypeClickHouse, Host: "h", UpsertStrategy: "DELETE_INSERT"},
+			wantErr: false,
+		},
+		{
+			name:    "clickhouse unknown strategy",
+			db:      DatabaseConfig{Type: DatabaseTypeClickHouse, Host: "h", UpsertStrategy: "collapsing"},
+			wantErr: true,
+		},
+		{
+			name:    "upsert_strategy on mysql",
+			db:      DatabaseConfig{Type: DatabaseTypeMySQL, Host: "h", User: "u", Password: "p", Database: "d", UpsertStrategy: "replacing"},
+			wantErr: true,
+		},
+		{
+			name:    "csv valid",
+			db:      DatabaseConfig{Type: DatabaseTypeCSV, Path: "drops/*.csv"},
+			wantErr: false,
+		},
+		{
+			name:    "parquet missing path",
+			db:      DatabaseConfig{Type: DatabaseTypeParquet},
+			wantErr: true,
+		},
+		{
+			name:    "jsonl with replicas",
+			db:      DatabaseConfig{Type: DatabaseTypeJSONL, Path: "x.jsonl", Replicas: []ReplicaConfig{{Host: "r1"}}},
+			wantErr: true,
+		},
 		{
 			name:    "unsupported type",
 			db:      DatabaseConfig{Type: "mongo"},
@@ -362,6 +507,90 @@ func TestValidateDatabaseConfig(t *testing.T) {
 	}
 }
 
+func TestValidateFileTargets(t *testing.T) {
+	fileTarget := func(t *testing.T) *Config {
+		cfg := baseConfig(t)
+		cfg.Databases[1] = DatabaseConfig{Name: "dst", Type: DatabaseTypeParquet, Path: t.TempDir()}
+		return cfg
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg := fileTarget(t)
+		cfg.Databases[1].Compression = " ZSTD "
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		db, _ := cfg.GetDatabase("dst")
+		if db.Compression != FileCompressionZstd || db.MaxRowsPerFile != DefaultMaxRowsPerFile {
+			t.Fatalf("unexpected file target settings: compression=%q max_rows_per_file=%d", db.Compression, db.MaxRowsPerFile)
+		}
+	})
+
+	cases := []struct {
+		name   string
+		mutate func(cfg *Config)
+		want   string
+	}{
+		{"merge mode", func(cfg *Config) { cfg.Tasks[0].Mode = TaskModeUpsert; cfg.Tasks[0].MergeKeys = []string{"id"} }, "supports only"},
+		{"indexes", func(cfg *Config) { cfg.Tasks[0].Indexes = []IndexConfig{{Name: "idx", Columns: []string{"id"}}} }, "indexes are not supported"},
+		{"post_sql", func(cfg *Config) { cfg.Tasks[0].PostSQL = []string{"SELECT 1"} }, "pre_sql/post_sql"},
+		{"snappy csv", func(cfg *Config) { cfg.Databases[1].Type = DatabaseTypeCSV; cfg.Databases[1].Compression = "snappy" }, "only supported for parquet"},
+		{"negative max rows", func(cfg *Config) { cfg.Databases[1].MaxRowsPerFile = -1 }, "max_rows_per_file must be >= 0"},
+		{"file settings on sqlite", func(cfg *Config) { cfg.Databases[0].PartitionBy = []string{"id"} }, "only supported for csv, jsonl and parquet"},
+		{"read and written", func(cfg *Config) {
+			cfg.Tasks = append(cfg.Tasks, TaskConfig{TableName: "copy", SQL: "SELECT * FROM dst", SourceDB: "dst", TargetDB: "src"})
+		}, "cannot also be read as a source"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := fileTarget(t)
+			tc.mutate(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateClickHouseTargets(t *testing.T) {
+	clickHouseTarget := func(t *testing.T) *Config {
+		cfg := baseConfig(t)
+		cfg.Databases[1] = DatabaseConfig{Name: "dst", Type: DatabaseTypeClickHouse, Host: "ch"}
+		return cfg
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg := clickHouseTarget(t)
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		db, _ := cfg.GetDatabase("dst")
+		if db.Port != "8123" || db.User != "default" || db.Database != "default" || db.UpsertStrategy != ClickHouseUpsertReplacing {
+			t.Fatalf("unexpected clickhouse defaults: %+v", db)
+		}
+	})
+
+	cases := []struct {
+		name   string
+		mutate func(cfg *Config)
+		want   string
+	}{
+		{"transaction", func(cfg *Config) { cfg.Tasks[0].Transaction.Enabled = true }, "transaction is not supported"},
+		{"unique index", func(cfg *Config) {
+			cfg.Tasks[0].Indexes = []IndexConfig{{Name: "uk", Columns: []string{"id"}, Unique: true}}
+		}, "unique indexes are not supported"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := clickHouseTarget(t)
+			tc.mutate(cfg)
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
 func TestResolveReplicaConfig(t *testing.T) {
 	primary := DatabaseConfig{
 		Name:     "prod",
@@ -476,6 +705,20 @@ func TestEnsureDatabaseSupportsSourceAndTarget(t *testing.T) {
 		t.Fatalf("ensureDatabaseSupportsTarget() error = %v", err)
 	}
 
+	fileDB := DatabaseConfig{Name: "drop", Type: DatabaseTypeCSV}
+	if err := ensureDatabaseSupportsSource(&fileDB); err != nil {
+		t.Fatalf("ensureDatabaseSupportsSource(csv) error = %v", err)
+	}
+	if err := ensureDatabaseSupportsTarget(&fileDB); err != nil {
+		t.Fatalf("ensureDatabaseSupportsTarget(csv) error = %v", err)
+	}
+	if !fileDB.IsFileDatabase() || fileDB.SQLDialect() != DatabaseTypeDuckDB {
+		t.Fatalf("expected csv to be a file database queried as duckdb, got %v %q", fileDB.IsFileDatabase(), fileDB.SQLDialect())
+	}
+	if okDB.IsFileDatabase() || okDB.SQLDialect() != DatabaseTypeMySQL {
+		t.Fatalf("expected mysql dialect, got %q", okDB.SQLDialect())
+	}
+
 	bad := DatabaseConfig{Name: "x", Type: "mongo"}
 	if err := ensureDatabaseSupportsSource(&bad); err == nil {
 		t.Fatalf("expected source support error")
@@ -581,6 +824,22 @@ func TestValidateTaskFieldErrors(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid timeout", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Timeout = "soon"
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "invalid timeout") {
+			t.Fatalf("expected invalid timeout error, got %v", err)
+		}
+		cfg.Tasks[0].Timeout = "0s"
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "timeout must be > 0") {
+			t.Fatalf("expected non-positive timeout error, got %v", err)
+		}
+		cfg.Tasks[0].Timeout = "30m"
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	})
+
 	t.Run("adaptive batch min_size must be > 0 when enabled", func(t *testing.T) {
 		cfg := baseConfig(t)
 		cfg.Tasks[0].AdaptiveBatch = AdaptiveBatchConfig{Enabled: true, MinSize: 0, MaxSize: 100, TargetLatencyMs: 100, MemoryLimitMB: 10}
@@ -621,6 +880,109 @@ func TestValidateTaskFieldErrors(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid load_method", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].LoadMethod = "copy"
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "load_method must be") {
+			t.Fatalf("expected load_method error, got %v", err)
+		}
+		cfg.Tasks[0].LoadMethod = " BULK "
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.Tasks[0].LoadMethod != LoadMethodBulk {
+			t.Fatalf("expected load_method to normalize to %q, got %q", LoadMethodBulk, cfg.Tasks[0].LoadMethod)
+		}
+	})
+
+	t.Run("bulk load_method rejected in merge mode", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].LoadMethod = LoadMethodBulk
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "load_method \"bulk\" is only valid") {
+			t.Fatalf("expected bulk merge error, got %v", err)
+		}
+	})
+
+	t.Run("transaction commit_every must be >= 0", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Transaction = TransactionConfig{Enabled: true, CommitEvery: -1}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transaction.commit_every must be >= 0") {
+			t.Fatalf("expected transaction commit_every error, got %v", err)
+		}
+	})
+
+	t.Run("transaction rejected with shard", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeAppend
+		cfg.Tasks[0].ResumeKey = "id"
+		cfg.Tasks[0].ResumeFrom = "0"
+		cfg.Tasks[0].Shard = ShardConfig{Enabled: true, Shards: 4}
+		cfg.Tasks[0].Transaction = TransactionConfig{Enabled: true}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "transaction is not supported with shard") {
+			t.Fatalf("expected transaction shard error, got %v", err)
+		}
+	})
+
+	t.Run("transaction valid config passes", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Transaction = TransactionConfig{Enabled: true, CommitEvery: 10}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected transaction config to pass, got %v", err)
+		}
+	})
+
+	t.Run("pipeline defaults applied", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Pipeline = PipelineConfig{Enabled: true, Writers: 3}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		got := cfg.Tasks[0].Pipeline
+		if got.TransformWorkers != 2 || got.Writers != 3 || got.QueueSize != 4 {
+			t.Fatalf("unexpected pipeline defaults: %+v", got)
+		}
+	})
+
+	t.Run("pipeline merge mode uses one writer", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeUpsert
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].Pipeline = PipelineConfig{Enabled: true}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if got := cfg.Tasks[0].Pipeline.Writers; got != 1 {
+			t.Fatalf("pipeline.writers = %d, want 1 in merge mode", got)
+		}
+
+		cfg = baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].Pipeline = PipelineConfig{Enabled: true, Writers: 2}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "pipeline.writers must be 1 in merge mode") {
+			t.Fatalf("expected merge writers error, got %v", err)
+		}
+	})
+
+	t.Run("pipeline negative values rejected", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Pipeline = PipelineConfig{Enabled: true, QueueSize: -1}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "must be >= 0") {
+			t.Fatalf("expected pipeline range error, got %v", err)
+		}
+	})
+
+	t.Run("pipeline rejected with transaction", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Pipeline = PipelineConfig{Enabled: true}
+		cfg.Tasks[0].Transaction = TransactionConfig{Enabled: true}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "pipeline is not supported with transaction") {
+			t.Fatalf("expected pipeline transaction error, got %v", err)
+		}
+	})
+
 	t.Run("invalid dlq format", func(t *testing.T) {
 		cfg := baseConfig(t)
 		cfg.Tasks[0].DLQFormat = "xml"
@@ -1287,6 +1649,86 @@ func TestValidateMaskingRules(t *testing.T) {
 			t.Fatalf("expected value error, got %v", err)
 		}
 	})
+
+	t.Run("keyed rules require a key source", func(t *testing.T) {
+		for _, rule := range []string{MaskRuleHMAC, MaskRuleFPE, MaskRuleToken} {
+			cfg := baseConfig(t)
+			cfg.Tasks[0].Masking = []MaskingConfig{{Column: "phone", Rule: rule}}
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), "requires key_env or key_file") {
+				t.Fatalf("rule %s: expected key error, got %v", rule, err)
+			}
+		}
+	})
+
+	t.Run("keyed rules inherit global key", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Masking = MaskingDefaults{KeyEnv: "MASK_KEY"}
+		cfg.Tasks[0].Masking = []MaskingConfig{
+			{Column: "phone", Rule: MaskRuleFPE},
+			{Column: "email", Rule: MaskRuleHMAC, KeyFile: "/etc/db-ferry/key"},
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if got := cfg.Tasks[0].Masking[0]; got.KeyEnv != "MASK_KEY" || got.KeyFile != "" {
+			t.Fatalf("expected inherited key_env, got %+v", got)
+		}
+		if got := cfg.Tasks[0].Masking[1]; got.KeyEnv != "" || got.KeyFile != "/etc/db-ferry/key" {
+			t.Fatalf("expected rule key_file to win, got %+v", got)
+		}
+	})
+
+	t.Run("fake rules resolve locale and seed", func(t *testing.T) {
+		seed := int64(42)
+		cfg := baseConfig(t)
+		cfg.Masking = MaskingDefaults{Locale: "zh-cn", Seed: &seed}
+		cfg.Tasks[0].Masking = []MaskingConfig{
+			{Column: "name", Rule: MaskRuleFakeName},
+			{Column: "email", Rule: MaskRuleFakeEmail, Locale: "EN_us"},
+			{Column: "phone", Rule: MaskRulePhoneCN},
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if got := cfg.Tasks[0].Masking[0]; got.Locale != MaskLocaleZhCN || got.Seed == nil || *got.Seed != 42 {
+			t.Fatalf("expected inherited locale and seed, got %+v", got)
+		}
+		if got := cfg.Tasks[0].Masking[1]; got.Locale != MaskLocaleEnUS {
+			t.Fatalf("expected normalized rule locale, got %q", got.Locale)
+		}
+		if got := cfg.Tasks[0].Masking[2]; got.Locale != "" || got.Seed != nil {
+			t.Fatalf("expected non-fake rule untouched, got %+v", got)
+		}
+	})
+
+	t.Run("fake rules reject unknown locale", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Masking = []MaskingConfig{{Column: "name", Rule: MaskRuleFakeName, Locale: "fr_FR"}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unsupported masking locale") {
+			t.Fatalf("expected locale error, got %v", err)
+		}
+
+		cfg = baseConfig(t)
+		cfg.Masking = MaskingDefaults{Locale: "fr_FR"}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "masking.locale") {
+			t.Fatalf("expected global locale error, got %v", err)
+		}
+	})
+
+	t.Run("key_env and key_file are exclusive", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Masking = []MaskingConfig{{Column: "phone", Rule: MaskRuleHMAC, KeyEnv: "K", KeyFile: "k"}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+			t.Fatalf("expected exclusive error, got %v", err)
+		}
+
+		cfg = baseConfig(t)
+		cfg.Masking = MaskingDefaults{KeyEnv: "K", KeyFile: "k"}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+			t.Fatalf("expected exclusive error, got %v", err)
+		}
+	})
 }
 
 func TestAssertionValidation(t *testing.T) {
@@ -1546,6 +1988,187 @@ func TestValidateCDCConfig(t *testing.T) {
 			t.Fatalf("expected resume_key mismatch error, got %v", err)
 		}
 	})
+
+	t.Run("logical cdc validates slot settings", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Databases[0] = DatabaseConfig{Name: "src", Type: DatabaseTypePostgreSQL, Host: "h", User: "u", Password: "p", Database: "d"}
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].StateFile = "./state.json"
+		cfg.Tasks[0].CDC = CDCConfig{
+			Enabled:      true,
+			Mode:         " Logical ",
+			PollInterval: "5s",
+			SlotName:     "dbf_users",
+			Publication:  "dbf_pub",
+			SourceTable:  "public.users",
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected valid logical CDC config to pass, got %v", err)
+		}
+		if !cfg.Tasks[0].CDC.IsLogical() || cfg.Tasks[0].ResumeKey != "" {
+			t.Fatalf("expected logical mode without resume_key, got %+v", cfg.Tasks[0].CDC)
+		}
+
+		cfg.Tasks[0].CDC.SlotName = ""
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "cdc.slot_name is required") {
+			t.Fatalf("expected slot_name required error, got %v", err)
+		}
+	})
+
+	t.Run("logical cdc requires postgresql source", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].StateFile = "./state.json"
+		cfg.Tasks[0].CDC = CDCConfig{Enabled: true, Mode: CDCModeLogical, PollInterval: "5s", SlotName: "s", Publication: "p", SourceTable: "users"}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "requires a postgresql source_db") {
+			t.Fatalf("expected postgresql source error, got %v", err)
+		}
+	})
+
+	t.Run("binlog cdc validates source settings", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Databases[0] = DatabaseConfig{Name: "src", Type: DatabaseTypeMySQL, Host: "h", User: "u", Password: "p", Database: "d"}
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].StateFile = "./state.json"
+		cfg.Tasks[0].CDC = CDCConfig{Enabled: true, Mode: "BINLOG", PollInterval: "5s", SourceTable: " app.users "}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected valid binlog CDC config to pass, got %v", err)
+		}
+		if !cfg.Tasks[0].CDC.IsBinlog() || cfg.Tasks[0].CDC.SourceTable != "app.users" || cfg.Tasks[0].ResumeKey != "" {
+			t.Fatalf("expected binlog mode without resume_key, got %+v", cfg.Tasks[0].CDC)
+		}
+
+		cfg.Tasks[0].CDC.SourceTable = ""
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "cdc.source_table is required") {
+			t.Fatalf("expected source_table required error, got %v", err)
+		}
+
+		cfg.Tasks[0].CDC.SourceTable = "users"
+		cfg.Tasks[0].Plugin = PluginConfig{Engine: "lua", Script: "return row"}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "plugin is not supported with cdc.mode") {
+			t.Fatalf("expected plugin rejection, got %v", err)
+		}
+	})
+
+	t.Run("binlog cdc requires mysql source", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].StateFile = "./state.json"
+		cfg.Tasks[0].CDC = CDCConfig{Enabled: true, Mode: CDCModeBinlog, PollInterval: "5s", SourceTable: "users"}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "requires a mysql source_db") {
+			t.Fatalf("expected mysql source error, got %v", err)
+		}
+	})
+
+	t.Run("cdc rejects unknown mode", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeAppend
+		cfg.Tasks[0].StateFile = "./state.json"
+		cfg.Tasks[0].CDC = CDCConfig{Enabled: true, Mode: "trigger", CursorColumn: "id", PollInterval: "5m"}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cdc.mode") {
+			t.Fatalf("expected cdc.mode error, got %v", err)
+		}
+	})
+}
+
+func TestValidateCDCDeleteDetection(t *testing.T) {
+	cdcMergeConfig := func(t *testing.T, cdc CDCConfig) *Config {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeMerge
+		cfg.Tasks[0].MergeKeys = []string{"id"}
+		cfg.Tasks[0].StateFile = "./state.json"
+		cdc.Enabled = true
+		cdc.CursorColumn = "updated_at"
+		cdc.PollInterval = "5m"
+		cfg.Tasks[0].CDC = cdc
+		return cfg
+	}
+
+	t.Run("defaults to key_set and delete", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{DeleteDetection: true})
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		got := cfg.Tasks[0].CDC
+		if got.DeleteStrategy != CDCDeleteStrategyKeySet || got.DeleteAction != CDCDeleteActionDelete {
+			t.Fatalf("unexpected defaults: strategy=%q action=%q", got.DeleteStrategy, got.DeleteAction)
+		}
+	})
+
+	t.Run("requires merge mode", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Mode = TaskModeAppend
+		cfg.Tasks[0].StateFile = "./state.json"
+		cfg.Tasks[0].CDC = CDCConfig{Enabled: true, CursorColumn: "updated_at", PollInterval: "5m", DeleteDetection: true}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cdc.delete_detection requires mode") {
+			t.Fatalf("expected merge mode error, got %v", err)
+		}
+	})
+
+	t.Run("rejects cursor template sql", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{DeleteDetection: true})
+		cfg.Tasks[0].SQL = "SELECT id, updated_at FROM users WHERE updated_at > {{.LastValue}}"
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "does not support {{.LastValue}}") {
+			t.Fatalf("expected template sql error, got %v", err)
+		}
+	})
+
+	t.Run("soft_delete requires column", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{DeleteDetection: true, DeleteStrategy: "Soft_Delete"})
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cdc.soft_delete_column is required") {
+			t.Fatalf("expected soft_delete_column error, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown strategy", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{DeleteDetection: true, DeleteStrategy: "trigger"})
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cdc.delete_strategy must be") {
+			t.Fatalf("expected strategy error, got %v", err)
+		}
+	})
+
+	t.Run("flag requires flag column", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{DeleteDetection: true, DeleteAction: "flag"})
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cdc.delete_flag_column is required") {
+			t.Fatalf("expected delete_flag_column error, got %v", err)
+		}
+	})
+
+	t.Run("rejects unknown action", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{DeleteDetection: true, DeleteAction: "archive"})
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cdc.delete_action must be") {
+			t.Fatalf("expected action error, got %v", err)
+		}
+	})
+
+	t.Run("soft_delete with flag passes", func(t *testing.T) {
+		cfg := cdcMergeConfig(t, CDCConfig{
+			DeleteDetection:  true,
+			DeleteStrategy:   CDCDeleteStrategySoftDelete,
+			SoftDeleteColumn: " deleted_at ",
+			DeleteAction:     CDCDeleteActionFlag,
+			DeleteFlagColumn: "is_deleted",
+		})
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.Tasks[0].CDC.SoftDeleteColumn != "deleted_at" {
+			t.Fatalf("expected trimmed soft_delete_column, got %q", cfg.Tasks[0].CDC.SoftDeleteColumn)
+		}
+	})
 }
 
 func TestValidateScheduleConfig(t *testing.T) {
@@ -1617,3 +2240,217 @@ func TestValidateScheduleMaxRetry(t *testing.T) {
 		t.Fatalf("expected max_retry error, got %v", err)
 	}
 }
+
+func TestValidateStateConfig(t *testing.T) {
+	t.Run("defaults to file backend", func(t *testing.T) {
+		cfg := baseConfig(t)
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.State.Backend != StateBackendFile || cfg.State.Table() != "db_ferry_state" || cfg.State.LockTTLDuration() != 5*time.Minute {
+			t.Fatalf("unexpected state defaults: %+v", cfg.State)
+		}
+	})
+
+	t.Run("database backend", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.State = StateConfig{Backend: " Database ", Database: "dst", LockTTL: "30s"}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.State.Backend != StateBackendDatabase || cfg.State.LockTTLDuration() != 30*time.Second {
+			t.Fatalf("unexpected state config: %+v", cfg.State)
+		}
+	})
+
+	t.Run("s3 backend", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.State = StateConfig{Backend: StateBackendS3, Bucket: "ferry", Endpoint: "http://minio:9000", PathStyle: true}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		state StateConfig
+		want  string
+	}{
+		{"unknown backend", StateConfig{Backend: "redis"}, "unsupported state.backend"},
+		{"database without name", StateConfig{Backend: StateBackendDatabase}, "state.database is required"},
+		{"undefined database", StateConfig{Backend: StateBackendDatabase, Database: "missing"}, "state.database 'missing' is not defined"},
+		{"s3 without bucket", StateConfig{Backend: StateBackendS3}, "state.bucket is required"},
+		{"invalid endpoint", StateConfig{Backend: StateBackendS3, Bucket: "b", Endpoint: "minio"}, "invalid state.endpoint"},
+		{"invalid lock ttl", StateConfig{LockTTL: "-1m"}, "invalid state.lock_ttl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := baseConfig(t)
+			cfg.State = tt.state
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("database type that cannot hold state", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Databases = append(cfg.Databases, DatabaseConfig{Name: "ch", Type: DatabaseTypeClickHouse, Host: "localhost", Port: "9000", Database: "default"})
+		cfg.State = StateConfig{Backend: StateBackendDatabase, Database: "ch"}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "cannot hold state") {
+			t.Fatalf("Validate() error = %v, want cannot hold state", err)
+		}
+	})
+}
+
+func TestValidateLeaderConfig(t *testing.T) {
+	t.Run("disabled skips validation", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Leader = LeaderConfig{LeaseTTL: "bad"}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	})
+
+	t.Run("database lease", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Leader = LeaderConfig{Enabled: true, Database: "dst", LeaseTTL: "10s"}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.Leader.Table() != "db_ferry_leader" || cfg.Leader.LeaseName() != "db-ferry" || cfg.Leader.LeaseTTLDuration() != 10*time.Second {
+			t.Fatalf("unexpected leader defaults: %+v", cfg.Leader)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		leader LeaderConfig
+		want   string
+	}{
+		{"no backend", LeaderConfig{Enabled: true}, "requires leader.database or leader.lock_file"},
+		{"both backends", LeaderConfig{Enabled: true, Database: "dst", LockFile: "leader.lock"}, "mutually exclusive"},
+		{"undefined database", LeaderConfig{Enabled: true, Database: "missing"}, "leader.database 'missing' is not defined"},
+		{"invalid lease ttl", LeaderConfig{Enabled: true, LockFile: "leader.lock", LeaseTTL: "0s"}, "invalid leader.lease_ttl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := baseConfig(t)
+			cfg.Leader = tt.leader
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTaskSchedules(t *testing.T) {
+	t.Run("task schedules form entries", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks = append(cfg.Tasks,
+			TaskConfig{TableName: "orders", SQL: "SELECT 1", SourceDB: "src", TargetDB: "dst", Schedule: ScheduleConfig{Cron: "@hourly", Timezone: "UTC"}},
+			TaskConfig{TableName: "items", SQL: "SELECT 1", SourceDB: "src", TargetDB: "dst", Schedule: ScheduleConfig{Cron: "@hourly", Timezone: "UTC"}},
+		)
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if !cfg.HasSchedule() {
+			t.Fatal("HasSchedule() = false, want true")
+		}
+		if key := cfg.Tasks[1].Schedule.EntryKey(); key != "@hourly @ UTC" {
+			t.Fatalf("EntryKey() = %q", key)
+		}
+	})
+
+	t.Run("no schedule", func(t *testing.T) {
+		cfg := baseConfig(t)
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+		if cfg.HasSchedule() {
+			t.Fatal("HasSchedule() = true, want false")
+		}
+	})
+
+	t.Run("invalid task cron", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Schedule = ScheduleConfig{Cron: "bad"}
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "task 1: invalid schedule.cron") {
+			t.Fatalf("Validate() error = %v, want invalid task cron", err)
+		}
+	})
+
+	t.Run("conflicting settings in one entry", func(t *testing.T) {
+		cfg := baseConfig(t)
+		cfg.Tasks[0].Schedule = ScheduleConfig{Cron: "@hourly", MaxRetry: 1}
+		cfg.Tasks = append(cfg.Tasks, TaskConfig{TableName: "orders", SQL: "SELECT 1", SourceDB: "src", TargetDB: "dst", Schedule: ScheduleConfig{Cron: "@hourly", MaxRetry: 2}})
+		err := cfg.Validate()
+		if err == nil || !strings.Contains(err.Error(), "differs in other schedule settings") {
+			t.Fatalf("Validate() error = %v, want conflicting schedule error", err)
+		}
+	})
+}
+
+func TestSelectTasks(t *testing.T) {
+	newCfg := func() *Config {
+		return &Config{Tasks: []TaskConfig{
+			{TableName: "a"},
+			{TableName: "b", DependsOn: []string{"a"}},
+			{TableName: "c", DependsOn: []string{"b"}},
+			{TableName: "d", DependsOn: []string{"a"}},
+			{TableName: "e"},
+			{TableName: "f", Ignore: true},
+		}}
+	}
+	kept := func(cfg *Config) string {
+		var names []string
+		for _, task := range cfg.Tasks {
+			if !task.Ignore {
+				names = append(names, task.TableName)
+			}
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name       string
+		names      []string
+		upstream   bool
+		downstream bool
+		want       string
+	}{
+		{name: "only named", names: []string{"c", "e"}, want: "c,e"},
+		{name: "with upstream", names: []string{"c"}, upstream: true, want: "a,b,c"},
+		{name: "with downstream", names: []string{"a"}, downstream: true, want: "a,b,c,d"},
+		{name: "both directions", names: []string{"b"}, upstream: true, downstream: true, want: "a,b,c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newCfg()
+			if err := cfg.SelectTasks(tt.names, tt.upstream, tt.downstream); err != nil {
+				t.Fatalf("SelectTasks() error = %v", err)
+			}
+			if got := kept(cfg); got != tt.want {
+				t.Fatalf("kept tasks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown task", func(t *testing.T) {
+		cfg := newCfg()
+		if err := cfg.SelectTasks([]string{"missing"}, true, false); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Fatalf("SelectTasks() error = %v, want not found", err)
+		}
+	})
+
+	t.Run("ignored task", func(t *testing.T) {
+		cfg := newCfg()
+		if err := cfg.SelectTasks([]string{"f"}, false, false); err == nil {
+			t.Fatal("SelectTasks() expected error for ignored task")
+		}
+	})
+}
@@ -5,10 +5,13 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -28,16 +31,32 @@ type Daemon struct {
 	watchEnabled bool
 	version      string
 
-	mu        sync.Mutex
-	cancel    context.CancelFunc
-	cfgHash   string
-	running   bool
-	stopCh    chan struct{}
-	stopOnce  sync.Once
-	lastErr   error
-	cron      *cron.Cron
-	sseServer *sse.Server
-	triggerCh chan struct{}
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	// roundCancels 取消正在执行的迁移轮次（不影响 daemon 本身）；各 cron 条目的轮次可并发执行
+	roundCancels map[int]context.CancelFunc
+	nextRound    int
+	// taskStatus 记录各任务最近一次执行的状态，用于跨 cron 条目的依赖判断
+	taskStatus map[string]string
+	// stateFiles 为各 state_file 的占用信号（容量为 1），共用 state_file 的轮次串行执行
+	stateFiles map[string]chan struct{}
+	cfgHash    string
+	running    bool
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+	lastErr    error
+	cron       *cron.Cron
+	sseServer  *sse.Server
+	triggerCh  chan struct{}
+	// elector 在启用 [leader] 时决定本实例是否执行迁移轮次
+	elector *leaderElector
+	// scheduleMu 串行化补跑状态文件的读写
+	scheduleMu sync.Mutex
+	// logMu 保护 logRefs/logFile/logPrev：并发的轮次共用一次日志重定向
+	logMu   sync.Mutex
+	logRefs int
+	logFile *os.File
+	logPrev io.Writer
 }
 
 // Options configures the daemon.
@@ -59,6 +78,9 @@ func New(opts Options) *Daemon {
 		stopCh:       make(chan struct{}),
 		triggerCh:    make(chan struct{}, 1),
 		sseServer:    opts.SSEServer,
+		roundCancels: make(map[int]context.CancelFunc),
+		taskStatus:   make(map[string]string),
+		stateFiles:   make(map[string]chan struct{}),
 	}
 }
 
@@ -79,7 +101,15 @@ func (d *Daemon) Run() error {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
 
-	if cfg.Schedule.Cron != "" {
+	if cfg.Leader.Enabled {
+		stopElection, err := d.startLeaderElection(cfg)
+		if err != nil {
+			return err
+		}
+		defer stopElection()
+	}
+
+	if cfg.HasSchedule() {
 		return d.runWithSchedule(cfg)
 	}
 
@@ -89,6 +119,35 @@ func (d *Daemon) Run() error {
 	return d.runOnce()
 }
 
+// startLeaderElection 竞选一次后在后台续期租约。成为领导者时触发一轮迁移
+// （watch 模式下接管备用实例的工作），失去领导权时取消正在执行的轮次。
+func (d *Daemon) startLeaderElection(cfg *config.Config) (func(), error) {
+	elector, err := newLeaderElector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	elector.campaign(context.Background())
+	if leader, holder := elector.status(); !leader {
+		log.Printf("[leader] Starting in standby, current leader: %s", holder)
+	}
+	elector.onElected = d.TriggerRound
+	elector.onDemoted = func() { d.CancelRound() }
+
+	d.mu.Lock()
+	d.elector = elector
+	d.mu.Unlock()
+	elector.start()
+
+	return func() {
+		if err := elector.close(); err != nil {
+			log.Printf("[leader] Failed to release lease: %v", err)
+		}
+		d.mu.Lock()
+		d.elector = nil
+		d.mu.Unlock()
+	}, nil
+}
+
 func (d *Daemon) runOnce() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	d.mu.Lock()
@@ -220,6 +279,11 @@ func (d *Daemon) runWithWatch() error {
 }
 
 func (d *Daemon) executeRound(ctx context.Context) error {
+	return d.runRound(ctx, nil)
+}
+
+// runRound 执行一轮迁移；entry 不为空时只执行该 cron 条目中依赖已满足的任务。
+func (d *Daemon) runRound(ctx context.Context, entry *scheduleEntry) error {
 	cfg, err := config.LoadConfig(d.configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
@@ -233,8 +297,44 @@ func (d *Daemon) executeRound(ctx context.Context) error {
 	d.cfgHash = hash
 	d.mu.Unlock()
 
+	if role, leader := d.Role(); role == RoleStandby {
+		log.Printf("[daemon] Standby (leader: %s), skipping migration round", leader)
+		return nil
+	}
+
+	var selected map[string]bool
+	if entry != nil {
+		selected = d.selectEntryTasks(cfg, entry)
+		if len(selected) == 0 {
+			log.Printf("[schedule] Entry %s: no runnable tasks, skipping", entry.name())
+			return nil
+		}
+		cfg = entryConfig(cfg, selected)
+		log.Printf("[schedule] Entry %s: running %s", entry.name(), joinTaskNames(selected))
+	}
+
 	log.Printf("[daemon] Starting migration round with %d tasks", len(cfg.Tasks))
 
+	ctx, cancelRound := context.WithCancel(ctx)
+	defer cancelRound()
+	d.mu.Lock()
+	d.nextRound++
+	roundID := d.nextRound
+	d.roundCancels[roundID] = cancelRound
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.roundCancels, roundID)
+		d.mu.Unlock()
+	}()
+
+	releaseState, err := d.lockStateFiles(ctx, cfg)
+	if err != nil {
+		log.Printf("[daemon] Migration round cancelled")
+		return err
+	}
+	defer releaseState()
+
 	manager := database.NewConnectionManager(cfg)
 	proc := processor.NewProcessorWithVersion(manager, cfg, d.version)
 
@@ -243,6 +343,9 @@ func (d *Daemon) executeRound(ctx context.Context) error {
 	}
 
 	err = proc.ProcessAllTasksContext(ctx)
+	if selected != nil {
+		d.finishEntryTasks(selected, proc.TaskResults(), err)
+	}
 
 	if closeErr := proc.Close(); closeErr != nil {
 		log.Printf("[daemon] Warning: failed to close resources: %v", closeErr)
@@ -260,6 +363,52 @@ func (d *Daemon) executeRound(ctx context.Context) error {
 	return nil
 }
 
+// lockStateFiles 等待并占用 cfg 中任务使用的 state_file，返回释放函数。各 Processor 的状态锁
+// owner 在进程内相同、文档版本各自缓存，共用 state_file 的轮次并发时后保存的一方会遇到版本冲突，
+// 先结束的一方还会释放另一方仍在使用的锁，因此这些轮次串行执行。按路径排序占用以避免死锁。
+func (d *Daemon) lockStateFiles(ctx context.Context, cfg *config.Config) (func(), error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, task := range cfg.Tasks {
+		if task.Ignore || task.StateFile == "" || seen[task.StateFile] {
+			continue
+		}
+		seen[task.StateFile] = true
+		paths = append(paths, task.StateFile)
+	}
+	sort.Strings(paths)
+
+	var held []chan struct{}
+	release := func() {
+		for _, ch := range held {
+			<-ch
+		}
+	}
+	for _, path := range paths {
+		d.mu.Lock()
+		ch, ok := d.stateFiles[path]
+		if !ok {
+			ch = make(chan struct{}, 1)
+			d.stateFiles[path] = ch
+		}
+		d.mu.Unlock()
+
+		select {
+		case ch <- struct{}{}:
+		default:
+			log.Printf("[daemon] Waiting for another round using state_file %s", path)
+			select {
+			case ch <- struct{}{}:
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			}
+		}
+		held = append(held, ch)
+	}
+	return release, nil
+}
+
 // notifySSE returns a progress notifier that bridges processor events to the SSE server.
 func (d *Daemon) notifySSE() processor.ProgressNotifier {
 	return func(event processor.ProgressEvent) {
@@ -306,6 +455,22 @@ func (d *Daemon) TriggerRound() {
 	}
 }
 
+// CancelRound interrupts the migration rounds in progress, if any, and
+// reports whether one was running. The daemon keeps running and starts the
+// next round as usual.
+func (d *Daemon) CancelRound() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.roundCancels) == 0 {
+		return false
+	}
+	log.Printf("[daemon] Cancelling current migration round")
+	for _, cancel := range d.roundCancels {
+		cancel()
+	}
+	return true
+}
+
 // Stop signals the daemon to shut down gracefully.
 func (d *Daemon) Stop() {
 	d.stopOnce.Do(func() {
@@ -330,6 +495,22 @@ func (d *Daemon) IsRunning() bool {
 	return d.running
 }
 
+// Role reports RoleLeader or RoleStandby together with the current leader when
+// leader election is enabled, and empty strings otherwise.
+func (d *Daemon) Role() (role, leader string) {
+	d.mu.Lock()
+	elector := d.elector
+	d.mu.Unlock()
+	if elector == nil {
+		return "", ""
+	}
+	isLeader, holder := elector.status()
+	if isLeader {
+		return RoleLeader, holder
+	}
+	return RoleStandby, holder
+}
+
 // LastError returns the error from the most recent failed round.
 func (d *Daemon) LastError() error {
 	d.mu.Lock()
@@ -347,16 +528,13 @@ func (d *Daemon) runWithSchedule(cfg *config.Config) error {
 		}
 	}
 
-	if cfg.Schedule.MissedCatchup {
-		if err := d.handleMissedCatchup(cfg, loc); err != nil {
-			log.Printf("[schedule] Missed catchup failed: %v", err)
-		}
+	if err := d.handleMissedCatchup(cfg, loc); err != nil {
+		log.Printf("[schedule] Missed catchup failed: %v", err)
 	}
 
-	c := cron.New(cron.WithLocation(loc))
-	job := &scheduledJob{d: d, cfg: cfg, loc: loc}
-	if _, err := c.AddJob(cfg.Schedule.Cron, job); err != nil {
-		return fmt.Errorf("failed to add cron job: %w", err)
+	c, err := newScheduleCron(d, cfg, loc)
+	if err != nil {
+		return err
 	}
 	c.Start()
 
@@ -379,43 +557,49 @@ func (d *Daemon) runWithSchedule(cfg *config.Config) error {
 // It is exposed as a package-level variable so tests can override it.
 var scheduleRetryDelay = 1 * time.Minute
 
+// newScheduleCron 为每个 cron 条目添加一个任务；带时区的任务级条目使用 CRON_TZ 前缀。
+func newScheduleCron(d *Daemon, cfg *config.Config, loc *time.Location) (*cron.Cron, error) {
+	entries, err := buildScheduleEntries(cfg, loc)
+	if err != nil {
+		return nil, err
+	}
+	c := cron.New(cron.WithLocation(loc))
+	for _, entry := range entries {
+		spec := entry.schedule.Cron
+		if entry.key != "" && entry.loc != loc {
+			spec = "CRON_TZ=" + entry.loc.String() + " " + spec
+		}
+		if _, err := c.AddJob(spec, &scheduledJob{d: d, entry: entry}); err != nil {
+			return nil, fmt.Errorf("failed to add cron job for schedule entry %s: %w", entry.name(), err)
+		}
+	}
+	return c, nil
+}
+
 type scheduledJob struct {
-	d   *Daemon
-	cfg *config.Config
-	loc *time.Location
+	d     *Daemon
+	entry *scheduleEntry
 }
 
 func (j *scheduledJob) Run() {
-	now := time.Now().In(j.loc)
-	if j.cfg.Schedule.StartAt != "" {
-		startAt, err := parseDaemonScheduleTime(j.cfg.Schedule.StartAt)
+	sched := j.entry.schedule
+	now := time.Now().In(j.entry.loc)
+	if sched.StartAt != "" {
+		startAt, err := parseDaemonScheduleTime(sched.StartAt)
 		if err == nil && now.Before(startAt) {
 			log.Printf("[schedule] Current time %s before start_at %s, skipping", now.Format(time.RFC3339), startAt.Format(time.RFC3339))
 			return
 		}
 	}
-	if j.cfg.Schedule.EndAt != "" {
-		endAt, err := parseDaemonScheduleTime(j.cfg.Schedule.EndAt)
+	if sched.EndAt != "" {
+		endAt, err := parseDaemonScheduleTime(sched.EndAt)
 		if err == nil && now.After(endAt) {
 			log.Printf("[schedule] Current time %s after end_at %s, skipping", now.Format(time.RFC3339), endAt.Format(time.RFC3339))
 			return
 		}
 	}
 
-	logDir := "logs"
-	_ = os.MkdirAll(logDir, 0o755)
-	logFileName := filepath.Join(logDir, now.Format("2006-01-02")+".log")
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-	if err != nil {
-		log.Printf("[schedule] Failed to open log file %s: %v", logFileName, err)
-	} else {
-		oldOutput := log.Writer()
-		log.SetOutput(logFile)
-		defer func() {
-			log.SetOutput(oldOutput)
-			_ = logFile.Close()
-		}()
-	}
+	defer j.d.redirectLog(now)()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -429,7 +613,7 @@ func (j *scheduledJob) Run() {
 	}()
 
 	var execErr error
-	maxRetry := j.cfg.Schedule.MaxRetry
+	maxRetry := sched.MaxRetry
 	for attempt := 0; attempt <= maxRetry; attempt++ {
 		if attempt > 0 {
 			log.Printf("[schedule] Retry attempt %d/%d after failure", attempt, maxRetry)
@@ -439,52 +623,123 @@ func (j *scheduledJob) Run() {
 				return
 			}
 		}
-		execErr = j.d.executeRound(ctx)
-		if execErr == nil {
+		execErr = j.d.runRound(ctx, j.entry)
+		if execErr == nil || errors.Is(execErr, context.Canceled) {
 			break
 		}
 		log.Printf("[schedule] Migration round failed: %v", execErr)
 		j.d.mu.Lock()
 		j.d.lastErr = execErr
 		j.d.mu.Unlock()
-		if !j.cfg.Schedule.RetryOnFailure || attempt == maxRetry {
+		if !sched.RetryOnFailure || attempt == maxRetry {
 			break
 		}
 	}
 
-	if j.cfg.Schedule.MissedCatchup {
-		if err := j.d.recordLastRun(j.d.scheduleStatePath(), time.Now()); err != nil {
+	if sched.MissedCatchup {
+		if err := j.d.recordLastRun(j.d.scheduleStatePath(), j.entry.key, time.Now()); err != nil {
 			log.Printf("[schedule] Failed to record last run: %v", err)
 		}
 	}
 }
 
+// redirectLog 将进程的日志输出切换到 logs/<日期>.log，返回恢复函数。
+// 日志输出是进程级的，而各 cron 条目的轮次可以重叠：第一个开始的轮次打开文件并切换输出，
+// 最后一个结束的轮次恢复原输出并关闭文件，重叠期间所有轮次写入同一个文件。
+func (d *Daemon) redirectLog(now time.Time) func() {
+	d.logMu.Lock()
+	defer d.logMu.Unlock()
+	if d.logRefs == 0 {
+		logDir := "logs"
+		_ = os.MkdirAll(logDir, 0o755)
+		logFileName := filepath.Join(logDir, now.Format("2006-01-02")+".log")
+		logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Printf("[schedule] Failed to open log file %s: %v", logFileName, err)
+			return func() {}
+		}
+		d.logFile = logFile
+		d.logPrev = log.Writer()
+		log.SetOutput(logFile)
+	}
+	d.logRefs++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.logMu.Lock()
+			defer d.logMu.Unlock()
+			d.logRefs--
+			if d.logRefs > 0 {
+				return
+			}
+			log.SetOutput(d.logPrev)
+			_ = d.logFile.Close()
+			d.logFile, d.logPrev = nil, nil
+		})
+	}
+}
+
 func (d *Daemon) scheduleStatePath() string {
 	dir := filepath.Dir(d.configPath)
 	return filepath.Join(dir, ".db-ferry-schedule-state.json")
 }
 
+// scheduleState 保存各 cron 条目最近一次执行的时间：全局条目为 LastRun，任务级条目按条目键保存在 Entries。
 type scheduleState struct {
-	LastRun time.Time `json:"last_run"`
+	LastRun time.Time            `json:"last_run"`
+	Entries map[string]time.Time `json:"entries,omitempty"`
 }
 
-func (d *Daemon) loadLastRun(path string) (time.Time, error) {
+func (d *Daemon) loadScheduleState(path string) (scheduleState, error) {
+	var state scheduleState
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return time.Time{}, nil
+			return state, nil
 		}
-		return time.Time{}, err
+		return state, err
 	}
-	var state scheduleState
 	if err := json.Unmarshal(data, &state); err != nil {
+		return scheduleState{}, err
+	}
+	return state, nil
+}
+
+func (d *Daemon) loadLastRun(path string) (time.Time, error) {
+	return d.loadEntryLastRun(path, "")
+}
+
+func (d *Daemon) loadEntryLastRun(path, key string) (time.Time, error) {
+	d.scheduleMu.Lock()
+	defer d.scheduleMu.Unlock()
+	state, err := d.loadScheduleState(path)
+	if err != nil {
 		return time.Time{}, err
 	}
-	return state.LastRun, nil
+	if key == "" {
+		return state.LastRun, nil
+	}
+	return state.Entries[key], nil
 }
 
-func (d *Daemon) recordLastRun(path string, t time.Time) error {
-	state := scheduleState{LastRun: t}
+// recordLastRun 记录条目 key（全局条目为空）最近一次执行的时间，保留其他条目的记录。
+func (d *Daemon) recordLastRun(path, key string, t time.Time) error {
+	d.scheduleMu.Lock()
+	defer d.scheduleMu.Unlock()
+	state, err := d.loadScheduleState(path)
+	if err != nil {
+		log.Printf("[schedule] Ignoring unreadable schedule state %s: %v", path, err)
+		state = scheduleState{}
+	}
+	if key == "" {
+		state.LastRun = t
+	} else {
+		if state.Entries == nil {
+			state.Entries = make(map[string]time.Time)
+		}
+		state.Entries[key] = t
+	}
 	data, err := json.Marshal(state)
 	if err != nil {
 		return err
@@ -493,8 +748,29 @@ func (d *Daemon) recordLastRun(path string, t time.Time) error {
 }
 
 func (d *Daemon) handleMissedCatchup(cfg *config.Config, loc *time.Location) error {
+	entries, err := buildScheduleEntries(cfg, loc)
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, entry := range entries {
+		if !entry.schedule.MissedCatchup {
+			continue
+		}
+		if err := d.catchupEntry(entry); err != nil {
+			log.Printf("[schedule] Entry %s: %v", entry.name(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// catchupEntry 在条目错过了上一次计划执行时立即补跑一次。
+func (d *Daemon) catchupEntry(entry *scheduleEntry) error {
 	path := d.scheduleStatePath()
-	lastRun, err := d.loadLastRun(path)
+	lastRun, err := d.loadEntryLastRun(path, entry.key)
 	if err != nil {
 		return fmt.Errorf("failed to load last run: %w", err)
 	}
@@ -502,29 +778,16 @@ func (d *Daemon) handleMissedCatchup(cfg *config.Config, loc *time.Location) err
 		return nil
 	}
 	parser := cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
-	schedule, err := parser.Parse(cfg.Schedule.Cron)
+	schedule, err := parser.Parse(entry.schedule.Cron)
 	if err != nil {
 		return fmt.Errorf("failed to parse cron: %w", err)
 	}
-	next := schedule.Next(lastRun)
-	now := time.Now().In(loc)
+	next := schedule.Next(lastRun.In(entry.loc))
+	now := time.Now().In(entry.loc)
 	if next.Before(now) || next.Equal(now) {
-		log.Printf("[schedule] Missed catchup: last run %s, next scheduled %s, now %s", lastRun.Format(time.RFC3339), next.Format(time.RFC3339), now.Format(time.RFC3339))
+		log.Printf("[schedule] Missed catchup for entry %s: last run %s, next scheduled %s, now %s", entry.name(), lastRun.Format(time.RFC3339), next.Format(time.RFC3339), now.Format(time.RFC3339))
 
-		logDir := "logs"
-		_ = os.MkdirAll(logDir, 0o755)
-		logFileName := filepath.Join(logDir, now.Format("2006-01-02")+".log")
-		logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-		if err != nil {
-			log.Printf("[schedule] Failed to open log file %s: %v", logFileName, err)
-		} else {
-			oldOutput := log.Writer()
-			log.SetOutput(logFile)
-			defer func() {
-				log.SetOutput(oldOutput)
-				_ = logFile.Close()
-			}()
-		}
+		defer d.redirectLog(now)()
 
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
@@ -532,10 +795,10 @@ func (d *Daemon) handleMissedCatchup(cfg *config.Config, loc *time.Location) err
 			<-d.stopCh
 			cancel()
 		}()
-		if err := d.executeRound(ctx); err != nil {
+		if err := d.runRound(ctx, entry); err != nil {
 			return fmt.Errorf("missed catchup execution failed: %w", err)
 		}
-		if err := d.recordLastRun(path, time.Now()); err != nil {
+		if err := d.recordLastRun(path, entry.key, time.Now()); err != nil {
 			log.Printf("[schedule] Failed to record last run after catchup: %v", err)
 		}
 	}
@@ -608,18 +871,17 @@ func (d *Daemon) watchConfigForSchedule(currentLoc *time.Location) {
 				loc = newLoc
 			}
 
+			c, err := newScheduleCron(d, cfg, loc)
+			if err != nil {
+				log.Printf("[schedule] Failed to add cron job after reload: %v", err)
+				continue
+			}
 			d.mu.Lock()
 			if d.cron != nil {
 				ctx := d.cron.Stop()
 				<-ctx.Done()
 			}
-			d.cron = cron.New(cron.WithLocation(loc))
-			job := &scheduledJob{d: d, cfg: cfg, loc: loc}
-			if _, err := d.cron.AddJob(cfg.Schedule.Cron, job); err != nil {
-				log.Printf("[schedule] Failed to add cron job after reload: %v", err)
-				d.mu.Unlock()
-				continue
-			}
+			d.cron = c
 			d.cron.Start()
 			d.mu.Unlock()
 
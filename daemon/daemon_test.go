@@ -3,6 +3,7 @@ package daemon
 import (
 	"database/sql"
 	"encoding/json"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -367,6 +368,35 @@ func TestDaemonScheduleLogRotation(t *testing.T) {
 	}
 }
 
+func TestDaemonRedirectLogOverlappingRounds(t *testing.T) {
+	dir := t.TempDir()
+	oldWd, _ := os.Getwd()
+	_ = os.Chdir(dir)
+	defer func() { _ = os.Chdir(oldWd) }()
+
+	original := log.Writer()
+	d := New(Options{})
+	now := time.Now()
+
+	// 两个条目的轮次重叠，先开始的轮次先结束
+	releaseFirst := d.redirectLog(now)
+	releaseSecond := d.redirectLog(now)
+	releaseFirst()
+	log.Printf("second round still running")
+	releaseSecond()
+
+	if log.Writer() != original {
+		t.Fatal("expected the original log output to be restored after the last round")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "logs", now.Format("2006-01-02")+".log"))
+	if err != nil {
+		t.Fatalf("read log file error = %v", err)
+	}
+	if !strings.Contains(string(data), "second round still running") {
+		t.Fatalf("expected the log file to stay open until the last round finished, got %q", data)
+	}
+}
+
 func TestDaemonScheduleWithWatchReload(t *testing.T) {
 	dir := t.TempDir()
 	cfgPath, _, dstDB := setupTestDBs(t, dir)
@@ -691,6 +721,19 @@ func TestDaemonTriggerRound(t *testing.T) {
 	}
 }
 
+func TestDaemonCancelRound(t *testing.T) {
+	d := New(Options{ConfigPath: "unused.toml"})
+	if d.CancelRound() {
+		t.Fatal("expected CancelRound() to report no running round")
+	}
+
+	cancelled := false
+	d.roundCancels[1] = func() { cancelled = true }
+	if !d.CancelRound() || !cancelled {
+		t.Fatal("expected CancelRound() to cancel the running round")
+	}
+}
+
 func setupTestDBs(t *testing.T, dir string) (string, *sql.DB, *sql.DB) {
 	t.Helper()
 
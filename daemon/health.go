@@ -67,9 +67,12 @@ func (h *HealthServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 	status := http.StatusOK
 	msg := "healthy"
+	role, leader := h.daemon.Role()
 	if !h.daemon.IsRunning() {
 		status = http.StatusServiceUnavailable
 		msg = "not running"
+	} else if role == RoleStandby {
+		msg = RoleStandby
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -79,6 +82,10 @@ func (h *HealthServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"status": msg,
 		"time":   time.Now().UTC().Format(time.RFC3339),
 	}
+	if role != "" {
+		resp["role"] = role
+		resp["leader"] = leader
+	}
 	if err := h.daemon.LastError(); err != nil {
 		resp["last_error"] = err.Error()
 	}
@@ -214,3 +214,37 @@ func waitForServer(t *testing.T, url string) {
 		}
 	}
 }
+
+func TestHealthServerHandleHealthStandby(t *testing.T) {
+	d := New(Options{ConfigPath: "test.toml"})
+	elector := &leaderElector{lease: &fileLease{path: t.TempDir() + "/leader.lock"}, holder: "host-a:1:x"}
+	d.mu.Lock()
+	d.running = true
+	d.elector = elector
+	d.mu.Unlock()
+
+	hs := NewHealthServer(":0", d)
+	hs.Start()
+	defer hs.Stop()
+
+	baseURL := "http://" + hs.server.Addr
+	waitForServer(t, baseURL+"/health")
+
+	resp, err := http.Get(baseURL + "/health")
+	if err != nil {
+		t.Fatalf("GET /health error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	var result map[string]any
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("unmarshal health response error = %v", err)
+	}
+	if result["status"] != RoleStandby || result["role"] != RoleStandby || result["leader"] != "host-a:1:x" {
+		t.Fatalf("unexpected standby health response: %v", result)
+	}
+}
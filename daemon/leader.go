@@ -0,0 +1,293 @@
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// Daemon roles reported by Role and the health endpoint.
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// leaderLease 是领导者租约的存储。acquire 获取或续期租约并返回当前持有者，
+// 租约被其他实例持有且未过期时返回 database.ErrStateLocked。
+type leaderLease interface {
+	acquire(ctx context.Context, owner string, ttl time.Duration) (string, error)
+	release(ctx context.Context, owner string) error
+	close() error
+}
+
+// leaderElector 定期竞选并续期租约，记录本实例是否为领导者。
+type leaderElector struct {
+	lease leaderLease
+	owner string
+	ttl   time.Duration
+
+	mu     sync.Mutex
+	leader bool
+	holder string
+
+	// onElected / onDemoted 在角色切换时调用
+	onElected func()
+	onDemoted func()
+
+	started  bool
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newLeaderElector(cfg *config.Config) (*leaderElector, error) {
+	lc := cfg.Leader
+	var lease leaderLease
+	if lc.LockFile != "" {
+		lease = &fileLease{path: lc.LockFile}
+	} else {
+		dbCfg, ok := cfg.GetDatabase(lc.Database)
+		if !ok {
+			return nil, fmt.Errorf("leader.database '%s' is not defined", lc.Database)
+		}
+		target, err := database.OpenTarget(dbCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open leader database %s: %w", lc.Database, err)
+		}
+		table := database.NewStateTable(dbCfg.Type, lc.Table())
+		if err := table.EnsureTable(context.Background(), target); err != nil {
+			_ = target.Close()
+			return nil, err
+		}
+		lease = &dbLease{table: table, target: target, key: lc.LeaseName()}
+	}
+	return &leaderElector{
+		lease: lease,
+		owner: leaderIdentity(),
+		ttl:   lc.LeaseTTLDuration(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}, nil
+}
+
+// leaderIdentity 返回 host:pid:token 形式的实例标识。
+func leaderIdentity() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
+
+// campaign 尝试获取或续期租约一次并更新角色。
+func (e *leaderElector) campaign(ctx context.Context) {
+	holder, err := e.lease.acquire(ctx, e.owner, e.ttl)
+	elected := err == nil
+	if err != nil && !errors.Is(err, database.ErrStateLocked) {
+		// 无法确认租约时按失去领导权处理，租约过期前其他实例也无法接管
+		log.Printf("[leader] Failed to renew lease: %v", err)
+		holder = ""
+	}
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader, e.holder = elected, holder
+	onElected, onDemoted := e.onElected, e.onDemoted
+	e.mu.Unlock()
+
+	switch {
+	case elected && !wasLeader:
+		log.Printf("[leader] Acquired leadership as %s", e.owner)
+		if onElected != nil {
+			onElected()
+		}
+	case !elected && wasLeader:
+		log.Printf("[leader] Lost leadership (current leader: %s), entering standby", holder)
+		if onDemoted != nil {
+			onDemoted()
+		}
+	}
+}
+
+// start 在后台每隔 ttl/3 竞选一次，直到 close。
+func (e *leaderElector) start() {
+	e.mu.Lock()
+	e.started = true
+	e.mu.Unlock()
+	go e.run()
+}
+
+func (e *leaderElector) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+			e.campaign(context.Background())
+		}
+	}
+}
+
+// status 返回本实例是否为领导者以及当前已知的领导者。
+func (e *leaderElector) status() (bool, string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader, e.holder
+}
+
+// close 停止竞选，并在本实例为领导者时释放租约，使备用实例无需等待过期即可接管。
+func (e *leaderElector) close() error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	e.mu.Lock()
+	started := e.started
+	e.mu.Unlock()
+	if started {
+		<-e.done
+	}
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader = false
+	e.mu.Unlock()
+
+	var err error
+	if wasLeader {
+		err = e.lease.release(context.Background(), e.owner)
+	}
+	if closeErr := e.lease.close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// dbLease 以状态表中的一行作为租约。
+type dbLease struct {
+	table  *database.StateTable
+	target database.TargetDB
+	key    string
+}
+
+func (l *dbLease) acquire(ctx context.Context, owner string, ttl time.Duration) (string, error) {
+	err := l.table.Lock(ctx, l.target, l.key, owner, ttl)
+	if err == nil {
+		return owner, nil
+	}
+	if !errors.Is(err, database.ErrStateLocked) {
+		return "", err
+	}
+	holder, _, herr := l.table.Holder(ctx, l.target, l.key)
+	if herr != nil {
+		return "", herr
+	}
+	return holder, err
+}
+
+func (l *dbLease) release(ctx context.Context, owner string) error {
+	return l.table.Unlock(ctx, l.target, l.key, owner)
+}
+
+func (l *dbLease) close() error {
+	return l.target.Close()
+}
+
+// fileLease 以本地锁文件作为租约，适用于单机部署与测试。
+type fileLease struct {
+	path string
+}
+
+type fileLeaseRecord struct {
+	Owner   string `json:"owner"`
+	Expires int64  `json:"expires"`
+}
+
+func (l *fileLease) acquire(_ context.Context, owner string, ttl time.Duration) (string, error) {
+	if dir := filepath.Dir(l.path); dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("failed to create lease directory %s: %w", dir, err)
+		}
+	}
+	held, err := l.read()
+	if err != nil {
+		return "", err
+	}
+	if held.Owner != "" && held.Owner != owner && time.Now().UnixMilli() < held.Expires {
+		return held.Owner, fmt.Errorf("%w: %s is held by %s", database.ErrStateLocked, l.path, held.Owner)
+	}
+
+	data, err := json.Marshal(fileLeaseRecord{Owner: owner, Expires: time.Now().Add(ttl).UnixMilli()})
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to write lease %s: %w", l.path, err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write lease %s: %w", l.path, err)
+	}
+
+	// 两个实例同时接管时只有最后一次 rename 生效，回读确认
+	held, err = l.read()
+	if err != nil {
+		return "", err
+	}
+	if held.Owner != owner {
+		return held.Owner, fmt.Errorf("%w: %s is held by %s", database.ErrStateLocked, l.path, held.Owner)
+	}
+	return owner, nil
+}
+
+func (l *fileLease) release(_ context.Context, owner string) error {
+	held, err := l.read()
+	if err != nil || held.Owner != owner {
+		return err
+	}
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lease %s: %w", l.path, err)
+	}
+	return nil
+}
+
+func (l *fileLease) close() error {
+	return nil
+}
+
+// read 返回锁文件内容，文件不存在或无法解析时返回空记录。
+func (l *fileLease) read() (fileLeaseRecord, error) {
+	var held fileLeaseRecord
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return held, nil
+		}
+		return held, fmt.Errorf("failed to read lease %s: %w", l.path, err)
+	}
+	if json.Unmarshal(data, &held) != nil {
+		return fileLeaseRecord{}, nil
+	}
+	return held, nil
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+)
+
+func newTestElector(t *testing.T, cfg *config.Config) *leaderElector {
+	t.Helper()
+	e, err := newLeaderElector(cfg)
+	if err != nil {
+		t.Fatalf("newLeaderElector() error = %v", err)
+	}
+	return e
+}
+
+func TestLeaderElectorFileLeaseFailover(t *testing.T) {
+	cfg := &config.Config{Leader: config.LeaderConfig{
+		Enabled:  true,
+		LockFile: filepath.Join(t.TempDir(), "leader.lock"),
+		LeaseTTL: "200ms",
+	}}
+	ctx := context.Background()
+	a := newTestElector(t, cfg)
+	b := newTestElector(t, cfg)
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if leader, _ := a.status(); !leader {
+		t.Fatal("first instance should be leader")
+	}
+	if leader, holder := b.status(); leader || holder != a.owner {
+		t.Fatalf("second instance status = %v, %q, want standby behind %q", leader, holder, a.owner)
+	}
+
+	// 领导者崩溃（不释放租约），租约过期后备用实例接管
+	elected := make(chan struct{}, 1)
+	b.onElected = func() { elected <- struct{}{} }
+	time.Sleep(250 * time.Millisecond)
+	b.campaign(ctx)
+	select {
+	case <-elected:
+	default:
+		t.Fatal("standby was not elected after the lease expired")
+	}
+
+	demoted := make(chan struct{}, 1)
+	a.onDemoted = func() { demoted <- struct{}{} }
+	a.campaign(ctx)
+	select {
+	case <-demoted:
+	default:
+		t.Fatal("former leader was not demoted")
+	}
+
+	// 正常退出时释放租约，其他实例无需等待过期
+	if err := b.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+	if _, err := os.Stat(cfg.Leader.LockFile); !os.IsNotExist(err) {
+		t.Fatalf("expected lease to be released, stat error = %v", err)
+	}
+	a.campaign(ctx)
+	if leader, _ := a.status(); !leader {
+		t.Fatal("instance should be elected after the leader released the lease")
+	}
+	if err := a.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+}
+
+func TestLeaderElectorDatabaseLease(t *testing.T) {
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "coord", Type: config.DatabaseTypeSQLite, Path: filepath.Join(t.TempDir(), "coord.db")},
+		},
+		Leader: config.LeaderConfig{Enabled: true, Database: "coord", LeaseTTL: "1m"},
+	}
+	ctx := context.Background()
+	a := newTestElector(t, cfg)
+	b := newTestElector(t, cfg)
+
+	a.campaign(ctx)
+	b.campaign(ctx)
+	if leader, _ := a.status(); !leader {
+		t.Fatal("first instance should be leader")
+	}
+	if leader, holder := b.status(); leader || holder != a.owner {
+		t.Fatalf("second instance status = %v, %q, want standby behind %q", leader, holder, a.owner)
+	}
+
+	if err := a.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+	b.campaign(ctx)
+	if leader, _ := b.status(); !leader {
+		t.Fatal("standby should take over after the leader released the lease")
+	}
+	if err := b.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+}
+
+func TestDaemonStandbySkipsRound(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath, _, dstDB := setupTestDBs(t, dir)
+	lockFile := filepath.Join(dir, "leader.lock")
+
+	f, err := os.OpenFile(cfgPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("open config error = %v", err)
+	}
+	_, err = f.WriteString(strings.Join([]string{
+		"",
+		"[leader]",
+		"enabled = true",
+		`lock_file = "` + lockFile + `"`,
+		"",
+	}, "\n"))
+	f.Close()
+	if err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+
+	// 另一个实例持有租约
+	other := &fileLease{path: lockFile}
+	if _, err := other.acquire(context.Background(), "other-host:1:x", time.Minute); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	d := New(Options{ConfigPath: cfgPath})
+	if err := d.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var count int
+	if err := dstDB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'dst_users'`).Scan(&count); err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	if count != 0 {
+		t.Fatal("standby instance should not run the migration round")
+	}
+	if role, _ := d.Role(); role != "" {
+		t.Fatalf("Role() after Run = %q, want election stopped", role)
+	}
+}
@@ -0,0 +1,173 @@
+package daemon
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/processor"
+)
+
+// scheduleEntry 是一个独立的 cron 条目。全局 [schedule] 条目覆盖未配置任务级 schedule 的任务，
+// cron 与时区相同的任务级 schedule 合并为一个条目。
+type scheduleEntry struct {
+	// key 为补跑状态中的条目键，全局条目为空
+	key      string
+	schedule config.ScheduleConfig
+	loc      *time.Location
+	tasks    map[string]bool
+}
+
+func (e *scheduleEntry) name() string {
+	if e.key == "" {
+		return "global"
+	}
+	return e.key
+}
+
+// buildScheduleEntries 按配置生成 cron 条目，globalLoc 为全局 schedule 的时区。
+func buildScheduleEntries(cfg *config.Config, globalLoc *time.Location) ([]*scheduleEntry, error) {
+	var entries []*scheduleEntry
+	global := &scheduleEntry{schedule: cfg.Schedule, loc: globalLoc, tasks: make(map[string]bool)}
+	byKey := make(map[string]*scheduleEntry)
+
+	for _, task := range cfg.Tasks {
+		if task.Ignore {
+			continue
+		}
+		if task.Schedule.Cron == "" {
+			global.tasks[task.TableName] = true
+			continue
+		}
+		key := task.Schedule.EntryKey()
+		entry, ok := byKey[key]
+		if !ok {
+			// 未配置时区的任务沿用全局 schedule 的时区
+			loc := globalLoc
+			if task.Schedule.Timezone != "" {
+				var err error
+				loc, err = time.LoadLocation(task.Schedule.Timezone)
+				if err != nil {
+					return nil, fmt.Errorf("failed to load timezone for task %s: %w", task.TableName, err)
+				}
+			}
+			entry = &scheduleEntry{key: key, schedule: task.Schedule, loc: loc, tasks: make(map[string]bool)}
+			byKey[key] = entry
+			entries = append(entries, entry)
+		}
+		entry.tasks[task.TableName] = true
+	}
+
+	if cfg.Schedule.Cron != "" && len(global.tasks) > 0 {
+		entries = append([]*scheduleEntry{global}, entries...)
+	}
+	return entries, nil
+}
+
+// Task run states tracked across schedule entries.
+const (
+	taskRunning   = "running"
+	taskSucceeded = "succeeded"
+	taskFailed    = "failed"
+)
+
+// selectEntryTasks 返回条目本次执行的任务。依赖其他条目中正在执行或最近一次失败的任务时，
+// 该任务以及条目内依赖它的任务本轮跳过。
+func (d *Daemon) selectEntryTasks(cfg *config.Config, entry *scheduleEntry) map[string]bool {
+	deps := make(map[string][]string)
+	for _, task := range cfg.Tasks {
+		if !task.Ignore && entry.tasks[task.TableName] {
+			deps[task.TableName] = append(deps[task.TableName], task.DependsOn...)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	selected := make(map[string]bool, len(deps))
+	for name := range deps {
+		selected[name] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for name, taskDeps := range deps {
+			if !selected[name] {
+				continue
+			}
+			for _, dep := range taskDeps {
+				blocked := false
+				if entry.tasks[dep] {
+					blocked = !selected[dep]
+				} else if status := d.taskStatus[dep]; status == taskRunning || status == taskFailed {
+					blocked = true
+					log.Printf("[schedule] Entry %s: skipping task %s, dependency %s is %s", entry.name(), name, dep, status)
+				}
+				if blocked {
+					selected[name] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for name, ok := range selected {
+		if ok {
+			d.taskStatus[name] = taskRunning
+		} else {
+			delete(selected, name)
+		}
+	}
+	return selected
+}
+
+// finishEntryTasks 按任务结果记录各任务最近一次执行的状态。
+func (d *Daemon) finishEntryTasks(selected map[string]bool, results []processor.TaskResult, roundErr error) {
+	status := make(map[string]string)
+	for _, r := range results {
+		if r.Status == "success" {
+			if status[r.Name] == "" {
+				status[r.Name] = taskSucceeded
+			}
+		} else {
+			status[r.Name] = taskFailed
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name := range selected {
+		s := status[name]
+		if s == "" {
+			s = taskSucceeded
+			if roundErr != nil {
+				s = taskFailed
+			}
+		}
+		d.taskStatus[name] = s
+	}
+}
+
+// entryConfig 返回只保留条目所选任务的配置副本。
+func entryConfig(cfg *config.Config, selected map[string]bool) *config.Config {
+	out := *cfg
+	out.Tasks = make([]config.TaskConfig, len(cfg.Tasks))
+	copy(out.Tasks, cfg.Tasks)
+	for i := range out.Tasks {
+		if !selected[out.Tasks[i].TableName] {
+			out.Tasks[i].Ignore = true
+		}
+	}
+	return &out
+}
+
+func joinTaskNames(selected map[string]bool) string {
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
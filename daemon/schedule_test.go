@@ -0,0 +1,210 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/processor"
+)
+
+func TestBuildScheduleEntries(t *testing.T) {
+	hourly := config.ScheduleConfig{Cron: "@every 1h"}
+	cfg := &config.Config{
+		Schedule: config.ScheduleConfig{Cron: "0 2 * * *"},
+		Tasks: []config.TaskConfig{
+			{TableName: "a"},
+			{TableName: "b", Schedule: hourly},
+			{TableName: "c", Schedule: hourly},
+			{TableName: "d", Schedule: config.ScheduleConfig{Cron: "@every 1h", Timezone: "UTC"}},
+			{TableName: "e", Ignore: true},
+		},
+	}
+
+	entries, err := buildScheduleEntries(cfg, time.Local)
+	if err != nil {
+		t.Fatalf("buildScheduleEntries() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(entries))
+	}
+	if entries[0].name() != "global" || !entries[0].tasks["a"] || len(entries[0].tasks) != 1 {
+		t.Fatalf("global entry = %+v", entries[0])
+	}
+	if entries[1].key != "@every 1h" || !entries[1].tasks["b"] || !entries[1].tasks["c"] || entries[1].loc != time.Local {
+		t.Fatalf("hourly entry = %+v", entries[1])
+	}
+	if entries[2].key != "@every 1h @ UTC" || entries[2].loc.String() != "UTC" {
+		t.Fatalf("UTC entry = %+v", entries[2])
+	}
+
+	// 没有全局 cron 时不生成全局条目
+	cfg.Schedule = config.ScheduleConfig{}
+	entries, err = buildScheduleEntries(cfg, time.Local)
+	if err != nil {
+		t.Fatalf("buildScheduleEntries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].key == "" {
+		t.Fatalf("entries without global cron = %+v", entries)
+	}
+}
+
+func TestSelectEntryTasksDependencies(t *testing.T) {
+	cfg := &config.Config{
+		Tasks: []config.TaskConfig{
+			{TableName: "a"},
+			{TableName: "b", DependsOn: []string{"a"}},
+			{TableName: "c", DependsOn: []string{"b"}},
+			{TableName: "e"},
+		},
+	}
+	entry := &scheduleEntry{key: "@every 1h", tasks: map[string]bool{"b": true, "c": true, "e": true}}
+	d := New(Options{ConfigPath: "unused.toml"})
+
+	d.taskStatus["a"] = taskFailed
+	selected := d.selectEntryTasks(cfg, entry)
+	if got := joinTaskNames(selected); got != "e" {
+		t.Fatalf("selected with failed upstream = %q, want e", got)
+	}
+	if d.taskStatus["e"] != taskRunning || d.taskStatus["b"] != "" {
+		t.Fatalf("task status = %v", d.taskStatus)
+	}
+
+	d.finishEntryTasks(selected, []processor.TaskResult{{Name: "e", Status: "success"}}, nil)
+	d.taskStatus["a"] = taskSucceeded
+	selected = d.selectEntryTasks(cfg, entry)
+	if got := joinTaskNames(selected); got != "b, c, e" {
+		t.Fatalf("selected with succeeded upstream = %q, want b, c, e", got)
+	}
+
+	d.finishEntryTasks(selected, []processor.TaskResult{{Name: "b", Status: "success"}, {Name: "c", Status: "failed"}}, nil)
+	if d.taskStatus["b"] != taskSucceeded || d.taskStatus["c"] != taskFailed || d.taskStatus["e"] != taskSucceeded {
+		t.Fatalf("task status after finish = %v", d.taskStatus)
+	}
+}
+
+func TestDaemonRecordEntryLastRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	d := New(Options{ConfigPath: filepath.Join(dir, "task.toml")})
+
+	global := time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC)
+	hourly := time.Date(2026, 1, 1, 3, 0, 0, 0, time.UTC)
+	if err := d.recordLastRun(path, "", global); err != nil {
+		t.Fatalf("recordLastRun() error = %v", err)
+	}
+	if err := d.recordLastRun(path, "@every 1h", hourly); err != nil {
+		t.Fatalf("recordLastRun() error = %v", err)
+	}
+
+	if got, err := d.loadLastRun(path); err != nil || !got.Equal(global) {
+		t.Fatalf("loadLastRun() = %v, %v; want %v", got, err, global)
+	}
+	if got, err := d.loadEntryLastRun(path, "@every 1h"); err != nil || !got.Equal(hourly) {
+		t.Fatalf("loadEntryLastRun() = %v, %v; want %v", got, err, hourly)
+	}
+	if got, err := d.loadEntryLastRun(path, "@daily"); err != nil || !got.IsZero() {
+		t.Fatalf("loadEntryLastRun(unknown) = %v, %v; want zero", got, err)
+	}
+}
+
+func TestDaemonRunWithTaskSchedule(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath, _, dstDB := setupTestDBs(t, dir)
+
+	content, _ := os.ReadFile(cfgPath)
+	scheduleSection := "\n[tasks.schedule]\ncron = \"@every 50ms\"\n"
+	_ = os.WriteFile(cfgPath, append(content, []byte(scheduleSection)...), 0o644)
+
+	d := New(Options{ConfigPath: cfgPath})
+
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		d.Stop()
+	}()
+
+	if err := d.Run(); err != nil {
+		t.Fatalf("Run error = %v", err)
+	}
+
+	var cnt int
+	if err := dstDB.QueryRow(`SELECT COUNT(*) FROM "dst_users"`).Scan(&cnt); err != nil {
+		t.Fatalf("query target count error = %v", err)
+	}
+	if cnt != 2 {
+		t.Fatalf("target row count = %d, want 2", cnt)
+	}
+	if d.taskStatus["dst_users"] != taskSucceeded && d.taskStatus["dst_users"] != taskRunning {
+		t.Fatalf("task status = %q", d.taskStatus["dst_users"])
+	}
+}
+
+func TestDaemonOverlappingEntriesShareStateFile(t *testing.T) {
+	dir := t.TempDir()
+	cfgPath, _, dstDB := setupTestDBs(t, dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	base, _ := os.ReadFile(cfgPath)
+	var b strings.Builder
+	b.WriteString(strings.SplitN(string(base), "[[tasks]]", 2)[0])
+	for _, task := range []struct{ table, cron string }{{"dst_a", "@every 1h"}, {"dst_b", "@every 2h"}} {
+		fmt.Fprintf(&b, "[[tasks]]\ntable_name = %q\nsql = \"SELECT id, name FROM src_users ORDER BY id\"\n", task.table)
+		fmt.Fprintf(&b, "source_db = \"src\"\ntarget_db = \"dst\"\nmode = \"append\"\nresume_key = \"id\"\nstate_file = %q\n", statePath)
+		fmt.Fprintf(&b, "[tasks.schedule]\ncron = %q\n\n", task.cron)
+	}
+	if err := os.WriteFile(cfgPath, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+	cfg, err := config.LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	entries, err := buildScheduleEntries(cfg, time.Local)
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("buildScheduleEntries() = %d entries, %v; want 2", len(entries), err)
+	}
+
+	// 两个条目同时触发并写同一个 state_file
+	d := New(Options{ConfigPath: cfgPath})
+	errs := make(chan error, len(entries))
+	for _, entry := range entries {
+		go func(entry *scheduleEntry) { errs <- d.runRound(context.Background(), entry) }(entry)
+	}
+	for range entries {
+		if err := <-errs; err != nil {
+			t.Fatalf("runRound() error = %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file error = %v", err)
+	}
+	for _, table := range []string{"dst_a", "dst_b"} {
+		if !strings.Contains(string(data), table) {
+			t.Fatalf("expected state file to keep the cursor of %s, got %s", table, data)
+		}
+		var cnt int
+		if err := dstDB.QueryRow(`SELECT COUNT(*) FROM "` + table + `"`).Scan(&cnt); err != nil || cnt != 2 {
+			t.Fatalf("%s row count = %d, %v; want 2", table, cnt, err)
+		}
+	}
+
+	// 占用中的 state_file 会让后来的轮次等待，取消时返回
+	release, err := d.lockStateFiles(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("lockStateFiles() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := d.lockStateFiles(ctx, cfg); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the second round to wait for the state_file, got %v", err)
+	}
+	release()
+}
@@ -0,0 +1,701 @@
+package database
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MySQL binlog event types handled by the CDC reader.
+const (
+	BinlogQueryEvent         = 2
+	BinlogRotateEvent        = 4
+	BinlogFormatDescEvent    = 15
+	BinlogXIDEvent           = 16
+	BinlogTableMapEvent      = 19
+	BinlogWriteRowsEventV1   = 23
+	BinlogUpdateRowsV1       = 24
+	BinlogDeleteRowsV1       = 25
+	BinlogHeartbeatEvent     = 27
+	BinlogWriteRowsEventV2   = 30
+	BinlogUpdateRowsV2       = 31
+	BinlogDeleteRowsV2       = 32
+	BinlogGTIDEvent          = 33
+	BinlogXAPrepareEvent     = 38
+	BinlogPartialUpdateRows  = 39
+	BinlogTransactionPayload = 40
+)
+
+// MySQL column types as they appear in TABLE_MAP events.
+const (
+	mysqlTypeDecimal    = 0
+	mysqlTypeTiny       = 1
+	mysqlTypeShort      = 2
+	mysqlTypeLong       = 3
+	mysqlTypeFloat      = 4
+	mysqlTypeDouble     = 5
+	mysqlTypeNull       = 6
+	mysqlTypeTimestamp  = 7
+	mysqlTypeLongLong   = 8
+	mysqlTypeInt24      = 9
+	mysqlTypeDate       = 10
+	mysqlTypeTime       = 11
+	mysqlTypeDateTime   = 12
+	mysqlTypeYear       = 13
+	mysqlTypeVarchar    = 15
+	mysqlTypeBit        = 16
+	mysqlTypeTimestamp2 = 17
+	mysqlTypeDateTime2  = 18
+	mysqlTypeTime2      = 19
+	mysqlTypeJSON       = 245
+	mysqlTypeNewDecimal = 246
+	mysqlTypeEnum       = 247
+	mysqlTypeSet        = 248
+	mysqlTypeTinyBlob   = 249
+	mysqlTypeMediumBlob = 250
+	mysqlTypeLongBlob   = 251
+	mysqlTypeBlob       = 252
+	mysqlTypeVarString  = 253
+	mysqlTypeString     = 254
+	mysqlTypeGeometry   = 255
+)
+
+// BinlogPosition is a binlog file name and byte offset.
+type BinlogPosition struct {
+	File string
+	Pos  uint32
+}
+
+// String formats the position as "file:pos", the form kept in state files.
+func (p BinlogPosition) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Pos)
+}
+
+// ParseBinlogPosition parses a "file:pos" position.
+func ParseBinlogPosition(s string) (BinlogPosition, error) {
+	file, pos, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok || file == "" {
+		return BinlogPosition{}, fmt.Errorf("invalid binlog position %q", s)
+	}
+	n, err := strconv.ParseUint(pos, 10, 32)
+	if err != nil {
+		return BinlogPosition{}, fmt.Errorf("invalid binlog position %q: %w", s, err)
+	}
+	return BinlogPosition{File: file, Pos: uint32(n)}, nil
+}
+
+// BinlogTableMap describes a table announced by a TABLE_MAP event. Columns and
+// Unsigned are not part of the event; callers fill them from information_schema
+// before decoding row events of the table.
+type BinlogTableMap struct {
+	TableID  uint64
+	Schema   string
+	Table    string
+	Types    []byte
+	Meta     []uint16
+	Columns  []string
+	Unsigned []bool
+}
+
+// BinlogRow is one row image pair. Before is set for updates and deletes,
+// After for inserts and updates. Values of columns missing from the image
+// (binlog_row_image = minimal) are nil and flagged false in the event's
+// BeforePresent/AfterPresent.
+type BinlogRow struct {
+	Before []any
+	After  []any
+}
+
+// BinlogEvent is a decoded binlog event. Only the fields relevant to Type are populated.
+type BinlogEvent struct {
+	Type    byte
+	NextPos uint32
+
+	// ROTATE
+	NextFile string
+	// GTID, formatted as "uuid:gno"
+	GTID string
+	// QUERY
+	Query string
+	// TABLE_MAP
+	TableMap *BinlogTableMap
+	// *_ROWS
+	Table         *BinlogTableMap
+	Rows          []BinlogRow
+	BeforePresent []bool
+	AfterPresent  []bool
+}
+
+// IsRowsEvent reports whether the event carries row changes.
+func (e *BinlogEvent) IsRowsEvent() bool {
+	switch e.Type {
+	case BinlogWriteRowsEventV1, BinlogWriteRowsEventV2,
+		BinlogUpdateRowsV1, BinlogUpdateRowsV2,
+		BinlogDeleteRowsV1, BinlogDeleteRowsV2:
+		return true
+	}
+	return false
+}
+
+// IsInsert, IsUpdate and IsDelete classify row events.
+func (e *BinlogEvent) IsInsert() bool {
+	return e.Type == BinlogWriteRowsEventV1 || e.Type == BinlogWriteRowsEventV2
+}
+
+func (e *BinlogEvent) IsUpdate() bool {
+	return e.Type == BinlogUpdateRowsV1 || e.Type == BinlogUpdateRowsV2
+}
+
+func (e *BinlogEvent) IsDelete() bool {
+	return e.Type == BinlogDeleteRowsV1 || e.Type == BinlogDeleteRowsV2
+}
+
+// BinlogParser decodes binlog events and tracks table maps. Rows events of
+// tables whose Columns have not been filled are not decoded, so unsupported
+// column types in unrelated tables do not stop the stream.
+type BinlogParser struct {
+	tables   map[uint64]*BinlogTableMap
+	checksum bool
+	loc      *time.Location
+}
+
+// NewBinlogParser creates an empty parser. checksum tells whether events carry
+// a trailing CRC32, as when binlog_checksum = CRC32; a FORMAT_DESCRIPTION event
+// overrides it.
+func NewBinlogParser(checksum bool) *BinlogParser {
+	return &BinlogParser{tables: make(map[uint64]*BinlogTableMap), checksum: checksum, loc: time.UTC}
+}
+
+// SetLocation sets the zone TIMESTAMP values are rendered in. Binlog events
+// carry TIMESTAMP as UTC seconds; a SELECT returns them converted to the
+// session time_zone, so this should be the source's session zone.
+func (bp *BinlogParser) SetLocation(loc *time.Location) {
+	bp.loc = loc
+}
+
+const binlogHeaderSize = 19
+
+// Parse decodes a single event including its 19-byte header.
+func (bp *BinlogParser) Parse(data []byte) (*BinlogEvent, error) {
+	if len(data) < binlogHeaderSize {
+		return nil, fmt.Errorf("binlog event truncated: %d bytes", len(data))
+	}
+	ev := &BinlogEvent{
+		Type:    data[4],
+		NextPos: binary.LittleEndian.Uint32(data[13:17]),
+	}
+	body := data[binlogHeaderSize:]
+	if ev.Type == BinlogFormatDescEvent {
+		bp.checksum = formatDescChecksum(body)
+	}
+	if bp.checksum {
+		if len(body) < 4 {
+			return nil, fmt.Errorf("binlog event type %d too short for checksum", ev.Type)
+		}
+		body = body[:len(body)-4]
+	}
+	r := &binlogReader{buf: body, loc: bp.loc}
+
+	switch {
+	case ev.Type == BinlogPartialUpdateRows, ev.Type == BinlogTransactionPayload:
+		return nil, fmt.Errorf("binlog event type %d is not supported; disable binlog_row_value_options and binlog_transaction_compression", ev.Type)
+	case ev.Type == BinlogGTIDEvent:
+		r.skip(1) // commit flag
+		sid := r.readBytes(16)
+		gno := r.readUint(8)
+		if r.err == nil {
+			ev.GTID = fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], gno)
+		}
+	case ev.Type == BinlogRotateEvent:
+		pos := r.readUint(8)
+		ev.NextFile = string(r.rest())
+		// A rotate announces where the next file starts.
+		ev.NextPos = uint32(pos)
+	case ev.Type == BinlogQueryEvent:
+		r.skip(8) // thread id, exec time
+		schemaLen := int(r.readUint(1))
+		r.skip(2) // error code
+		statusLen := int(r.readUint(2))
+		r.skip(statusLen)
+		r.skip(schemaLen + 1)
+		ev.Query = string(r.rest())
+	case ev.Type == BinlogTableMapEvent:
+		tm, err := decodeTableMap(r)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := bp.tables[tm.TableID]; ok && prev.Schema == tm.Schema && prev.Table == tm.Table {
+			tm.Columns, tm.Unsigned = prev.Columns, prev.Unsigned
+		}
+		bp.tables[tm.TableID] = tm
+		ev.TableMap = tm
+	case ev.IsRowsEvent():
+		if err := bp.decodeRows(ev, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode binlog event type %d: %w", ev.Type, r.err)
+	}
+	return ev, nil
+}
+
+// formatDescChecksum reports whether a FORMAT_DESCRIPTION event body announces
+// CRC32 checksums. Servers before 5.6.1 do not write the algorithm byte.
+func formatDescChecksum(body []byte) bool {
+	if len(body) < 2+50+5 {
+		return false
+	}
+	version := string(body[2:52])
+	if i := strings.IndexByte(version, 0); i >= 0 {
+		version = version[:i]
+	}
+	var major, minor, patch int
+	fmt.Sscanf(version, "%d.%d.%d", &major, &minor, &patch)
+	if major*10000+minor*100+patch < 50601 {
+		return false
+	}
+	return body[len(body)-5] == 1
+}
+
+func decodeTableMap(r *binlogReader) (*BinlogTableMap, error) {
+	tm := &BinlogTableMap{TableID: r.readUint(6)}
+	r.skip(2) // flags
+	tm.Schema = string(r.readBytes(int(r.readUint(1))))
+	r.skip(1)
+	tm.Table = string(r.readBytes(int(r.readUint(1))))
+	r.skip(1)
+	n := int(r.readLenEnc())
+	tm.Types = append([]byte(nil), r.readBytes(n)...)
+	metaLen := int(r.readLenEnc())
+	meta := &binlogReader{buf: r.readBytes(metaLen)}
+	tm.Meta = make([]uint16, n)
+	for i, t := range tm.Types {
+		switch t {
+		case mysqlTypeFloat, mysqlTypeDouble, mysqlTypeBlob, mysqlTypeGeometry, mysqlTypeJSON,
+			mysqlTypeTimestamp2, mysqlTypeDateTime2, mysqlTypeTime2:
+			tm.Meta[i] = uint16(meta.readUint(1))
+		case mysqlTypeVarchar, mysqlTypeVarString, mysqlTypeBit:
+			tm.Meta[i] = uint16(meta.readUint(2))
+		case mysqlTypeNewDecimal, mysqlTypeString, mysqlTypeEnum, mysqlTypeSet:
+			// Stored high byte first (precision/real type, then scale/length).
+			hi := meta.readUint(1)
+			lo := meta.readUint(1)
+			tm.Meta[i] = uint16(hi<<8 | lo)
+		}
+	}
+	if meta.err != nil {
+		return nil, fmt.Errorf("invalid table map metadata for %s.%s: %w", tm.Schema, tm.Table, meta.err)
+	}
+	// The null bitmap and optional metadata that follow are not needed.
+	return tm, nil
+}
+
+func (bp *BinlogParser) decodeRows(ev *BinlogEvent, r *binlogReader) error {
+	tableID := r.readUint(6)
+	r.skip(2) // flags
+	if ev.Type >= BinlogWriteRowsEventV2 {
+		extra := int(r.readUint(2))
+		r.skip(extra - 2)
+	}
+	if r.err != nil {
+		return nil
+	}
+	tm, ok := bp.tables[tableID]
+	if !ok {
+		return fmt.Errorf("binlog rows event references unknown table id %d", tableID)
+	}
+	ev.Table = tm
+	if tm.Columns == nil {
+		return nil
+	}
+
+	n := int(r.readLenEnc())
+	if n != len(tm.Types) {
+		return fmt.Errorf("binlog rows event for %s.%s has %d columns, table map has %d", tm.Schema, tm.Table, n, len(tm.Types))
+	}
+	first := r.readBitmap(n)
+	second := first
+	if ev.IsUpdate() {
+		second = r.readBitmap(n)
+	}
+	if ev.IsInsert() {
+		ev.AfterPresent = first
+	} else {
+		ev.BeforePresent = first
+		if ev.IsUpdate() {
+			ev.AfterPresent = second
+		}
+	}
+
+	for len(r.buf) > 0 && r.err == nil {
+		var row BinlogRow
+		var err error
+		if ev.IsInsert() {
+			row.After, err = r.readRowImage(tm, first)
+		} else {
+			row.Before, err = r.readRowImage(tm, first)
+			if err == nil && ev.IsUpdate() {
+				row.After, err = r.readRowImage(tm, second)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode row of %s.%s: %w", tm.Schema, tm.Table, err)
+		}
+		ev.Rows = append(ev.Rows, row)
+	}
+	return nil
+}
+
+type binlogReader struct {
+	buf []byte
+	err error
+	loc *time.Location
+}
+
+func (r *binlogReader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if n < 0 || len(r.buf) < n {
+		r.err = fmt.Errorf("event truncated")
+		return false
+	}
+	return true
+}
+
+func (r *binlogReader) skip(n int) {
+	if r.need(n) {
+		r.buf = r.buf[n:]
+	}
+}
+
+func (r *binlogReader) readBytes(n int) []byte {
+	if !r.need(n) {
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *binlogReader) rest() []byte {
+	b := r.buf
+	r.buf = nil
+	return b
+}
+
+// readUint reads an n-byte little-endian unsigned integer.
+func (r *binlogReader) readUint(n int) uint64 {
+	b := r.readBytes(n)
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// readUintBE reads an n-byte big-endian unsigned integer.
+func (r *binlogReader) readUintBE(n int) uint64 {
+	var v uint64
+	for _, c := range r.readBytes(n) {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func (r *binlogReader) readLenEnc() uint64 {
+	switch first := r.readUint(1); {
+	case first < 0xfb:
+		return first
+	case first == 0xfc:
+		return r.readUint(2)
+	case first == 0xfd:
+		return r.readUint(3)
+	case first == 0xfe:
+		return r.readUint(8)
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("invalid length-encoded integer")
+		}
+		return 0
+	}
+}
+
+func (r *binlogReader) readBitmap(n int) []bool {
+	b := r.readBytes((n + 7) / 8)
+	bits := make([]bool, n)
+	if b == nil {
+		return bits
+	}
+	for i := range bits {
+		bits[i] = b[i/8]&(1<<(i%8)) != 0
+	}
+	return bits
+}
+
+func (r *binlogReader) readRowImage(tm *BinlogTableMap, present []bool) ([]any, error) {
+	count := 0
+	for _, p := range present {
+		if p {
+			count++
+		}
+	}
+	nulls := r.readBitmap(count)
+	values := make([]any, len(tm.Types))
+	idx := 0
+	for i, p := range present {
+		if !p {
+			continue
+		}
+		isNull := nulls[idx]
+		idx++
+		if isNull {
+			continue
+		}
+		unsigned := i < len(tm.Unsigned) && tm.Unsigned[i]
+		v, err := r.readValue(tm.Types[i], tm.Meta[i], unsigned)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return values, nil
+}
+
+func (r *binlogReader) readInt(n int, unsigned bool) any {
+	v := r.readUint(n)
+	if unsigned {
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10)
+		}
+		return int64(v)
+	}
+	shift := uint(64 - 8*n)
+	return int64(v<<shift) >> shift
+}
+
+func (r *binlogReader) readValue(typ byte, meta uint16, unsigned bool) (any, error) {
+	switch typ {
+	case mysqlTypeTiny:
+		return r.readInt(1, unsigned), nil
+	case mysqlTypeShort:
+		return r.readInt(2, unsigned), nil
+	case mysqlTypeInt24:
+		return r.readInt(3, unsigned), nil
+	case mysqlTypeLong:
+		return r.readInt(4, unsigned), nil
+	case mysqlTypeLongLong:
+		return r.readInt(8, unsigned), nil
+	case mysqlTypeFloat:
+		return float64(math.Float32frombits(uint32(r.readUint(4)))), nil
+	case mysqlTypeDouble:
+		return math.Float64frombits(r.readUint(8)), nil
+	case mysqlTypeYear:
+		if y := r.readUint(1); y != 0 {
+			return int64(1900 + y), nil
+		}
+		return int64(0), nil
+	case mysqlTypeNewDecimal:
+		return r.readDecimal(int(meta>>8), int(meta&0xff))
+	case mysqlTypeDate:
+		v := r.readUint(3)
+		return fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)&15, v&31), nil
+	case mysqlTypeDateTime2:
+		v := int64(r.readUintBE(5)) - 0x8000000000
+		frac := r.readFrac(meta)
+		ymd, hms := v>>17, v&(1<<17-1)
+		ym := ymd >> 5
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", ym/13, ym%13, ymd&31, hms>>12, (hms>>6)&63, hms&63) + formatFrac(frac, meta), nil
+	case mysqlTypeTimestamp2:
+		sec := int64(r.readUintBE(4))
+		frac := r.readFrac(meta)
+		return r.formatTimestamp(sec) + formatFrac(frac, meta), nil
+	case mysqlTypeTime2:
+		return r.readTime2(meta), nil
+	case mysqlTypeDateTime:
+		v := r.readUint(8)
+		d, t := v/1000000, v%1000000
+		return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, d/100%100, d%100, t/10000, t/100%100, t%100), nil
+	case mysqlTypeTimestamp:
+		return r.formatTimestamp(int64(r.readUint(4))), nil
+	case mysqlTypeTime:
+		v := r.readUint(3)
+		return fmt.Sprintf("%02d:%02d:%02d", v/10000, v/100%100, v%100), nil
+	case mysqlTypeVarchar, mysqlTypeVarString:
+		size := 1
+		if meta >= 256 {
+			size = 2
+		}
+		return string(r.readBytes(int(r.readUint(size)))), nil
+	case mysqlTypeString, mysqlTypeEnum, mysqlTypeSet:
+		realType, length := byte(meta>>8), int(meta&0xff)
+		if realType == mysqlTypeEnum || realType == mysqlTypeSet {
+			return int64(r.readUint(length)), nil
+		}
+		if realType&0x30 != 0x30 {
+			length |= int((realType&0x30)^0x30) << 4
+		}
+		size := 1
+		if length >= 256 {
+			size = 2
+		}
+		return string(r.readBytes(int(r.readUint(size)))), nil
+	case mysqlTypeBit:
+		nbits := int(meta>>8)*8 + int(meta&0xff)
+		return int64(r.readUintBE((nbits + 7) / 8)), nil
+	case mysqlTypeBlob, mysqlTypeTinyBlob, mysqlTypeMediumBlob, mysqlTypeLongBlob, mysqlTypeGeometry:
+		b := r.readBytes(int(r.readUint(int(meta))))
+		return append([]byte(nil), b...), nil
+	case mysqlTypeJSON:
+		b := r.readBytes(int(r.readUint(int(meta))))
+		if r.err != nil {
+			return nil, r.err
+		}
+		return decodeBinlogJSON(b)
+	case mysqlTypeNull:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported binlog column type %d", typ)
+	}
+}
+
+// formatTimestamp renders TIMESTAMP seconds in the reader's zone. The zero
+// value is kept as MySQL prints it.
+func (r *binlogReader) formatTimestamp(sec int64) string {
+	if sec == 0 {
+		return "0000-00-00 00:00:00"
+	}
+	loc := r.loc
+	if loc == nil {
+		loc = time.UTC
+	}
+	return time.Unix(sec, 0).In(loc).Format("2006-01-02 15:04:05")
+}
+
+// readFrac reads the fractional seconds of a TIME2/DATETIME2/TIMESTAMP2 value
+// and returns microseconds.
+func (r *binlogReader) readFrac(fsp uint16) int64 {
+	switch fsp {
+	case 1, 2:
+		return int64(r.readUintBE(1)) * 10000
+	case 3, 4:
+		return int64(r.readUintBE(2)) * 100
+	case 5, 6:
+		return int64(r.readUintBE(3))
+	}
+	return 0
+}
+
+func (r *binlogReader) readTime2(fsp uint16) string {
+	var packed int64
+	switch fsp {
+	case 1, 2:
+		intpart := int64(r.readUintBE(3)) - 0x800000
+		frac := int64(r.readUintBE(1))
+		if intpart < 0 && frac > 0 {
+			intpart++
+			frac -= 0x100
+		}
+		packed = intpart<<24 + frac*10000
+	case 3, 4:
+		intpart := int64(r.readUintBE(3)) - 0x800000
+		frac := int64(r.readUintBE(2))
+		if intpart < 0 && frac > 0 {
+			intpart++
+			frac -= 0x10000
+		}
+		packed = intpart<<24 + frac*100
+	case 5, 6:
+		packed = int64(r.readUintBE(6)) - 0x800000000000
+	default:
+		packed = (int64(r.readUintBE(3)) - 0x800000) << 24
+	}
+	sign := ""
+	if packed < 0 {
+		sign = "-"
+		packed = -packed
+	}
+	hms, frac := packed>>24, packed%(1<<24)
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, (hms>>12)&0x3ff, (hms>>6)&63, hms&63) + formatFrac(frac, fsp)
+}
+
+func formatFrac(micros int64, fsp uint16) string {
+	if fsp == 0 {
+		return ""
+	}
+	return "." + fmt.Sprintf("%06d", micros)[:fsp]
+}
+
+var decimalDigitBytes = [10]int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// readDecimal decodes a NEWDECIMAL value into its decimal text form.
+func (r *binlogReader) readDecimal(precision, scale int) (any, error) {
+	intg := precision - scale
+	intg0, intgx := intg/9, intg%9
+	frac0, fracx := scale/9, scale%9
+	size := intg0*4 + decimalDigitBytes[intgx] + frac0*4 + decimalDigitBytes[fracx]
+	raw := r.readBytes(size)
+	if raw == nil {
+		return nil, r.err
+	}
+	buf := append([]byte(nil), raw...)
+	var mask byte
+	negative := buf[0]&0x80 == 0
+	if negative {
+		mask = 0xff
+	}
+	buf[0] ^= 0x80
+
+	d := &binlogReader{buf: buf}
+	group := func(n int) uint64 {
+		b := d.readBytes(n)
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c^mask)
+		}
+		return v
+	}
+
+	var sb strings.Builder
+	if negative {
+		sb.WriteByte('-')
+	}
+	var intPart strings.Builder
+	if intgx > 0 {
+		intPart.WriteString(strconv.FormatUint(group(decimalDigitBytes[intgx]), 10))
+	}
+	for i := 0; i < intg0; i++ {
+		v := group(4)
+		if intPart.Len() == 0 {
+			intPart.WriteString(strconv.FormatUint(v, 10))
+		} else {
+			intPart.WriteString(fmt.Sprintf("%09d", v))
+		}
+	}
+	ip := strings.TrimLeft(intPart.String(), "0")
+	if ip == "" {
+		ip = "0"
+	}
+	sb.WriteString(ip)
+	if scale > 0 {
+		sb.WriteByte('.')
+		for i := 0; i < frac0; i++ {
+			sb.WriteString(fmt.Sprintf("%09d", group(4)))
+		}
+		if fracx > 0 {
+			sb.WriteString(fmt.Sprintf("%0*d", fracx, group(decimalDigitBytes[fracx])))
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return sb.String(), nil
+}
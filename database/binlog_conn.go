@@ -0,0 +1,410 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"db-ferry/config"
+)
+
+// MySQL client capability flags used by the replication handshake.
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientLongFlag         = 0x00000004
+	mysqlClientConnectWithDB    = 0x00000008
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientTransactions     = 0x00002000
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+)
+
+const (
+	mysqlComQuit       = 0x01
+	mysqlComQuery      = 0x03
+	mysqlComBinlogDump = 0x12
+
+	// binlogDumpNonBlock makes the server send EOF once the client has caught
+	// up instead of waiting for new events.
+	binlogDumpNonBlock = 0x01
+
+	mysqlMaxPacketSize  = 1<<24 - 1
+	mysqlCharsetUTF8MB4 = 45
+)
+
+const (
+	binlogDialTimeout = 30 * time.Second
+	binlogReadTimeout = 5 * time.Minute
+)
+
+// BinlogConn is a minimal MySQL replication client. It authenticates with
+// mysql_native_password or caching_sha2_password and reads binlog events
+// requested with COM_BINLOG_DUMP. It is not safe for concurrent use.
+type BinlogConn struct {
+	conn     net.Conn
+	seq      byte
+	secure   bool
+	scramble []byte
+}
+
+// DialBinlog opens a replication connection using the MySQL connection settings
+// of dbCfg, including its ssl_mode.
+func DialBinlog(dbCfg config.DatabaseConfig) (*BinlogConn, error) {
+	port := dbCfg.Port
+	if port == "" {
+		port = "3306"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(dbCfg.Host, port), binlogDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mysql for binlog streaming: %w", err)
+	}
+	c := &BinlogConn{conn: conn}
+	if err := c.handshake(dbCfg); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mysql binlog handshake failed: %w", err)
+	}
+	return c, nil
+}
+
+// Close sends COM_QUIT and closes the connection.
+func (c *BinlogConn) Close() error {
+	c.seq = 0
+	_ = c.writePacket([]byte{mysqlComQuit})
+	return c.conn.Close()
+}
+
+// Interrupt unblocks a pending read, e.g. when the task is cancelled. The
+// connection is unusable afterwards and must be closed.
+func (c *BinlogConn) Interrupt() {
+	_ = c.conn.SetDeadline(time.Unix(1, 0))
+}
+
+// Exec runs a statement that returns no result set, such as SET.
+func (c *BinlogConn) Exec(query string) error {
+	c.seq = 0
+	if err := c.writePacket(append([]byte{mysqlComQuery}, query...)); err != nil {
+		return err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	switch data[0] {
+	case 0x00:
+		return nil
+	case 0xff:
+		return parseMySQLError(data)
+	default:
+		return fmt.Errorf("unexpected response to %q", query)
+	}
+}
+
+// StartDump asks the server to stream events from pos. The stream ends once
+// all events written so far have been sent.
+func (c *BinlogConn) StartDump(pos BinlogPosition) error {
+	c.seq = 0
+	buf := []byte{mysqlComBinlogDump}
+	buf = binary.LittleEndian.AppendUint32(buf, pos.Pos)
+	buf = binary.LittleEndian.AppendUint16(buf, binlogDumpNonBlock)
+	buf = binary.LittleEndian.AppendUint32(buf, 0) // server_id 0: not a registered replica
+	buf = append(buf, pos.File...)
+	return c.writePacket(buf)
+}
+
+// ReadEvent returns the next raw event, including its header, or nil once the
+// end of the binlog has been reached.
+func (c *BinlogConn) ReadEvent() ([]byte, error) {
+	data, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case data[0] == 0x00:
+		return data[1:], nil
+	case data[0] == 0xfe && len(data) < 9:
+		return nil, nil
+	case data[0] == 0xff:
+		return nil, parseMySQLError(data)
+	default:
+		return nil, fmt.Errorf("unexpected binlog packet header 0x%02x", data[0])
+	}
+}
+
+func (c *BinlogConn) handshake(dbCfg config.DatabaseConfig) error {
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if data[0] == 0xff {
+		return parseMySQLError(data)
+	}
+	r := &binlogReader{buf: data}
+	if v := r.readUint(1); v != 10 && r.err == nil {
+		return fmt.Errorf("unsupported protocol version %d", v)
+	}
+	if i := bytes.IndexByte(r.buf, 0); i >= 0 {
+		r.skip(i + 1) // server version
+	}
+	r.skip(4) // connection id
+	scramble := append([]byte(nil), r.readBytes(8)...)
+	r.skip(1)
+	serverCaps := uint32(r.readUint(2))
+	r.skip(3) // charset, status flags
+	serverCaps |= uint32(r.readUint(2)) << 16
+	authLen := int(r.readUint(1))
+	r.skip(10)
+	if n := max(13, authLen-8); serverCaps&mysqlClientSecureConnection != 0 {
+		part := r.readBytes(n)
+		scramble = append(scramble, bytes.TrimRight(part, "\x00")...)
+	}
+	plugin := "mysql_native_password"
+	if serverCaps&mysqlClientPluginAuth != 0 {
+		if i := bytes.IndexByte(r.buf, 0); i >= 0 {
+			plugin = string(r.buf[:i])
+		} else if len(r.buf) > 0 {
+			plugin = string(r.buf)
+		}
+	}
+	if r.err != nil {
+		return fmt.Errorf("invalid handshake packet: %w", r.err)
+	}
+	c.scramble = scramble
+
+	caps := uint32(mysqlClientLongPassword | mysqlClientLongFlag | mysqlClientProtocol41 |
+		mysqlClientTransactions | mysqlClientSecureConnection | mysqlClientPluginAuth)
+	if dbCfg.Database != "" {
+		caps |= mysqlClientConnectWithDB
+	}
+
+	header := binary.LittleEndian.AppendUint32(nil, 0)
+	header = binary.LittleEndian.AppendUint32(header, mysqlMaxPacketSize)
+	header = append(header, mysqlCharsetUTF8MB4)
+	header = append(header, make([]byte, 23)...)
+
+	if dbCfg.SSLMode != "" && dbCfg.SSLMode != config.SSLModeDisable {
+		if serverCaps&mysqlClientSSL == 0 {
+			return fmt.Errorf("server does not support TLS but ssl_mode is %q", dbCfg.SSLMode)
+		}
+		caps |= mysqlClientSSL
+		binary.LittleEndian.PutUint32(header, caps)
+		if err := c.writePacket(header); err != nil {
+			return err
+		}
+		tlsConfig, err := buildTLSConfig(dbCfg)
+		if err != nil {
+			return err
+		}
+		tlsConn := tls.Client(c.conn, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return fmt.Errorf("tls handshake failed: %w", err)
+		}
+		c.conn = tlsConn
+		c.secure = true
+	}
+	binary.LittleEndian.PutUint32(header, caps)
+
+	authResp, err := c.authResponse(plugin, dbCfg.Password)
+	if err != nil {
+		return err
+	}
+	resp := append([]byte(nil), header...)
+	resp = append(append(resp, dbCfg.User...), 0)
+	resp = append(resp, byte(len(authResp)))
+	resp = append(resp, authResp...)
+	if dbCfg.Database != "" {
+		resp = append(append(resp, dbCfg.Database...), 0)
+	}
+	resp = append(append(resp, plugin...), 0)
+	if err := c.writePacket(resp); err != nil {
+		return err
+	}
+	return c.finishAuth(plugin, dbCfg.Password)
+}
+
+func (c *BinlogConn) authResponse(plugin, password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	switch plugin {
+	case "mysql_native_password":
+		return scrambleNativePassword(c.scramble, password), nil
+	case "caching_sha2_password":
+		return scrambleSHA256Password(c.scramble, password), nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication plugin %q", plugin)
+	}
+}
+
+func (c *BinlogConn) finishAuth(plugin, password string) error {
+	for {
+		data, err := c.readPacket()
+		if err != nil {
+			return err
+		}
+		switch data[0] {
+		case 0x00:
+			return nil
+		case 0xff:
+			return parseMySQLError(data)
+		case 0xfe:
+			// Auth switch request: plugin name followed by a new scramble.
+			name, rest, _ := bytes.Cut(data[1:], []byte{0})
+			plugin = string(name)
+			c.scramble = bytes.TrimRight(rest, "\x00")
+			resp, err := c.authResponse(plugin, password)
+			if err != nil {
+				return err
+			}
+			if err := c.writePacket(resp); err != nil {
+				return err
+			}
+		case 0x01:
+			if plugin != "caching_sha2_password" || len(data) < 2 {
+				return fmt.Errorf("unexpected auth packet for plugin %q", plugin)
+			}
+			switch data[1] {
+			case 0x03:
+				// Fast auth succeeded; the OK packet follows.
+			case 0x04:
+				if err := c.fullSHA256Auth(password); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unexpected caching_sha2_password state 0x%02x", data[1])
+			}
+		default:
+			return fmt.Errorf("unexpected auth packet header 0x%02x", data[0])
+		}
+	}
+}
+
+// fullSHA256Auth sends the password in clear text over TLS, or encrypted with
+// the server's RSA public key otherwise.
+func (c *BinlogConn) fullSHA256Auth(password string) error {
+	plain := append([]byte(password), 0)
+	if c.secure {
+		return c.writePacket(plain)
+	}
+	if err := c.writePacket([]byte{0x02}); err != nil {
+		return err
+	}
+	data, err := c.readPacket()
+	if err != nil {
+		return err
+	}
+	if data[0] != 0x01 {
+		return fmt.Errorf("failed to read server public key")
+	}
+	block, _ := pem.Decode(data[1:])
+	if block == nil {
+		return fmt.Errorf("invalid server public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("invalid server public key: %w", err)
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("server public key is not an RSA key")
+	}
+	for i := range plain {
+		plain[i] ^= c.scramble[i%len(c.scramble)]
+	}
+	enc, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt password: %w", err)
+	}
+	return c.writePacket(enc)
+}
+
+func scrambleNativePassword(scramble []byte, password string) []byte {
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	out := h.Sum(nil)
+	for i := range out {
+		out[i] ^= stage1[i]
+	}
+	return out
+}
+
+func scrambleSHA256Password(scramble []byte, password string) []byte {
+	m1 := sha256.Sum256([]byte(password))
+	m2 := sha256.Sum256(m1[:])
+	h := sha256.New()
+	h.Write(m2[:])
+	h.Write(scramble)
+	out := h.Sum(nil)
+	for i := range out {
+		out[i] ^= m1[i]
+	}
+	return out
+}
+
+func parseMySQLError(data []byte) error {
+	r := &binlogReader{buf: data[1:]}
+	code := r.readUint(2)
+	msg := r.rest()
+	if len(msg) > 0 && msg[0] == '#' && len(msg) >= 6 {
+		msg = msg[6:]
+	}
+	return fmt.Errorf("mysql error %d: %s", code, msg)
+}
+
+func (c *BinlogConn) readPacket() ([]byte, error) {
+	var payload []byte
+	for {
+		if err := c.conn.SetReadDeadline(time.Now().Add(binlogReadTimeout)); err != nil {
+			return nil, err
+		}
+		var header [4]byte
+		if _, err := io.ReadFull(c.conn, header[:]); err != nil {
+			return nil, fmt.Errorf("failed to read mysql packet: %w", err)
+		}
+		size := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+		c.seq = header[3] + 1
+		chunk := make([]byte, size)
+		if _, err := io.ReadFull(c.conn, chunk); err != nil {
+			return nil, fmt.Errorf("failed to read mysql packet: %w", err)
+		}
+		payload = append(payload, chunk...)
+		if size < mysqlMaxPacketSize {
+			break
+		}
+	}
+	if len(payload) == 0 {
+		return nil, fmt.Errorf("empty mysql packet")
+	}
+	return payload, nil
+}
+
+func (c *BinlogConn) writePacket(payload []byte) error {
+	for {
+		size := min(len(payload), mysqlMaxPacketSize)
+		buf := make([]byte, 4, 4+size)
+		buf[0], buf[1], buf[2] = byte(size), byte(size>>8), byte(size>>16)
+		buf[3] = c.seq
+		c.seq++
+		buf = append(buf, payload[:size]...)
+		if _, err := c.conn.Write(buf); err != nil {
+			return fmt.Errorf("failed to write mysql packet: %w", err)
+		}
+		payload = payload[size:]
+		if size < mysqlMaxPacketSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MySQL binary JSON value types.
+const (
+	jsonbSmallObject = 0x00
+	jsonbLargeObject = 0x01
+	jsonbSmallArray  = 0x02
+	jsonbLargeArray  = 0x03
+	jsonbLiteral     = 0x04
+	jsonbInt16       = 0x05
+	jsonbUint16      = 0x06
+	jsonbInt32       = 0x07
+	jsonbUint32      = 0x08
+	jsonbInt64       = 0x09
+	jsonbUint64      = 0x0a
+	jsonbDouble      = 0x0b
+	jsonbString      = 0x0c
+	jsonbOpaque      = 0x0f
+
+	jsonbLiteralNull  = 0x00
+	jsonbLiteralTrue  = 0x01
+	jsonbLiteralFalse = 0x02
+)
+
+// decodeBinlogJSON converts a JSON column value in MySQL's binary encoding
+// into JSON text formatted the way MySQL prints it (", " and ": " separators),
+// which is what a SELECT of the column returns.
+func decodeBinlogJSON(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "null", nil
+	}
+	var sb strings.Builder
+	if err := writeJSONValue(&sb, data[0], data[1:]); err != nil {
+		return "", fmt.Errorf("invalid binary JSON value: %w", err)
+	}
+	return sb.String(), nil
+}
+
+func writeJSONValue(sb *strings.Builder, typ byte, data []byte) error {
+	switch typ {
+	case jsonbSmallObject, jsonbLargeObject:
+		return writeJSONContainer(sb, data, typ == jsonbLargeObject, true)
+	case jsonbSmallArray, jsonbLargeArray:
+		return writeJSONContainer(sb, data, typ == jsonbLargeArray, false)
+	case jsonbLiteral:
+		if len(data) < 1 {
+			return errJSONTruncated
+		}
+		switch data[0] {
+		case jsonbLiteralNull:
+			sb.WriteString("null")
+		case jsonbLiteralTrue:
+			sb.WriteString("true")
+		case jsonbLiteralFalse:
+			sb.WriteString("false")
+		default:
+			return fmt.Errorf("unknown literal %#x", data[0])
+		}
+	case jsonbInt16, jsonbUint16, jsonbInt32, jsonbUint32, jsonbInt64, jsonbUint64:
+		size := jsonIntSize(typ)
+		if len(data) < size {
+			return errJSONTruncated
+		}
+		var v uint64
+		for i := size - 1; i >= 0; i-- {
+			v = v<<8 | uint64(data[i])
+		}
+		if typ == jsonbUint16 || typ == jsonbUint32 || typ == jsonbUint64 {
+			sb.WriteString(strconv.FormatUint(v, 10))
+		} else {
+			shift := uint(64 - 8*size)
+			sb.WriteString(strconv.FormatInt(int64(v<<shift)>>shift, 10))
+		}
+	case jsonbDouble:
+		if len(data) < 8 {
+			return errJSONTruncated
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(data))
+		sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case jsonbString:
+		n, used, err := readJSONVarLen(data)
+		if err != nil {
+			return err
+		}
+		if len(data) < used+n {
+			return errJSONTruncated
+		}
+		writeJSONString(sb, string(data[used:used+n]))
+	case jsonbOpaque:
+		return writeJSONOpaque(sb, data)
+	default:
+		return fmt.Errorf("unknown value type %#x", typ)
+	}
+	return nil
+}
+
+var errJSONTruncated = fmt.Errorf("value truncated")
+
+func jsonIntSize(typ byte) int {
+	switch typ {
+	case jsonbInt16, jsonbUint16:
+		return 2
+	case jsonbInt32, jsonbUint32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// writeJSONContainer writes an object or array. Offsets inside a container
+// are relative to its start; small containers use 2-byte offsets and sizes,
+// large ones 4-byte.
+func writeJSONContainer(sb *strings.Builder, data []byte, large, object bool) error {
+	width := 2
+	if large {
+		width = 4
+	}
+	readN := func(b []byte) int {
+		if width == 2 {
+			return int(binary.LittleEndian.Uint16(b))
+		}
+		return int(binary.LittleEndian.Uint32(b))
+	}
+	if len(data) < 2*width {
+		return errJSONTruncated
+	}
+	count, size := readN(data), readN(data[width:])
+	if size > len(data) {
+		return errJSONTruncated
+	}
+	data = data[:size]
+
+	keyEntries := 2 * width
+	valueEntries := keyEntries
+	if object {
+		valueEntries += count * (width + 2)
+	}
+	if valueEntries+count*(1+width) > len(data) {
+		return errJSONTruncated
+	}
+
+	open, close := "[", "]"
+	if object {
+		open, close = "{", "}"
+	}
+	sb.WriteString(open)
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if object {
+			entry := data[keyEntries+i*(width+2):]
+			offset, length := readN(entry), int(binary.LittleEndian.Uint16(entry[width:]))
+			if offset+length > len(data) {
+				return errJSONTruncated
+			}
+			writeJSONString(sb, string(data[offset:offset+length]))
+			sb.WriteString(": ")
+		}
+		entry := data[valueEntries+i*(1+width):]
+		typ := entry[0]
+		if jsonInlined(typ, large) {
+			if err := writeJSONValue(sb, typ, entry[1:1+width]); err != nil {
+				return err
+			}
+			continue
+		}
+		offset := readN(entry[1:])
+		if offset >= len(data) {
+			return errJSONTruncated
+		}
+		if err := writeJSONValue(sb, typ, data[offset:]); err != nil {
+			return err
+		}
+	}
+	sb.WriteString(close)
+	return nil
+}
+
+// jsonInlined reports whether a value of typ is stored in its entry instead
+// of at an offset.
+func jsonInlined(typ byte, large bool) bool {
+	switch typ {
+	case jsonbLiteral, jsonbInt16, jsonbUint16:
+		return true
+	case jsonbInt32, jsonbUint32:
+		return large
+	}
+	return false
+}
+
+// readJSONVarLen reads a length stored 7 bits per byte, low bits first.
+func readJSONVarLen(data []byte) (int, int, error) {
+	var n int
+	for i := 0; i < len(data) && i < 5; i++ {
+		n |= int(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return n, i + 1, nil
+		}
+	}
+	return 0, 0, errJSONTruncated
+}
+
+// writeJSONOpaque writes values of MySQL types without a JSON counterpart
+// (DECIMAL, temporal types) as JSON strings or numbers the way MySQL does.
+func writeJSONOpaque(sb *strings.Builder, data []byte) error {
+	if len(data) < 1 {
+		return errJSONTruncated
+	}
+	fieldType := data[0]
+	n, used, err := readJSONVarLen(data[1:])
+	if err != nil {
+		return err
+	}
+	if len(data) < 1+used+n {
+		return errJSONTruncated
+	}
+	value := data[1+used : 1+used+n]
+
+	switch fieldType {
+	case mysqlTypeNewDecimal:
+		if len(value) < 2 {
+			return errJSONTruncated
+		}
+		r := &binlogReader{buf: value[2:]}
+		d, err := r.readDecimal(int(value[0]), int(value[1]))
+		if err != nil {
+			return err
+		}
+		sb.WriteString(d.(string))
+		return nil
+	case mysqlTypeDate, mysqlTypeDateTime, mysqlTypeTimestamp, mysqlTypeDateTime2, mysqlTypeTimestamp2,
+		mysqlTypeTime, mysqlTypeTime2:
+		if len(value) < 8 {
+			return errJSONTruncated
+		}
+		writeJSONString(sb, formatPackedTemporal(fieldType, int64(binary.LittleEndian.Uint64(value))))
+		return nil
+	}
+	writeJSONString(sb, fmt.Sprintf("base64:type%d:%s", fieldType, base64.StdEncoding.EncodeToString(value)))
+	return nil
+}
+
+// formatPackedTemporal formats MySQL's packed in-memory temporal value: the
+// date/time fields in the high bits and microseconds in the low 24 bits.
+func formatPackedTemporal(fieldType byte, packed int64) string {
+	sign := ""
+	if packed < 0 {
+		sign = "-"
+		packed = -packed
+	}
+	intpart, micros := packed>>24, packed%(1<<24)
+	frac := ""
+	if micros != 0 {
+		frac = fmt.Sprintf(".%06d", micros)
+	}
+	switch fieldType {
+	case mysqlTypeTime, mysqlTypeTime2:
+		return fmt.Sprintf("%s%02d:%02d:%02d", sign, (intpart>>12)%(1<<10), (intpart>>6)%(1<<6), intpart%(1<<6)) + frac
+	case mysqlTypeDate:
+		ymd := intpart >> 17
+		ym := ymd >> 5
+		return fmt.Sprintf("%04d-%02d-%02d", ym/13, ym%13, ymd&31)
+	}
+	ymd, hms := intpart>>17, intpart%(1<<17)
+	ym := ymd >> 5
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", ym/13, ym%13, ymd&31, hms>>12, (hms>>6)&63, hms&63) + frac
+}
+
+func writeJSONString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == utf8.RuneError {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"db-ferry/config"
+)
+
+// TestBinlogConnAgainstMySQL streams real binlog events through BinlogConn and
+// BinlogParser. It needs a MySQL 8 server with binlog_format = ROW and a user
+// holding REPLICATION SLAVE/CLIENT, given as a go-sql-driver DSN:
+//
+//	DB_FERRY_TEST_MYSQL_DSN='root:secret@tcp(127.0.0.1:3306)/test' go test ./database -run AgainstMySQL
+func TestBinlogConnAgainstMySQL(t *testing.T) {
+	dsn := os.Getenv("DB_FERRY_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("DB_FERRY_TEST_MYSQL_DSN is not set")
+	}
+	mycfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("ParseDSN() error = %v", err)
+	}
+	mycfg.ParseTime = true
+	db, err := sql.Open("mysql", mycfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("open mysql error = %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	mustExec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+	}
+	mustExec("DROP TABLE IF EXISTS dbf_binlog_it")
+	mustExec("CREATE TABLE dbf_binlog_it (id INT PRIMARY KEY, name VARCHAR(20), doc JSON, ts TIMESTAMP(3) NULL, amount DECIMAL(10,2))")
+	t.Cleanup(func() { _, _ = db.Exec("DROP TABLE IF EXISTS dbf_binlog_it") })
+
+	pos := currentBinlogPosition(t, db)
+	var offset int
+	if err := db.QueryRow("SELECT TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), NOW())").Scan(&offset); err != nil {
+		t.Fatalf("read session offset error = %v", err)
+	}
+
+	mustExec(`INSERT INTO dbf_binlog_it VALUES (1, 'ann', '{"k": [1, "x", 2.5], "n": null, "s": "a\"b"}', '2024-01-02 03:04:05.678', 12.34)`)
+	var wantDoc string
+	if err := db.QueryRow("SELECT CAST(doc AS CHAR) FROM dbf_binlog_it WHERE id = 1").Scan(&wantDoc); err != nil {
+		t.Fatalf("read doc error = %v", err)
+	}
+	mustExec("UPDATE dbf_binlog_it SET name = 'bob' WHERE id = 1")
+	mustExec("DELETE FROM dbf_binlog_it WHERE id = 1")
+
+	host, port, err := net.SplitHostPort(mycfg.Addr)
+	if err != nil {
+		t.Fatalf("invalid DSN address %q: %v", mycfg.Addr, err)
+	}
+	conn, err := DialBinlog(config.DatabaseConfig{Type: config.DatabaseTypeMySQL, Host: host, Port: port, User: mycfg.User, Password: mycfg.Passwd, Database: mycfg.DBName})
+	if err != nil {
+		t.Fatalf("DialBinlog() error = %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Exec("SET @master_binlog_checksum = @@global.binlog_checksum"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := conn.StartDump(pos); err != nil {
+		t.Fatalf("StartDump() error = %v", err)
+	}
+
+	bp := NewBinlogParser(false)
+	bp.SetLocation(time.FixedZone("session", offset))
+	var events []*BinlogEvent
+	for {
+		data, err := conn.ReadEvent()
+		if err != nil {
+			t.Fatalf("ReadEvent() error = %v", err)
+		}
+		if data == nil {
+			break
+		}
+		ev, err := bp.Parse(data)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if ev.TableMap != nil && ev.TableMap.Table == "dbf_binlog_it" {
+			ev.TableMap.Columns = []string{"id", "name", "doc", "ts", "amount"}
+			ev.TableMap.Unsigned = make([]bool, 5)
+		}
+		if ev.IsRowsEvent() && ev.Table.Table == "dbf_binlog_it" {
+			events = append(events, ev)
+		}
+	}
+
+	if len(events) != 3 {
+		t.Fatalf("expected insert, update and delete, got %d rows events", len(events))
+	}
+	ins := events[0].Rows[0].After
+	if !events[0].IsInsert() || ins[0] != int64(1) || ins[1] != "ann" || ins[2] != wantDoc || ins[3] != "2024-01-02 03:04:05.678" || ins[4] != "12.34" {
+		t.Fatalf("unexpected insert row %#v (want doc %s)", ins, wantDoc)
+	}
+	if !events[1].IsUpdate() || events[1].Rows[0].After[1] != "bob" {
+		t.Fatalf("unexpected update %#v", events[1].Rows)
+	}
+	if !events[2].IsDelete() || events[2].Rows[0].Before[0] != int64(1) {
+		t.Fatalf("unexpected delete %#v", events[2].Rows)
+	}
+}
+
+func currentBinlogPosition(t *testing.T, db *sql.DB) BinlogPosition {
+	t.Helper()
+	rows, err := db.Query("SHOW MASTER STATUS")
+	if err != nil {
+		if rows, err = db.Query("SHOW BINARY LOG STATUS"); err != nil {
+			t.Fatalf("read binlog status error = %v", err)
+		}
+	}
+	defer rows.Close()
+	cols, _ := rows.Columns()
+	if !rows.Next() {
+		t.Fatalf("binary logging is not enabled")
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		t.Fatalf("scan binlog status error = %v", err)
+	}
+	pos, err := ParseBinlogPosition(values[0].String + ":" + values[1].String)
+	if err != nil {
+		t.Fatalf("ParseBinlogPosition() error = %v", err)
+	}
+	return pos
+}
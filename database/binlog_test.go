@@ -0,0 +1,361 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+)
+
+// binlogEvent wraps an event body in a v4 header.
+func binlogEvent(typ byte, nextPos uint32, body []byte) []byte {
+	ev := make([]byte, binlogHeaderSize, binlogHeaderSize+len(body))
+	ev[4] = typ
+	binary.LittleEndian.PutUint32(ev[9:], uint32(binlogHeaderSize+len(body)))
+	binary.LittleEndian.PutUint32(ev[13:], nextPos)
+	return append(ev, body...)
+}
+
+// usersTableMap announces users(id INT, name VARCHAR(50), amount DECIMAL(10,2), created DATETIME).
+func usersTableMap() []byte {
+	body := []byte{42, 0, 0, 0, 0, 0, 1, 0}
+	body = append(body, 3)
+	body = append(body, "app\x00"...)
+	body = append(body, 5)
+	body = append(body, "users\x00"...)
+	body = append(body, 4, mysqlTypeLong, mysqlTypeVarchar, mysqlTypeNewDecimal, mysqlTypeDateTime2)
+	body = append(body, 5, 200, 0, 10, 2, 0)
+	body = append(body, 0x0e)
+	return binlogEvent(BinlogTableMapEvent, 300, body)
+}
+
+// usersRow encodes a full row image; a nil name is written as NULL.
+func usersRow(id uint32, name any) []byte {
+	nulls := byte(0)
+	if name == nil {
+		nulls = 0x02
+	}
+	row := []byte{nulls}
+	row = binary.LittleEndian.AppendUint32(row, id)
+	if s, ok := name.(string); ok {
+		row = append(row, byte(len(s)))
+		row = append(row, s...)
+	}
+	// DECIMAL(10,2) 1234.56
+	row = append(row, 0x80, 0x00, 0x04, 0xd2, 0x38)
+	// DATETIME 2024-03-05 10:20:30
+	ymd := uint64((2024*13+3)<<5 | 5)
+	hms := uint64(10<<12 | 20<<6 | 30)
+	packed := ymd<<17 | hms + 0x8000000000
+	row = append(row, byte(packed>>32), byte(packed>>24), byte(packed>>16), byte(packed>>8), byte(packed))
+	return row
+}
+
+func usersRowsEvent(typ byte, rows ...[]byte) []byte {
+	body := []byte{42, 0, 0, 0, 0, 0, 0, 0, 2, 0, 4, 0x0f}
+	if typ == BinlogUpdateRowsV2 {
+		body = append(body, 0x0f)
+	}
+	for _, r := range rows {
+		body = append(body, r...)
+	}
+	return binlogEvent(typ, 400, body)
+}
+
+func TestBinlogParserDecodesRowEvents(t *testing.T) {
+	bp := NewBinlogParser(false)
+
+	ev, err := bp.Parse(usersTableMap())
+	if err != nil {
+		t.Fatalf("Parse(table map) error = %v", err)
+	}
+	tm := ev.TableMap
+	if tm == nil || tm.Schema != "app" || tm.Table != "users" || len(tm.Types) != 4 {
+		t.Fatalf("unexpected table map: %+v", tm)
+	}
+	if tm.Meta[1] != 200 || tm.Meta[2] != 10<<8|2 {
+		t.Fatalf("unexpected column metadata: %v", tm.Meta)
+	}
+
+	// Rows of tables without column names are skipped.
+	ev, err = bp.Parse(usersRowsEvent(BinlogWriteRowsEventV2, usersRow(1, "ann")))
+	if err != nil {
+		t.Fatalf("Parse(undescribed rows) error = %v", err)
+	}
+	if ev.Table != tm || ev.Rows != nil {
+		t.Fatalf("expected undecoded rows, got %+v", ev.Rows)
+	}
+
+	tm.Columns = []string{"id", "name", "amount", "created"}
+	ev, err = bp.Parse(usersRowsEvent(BinlogWriteRowsEventV2, usersRow(1, "ann"), usersRow(2, nil)))
+	if err != nil {
+		t.Fatalf("Parse(write rows) error = %v", err)
+	}
+	if !ev.IsInsert() || len(ev.Rows) != 2 {
+		t.Fatalf("unexpected insert event: %+v", ev)
+	}
+	got := ev.Rows[0].After
+	if got[0] != int64(1) || got[1] != "ann" || got[2] != "1234.56" || got[3] != "2024-03-05 10:20:30" {
+		t.Fatalf("unexpected row values: %#v", got)
+	}
+	if ev.Rows[1].After[1] != nil {
+		t.Fatalf("expected NULL name, got %#v", ev.Rows[1].After[1])
+	}
+
+	ev, err = bp.Parse(usersRowsEvent(BinlogUpdateRowsV2, usersRow(2, "bo"), usersRow(2, "bob")))
+	if err != nil {
+		t.Fatalf("Parse(update rows) error = %v", err)
+	}
+	if !ev.IsUpdate() || ev.Rows[0].Before[1] != "bo" || ev.Rows[0].After[1] != "bob" {
+		t.Fatalf("unexpected update event: %+v", ev.Rows)
+	}
+
+	truncated := usersRowsEvent(BinlogDeleteRowsV2, usersRow(3, "cy"))
+	if _, err := bp.Parse(truncated[:len(truncated)-2]); err == nil {
+		t.Fatalf("expected truncated row error")
+	}
+}
+
+func TestBinlogParserChecksumAndControlEvents(t *testing.T) {
+	withCRC := func(ev []byte) []byte {
+		return binary.LittleEndian.AppendUint32(ev, crc32.ChecksumIEEE(ev))
+	}
+	bp := NewBinlogParser(true)
+
+	rotate := binary.LittleEndian.AppendUint64(nil, 4)
+	rotate = append(rotate, "mysql-bin.000007"...)
+	ev, err := bp.Parse(withCRC(binlogEvent(BinlogRotateEvent, 0, rotate)))
+	if err != nil {
+		t.Fatalf("Parse(rotate) error = %v", err)
+	}
+	if ev.NextFile != "mysql-bin.000007" || ev.NextPos != 4 {
+		t.Fatalf("unexpected rotate: %+v", ev)
+	}
+
+	gtid := []byte{1}
+	gtid = append(gtid, bytes.Repeat([]byte{0xab}, 16)...)
+	gtid = binary.LittleEndian.AppendUint64(gtid, 17)
+	ev, err = bp.Parse(withCRC(binlogEvent(BinlogGTIDEvent, 500, gtid)))
+	if err != nil {
+		t.Fatalf("Parse(gtid) error = %v", err)
+	}
+	if ev.GTID != "abababab-abab-abab-abab-abababababab:17" {
+		t.Fatalf("unexpected gtid %q", ev.GTID)
+	}
+
+	query := binary.LittleEndian.AppendUint32(nil, 9)
+	query = binary.LittleEndian.AppendUint32(query, 0)
+	query = append(query, 3, 0, 0, 0, 0)
+	query = append(query, "app\x00BEGIN"...)
+	ev, err = bp.Parse(withCRC(binlogEvent(BinlogQueryEvent, 600, query)))
+	if err != nil {
+		t.Fatalf("Parse(query) error = %v", err)
+	}
+	if ev.Query != "BEGIN" || ev.NextPos != 600 {
+		t.Fatalf("unexpected query event: %+v", ev)
+	}
+
+	if _, err := bp.Parse(binlogEvent(BinlogTransactionPayload, 0, make([]byte, 8))); err == nil {
+		t.Fatalf("expected compressed transaction error")
+	}
+}
+
+func TestFormatDescChecksum(t *testing.T) {
+	body := make([]byte, 2+50+4+1+40)
+	copy(body[2:], "8.0.36")
+	body = append(body, 1, 0, 0, 0, 0)
+	if !formatDescChecksum(body) {
+		t.Fatalf("expected CRC32 checksum for 8.0 server")
+	}
+	body[len(body)-5] = 0
+	if formatDescChecksum(body) {
+		t.Fatalf("expected no checksum when algorithm is off")
+	}
+	copy(body[2:], "5.5.62")
+	body[len(body)-5] = 1
+	if formatDescChecksum(body) {
+		t.Fatalf("expected no checksum for servers before 5.6.1")
+	}
+}
+
+func TestBinlogPositionRoundTrip(t *testing.T) {
+	pos, err := ParseBinlogPosition(" mysql-bin.000003:1547 ")
+	if err != nil {
+		t.Fatalf("ParseBinlogPosition() error = %v", err)
+	}
+	if pos.File != "mysql-bin.000003" || pos.Pos != 1547 || pos.String() != "mysql-bin.000003:1547" {
+		t.Fatalf("unexpected position %+v", pos)
+	}
+	for _, bad := range []string{"", "mysql-bin.000003", ":4", "mysql-bin.000003:x"} {
+		if _, err := ParseBinlogPosition(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+// fakeBinlogServer replays a handshake and recorded events to one client.
+func fakeBinlogServer(t *testing.T, events [][]byte) (string, <-chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	dump := make(chan []byte, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		write := func(seq byte, payload []byte) {
+			header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+			conn.Write(append(header, payload...))
+		}
+		read := func() []byte {
+			var header [4]byte
+			if _, err := io.ReadFull(conn, header[:]); err != nil {
+				return nil
+			}
+			buf := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+			io.ReadFull(conn, buf)
+			return buf
+		}
+
+		scramble := []byte("abcdefghijklmnopqrst")
+		hs := []byte{10}
+		hs = append(hs, "8.0.36\x00"...)
+		hs = append(hs, 1, 0, 0, 0)
+		hs = append(hs, scramble[:8]...)
+		hs = append(hs, 0)
+		caps := uint32(mysqlClientProtocol41 | mysqlClientSecureConnection | mysqlClientPluginAuth)
+		hs = binary.LittleEndian.AppendUint16(hs, uint16(caps))
+		hs = append(hs, 45, 2, 0)
+		hs = binary.LittleEndian.AppendUint16(hs, uint16(caps>>16))
+		hs = append(hs, 21)
+		hs = append(hs, make([]byte, 10)...)
+		hs = append(hs, scramble[8:]...)
+		hs = append(hs, 0)
+		hs = append(hs, "mysql_native_password\x00"...)
+		write(0, hs)
+
+		resp := read()
+		want := scrambleNativePassword(scramble, "secret")
+		if !bytes.Contains(resp, append([]byte("repl\x00\x14"), want...)) {
+			write(2, append([]byte{0xff, 0x15, 0x04}, "#28000Access denied"...))
+			return
+		}
+		write(2, []byte{0, 0, 0, 2, 0, 0, 0})
+
+		read() // SET @master_binlog_checksum
+		write(1, []byte{0, 0, 0, 2, 0, 0, 0})
+
+		dump <- read()
+		for i, ev := range events {
+			write(byte(i+1), append([]byte{0}, ev...))
+		}
+		write(byte(len(events)+1), []byte{0xfe, 0, 0, 2, 0})
+		read() // COM_QUIT
+	}()
+	return ln.Addr().String(), dump
+}
+
+func TestBinlogConnReplaysEvents(t *testing.T) {
+	events := [][]byte{usersTableMap(), usersRowsEvent(BinlogWriteRowsEventV2, usersRow(1, "ann"))}
+	addr, dump := fakeBinlogServer(t, events)
+	host, port, _ := net.SplitHostPort(addr)
+
+	conn, err := DialBinlog(config.DatabaseConfig{Type: config.DatabaseTypeMySQL, Host: host, Port: port, User: "repl", Password: "secret"})
+	if err != nil {
+		t.Fatalf("DialBinlog() error = %v", err)
+	}
+	defer conn.Close()
+	if err := conn.Exec("SET @master_binlog_checksum = 'NONE'"); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	if err := conn.StartDump(BinlogPosition{File: "mysql-bin.000002", Pos: 120}); err != nil {
+		t.Fatalf("StartDump() error = %v", err)
+	}
+
+	req := <-dump
+	if req[0] != mysqlComBinlogDump || binary.LittleEndian.Uint32(req[1:]) != 120 || string(req[11:]) != "mysql-bin.000002" {
+		t.Fatalf("unexpected dump request %q", req)
+	}
+	if flags := binary.LittleEndian.Uint16(req[5:]); flags != binlogDumpNonBlock {
+		t.Fatalf("expected non-blocking dump, got flags %d", flags)
+	}
+
+	for i, want := range events {
+		got, err := conn.ReadEvent()
+		if err != nil {
+			t.Fatalf("ReadEvent(%d) error = %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("event %d mismatch", i)
+		}
+	}
+	got, err := conn.ReadEvent()
+	if err != nil || got != nil {
+		t.Fatalf("expected end of stream, got %v, %v", got, err)
+	}
+}
+
+func TestBinlogConnReportsAuthError(t *testing.T) {
+	addr, _ := fakeBinlogServer(t, nil)
+	host, port, _ := net.SplitHostPort(addr)
+	_, err := DialBinlog(config.DatabaseConfig{Host: host, Port: port, User: "repl", Password: "wrong"})
+	if err == nil || !bytes.Contains([]byte(err.Error()), []byte("mysql error 1045: Access denied")) {
+		t.Fatalf("expected access denied error, got %v", err)
+	}
+}
+
+func TestDecodeBinlogJSON(t *testing.T) {
+	array := []byte{0x02, 0x00, 0x0c, 0x00, 0x04, 0x01, 0x00, 0x0c, 0x0a, 0x00, 0x01, 'x'}
+	object := []byte{0x00, 0x02, 0x00, 0x20, 0x00, 0x12, 0x00, 0x01, 0x00, 0x13, 0x00, 0x01, 0x00, 0x05, 0x01, 0x00, 0x02, 0x14, 0x00, 'a', 'b'}
+	object = append(object, array...)
+	got, err := decodeBinlogJSON(object)
+	if err != nil {
+		t.Fatalf("decodeBinlogJSON(object) error = %v", err)
+	}
+	if want := `{"a": 1, "b": [true, "x"]}`; got != want {
+		t.Fatalf("decodeBinlogJSON(object) = %s, want %s", got, want)
+	}
+
+	if got, err := decodeBinlogJSON([]byte{0x0c, 0x04, 'a', '"', '\n', 'b'}); err != nil || got != `"a\"\nb"` {
+		t.Fatalf("decodeBinlogJSON(string) = %s, %v", got, err)
+	}
+	if got, err := decodeBinlogJSON([]byte{0x0b, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f}); err != nil || got != "1.5" {
+		t.Fatalf("decodeBinlogJSON(double) = %s, %v", got, err)
+	}
+	if _, err := decodeBinlogJSON(object[:len(object)-3]); err == nil {
+		t.Fatalf("expected truncated JSON error")
+	}
+
+	// JSON columns are length-prefixed with meta bytes.
+	r := &binlogReader{buf: append([]byte{3, 0, 0, 0}, 0x04, 0x00, 0x00)}
+	if v, err := r.readValue(mysqlTypeJSON, 4, false); err != nil || v != "null" {
+		t.Fatalf("readValue(json) = %#v, %v", v, err)
+	}
+}
+
+func TestBinlogTimestampUsesParserLocation(t *testing.T) {
+	ts := binary.BigEndian.AppendUint32(nil, 1700000000)
+	r := &binlogReader{buf: ts, loc: time.FixedZone("+08:00", 8*3600)}
+	if v, err := r.readValue(mysqlTypeTimestamp2, 0, false); err != nil || v != "2023-11-15 06:13:20" {
+		t.Fatalf("readValue(timestamp2) = %#v, %v", v, err)
+	}
+	r = &binlogReader{buf: ts}
+	if v, err := r.readValue(mysqlTypeTimestamp2, 0, false); err != nil || v != "2023-11-14 22:13:20" {
+		t.Fatalf("readValue(timestamp2, UTC) = %#v, %v", v, err)
+	}
+	r = &binlogReader{buf: []byte{0, 0, 0, 0}}
+	if v, err := r.readValue(mysqlTypeTimestamp2, 0, false); err != nil || v != "0000-00-00 00:00:00" {
+		t.Fatalf("readValue(zero timestamp2) = %#v, %v", v, err)
+	}
+}
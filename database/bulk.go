@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrBulkLoadUnsupported 表示当前批次无法走原生批量装载，调用方应回退到 InsertData。
+var ErrBulkLoadUnsupported = errors.New("bulk load is not supported for this batch")
+
+// BulkLoader 由支持原生批量装载的目标库实现（PostgreSQL COPY、SQL Server bulk copy、
+// MySQL LOAD DATA LOCAL INFILE、DuckDB appender、Oracle 数组绑定）。
+type BulkLoader interface {
+	// BulkInsertData 以原生批量装载写入一个批次；返回 ErrBulkLoadUnsupported 时应改用 InsertData
+	BulkInsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error
+}
+
+// hasColumnTransform 报告是否有列带 transform 表达式；流式装载协议无法在目标端对参数求值。
+func hasColumnTransform(columns []ColumnMetadata) bool {
+	for _, col := range columns {
+		if strings.TrimSpace(col.Transform) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func columnNames(columns []ColumnMetadata) []string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = col.Name
+	}
+	return names
+}
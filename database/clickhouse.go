@@ -0,0 +1,561 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"db-ferry/config"
+)
+
+const (
+	clickHouseEngineMergeTree          = "MergeTree"
+	clickHouseEngineReplacingMergeTree = "ReplacingMergeTree"
+)
+
+// ClickHouseDB 通过 HTTP 接口读写 ClickHouse。
+// merge 模式按 upsert_strategy 写入：replacing 以 merge_keys 为排序键建 ReplacingMergeTree 表，
+// 直接追加新版本行，由后台合并（或查询时的 FINAL）去重；delete_insert 先按键删除旧行再插入。
+type ClickHouseDB struct {
+	db             *sql.DB
+	upsertStrategy string
+
+	mu      sync.Mutex
+	engines map[string]string // 表名 -> 表引擎，upsert 与计数时使用
+}
+
+var (
+	_ SourceDB          = (*ClickHouseDB)(nil)
+	_ TargetDB          = (*ClickHouseDB)(nil)
+	_ KeyedTableEnsurer = (*ClickHouseDB)(nil)
+)
+
+func NewClickHouseDB(dbCfg config.DatabaseConfig) (*ClickHouseDB, error) {
+	connector, err := newClickHouseConnector(dbCfg)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	if dbCfg.PoolMaxOpen > 0 {
+		db.SetMaxOpenConns(dbCfg.PoolMaxOpen)
+	}
+	if dbCfg.PoolMaxIdle > 0 {
+		db.SetMaxIdleConns(dbCfg.PoolMaxIdle)
+	}
+
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to ping clickhouse database: %w", err)
+	}
+
+	log.Println("Successfully connected to ClickHouse database")
+	strategy := dbCfg.UpsertStrategy
+	if strategy == "" {
+		strategy = config.ClickHouseUpsertReplacing
+	}
+	return &ClickHouseDB{db: db, upsertStrategy: strategy, engines: make(map[string]string)}, nil
+}
+
+func (c *ClickHouseDB) Close() error {
+	if c.db != nil {
+		return c.db.Close()
+	}
+	return nil
+}
+
+func (c *ClickHouseDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := c.db.ExecContext(ctx, sql, args...)
+	return err
+}
+
+// SwapTable 以 EXCHANGE TABLES 原子交换暂存表与目标表，再删除换下来的旧表
+func (c *ClickHouseDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeClickHouse, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	defer c.forgetEngine(stagingTable, tableName)
+	for _, stmt := range stmts {
+		if _, err := c.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+func (c *ClickHouseDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
+	log.Printf("Executing ClickHouse query: %s", sql)
+	rows, err := c.db.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute clickhouse query: %w", err)
+	}
+	return rows, nil
+}
+
+func (c *ClickHouseDB) GetRowCount(ctx context.Context, sql string) (int, error) {
+	var count int
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sql)
+	if err := c.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get row count: %w", err)
+	}
+	return count, nil
+}
+
+func (c *ClickHouseDB) GetTables() ([]string, error) {
+	query := `
+		SELECT name
+		FROM system.tables
+		WHERE database = currentDatabase() AND NOT is_temporary
+		ORDER BY name
+	`
+	rows, err := c.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func (c *ClickHouseDB) CreateTable(tableName string, columns []ColumnMetadata) error {
+	return c.createTable(tableName, columns, true, clickHouseEngineMergeTree, nil)
+}
+
+func (c *ClickHouseDB) EnsureTable(tableName string, columns []ColumnMetadata) error {
+	return c.createTable(tableName, columns, false, clickHouseEngineMergeTree, nil)
+}
+
+// EnsureKeyedTable 以 merge_keys 为排序键建表（如不存在）：replacing 策略使用 ReplacingMergeTree，
+// 相同键的行在合并时只保留最后写入的一行。
+func (c *ClickHouseDB) EnsureKeyedTable(tableName string, columns []ColumnMetadata, keys []string) error {
+	engine := clickHouseEngineReplacingMergeTree
+	if c.upsertStrategy == config.ClickHouseUpsertDeleteInsert {
+		engine = clickHouseEngineMergeTree
+	}
+	return c.createTable(tableName, columns, false, engine, keys)
+}
+
+func (c *ClickHouseDB) GetTableColumns(tableName string) ([]ColumnMetadata, error) {
+	query := `
+		SELECT name, type
+		FROM system.columns
+		WHERE database = currentDatabase() AND table = ?
+		ORDER BY position
+	`
+	rows, err := c.db.Query(query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table columns: %w", err)
+	}
+	defer rows.Close()
+
+	var cols []ColumnMetadata
+	for rows.Next() {
+		var name, dataType string
+		if err := rows.Scan(&name, &dataType); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		cols = append(cols, ColumnMetadata{Name: name, DatabaseType: dataType})
+	}
+	return cols, rows.Err()
+}
+
+func (c *ClickHouseDB) createTable(tableName string, columns []ColumnMetadata, dropExisting bool, engine string, orderBy []string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns provided for table creation")
+	}
+	defer c.forgetEngine(tableName)
+
+	if dropExisting {
+		dropSQL := BuildDropTableSQL(config.DatabaseTypeClickHouse, tableName)
+		log.Printf("Dropping existing ClickHouse table: %s", dropSQL)
+		if _, err := c.db.Exec(dropSQL); err != nil {
+			return fmt.Errorf("failed to drop table %s: %w", tableName, err)
+		}
+	}
+
+	createSQL := buildClickHouseCreateTableSQL(tableName, columns, !dropExisting, engine, orderBy)
+	log.Printf("Creating new ClickHouse table: %s", createSQL)
+	if _, err := c.db.Exec(createSQL); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// buildClickHouseCreateTableSQL 返回 ClickHouse 建表语句。orderBy 为空时按 tuple() 排序（不排序）；
+// 排序键列不允许为 NULL，建为非 Nullable 类型。
+func buildClickHouseCreateTableSQL(tableName string, columns []ColumnMetadata, ifNotExists bool, engine string, orderBy []string) string {
+	keySet := make(map[string]struct{}, len(orderBy))
+	for _, key := range orderBy {
+		keySet[strings.ToLower(key)] = struct{}{}
+	}
+
+	columnDefs := make([]string, len(columns))
+	for i, col := range columns {
+		if _, isKey := keySet[strings.ToLower(col.Name)]; isKey {
+			col.Nullable, col.NullableValid = false, true
+		}
+		columnDefs[i] = fmt.Sprintf("%s %s", QuoteIdentifier(config.DatabaseTypeClickHouse, col.Name), MapToClickHouseType(col))
+	}
+
+	order := "tuple()"
+	if len(orderBy) > 0 {
+		keys := make([]string, len(orderBy))
+		for i, key := range orderBy {
+			keys[i] = QuoteIdentifier(config.DatabaseTypeClickHouse, key)
+		}
+		order = "(" + strings.Join(keys, ", ") + ")"
+	}
+
+	createStmt := "CREATE TABLE"
+	if ifNotExists {
+		createStmt = "CREATE TABLE IF NOT EXISTS"
+	}
+	return fmt.Sprintf("%s %s (%s) ENGINE = %s ORDER BY %s",
+		createStmt,
+		QuoteIdentifier(config.DatabaseTypeClickHouse, tableName),
+		strings.Join(columnDefs, ", "),
+		engine,
+		order)
+}
+
+// InsertData 以一条 INSERT ... FORMAT JSONCompactEachRow 语句写入整个批次，ClickHouse 按一次插入生成一个数据分片。
+// 行数据作为请求体发送而不是内联为字面量；带 transform 的批次经 input() 表函数读入原始值，在服务端对表达式求值。
+func (c *ClickHouseDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var inputTypes []string
+	asString := make([]bool, len(columns))
+	if hasColumnTransform(columns) {
+		inputTypes = make([]string, len(columns))
+		for i := range columns {
+			inputTypes[i] = clickHouseInputType(values, i)
+			asString[i] = inputTypes[i] == "String"
+		}
+	}
+	insertSQL := buildClickHouseInsertSQL(tableName, columns, inputTypes)
+	data, err := encodeClickHouseRows(columns, values, asString)
+	if err != nil {
+		return err
+	}
+
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to insert batch: %w", err)
+	}
+	defer conn.Close()
+	err = conn.Raw(func(driverConn any) error {
+		_, err := driverConn.(*clickHouseConn).insert(ctx, insertSQL, data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert batch: %w", err)
+	}
+	return nil
+}
+
+// buildClickHouseInsertSQL 返回以 FORMAT 结尾的 INSERT 语句。inputTypes 为空时行数据直接按目标列类型解析；
+// inputTypes 非空时从 input('c1 T1, ...') 读取，transform 中的 ? 替换为对应的输入列。
+func buildClickHouseInsertSQL(tableName string, columns []ColumnMetadata, inputTypes []string) string {
+	columnNames := make([]string, len(columns))
+	for i, col := range columns {
+		columnNames[i] = QuoteIdentifier(config.DatabaseTypeClickHouse, col.Name)
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s)",
+		QuoteIdentifier(config.DatabaseTypeClickHouse, tableName),
+		strings.Join(columnNames, ", "))
+	if len(inputTypes) == 0 {
+		return insertSQL + " FORMAT " + clickHouseInputFormat
+	}
+
+	exprs := make([]string, len(columns))
+	structure := make([]string, len(columns))
+	for i, col := range columns {
+		exprs[i] = buildClickHousePlaceholder(i, col.Transform)
+		structure[i] = fmt.Sprintf("c%d Nullable(%s)", i+1, inputTypes[i])
+	}
+	return fmt.Sprintf("%s SELECT %s FROM input(%s) FORMAT %s",
+		insertSQL,
+		strings.Join(exprs, ", "),
+		quoteDialectString(config.DatabaseTypeClickHouse, strings.Join(structure, ", ")),
+		clickHouseInputFormat)
+}
+
+// clickHouseInputType 按批次中该列的非 NULL 值推断 input() 的列类型；值的类型不一致或全为 NULL 时按字符串读取。
+func clickHouseInputType(values [][]any, idx int) string {
+	inputType := ""
+	for _, row := range values {
+		if idx >= len(row) || row[idx] == nil {
+			continue
+		}
+		var t string
+		switch row[idx].(type) {
+		case bool:
+			t = "Bool"
+		case int, int8, int16, int32, int64:
+			t = "Int64"
+		case uint, uint8, uint16, uint32, uint64:
+			t = "UInt64"
+		case float32, float64:
+			t = "Float64"
+		case time.Time:
+			t = "DateTime64(6, 'UTC')"
+		default:
+			t = "String"
+		}
+		if inputType != "" && inputType != t {
+			return "String"
+		}
+		inputType = t
+	}
+	if inputType == "" {
+		return "String"
+	}
+	return inputType
+}
+
+// encodeClickHouseRows 把批次编码为 JSONCompactEachRow：每行一个 JSON 数组，以换行分隔。
+func encodeClickHouseRows(columns []ColumnMetadata, values [][]any, asString []bool) ([]byte, error) {
+	buf := make([]byte, 0, len(values)*len(columns)*16)
+	for _, row := range values {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("row has %d values, expected %d", len(row), len(columns))
+		}
+		buf = append(buf, '[')
+		for i, v := range row {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendClickHouseJSON(buf, v, asString[i])
+		}
+		buf = append(buf, ']', '\n')
+	}
+	return buf, nil
+}
+
+func (c *ClickHouseDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if len(mergeKeys) == 0 {
+		return fmt.Errorf("merge_keys is required for upsert")
+	}
+
+	if c.upsertStrategy != config.ClickHouseUpsertDeleteInsert {
+		engine, err := c.tableEngine(tableName)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(engine, clickHouseEngineReplacingMergeTree) {
+			return fmt.Errorf("table %s uses engine %s; upsert_strategy %q requires a ReplacingMergeTree ordered by the merge keys (or use %q)",
+				tableName, engine, config.ClickHouseUpsertReplacing, config.ClickHouseUpsertDeleteInsert)
+		}
+		return c.InsertData(ctx, tableName, columns, values)
+	}
+
+	keyIndexes := make([]int, len(mergeKeys))
+	for i, key := range mergeKeys {
+		keyIndexes[i] = -1
+		for j, col := range columns {
+			if strings.EqualFold(col.Name, key) {
+				keyIndexes[i] = j
+				break
+			}
+		}
+		if keyIndexes[i] < 0 {
+			return fmt.Errorf("merge key %s is not among the inserted columns", key)
+		}
+	}
+
+	// 同一批次内重复的键只保留最后一行，与其他方言逐行 upsert 的结果一致
+	rowKeys := make([]string, len(values))
+	latest := make(map[string]int, len(values))
+	keys := make([][]any, 0, len(values))
+	for i, row := range values {
+		key := make([]any, len(keyIndexes))
+		for j, idx := range keyIndexes {
+			key[j] = row[idx]
+		}
+		rowKeys[i] = fmt.Sprintf("%#v", key)
+		if _, seen := latest[rowKeys[i]]; !seen {
+			keys = append(keys, key)
+		}
+		latest[rowKeys[i]] = i
+	}
+	rows := make([][]any, 0, len(latest))
+	for i, row := range values {
+		if latest[rowKeys[i]] == i {
+			rows = append(rows, row)
+		}
+	}
+
+	deleteSQL, args := BuildDeleteByKeysSQL(config.DatabaseTypeClickHouse, tableName, mergeKeys, keys)
+	if _, err := c.db.ExecContext(ctx, deleteSQL, args...); err != nil {
+		return fmt.Errorf("failed to delete existing rows: %w", err)
+	}
+	return c.InsertData(ctx, tableName, columns, rows)
+}
+
+// GetTableRowCount 对 ReplacingMergeTree 表使用 FINAL，只统计去重后的行
+func (c *ClickHouseDB) GetTableRowCount(tableName string) (int, error) {
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteIdentifier(config.DatabaseTypeClickHouse, tableName))
+	if engine, err := c.tableEngine(tableName); err == nil && strings.Contains(engine, clickHouseEngineReplacingMergeTree) {
+		countSQL += " FINAL"
+	}
+	var count int
+	if err := c.db.QueryRow(countSQL).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get row count for table %s: %w", tableName, err)
+	}
+	return count, nil
+}
+
+// TableEngine 返回表引擎名称（如 ReplacingMergeTree），表不存在时返回空字符串。
+func (c *ClickHouseDB) TableEngine(tableName string) (string, error) {
+	var engine string
+	err := c.db.QueryRow("SELECT engine FROM system.tables WHERE database = currentDatabase() AND name = ?", tableName).Scan(&engine)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read engine of table %s: %w", tableName, err)
+	}
+	return engine, nil
+}
+
+func (c *ClickHouseDB) tableEngine(tableName string) (string, error) {
+	c.mu.Lock()
+	engine, ok := c.engines[tableName]
+	c.mu.Unlock()
+	if ok {
+		return engine, nil
+	}
+	engine, err := c.TableEngine(tableName)
+	if err != nil {
+		return "", err
+	}
+	if engine == "" {
+		return "", fmt.Errorf("table %s does not exist", tableName)
+	}
+	c.mu.Lock()
+	c.engines[tableName] = engine
+	c.mu.Unlock()
+	return engine, nil
+}
+
+func (c *ClickHouseDB) forgetEngine(tableNames ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, name := range tableNames {
+		delete(c.engines, name)
+	}
+}
+
+// CreateIndexes 创建数据跳过索引（minmax），并为已有数据物化索引
+func (c *ClickHouseDB) CreateIndexes(tableName string, indexes []config.IndexConfig) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+
+	qTable := QuoteIdentifier(config.DatabaseTypeClickHouse, tableName)
+	for _, idx := range indexes {
+		index := idx
+		createSQL, err := BuildCreateIndexSQL(config.DatabaseTypeClickHouse, tableName, index)
+		if err != nil {
+			return fmt.Errorf("failed to create index '%s' on table '%s': %w", index.Name, tableName, err)
+		}
+		qIndex := QuoteIdentifier(config.DatabaseTypeClickHouse, index.Name)
+		if _, err := c.db.Exec(fmt.Sprintf("ALTER TABLE %s DROP INDEX IF EXISTS %s", qTable, qIndex)); err != nil {
+			log.Printf("Warning: failed to drop existing index '%s': %v", index.Name, err)
+		}
+		log.Printf("Creating ClickHouse index: %s", createSQL)
+		if _, err := c.db.Exec(createSQL); err != nil {
+			return fmt.Errorf("failed to create index '%s' on table '%s': %w", index.Name, tableName, err)
+		}
+		if _, err := c.db.Exec(fmt.Sprintf("ALTER TABLE %s MATERIALIZE INDEX %s", qTable, qIndex)); err != nil {
+			return fmt.Errorf("failed to materialize index '%s' on table '%s': %w", index.Name, tableName, err)
+		}
+	}
+	return nil
+}
+
+// buildClickHousePlaceholder 返回 input() 中第 i 列的引用，transform 中的 ? 替换为该列。
+func buildClickHousePlaceholder(i int, transform string) string {
+	ref := fmt.Sprintf("c%d", i+1)
+	if transform != "" {
+		return strings.ReplaceAll(transform, "?", ref)
+	}
+	return ref
+}
+
+// MapToClickHouseType maps column metadata to a ClickHouse column type. Columns are
+// Nullable unless the source reports them as NOT NULL.
+func MapToClickHouseType(column ColumnMetadata) string {
+	typeDef := mapToClickHouseBaseType(column)
+	if column.NullableValid && !column.Nullable {
+		return typeDef
+	}
+	return "Nullable(" + typeDef + ")"
+}
+
+func mapToClickHouseBaseType(column ColumnMetadata) string {
+	typeName := strings.ToUpper(column.DatabaseType)
+	if typeName == "" {
+		typeName = strings.ToUpper(column.GoType)
+	}
+
+	precision := int64(0)
+	scale := int64(0)
+	if column.PrecisionScaleValid {
+		precision = column.Precision
+		scale = column.Scale
+	}
+
+	switch {
+	case strings.Contains(typeName, "INT"):
+		return "Int64"
+	case strings.Contains(typeName, "DOUBLE"), strings.Contains(typeName, "FLOAT"), strings.Contains(typeName, "REAL"):
+		return "Float64"
+	case strings.Contains(typeName, "DEC"), strings.Contains(typeName, "NUMERIC"), strings.Contains(typeName, "NUMBER"):
+		if precision > 0 {
+			if precision > 76 {
+				precision = 76
+			}
+			if scale < 0 {
+				scale = 0
+			}
+			if scale > precision {
+				scale = precision
+			}
+			return fmt.Sprintf("Decimal(%d, %d)", precision, scale)
+		}
+		return "Decimal(38, 0)"
+	case strings.Contains(typeName, "CHAR"), strings.Contains(typeName, "TEXT"), strings.Contains(typeName, "CLOB"), strings.Contains(typeName, "STRING"):
+		return "String"
+	case strings.Contains(typeName, "DATE"), strings.Contains(typeName, "TIME"):
+		return "DateTime64(6, 'UTC')"
+	case strings.Contains(typeName, "BLOB"), strings.Contains(typeName, "BINARY"), strings.Contains(typeName, "RAW"), strings.Contains(typeName, "BYTEA"):
+		return "String"
+	case strings.Contains(typeName, "BOOL"):
+		return "Bool"
+	case typeName == "UUID":
+		return "UUID"
+	default:
+		if column.PrecisionScaleValid && column.Scale > 0 {
+			return "Float64"
+		}
+		return "String"
+	}
+}
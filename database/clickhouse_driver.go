@@ -0,0 +1,578 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+)
+
+// ClickHouse 通过 HTTP 接口访问：查询文本以 POST 发送，结果以 JSONCompactEachRowWithNamesAndTypes 流式读取。
+// HTTP 接口没有 ? 位置参数（{name:Type} 参数要求声明类型），参数在客户端格式化为字面量后内联；
+// 批量写入不走这条路径，语句放在 query 参数中，行数据以 JSONCompactEachRow 格式作为请求体发送（见 insert）。
+
+const (
+	clickHouseResultFormat = "JSONCompactEachRowWithNamesAndTypes"
+	clickHouseInputFormat  = "JSONCompactEachRow"
+)
+
+// clickHouseConnector 实现 driver.Connector，所有连接共享同一个 http.Client。
+type clickHouseConnector struct {
+	endpoint string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newClickHouseConnector(dbCfg config.DatabaseConfig) (*clickHouseConnector, error) {
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if dbCfg.SSLMode != config.SSLModeDisable && dbCfg.SSLMode != "" {
+		tlsConfig, err := buildTLSConfig(dbCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build TLS config: %w", err)
+		}
+		transport.TLSClientConfig = tlsConfig
+		scheme = "https"
+	}
+
+	params := url.Values{}
+	params.Set("database", dbCfg.Database)
+	params.Set("default_format", clickHouseResultFormat)
+	// 64 位整数输出为 JSON 数字，Decimal 输出为字符串以保留精度，时间统一输出为 UTC 的 ISO 8601
+	params.Set("output_format_json_quote_64bit_integers", "0")
+	params.Set("output_format_json_quote_decimals", "1")
+	params.Set("date_time_output_format", "iso")
+	// ALTER TABLE ... UPDATE / DELETE 等 mutation 等待执行完成后才返回
+	params.Set("mutations_sync", "1")
+
+	return &clickHouseConnector{
+		endpoint: fmt.Sprintf("%s://%s/?%s", scheme, net.JoinHostPort(dbCfg.Host, dbCfg.Port), params.Encode()),
+		user:     dbCfg.User,
+		password: dbCfg.Password,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
+
+func (c *clickHouseConnector) Connect(context.Context) (driver.Conn, error) {
+	return &clickHouseConn{c: c}, nil
+}
+
+func (c *clickHouseConnector) Driver() driver.Driver {
+	return clickHouseDriver{}
+}
+
+type clickHouseDriver struct{}
+
+func (clickHouseDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("clickhouse: connections must be opened through a connector")
+}
+
+type clickHouseConn struct {
+	c *clickHouseConnector
+}
+
+var (
+	_ driver.QueryerContext    = (*clickHouseConn)(nil)
+	_ driver.ExecerContext     = (*clickHouseConn)(nil)
+	_ driver.Pinger            = (*clickHouseConn)(nil)
+	_ driver.NamedValueChecker = (*clickHouseConn)(nil)
+)
+
+func (cn *clickHouseConn) Prepare(query string) (driver.Stmt, error) {
+	return &clickHouseStmt{conn: cn, query: query}, nil
+}
+
+func (cn *clickHouseConn) Close() error {
+	return nil
+}
+
+func (cn *clickHouseConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("clickhouse: transactions are not supported")
+}
+
+func (cn *clickHouseConn) Ping(ctx context.Context) error {
+	_, err := cn.ExecContext(ctx, "SELECT 1", nil)
+	return err
+}
+
+// CheckNamedValue 接受默认转换器不支持的参数（如超过 int64 的 uint64），格式化字面量时再处理。
+func (cn *clickHouseConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value); err == nil {
+		nv.Value = v
+	}
+	return nil
+}
+
+func (cn *clickHouseConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	resp, err := cn.post(ctx, query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	return execResult(resp)
+}
+
+// insert 执行一条以 FORMAT 结尾的 INSERT 语句：语句放在 query 参数中，data 作为请求体，
+// ClickHouse 按输入格式解析行数据，值不会拼进 SQL 文本。
+func (cn *clickHouseConn) insert(ctx context.Context, query string, data []byte) (driver.Result, error) {
+	endpoint := cn.c.endpoint + "&wait_end_of_query=1&query=" + url.QueryEscape(query)
+	resp, err := cn.send(ctx, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return execResult(resp)
+}
+
+func execResult(resp *http.Response) (driver.Result, error) {
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return nil, fmt.Errorf("clickhouse: failed to read response: %w", err)
+	}
+	return clickHouseResult(parseClickHouseWrittenRows(resp.Header.Get("X-ClickHouse-Summary"))), nil
+}
+
+func (cn *clickHouseConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	resp, err := cn.post(ctx, query, args, false)
+	if err != nil {
+		return nil, err
+	}
+	rows := &clickHouseRows{body: resp.Body, dec: json.NewDecoder(resp.Body)}
+	var names, types []string
+	if err := rows.dec.Decode(&names); err != nil {
+		if errors.Is(err, io.EOF) {
+			// 不返回结果集的语句（DDL 等）
+			return rows, nil
+		}
+		return nil, rows.readError(err)
+	}
+	if err := rows.dec.Decode(&types); err != nil {
+		return nil, rows.readError(err)
+	}
+	if len(names) != len(types) {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("clickhouse: result header has %d names but %d types", len(names), len(types))
+	}
+	rows.names = names
+	rows.columns = make([]clickHouseColumn, len(types))
+	for i, t := range types {
+		rows.columns[i] = parseClickHouseType(t)
+	}
+	return rows, nil
+}
+
+// post 发送一条语句；执行类语句带 wait_end_of_query，执行中的错误以 HTTP 状态码返回而不是混在响应体里。
+func (cn *clickHouseConn) post(ctx context.Context, query string, args []driver.NamedValue, exec bool) (*http.Response, error) {
+	text, err := interpolateClickHouseQuery(query, args)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := cn.c.endpoint
+	if exec {
+		endpoint += "&wait_end_of_query=1"
+	}
+	return cn.send(ctx, endpoint, strings.NewReader(text))
+}
+
+func (cn *clickHouseConn) send(ctx context.Context, endpoint string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: failed to build request: %w", err)
+	}
+	if cn.c.user != "" {
+		req.Header.Set("X-ClickHouse-User", cn.c.user)
+	}
+	if cn.c.password != "" {
+		req.Header.Set("X-ClickHouse-Key", cn.c.password)
+	}
+	resp, err := cn.c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse: request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, fmt.Errorf("clickhouse: %s", strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+type clickHouseStmt struct {
+	conn  *clickHouseConn
+	query string
+}
+
+func (s *clickHouseStmt) Close() error  { return nil }
+func (s *clickHouseStmt) NumInput() int { return -1 }
+
+func (s *clickHouseStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *clickHouseStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *clickHouseStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.ExecContext(ctx, s.query, args)
+}
+
+func (s *clickHouseStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.QueryContext(ctx, s.query, args)
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+type clickHouseResult int64
+
+func (r clickHouseResult) LastInsertId() (int64, error) {
+	return 0, errors.New("clickhouse: LastInsertId is not supported")
+}
+
+func (r clickHouseResult) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// parseClickHouseWrittenRows 从 X-ClickHouse-Summary 响应头读取写入的行数。
+func parseClickHouseWrittenRows(summary string) int64 {
+	var s struct {
+		WrittenRows string `json:"written_rows"`
+	}
+	if summary == "" || json.Unmarshal([]byte(summary), &s) != nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s.WrittenRows, 10, 64)
+	return n
+}
+
+// interpolateClickHouseQuery 把 query 中字符串与带引号标识符之外的 ? 依次替换为 args 的字面量。
+func interpolateClickHouseQuery(query string, args []driver.NamedValue) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
+	var b strings.Builder
+	b.Grow(len(query) + 16*len(args))
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case quote != 0:
+			b.WriteByte(ch)
+			if ch == '\\' && i+1 < len(query) {
+				i++
+				b.WriteByte(query[i])
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+			b.WriteByte(ch)
+		case ch == '?':
+			if n >= len(args) {
+				return "", fmt.Errorf("clickhouse: query has more placeholders than the %d arguments given", len(args))
+			}
+			b.WriteString(SQLLiteral(config.DatabaseTypeClickHouse, ColumnMetadata{}, args[n].Value))
+			n++
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("clickhouse: query has %d placeholders but %d arguments were given", n, len(args))
+	}
+	return b.String(), nil
+}
+
+// appendClickHouseJSON 把一个值编码为 JSONCompactEachRow 中的 JSON 值；asString 为 true 时数字与布尔值也写成字符串。
+func appendClickHouseJSON(b []byte, value any, asString bool) []byte {
+	var text string
+	quoted := true
+	switch v := value.(type) {
+	case nil:
+		return append(b, "null"...)
+	case bool:
+		text, quoted = strconv.FormatBool(v), false
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		text, quoted = fmt.Sprintf("%d", v), false
+	case float32:
+		text, quoted = formatClickHouseFloat(float64(v), 32)
+	case float64:
+		text, quoted = formatClickHouseFloat(v, 64)
+	case time.Time:
+		// DateTime64(6) 列保存微秒，db-ferry 建表时统一使用 UTC
+		text = v.UTC().Format("2006-01-02 15:04:05.999999")
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		text = fmt.Sprint(v)
+	}
+	if !quoted && !asString {
+		return append(b, text...)
+	}
+	return appendClickHouseJSONString(b, text)
+}
+
+// formatClickHouseFloat 返回浮点数的文本；JSON 没有 NaN 与无穷大，写成 ClickHouse 能解析的带引号文本。
+func formatClickHouseFloat(v float64, bitSize int) (string, bool) {
+	switch {
+	case math.IsNaN(v):
+		return "nan", true
+	case math.IsInf(v, 1):
+		return "inf", true
+	case math.IsInf(v, -1):
+		return "-inf", true
+	}
+	return strconv.FormatFloat(v, 'g', -1, bitSize), false
+}
+
+// appendClickHouseJSONString 只转义引号、反斜杠与控制字符，其余字节原样写出：
+// ClickHouse 不校验 UTF-8，二进制数据按字节写入 String 列，而 encoding/json 会把非法 UTF-8 替换掉。
+func appendClickHouseJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '"' || ch == '\\':
+			b = append(b, '\\', ch)
+		case ch == '\n':
+			b = append(b, '\\', 'n')
+		case ch == '\r':
+			b = append(b, '\\', 'r')
+		case ch == '\t':
+			b = append(b, '\\', 't')
+		case ch < 0x20 || ch == 0x7f:
+			b = append(b, '\\', 'u', '0', '0', hex[ch>>4], hex[ch&0xf])
+		default:
+			b = append(b, ch)
+		}
+	}
+	return append(b, '"')
+}
+
+// clickHouseColumn 是去掉 Nullable / LowCardinality 包装后的列类型。
+type clickHouseColumn struct {
+	typeName     string // 大写、不带参数，如 DECIMAL、DATETIME64、FIXEDSTRING
+	nullable     bool
+	length       int64
+	precision    int64
+	scale        int64
+	decimalValid bool
+}
+
+func parseClickHouseType(t string) clickHouseColumn {
+	var col clickHouseColumn
+	t = strings.TrimSpace(t)
+	if inner, ok := unwrapClickHouseType(t, "LowCardinality"); ok {
+		t = inner
+	}
+	if inner, ok := unwrapClickHouseType(t, "Nullable"); ok {
+		t, col.nullable = inner, true
+	}
+
+	name, params := t, ""
+	if open := strings.IndexByte(t, '('); open > 0 && strings.HasSuffix(t, ")") {
+		name, params = t[:open], t[open+1:len(t)-1]
+	}
+	col.typeName = strings.ToUpper(name)
+
+	var args []int64
+	for _, p := range strings.Split(params, ",") {
+		if v, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64); err == nil {
+			args = append(args, v)
+		}
+	}
+	switch col.typeName {
+	case "FIXEDSTRING":
+		if len(args) == 1 {
+			col.length = args[0]
+		}
+	case "DECIMAL":
+		if len(args) == 2 {
+			col.precision, col.scale, col.decimalValid = args[0], args[1], true
+		}
+	case "DECIMAL32", "DECIMAL64", "DECIMAL128", "DECIMAL256":
+		precision := map[string]int64{"DECIMAL32": 9, "DECIMAL64": 18, "DECIMAL128": 38, "DECIMAL256": 76}[col.typeName]
+		if len(args) == 1 {
+			col.precision, col.scale, col.decimalValid = precision, args[0], true
+		}
+		col.typeName = "DECIMAL"
+	}
+	return col
+}
+
+func unwrapClickHouseType(t, wrapper string) (string, bool) {
+	if strings.HasPrefix(t, wrapper+"(") && strings.HasSuffix(t, ")") {
+		return t[len(wrapper)+1 : len(t)-1], true
+	}
+	return t, false
+}
+
+func (c clickHouseColumn) isInteger() bool {
+	return strings.HasPrefix(c.typeName, "INT") || strings.HasPrefix(c.typeName, "UINT")
+}
+
+func (c clickHouseColumn) isTime() bool {
+	return strings.HasPrefix(c.typeName, "DATE")
+}
+
+func (c clickHouseColumn) scanType() reflect.Type {
+	switch {
+	case c.isInteger():
+		return reflect.TypeOf(int64(0))
+	case strings.HasPrefix(c.typeName, "FLOAT"):
+		return reflect.TypeOf(float64(0))
+	case c.typeName == "BOOL":
+		return reflect.TypeOf(false)
+	case c.isTime():
+		return reflect.TypeOf(time.Time{})
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+// value 把一个 JSON 值转换为 driver.Value：整数为 int64（超出范围时为字符串），Decimal 为字符串，
+// 日期时间为 UTC 的 time.Time，数组、Map 与 Tuple 保留为 JSON 文本。
+func (c clickHouseColumn) value(raw json.RawMessage) (driver.Value, error) {
+	text := string(raw)
+	switch {
+	case text == "null":
+		return nil, nil
+	case text == "true" || text == "false":
+		return text == "true", nil
+	case strings.HasPrefix(text, `"`):
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		if c.isTime() {
+			return parseClickHouseTime(s)
+		}
+		return s, nil
+	case strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{"):
+		return text, nil
+	case c.isInteger():
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(text, 10, 64); err == nil && n > math.MaxInt64 {
+			return text, nil
+		}
+		return nil, fmt.Errorf("invalid %s value %s", c.typeName, text)
+	default:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value %s", c.typeName, text)
+		}
+		return f, nil
+	}
+}
+
+func parseClickHouseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02", "2006-01-02 15:04:05.999999999"} {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date/time value %q", s)
+}
+
+type clickHouseRows struct {
+	body    io.ReadCloser
+	dec     *json.Decoder
+	names   []string
+	columns []clickHouseColumn
+}
+
+var (
+	_ driver.RowsColumnTypeDatabaseTypeName = (*clickHouseRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*clickHouseRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*clickHouseRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*clickHouseRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*clickHouseRows)(nil)
+)
+
+func (r *clickHouseRows) Columns() []string {
+	return r.names
+}
+
+func (r *clickHouseRows) Close() error {
+	return r.body.Close()
+}
+
+func (r *clickHouseRows) Next(dest []driver.Value) error {
+	if r.names == nil {
+		return io.EOF
+	}
+	var row []json.RawMessage
+	if err := r.dec.Decode(&row); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return r.readError(err)
+	}
+	if len(row) != len(dest) {
+		return fmt.Errorf("clickhouse: row has %d values, expected %d", len(row), len(dest))
+	}
+	for i, raw := range row {
+		v, err := r.columns[i].value(raw)
+		if err != nil {
+			return fmt.Errorf("clickhouse: column %s: %w", r.names[i], err)
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+// readError 在结果无法解析时返回服务端写在响应体中的异常文本（查询在输出开始后失败时 HTTP 状态码仍为 200）。
+func (r *clickHouseRows) readError(err error) error {
+	defer r.body.Close()
+	rest, _ := io.ReadAll(io.LimitReader(io.MultiReader(r.dec.Buffered(), r.body), 64<<10))
+	if msg := strings.TrimSpace(string(rest)); msg != "" {
+		return fmt.Errorf("clickhouse: %s", msg)
+	}
+	return fmt.Errorf("clickhouse: failed to read result: %w", err)
+}
+
+func (r *clickHouseRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.columns[index].typeName
+}
+
+func (r *clickHouseRows) ColumnTypeScanType(index int) reflect.Type {
+	return r.columns[index].scanType()
+}
+
+func (r *clickHouseRows) ColumnTypeNullable(index int) (bool, bool) {
+	return r.columns[index].nullable, true
+}
+
+func (r *clickHouseRows) ColumnTypeLength(index int) (int64, bool) {
+	col := r.columns[index]
+	return col.length, col.length > 0
+}
+
+func (r *clickHouseRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	col := r.columns[index]
+	return col.precision, col.scale, col.decimalValid
+}
@@ -0,0 +1,303 @@
+package database
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+)
+
+// fakeClickHouse 模拟 ClickHouse HTTP 接口：记录收到的语句，按语句前缀返回预设的响应体。
+type fakeClickHouse struct {
+	mu        sync.Mutex
+	queries   []string
+	responses map[string]string
+	user      string
+}
+
+func newFakeClickHouse(t *testing.T, responses map[string]string) (*fakeClickHouse, config.DatabaseConfig) {
+	t.Helper()
+	fake := &fakeClickHouse{responses: responses}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serve))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("parse server url: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(u.Host)
+	return fake, config.DatabaseConfig{Name: "ch", Type: config.DatabaseTypeClickHouse, Host: host, Port: port, User: "default", Database: "default"}
+}
+
+func (f *fakeClickHouse) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	query := string(body)
+	if q := r.URL.Query().Get("query"); q != "" {
+		// INSERT ... FORMAT 的语句在 query 参数中，请求体是行数据
+		query = q + "\n" + query
+	}
+	f.mu.Lock()
+	f.queries = append(f.queries, query)
+	f.user = r.Header.Get("X-ClickHouse-User")
+	f.mu.Unlock()
+
+	if strings.HasPrefix(query, "BOOM") {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, "Code: 62. DB::Exception: Syntax error")
+		return
+	}
+	if strings.HasPrefix(query, "INSERT") {
+		w.Header().Set("X-ClickHouse-Summary", `{"read_rows":"0","written_rows":"2"}`)
+		return
+	}
+	for prefix, resp := range f.responses {
+		if strings.HasPrefix(query, prefix) {
+			_, _ = io.WriteString(w, resp)
+			return
+		}
+	}
+}
+
+func (f *fakeClickHouse) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+func TestClickHouseQueryDecodesTypedRows(t *testing.T) {
+	fake, cfg := newFakeClickHouse(t, map[string]string{
+		"SELECT id": `["id","name","amount","created_at","active","tags"]
+["Int64","LowCardinality(Nullable(String))","Decimal(10, 2)","DateTime64(6, 'UTC')","Bool","Array(String)"]
+[1,"a","12.30","2024-01-02T03:04:05.123456Z",true,["x","y"]]
+[18446744073709551615,null,"0.00","2024-01-02T00:00:00Z",false,[]]
+`,
+	})
+	ch, err := NewClickHouseDB(cfg)
+	if err != nil {
+		t.Fatalf("NewClickHouseDB() error = %v", err)
+	}
+	defer ch.Close()
+
+	rows, err := ch.Query(context.Background(), "SELECT id, name, amount, created_at, active, tags FROM t")
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatalf("ColumnTypes() error = %v", err)
+	}
+	if got := types[2].DatabaseTypeName(); got != "DECIMAL" {
+		t.Fatalf("amount type = %s, want DECIMAL", got)
+	}
+	if p, s, ok := types[2].DecimalSize(); !ok || p != 10 || s != 2 {
+		t.Fatalf("amount decimal size = (%d, %d, %v)", p, s, ok)
+	}
+	if nullable, ok := types[1].Nullable(); !ok || !nullable {
+		t.Fatalf("name nullable = (%v, %v), want (true, true)", nullable, ok)
+	}
+
+	var got [][]any
+	for rows.Next() {
+		vals := make([]any, 6)
+		ptrs := make([]any, 6)
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		got = append(got, vals)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("rows.Err() = %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d rows, want 2", len(got))
+	}
+	want := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	if got[0][0] != int64(1) || got[0][1] != "a" || got[0][2] != "12.30" || !got[0][3].(time.Time).Equal(want) || got[0][4] != true || got[0][5] != `["x","y"]` {
+		t.Fatalf("row 0 = %#v", got[0])
+	}
+	if got[1][0] != "18446744073709551615" || got[1][1] != nil {
+		t.Fatalf("row 1 = %#v", got[1])
+	}
+	if fake.user != "default" {
+		t.Fatalf("X-ClickHouse-User = %q", fake.user)
+	}
+}
+
+func TestClickHouseInsertAndServerError(t *testing.T) {
+	fake, cfg := newFakeClickHouse(t, nil)
+	ch, err := NewClickHouseDB(cfg)
+	if err != nil {
+		t.Fatalf("NewClickHouseDB() error = %v", err)
+	}
+	defer ch.Close()
+
+	cols := []ColumnMetadata{{Name: "id", DatabaseType: "INT"}, {Name: "name", DatabaseType: "VARCHAR"}}
+	if err := ch.InsertData(context.Background(), "users", cols, [][]any{{1, "it's"}, {2, nil}, {3, `'); DROP TABLE users; --`}}); err != nil {
+		t.Fatalf("InsertData() error = %v", err)
+	}
+	queries := fake.recorded()
+	wantInsert := "INSERT INTO \"users\" (\"id\", \"name\") FORMAT JSONCompactEachRow\n" +
+		"[1,\"it's\"]\n[2,null]\n[3,\"'); DROP TABLE users; --\"]\n"
+	if last := queries[len(queries)-1]; last != wantInsert {
+		t.Fatalf("insert = %q, want %q", last, wantInsert)
+	}
+
+	err = ch.Exec(context.Background(), "BOOM")
+	if err == nil || !strings.Contains(err.Error(), "Syntax error") {
+		t.Fatalf("Exec() error = %v, want server exception", err)
+	}
+}
+
+func TestClickHouseUpsertStrategies(t *testing.T) {
+	cols := []ColumnMetadata{{Name: "id", DatabaseType: "INT"}, {Name: "name", DatabaseType: "VARCHAR"}}
+	values := [][]any{{1, "a"}, {2, "b"}, {1, "c"}}
+
+	t.Run("replacing requires ReplacingMergeTree", func(t *testing.T) {
+		_, cfg := newFakeClickHouse(t, map[string]string{
+			"SELECT engine": "[\"engine\"]\n[\"String\"]\n[\"MergeTree\"]\n",
+		})
+		ch, err := NewClickHouseDB(cfg)
+		if err != nil {
+			t.Fatalf("NewClickHouseDB() error = %v", err)
+		}
+		defer ch.Close()
+		err = ch.UpsertData(context.Background(), "users", cols, values, []string{"id"})
+		if err == nil || !strings.Contains(err.Error(), "ReplacingMergeTree") {
+			t.Fatalf("UpsertData() error = %v, want engine error", err)
+		}
+	})
+
+	t.Run("delete_insert keeps last row per key", func(t *testing.T) {
+		fake, cfg := newFakeClickHouse(t, nil)
+		cfg.UpsertStrategy = config.ClickHouseUpsertDeleteInsert
+		ch, err := NewClickHouseDB(cfg)
+		if err != nil {
+			t.Fatalf("NewClickHouseDB() error = %v", err)
+		}
+		defer ch.Close()
+		if err := ch.UpsertData(context.Background(), "users", cols, values, []string{"id"}); err != nil {
+			t.Fatalf("UpsertData() error = %v", err)
+		}
+		queries := fake.recorded()
+		if !strings.HasPrefix(queries[len(queries)-2], `DELETE FROM "users" WHERE`) {
+			t.Fatalf("delete = %q", queries[len(queries)-2])
+		}
+		wantInsert := "INSERT INTO \"users\" (\"id\", \"name\") FORMAT JSONCompactEachRow\n[2,\"b\"]\n[1,\"c\"]\n"
+		if last := queries[len(queries)-1]; last != wantInsert {
+			t.Fatalf("insert = %q, want %q", last, wantInsert)
+		}
+	})
+}
+
+func TestClickHouseInsertTransformReadsInput(t *testing.T) {
+	fake, cfg := newFakeClickHouse(t, nil)
+	ch, err := NewClickHouseDB(cfg)
+	if err != nil {
+		t.Fatalf("NewClickHouseDB() error = %v", err)
+	}
+	defer ch.Close()
+
+	cols := []ColumnMetadata{
+		{Name: "id", DatabaseType: "INT"},
+		{Name: "day", DatabaseType: "VARCHAR", Transform: "toDate(?)"},
+		{Name: "code", DatabaseType: "VARCHAR", Transform: "upper(?)"},
+	}
+	values := [][]any{{int64(1), "2024-01-02", 7}, {int64(2), nil, "b"}}
+	if err := ch.InsertData(context.Background(), "events", cols, values); err != nil {
+		t.Fatalf("InsertData() error = %v", err)
+	}
+	queries := fake.recorded()
+	wantInsert := `INSERT INTO "events" ("id", "day", "code") SELECT c1, toDate(c2), upper(c3) ` +
+		`FROM input('c1 Nullable(Int64), c2 Nullable(String), c3 Nullable(String)') FORMAT JSONCompactEachRow` + "\n" +
+		`[1,"2024-01-02","7"]` + "\n" + `[2,null,"b"]` + "\n"
+	if last := queries[len(queries)-1]; last != wantInsert {
+		t.Fatalf("insert = %q, want %q", last, wantInsert)
+	}
+}
+
+func TestAppendClickHouseJSON(t *testing.T) {
+	tests := []struct {
+		value    any
+		asString bool
+		want     string
+	}{
+		{nil, false, `null`},
+		{true, false, `true`},
+		{true, true, `"true"`},
+		{uint64(18446744073709551615), false, `18446744073709551615`},
+		{int8(-3), true, `"-3"`},
+		{1.5, false, `1.5`},
+		{math.NaN(), false, `"nan"`},
+		{math.Inf(-1), false, `"-inf"`},
+		{time.Date(2024, 1, 2, 11, 4, 5, 123456789, time.FixedZone("UTC+8", 8*3600)), false, `"2024-01-02 03:04:05.123456"`},
+		{"a\"b\\c\nd\x01", false, `"a\"b\\c\nd\u0001"`},
+		{[]byte{0xff, 0x00, 'x'}, false, "\"\xff\\u0000x\""},
+	}
+	for _, tt := range tests {
+		if got := string(appendClickHouseJSON(nil, tt.value, tt.asString)); got != tt.want {
+			t.Errorf("appendClickHouseJSON(%#v, %v) = %q, want %q", tt.value, tt.asString, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolateClickHouseQuery(t *testing.T) {
+	args := []driver.NamedValue{{Ordinal: 1, Value: int64(7)}, {Ordinal: 2, Value: `a\b`}}
+	got, err := interpolateClickHouseQuery(`SELECT '?', "x?" FROM t WHERE id = ? AND name = ?`, args)
+	if err != nil {
+		t.Fatalf("interpolateClickHouseQuery() error = %v", err)
+	}
+	want := `SELECT '?', "x?" FROM t WHERE id = 7 AND name = 'a\\b'`
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	if _, err := interpolateClickHouseQuery("SELECT ?", append(args, args...)); err == nil {
+		t.Fatalf("expected placeholder count error")
+	}
+}
+
+func TestMapToClickHouseType(t *testing.T) {
+	tests := []struct {
+		col  ColumnMetadata
+		want string
+	}{
+		{ColumnMetadata{DatabaseType: "INT", NullableValid: true}, "Int64"},
+		{ColumnMetadata{DatabaseType: "VARCHAR", NullableValid: true, Nullable: true}, "Nullable(String)"},
+		{ColumnMetadata{DatabaseType: "DECIMAL", PrecisionScaleValid: true, Precision: 12, Scale: 3, NullableValid: true}, "Decimal(12, 3)"},
+		{ColumnMetadata{DatabaseType: "TIMESTAMP", NullableValid: true}, "DateTime64(6, 'UTC')"},
+		{ColumnMetadata{DatabaseType: "BOOLEAN", NullableValid: true}, "Bool"},
+		{ColumnMetadata{DatabaseType: "INT"}, "Nullable(Int64)"},
+	}
+	for _, tt := range tests {
+		if got := MapToClickHouseType(tt.col); got != tt.want {
+			t.Errorf("MapToClickHouseType(%+v) = %s, want %s", tt.col, got, tt.want)
+		}
+	}
+}
+
+func TestBuildClickHouseCreateTableSQLKeys(t *testing.T) {
+	cols := []ColumnMetadata{
+		{Name: "id", DatabaseType: "INT", NullableValid: true, Nullable: true},
+		{Name: "name", DatabaseType: "VARCHAR", NullableValid: true, Nullable: true},
+	}
+	got := buildClickHouseCreateTableSQL("users", cols, true, clickHouseEngineReplacingMergeTree, []string{"id"})
+	want := `CREATE TABLE IF NOT EXISTS "users" ("id" Int64, "name" Nullable(String)) ENGINE = ReplacingMergeTree ORDER BY ("id")`
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
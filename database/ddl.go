@@ -17,7 +17,7 @@ func QuoteIdentifier(dbType, name string) string {
 		return `"` + strings.ReplaceAll(upper, `"`, `""`) + `"`
 	case config.DatabaseTypeSQLServer:
 		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
-	case config.DatabaseTypeSQLite, config.DatabaseTypePostgreSQL, config.DatabaseTypeDuckDB:
+	case config.DatabaseTypeSQLite, config.DatabaseTypePostgreSQL, config.DatabaseTypeDuckDB, config.DatabaseTypeClickHouse:
 		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
 	default:
 		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
@@ -39,6 +39,8 @@ func MapType(dbType string, column ColumnMetadata) string {
 		return MapToSQLServerType(column)
 	case config.DatabaseTypeDuckDB:
 		return MapToDuckDBType(column)
+	case config.DatabaseTypeClickHouse:
+		return MapToClickHouseType(column)
 	default:
 		return "TEXT"
 	}
@@ -86,6 +88,8 @@ func BuildCreateTableSQL(dbType, tableName string, columns []ColumnMetadata, dro
 			literal := strings.ReplaceAll(qTable, "'", "''")
 			createSQL = fmt.Sprintf("IF OBJECT_ID(N'%s', 'U') IS NULL %s", literal, createSQL)
 		}
+	case config.DatabaseTypeClickHouse:
+		createSQL = buildClickHouseCreateTableSQL(tableName, columns, !dropExisting, clickHouseEngineMergeTree, nil)
 	default:
 		createStmt := "CREATE TABLE"
 		if !dropExisting {
@@ -121,6 +125,16 @@ func BuildCreateIndexSQL(dbType, tableName string, index config.IndexConfig) (st
 
 	var sql string
 	switch strings.ToLower(dbType) {
+	case config.DatabaseTypeClickHouse:
+		// ClickHouse only has data-skipping indexes; they cannot enforce uniqueness or take a sort order.
+		if index.Unique {
+			return "", fmt.Errorf("unique index '%s' is not supported by clickhouse", index.Name)
+		}
+		names := make([]string, len(index.ParsedColumns))
+		for i, col := range index.ParsedColumns {
+			names[i] = QuoteIdentifier(dbType, col.Name)
+		}
+		sql = fmt.Sprintf("ALTER TABLE %s ADD INDEX IF NOT EXISTS %s (%s) TYPE minmax GRANULARITY 1", qTable, qIndex, strings.Join(names, ", "))
 	case config.DatabaseTypeSQLite:
 		sql = fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)", uniqueStr, qIndex, qTable, strings.Join(columns, ", "))
 		if index.Where != "" {
@@ -134,19 +148,40 @@ func BuildCreateIndexSQL(dbType, tableName string, index config.IndexConfig) (st
 }
 
 // GeneratePlanDDL generates a list of DDL statements for a task in dry-run mode.
+// For replace mode the statements mirror prepareTargetTable and SwapTable: the
+// staging table and its indexes are built first, then swapped into place.
 func GeneratePlanDDL(dbType, tableName string, columns []ColumnMetadata, mode string, skipCreate bool, indexes []config.IndexConfig) ([]string, error) {
 	var stmts []string
+	pending := indexes
 
 	if !skipCreate {
 		switch mode {
 		case config.TaskModeAppend, config.TaskModeMerge:
 			stmts = BuildCreateTableSQL(dbType, tableName, columns, false)
 		default:
-			stmts = BuildCreateTableSQL(dbType, tableName, columns, true)
+			staging := StagingTableName(tableName)
+			stmts = BuildCreateTableSQL(dbType, staging, columns, true)
+			if len(stmts) > 0 {
+				if IndexesSurviveSwap(dbType) {
+					for _, idx := range StagingIndexes(dbType, indexes) {
+						sql, err := BuildCreateIndexSQL(dbType, staging, idx)
+						if err != nil {
+							return nil, err
+						}
+						stmts = append(stmts, sql)
+					}
+					pending = nil
+				}
+				swap, err := BuildSwapTableSQL(dbType, staging, tableName, indexes)
+				if err != nil {
+					return nil, err
+				}
+				stmts = append(stmts, swap...)
+			}
 		}
 	}
 
-	for _, idx := range indexes {
+	for _, idx := range pending {
 		sql, err := BuildCreateIndexSQL(dbType, tableName, idx)
 		if err != nil {
 			return nil, err
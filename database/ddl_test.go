@@ -131,8 +131,32 @@ func TestGeneratePlanDDL(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GeneratePlanDDL() error = %v", err)
 	}
-	if len(ddl) != 3 {
-		t.Fatalf("expected 3 DDL statements for replace mode, got %d", len(ddl))
+	if len(ddl) != 7 {
+		t.Fatalf("expected 7 DDL statements for replace mode, got %d: %v", len(ddl), ddl)
+	}
+	if !strings.Contains(ddl[1], `CREATE TABLE "users__dbf_staging"`) {
+		t.Fatalf("expected replace mode to create staging table, got %s", ddl[1])
+	}
+	if !strings.Contains(ddl[2], `INDEX IF NOT EXISTS "idx_id__dbf_staging" ON "users__dbf_staging"`) {
+		t.Fatalf("expected index on staging table before the swap, got %s", ddl[2])
+	}
+	if ddl[4] != `ALTER TABLE "users__dbf_staging" RENAME TO "users"` {
+		t.Fatalf("expected staging table swap, got %s", ddl[4])
+	}
+	if ddl[5] != `DROP INDEX IF EXISTS "idx_id__dbf_staging"` || !strings.Contains(ddl[6], `"idx_id" ON "users"`) {
+		t.Fatalf("expected staging index renamed in the swap, got %v", ddl[5:])
+	}
+
+	ddl, err = GeneratePlanDDL(config.DatabaseTypeMySQL, "users", cols, config.TaskModeReplace, false, indexes)
+	if err != nil {
+		t.Fatalf("GeneratePlanDDL() error = %v", err)
+	}
+	swap, _ := BuildSwapTableSQL(config.DatabaseTypeMySQL, "users__dbf_staging", "users", indexes)
+	if got := ddl[len(ddl)-len(swap):]; strings.Join(got, ";") != strings.Join(swap, ";") {
+		t.Fatalf("expected plan to end with the swap statements, got %v", got)
+	}
+	if swap[1] != "CREATE TABLE IF NOT EXISTS `users` LIKE `users__dbf_staging`" {
+		t.Fatalf("expected MySQL swap to handle a missing live table, got %s", swap[1])
 	}
 
 	ddl, err = GeneratePlanDDL(config.DatabaseTypeSQLite, "users", cols, config.TaskModeAppend, false, nil)
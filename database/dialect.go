@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"db-ferry/config"
+)
+
+// DetectDialect 根据 version() 的返回值识别 MySQL / PostgreSQL 兼容引擎，原生 MySQL 与 PostgreSQL 返回空字符串。
+func DetectDialect(ctx context.Context, source SourceDB, dbType string) (string, error) {
+	switch strings.ToLower(dbType) {
+	case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL:
+	default:
+		return "", nil
+	}
+
+	rows, err := source.Query(ctx, "SELECT version()")
+	if err != nil {
+		return "", fmt.Errorf("failed to read server version: %w", err)
+	}
+	defer rows.Close()
+
+	var version string
+	if rows.Next() {
+		if err := rows.Scan(&version); err != nil {
+			return "", fmt.Errorf("failed to scan server version: %w", err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return dialectFromVersion(version), nil
+}
+
+func dialectFromVersion(version string) string {
+	v := strings.ToLower(version)
+	switch {
+	case strings.Contains(v, "mariadb"):
+		return config.DialectMariaDB
+	case strings.Contains(v, "tidb"):
+		return config.DialectTiDB
+	case strings.Contains(v, "cockroachdb"):
+		return config.DialectCockroachDB
+	default:
+		return ""
+	}
+}
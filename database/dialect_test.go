@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"db-ferry/config"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDetectDialect(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{"8.0.36", ""},
+		{"10.11.6-MariaDB-1:10.11.6+maria~ubu2204", config.DialectMariaDB},
+		{"8.0.11-TiDB-v7.5.0", config.DialectTiDB},
+		{"PostgreSQL 16.2 on x86_64-pc-linux-gnu", ""},
+		{"CockroachDB CCL v23.2.1 (x86_64-pc-linux-gnu)", config.DialectCockroachDB},
+	}
+	for _, tc := range cases {
+		db, mock := newSQLMock(t)
+		mock.ExpectQuery("SELECT version()").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(tc.version))
+		got, err := DetectDialect(context.Background(), &MySQLDB{db: db}, config.DatabaseTypeMySQL)
+		if err != nil {
+			t.Fatalf("DetectDialect(%q) error = %v", tc.version, err)
+		}
+		if got != tc.want {
+			t.Fatalf("DetectDialect(%q) = %q, want %q", tc.version, got, tc.want)
+		}
+	}
+
+	got, err := DetectDialect(context.Background(), nil, config.DatabaseTypeSQLite)
+	if err != nil || got != "" {
+		t.Fatalf("DetectDialect(sqlite) = %q, %v", got, err)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+)
+
+// BuildDeleteByKeysSQL returns a DELETE statement removing the rows identified by keys,
+// together with the bind arguments for its placeholders. Each entry in keys holds one
+// value per key column, in the same order as keyColumns.
+func BuildDeleteByKeysSQL(dbType, tableName string, keyColumns []string, keys [][]any) (string, []any) {
+	predicate, args := buildKeysPredicate(dbType, keyColumns, keys)
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", QuoteIdentifier(dbType, tableName), predicate), args
+}
+
+// BuildFlagByKeysSQL returns an UPDATE statement setting flagColumn to flag (1 marks
+// a row deleted, 0 clears the mark) for the rows identified by keys.
+func BuildFlagByKeysSQL(dbType, tableName, flagColumn string, flag int, keyColumns []string, keys [][]any) (string, []any) {
+	predicate, args := buildKeysPredicate(dbType, keyColumns, keys)
+	if dbType == config.DatabaseTypeClickHouse {
+		// ClickHouse updates rows through a mutation.
+		return fmt.Sprintf("ALTER TABLE %s UPDATE %s = %d WHERE %s",
+			QuoteIdentifier(dbType, tableName),
+			QuoteIdentifier(dbType, flagColumn),
+			flag,
+			predicate), args
+	}
+	return fmt.Sprintf("UPDATE %s SET %s = %d WHERE %s",
+		QuoteIdentifier(dbType, tableName),
+		QuoteIdentifier(dbType, flagColumn),
+		flag,
+		predicate), args
+}
+
+// BindPlaceholder returns the positional placeholder for the n-th (1-based) bind argument.
+func BindPlaceholder(dbType string, n int) string {
+	switch dbType {
+	case config.DatabaseTypePostgreSQL:
+		return fmt.Sprintf("$%d", n)
+	case config.DatabaseTypeSQLServer:
+		return fmt.Sprintf("@p%d", n)
+	case config.DatabaseTypeOracle:
+		return fmt.Sprintf(":%d", n)
+	default:
+		return "?"
+	}
+}
+
+// Key values are bound rather than inlined so that timestamps keep their full
+// precision and zone, booleans keep their type, and no dialect-specific
+// escaping is needed.
+func buildKeysPredicate(dbType string, keyColumns []string, keys [][]any) (string, []any) {
+	quoted := make([]string, len(keyColumns))
+	for i, col := range keyColumns {
+		quoted[i] = QuoteIdentifier(dbType, col)
+	}
+
+	var args []any
+	groups := make([]string, len(keys))
+	for i, key := range keys {
+		conds := make([]string, len(quoted))
+		for j, col := range quoted {
+			if key[j] == nil {
+				conds[j] = col + " IS NULL"
+				continue
+			}
+			args = append(args, key[j])
+			conds[j] = col + " = " + BindPlaceholder(dbType, len(args))
+		}
+		groups[i] = "(" + strings.Join(conds, " AND ") + ")"
+	}
+	return strings.Join(groups, " OR "), args
+}
+
+// SQLLiteral renders value as a literal of the given dialect, for SQL scripts that
+// are reviewed or run outside db-ferry and therefore cannot use bind arguments.
+func SQLLiteral(dbType string, column ColumnMetadata, value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if dbType == config.DatabaseTypePostgreSQL || dbType == config.DatabaseTypeDuckDB || dbType == config.DatabaseTypeClickHouse {
+			return strings.ToUpper(strconv.FormatBool(v))
+		}
+		if v {
+			return "1"
+		}
+		return "0"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		if dbType == config.DatabaseTypeClickHouse {
+			// DateTime64(6) columns hold microseconds; db-ferry creates them in UTC.
+			return quoteDialectString(dbType, v.UTC().Format("2006-01-02 15:04:05.999999"))
+		}
+		text := v.Format("2006-01-02 15:04:05.999999999")
+		if dbType == config.DatabaseTypeOracle {
+			return "TIMESTAMP " + quoteDialectString(dbType, text)
+		}
+		return quoteDialectString(dbType, text)
+	case []byte:
+		if isBinaryColumn(column) {
+			return binaryLiteral(dbType, v)
+		}
+		return quoteDialectString(dbType, string(v))
+	case string:
+		return quoteDialectString(dbType, v)
+	default:
+		return quoteDialectString(dbType, fmt.Sprint(v))
+	}
+}
+
+func quoteDialectString(dbType, value string) string {
+	escaped := strings.ReplaceAll(value, "'", "''")
+	switch dbType {
+	case config.DatabaseTypeMySQL, config.DatabaseTypeClickHouse:
+		// MySQL treats backslash as an escape character unless NO_BACKSLASH_ESCAPES is set;
+		// ClickHouse always does.
+		return "'" + strings.ReplaceAll(escaped, `\`, `\\`) + "'"
+	case config.DatabaseTypeSQLServer:
+		return "N'" + escaped + "'"
+	default:
+		return "'" + escaped + "'"
+	}
+}
+
+func binaryLiteral(dbType string, value []byte) string {
+	encoded := hex.EncodeToString(value)
+	switch dbType {
+	case config.DatabaseTypePostgreSQL:
+		return "'\\x" + encoded + "'::bytea"
+	case config.DatabaseTypeSQLServer:
+		return "0x" + encoded
+	case config.DatabaseTypeOracle:
+		return "HEXTORAW('" + encoded + "')"
+	case config.DatabaseTypeDuckDB:
+		return "from_hex('" + encoded + "')"
+	case config.DatabaseTypeClickHouse:
+		return "unhex('" + encoded + "')"
+	default:
+		return "X'" + encoded + "'"
+	}
+}
+
+func isBinaryColumn(column ColumnMetadata) bool {
+	typeName := strings.ToUpper(column.DatabaseType)
+	for _, marker := range []string{"BLOB", "BINARY", "BYTEA", "RAW", "IMAGE"} {
+		if strings.Contains(typeName, marker) {
+			return true
+		}
+	}
+	return false
+}
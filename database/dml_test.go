@@ -0,0 +1,84 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+)
+
+func TestBuildDeleteByKeysSQL(t *testing.T) {
+	keys := [][]any{
+		{int64(1), `o'hara\`},
+		{int64(2), nil},
+	}
+	got, args := BuildDeleteByKeysSQL(config.DatabaseTypeMySQL, "users", []string{"id", "name"}, keys)
+	want := "DELETE FROM `users` WHERE (`id` = ? AND `name` = ?) OR (`id` = ? AND `name` IS NULL)"
+	if got != want {
+		t.Fatalf("BuildDeleteByKeysSQL() = %q, want %q", got, want)
+	}
+	if wantArgs := []any{int64(1), `o'hara\`, int64(2)}; !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("BuildDeleteByKeysSQL() args = %#v, want %#v", args, wantArgs)
+	}
+}
+
+func TestBuildFlagByKeysSQL(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 123456000, time.FixedZone("UTC+8", 8*3600))
+	got, args := BuildFlagByKeysSQL(config.DatabaseTypeSQLServer, "events", "is_deleted", 1, []string{"created_at", "active"}, [][]any{{ts, true}})
+	want := "UPDATE [events] SET [is_deleted] = 1 WHERE ([created_at] = @p1 AND [active] = @p2)"
+	if got != want {
+		t.Fatalf("BuildFlagByKeysSQL() = %q, want %q", got, want)
+	}
+	if len(args) != 2 || !args[0].(time.Time).Equal(ts) || args[1] != true {
+		t.Fatalf("BuildFlagByKeysSQL() args = %#v", args)
+	}
+}
+
+func TestBindPlaceholder(t *testing.T) {
+	cases := map[string]string{
+		config.DatabaseTypeMySQL:      "?",
+		config.DatabaseTypeSQLite:     "?",
+		config.DatabaseTypePostgreSQL: "$3",
+		config.DatabaseTypeSQLServer:  "@p3",
+		config.DatabaseTypeOracle:     ":3",
+	}
+	for dbType, want := range cases {
+		if got := BindPlaceholder(dbType, 3); got != want {
+			t.Errorf("BindPlaceholder(%s) = %q, want %q", dbType, got, want)
+		}
+	}
+}
+
+func TestSQLLiteral(t *testing.T) {
+	ts := time.Date(2024, 3, 5, 10, 30, 0, 0, time.UTC)
+	blob := ColumnMetadata{Name: "data", DatabaseType: "BLOB"}
+	text := ColumnMetadata{Name: "name", DatabaseType: "TEXT"}
+	cases := []struct {
+		dbType string
+		column ColumnMetadata
+		value  any
+		want   string
+	}{
+		{config.DatabaseTypeMySQL, text, nil, "NULL"},
+		{config.DatabaseTypeMySQL, text, `it's a \ test`, `'it''s a \\ test'`},
+		{config.DatabaseTypePostgreSQL, text, `a\b`, `'a\b'`},
+		{config.DatabaseTypeSQLServer, text, "名", "N'名'"},
+		{config.DatabaseTypePostgreSQL, text, true, "TRUE"},
+		{config.DatabaseTypeSQLite, text, true, "1"},
+		{config.DatabaseTypeSQLite, text, int64(42), "42"},
+		{config.DatabaseTypeSQLite, text, 1.5, "1.5"},
+		{config.DatabaseTypeOracle, text, ts, "TIMESTAMP '2024-03-05 10:30:00'"},
+		{config.DatabaseTypePostgreSQL, text, ts, "'2024-03-05 10:30:00'"},
+		{config.DatabaseTypeMySQL, text, []byte("123.40"), "'123.40'"},
+		{config.DatabaseTypeMySQL, blob, []byte{0xde, 0xad}, "X'dead'"},
+		{config.DatabaseTypePostgreSQL, blob, []byte{0xde, 0xad}, `'\xdead'::bytea`},
+		{config.DatabaseTypeSQLServer, blob, []byte{0xde, 0xad}, "0xdead"},
+		{config.DatabaseTypeOracle, blob, []byte{0xde, 0xad}, "HEXTORAW('dead')"},
+	}
+	for _, tc := range cases {
+		if got := SQLLiteral(tc.dbType, tc.column, tc.value); got != tc.want {
+			t.Errorf("SQLLiteral(%s, %v) = %s, want %s", tc.dbType, tc.value, got, tc.want)
+		}
+	}
+}
@@ -3,14 +3,16 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
 	"log"
 	"strings"
 
 	"db-ferry/config"
 
-	_ "github.com/duckdb/duckdb-go/v2"
+	"github.com/duckdb/duckdb-go/v2"
 )
 
 type DuckDB struct {
@@ -56,24 +58,37 @@ func (d *DuckDB) Close() error {
 	return nil
 }
 
-func (d *DuckDB) Exec(sql string) error {
-	_, err := d.db.Exec(sql)
+func (d *DuckDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := d.db.ExecContext(ctx, sql, args...)
 	return err
 }
 
-func (d *DuckDB) Query(sql string) (*sql.Rows, error) {
+func (d *DuckDB) beginLoadTx(ctx context.Context) (*LoadTx, error) {
+	return beginLoadTx(ctx, d.db, config.DatabaseTypeDuckDB)
+}
+
+// SwapTable 在单个事务内删除旧表并将暂存表重命名为目标表
+func (d *DuckDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeDuckDB, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	return execInTx(d.db, stmts)
+}
+
+func (d *DuckDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	log.Printf("Executing DuckDB query: %s", sql)
-	rows, err := d.db.Query(sql)
+	rows, err := d.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute duckdb query: %w", err)
 	}
 	return rows, nil
 }
 
-func (d *DuckDB) GetRowCount(sql string) (int, error) {
+func (d *DuckDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sql)
-	if err := d.db.QueryRow(countSQL).Scan(&count); err != nil {
+	if err := d.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to get row count: %w", err)
 	}
 	return count, nil
@@ -164,16 +179,16 @@ func (d *DuckDB) createTable(tableName string, columns []ColumnMetadata, dropExi
 	return nil
 }
 
-func (d *DuckDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (d *DuckDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	if len(values) == 0 {
 		return nil
 	}
 
-	tx, err := d.db.Begin()
+	tx, err := beginBatch(ctx, d.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
 	placeholders := make([]string, len(columns))
 	columnNames := make([]string, len(columns))
@@ -187,26 +202,120 @@ func (d *DuckDB) InsertData(tableName string, columns []ColumnMetadata, values [
 		strings.Join(columnNames, ", "),
 		strings.Join(placeholders, ", "))
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to insert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (d *DuckDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+// BulkInsertData 使用 DuckDB appender 写入一个批次。appender 按表的列顺序追加整行，
+// 批次列与目标表列顺序不一致、带 transform 或处于装载事务内时回退到 INSERT。
+func (d *DuckDB) BulkInsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if hasColumnTransform(columns) || LoadTxFromContext(ctx) != nil {
+		return ErrBulkLoadUnsupported
+	}
+
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	tableColumns, err := duckDBColumnOrder(ctx, conn, tableName)
+	if err != nil {
+		return err
+	}
+	if len(tableColumns) != len(columns) {
+		return ErrBulkLoadUnsupported
+	}
+	for i, col := range columns {
+		if !strings.EqualFold(tableColumns[i], col.Name) {
+			return ErrBulkLoadUnsupported
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "BEGIN TRANSACTION"); err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(context.WithoutCancel(ctx), "ROLLBACK")
+		}
+	}()
+
+	err = conn.Raw(func(driverConn any) error {
+		dc, ok := driverConn.(driver.Conn)
+		if !ok {
+			return fmt.Errorf("unexpected duckdb connection type %T", driverConn)
+		}
+		appender, err := duckdb.NewAppender(dc, "", "", tableName)
+		if err != nil {
+			return err
+		}
+		args := make([]driver.Value, len(columns))
+		for _, row := range values {
+			for i, v := range row {
+				args[i] = v
+			}
+			if err := appender.AppendRow(args...); err != nil {
+				_ = appender.Close()
+				return err
+			}
+		}
+		return appender.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to append rows: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+func duckDBColumnOrder(ctx context.Context, conn *sql.Conn, tableName string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = 'main' AND table_name = ?
+		ORDER BY ordinal_position
+	`, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table columns: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (d *DuckDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	if len(values) == 0 {
 		return nil
 	}
@@ -249,25 +358,25 @@ func (d *DuckDB) UpsertData(tableName string, columns []ColumnMetadata, values [
 		action,
 	)
 
-	tx, err := d.db.Begin()
+	tx, err := beginBatch(ctx, d.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare upsert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to upsert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
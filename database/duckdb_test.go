@@ -3,7 +3,9 @@
 package database
 
 import (
+	"context"
 	"errors"
+	"path/filepath"
 	"regexp"
 	"testing"
 
@@ -51,7 +53,7 @@ func TestDuckDBInsertUpsertAndCount(t *testing.T) {
 	insertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	insertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := d.InsertData("users", cols, values); err != nil {
+	if err := d.InsertData(context.Background(), "users", cols, values); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
 
@@ -61,7 +63,7 @@ func TestDuckDBInsertUpsertAndCount(t *testing.T) {
 	upsertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	upsertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := d.UpsertData("users", cols, values, []string{"id"}); err != nil {
+	if err := d.UpsertData(context.Background(), "users", cols, values, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() error = %v", err)
 	}
 
@@ -74,7 +76,7 @@ func TestDuckDBInsertUpsertAndCount(t *testing.T) {
 	transformPrep := mock.ExpectPrepare(regexp.QuoteMeta(insertTransformSQL))
 	transformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := d.InsertData("users", transformCols, [][]any{{1, "a"}}); err != nil {
+	if err := d.InsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}); err != nil {
 		t.Fatalf("InsertData() with transform error = %v", err)
 	}
 
@@ -83,13 +85,13 @@ func TestDuckDBInsertUpsertAndCount(t *testing.T) {
 	upsertTransformPrep := mock.ExpectPrepare(regexp.QuoteMeta(upsertTransformSQL))
 	upsertTransformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := d.UpsertData("users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
+	if err := d.UpsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() with transform error = %v", err)
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM (SELECT * FROM users) AS count_query`)).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-	cnt, err := d.GetRowCount("SELECT * FROM users")
+	cnt, err := d.GetRowCount(context.Background(), "SELECT * FROM users")
 	if err != nil {
 		t.Fatalf("GetRowCount() error = %v", err)
 	}
@@ -109,7 +111,7 @@ func TestDuckDBInsertUpsertAndCount(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1")).
 		WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
-	rows, err := d.Query("SELECT 1")
+	rows, err := d.Query(context.Background(), "SELECT 1")
 	if err != nil {
 		t.Fatalf("Query() error = %v", err)
 	}
@@ -120,6 +122,38 @@ func TestDuckDBInsertUpsertAndCount(t *testing.T) {
 	}
 }
 
+func TestDuckDBBulkInsertData(t *testing.T) {
+	d, err := NewDuckDB(filepath.Join(t.TempDir(), "bulk.duckdb"), 0, 0, "")
+	if err != nil {
+		t.Fatalf("NewDuckDB() error = %v", err)
+	}
+	defer d.Close()
+
+	cols := []ColumnMetadata{
+		{Name: "id", DatabaseType: "BIGINT"},
+		{Name: "name", DatabaseType: "VARCHAR"},
+	}
+	if err := d.Exec(context.Background(), `CREATE TABLE "users" ("id" BIGINT, "name" VARCHAR)`); err != nil {
+		t.Fatalf("create table error = %v", err)
+	}
+	if err := d.BulkInsertData(context.Background(), "users", cols, [][]any{{int64(1), "a"}, {int64(2), nil}}); err != nil {
+		t.Fatalf("BulkInsertData() error = %v", err)
+	}
+	cnt, err := d.GetTableRowCount("users")
+	if err != nil {
+		t.Fatalf("GetTableRowCount() error = %v", err)
+	}
+	if cnt != 2 {
+		t.Fatalf("GetTableRowCount() = %d, want 2", cnt)
+	}
+
+	// 列顺序与目标表不一致时 appender 无法使用
+	reordered := []ColumnMetadata{cols[1], cols[0]}
+	if err := d.BulkInsertData(context.Background(), "users", reordered, [][]any{{"c", int64(3)}}); !errors.Is(err, ErrBulkLoadUnsupported) {
+		t.Fatalf("expected ErrBulkLoadUnsupported for reordered columns, got %v", err)
+	}
+}
+
 func TestDuckDBCreateIndexesAndHelpers(t *testing.T) {
 	db, mock := newSQLMock(t)
 	d := &DuckDB{db: db}
@@ -150,7 +184,7 @@ func TestDuckDBExec(t *testing.T) {
 	d := &DuckDB{db: db}
 
 	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE t (id INT)`)).WillReturnResult(sqlmock.NewResult(0, 0))
-	if err := d.Exec(`CREATE TABLE t (id INT)`); err != nil {
+	if err := d.Exec(context.Background(), `CREATE TABLE t (id INT)`); err != nil {
 		t.Fatalf("Exec() error = %v", err)
 	}
 
@@ -190,10 +224,10 @@ func TestDuckDBEdgeCasesAndTypeMapping(t *testing.T) {
 	if err := d.CreateTable("users", nil); err == nil {
 		t.Fatalf("expected CreateTable() error for empty columns")
 	}
-	if err := d.InsertData("users", nil, nil); err != nil {
+	if err := d.InsertData(context.Background(), "users", nil, nil); err != nil {
 		t.Fatalf("InsertData() empty should be nil, got %v", err)
 	}
-	if err := d.UpsertData("users", nil, [][]any{{1}}, nil); err == nil {
+	if err := d.UpsertData(context.Background(), "users", nil, [][]any{{1}}, nil); err == nil {
 		t.Fatalf("expected merge_keys required error")
 	}
 
@@ -216,3 +250,20 @@ func TestDuckDBEdgeCasesAndTypeMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestDuckDBSwapTable(t *testing.T) {
+	db, mock := newSQLMock(t)
+	d := &DuckDB{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "users__dbf_staging" RENAME TO "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	if err := d.SwapTable("users__dbf_staging", "users", []config.IndexConfig{{Name: "idx_id", Columns: []string{"id"}}}); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+}
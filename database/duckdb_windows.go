@@ -3,6 +3,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
@@ -26,11 +27,11 @@ func (d *DuckDB) Close() error {
 	return fmt.Errorf("duckdb is not supported on windows builds")
 }
 
-func (d *DuckDB) Query(sql string) (*sql.Rows, error) {
+func (d *DuckDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	return nil, fmt.Errorf("duckdb is not supported on windows builds")
 }
 
-func (d *DuckDB) GetRowCount(sql string) (int, error) {
+func (d *DuckDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	return 0, fmt.Errorf("duckdb is not supported on windows builds")
 }
 
@@ -46,11 +47,11 @@ func (d *DuckDB) EnsureTable(tableName string, columns []ColumnMetadata) error {
 	return fmt.Errorf("duckdb is not supported on windows builds")
 }
 
-func (d *DuckDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (d *DuckDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	return fmt.Errorf("duckdb is not supported on windows builds")
 }
 
-func (d *DuckDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+func (d *DuckDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	return fmt.Errorf("duckdb is not supported on windows builds")
 }
 
@@ -66,6 +67,10 @@ func (d *DuckDB) CreateIndexes(tableName string, indexes []config.IndexConfig) e
 	return fmt.Errorf("duckdb is not supported on windows builds")
 }
 
-func (d *DuckDB) Exec(sql string) error {
+func (d *DuckDB) Exec(ctx context.Context, sql string, args ...any) error {
+	return fmt.Errorf("duckdb is not supported on windows builds")
+}
+
+func (d *DuckDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
 	return fmt.Errorf("duckdb is not supported on windows builds")
 }
@@ -0,0 +1,121 @@
+//go:build !windows
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"db-ferry/config"
+)
+
+// FileSource 以内存中的 DuckDB 读取本地 CSV / JSONL / Parquet 文件。
+// 文件（或 glob 匹配的一组文件）以数据库别名注册为视图，任务 sql 中以该名称引用，
+// 列类型由 DuckDB 根据文件内容推断。文件在第一次查询时才打开，同一别名作为文件目标时不会读取输出目录。
+type FileSource struct {
+	cfg  config.DatabaseConfig
+	once sync.Once
+	db   *sql.DB
+	err  error
+}
+
+var _ SourceDB = (*FileSource)(nil)
+
+// NewFileSource 返回读取 dbCfg.Path 的文件源，视图名为 dbCfg.Name。
+func NewFileSource(dbCfg config.DatabaseConfig) *FileSource {
+	return &FileSource{cfg: dbCfg}
+}
+
+func (f *FileSource) conn() (*sql.DB, error) {
+	f.once.Do(func() {
+		f.db, f.err = openFileSource(f.cfg)
+	})
+	return f.db, f.err
+}
+
+func openFileSource(dbCfg config.DatabaseConfig) (*sql.DB, error) {
+	reader, err := fileReaderSQL(dbCfg.Type, dbCfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(dbCfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern %q: %w", dbCfg.Path, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no %s files match %q", dbCfg.Type, dbCfg.Path)
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open duckdb for %s source: %w", dbCfg.Type, err)
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE VIEW %s AS SELECT * FROM %s", QuoteIdentifier(config.DatabaseTypeDuckDB, dbCfg.Name), reader)); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to read %s files %q: %w", dbCfg.Type, dbCfg.Path, err)
+	}
+
+	log.Printf("Opened %s source %s over %d file(s) matching %s", dbCfg.Type, dbCfg.Name, len(matches), dbCfg.Path)
+	return db, nil
+}
+
+// fileReaderSQL 返回读取 path 的 DuckDB 表函数调用；多个文件按列名合并。
+func fileReaderSQL(dbType, path string) (string, error) {
+	literal := "'" + strings.ReplaceAll(path, "'", "''") + "'"
+	switch dbType {
+	case config.DatabaseTypeCSV:
+		return fmt.Sprintf("read_csv_auto(%s, union_by_name = true)", literal), nil
+	case config.DatabaseTypeJSONL:
+		return fmt.Sprintf("read_json_auto(%s, format = 'newline_delimited', union_by_name = true)", literal), nil
+	case config.DatabaseTypeParquet:
+		return fmt.Sprintf("read_parquet(%s, union_by_name = true)", literal), nil
+	default:
+		return "", fmt.Errorf("unsupported file source type '%s'", dbType)
+	}
+}
+
+func (f *FileSource) Close() error {
+	if f.db != nil {
+		return f.db.Close()
+	}
+	return nil
+}
+
+func (f *FileSource) Query(ctx context.Context, sql string) (*sql.Rows, error) {
+	db, err := f.conn()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Executing file source query: %s", sql)
+	rows, err := db.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute file source query: %w", err)
+	}
+	return rows, nil
+}
+
+func (f *FileSource) GetRowCount(ctx context.Context, sql string) (int, error) {
+	db, err := f.conn()
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sql)
+	if err := db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get row count: %w", err)
+	}
+	return count, nil
+}
+
+// GetTables 返回以数据库别名注册的视图。
+func (f *FileSource) GetTables() ([]string, error) {
+	if _, err := f.conn(); err != nil {
+		return nil, err
+	}
+	return []string{f.cfg.Name}, nil
+}
@@ -0,0 +1,110 @@
+//go:build !windows
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"db-ferry/config"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestFileSourceReadsGlobWithFilter(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "part-1.csv"), "id,name,amount\n1,alice,10.5\n2,bob,3\n")
+	writeTestFile(t, filepath.Join(dir, "part-2.csv"), "id,name,amount\n3,carol,42\n")
+
+	src := NewFileSource(config.DatabaseConfig{Name: "vendor_drop", Type: config.DatabaseTypeCSV, Path: filepath.Join(dir, "*.csv")})
+	defer src.Close()
+	ctx := context.Background()
+
+	tables, err := src.GetTables()
+	if err != nil || len(tables) != 1 || tables[0] != "vendor_drop" {
+		t.Fatalf("GetTables() = %v, %v", tables, err)
+	}
+
+	cols, err := GetTableSchema(ctx, src, "vendor_drop")
+	if err != nil {
+		t.Fatalf("GetTableSchema() error = %v", err)
+	}
+	var types []string
+	for _, col := range cols {
+		types = append(types, col.Name+" "+col.DatabaseType)
+	}
+	if got := strings.Join(types, ", "); got != "id BIGINT, name VARCHAR, amount DOUBLE" {
+		t.Fatalf("inferred columns = %s", got)
+	}
+
+	query := "SELECT id, name FROM vendor_drop WHERE amount > 5 ORDER BY id"
+	count, err := src.GetRowCount(ctx, query)
+	if err != nil || count != 2 {
+		t.Fatalf("GetRowCount() = %d, %v", count, err)
+	}
+	rows, err := src.Query(ctx, query)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		names = append(names, name)
+	}
+	if strings.Join(names, ",") != "alice,carol" {
+		t.Fatalf("unexpected rows %v", names)
+	}
+}
+
+func TestFileSourceJSONLAndParquet(t *testing.T) {
+	dir := t.TempDir()
+	jsonlPath := filepath.Join(dir, "events.jsonl")
+	writeTestFile(t, jsonlPath, "{\"id\": 1, \"kind\": \"click\"}\n{\"id\": 2, \"kind\": \"view\"}\n")
+
+	parquetPath := filepath.Join(dir, "events.parquet")
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("open duckdb: %v", err)
+	}
+	if _, err := db.Exec("COPY (SELECT range AS id, 'k' || range AS kind FROM range(5)) TO '" + parquetPath + "' (FORMAT PARQUET)"); err != nil {
+		t.Fatalf("write parquet: %v", err)
+	}
+	_ = db.Close()
+
+	for _, tc := range []struct {
+		typ, path string
+		want      int
+	}{
+		{config.DatabaseTypeJSONL, jsonlPath, 2},
+		{config.DatabaseTypeParquet, parquetPath, 5},
+	} {
+		src := NewFileSource(config.DatabaseConfig{Name: "events", Type: tc.typ, Path: tc.path})
+		count, err := src.GetRowCount(context.Background(), "SELECT id, kind FROM events")
+		_ = src.Close()
+		if err != nil || count != tc.want {
+			t.Fatalf("%s: GetRowCount() = %d, %v; want %d", tc.typ, count, err, tc.want)
+		}
+	}
+}
+
+func TestFileSourceNoMatchingFiles(t *testing.T) {
+	src := NewFileSource(config.DatabaseConfig{Name: "drop", Type: config.DatabaseTypeCSV, Path: filepath.Join(t.TempDir(), "*.csv")})
+	_, err := src.GetTables()
+	if err == nil || !strings.Contains(err.Error(), "no csv files match") {
+		t.Fatalf("expected no-match error, got %v", err)
+	}
+}
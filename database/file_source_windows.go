@@ -0,0 +1,35 @@
+//go:build windows
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"db-ferry/config"
+)
+
+type FileSource struct{}
+
+var _ SourceDB = (*FileSource)(nil)
+
+func NewFileSource(dbCfg config.DatabaseConfig) *FileSource {
+	return &FileSource{}
+}
+
+func (f *FileSource) Close() error {
+	return nil
+}
+
+func (f *FileSource) Query(ctx context.Context, sql string) (*sql.Rows, error) {
+	return nil, fmt.Errorf("file sources are not supported on windows builds")
+}
+
+func (f *FileSource) GetRowCount(ctx context.Context, sql string) (int, error) {
+	return 0, fmt.Errorf("file sources are not supported on windows builds")
+}
+
+func (f *FileSource) GetTables() ([]string, error) {
+	return nil, fmt.Errorf("file sources are not supported on windows builds")
+}
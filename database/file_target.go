@@ -0,0 +1,506 @@
+//go:build !windows
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"db-ferry/config"
+)
+
+// FileSchemaName 是每个表目录下记录列定义的 schema 文件名。
+const FileSchemaName = "_schema.json"
+
+const fileBufferSuffix = "__dbf_buffer"
+
+var filePartPattern = regexp.MustCompile(`^part-(\d+)`)
+
+// FileTarget 把每张表写成 path 下的一个目录：CreateTable / EnsureTable 写出 schema 文件，
+// 写入的行先缓存在内存 DuckDB 中，满 max_rows_per_file 行或读取表之前落盘为
+// part-NNNNN 文件（配置 partition_by 时按 Hive 风格分区目录写出）。
+// Query 与 GetTableRowCount 读取已写出的文件，因此校验与写入后断言检查的是实际输出。
+type FileTarget struct {
+	cfg    config.DatabaseConfig
+	mu     sync.Mutex
+	db     *sql.DB
+	buf    *DuckDB
+	tables map[string]*fileTable
+}
+
+// fileTable 记录一张表的 schema 与缓存状态。
+type fileTable struct {
+	columns  []ColumnMetadata
+	buffered int
+	nextPart int
+}
+
+// fileSchema 是 schema 文件的内容。
+type fileSchema struct {
+	Table       string             `json:"table"`
+	Format      string             `json:"format"`
+	Compression string             `json:"compression,omitempty"`
+	PartitionBy []string           `json:"partition_by,omitempty"`
+	Columns     []fileSchemaColumn `json:"columns"`
+}
+
+type fileSchemaColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	SourceType string `json:"source_type,omitempty"`
+	Nullable   *bool  `json:"nullable,omitempty"`
+}
+
+var (
+	_ TargetDB     = (*FileTarget)(nil)
+	_ TableDropper = (*FileTarget)(nil)
+)
+
+// NewFileTarget 返回写入 dbCfg.Path 目录的文件目标；目录在创建第一张表时才建立。
+func NewFileTarget(dbCfg config.DatabaseConfig) *FileTarget {
+	return &FileTarget{cfg: dbCfg, tables: make(map[string]*fileTable)}
+}
+
+func (f *FileTarget) conn() (*sql.DB, error) {
+	if f.db != nil {
+		return f.db, nil
+	}
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open duckdb for %s target: %w", f.cfg.Type, err)
+	}
+	f.db, f.buf = db, &DuckDB{db: db}
+	return db, nil
+}
+
+func (f *FileTarget) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.db == nil {
+		return nil
+	}
+	var errs []error
+	for name := range f.tables {
+		if err := f.flush(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := f.db.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (f *FileTarget) tableDir(tableName string) string {
+	return filepath.Join(f.cfg.Path, tableName)
+}
+
+func (f *FileTarget) CreateTable(tableName string, columns []ColumnMetadata) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.dropLocked(tableName); err != nil {
+		return err
+	}
+	return f.createLocked(tableName, columns)
+}
+
+func (f *FileTarget) EnsureTable(tableName string, columns []ColumnMetadata) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.table(tableName); err == nil {
+		return nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return f.createLocked(tableName, columns)
+}
+
+// createLocked 建立表目录、写出 schema 文件并创建缓存表。
+func (f *FileTarget) createLocked(tableName string, columns []ColumnMetadata) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns provided for table creation")
+	}
+	if err := checkPartitionColumns(f.cfg.PartitionBy, columns); err != nil {
+		return fmt.Errorf("table %s: %w", tableName, err)
+	}
+	dir := f.tableDir(tableName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory for table %s: %w", tableName, err)
+	}
+
+	schema := fileSchema{
+		Table:       tableName,
+		Format:      f.cfg.Type,
+		Compression: f.cfg.Compression,
+		PartitionBy: f.cfg.PartitionBy,
+		Columns:     make([]fileSchemaColumn, len(columns)),
+	}
+	for i, col := range columns {
+		sc := fileSchemaColumn{Name: col.Name, Type: MapToDuckDBType(col), SourceType: col.DatabaseType}
+		if col.NullableValid {
+			nullable := col.Nullable
+			sc.Nullable = &nullable
+		}
+		schema.Columns[i] = sc
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode schema for table %s: %w", tableName, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileSchemaName), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write schema for table %s: %w", tableName, err)
+	}
+
+	_, err = f.register(tableName, columns)
+	return err
+}
+
+// table 返回已登记的表；未登记时从表目录下的 schema 文件载入（append 模式或 skip_create_table 时沿用上次的输出）。
+// schema 文件不存在时返回的错误包装 os.ErrNotExist。
+func (f *FileTarget) table(tableName string) (*fileTable, error) {
+	if t, ok := f.tables[tableName]; ok {
+		return t, nil
+	}
+	data, err := os.ReadFile(filepath.Join(f.tableDir(tableName), FileSchemaName))
+	if err != nil {
+		return nil, fmt.Errorf("table %s has no schema file: %w", tableName, err)
+	}
+	var schema fileSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("failed to parse schema for table %s: %w", tableName, err)
+	}
+	columns := make([]ColumnMetadata, len(schema.Columns))
+	for i, sc := range schema.Columns {
+		columns[i] = ColumnMetadata{Name: sc.Name, DatabaseType: sc.Type}
+	}
+	return f.register(tableName, columns)
+}
+
+// register 为表创建缓存表，并以表名创建读取已写出文件的视图。
+func (f *FileTarget) register(tableName string, columns []ColumnMetadata) (*fileTable, error) {
+	if _, err := f.conn(); err != nil {
+		return nil, err
+	}
+	if err := f.buf.CreateTable(tableName+fileBufferSuffix, columns); err != nil {
+		return nil, err
+	}
+	next, err := f.nextPartNumber(tableName)
+	if err != nil {
+		return nil, err
+	}
+	t := &fileTable{columns: columns, nextPart: next}
+	f.tables[tableName] = t
+	if err := f.refreshView(tableName); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// nextPartNumber 返回表目录下已有数据文件之后的下一个编号，append 时新文件不会覆盖旧文件。
+func (f *FileTarget) nextPartNumber(tableName string) (int, error) {
+	files, err := f.dataFiles(tableName)
+	if err != nil {
+		return 0, err
+	}
+	next := 1
+	for _, file := range files {
+		m := filePartPattern.FindStringSubmatch(filepath.Base(file))
+		if m == nil {
+			continue
+		}
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= next {
+			next = n + 1
+		}
+	}
+	return next, nil
+}
+
+// dataFiles 返回表目录（含分区子目录）下的全部数据文件。
+func (f *FileTarget) dataFiles(tableName string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(f.tableDir(tableName), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), "part-") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files for table %s: %w", tableName, err)
+	}
+	return files, nil
+}
+
+// refreshView 让表名视图指向已写出的文件；还没有文件时指向（已清空的）缓存表。
+func (f *FileTarget) refreshView(tableName string) error {
+	files, err := f.dataFiles(tableName)
+	if err != nil {
+		return err
+	}
+	from := QuoteIdentifier(config.DatabaseTypeDuckDB, tableName+fileBufferSuffix)
+	if len(files) > 0 {
+		from, err = fileReaderSQL(f.cfg.Type, filepath.Join(f.tableDir(tableName), "**", "part-*"))
+		if err != nil {
+			return err
+		}
+		if len(f.cfg.PartitionBy) > 0 {
+			from = strings.TrimSuffix(from, ")") + ", hive_partitioning = true)"
+		}
+	}
+	viewSQL := fmt.Sprintf("CREATE OR REPLACE VIEW %s AS SELECT * FROM %s", QuoteIdentifier(config.DatabaseTypeDuckDB, tableName), from)
+	if _, err := f.db.Exec(viewSQL); err != nil {
+		return fmt.Errorf("failed to read back files for table %s: %w", tableName, err)
+	}
+	return nil
+}
+
+func (f *FileTarget) GetTableColumns(tableName string) ([]ColumnMetadata, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(tableName)
+	if err != nil {
+		return nil, err
+	}
+	return t.columns, nil
+}
+
+func (f *FileTarget) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.table(tableName)
+	if err != nil {
+		return err
+	}
+	if err := f.buf.InsertData(ctx, tableName+fileBufferSuffix, columns, values); err != nil {
+		return err
+	}
+	t.buffered += len(values)
+	if f.cfg.MaxRowsPerFile > 0 && t.buffered >= f.cfg.MaxRowsPerFile {
+		return f.flush(tableName)
+	}
+	return nil
+}
+
+func (f *FileTarget) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+	return fmt.Errorf("%s target does not support merge/upsert writes", f.cfg.Type)
+}
+
+// flush 把缓存的行写成新的数据文件并清空缓存；每个文件最多 max_rows_per_file 行。
+func (f *FileTarget) flush(tableName string) error {
+	t, ok := f.tables[tableName]
+	if !ok || t.buffered == 0 {
+		return nil
+	}
+	buffer := QuoteIdentifier(config.DatabaseTypeDuckDB, tableName+fileBufferSuffix)
+	chunk := f.cfg.MaxRowsPerFile
+	if chunk <= 0 {
+		chunk = t.buffered
+	}
+	for offset := 0; offset < t.buffered; offset += chunk {
+		query := fmt.Sprintf("SELECT * FROM %s ORDER BY rowid LIMIT %d OFFSET %d", buffer, chunk, offset)
+		copySQL := fmt.Sprintf("COPY (%s) TO '%s' (%s)", query, f.partTarget(tableName, t.nextPart), f.copyOptions(t.nextPart))
+		if _, err := f.db.Exec(copySQL); err != nil {
+			return fmt.Errorf("failed to write %s file for table %s: %w", f.cfg.Type, tableName, err)
+		}
+		t.nextPart++
+	}
+	log.Printf("Wrote %d rows of table %s to %s", t.buffered, tableName, f.tableDir(tableName))
+	if _, err := f.db.Exec("DELETE FROM " + buffer); err != nil {
+		return fmt.Errorf("failed to clear buffer for table %s: %w", tableName, err)
+	}
+	t.buffered = 0
+	return f.refreshView(tableName)
+}
+
+// partTarget 返回 COPY 的输出位置：未分区时为单个文件，分区时为表目录。
+func (f *FileTarget) partTarget(tableName string, part int) string {
+	target := f.tableDir(tableName)
+	if len(f.cfg.PartitionBy) == 0 {
+		target = filepath.Join(target, fmt.Sprintf("part-%05d%s", part, fileExtension(f.cfg.Type, f.cfg.Compression)))
+	}
+	return strings.ReplaceAll(target, "'", "''")
+}
+
+func (f *FileTarget) copyOptions(part int) string {
+	opts := []string{copyFormat(f.cfg.Type)}
+	switch {
+	case f.cfg.Compression == config.FileCompressionNone && f.cfg.Type == config.DatabaseTypeParquet:
+		opts = append(opts, "COMPRESSION uncompressed")
+	case f.cfg.Compression != "":
+		opts = append(opts, "COMPRESSION "+f.cfg.Compression)
+	}
+	if len(f.cfg.PartitionBy) > 0 {
+		cols := make([]string, len(f.cfg.PartitionBy))
+		for i, col := range f.cfg.PartitionBy {
+			cols[i] = QuoteIdentifier(config.DatabaseTypeDuckDB, col)
+		}
+		opts = append(opts,
+			fmt.Sprintf("PARTITION_BY (%s)", strings.Join(cols, ", ")),
+			fmt.Sprintf("FILENAME_PATTERN 'part-%05d-{i}'", part),
+			"OVERWRITE_OR_IGNORE true")
+	}
+	return strings.Join(opts, ", ")
+}
+
+func copyFormat(fileType string) string {
+	switch fileType {
+	case config.DatabaseTypeCSV:
+		return "FORMAT CSV, HEADER true"
+	case config.DatabaseTypeJSONL:
+		return "FORMAT JSON"
+	default:
+		return "FORMAT PARQUET"
+	}
+}
+
+func fileExtension(fileType, compression string) string {
+	ext := "." + fileType
+	switch compression {
+	case config.FileCompressionGzip:
+		if fileType != config.DatabaseTypeParquet {
+			ext += ".gz"
+		}
+	case config.FileCompressionZstd:
+		if fileType != config.DatabaseTypeParquet {
+			ext += ".zst"
+		}
+	}
+	return ext
+}
+
+func checkPartitionColumns(partitionBy []string, columns []ColumnMetadata) error {
+	for _, name := range partitionBy {
+		found := false
+		for _, col := range columns {
+			if strings.EqualFold(col.Name, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("partition column '%s' is not in the result set", name)
+		}
+	}
+	return nil
+}
+
+func (f *FileTarget) GetTableRowCount(tableName string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.table(tableName); err != nil {
+		return 0, err
+	}
+	if err := f.flush(tableName); err != nil {
+		return 0, err
+	}
+	var count int
+	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM %s", QuoteIdentifier(config.DatabaseTypeDuckDB, tableName))
+	if err := f.db.QueryRow(countSQL).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to get row count for table %s: %w", tableName, err)
+	}
+	return count, nil
+}
+
+// Query 先把所有缓存写出，再以 DuckDB 方言在表名视图上执行查询。
+func (f *FileTarget) Query(ctx context.Context, sql string) (*sql.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	db, err := f.conn()
+	if err != nil {
+		return nil, err
+	}
+	for name := range f.tables {
+		if err := f.flush(name); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := db.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s files: %w", f.cfg.Type, err)
+	}
+	return rows, nil
+}
+
+func (f *FileTarget) CreateIndexes(tableName string, indexes []config.IndexConfig) error {
+	if len(indexes) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s target does not support indexes", f.cfg.Type)
+}
+
+func (f *FileTarget) Exec(ctx context.Context, sql string, args ...any) error {
+	return fmt.Errorf("%s target does not execute SQL", f.cfg.Type)
+}
+
+// SwapTable 写出暂存表的缓存后，以暂存目录替换表目录。
+func (f *FileTarget) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.flush(stagingTable); err != nil {
+		return err
+	}
+	if err := f.dropLocked(tableName); err != nil {
+		return err
+	}
+	if err := os.Rename(f.tableDir(stagingTable), f.tableDir(tableName)); err != nil {
+		return fmt.Errorf("failed to swap %s into %s: %w", stagingTable, tableName, err)
+	}
+	if err := f.forget(stagingTable); err != nil {
+		return err
+	}
+	_, err := f.table(tableName)
+	return err
+}
+
+// DropTable 删除表目录及其缓存。
+func (f *FileTarget) DropTable(tableName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.dropLocked(tableName)
+}
+
+func (f *FileTarget) dropLocked(tableName string) error {
+	if err := f.forget(tableName); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(f.tableDir(tableName)); err != nil {
+		return fmt.Errorf("failed to remove files of table %s: %w", tableName, err)
+	}
+	return nil
+}
+
+// forget 丢弃表的缓存表与视图，不影响已写出的文件。
+func (f *FileTarget) forget(tableName string) error {
+	if _, ok := f.tables[tableName]; !ok {
+		return nil
+	}
+	delete(f.tables, tableName)
+	stmts := []string{
+		"DROP VIEW IF EXISTS " + QuoteIdentifier(config.DatabaseTypeDuckDB, tableName),
+		"DROP TABLE IF EXISTS " + QuoteIdentifier(config.DatabaseTypeDuckDB, tableName+fileBufferSuffix),
+	}
+	for _, stmt := range stmts {
+		if _, err := f.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to release buffer of table %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
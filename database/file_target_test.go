@@ -0,0 +1,154 @@
+//go:build !windows
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"db-ferry/config"
+)
+
+var fileTargetColumns = []ColumnMetadata{
+	{Name: "id", DatabaseType: "INTEGER"},
+	{Name: "region", DatabaseType: "VARCHAR"},
+}
+
+func relativeDataFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(d.Name(), "part-") {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", dir, err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestFileTargetPartitionedParquet(t *testing.T) {
+	dir := t.TempDir()
+	target := NewFileTarget(config.DatabaseConfig{
+		Name: "export", Type: config.DatabaseTypeParquet, Path: dir,
+		Compression: config.FileCompressionZstd, MaxRowsPerFile: 2, PartitionBy: []string{"region"},
+	})
+	defer target.Close()
+	ctx := context.Background()
+
+	if err := target.CreateTable("users", fileTargetColumns); err != nil {
+		t.Fatalf("CreateTable() error = %v", err)
+	}
+	rows := [][]any{{1, "eu"}, {2, "us"}, {3, "eu"}, {4, "eu"}, {5, "us"}}
+	if err := target.InsertData(ctx, "users", fileTargetColumns, rows); err != nil {
+		t.Fatalf("InsertData() error = %v", err)
+	}
+
+	count, err := target.GetTableRowCount("users")
+	if err != nil || count != 5 {
+		t.Fatalf("GetTableRowCount() = %d, %v; want 5", count, err)
+	}
+	got := strings.Join(relativeDataFiles(t, filepath.Join(dir, "users")), " ")
+	// 每个文件最多 2 行：[1 eu, 2 us] [3 eu, 4 eu] [5 us]
+	want := "region=eu/part-00001-0.parquet region=eu/part-00002-0.parquet region=us/part-00001-0.parquet region=us/part-00003-0.parquet"
+	if got != want {
+		t.Fatalf("data files = %s\nwant %s", got, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "users", FileSchemaName))
+	if err != nil {
+		t.Fatalf("read schema: %v", err)
+	}
+	var schema fileSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("decode schema: %v", err)
+	}
+	if schema.Format != "parquet" || len(schema.Columns) != 2 || schema.Columns[0].Type != "BIGINT" || schema.Columns[0].SourceType != "INTEGER" {
+		t.Fatalf("unexpected schema %+v", schema)
+	}
+
+	q, err := target.Query(ctx, `SELECT count(*) FROM "users" WHERE region = 'eu'`)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	defer q.Close()
+	var eu int
+	if !q.Next() || q.Scan(&eu) != nil || eu != 3 {
+		t.Fatalf("expected 3 eu rows, got %d", eu)
+	}
+}
+
+func TestFileTargetAppendAcrossRunsAndSwap(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.DatabaseConfig{Name: "export", Type: config.DatabaseTypeCSV, Path: dir, Compression: config.FileCompressionGzip, MaxRowsPerFile: 100}
+	ctx := context.Background()
+
+	for run := 0; run < 2; run++ {
+		target := NewFileTarget(cfg)
+		if err := target.EnsureTable("users", fileTargetColumns); err != nil {
+			t.Fatalf("EnsureTable() error = %v", err)
+		}
+		if err := target.InsertData(ctx, "users", fileTargetColumns, [][]any{{run*2 + 1, "eu"}, {run*2 + 2, "us"}}); err != nil {
+			t.Fatalf("InsertData() error = %v", err)
+		}
+		if err := target.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+	}
+	if got := strings.Join(relativeDataFiles(t, filepath.Join(dir, "users")), " "); got != "part-00001.csv.gz part-00002.csv.gz" {
+		t.Fatalf("data files after two appends = %s", got)
+	}
+
+	target := NewFileTarget(cfg)
+	defer target.Close()
+	count, err := target.GetTableRowCount("users")
+	if err != nil || count != 4 {
+		t.Fatalf("GetTableRowCount() = %d, %v; want 4", count, err)
+	}
+
+	staging := StagingTableName("users")
+	if err := target.CreateTable(staging, fileTargetColumns); err != nil {
+		t.Fatalf("CreateTable(staging) error = %v", err)
+	}
+	if err := target.InsertData(ctx, staging, fileTargetColumns, [][]any{{9, "apac"}}); err != nil {
+		t.Fatalf("InsertData(staging) error = %v", err)
+	}
+	if err := target.SwapTable(staging, "users", nil); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, staging)); !os.IsNotExist(err) {
+		t.Fatalf("expected staging directory to be gone, stat err = %v", err)
+	}
+	count, err = target.GetTableRowCount("users")
+	if err != nil || count != 1 {
+		t.Fatalf("GetTableRowCount() after swap = %d, %v; want 1", count, err)
+	}
+
+	if err := target.DropTable("users"); err != nil {
+		t.Fatalf("DropTable() error = %v", err)
+	}
+	if _, err := target.GetTableColumns("users"); err == nil {
+		t.Fatalf("expected dropped table to have no schema")
+	}
+}
+
+func TestFileTargetRejectsMissingPartitionColumn(t *testing.T) {
+	target := NewFileTarget(config.DatabaseConfig{Name: "export", Type: config.DatabaseTypeJSONL, Path: t.TempDir(), PartitionBy: []string{"country"}})
+	defer target.Close()
+	err := target.CreateTable("users", fileTargetColumns)
+	if err == nil || !strings.Contains(err.Error(), "partition column 'country'") {
+		t.Fatalf("expected partition column error, got %v", err)
+	}
+}
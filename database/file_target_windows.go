@@ -0,0 +1,73 @@
+//go:build windows
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"db-ferry/config"
+)
+
+// FileSchemaName 是每个表目录下记录列定义的 schema 文件名。
+const FileSchemaName = "_schema.json"
+
+type FileTarget struct{}
+
+var (
+	_ TargetDB     = (*FileTarget)(nil)
+	_ TableDropper = (*FileTarget)(nil)
+)
+
+func NewFileTarget(dbCfg config.DatabaseConfig) *FileTarget {
+	return &FileTarget{}
+}
+
+func (f *FileTarget) Close() error {
+	return nil
+}
+
+func (f *FileTarget) CreateTable(tableName string, columns []ColumnMetadata) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) EnsureTable(tableName string, columns []ColumnMetadata) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) GetTableColumns(tableName string) ([]ColumnMetadata, error) {
+	return nil, fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) GetTableRowCount(tableName string) (int, error) {
+	return 0, fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) Query(ctx context.Context, sql string) (*sql.Rows, error) {
+	return nil, fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) CreateIndexes(tableName string, indexes []config.IndexConfig) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) Exec(ctx context.Context, sql string, args ...any) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
+
+func (f *FileTarget) DropTable(tableName string) error {
+	return fmt.Errorf("file targets are not supported on windows builds")
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -21,11 +22,17 @@ type MigrationRecord struct {
 	Mode             string
 	RowsProcessed    int64
 	RowsFailed       int64
+	RowsDeleted      int64
 	ValidationResult string
 	ErrorMessage     string
 	Version          string
 }
 
+// Failed reports whether the run recorded an error or never finished.
+func (m MigrationRecord) Failed() bool {
+	return m.ErrorMessage != "" || m.FinishedAt == nil
+}
+
 // HistoryRecorder writes migration audit records to a target database.
 type HistoryRecorder struct {
 	dbType    string
@@ -44,40 +51,76 @@ func NewHistoryRecorder(dbType, tableName string) *HistoryRecorder {
 	}
 }
 
-// EnsureTable creates the history table if it does not exist.
-func (r *HistoryRecorder) EnsureTable(target TargetDB) error {
+// EnsureTable creates the history table if it does not exist and adds
+// columns introduced after the table was first created.
+func (r *HistoryRecorder) EnsureTable(ctx context.Context, target TargetDB) error {
 	sql := r.buildCreateTableSQL()
-	return target.Exec(sql)
+	if err := target.Exec(ctx, sql); err != nil {
+		return err
+	}
+	return SyncSchema(ctx, target, r.dbType, r.tableName, []ColumnMetadata{
+		{Name: "rows_deleted", DatabaseType: "BIGINT"},
+	})
 }
 
 // Start inserts a new migration record and returns its generated ID.
-func (r *HistoryRecorder) Start(target TargetDB, rec *MigrationRecord) (string, error) {
+func (r *HistoryRecorder) Start(ctx context.Context, target TargetDB, rec *MigrationRecord) (string, error) {
 	rec.ID = r.idGen()
 	rec.StartedAt = time.Now().UTC()
 	sql := r.buildInsertSQL(rec)
-	if err := target.Exec(sql); err != nil {
+	if err := target.Exec(ctx, sql); err != nil {
 		return "", fmt.Errorf("failed to insert history record: %w", err)
 	}
 	return rec.ID, nil
 }
 
 // Finish updates the migration record with results.
-func (r *HistoryRecorder) Finish(target TargetDB, id string, processed, failed int64, validationResult, errMsg string) error {
+func (r *HistoryRecorder) Finish(ctx context.Context, target TargetDB, id string, processed, failed, deleted int64, validationResult, errMsg string) error {
 	now := time.Now().UTC()
-	sql := r.buildUpdateSQL(id, processed, failed, validationResult, errMsg, now)
-	if err := target.Exec(sql); err != nil {
+	sql := r.buildUpdateSQL(id, processed, failed, deleted, validationResult, errMsg, now)
+	if err := target.Exec(ctx, sql); err != nil {
 		return fmt.Errorf("failed to update history record: %w", err)
 	}
 	return nil
 }
 
 // List returns the most recent migration records ordered by started_at desc.
-func (r *HistoryRecorder) List(target TargetDB, limit int) ([]MigrationRecord, error) {
+func (r *HistoryRecorder) List(ctx context.Context, target TargetDB, limit int) ([]MigrationRecord, error) {
 	if limit <= 0 {
 		limit = 10
 	}
-	q := r.buildListSQL(limit)
-	rows, err := target.Query(q)
+	return r.list(ctx, target, limit, "")
+}
+
+// Latest returns the most recent record of each named task. Tasks without
+// any history are absent from the result.
+func (r *HistoryRecorder) Latest(ctx context.Context, target TargetDB, taskNames []string) (map[string]MigrationRecord, error) {
+	out := make(map[string]MigrationRecord, len(taskNames))
+	for _, name := range taskNames {
+		records, err := r.list(ctx, target, 1, "task_name = "+r.quote(name))
+		if err != nil {
+			return nil, err
+		}
+		if len(records) > 0 {
+			out[name] = records[0]
+		}
+	}
+	return out, nil
+}
+
+func (r *HistoryRecorder) list(ctx context.Context, target TargetDB, limit int, where string) ([]MigrationRecord, error) {
+	// Tables written by older versions have no rows_deleted column; read them as zero.
+	deletedExpr := "0 AS rows_deleted"
+	if cols, err := target.GetTableColumns(r.tableName); err == nil {
+		for _, col := range cols {
+			if strings.EqualFold(col.Name, "rows_deleted") {
+				deletedExpr = "COALESCE(rows_deleted, 0) AS rows_deleted"
+				break
+			}
+		}
+	}
+	q := r.buildListSQL(limit, deletedExpr, where)
+	rows, err := target.Query(ctx, q)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query history: %w", err)
 	}
@@ -98,6 +141,7 @@ func (r *HistoryRecorder) List(target TargetDB, limit int) ([]MigrationRecord, e
 			&rec.Mode,
 			&rec.RowsProcessed,
 			&rec.RowsFailed,
+			&rec.RowsDeleted,
 			&rec.ValidationResult,
 			&rec.ErrorMessage,
 			&rec.Version,
@@ -131,6 +175,7 @@ func (r *HistoryRecorder) buildCreateTableSQL() string {
 			mode VARCHAR(50),
 			rows_processed BIGINT,
 			rows_failed BIGINT,
+			rows_deleted BIGINT,
 			validation_result VARCHAR(50),
 			error_message TEXT,
 			version VARCHAR(50)
@@ -147,6 +192,7 @@ func (r *HistoryRecorder) buildCreateTableSQL() string {
 			mode VARCHAR(50),
 			rows_processed BIGINT,
 			rows_failed BIGINT,
+			rows_deleted BIGINT,
 			validation_result VARCHAR(50),
 			error_message TEXT,
 			version VARCHAR(50)
@@ -164,6 +210,7 @@ func (r *HistoryRecorder) buildCreateTableSQL() string {
 				mode VARCHAR2(50),
 				rows_processed NUMBER(19,0),
 				rows_failed NUMBER(19,0),
+				rows_deleted NUMBER(19,0),
 				validation_result VARCHAR2(50),
 				error_message CLOB,
 				version VARCHAR2(50)
@@ -188,10 +235,28 @@ func (r *HistoryRecorder) buildCreateTableSQL() string {
 			mode NVARCHAR(50),
 			rows_processed BIGINT,
 			rows_failed BIGINT,
+			rows_deleted BIGINT,
 			validation_result NVARCHAR(50),
 			error_message NVARCHAR(MAX),
 			version NVARCHAR(50)
 		)`, literal, table)
+	case config.DatabaseTypeClickHouse:
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id String,
+			config_hash String,
+			started_at String,
+			finished_at Nullable(String),
+			task_name String,
+			source_db String,
+			target_db String,
+			mode String,
+			rows_processed Int64,
+			rows_failed Int64,
+			rows_deleted Int64,
+			validation_result String,
+			error_message String,
+			version String
+		) ENGINE = MergeTree ORDER BY id`, table)
 	case config.DatabaseTypeDuckDB:
 		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
 			id VARCHAR PRIMARY KEY,
@@ -204,6 +269,7 @@ func (r *HistoryRecorder) buildCreateTableSQL() string {
 			mode VARCHAR,
 			rows_processed BIGINT,
 			rows_failed BIGINT,
+			rows_deleted BIGINT,
 			validation_result VARCHAR,
 			error_message VARCHAR,
 			version VARCHAR
@@ -221,6 +287,7 @@ func (r *HistoryRecorder) buildCreateTableSQL() string {
 			mode TEXT,
 			rows_processed INTEGER,
 			rows_failed INTEGER,
+			rows_deleted INTEGER,
 			validation_result TEXT,
 			error_message TEXT,
 			version TEXT
@@ -232,43 +299,62 @@ func (r *HistoryRecorder) buildInsertSQL(rec *MigrationRecord) string {
 	table := QuoteIdentifier(r.dbType, r.tableName)
 	started := rec.StartedAt.Format("2006-01-02 15:04:05")
 	return fmt.Sprintf(
-		"INSERT INTO %s (id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, validation_result, error_message, version) VALUES (%s, %s, %s, NULL, %s, %s, %s, %s, 0, 0, '', '', %s)",
+		"INSERT INTO %s (id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, rows_deleted, validation_result, error_message, version) VALUES (%s, %s, %s, NULL, %s, %s, %s, %s, 0, 0, 0, '', '', %s)",
 		table,
-		quoteStringLiteral(rec.ID),
-		quoteStringLiteral(rec.ConfigHash),
-		quoteStringLiteral(started),
-		quoteStringLiteral(rec.TaskName),
-		quoteStringLiteral(rec.SourceDB),
-		quoteStringLiteral(rec.TargetDB),
-		quoteStringLiteral(rec.Mode),
-		quoteStringLiteral(rec.Version),
+		r.quote(rec.ID),
+		r.quote(rec.ConfigHash),
+		r.quote(started),
+		r.quote(rec.TaskName),
+		r.quote(rec.SourceDB),
+		r.quote(rec.TargetDB),
+		r.quote(rec.Mode),
+		r.quote(rec.Version),
 	)
 }
 
-func (r *HistoryRecorder) buildUpdateSQL(id string, processed, failed int64, validationResult, errMsg string, finished time.Time) string {
+func (r *HistoryRecorder) buildUpdateSQL(id string, processed, failed, deleted int64, validationResult, errMsg string, finished time.Time) string {
 	table := QuoteIdentifier(r.dbType, r.tableName)
 	finishedStr := finished.Format("2006-01-02 15:04:05")
+	if strings.EqualFold(r.dbType, config.DatabaseTypeClickHouse) {
+		// ClickHouse updates rows through a mutation.
+		return fmt.Sprintf(
+			"ALTER TABLE %s UPDATE finished_at = %s, rows_processed = %d, rows_failed = %d, rows_deleted = %d, validation_result = %s, error_message = %s WHERE id = %s",
+			table,
+			r.quote(finishedStr),
+			processed,
+			failed,
+			deleted,
+			r.quote(validationResult),
+			r.quote(errMsg),
+			r.quote(id),
+		)
+	}
 	return fmt.Sprintf(
-		"UPDATE %s SET finished_at = %s, rows_processed = %d, rows_failed = %d, validation_result = %s, error_message = %s WHERE id = %s",
+		"UPDATE %s SET finished_at = %s, rows_processed = %d, rows_failed = %d, rows_deleted = %d, validation_result = %s, error_message = %s WHERE id = %s",
 		table,
-		quoteStringLiteral(finishedStr),
+		r.quote(finishedStr),
 		processed,
 		failed,
-		quoteStringLiteral(validationResult),
-		quoteStringLiteral(errMsg),
-		quoteStringLiteral(id),
+		deleted,
+		r.quote(validationResult),
+		r.quote(errMsg),
+		r.quote(id),
 	)
 }
 
-func (r *HistoryRecorder) buildListSQL(limit int) string {
+func (r *HistoryRecorder) buildListSQL(limit int, deletedExpr, where string) string {
 	table := QuoteIdentifier(r.dbType, r.tableName)
+	if where != "" {
+		table += " WHERE " + where
+	}
+	// 同一秒开始的记录按 id（纳秒时间戳）区分先后
 	switch strings.ToLower(r.dbType) {
 	case config.DatabaseTypeSQLServer:
-		return fmt.Sprintf("SELECT TOP %d id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, validation_result, error_message, version FROM %s ORDER BY started_at DESC", limit, table)
+		return fmt.Sprintf("SELECT TOP %d id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, %s, validation_result, error_message, version FROM %s ORDER BY started_at DESC, id DESC", limit, deletedExpr, table)
 	case config.DatabaseTypeOracle:
-		return fmt.Sprintf("SELECT * FROM (SELECT id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, validation_result, error_message, version FROM %s ORDER BY started_at DESC) WHERE ROWNUM <= %d", table, limit)
+		return fmt.Sprintf("SELECT * FROM (SELECT id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, %s, validation_result, error_message, version FROM %s ORDER BY started_at DESC, id DESC) WHERE ROWNUM <= %d", deletedExpr, table, limit)
 	default:
-		return fmt.Sprintf("SELECT id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, validation_result, error_message, version FROM %s ORDER BY started_at DESC LIMIT %d", table, limit)
+		return fmt.Sprintf("SELECT id, config_hash, started_at, finished_at, task_name, source_db, target_db, mode, rows_processed, rows_failed, %s, validation_result, error_message, version FROM %s ORDER BY started_at DESC, id DESC LIMIT %d", deletedExpr, table, limit)
 	}
 }
 
@@ -290,6 +376,14 @@ func parseHistoryTime(s string) time.Time {
 	return time.Time{}
 }
 
+// quote renders a string literal; ClickHouse also treats backslash as an escape character.
+func (r *HistoryRecorder) quote(s string) string {
+	if strings.EqualFold(r.dbType, config.DatabaseTypeClickHouse) {
+		return quoteDialectString(config.DatabaseTypeClickHouse, s)
+	}
+	return quoteStringLiteral(s)
+}
+
 func quoteStringLiteral(s string) string {
 	escaped := strings.ReplaceAll(s, "'", "''")
 	return "'" + escaped + "'"
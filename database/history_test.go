@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -24,7 +25,7 @@ func TestHistoryRecorder_EnsureTable(t *testing.T) {
 	db := newTestSQLiteTarget(t)
 	recorder := NewHistoryRecorder(config.DatabaseTypeSQLite, "test_migrations")
 
-	if err := recorder.EnsureTable(db); err != nil {
+	if err := recorder.EnsureTable(context.Background(), db); err != nil {
 		t.Fatalf("EnsureTable failed: %v", err)
 	}
 
@@ -37,7 +38,7 @@ func TestHistoryRecorder_EnsureTable(t *testing.T) {
 		Mode:       "append",
 		Version:    "dev",
 	}
-	_, err := recorder.Start(db, rec)
+	_, err := recorder.Start(context.Background(), db, rec)
 	if err != nil {
 		t.Fatalf("Start after EnsureTable failed: %v", err)
 	}
@@ -46,7 +47,7 @@ func TestHistoryRecorder_EnsureTable(t *testing.T) {
 func TestHistoryRecorder_StartAndFinish(t *testing.T) {
 	db := newTestSQLiteTarget(t)
 	recorder := NewHistoryRecorder(config.DatabaseTypeSQLite, "test_migrations")
-	_ = recorder.EnsureTable(db)
+	_ = recorder.EnsureTable(context.Background(), db)
 
 	rec := &MigrationRecord{
 		ConfigHash: "hash123",
@@ -57,7 +58,7 @@ func TestHistoryRecorder_StartAndFinish(t *testing.T) {
 		Version:    "1.0.0",
 	}
 
-	id, err := recorder.Start(db, rec)
+	id, err := recorder.Start(context.Background(), db, rec)
 	if err != nil {
 		t.Fatalf("Start failed: %v", err)
 	}
@@ -68,12 +69,12 @@ func TestHistoryRecorder_StartAndFinish(t *testing.T) {
 		t.Fatal("expected StartedAt to be set")
 	}
 
-	err = recorder.Finish(db, id, 100, 2, "success", "")
+	err = recorder.Finish(context.Background(), db, id, 100, 2, 3, "success", "")
 	if err != nil {
 		t.Fatalf("Finish failed: %v", err)
 	}
 
-	records, err := recorder.List(db, 10)
+	records, err := recorder.List(context.Background(), db, 10)
 	if err != nil {
 		t.Fatalf("List failed: %v", err)
 	}
@@ -91,6 +92,9 @@ func TestHistoryRecorder_StartAndFinish(t *testing.T) {
 	if r.RowsFailed != 2 {
 		t.Errorf("expected rows_failed 2, got %d", r.RowsFailed)
 	}
+	if r.RowsDeleted != 3 {
+		t.Errorf("expected rows_deleted 3, got %d", r.RowsDeleted)
+	}
 	if r.ValidationResult != "success" {
 		t.Errorf("expected validation_result success, got %s", r.ValidationResult)
 	}
@@ -102,10 +106,62 @@ func TestHistoryRecorder_StartAndFinish(t *testing.T) {
 	}
 }
 
+func TestHistoryRecorder_EnsureTableUpgradesLegacyTable(t *testing.T) {
+	db := newTestSQLiteTarget(t)
+	legacy := `CREATE TABLE test_migrations (
+		id TEXT PRIMARY KEY, config_hash TEXT, started_at TEXT, finished_at TEXT,
+		task_name TEXT, source_db TEXT, target_db TEXT, mode TEXT,
+		rows_processed INTEGER, rows_failed INTEGER,
+		validation_result TEXT, error_message TEXT, version TEXT
+	)`
+	if err := db.Exec(context.Background(), legacy); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if err := db.Exec(context.Background(), `INSERT INTO test_migrations VALUES ('old', '', '2026-01-01 00:00:00', NULL, 'legacy', 'src', 'dst', 'append', 5, 0, 'success', '', 'dev')`); err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+
+	recorder := NewHistoryRecorder(config.DatabaseTypeSQLite, "test_migrations")
+	records, err := recorder.List(context.Background(), db, 10)
+	if err != nil {
+		t.Fatalf("List on legacy table failed: %v", err)
+	}
+	if len(records) != 1 || records[0].RowsDeleted != 0 {
+		t.Fatalf("unexpected legacy records: %+v", records)
+	}
+
+	if err := recorder.EnsureTable(context.Background(), db); err != nil {
+		t.Fatalf("EnsureTable failed: %v", err)
+	}
+	id, err := recorder.Start(context.Background(), db, &MigrationRecord{TaskName: "orders"})
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := recorder.Finish(context.Background(), db, id, 10, 0, 4, "success", ""); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	records, err = recorder.List(context.Background(), db, 10)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	found := false
+	for _, r := range records {
+		if r.ID == id {
+			found = true
+			if r.RowsDeleted != 4 {
+				t.Fatalf("expected rows_deleted 4, got %d", r.RowsDeleted)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("record %s not listed", id)
+	}
+}
+
 func TestHistoryRecorder_ListOrderingAndLimit(t *testing.T) {
 	db := newTestSQLiteTarget(t)
 	recorder := NewHistoryRecorder(config.DatabaseTypeSQLite, "test_migrations")
-	_ = recorder.EnsureTable(db)
+	_ = recorder.EnsureTable(context.Background(), db)
 
 	for i := 0; i < 5; i++ {
 		rec := &MigrationRecord{
@@ -116,14 +172,14 @@ func TestHistoryRecorder_ListOrderingAndLimit(t *testing.T) {
 			Mode:       "append",
 			Version:    "dev",
 		}
-		_, err := recorder.Start(db, rec)
+		_, err := recorder.Start(context.Background(), db, rec)
 		if err != nil {
 			t.Fatalf("Start failed: %v", err)
 		}
 		time.Sleep(2 * time.Millisecond)
 	}
 
-	records, err := recorder.List(db, 3)
+	records, err := recorder.List(context.Background(), db, 3)
 	if err != nil {
 		t.Fatalf("List failed: %v", err)
 	}
@@ -138,6 +194,53 @@ func TestHistoryRecorder_ListOrderingAndLimit(t *testing.T) {
 	}
 }
 
+func TestHistoryRecorder_Latest(t *testing.T) {
+	db := newTestSQLiteTarget(t)
+	recorder := NewHistoryRecorder(config.DatabaseTypeSQLite, "test_migrations")
+	ctx := context.Background()
+	_ = recorder.EnsureTable(ctx, db)
+
+	runs := []struct {
+		task   string
+		errMsg string
+	}{
+		{"orders", "boom"},
+		{"orders", ""},
+		{"users", ""},
+		{"users", "duplicate key"},
+	}
+	for _, run := range runs {
+		id, err := recorder.Start(ctx, db, &MigrationRecord{TaskName: run.task, Mode: "append"})
+		if err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		if err := recorder.Finish(ctx, db, id, 1, 0, 0, "", run.errMsg); err != nil {
+			t.Fatalf("Finish failed: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if _, err := recorder.Start(ctx, db, &MigrationRecord{TaskName: "events", Mode: "append"}); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	latest, err := recorder.Latest(ctx, db, []string{"orders", "users", "events", "missing"})
+	if err != nil {
+		t.Fatalf("Latest failed: %v", err)
+	}
+	if len(latest) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(latest))
+	}
+	if latest["orders"].Failed() {
+		t.Errorf("orders: expected last run to succeed, got %+v", latest["orders"])
+	}
+	if !latest["users"].Failed() || latest["users"].ErrorMessage != "duplicate key" {
+		t.Errorf("users: expected last run to fail, got %+v", latest["users"])
+	}
+	if !latest["events"].Failed() {
+		t.Errorf("events: expected unfinished run to count as failed")
+	}
+}
+
 func TestHistoryRecorder_buildCreateTableSQL_CrossDatabase(t *testing.T) {
 	tests := []struct {
 		dbType string
@@ -181,7 +284,7 @@ func TestHistoryRecorder_buildListSQL_CrossDatabase(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.dbType, func(t *testing.T) {
 			r := NewHistoryRecorder(tt.dbType, "history")
-			sql := r.buildListSQL(10)
+			sql := r.buildListSQL(10, "COALESCE(rows_deleted, 0) AS rows_deleted", "")
 			if !strings.Contains(sql, tt.want) {
 				t.Errorf("expected SQL to contain %q, got:\n%s", tt.want, sql)
 			}
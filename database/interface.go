@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 
 	"db-ferry/config"
@@ -26,11 +27,11 @@ type SourceDB interface {
 	// Close 关闭数据库连接
 	Close() error
 
-	// Query 执行查询并返回结果集
-	Query(sql string) (*sql.Rows, error)
+	// Query 执行查询并返回结果集；ctx 取消时中断查询
+	Query(ctx context.Context, sql string) (*sql.Rows, error)
 
 	// GetRowCount 获取查询结果的行数
-	GetRowCount(sql string) (int, error)
+	GetRowCount(ctx context.Context, sql string) (int, error)
 
 	// GetTables 获取数据库中所有可用的表和视图名称
 	GetTables() ([]string, error)
@@ -50,21 +51,35 @@ type TargetDB interface {
 	// GetTableColumns 获取目标表的现有列信息
 	GetTableColumns(tableName string) ([]ColumnMetadata, error)
 
-	// InsertData 批量插入数据
-	InsertData(tableName string, columns []ColumnMetadata, values [][]any) error
+	// InsertData 批量插入数据；ctx 取消时回滚当前批次
+	InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error
 
-	// UpsertData 批量插入或更新数据
-	UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error
+	// UpsertData 批量插入或更新数据；ctx 取消时回滚当前批次
+	UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error
 
 	// GetTableRowCount 获取目标表的行数
 	GetTableRowCount(tableName string) (int, error)
 
 	// Query 执行查询并返回结果集（校验模块需要）
-	Query(sql string) (*sql.Rows, error)
+	Query(ctx context.Context, sql string) (*sql.Rows, error)
 
 	// CreateIndexes 创建索引
 	CreateIndexes(tableName string, indexes []config.IndexConfig) error
 
-	// Exec 执行原始 SQL（用于 pre_sql / post_sql hooks）
-	Exec(sql string) error
+	// Exec 执行原始 SQL（用于 pre_sql / post_sql hooks），args 为按方言占位符绑定的参数
+	Exec(ctx context.Context, sql string, args ...any) error
+
+	// SwapTable 用暂存表替换目标表（replace 模式的原子切换），并将暂存表上的索引改为正式名称
+	SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error
+}
+
+// TableDropper 由不能通过 Exec 执行 DROP TABLE 的目标实现（如文件目标），用于清理暂存表。
+type TableDropper interface {
+	DropTable(tableName string) error
+}
+
+// KeyedTableEnsurer 由需要在建表时声明合并键的目标实现（如 ClickHouse 以 merge_keys 为排序键建 ReplacingMergeTree），
+// merge 模式以它代替 EnsureTable 创建目标表。
+type KeyedTableEnsurer interface {
+	EnsureKeyedTable(tableName string, columns []ColumnMetadata, keys []string) error
 }
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"db-ferry/config"
+)
+
+// ErrLoadTxUnsupported 表示目标库驱动无法开启跨批次的装载事务。
+var ErrLoadTxUnsupported = errors.New("load transaction is not supported by this target")
+
+const batchSavepointName = "db_ferry_batch"
+
+// savepointSQL 保存某个方言下批次级保存点的语句；begin 为空表示不支持保存点。
+type savepointSQL struct {
+	begin    string
+	rollback string
+	release  string
+}
+
+func batchSavepointSQL(dbType string) savepointSQL {
+	switch strings.ToLower(dbType) {
+	case config.DatabaseTypeSQLServer:
+		return savepointSQL{
+			begin:    "SAVE TRANSACTION " + batchSavepointName,
+			rollback: "ROLLBACK TRANSACTION " + batchSavepointName,
+		}
+	case config.DatabaseTypeOracle:
+		return savepointSQL{
+			begin:    "SAVEPOINT " + batchSavepointName,
+			rollback: "ROLLBACK TO SAVEPOINT " + batchSavepointName,
+		}
+	case config.DatabaseTypeDuckDB, config.DatabaseTypeClickHouse:
+		return savepointSQL{}
+	default:
+		return savepointSQL{
+			begin:    "SAVEPOINT " + batchSavepointName,
+			rollback: "ROLLBACK TO SAVEPOINT " + batchSavepointName,
+			release:  "RELEASE SAVEPOINT " + batchSavepointName,
+		}
+	}
+}
+
+// SupportsBatchSavepoint 报告目标库能否在装载事务内只回滚失败的批次。
+// 不支持时批次失败会使整个装载事务失效，重试与逐行 DLQ 回退都无法进行。
+func SupportsBatchSavepoint(dbType string) bool {
+	return batchSavepointSQL(dbType).begin != ""
+}
+
+// LoadTx 是跨多个批次的目标端装载事务。BeginLoadTx 返回的 context 携带该事务，
+// 以此 context 调用 InsertData / UpsertData 时批次写入加入该事务而不单独提交。
+type LoadTx struct {
+	db      *sql.DB
+	tx      *sql.Tx
+	sp      savepointSQL
+	aborted error
+}
+
+type loadTxKey struct{}
+
+type loadTxStarter interface {
+	beginLoadTx(ctx context.Context) (*LoadTx, error)
+}
+
+// BeginLoadTx 在目标库上开启装载事务，并返回携带该事务的 context。
+func BeginLoadTx(ctx context.Context, target TargetDB) (*LoadTx, context.Context, error) {
+	starter, ok := target.(loadTxStarter)
+	if !ok {
+		return nil, ctx, ErrLoadTxUnsupported
+	}
+	ltx, err := starter.beginLoadTx(ctx)
+	if err != nil {
+		return nil, ctx, err
+	}
+	return ltx, context.WithValue(ctx, loadTxKey{}, ltx), nil
+}
+
+// LoadTxFromContext 返回 ctx 携带的装载事务，没有时返回 nil。
+func LoadTxFromContext(ctx context.Context) *LoadTx {
+	ltx, _ := ctx.Value(loadTxKey{}).(*LoadTx)
+	return ltx
+}
+
+func beginLoadTx(ctx context.Context, db *sql.DB, dbType string) (*LoadTx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin load transaction: %w", err)
+	}
+	return &LoadTx{db: db, tx: tx, sp: batchSavepointSQL(dbType)}, nil
+}
+
+// Savepoints 报告失败的批次能否单独回滚后重试。
+func (l *LoadTx) Savepoints() bool {
+	return l.sp.begin != ""
+}
+
+// Commit 提交装载事务。
+func (l *LoadTx) Commit() error {
+	if l.aborted != nil {
+		_ = l.tx.Rollback()
+		return fmt.Errorf("load transaction aborted: %w", l.aborted)
+	}
+	if err := l.tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit load transaction: %w", err)
+	}
+	return nil
+}
+
+// Rollback 回滚装载事务；事务已结束时不做任何事。
+func (l *LoadTx) Rollback() error {
+	if err := l.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return fmt.Errorf("failed to roll back load transaction: %w", err)
+	}
+	return nil
+}
+
+// batchTx 是单个批次写入使用的事务：独立事务，或装载事务内以保存点包裹的一段。
+type batchTx struct {
+	*sql.Tx
+	ctx  context.Context
+	load *LoadTx
+	done bool
+}
+
+// beginBatch 为一个批次开启事务。ctx 携带本连接池的装载事务时在其中建立保存点，否则开启独立事务。
+func beginBatch(ctx context.Context, db *sql.DB) (*batchTx, error) {
+	if ltx := LoadTxFromContext(ctx); ltx != nil && ltx.db == db {
+		if ltx.aborted != nil {
+			return nil, fmt.Errorf("load transaction aborted: %w", ltx.aborted)
+		}
+		if ltx.sp.begin != "" {
+			if _, err := ltx.tx.ExecContext(ctx, ltx.sp.begin); err != nil {
+				return nil, fmt.Errorf("failed to create batch savepoint: %w", err)
+			}
+		}
+		return &batchTx{Tx: ltx.tx, ctx: ctx, load: ltx}, nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &batchTx{Tx: tx, ctx: ctx}, nil
+}
+
+// commit 提交独立事务；装载事务内只释放保存点，由装载事务统一提交。
+func (b *batchTx) commit() error {
+	b.done = true
+	if b.load == nil {
+		return b.Tx.Commit()
+	}
+	if b.load.sp.release != "" {
+		if _, err := b.Tx.ExecContext(b.ctx, b.load.sp.release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback 撤销本批次的写入，批次已提交时不做任何事。不支持保存点的方言无法只撤销本批次，
+// 装载事务随之失效。
+func (b *batchTx) rollback() {
+	if b.done {
+		return
+	}
+	b.done = true
+	if b.load == nil {
+		_ = b.Tx.Rollback()
+		return
+	}
+	if b.load.sp.rollback == "" {
+		b.load.aborted = errors.New("a batch failed and the dialect cannot roll back to a savepoint")
+		return
+	}
+	if _, err := b.Tx.ExecContext(context.WithoutCancel(b.ctx), b.load.sp.rollback); err != nil {
+		b.load.aborted = fmt.Errorf("failed to roll back batch savepoint: %w", err)
+	}
+}
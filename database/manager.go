@@ -189,7 +189,7 @@ func openConnectionInternal(dbCfg config.DatabaseConfig) (*connectionEntry, erro
 		if err != nil {
 			return nil, err
 		}
-		conn, err := NewMySQLDB(dsn, dbCfg.PoolMaxOpen, dbCfg.PoolMaxIdle)
+		conn, err := NewMySQLDB(dsn, dbCfg.PoolMaxOpen, dbCfg.PoolMaxIdle, dbCfg.Dialect)
 		if err != nil {
 			return nil, err
 		}
@@ -211,7 +211,7 @@ func openConnectionInternal(dbCfg config.DatabaseConfig) (*connectionEntry, erro
 		if err != nil {
 			return nil, err
 		}
-		conn, err := NewPostgresDB(dsn, dbCfg.PoolMaxOpen, dbCfg.PoolMaxIdle)
+		conn, err := NewPostgresDB(dsn, dbCfg.PoolMaxOpen, dbCfg.PoolMaxIdle, dbCfg.Dialect)
 		if err != nil {
 			return nil, err
 		}
@@ -226,6 +226,19 @@ func openConnectionInternal(dbCfg config.DatabaseConfig) (*connectionEntry, erro
 			return nil, err
 		}
 		return &connectionEntry{source: conn, target: conn, close: conn.Close}, nil
+	case config.DatabaseTypeClickHouse:
+		conn, err := NewClickHouseDB(dbCfg)
+		if err != nil {
+			return nil, err
+		}
+		return &connectionEntry{source: conn, target: conn, close: conn.Close}, nil
+	case config.DatabaseTypeCSV, config.DatabaseTypeJSONL, config.DatabaseTypeParquet:
+		// 文件按用途延迟打开：作为源时读取 path 匹配的文件，作为目标时写入 path 目录
+		source, target := NewFileSource(dbCfg), NewFileTarget(dbCfg)
+		closeFn := func() error {
+			return errors.Join(source.Close(), target.Close())
+		}
+		return &connectionEntry{source: source, target: target, close: closeFn}, nil
 	default:
 		return nil, fmt.Errorf("unsupported database type '%s'", dbCfg.Type)
 	}
@@ -299,6 +312,10 @@ func BuildMySQLDSN(dbCfg config.DatabaseConfig) (string, error) {
 		}
 		params += "&tls=" + tlsName
 	}
+	if dbCfg.Dialect == config.DialectTiDB {
+		// 允许向 AUTO_RANDOM 主键写入源端的原值
+		params += "&allow_auto_random_explicit_insert=1"
+	}
 
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s",
 		dbCfg.User,
@@ -148,6 +148,16 @@ func TestDSNBuilders(t *testing.T) {
 		t.Fatalf("unexpected mysql DSN: %s", mysql)
 	}
 
+	tidb, err := BuildMySQLDSN(config.DatabaseConfig{
+		User: "root", Host: "h", Port: "4000", Database: "d", Dialect: config.DialectTiDB,
+	})
+	if err != nil {
+		t.Fatalf("BuildMySQLDSN() error = %v", err)
+	}
+	if !strings.HasSuffix(tidb, "&allow_auto_random_explicit_insert=1") {
+		t.Fatalf("unexpected tidb DSN: %s", tidb)
+	}
+
 	postgres, err := BuildPostgresDSN(config.DatabaseConfig{
 		Host: "h", Port: "5432", User: "u", Password: "p", Database: "d",
 	})
@@ -1,18 +1,24 @@
 package database
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"db-ferry/config"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type MySQLDB struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect string // 为空表示 MySQL，另有 mariadb、tidb
 }
 
 var (
@@ -20,7 +26,7 @@ var (
 	_ TargetDB = (*MySQLDB)(nil)
 )
 
-func NewMySQLDB(connectionString string, maxOpen, maxIdle int) (*MySQLDB, error) {
+func NewMySQLDB(connectionString string, maxOpen, maxIdle int, dialect string) (*MySQLDB, error) {
 	db, err := sql.Open("mysql", connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open mysql connection: %w", err)
@@ -36,8 +42,8 @@ func NewMySQLDB(connectionString string, maxOpen, maxIdle int) (*MySQLDB, error)
 		return nil, fmt.Errorf("failed to ping mysql database: %w", err)
 	}
 
-	log.Println("Successfully connected to MySQL database")
-	return &MySQLDB{db: db}, nil
+	log.Printf("Successfully connected to %s database", mysqlDialectName(dialect))
+	return &MySQLDB{db: db, dialect: dialect}, nil
 }
 
 func (m *MySQLDB) Close() error {
@@ -47,24 +53,44 @@ func (m *MySQLDB) Close() error {
 	return nil
 }
 
-func (m *MySQLDB) Exec(sql string) error {
-	_, err := m.db.Exec(sql)
+func (m *MySQLDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := m.db.ExecContext(ctx, sql, args...)
 	return err
 }
 
-func (m *MySQLDB) Query(sql string) (*sql.Rows, error) {
+func (m *MySQLDB) beginLoadTx(ctx context.Context) (*LoadTx, error) {
+	return beginLoadTx(ctx, m.db, config.DatabaseTypeMySQL)
+}
+
+// SwapTable 通过单条 RENAME TABLE 语句原子交换暂存表与目标表，然后删除旧表。
+// 目标表不存在时先按暂存表结构建一张空表，保证 RENAME 可以执行。
+// MySQL 的索引名属于表，暂存表上的索引随 RENAME 保留。MySQL 的 DDL 会隐式提交，因此不使用事务。
+func (m *MySQLDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeMySQL, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if _, err := m.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to swap table %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+func (m *MySQLDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	log.Printf("Executing MySQL query: %s", sql)
-	rows, err := m.db.Query(sql)
+	rows, err := m.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute mysql query: %w", err)
 	}
 	return rows, nil
 }
 
-func (m *MySQLDB) GetRowCount(sql string) (int, error) {
+func (m *MySQLDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sql)
-	if err := m.db.QueryRow(countSQL).Scan(&count); err != nil {
+	if err := m.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to get row count: %w", err)
 	}
 	return count, nil
@@ -158,16 +184,16 @@ func (m *MySQLDB) createTable(tableName string, columns []ColumnMetadata, dropEx
 	return nil
 }
 
-func (m *MySQLDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (m *MySQLDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	if len(values) == 0 {
 		return nil
 	}
 
-	tx, err := m.db.Begin()
+	tx, err := beginBatch(ctx, m.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
 	placeholders := make([]string, len(columns))
 	columnNames := make([]string, len(columns))
@@ -181,26 +207,127 @@ func (m *MySQLDB) InsertData(tableName string, columns []ColumnMetadata, values
 		strings.Join(columnNames, ", "),
 		strings.Join(placeholders, ", "))
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to insert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (m *MySQLDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+// BulkInsertData 通过 LOAD DATA LOCAL INFILE 流式写入一个批次；需要服务端开启 local_infile。
+// 各列先读入用户变量，再由 SET 子句赋值，因此 transform 表达式仍然生效。
+func (m *MySQLDB) BulkInsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	tx, err := beginBatch(ctx, m.db)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	variables := make([]string, len(columns))
+	assignments := make([]string, len(columns))
+	for i, col := range columns {
+		variables[i] = fmt.Sprintf("@c%d", i+1)
+		expr := variables[i]
+		if col.Transform != "" {
+			expr = strings.ReplaceAll(col.Transform, "?", variables[i])
+		}
+		assignments[i] = fmt.Sprintf("%s = %s", m.quoteIdentifier(col.Name), expr)
+	}
+
+	handler := fmt.Sprintf("db_ferry_%d", mysqlLoadSeq.Add(1))
+	data := encodeMySQLLoadData(values)
+	mysql.RegisterReaderHandler(handler, func() io.Reader { return bytes.NewReader(data) })
+	defer mysql.DeregisterReaderHandler(handler)
+
+	loadSQL := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s CHARACTER SET utf8mb4 "+
+		"FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (%s) SET %s",
+		handler,
+		m.quoteIdentifier(tableName),
+		strings.Join(variables, ", "),
+		strings.Join(assignments, ", "))
+
+	if _, err := tx.ExecContext(ctx, loadSQL); err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+var mysqlLoadSeq atomic.Uint64
+
+// encodeMySQLLoadData 按 LOAD DATA 默认转义规则把行编码为制表符分隔文本，NULL 写作 \N。
+func encodeMySQLLoadData(values [][]any) []byte {
+	var buf bytes.Buffer
+	for _, row := range values {
+		for i, v := range row {
+			if i > 0 {
+				buf.WriteByte('\t')
+			}
+			switch val := v.(type) {
+			case nil:
+				buf.WriteString(`\N`)
+			case []byte:
+				writeMySQLLoadEscaped(&buf, string(val))
+			case string:
+				writeMySQLLoadEscaped(&buf, val)
+			case bool:
+				if val {
+					buf.WriteByte('1')
+				} else {
+					buf.WriteByte('0')
+				}
+			case time.Time:
+				// 与 INSERT 路径一致：BuildMySQLDSN 未设置 loc，驱动按 UTC 写入时间
+				buf.WriteString(val.UTC().Format("2006-01-02 15:04:05.999999"))
+			default:
+				writeMySQLLoadEscaped(&buf, fmt.Sprint(val))
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func writeMySQLLoadEscaped(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case 0:
+			buf.WriteString(`\0`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+func (m *MySQLDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	if len(values) == 0 {
 		return nil
 	}
@@ -236,25 +363,25 @@ func (m *MySQLDB) UpsertData(tableName string, columns []ColumnMetadata, values
 		strings.Join(updateAssignments, ", "),
 	)
 
-	tx, err := m.db.Begin()
+	tx, err := beginBatch(ctx, m.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare upsert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to upsert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
@@ -299,7 +426,11 @@ func (m *MySQLDB) createIndex(tableName string, index config.IndexConfig) error
 
 	columns := make([]string, len(index.ParsedColumns))
 	for i, col := range index.ParsedColumns {
-		columns[i] = fmt.Sprintf("%s %s", m.quoteIdentifier(col.Name), col.Order)
+		columns[i] = m.quoteIdentifier(col.Name)
+		// TiDB 解析但忽略降序索引，不写排序方向以免误以为索引按 DESC 排列
+		if m.dialect != config.DialectTiDB {
+			columns[i] += " " + col.Order
+		}
 	}
 
 	uniqueStr := ""
@@ -313,7 +444,7 @@ func (m *MySQLDB) createIndex(tableName string, index config.IndexConfig) error
 		m.quoteIdentifier(tableName),
 		strings.Join(columns, ", "))
 
-	log.Printf("Creating MySQL index: %s", createSQL)
+	log.Printf("Creating %s index: %s", mysqlDialectName(m.dialect), createSQL)
 	if _, err := m.db.Exec(createSQL); err != nil {
 		return fmt.Errorf("failed to create index '%s': %w", index.Name, err)
 	}
@@ -322,7 +453,47 @@ func (m *MySQLDB) createIndex(tableName string, index config.IndexConfig) error
 }
 
 func (m *MySQLDB) mapToMySQLType(column ColumnMetadata) string {
-	return MapToMySQLType(column)
+	return MapToMySQLDialectType(m.dialect, column)
+}
+
+// MapToMySQLDialectType 按 MySQL 兼容引擎的方言映射列类型。MariaDB 与 TiDB 的 DATETIME 保留微秒，
+// VARCHAR 不超过 utf8mb4 单列上限 16383 字符；MariaDB 另有原生 UUID，TiDB 有原生 JSON。
+func MapToMySQLDialectType(dialect string, column ColumnMetadata) string {
+	if dialect != config.DialectMariaDB && dialect != config.DialectTiDB {
+		return MapToMySQLType(column)
+	}
+
+	typeName := strings.ToUpper(column.DatabaseType)
+	switch {
+	case typeName == "UUID" && dialect == config.DialectMariaDB:
+		return "UUID"
+	case strings.Contains(typeName, "JSON"):
+		if dialect == config.DialectTiDB {
+			return "JSON"
+		}
+		return "LONGTEXT"
+	}
+
+	typeDef := MapToMySQLType(column)
+	switch {
+	case typeDef == "DATETIME":
+		return "DATETIME(6)"
+	case strings.HasPrefix(typeDef, "VARCHAR(") && column.Length > 16383:
+		return "MEDIUMTEXT"
+	default:
+		return typeDef
+	}
+}
+
+func mysqlDialectName(dialect string) string {
+	switch dialect {
+	case config.DialectMariaDB:
+		return "MariaDB"
+	case config.DialectTiDB:
+		return "TiDB"
+	default:
+		return "MySQL"
+	}
 }
 
 // MapToMySQLType maps column metadata to a MySQL column type.
@@ -1,13 +1,16 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"testing"
+	"time"
 
 	"db-ferry/config"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 )
 
 func TestMySQLCreateTableAndEnsureTable(t *testing.T) {
@@ -49,7 +52,7 @@ func TestMySQLInsertUpsertAndCount(t *testing.T) {
 	insertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	insertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := m.InsertData("users", cols, values); err != nil {
+	if err := m.InsertData(context.Background(), "users", cols, values); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
 
@@ -59,7 +62,7 @@ func TestMySQLInsertUpsertAndCount(t *testing.T) {
 	upsertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	upsertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := m.UpsertData("users", cols, values, []string{"id"}); err != nil {
+	if err := m.UpsertData(context.Background(), "users", cols, values, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() error = %v", err)
 	}
 
@@ -72,7 +75,7 @@ func TestMySQLInsertUpsertAndCount(t *testing.T) {
 	transformPrep := mock.ExpectPrepare(regexp.QuoteMeta(insertTransformSQL))
 	transformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := m.InsertData("users", transformCols, [][]any{{1, "a"}}); err != nil {
+	if err := m.InsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}); err != nil {
 		t.Fatalf("InsertData() with transform error = %v", err)
 	}
 
@@ -81,13 +84,13 @@ func TestMySQLInsertUpsertAndCount(t *testing.T) {
 	upsertTransformPrep := mock.ExpectPrepare(regexp.QuoteMeta(upsertTransformSQL))
 	upsertTransformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := m.UpsertData("users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
+	if err := m.UpsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() with transform error = %v", err)
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT * FROM users) AS count_query")).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-	cnt, err := m.GetRowCount("SELECT * FROM users")
+	cnt, err := m.GetRowCount(context.Background(), "SELECT * FROM users")
 	if err != nil {
 		t.Fatalf("GetRowCount() error = %v", err)
 	}
@@ -107,7 +110,7 @@ func TestMySQLInsertUpsertAndCount(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1")).
 		WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
-	rows, err := m.Query("SELECT 1")
+	rows, err := m.Query(context.Background(), "SELECT 1")
 	if err != nil {
 		t.Fatalf("Query() error = %v", err)
 	}
@@ -118,6 +121,41 @@ func TestMySQLInsertUpsertAndCount(t *testing.T) {
 	}
 }
 
+func TestEncodeMySQLLoadData(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	got := string(encodeMySQLLoadData([][]any{
+		{int64(1), "a\tb\nc\\d", nil, true, ts},
+		{int64(2), []byte("x\x00y"), "\\N", false, 1.5},
+	}))
+	want := "1\ta\\tb\\nc\\\\d\t\\N\t1\t2024-01-02 03:04:05.6\n" +
+		"2\tx\\0y\t\\\\N\t0\t1.5\n"
+	if got != want {
+		t.Fatalf("encodeMySQLLoadData() = %q, want %q", got, want)
+	}
+}
+
+func TestEncodeMySQLLoadDataMatchesInsertTimeZone(t *testing.T) {
+	dsn, err := BuildMySQLDSN(config.DatabaseConfig{Type: config.DatabaseTypeMySQL, Host: "localhost", Port: "3306", User: "u", Password: "p", Database: "d"})
+	if err != nil {
+		t.Fatalf("BuildMySQLDSN() error = %v", err)
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("ParseDSN() error = %v", err)
+	}
+
+	// INSERT 路径由驱动按 DSN 的 loc 转换时间，LOAD DATA 必须写入相同的墙上时间
+	ts := time.Date(2024, 1, 2, 8, 4, 5, 0, time.FixedZone("UTC+8", 8*3600))
+	got := string(encodeMySQLLoadData([][]any{{ts}}))
+	want := ts.In(cfg.Loc).Format("2006-01-02 15:04:05.999999") + "\n"
+	if got != want {
+		t.Fatalf("encodeMySQLLoadData() = %q, want %q", got, want)
+	}
+	if got != "2024-01-02 00:04:05\n" {
+		t.Fatalf("expected UTC wall-clock time, got %q", got)
+	}
+}
+
 func TestMySQLCreateIndexes(t *testing.T) {
 	db, mock := newSQLMock(t)
 	m := &MySQLDB{db: db}
@@ -144,7 +182,7 @@ func TestMySQLExec(t *testing.T) {
 	m := &MySQLDB{db: db}
 
 	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE t (id INT)")).WillReturnResult(sqlmock.NewResult(0, 0))
-	if err := m.Exec("CREATE TABLE t (id INT)"); err != nil {
+	if err := m.Exec(context.Background(), "CREATE TABLE t (id INT)"); err != nil {
 		t.Fatalf("Exec() error = %v", err)
 	}
 
@@ -184,13 +222,13 @@ func TestMySQLQueryAndCountErrors(t *testing.T) {
 	m := &MySQLDB{db: db}
 
 	mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("query failed"))
-	_, err := m.Query("SELECT 1")
+	_, err := m.Query(context.Background(), "SELECT 1")
 	if err == nil {
 		t.Fatalf("expected Query error")
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT * FROM bad) AS count_query")).WillReturnError(errors.New("count failed"))
-	_, err = m.GetRowCount("SELECT * FROM bad")
+	_, err = m.GetRowCount(context.Background(), "SELECT * FROM bad")
 	if err == nil {
 		t.Fatalf("expected GetRowCount error")
 	}
@@ -218,10 +256,10 @@ func TestMySQLEdgeCases(t *testing.T) {
 	if err := m.CreateTable("users", nil); err == nil {
 		t.Fatalf("expected CreateTable() error for empty columns")
 	}
-	if err := m.InsertData("users", nil, nil); err != nil {
+	if err := m.InsertData(context.Background(), "users", nil, nil); err != nil {
 		t.Fatalf("InsertData() empty should be nil, got %v", err)
 	}
-	if err := m.UpsertData("users", nil, [][]any{{1}}, nil); err == nil {
+	if err := m.UpsertData(context.Background(), "users", nil, [][]any{{1}}, nil); err == nil {
 		t.Fatalf("expected merge_keys required error")
 	}
 
@@ -248,3 +286,64 @@ func TestMySQLEdgeCases(t *testing.T) {
 		}
 	}
 }
+
+func TestMySQLSwapTable(t *testing.T) {
+	db, mock := newSQLMock(t)
+	m := &MySQLDB{db: db}
+
+	// Indexes keep their names: MySQL scopes index names to the table.
+	indexes := []config.IndexConfig{{Name: "idx_id", Columns: []string{"id"}}}
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE IF EXISTS `users__dbf_old`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS `users` LIKE `users__dbf_staging`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("RENAME TABLE `users` TO `users__dbf_old`, `users__dbf_staging` TO `users`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE IF EXISTS `users__dbf_old`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := m.SwapTable("users__dbf_staging", "users", indexes); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("DROP TABLE IF EXISTS `users__dbf_old`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE TABLE IF NOT EXISTS `users` LIKE `users__dbf_staging`")).WillReturnError(errors.New("boom"))
+	if err := m.SwapTable("users__dbf_staging", "users", nil); err == nil {
+		t.Fatalf("expected SwapTable() error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+}
+
+func TestMySQLDialects(t *testing.T) {
+	db, mock := newSQLMock(t)
+	m := &MySQLDB{db: db, dialect: config.DialectTiDB}
+
+	// TiDB 忽略降序索引，不写排序方向
+	mock.ExpectExec(regexp.QuoteMeta("DROP INDEX IF EXISTS `idx_created` ON `users`")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta("CREATE INDEX `idx_created` ON `users` (`created_at`)")).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := m.CreateIndexes("users", []config.IndexConfig{{Name: "idx_created", Columns: []string{"created_at:DESC"}}}); err != nil {
+		t.Fatalf("CreateIndexes() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+
+	cases := []struct {
+		dialect string
+		meta    ColumnMetadata
+		want    string
+	}{
+		{"", ColumnMetadata{DatabaseType: "TIMESTAMP"}, "DATETIME"},
+		{"", ColumnMetadata{DatabaseType: "UUID"}, "TEXT"},
+		{config.DialectMariaDB, ColumnMetadata{DatabaseType: "TIMESTAMP"}, "DATETIME(6)"},
+		{config.DialectMariaDB, ColumnMetadata{DatabaseType: "UUID"}, "UUID"},
+		{config.DialectMariaDB, ColumnMetadata{DatabaseType: "JSON"}, "LONGTEXT"},
+		{config.DialectTiDB, ColumnMetadata{DatabaseType: "JSON"}, "JSON"},
+		{config.DialectTiDB, ColumnMetadata{DatabaseType: "UUID"}, "TEXT"},
+		{config.DialectTiDB, ColumnMetadata{DatabaseType: "VARCHAR", LengthValid: true, Length: 20000}, "MEDIUMTEXT"},
+		{config.DialectTiDB, ColumnMetadata{DatabaseType: "VARCHAR", LengthValid: true, Length: 200}, "VARCHAR(200)"},
+	}
+	for _, tc := range cases {
+		if got := MapToMySQLDialectType(tc.dialect, tc.meta); got != tc.want {
+			t.Fatalf("MapToMySQLDialectType(%q, %+v) = %s, want %s", tc.dialect, tc.meta, got, tc.want)
+		}
+	}
+}
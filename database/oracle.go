@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -47,24 +48,49 @@ func (o *OracleDB) Close() error {
 	return nil
 }
 
-func (o *OracleDB) Exec(sql string) error {
-	_, err := o.db.Exec(sql)
+func (o *OracleDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := o.db.ExecContext(ctx, sql, args...)
 	return err
 }
 
-func (o *OracleDB) Query(sql string) (*sql.Rows, error) {
+func (o *OracleDB) beginLoadTx(ctx context.Context) (*LoadTx, error) {
+	return beginLoadTx(ctx, o.db, config.DatabaseTypeOracle)
+}
+
+// SwapTable 先将目标表改名为旧表再将暂存表改名为目标表。
+// Oracle 的 DDL 会隐式提交，两次改名之间存在短暂的无表窗口；第二步失败时会尝试恢复旧表。
+func (o *OracleDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeOracle, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	for i, stmt := range stmts {
+		if _, err := o.db.Exec(stmt); err != nil {
+			if i == 2 {
+				restore := fmt.Sprintf("BEGIN EXECUTE IMMEDIATE 'ALTER TABLE %s RENAME TO %s'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -942 THEN RAISE; END IF; END;", o.ident(retiredTableName(tableName)), o.ident(tableName))
+				if _, restoreErr := o.db.Exec(restore); restoreErr != nil {
+					log.Printf("Warning: failed to restore table %s after swap failure: %v", tableName, restoreErr)
+				}
+			}
+			return fmt.Errorf("failed to swap table %s: %w", tableName, err)
+		}
+	}
+	return nil
+}
+
+func (o *OracleDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	log.Printf("Executing Oracle query: %s", sql)
-	rows, err := o.db.Query(sql)
+	rows, err := o.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute oracle query: %w", err)
 	}
 	return rows, nil
 }
 
-func (o *OracleDB) GetRowCount(sql string) (int, error) {
+func (o *OracleDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) count_query", sql)
-	if err := o.db.QueryRow(countSQL).Scan(&count); err != nil {
+	if err := o.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to get row count: %w", err)
 	}
 	return count, nil
@@ -157,16 +183,16 @@ func (o *OracleDB) createTable(tableName string, columns []ColumnMetadata, dropE
 	return nil
 }
 
-func (o *OracleDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (o *OracleDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	if len(values) == 0 {
 		return nil
 	}
 
-	tx, err := o.db.Begin()
+	tx, err := beginBatch(ctx, o.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
 	placeholders := make([]string, len(columns))
 	columnNames := make([]string, len(columns))
@@ -180,26 +206,70 @@ func (o *OracleDB) InsertData(tableName string, columns []ColumnMetadata, values
 		strings.Join(columnNames, ", "),
 		strings.Join(placeholders, ", "))
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to insert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// BulkInsertData 以数组绑定一次执行整个批次：每个占位符绑定一列的全部值
+func (o *OracleDB) BulkInsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	tx, err := beginBatch(ctx, o.db)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	placeholders := make([]string, len(columns))
+	columnNames := make([]string, len(columns))
+	for i, col := range columns {
+		placeholders[i] = buildOraclePlaceholder(i, col.Transform)
+		columnNames[i] = o.ident(col.Name)
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		o.ident(tableName),
+		strings.Join(columnNames, ", "),
+		strings.Join(placeholders, ", "))
+
+	arrays := make([]any, len(columns))
+	for i := range columns {
+		column := make([]any, len(values))
+		for r, row := range values {
+			column[r] = row[i]
+		}
+		arrays[i] = column
+	}
+
+	if _, err := tx.ExecContext(ctx, insertSQL, arrays...); err != nil {
+		return fmt.Errorf("failed to insert batch with array binding: %w", err)
+	}
+
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (o *OracleDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+func (o *OracleDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	if len(values) == 0 {
 		return nil
 	}
@@ -247,25 +317,25 @@ func (o *OracleDB) UpsertData(tableName string, columns []ColumnMetadata, values
 		strings.Join(sourceColumns, ", "),
 	)
 
-	tx, err := o.db.Begin()
+	tx, err := beginBatch(ctx, o.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
-	stmt, err := tx.Prepare(mergeSQL)
+	stmt, err := tx.PrepareContext(ctx, mergeSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare upsert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to upsert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"testing"
@@ -61,7 +62,7 @@ func TestOracleInsertUpsertAndCount(t *testing.T) {
 	insertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	insertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := o.InsertData("users", cols, values); err != nil {
+	if err := o.InsertData(context.Background(), "users", cols, values); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
 
@@ -71,7 +72,7 @@ func TestOracleInsertUpsertAndCount(t *testing.T) {
 	upsertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	upsertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := o.UpsertData("users", cols, values, []string{"id"}); err != nil {
+	if err := o.UpsertData(context.Background(), "users", cols, values, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() error = %v", err)
 	}
 
@@ -84,7 +85,7 @@ func TestOracleInsertUpsertAndCount(t *testing.T) {
 	transformPrep := mock.ExpectPrepare(regexp.QuoteMeta(insertTransformSQL))
 	transformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := o.InsertData("users", transformCols, [][]any{{1, "a"}}); err != nil {
+	if err := o.InsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}); err != nil {
 		t.Fatalf("InsertData() with transform error = %v", err)
 	}
 
@@ -93,13 +94,13 @@ func TestOracleInsertUpsertAndCount(t *testing.T) {
 	upsertTransformPrep := mock.ExpectPrepare(regexp.QuoteMeta(upsertTransformSQL))
 	upsertTransformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := o.UpsertData("users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
+	if err := o.UpsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() with transform error = %v", err)
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM (SELECT * FROM users) count_query`)).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-	cnt, err := o.GetRowCount("SELECT * FROM users")
+	cnt, err := o.GetRowCount(context.Background(), "SELECT * FROM users")
 	if err != nil {
 		t.Fatalf("GetRowCount() error = %v", err)
 	}
@@ -119,7 +120,7 @@ func TestOracleInsertUpsertAndCount(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1")).
 		WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
-	rows, err := o.Query("SELECT 1")
+	rows, err := o.Query(context.Background(), "SELECT 1")
 	if err != nil {
 		t.Fatalf("Query() error = %v", err)
 	}
@@ -160,7 +161,7 @@ func TestOracleExec(t *testing.T) {
 	o := &OracleDB{db: db}
 
 	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE t (id INT)`)).WillReturnResult(sqlmock.NewResult(0, 0))
-	if err := o.Exec(`CREATE TABLE t (id INT)`); err != nil {
+	if err := o.Exec(context.Background(), `CREATE TABLE t (id INT)`); err != nil {
 		t.Fatalf("Exec() error = %v", err)
 	}
 
@@ -200,10 +201,10 @@ func TestOracleEdgeCasesAndTypeMapping(t *testing.T) {
 	if err := o.CreateTable("users", nil); err == nil {
 		t.Fatalf("expected CreateTable() error for empty columns")
 	}
-	if err := o.InsertData("users", nil, nil); err != nil {
+	if err := o.InsertData(context.Background(), "users", nil, nil); err != nil {
 		t.Fatalf("InsertData() empty should be nil, got %v", err)
 	}
-	if err := o.UpsertData("users", nil, [][]any{{1}}, nil); err == nil {
+	if err := o.UpsertData(context.Background(), "users", nil, [][]any{{1}}, nil); err == nil {
 		t.Fatalf("expected merge_keys required error")
 	}
 
@@ -226,3 +227,36 @@ func TestOracleEdgeCasesAndTypeMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestOracleSwapTable(t *testing.T) {
+	db, mock := newSQLMock(t)
+	o := &OracleDB{db: db}
+
+	indexes := []config.IndexConfig{{Name: "idx_id", Columns: []string{"id"}}}
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeOracle, "users__dbf_staging", "users", indexes)
+	if err != nil {
+		t.Fatalf("BuildSwapTableSQL() error = %v", err)
+	}
+	if len(stmts) != 5 || stmts[4] != `ALTER INDEX "IDX_ID__DBF_STAGING" RENAME TO "IDX_ID"` {
+		t.Fatalf("expected 4 swap statements and an index rename, got %v", stmts)
+	}
+	for _, stmt := range stmts {
+		mock.ExpectExec(regexp.QuoteMeta(stmt)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	if err := o.SwapTable("users__dbf_staging", "users", indexes); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+
+	// A failed rename of the staging table restores the retired live table.
+	mock.ExpectExec(regexp.QuoteMeta(stmts[0])).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(stmts[1])).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(stmts[2])).WillReturnError(errors.New("rename failed"))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "USERS__DBF_OLD" RENAME TO "USERS"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := o.SwapTable("users__dbf_staging", "users", indexes); err == nil {
+		t.Fatalf("expected SwapTable() error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package database
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PgOutput message types (protocol version 1).
+const (
+	PgOutputBegin    = 'B'
+	PgOutputCommit   = 'C'
+	PgOutputOrigin   = 'O'
+	PgOutputRelation = 'R'
+	PgOutputType     = 'Y'
+	PgOutputInsert   = 'I'
+	PgOutputUpdate   = 'U'
+	PgOutputDelete   = 'D'
+	PgOutputTruncate = 'T'
+)
+
+// PgRelation describes a table announced by a pgoutput Relation message.
+type PgRelation struct {
+	ID        uint32
+	Namespace string
+	Name      string
+	Columns   []PgRelationColumn
+}
+
+// PgRelationColumn is a single column of a PgRelation.
+type PgRelationColumn struct {
+	Name    string
+	TypeOID uint32
+	IsKey   bool
+}
+
+// PgTupleValue is one column of a pgoutput tuple. Unchanged marks a TOASTed
+// value that was not modified and therefore not sent.
+type PgTupleValue struct {
+	Null      bool
+	Unchanged bool
+	Text      string
+}
+
+// PgOutputMessage is a decoded pgoutput message. Only the fields relevant to
+// its Type are populated.
+type PgOutputMessage struct {
+	Type        byte
+	Relation    *PgRelation
+	RelationID  uint32
+	RelationIDs []uint32
+	NewTuple    []PgTupleValue
+	OldTuple    []PgTupleValue
+}
+
+// DecodePgOutput parses a single binary pgoutput message.
+func DecodePgOutput(data []byte) (*PgOutputMessage, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty pgoutput message")
+	}
+	r := &pgReader{buf: data[1:]}
+	msg := &PgOutputMessage{Type: data[0]}
+
+	switch msg.Type {
+	case PgOutputBegin, PgOutputCommit, PgOutputOrigin, PgOutputType:
+		// Transaction framing and type metadata carry nothing we apply.
+	case PgOutputRelation:
+		rel := &PgRelation{ID: r.readUint32(), Namespace: r.readString(), Name: r.readString()}
+		r.readByte() // replica identity
+		n := int(r.readUint16())
+		for i := 0; i < n && r.err == nil; i++ {
+			flags := r.readByte()
+			col := PgRelationColumn{Name: r.readString(), TypeOID: r.readUint32(), IsKey: flags&1 == 1}
+			r.readUint32() // type modifier
+			rel.Columns = append(rel.Columns, col)
+		}
+		msg.Relation = rel
+	case PgOutputInsert:
+		msg.RelationID = r.readUint32()
+		if kind := r.readByte(); kind != 'N' && r.err == nil {
+			return nil, fmt.Errorf("unexpected pgoutput insert tuple marker %q", kind)
+		}
+		msg.NewTuple = r.readTuple()
+	case PgOutputUpdate:
+		msg.RelationID = r.readUint32()
+		kind := r.readByte()
+		if kind == 'K' || kind == 'O' {
+			msg.OldTuple = r.readTuple()
+			kind = r.readByte()
+		}
+		if kind != 'N' && r.err == nil {
+			return nil, fmt.Errorf("unexpected pgoutput update tuple marker %q", kind)
+		}
+		msg.NewTuple = r.readTuple()
+	case PgOutputDelete:
+		msg.RelationID = r.readUint32()
+		if kind := r.readByte(); kind != 'K' && kind != 'O' && r.err == nil {
+			return nil, fmt.Errorf("unexpected pgoutput delete tuple marker %q", kind)
+		}
+		msg.OldTuple = r.readTuple()
+	case PgOutputTruncate:
+		n := int(r.readUint32())
+		r.readByte() // options
+		for i := 0; i < n && r.err == nil; i++ {
+			msg.RelationIDs = append(msg.RelationIDs, r.readUint32())
+		}
+	default:
+		return nil, fmt.Errorf("unsupported pgoutput message type %q", msg.Type)
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to decode pgoutput %q message: %w", msg.Type, r.err)
+	}
+	return msg, nil
+}
+
+// PgTextValue converts a pgoutput text value into the Go value the snapshot
+// scan produces for the same column type, so streamed rows bind the same way.
+// Values that fail to parse (e.g. "infinity") are passed through as text.
+func PgTextValue(typeOID uint32, text string) any {
+	switch typeOID {
+	case 20, 21, 23: // int8, int2, int4
+		if v, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return v
+		}
+	case 700, 701: // float4, float8
+		if v, err := strconv.ParseFloat(text, 64); err == nil {
+			return v
+		}
+	case 16: // bool
+		return text == "t"
+	case 17: // bytea
+		if v, err := decodePgBytea(text); err == nil {
+			return v
+		}
+	case 1700: // numeric, kept as its exact text like the snapshot scan
+		return []byte(text)
+	case 1082: // date
+		if v, err := time.Parse("2006-01-02", text); err == nil {
+			return v
+		}
+	case 1114: // timestamp
+		if v, err := time.Parse("2006-01-02 15:04:05.999999999", text); err == nil {
+			return v
+		}
+	case 1184: // timestamptz
+		for _, layout := range pgTimestamptzLayouts {
+			if v, err := time.Parse(layout, text); err == nil {
+				return v
+			}
+		}
+	}
+	return text
+}
+
+// pgTimestamptzLayouts covers the UTC offsets PostgreSQL prints: whole hours,
+// hours and minutes, or hours, minutes and seconds.
+var pgTimestamptzLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07:00:00",
+}
+
+// decodePgBytea decodes the hex ("\x0a0b") or escape ("a\\b\001") bytea
+// output formats.
+func decodePgBytea(text string) ([]byte, error) {
+	if strings.HasPrefix(text, `\x`) {
+		return hex.DecodeString(text[2:])
+	}
+	out := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			out = append(out, text[i])
+			continue
+		}
+		if i+1 < len(text) && text[i+1] == '\\' {
+			out = append(out, '\\')
+			i++
+			continue
+		}
+		if i+4 > len(text) {
+			return nil, fmt.Errorf("invalid bytea escape in %q", text)
+		}
+		v, err := strconv.ParseUint(text[i+1:i+4], 8, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea escape in %q", text)
+		}
+		out = append(out, byte(v))
+		i += 3
+	}
+	return out, nil
+}
+
+// PgTypeName returns a database type name for a PostgreSQL type OID.
+func PgTypeName(typeOID uint32) string {
+	switch typeOID {
+	case 16:
+		return "BOOL"
+	case 17:
+		return "BYTEA"
+	case 20:
+		return "INT8"
+	case 21:
+		return "INT2"
+	case 23:
+		return "INT4"
+	case 700:
+		return "FLOAT4"
+	case 701:
+		return "FLOAT8"
+	case 1700:
+		return "NUMERIC"
+	case 1082:
+		return "DATE"
+	case 1114:
+		return "TIMESTAMP"
+	case 1184:
+		return "TIMESTAMPTZ"
+	case 1043:
+		return "VARCHAR"
+	default:
+		return "TEXT"
+	}
+}
+
+// ParsePgLSN parses an LSN in the textual "X/Y" form.
+func ParsePgLSN(s string) (uint64, error) {
+	hi, lo, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return 0, fmt.Errorf("invalid LSN %q", s)
+	}
+	h, err := strconv.ParseUint(hi, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %w", s, err)
+	}
+	l, err := strconv.ParseUint(lo, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LSN %q: %w", s, err)
+	}
+	return h<<32 | l, nil
+}
+
+// FormatPgLSN formats an LSN in the textual "X/Y" form.
+func FormatPgLSN(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", lsn>>32, uint32(lsn))
+}
+
+type pgReader struct {
+	buf []byte
+	err error
+}
+
+func (r *pgReader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if len(r.buf) < n {
+		r.err = fmt.Errorf("message truncated")
+		return false
+	}
+	return true
+}
+
+func (r *pgReader) readByte() byte {
+	if !r.need(1) {
+		return 0
+	}
+	b := r.buf[0]
+	r.buf = r.buf[1:]
+	return b
+}
+
+func (r *pgReader) readUint16() uint16 {
+	if !r.need(2) {
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.buf)
+	r.buf = r.buf[2:]
+	return v
+}
+
+func (r *pgReader) readUint32() uint32 {
+	if !r.need(4) {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.buf)
+	r.buf = r.buf[4:]
+	return v
+}
+
+func (r *pgReader) readString() string {
+	if r.err != nil {
+		return ""
+	}
+	for i, b := range r.buf {
+		if b == 0 {
+			s := string(r.buf[:i])
+			r.buf = r.buf[i+1:]
+			return s
+		}
+	}
+	r.err = fmt.Errorf("unterminated string")
+	return ""
+}
+
+func (r *pgReader) readTuple() []PgTupleValue {
+	n := int(r.readUint16())
+	values := make([]PgTupleValue, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		switch kind := r.readByte(); kind {
+		case 'n':
+			values = append(values, PgTupleValue{Null: true})
+		case 'u':
+			values = append(values, PgTupleValue{Unchanged: true})
+		case 't':
+			size := int(r.readUint32())
+			if !r.need(size) {
+				return values
+			}
+			values = append(values, PgTupleValue{Text: string(r.buf[:size])})
+			r.buf = r.buf[size:]
+		default:
+			if r.err == nil {
+				r.err = fmt.Errorf("unknown tuple column kind %q", kind)
+			}
+		}
+	}
+	return values
+}
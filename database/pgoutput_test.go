@@ -0,0 +1,126 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func pgString(s string) []byte { return append([]byte(s), 0) }
+
+func pgUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func TestDecodePgOutputRelationAndChanges(t *testing.T) {
+	rel := []byte{PgOutputRelation}
+	rel = append(rel, pgUint32(16384)...)
+	rel = append(rel, pgString("public")...)
+	rel = append(rel, pgString("users")...)
+	rel = append(rel, 'd', 0, 2)
+	rel = append(rel, 1)
+	rel = append(rel, pgString("id")...)
+	rel = append(rel, pgUint32(23)...)
+	rel = append(rel, pgUint32(0xFFFFFFFF)...)
+	rel = append(rel, 0)
+	rel = append(rel, pgString("name")...)
+	rel = append(rel, pgUint32(25)...)
+	rel = append(rel, pgUint32(0xFFFFFFFF)...)
+
+	msg, err := DecodePgOutput(rel)
+	if err != nil {
+		t.Fatalf("DecodePgOutput(relation) error = %v", err)
+	}
+	if msg.Relation == nil || msg.Relation.Name != "users" || msg.Relation.Namespace != "public" || len(msg.Relation.Columns) != 2 {
+		t.Fatalf("unexpected relation: %+v", msg.Relation)
+	}
+	if !msg.Relation.Columns[0].IsKey || msg.Relation.Columns[1].IsKey {
+		t.Fatalf("unexpected key flags: %+v", msg.Relation.Columns)
+	}
+
+	upd := []byte{PgOutputUpdate}
+	upd = append(upd, pgUint32(16384)...)
+	upd = append(upd, 'K', 0, 2, 't')
+	upd = append(upd, pgUint32(1)...)
+	upd = append(upd, '7', 'n')
+	upd = append(upd, 'N', 0, 2, 't')
+	upd = append(upd, pgUint32(1)...)
+	upd = append(upd, '8', 'u')
+
+	msg, err = DecodePgOutput(upd)
+	if err != nil {
+		t.Fatalf("DecodePgOutput(update) error = %v", err)
+	}
+	if msg.RelationID != 16384 || len(msg.OldTuple) != 2 || msg.OldTuple[0].Text != "7" || !msg.OldTuple[1].Null {
+		t.Fatalf("unexpected old tuple: %+v", msg)
+	}
+	if len(msg.NewTuple) != 2 || msg.NewTuple[0].Text != "8" || !msg.NewTuple[1].Unchanged {
+		t.Fatalf("unexpected new tuple: %+v", msg.NewTuple)
+	}
+	if v := PgTextValue(23, msg.NewTuple[0].Text); v != int64(8) {
+		t.Fatalf("PgTextValue(int4) = %#v", v)
+	}
+
+	if _, err := DecodePgOutput(upd[:len(upd)-3]); err == nil {
+		t.Fatalf("expected truncated message error")
+	}
+	if _, err := DecodePgOutput([]byte{'Z'}); err == nil {
+		t.Fatalf("expected unsupported message error")
+	}
+}
+
+func TestPgLSNRoundTrip(t *testing.T) {
+	lsn, err := ParsePgLSN("16/B374D848")
+	if err != nil {
+		t.Fatalf("ParsePgLSN() error = %v", err)
+	}
+	if lsn != 0x16B374D848 {
+		t.Fatalf("ParsePgLSN() = %X", lsn)
+	}
+	if got := FormatPgLSN(lsn); got != "16/B374D848" {
+		t.Fatalf("FormatPgLSN() = %q", got)
+	}
+	if _, err := ParsePgLSN("16B374D848"); err == nil {
+		t.Fatalf("expected error for LSN without separator")
+	}
+}
+
+func TestPgTextValueMatchesSnapshotTypes(t *testing.T) {
+	if v, ok := PgTextValue(17, `\x00ff41`).([]byte); !ok || !bytes.Equal(v, []byte{0x00, 0xff, 'A'}) {
+		t.Fatalf("PgTextValue(bytea hex) = %#v", PgTextValue(17, `\x00ff41`))
+	}
+	if v, ok := PgTextValue(17, `a\\b\001`).([]byte); !ok || !bytes.Equal(v, []byte{'a', '\\', 'b', 1}) {
+		t.Fatalf("PgTextValue(bytea escape) = %#v", PgTextValue(17, `a\\b\001`))
+	}
+	if v, ok := PgTextValue(1700, "12.3400").([]byte); !ok || string(v) != "12.3400" {
+		t.Fatalf("PgTextValue(numeric) = %#v", PgTextValue(1700, "12.3400"))
+	}
+
+	date := PgTextValue(1082, "2026-03-04")
+	if v, ok := date.(time.Time); !ok || !v.Equal(time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("PgTextValue(date) = %#v", date)
+	}
+	ts := PgTextValue(1114, "2026-03-04 05:06:07.123456")
+	if v, ok := ts.(time.Time); !ok || !v.Equal(time.Date(2026, 3, 4, 5, 6, 7, 123456000, time.UTC)) {
+		t.Fatalf("PgTextValue(timestamp) = %#v", ts)
+	}
+	for text, offset := range map[string]int{
+		"2026-03-04 05:06:07+08":       8 * 3600,
+		"2026-03-04 05:06:07.5+05:30":  5*3600 + 1800,
+		"2026-03-04 05:06:07-03:30:15": -(3*3600 + 1815),
+	} {
+		v, ok := PgTextValue(1184, text).(time.Time)
+		if !ok {
+			t.Fatalf("PgTextValue(timestamptz %q) = %#v", text, PgTextValue(1184, text))
+		}
+		if _, got := v.Zone(); got != offset {
+			t.Fatalf("PgTextValue(timestamptz %q) offset = %d, want %d", text, got, offset)
+		}
+	}
+	if v := PgTextValue(1184, "infinity"); v != "infinity" {
+		t.Fatalf("PgTextValue(infinity) = %#v", v)
+	}
+}
@@ -1,18 +1,24 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	"db-ferry/config"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type PostgresDB struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect string // 为空表示 PostgreSQL，另有 cockroachdb
+
+	mu          sync.Mutex
+	primaryKeys map[string][]string // CockroachDB upsert 使用的表主键缓存
 }
 
 var (
@@ -20,7 +26,7 @@ var (
 	_ TargetDB = (*PostgresDB)(nil)
 )
 
-func NewPostgresDB(connectionString string, maxOpen, maxIdle int) (*PostgresDB, error) {
+func NewPostgresDB(connectionString string, maxOpen, maxIdle int, dialect string) (*PostgresDB, error) {
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open postgresql connection: %w", err)
@@ -36,8 +42,8 @@ func NewPostgresDB(connectionString string, maxOpen, maxIdle int) (*PostgresDB,
 		return nil, fmt.Errorf("failed to ping postgresql database: %w", err)
 	}
 
-	log.Println("Successfully connected to PostgreSQL database")
-	return &PostgresDB{db: db}, nil
+	log.Printf("Successfully connected to %s database", postgresDialectName(dialect))
+	return &PostgresDB{db: db, dialect: dialect}, nil
 }
 
 func (p *PostgresDB) Close() error {
@@ -47,24 +53,47 @@ func (p *PostgresDB) Close() error {
 	return nil
 }
 
-func (p *PostgresDB) Exec(sql string) error {
-	_, err := p.db.Exec(sql)
+func (p *PostgresDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := p.db.ExecContext(ctx, sql, args...)
 	return err
 }
 
-func (p *PostgresDB) Query(sql string) (*sql.Rows, error) {
+func (p *PostgresDB) beginLoadTx(ctx context.Context) (*LoadTx, error) {
+	return beginLoadTx(ctx, p.db, config.DatabaseTypePostgreSQL)
+}
+
+// SwapTable 利用 PostgreSQL 的事务性 DDL 原子替换目标表。
+// CockroachDB 不建议在一个显式事务中执行多条 schema 变更，逐条执行，删表与改名之间目标表短暂不存在。
+func (p *PostgresDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypePostgreSQL, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	if p.dialect != config.DialectCockroachDB {
+		return execInTx(p.db, stmts)
+	}
+	for _, stmt := range stmts {
+		if _, err := p.db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to execute %q: %w", stmt, err)
+		}
+	}
+	p.forgetPrimaryKey(tableName)
+	return nil
+}
+
+func (p *PostgresDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	log.Printf("Executing PostgreSQL query: %s", sql)
-	rows, err := p.db.Query(sql)
+	rows, err := p.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute postgresql query: %w", err)
 	}
 	return rows, nil
 }
 
-func (p *PostgresDB) GetRowCount(sql string) (int, error) {
+func (p *PostgresDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sql)
-	if err := p.db.QueryRow(countSQL).Scan(&count); err != nil {
+	if err := p.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to get row count: %w", err)
 	}
 	return count, nil
@@ -133,10 +162,11 @@ func (p *PostgresDB) createTable(tableName string, columns []ColumnMetadata, dro
 
 	if dropExisting {
 		dropSQL := fmt.Sprintf("DROP TABLE IF EXISTS %s", p.quoteIdentifier(tableName))
-		log.Printf("Dropping existing PostgreSQL table: %s", dropSQL)
+		log.Printf("Dropping existing %s table: %s", postgresDialectName(p.dialect), dropSQL)
 		if _, err := p.db.Exec(dropSQL); err != nil {
 			return fmt.Errorf("failed to drop table %s: %w", tableName, err)
 		}
+		p.forgetPrimaryKey(tableName)
 	}
 
 	columnDefs := make([]string, len(columns))
@@ -150,7 +180,7 @@ func (p *PostgresDB) createTable(tableName string, columns []ColumnMetadata, dro
 		createStmt = "CREATE TABLE IF NOT EXISTS"
 	}
 	createSQL := fmt.Sprintf("%s %s (%s)", createStmt, p.quoteIdentifier(tableName), strings.Join(columnDefs, ", "))
-	log.Printf("Creating new PostgreSQL table: %s", createSQL)
+	log.Printf("Creating new %s table: %s", postgresDialectName(p.dialect), createSQL)
 	if _, err := p.db.Exec(createSQL); err != nil {
 		return fmt.Errorf("failed to create table %s: %w", tableName, err)
 	}
@@ -158,16 +188,16 @@ func (p *PostgresDB) createTable(tableName string, columns []ColumnMetadata, dro
 	return nil
 }
 
-func (p *PostgresDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (p *PostgresDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	if len(values) == 0 {
 		return nil
 	}
 
-	tx, err := p.db.Begin()
+	tx, err := beginBatch(ctx, p.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
 	placeholders := make([]string, len(columns))
 	columnNames := make([]string, len(columns))
@@ -181,26 +211,63 @@ func (p *PostgresDB) InsertData(tableName string, columns []ColumnMetadata, valu
 		strings.Join(columnNames, ", "),
 		strings.Join(placeholders, ", "))
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to insert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (p *PostgresDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+// BulkInsertData 使用 COPY FROM STDIN 写入一个批次
+func (p *PostgresDB) BulkInsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if hasColumnTransform(columns) {
+		return ErrBulkLoadUnsupported
+	}
+
+	tx, err := beginBatch(ctx, p.db)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(tableName, columnNames(columns)...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range values {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("failed to copy row: %w", err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush copy data: %w", err)
+	}
+
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	if len(values) == 0 {
 		return nil
 	}
@@ -242,32 +309,86 @@ func (p *PostgresDB) UpsertData(tableName string, columns []ColumnMetadata, valu
 		strings.Join(conflictCols, ", "),
 		action,
 	)
+	if p.dialect == config.DialectCockroachDB {
+		// merge_keys 恰为主键时使用 CockroachDB 的 UPSERT，按主键直接写入而不先读取冲突行
+		isPK, err := p.mergeKeysArePrimaryKey(ctx, tableName, mergeKeys)
+		if err != nil {
+			return err
+		}
+		if isPK && len(updateAssignments) > 0 {
+			insertSQL = fmt.Sprintf("UPSERT INTO %s (%s) VALUES (%s)",
+				p.quoteIdentifier(tableName),
+				strings.Join(columnNames, ", "),
+				strings.Join(placeholders, ", "))
+		}
+	}
 
-	tx, err := p.db.Begin()
+	tx, err := beginBatch(ctx, p.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare upsert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to upsert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
+// mergeKeysArePrimaryKey 判断 mergeKeys 是否恰好是表的主键（不区分大小写与顺序）。
+// 没有显式主键的 CockroachDB 表以隐藏的 rowid 为主键，此时返回 false。
+func (p *PostgresDB) mergeKeysArePrimaryKey(ctx context.Context, tableName string, mergeKeys []string) (bool, error) {
+	p.mu.Lock()
+	pk, ok := p.primaryKeys[tableName]
+	p.mu.Unlock()
+	if !ok {
+		var err error
+		pk, err = GetTablePrimaryKey(ctx, p, config.DatabaseTypePostgreSQL, tableName)
+		if err != nil {
+			return false, err
+		}
+		p.mu.Lock()
+		if p.primaryKeys == nil {
+			p.primaryKeys = make(map[string][]string)
+		}
+		p.primaryKeys[tableName] = pk
+		p.mu.Unlock()
+	}
+
+	if len(pk) != len(mergeKeys) {
+		return false, nil
+	}
+	pkSet := make(map[string]struct{}, len(pk))
+	for _, col := range pk {
+		pkSet[strings.ToLower(col)] = struct{}{}
+	}
+	for _, key := range mergeKeys {
+		if _, ok := pkSet[strings.ToLower(key)]; !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *PostgresDB) forgetPrimaryKey(tableName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.primaryKeys, tableName)
+}
+
 func (p *PostgresDB) GetTableRowCount(tableName string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM %s", p.quoteIdentifier(tableName))
@@ -299,7 +420,12 @@ func (p *PostgresDB) CreateIndexes(tableName string, indexes []config.IndexConfi
 }
 
 func (p *PostgresDB) createIndex(tableName string, index config.IndexConfig) error {
-	if _, err := p.db.Exec(fmt.Sprintf("DROP INDEX IF EXISTS %s", p.quoteIdentifier(index.Name))); err != nil {
+	dropSQL := fmt.Sprintf("DROP INDEX IF EXISTS %s", p.quoteIdentifier(index.Name))
+	if p.dialect == config.DialectCockroachDB {
+		// CockroachDB 的索引名属于表，唯一索引需 CASCADE 才能删除
+		dropSQL = fmt.Sprintf("DROP INDEX IF EXISTS %s@%s CASCADE", p.quoteIdentifier(tableName), p.quoteIdentifier(index.Name))
+	}
+	if _, err := p.db.Exec(dropSQL); err != nil {
 		log.Printf("Warning: failed to drop existing index '%s': %v", index.Name, err)
 	}
 
@@ -319,7 +445,7 @@ func (p *PostgresDB) createIndex(tableName string, index config.IndexConfig) err
 		p.quoteIdentifier(tableName),
 		strings.Join(columns, ", "))
 
-	log.Printf("Creating PostgreSQL index: %s", createSQL)
+	log.Printf("Creating %s index: %s", postgresDialectName(p.dialect), createSQL)
 	if _, err := p.db.Exec(createSQL); err != nil {
 		return fmt.Errorf("failed to create index '%s': %w", index.Name, err)
 	}
@@ -328,9 +454,51 @@ func (p *PostgresDB) createIndex(tableName string, index config.IndexConfig) err
 }
 
 func (p *PostgresDB) mapToPostgresType(column ColumnMetadata) string {
+	if p.dialect == config.DialectCockroachDB {
+		return MapToCockroachType(column)
+	}
 	return MapToPostgresType(column)
 }
 
+// MapToCockroachType 把列元数据映射为 CockroachDB 的原生类型名（STRING、BYTES、FLOAT8 等），
+// 并使用原生 UUID 与 JSONB。
+func MapToCockroachType(column ColumnMetadata) string {
+	typeName := strings.ToUpper(column.DatabaseType)
+	switch {
+	case typeName == "UUID":
+		return "UUID"
+	case strings.Contains(typeName, "JSON"):
+		return "JSONB"
+	}
+
+	typeDef := MapToPostgresType(column)
+	switch {
+	case typeDef == "BIGINT":
+		return "INT8"
+	case typeDef == "DOUBLE PRECISION":
+		return "FLOAT8"
+	case strings.HasPrefix(typeDef, "NUMERIC"):
+		return "DECIMAL" + strings.TrimPrefix(typeDef, "NUMERIC")
+	case typeDef == "TEXT":
+		return "STRING"
+	case strings.HasPrefix(typeDef, "VARCHAR"):
+		return "STRING" + strings.TrimPrefix(typeDef, "VARCHAR")
+	case typeDef == "BYTEA":
+		return "BYTES"
+	case typeDef == "BOOLEAN":
+		return "BOOL"
+	default:
+		return typeDef
+	}
+}
+
+func postgresDialectName(dialect string) string {
+	if dialect == config.DialectCockroachDB {
+		return "CockroachDB"
+	}
+	return "PostgreSQL"
+}
+
 // MapToPostgresType maps column metadata to a PostgreSQL column type.
 func MapToPostgresType(column ColumnMetadata) string {
 	typeName := strings.ToUpper(column.DatabaseType)
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"testing"
@@ -49,7 +50,7 @@ func TestPostgresInsertUpsertAndCount(t *testing.T) {
 	insertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	insertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := p.InsertData("users", cols, values); err != nil {
+	if err := p.InsertData(context.Background(), "users", cols, values); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
 
@@ -59,7 +60,7 @@ func TestPostgresInsertUpsertAndCount(t *testing.T) {
 	upsertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	upsertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := p.UpsertData("users", cols, values, []string{"id"}); err != nil {
+	if err := p.UpsertData(context.Background(), "users", cols, values, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() error = %v", err)
 	}
 
@@ -72,7 +73,7 @@ func TestPostgresInsertUpsertAndCount(t *testing.T) {
 	transformPrep := mock.ExpectPrepare(regexp.QuoteMeta(insertTransformSQL))
 	transformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := p.InsertData("users", transformCols, [][]any{{1, "a"}}); err != nil {
+	if err := p.InsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}); err != nil {
 		t.Fatalf("InsertData() with transform error = %v", err)
 	}
 
@@ -81,13 +82,13 @@ func TestPostgresInsertUpsertAndCount(t *testing.T) {
 	upsertTransformPrep := mock.ExpectPrepare(regexp.QuoteMeta(upsertTransformSQL))
 	upsertTransformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := p.UpsertData("users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
+	if err := p.UpsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() with transform error = %v", err)
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM (SELECT * FROM users) AS count_query`)).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-	cnt, err := p.GetRowCount("SELECT * FROM users")
+	cnt, err := p.GetRowCount(context.Background(), "SELECT * FROM users")
 	if err != nil {
 		t.Fatalf("GetRowCount() error = %v", err)
 	}
@@ -107,7 +108,7 @@ func TestPostgresInsertUpsertAndCount(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1")).
 		WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
-	rows, err := p.Query("SELECT 1")
+	rows, err := p.Query(context.Background(), "SELECT 1")
 	if err != nil {
 		t.Fatalf("Query() error = %v", err)
 	}
@@ -118,6 +119,34 @@ func TestPostgresInsertUpsertAndCount(t *testing.T) {
 	}
 }
 
+func TestPostgresBulkInsertData(t *testing.T) {
+	db, mock := newSQLMock(t)
+	p := &PostgresDB{db: db}
+	cols := []ColumnMetadata{
+		{Name: "id", DatabaseType: "INT"},
+		{Name: "name", DatabaseType: "VARCHAR"},
+	}
+
+	mock.ExpectBegin()
+	copyPrep := mock.ExpectPrepare(regexp.QuoteMeta(`COPY "users" ("id", "name") FROM STDIN`))
+	copyPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(0, 0))
+	copyPrep.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	if err := p.BulkInsertData(context.Background(), "users", cols, [][]any{{1, "a"}, {2, "b"}}); err != nil {
+		t.Fatalf("BulkInsertData() error = %v", err)
+	}
+
+	transformCols := []ColumnMetadata{{Name: "id", Transform: "id + ?"}}
+	if err := p.BulkInsertData(context.Background(), "users", transformCols, [][]any{{1}}); !errors.Is(err, ErrBulkLoadUnsupported) {
+		t.Fatalf("expected ErrBulkLoadUnsupported for transform columns, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+}
+
 func TestPostgresCreateIndexesAndHelpers(t *testing.T) {
 	db, mock := newSQLMock(t)
 	p := &PostgresDB{db: db}
@@ -152,7 +181,7 @@ func TestPostgresExec(t *testing.T) {
 	p := &PostgresDB{db: db}
 
 	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE t (id INT)`)).WillReturnResult(sqlmock.NewResult(0, 0))
-	if err := p.Exec(`CREATE TABLE t (id INT)`); err != nil {
+	if err := p.Exec(context.Background(), `CREATE TABLE t (id INT)`); err != nil {
 		t.Fatalf("Exec() error = %v", err)
 	}
 
@@ -192,13 +221,13 @@ func TestPostgresQueryAndCountErrors(t *testing.T) {
 	p := &PostgresDB{db: db}
 
 	mock.ExpectQuery("SELECT 1").WillReturnError(errors.New("query failed"))
-	_, err := p.Query("SELECT 1")
+	_, err := p.Query(context.Background(), "SELECT 1")
 	if err == nil {
 		t.Fatalf("expected Query error")
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT COUNT(*) FROM (SELECT * FROM bad) AS count_query")).WillReturnError(errors.New("count failed"))
-	_, err = p.GetRowCount("SELECT * FROM bad")
+	_, err = p.GetRowCount(context.Background(), "SELECT * FROM bad")
 	if err == nil {
 		t.Fatalf("expected GetRowCount error")
 	}
@@ -226,10 +255,10 @@ func TestPostgresEdgeCasesAndTypeMapping(t *testing.T) {
 	if err := p.CreateTable("users", nil); err == nil {
 		t.Fatalf("expected CreateTable() error for empty columns")
 	}
-	if err := p.InsertData("users", nil, nil); err != nil {
+	if err := p.InsertData(context.Background(), "users", nil, nil); err != nil {
 		t.Fatalf("InsertData() empty should be nil, got %v", err)
 	}
-	if err := p.UpsertData("users", nil, [][]any{{1}}, nil); err == nil {
+	if err := p.UpsertData(context.Background(), "users", nil, [][]any{{1}}, nil); err == nil {
 		t.Fatalf("expected merge_keys required error")
 	}
 
@@ -252,3 +281,100 @@ func TestPostgresEdgeCasesAndTypeMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestPostgresSwapTable(t *testing.T) {
+	db, mock := newSQLMock(t)
+	p := &PostgresDB{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "users__dbf_staging" RENAME TO "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER INDEX "idx_id__dbf_staging" RENAME TO "idx_id"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	if err := p.SwapTable("users__dbf_staging", "users", []config.IndexConfig{{Name: "idx_id", Columns: []string{"id"}}}); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "users__dbf_staging" RENAME TO "users"`)).WillReturnError(errors.New("rename failed"))
+	mock.ExpectRollback()
+	if err := p.SwapTable("users__dbf_staging", "users", nil); err == nil {
+		t.Fatalf("expected SwapTable() error")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+}
+
+func TestCockroachDialect(t *testing.T) {
+	db, mock := newSQLMock(t)
+	p := &PostgresDB{db: db, dialect: config.DialectCockroachDB}
+	cols := []ColumnMetadata{
+		{Name: "id", DatabaseType: "INT"},
+		{Name: "name", DatabaseType: "VARCHAR"},
+	}
+
+	// merge_keys 为主键时使用 UPSERT，主键只查询一次
+	mock.ExpectQuery("FROM information_schema.table_constraints").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("id"))
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectPrepare(regexp.QuoteMeta(`UPSERT INTO "users" ("id", "name") VALUES ($1, $2)`)).
+			ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		if err := p.UpsertData(context.Background(), "users", cols, [][]any{{1, "a"}}, []string{"ID"}); err != nil {
+			t.Fatalf("UpsertData() error = %v", err)
+		}
+	}
+
+	// 没有显式主键（隐藏 rowid）时回退到 ON CONFLICT
+	mock.ExpectQuery("FROM information_schema.table_constraints").
+		WillReturnRows(sqlmock.NewRows([]string{"column_name"}).AddRow("rowid"))
+	mock.ExpectBegin()
+	mock.ExpectPrepare(regexp.QuoteMeta(`INSERT INTO "events" ("id", "name") VALUES ($1, $2) ON CONFLICT("id") DO UPDATE SET "name"=EXCLUDED."name"`)).
+		ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	if err := p.UpsertData(context.Background(), "events", cols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
+		t.Fatalf("UpsertData() error = %v", err)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta(`DROP INDEX IF EXISTS "users"@"uk_id" CASCADE`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`CREATE UNIQUE INDEX "uk_id" ON "users" ("id" ASC)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := p.CreateIndexes("users", []config.IndexConfig{{Name: "uk_id", Columns: []string{"id"}, Unique: true}}); err != nil {
+		t.Fatalf("CreateIndexes() error = %v", err)
+	}
+
+	// 交换表逐条执行，不包在显式事务里
+	mock.ExpectExec(regexp.QuoteMeta(`DROP TABLE IF EXISTS "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE "users__dbf_staging" RENAME TO "users"`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := p.SwapTable("users__dbf_staging", "users", nil); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+
+	cases := []struct {
+		meta ColumnMetadata
+		want string
+	}{
+		{ColumnMetadata{DatabaseType: "INT"}, "INT8"},
+		{ColumnMetadata{DatabaseType: "DOUBLE"}, "FLOAT8"},
+		{ColumnMetadata{DatabaseType: "DECIMAL", PrecisionScaleValid: true, Precision: 10, Scale: 2}, "DECIMAL(10,2)"},
+		{ColumnMetadata{DatabaseType: "VARCHAR", LengthValid: true, Length: 12}, "STRING(12)"},
+		{ColumnMetadata{DatabaseType: "TEXT"}, "STRING"},
+		{ColumnMetadata{DatabaseType: "BLOB"}, "BYTES"},
+		{ColumnMetadata{DatabaseType: "BOOL"}, "BOOL"},
+		{ColumnMetadata{DatabaseType: "UUID"}, "UUID"},
+		{ColumnMetadata{DatabaseType: "JSONB"}, "JSONB"},
+		{ColumnMetadata{DatabaseType: "DATE"}, "TIMESTAMP"},
+	}
+	for _, tc := range cases {
+		if got := p.mapToPostgresType(tc.meta); got != tc.want {
+			t.Fatalf("mapToPostgresType(%+v) = %s, want %s", tc.meta, got, tc.want)
+		}
+	}
+}
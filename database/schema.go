@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -24,7 +25,7 @@ func BuildAlterTableAddColumnSQL(dbType, tableName string, column ColumnMetadata
 }
 
 // SyncSchema compares desired columns with existing table columns and adds missing ones.
-func SyncSchema(db TargetDB, dbType, tableName string, desiredColumns []ColumnMetadata) error {
+func SyncSchema(ctx context.Context, db TargetDB, dbType, tableName string, desiredColumns []ColumnMetadata) error {
 	if len(desiredColumns) == 0 {
 		return nil
 	}
@@ -46,7 +47,7 @@ func SyncSchema(db TargetDB, dbType, tableName string, desiredColumns []ColumnMe
 
 		sql := BuildAlterTableAddColumnSQL(dbType, tableName, col)
 		log.Printf("Schema evolution: adding column %s to %s", col.Name, tableName)
-		if err := db.Exec(sql); err != nil {
+		if err := db.Exec(ctx, sql); err != nil {
 			return fmt.Errorf("failed to add column %s to %s: %w", col.Name, tableName, err)
 		}
 	}
@@ -69,9 +70,9 @@ type IndexInfo struct {
 }
 
 // GetTableSchema extracts column metadata by executing a dummy query.
-func GetTableSchema(source SourceDB, tableName string) ([]ColumnMetadata, error) {
+func GetTableSchema(ctx context.Context, source SourceDB, tableName string) ([]ColumnMetadata, error) {
 	sqlText := fmt.Sprintf("SELECT * FROM %s WHERE 1=0", tableName)
-	rows, err := source.Query(sqlText)
+	rows, err := source.Query(ctx, sqlText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query table schema: %w", err)
 	}
@@ -117,7 +118,7 @@ func GetTableSchema(source SourceDB, tableName string) ([]ColumnMetadata, error)
 
 // GetTablePrimaryKey attempts to retrieve the primary key columns for a table.
 // This is best-effort and may return an empty slice for unsupported database types.
-func GetTablePrimaryKey(source SourceDB, dbType, tableName string) ([]string, error) {
+func GetTablePrimaryKey(ctx context.Context, source SourceDB, dbType, tableName string) ([]string, error) {
 	var query string
 	switch strings.ToLower(dbType) {
 	case config.DatabaseTypeMySQL:
@@ -158,6 +159,15 @@ func GetTablePrimaryKey(source SourceDB, dbType, tableName string) ([]string, er
 			  AND tc.table_name = '%s'
 			ORDER BY kcu.ordinal_position
 		`, tableName)
+	case config.DatabaseTypeClickHouse:
+		query = fmt.Sprintf(`
+			SELECT name
+			FROM system.columns
+			WHERE database = currentDatabase()
+			  AND table = '%s'
+			  AND is_in_primary_key = 1
+			ORDER BY position
+		`, tableName)
 	case config.DatabaseTypeDuckDB:
 		query = fmt.Sprintf(`
 			SELECT constraint_column_names
@@ -169,7 +179,7 @@ func GetTablePrimaryKey(source SourceDB, dbType, tableName string) ([]string, er
 		return nil, nil
 	}
 
-	rows, err := source.Query(query)
+	rows, err := source.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query primary key: %w", err)
 	}
@@ -192,7 +202,7 @@ func GetTablePrimaryKey(source SourceDB, dbType, tableName string) ([]string, er
 
 // GetTableIndexes attempts to retrieve index information for a table.
 // This is best-effort and may return an empty slice for unsupported database types.
-func GetTableIndexes(source SourceDB, dbType, tableName string) ([]IndexInfo, error) {
+func GetTableIndexes(ctx context.Context, source SourceDB, dbType, tableName string) ([]IndexInfo, error) {
 	var query string
 	switch strings.ToLower(dbType) {
 	case config.DatabaseTypeMySQL:
@@ -215,7 +225,7 @@ func GetTableIndexes(source SourceDB, dbType, tableName string) ([]IndexInfo, er
 		`, tableName)
 	case config.DatabaseTypeSQLite:
 		listQuery := fmt.Sprintf(`SELECT name, "unique" FROM pragma_index_list('%s')`, tableName)
-		rows, err := source.Query(listQuery)
+		rows, err := source.Query(ctx, listQuery)
 		if err != nil {
 			return nil, fmt.Errorf("failed to query indexes: %w", err)
 		}
@@ -236,7 +246,7 @@ func GetTableIndexes(source SourceDB, dbType, tableName string) ([]IndexInfo, er
 
 		for name, info := range indexMap {
 			colQuery := fmt.Sprintf(`SELECT name FROM pragma_index_info('%s')`, name)
-			colRows, err := source.Query(colQuery)
+			colRows, err := source.Query(ctx, colQuery)
 			if err != nil {
 				continue
 			}
@@ -264,11 +274,20 @@ func GetTableIndexes(source SourceDB, dbType, tableName string) ([]IndexInfo, er
 			  AND i.type > 0
 			ORDER BY i.name, ic.key_ordinal
 		`, tableName)
+	case config.DatabaseTypeClickHouse:
+		// Data-skipping indexes index an expression; it is reported as the single column.
+		query = fmt.Sprintf(`
+			SELECT name as index_name, expr as column_name, false as is_unique
+			FROM system.data_skipping_indices
+			WHERE database = currentDatabase()
+			  AND table = '%s'
+			ORDER BY name
+		`, tableName)
 	default:
 		return nil, nil
 	}
 
-	rows, err := source.Query(query)
+	rows, err := source.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query indexes: %w", err)
 	}
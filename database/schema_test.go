@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"testing"
 
 	"db-ferry/config"
@@ -60,7 +61,7 @@ func TestSyncSchemaAddsMissingColumns(t *testing.T) {
 		{Name: "age", DatabaseType: "INTEGER"},
 	}
 
-	if err := SyncSchema(pg, config.DatabaseTypePostgreSQL, "users", desired); err != nil {
+	if err := SyncSchema(context.Background(), pg, config.DatabaseTypePostgreSQL, "users", desired); err != nil {
 		t.Fatalf("SyncSchema() error = %v", err)
 	}
 
@@ -84,7 +85,7 @@ func TestSyncSchemaNoMissingColumns(t *testing.T) {
 		{Name: "name", DatabaseType: "VARCHAR"},
 	}
 
-	if err := SyncSchema(pg, config.DatabaseTypePostgreSQL, "users", desired); err != nil {
+	if err := SyncSchema(context.Background(), pg, config.DatabaseTypePostgreSQL, "users", desired); err != nil {
 		t.Fatalf("SyncSchema() error = %v", err)
 	}
 
@@ -100,7 +101,7 @@ func TestSyncSchemaHandlesGetColumnsError(t *testing.T) {
 	mock.ExpectQuery("SELECT column_name, data_type FROM information_schema.columns").
 		WillReturnError(sqlmock.ErrCancelled)
 
-	if err := SyncSchema(pg, config.DatabaseTypePostgreSQL, "users", []ColumnMetadata{{Name: "id"}}); err == nil {
+	if err := SyncSchema(context.Background(), pg, config.DatabaseTypePostgreSQL, "users", []ColumnMetadata{{Name: "id"}}); err == nil {
 		t.Fatalf("expected error when GetTableColumns fails")
 	}
 }
@@ -115,7 +116,7 @@ func TestSyncSchemaHandlesAlterError(t *testing.T) {
 	mock.ExpectExec(`ALTER TABLE "users" ADD COLUMN "id" BIGINT`).
 		WillReturnError(sqlmock.ErrCancelled)
 
-	if err := SyncSchema(pg, config.DatabaseTypePostgreSQL, "users", []ColumnMetadata{{Name: "id", DatabaseType: "INTEGER"}}); err == nil {
+	if err := SyncSchema(context.Background(), pg, config.DatabaseTypePostgreSQL, "users", []ColumnMetadata{{Name: "id", DatabaseType: "INTEGER"}}); err == nil {
 		t.Fatalf("expected error when ALTER TABLE fails")
 	}
 }
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -54,24 +55,37 @@ func (s *SQLiteDB) Close() error {
 	return nil
 }
 
-func (s *SQLiteDB) Exec(sql string) error {
-	_, err := s.db.Exec(sql)
+func (s *SQLiteDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := s.db.ExecContext(ctx, sql, args...)
 	return err
 }
 
-func (s *SQLiteDB) Query(sql string) (*sql.Rows, error) {
+func (s *SQLiteDB) beginLoadTx(ctx context.Context) (*LoadTx, error) {
+	return beginLoadTx(ctx, s.db, config.DatabaseTypeSQLite)
+}
+
+// SwapTable 在单个事务内删除旧表并将暂存表重命名为目标表
+func (s *SQLiteDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeSQLite, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	return execInTx(s.db, stmts)
+}
+
+func (s *SQLiteDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	log.Printf("Executing SQLite query: %s", sql)
-	rows, err := s.db.Query(sql)
+	rows, err := s.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute sqlite query: %w", err)
 	}
 	return rows, nil
 }
 
-func (s *SQLiteDB) GetRowCount(sql string) (int, error) {
+func (s *SQLiteDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s)", sql)
-	if err := s.db.QueryRow(countSQL).Scan(&count); err != nil {
+	if err := s.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to get row count: %w", err)
 	}
 	return count, nil
@@ -166,17 +180,17 @@ func (s *SQLiteDB) createTable(tableName string, columns []ColumnMetadata, dropE
 	return nil
 }
 
-func (s *SQLiteDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (s *SQLiteDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	if len(values) == 0 {
 		return nil
 	}
 
 	// Start transaction
-	tx, err := s.db.Begin()
+	tx, err := beginBatch(ctx, s.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
 	// Prepare insert statement
 	placeholders := make([]string, len(columns))
@@ -191,7 +205,7 @@ func (s *SQLiteDB) InsertData(tableName string, columns []ColumnMetadata, values
 		strings.Join(columnNames, "\", \""),
 		strings.Join(placeholders, ", "))
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
@@ -199,21 +213,21 @@ func (s *SQLiteDB) InsertData(tableName string, columns []ColumnMetadata, values
 
 	// Insert data in batches
 	for _, row := range values {
-		_, err := stmt.Exec(row...)
+		_, err := stmt.ExecContext(ctx, row...)
 		if err != nil {
 			return fmt.Errorf("failed to insert row: %w", err)
 		}
 	}
 
 	// Commit transaction
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (s *SQLiteDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+func (s *SQLiteDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	if len(values) == 0 {
 		return nil
 	}
@@ -255,25 +269,25 @@ func (s *SQLiteDB) UpsertData(tableName string, columns []ColumnMetadata, values
 		action,
 	)
 
-	tx, err := s.db.Begin()
+	tx, err := beginBatch(ctx, s.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare upsert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to upsert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 
@@ -22,17 +23,17 @@ func TestSQLiteBasicFlow(t *testing.T) {
 	if err := s.CreateTable("users", cols); err != nil {
 		t.Fatalf("CreateTable() error = %v", err)
 	}
-	if err := s.InsertData("users", cols, [][]any{{1, "a"}, {2, "b"}}); err != nil {
+	if err := s.InsertData(context.Background(), "users", cols, [][]any{{1, "a"}, {2, "b"}}); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
 
-	rows, err := s.Query(`SELECT id, name FROM "users" ORDER BY id`)
+	rows, err := s.Query(context.Background(), `SELECT id, name FROM "users" ORDER BY id`)
 	if err != nil {
 		t.Fatalf("Query() error = %v", err)
 	}
 	rows.Close()
 
-	cnt, err := s.GetRowCount(`SELECT id FROM "users"`)
+	cnt, err := s.GetRowCount(context.Background(), `SELECT id FROM "users"`)
 	if err != nil {
 		t.Fatalf("GetRowCount() error = %v", err)
 	}
@@ -52,7 +53,7 @@ func TestSQLiteBasicFlow(t *testing.T) {
 		{Name: "id", DatabaseType: "INTEGER", Transform: "? + 1"},
 		{Name: "name", DatabaseType: "VARCHAR", Transform: "UPPER(?)"},
 	}
-	if err := s.InsertData("users", transformCols, [][]any{{1, "alice"}}); err != nil {
+	if err := s.InsertData(context.Background(), "users", transformCols, [][]any{{1, "alice"}}); err != nil {
 		t.Fatalf("InsertData() with transform error = %v", err)
 	}
 	var upperName string
@@ -83,10 +84,10 @@ func TestSQLiteEnsureTableAndUpsert(t *testing.T) {
 	if _, err := s.db.Exec(`CREATE UNIQUE INDEX idx_users_id ON "users"("id")`); err != nil {
 		t.Fatalf("create unique index error = %v", err)
 	}
-	if err := s.InsertData("users", cols, [][]any{{1, "old"}}); err != nil {
+	if err := s.InsertData(context.Background(), "users", cols, [][]any{{1, "old"}}); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
-	if err := s.UpsertData("users", cols, [][]any{{1, "new"}, {2, "b"}}, []string{"id"}); err != nil {
+	if err := s.UpsertData(context.Background(), "users", cols, [][]any{{1, "new"}, {2, "b"}}, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() error = %v", err)
 	}
 
@@ -99,6 +100,48 @@ func TestSQLiteEnsureTableAndUpsert(t *testing.T) {
 	}
 }
 
+func TestSQLiteLoadTxRollsBackFailedBatchOnly(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sqlite.db")
+	s, err := NewSQLiteDB(dbPath, 0, 0, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteDB() error = %v", err)
+	}
+	defer s.Close()
+
+	if _, err := s.db.Exec(`CREATE TABLE "users" ("id" INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatalf("create table error = %v", err)
+	}
+	cols := []ColumnMetadata{{Name: "id", DatabaseType: "INTEGER"}}
+
+	ltx, ctx, err := BeginLoadTx(context.Background(), s)
+	if err != nil {
+		t.Fatalf("BeginLoadTx() error = %v", err)
+	}
+	if !ltx.Savepoints() {
+		t.Fatalf("expected sqlite load transaction to support savepoints")
+	}
+	if err := s.InsertData(ctx, "users", cols, [][]any{{1}, {2}}); err != nil {
+		t.Fatalf("InsertData() error = %v", err)
+	}
+	if err := s.InsertData(ctx, "users", cols, [][]any{{3}, {1}}); err == nil {
+		t.Fatalf("expected duplicate key error")
+	}
+	if err := s.InsertData(ctx, "users", cols, [][]any{{3}}); err != nil {
+		t.Fatalf("InsertData() after failed batch error = %v", err)
+	}
+	if err := ltx.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+
+	cnt, err := s.GetTableRowCount("users")
+	if err != nil {
+		t.Fatalf("GetTableRowCount() error = %v", err)
+	}
+	if cnt != 3 {
+		t.Fatalf("GetTableRowCount() = %d, want 3", cnt)
+	}
+}
+
 func TestSQLiteGetTableColumns(t *testing.T) {
 	dbPath := filepath.Join(t.TempDir(), "sqlite.db")
 	s, err := NewSQLiteDB(dbPath, 0, 0, "")
@@ -174,12 +217,12 @@ func TestSQLiteQueryAndCountErrors(t *testing.T) {
 	}
 	defer s.Close()
 
-	_, err = s.Query("SELECT * FROM nonexistent")
+	_, err = s.Query(context.Background(), "SELECT * FROM nonexistent")
 	if err == nil {
 		t.Fatalf("expected query error for missing table")
 	}
 
-	_, err = s.GetRowCount("SELECT * FROM nonexistent")
+	_, err = s.GetRowCount(context.Background(), "SELECT * FROM nonexistent")
 	if err == nil {
 		t.Fatalf("expected GetRowCount error for missing table")
 	}
@@ -193,7 +236,7 @@ func TestSQLiteExec(t *testing.T) {
 	}
 	defer s.Close()
 
-	if err := s.Exec(`CREATE TABLE t (id INTEGER PRIMARY KEY)`); err != nil {
+	if err := s.Exec(context.Background(), `CREATE TABLE t (id INTEGER PRIMARY KEY)`); err != nil {
 		t.Fatalf("Exec() error = %v", err)
 	}
 }
@@ -271,10 +314,10 @@ func TestSQLiteEdgeCases(t *testing.T) {
 	if err := s.CreateTable("bad", nil); err == nil {
 		t.Fatalf("expected create table error when columns are empty")
 	}
-	if err := s.InsertData("missing", nil, nil); err != nil {
+	if err := s.InsertData(context.Background(), "missing", nil, nil); err != nil {
 		t.Fatalf("InsertData() with empty values should be nil, got %v", err)
 	}
-	if err := s.UpsertData("missing", nil, [][]any{{1}}, nil); err == nil {
+	if err := s.UpsertData(context.Background(), "missing", nil, [][]any{{1}}, nil); err == nil {
 		t.Fatalf("expected merge_keys required error")
 	}
 
@@ -295,3 +338,83 @@ func TestSQLiteEdgeCases(t *testing.T) {
 		}
 	}
 }
+
+func TestSQLiteSwapTable(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sqlite.db")
+	s, err := NewSQLiteDB(dbPath, 0, 0, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteDB() error = %v", err)
+	}
+	defer s.Close()
+
+	cols := []ColumnMetadata{{Name: "id", DatabaseType: "INTEGER"}}
+	staging := StagingTableName("users")
+	if err := s.CreateTable(staging, cols); err != nil {
+		t.Fatalf("CreateTable() error = %v", err)
+	}
+	if err := s.InsertData(context.Background(), staging, cols, [][]any{{1}, {2}}); err != nil {
+		t.Fatalf("InsertData() error = %v", err)
+	}
+
+	indexes := []config.IndexConfig{{Name: "idx_users_id", Columns: []string{"id"}}}
+	// First swap: no live table exists yet.
+	if err := s.SwapTable(staging, "users", nil); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+	if err := s.CreateIndexes("users", indexes); err != nil {
+		t.Fatalf("CreateIndexes() error = %v", err)
+	}
+
+	// Second swap: the staging index is built while the live index exists and
+	// takes over its name in the swap.
+	if err := s.CreateTable(staging, cols); err != nil {
+		t.Fatalf("CreateTable() error = %v", err)
+	}
+	if err := s.InsertData(context.Background(), staging, cols, [][]any{{3}}); err != nil {
+		t.Fatalf("InsertData() error = %v", err)
+	}
+	if err := s.CreateIndexes(staging, StagingIndexes(config.DatabaseTypeSQLite, indexes)); err != nil {
+		t.Fatalf("CreateIndexes(staging) error = %v", err)
+	}
+	if err := s.SwapTable(staging, "users", indexes); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+	rows, err := s.Query(context.Background(), `SELECT name, tbl_name FROM sqlite_master WHERE type = 'index'`)
+	if err != nil {
+		t.Fatalf("Query(sqlite_master) error = %v", err)
+	}
+	var indexNames []string
+	for rows.Next() {
+		var name, table string
+		if err := rows.Scan(&name, &table); err != nil {
+			t.Fatalf("Scan() error = %v", err)
+		}
+		indexNames = append(indexNames, name+"@"+table)
+	}
+	rows.Close()
+	if len(indexNames) != 1 || indexNames[0] != "idx_users_id@users" {
+		t.Fatalf("expected idx_users_id on users after swap, got %v", indexNames)
+	}
+
+	cnt, err := s.GetTableRowCount("users")
+	if err != nil {
+		t.Fatalf("GetTableRowCount() error = %v", err)
+	}
+	if cnt != 1 {
+		t.Fatalf("GetTableRowCount() = %d, want 1", cnt)
+	}
+	tables, err := s.GetTables()
+	if err != nil {
+		t.Fatalf("GetTables() error = %v", err)
+	}
+	if len(tables) != 1 || tables[0] != "users" {
+		t.Fatalf("expected only users table after swap, got %v", tables)
+	}
+
+	if err := s.SwapTable("missing", "users", nil); err == nil {
+		t.Fatalf("expected SwapTable() error for missing staging table")
+	}
+	if cnt, err := s.GetTableRowCount("users"); err != nil || cnt != 1 {
+		t.Fatalf("failed swap must keep live table, got count=%d err=%v", cnt, err)
+	}
+}
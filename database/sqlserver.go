@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -8,7 +9,7 @@ import (
 
 	"db-ferry/config"
 
-	_ "github.com/denisenkom/go-mssqldb"
+	mssql "github.com/denisenkom/go-mssqldb"
 )
 
 type SQLServerDB struct {
@@ -47,24 +48,37 @@ func (s *SQLServerDB) Close() error {
 	return nil
 }
 
-func (s *SQLServerDB) Exec(sql string) error {
-	_, err := s.db.Exec(sql)
+func (s *SQLServerDB) Exec(ctx context.Context, sql string, args ...any) error {
+	_, err := s.db.ExecContext(ctx, sql, args...)
 	return err
 }
 
-func (s *SQLServerDB) Query(sql string) (*sql.Rows, error) {
+func (s *SQLServerDB) beginLoadTx(ctx context.Context) (*LoadTx, error) {
+	return beginLoadTx(ctx, s.db, config.DatabaseTypeSQLServer)
+}
+
+// SwapTable 在单个事务内删除旧表并通过 sp_rename 切换暂存表
+func (s *SQLServerDB) SwapTable(stagingTable, tableName string, indexes []config.IndexConfig) error {
+	stmts, err := BuildSwapTableSQL(config.DatabaseTypeSQLServer, stagingTable, tableName, indexes)
+	if err != nil {
+		return err
+	}
+	return execInTx(s.db, stmts)
+}
+
+func (s *SQLServerDB) Query(ctx context.Context, sql string) (*sql.Rows, error) {
 	log.Printf("Executing SQL Server query: %s", sql)
-	rows, err := s.db.Query(sql)
+	rows, err := s.db.QueryContext(ctx, sql)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute sqlserver query: %w", err)
 	}
 	return rows, nil
 }
 
-func (s *SQLServerDB) GetRowCount(sql string) (int, error) {
+func (s *SQLServerDB) GetRowCount(ctx context.Context, sql string) (int, error) {
 	var count int
 	countSQL := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sql)
-	if err := s.db.QueryRow(countSQL).Scan(&count); err != nil {
+	if err := s.db.QueryRowContext(ctx, countSQL).Scan(&count); err != nil {
 		return 0, fmt.Errorf("failed to get row count: %w", err)
 	}
 	return count, nil
@@ -160,16 +174,16 @@ func (s *SQLServerDB) createTable(tableName string, columns []ColumnMetadata, dr
 	return nil
 }
 
-func (s *SQLServerDB) InsertData(tableName string, columns []ColumnMetadata, values [][]any) error {
+func (s *SQLServerDB) InsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
 	if len(values) == 0 {
 		return nil
 	}
 
-	tx, err := s.db.Begin()
+	tx, err := beginBatch(ctx, s.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
 	placeholders := make([]string, len(columns))
 	columnNames := make([]string, len(columns))
@@ -183,26 +197,63 @@ func (s *SQLServerDB) InsertData(tableName string, columns []ColumnMetadata, val
 		strings.Join(columnNames, ", "),
 		strings.Join(placeholders, ", "))
 
-	stmt, err := tx.Prepare(insertSQL)
+	stmt, err := tx.PrepareContext(ctx, insertSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare insert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to insert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// BulkInsertData 使用 TDS bulk copy 写入一个批次
+func (s *SQLServerDB) BulkInsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if hasColumnTransform(columns) {
+		return ErrBulkLoadUnsupported
+	}
+
+	tx, err := beginBatch(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	defer tx.rollback()
+
+	stmt, err := tx.PrepareContext(ctx, mssql.CopyIn(s.quoteIdentifier(tableName), mssql.BulkOptions{}, columnNames(columns)...))
+	if err != nil {
+		return fmt.Errorf("failed to prepare bulk copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, row := range values {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("failed to buffer bulk copy row: %w", err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush bulk copy: %w", err)
+	}
+
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func (s *SQLServerDB) UpsertData(tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
+func (s *SQLServerDB) UpsertData(ctx context.Context, tableName string, columns []ColumnMetadata, values [][]any, mergeKeys []string) error {
 	if len(values) == 0 {
 		return nil
 	}
@@ -253,25 +304,25 @@ func (s *SQLServerDB) UpsertData(tableName string, columns []ColumnMetadata, val
 		strings.Join(sourceRefs, ", "),
 	)
 
-	tx, err := s.db.Begin()
+	tx, err := beginBatch(ctx, s.db)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer tx.Rollback()
+	defer tx.rollback()
 
-	stmt, err := tx.Prepare(mergeSQL)
+	stmt, err := tx.PrepareContext(ctx, mergeSQL)
 	if err != nil {
 		return fmt.Errorf("failed to prepare upsert statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, row := range values {
-		if _, err := stmt.Exec(row...); err != nil {
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
 			return fmt.Errorf("failed to upsert row: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
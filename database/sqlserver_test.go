@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"regexp"
 	"testing"
@@ -52,7 +53,7 @@ func TestSQLServerInsertUpsertAndCount(t *testing.T) {
 	insertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	insertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := s.InsertData("users", cols, values); err != nil {
+	if err := s.InsertData(context.Background(), "users", cols, values); err != nil {
 		t.Fatalf("InsertData() error = %v", err)
 	}
 
@@ -62,7 +63,7 @@ func TestSQLServerInsertUpsertAndCount(t *testing.T) {
 	upsertPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	upsertPrep.ExpectExec().WithArgs(2, "b").WillReturnResult(sqlmock.NewResult(2, 1))
 	mock.ExpectCommit()
-	if err := s.UpsertData("users", cols, values, []string{"id"}); err != nil {
+	if err := s.UpsertData(context.Background(), "users", cols, values, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() error = %v", err)
 	}
 
@@ -75,7 +76,7 @@ func TestSQLServerInsertUpsertAndCount(t *testing.T) {
 	transformPrep := mock.ExpectPrepare(regexp.QuoteMeta(insertTransformSQL))
 	transformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := s.InsertData("users", transformCols, [][]any{{1, "a"}}); err != nil {
+	if err := s.InsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}); err != nil {
 		t.Fatalf("InsertData() with transform error = %v", err)
 	}
 
@@ -84,13 +85,13 @@ func TestSQLServerInsertUpsertAndCount(t *testing.T) {
 	upsertTransformPrep := mock.ExpectPrepare(regexp.QuoteMeta(upsertTransformSQL))
 	upsertTransformPrep.ExpectExec().WithArgs(1, "a").WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
-	if err := s.UpsertData("users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
+	if err := s.UpsertData(context.Background(), "users", transformCols, [][]any{{1, "a"}}, []string{"id"}); err != nil {
 		t.Fatalf("UpsertData() with transform error = %v", err)
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM (SELECT * FROM users) AS count_query`)).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
-	cnt, err := s.GetRowCount("SELECT * FROM users")
+	cnt, err := s.GetRowCount(context.Background(), "SELECT * FROM users")
 	if err != nil {
 		t.Fatalf("GetRowCount() error = %v", err)
 	}
@@ -110,7 +111,7 @@ func TestSQLServerInsertUpsertAndCount(t *testing.T) {
 
 	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1")).
 		WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
-	rows, err := s.Query("SELECT 1")
+	rows, err := s.Query(context.Background(), "SELECT 1")
 	if err != nil {
 		t.Fatalf("Query() error = %v", err)
 	}
@@ -158,7 +159,7 @@ func TestSQLServerExec(t *testing.T) {
 	s := &SQLServerDB{db: db}
 
 	mock.ExpectExec(regexp.QuoteMeta(`CREATE TABLE t (id INT)`)).WillReturnResult(sqlmock.NewResult(0, 0))
-	if err := s.Exec(`CREATE TABLE t (id INT)`); err != nil {
+	if err := s.Exec(context.Background(), `CREATE TABLE t (id INT)`); err != nil {
 		t.Fatalf("Exec() error = %v", err)
 	}
 
@@ -198,10 +199,10 @@ func TestSQLServerEdgeCasesAndTypeMapping(t *testing.T) {
 	if err := s.CreateTable("users", nil); err == nil {
 		t.Fatalf("expected CreateTable() error for empty columns")
 	}
-	if err := s.InsertData("users", nil, nil); err != nil {
+	if err := s.InsertData(context.Background(), "users", nil, nil); err != nil {
 		t.Fatalf("InsertData() empty should be nil, got %v", err)
 	}
-	if err := s.UpsertData("users", nil, [][]any{{1}}, nil); err == nil {
+	if err := s.UpsertData(context.Background(), "users", nil, [][]any{{1}}, nil); err == nil {
 		t.Fatalf("expected merge_keys required error")
 	}
 
@@ -224,3 +225,20 @@ func TestSQLServerEdgeCasesAndTypeMapping(t *testing.T) {
 		}
 	}
 }
+
+func TestSQLServerSwapTable(t *testing.T) {
+	db, mock := newSQLMock(t)
+	s := &SQLServerDB{db: db}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`IF OBJECT_ID(N'[users]', 'U') IS NOT NULL DROP TABLE [users]`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`EXEC sp_rename N'users__dbf_staging', N'users'`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	if err := s.SwapTable("users__dbf_staging", "users", []config.IndexConfig{{Name: "idx_id", Columns: []string{"id"}}}); err != nil {
+		t.Fatalf("SwapTable() error = %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("sqlmock expectations: %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+)
+
+// ErrStateConflict is returned by StateTable.Save when the stored document
+// changed since it was loaded.
+var ErrStateConflict = errors.New("state was modified concurrently")
+
+// ErrStateLocked is returned by StateTable.Lock when another owner holds an
+// unexpired lock.
+var ErrStateLocked = errors.New("state is locked by another owner")
+
+// stateVersionEmpty marks a row that holds no document yet. Oracle stores empty
+// strings as NULL, so an explicit marker keeps "version = ?" comparable.
+const stateVersionEmpty = "0"
+
+// StateTable stores state documents as rows of a table in a target database.
+// Each row carries a version token for compare-and-swap updates and a lock
+// owner with an expiry time.
+type StateTable struct {
+	dbType    string
+	tableName string
+	now       func() time.Time
+}
+
+// NewStateTable creates a state table accessor for the given database type.
+func NewStateTable(dbType, tableName string) *StateTable {
+	return &StateTable{dbType: dbType, tableName: tableName, now: time.Now}
+}
+
+// EnsureTable creates the state table if it does not exist.
+func (s *StateTable) EnsureTable(ctx context.Context, target TargetDB) error {
+	if err := target.Exec(ctx, s.buildCreateTableSQL()); err != nil {
+		return fmt.Errorf("failed to create state table %s: %w", s.tableName, err)
+	}
+	return nil
+}
+
+// Load returns the document stored under key and its version. A key without a
+// document yields nil data and an empty version.
+func (s *StateTable) Load(ctx context.Context, target TargetDB, key string) ([]byte, string, error) {
+	q := fmt.Sprintf("SELECT state_value, version FROM %s WHERE state_key = %s",
+		QuoteIdentifier(s.dbType, s.tableName), quoteDialectString(s.dbType, key))
+	rows, err := target.Query(ctx, q)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read state %s: %w", key, err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, "", rows.Err()
+	}
+	var value, version sql.NullString
+	if err := rows.Scan(&value, &version); err != nil {
+		return nil, "", fmt.Errorf("failed to scan state %s: %w", key, err)
+	}
+	if !version.Valid || version.String == stateVersionEmpty {
+		return nil, "", nil
+	}
+	return []byte(value.String), version.String, nil
+}
+
+// Save stores data under key when the stored version still equals version (an
+// empty version means no document may exist yet) and returns the new version.
+// It returns ErrStateConflict when another writer got there first.
+func (s *StateTable) Save(ctx context.Context, target TargetDB, key string, data []byte, version string) (string, error) {
+	if err := s.ensureRow(ctx, target, key); err != nil {
+		return "", err
+	}
+	if version == "" {
+		version = stateVersionEmpty
+	}
+	next, err := newStateVersion()
+	if err != nil {
+		return "", err
+	}
+
+	q := fmt.Sprintf("UPDATE %s SET state_value = %s, version = %s WHERE state_key = %s AND version = %s",
+		QuoteIdentifier(s.dbType, s.tableName),
+		BindPlaceholder(s.dbType, 1), BindPlaceholder(s.dbType, 2),
+		BindPlaceholder(s.dbType, 3), BindPlaceholder(s.dbType, 4))
+	if err := target.Exec(ctx, q, string(data), next, key, version); err != nil {
+		return "", fmt.Errorf("failed to write state %s: %w", key, err)
+	}
+
+	// Exec does not report affected rows; the update took effect only if the
+	// row now carries the version token generated above.
+	_, stored, err := s.Load(ctx, target, key)
+	if err != nil {
+		return "", err
+	}
+	if stored != next {
+		return "", fmt.Errorf("%w: %s", ErrStateConflict, key)
+	}
+	return next, nil
+}
+
+// Lock acquires or renews the lock on key for owner until ttl elapses. It
+// returns ErrStateLocked when a different owner holds an unexpired lock.
+func (s *StateTable) Lock(ctx context.Context, target TargetDB, key, owner string, ttl time.Duration) error {
+	if err := s.ensureRow(ctx, target, key); err != nil {
+		return err
+	}
+	now := s.now()
+	q := fmt.Sprintf("UPDATE %s SET lock_owner = %s, lock_expires = %s WHERE state_key = %s AND (lock_owner IS NULL OR lock_owner = '' OR lock_owner = %s OR lock_expires < %s)",
+		QuoteIdentifier(s.dbType, s.tableName),
+		BindPlaceholder(s.dbType, 1), BindPlaceholder(s.dbType, 2), BindPlaceholder(s.dbType, 3),
+		BindPlaceholder(s.dbType, 4), BindPlaceholder(s.dbType, 5))
+	if err := target.Exec(ctx, q, owner, now.Add(ttl).UnixMilli(), key, owner, now.UnixMilli()); err != nil {
+		return fmt.Errorf("failed to lock state %s: %w", key, err)
+	}
+
+	holder, expires, err := s.lockHolder(ctx, target, key)
+	if err != nil {
+		return err
+	}
+	if holder != owner {
+		return fmt.Errorf("%w: %s is held by %s until %s", ErrStateLocked, key, holder, time.UnixMilli(expires).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Unlock releases the lock on key if owner holds it.
+func (s *StateTable) Unlock(ctx context.Context, target TargetDB, key, owner string) error {
+	q := fmt.Sprintf("UPDATE %s SET lock_owner = NULL, lock_expires = 0 WHERE state_key = %s AND lock_owner = %s",
+		QuoteIdentifier(s.dbType, s.tableName), BindPlaceholder(s.dbType, 1), BindPlaceholder(s.dbType, 2))
+	if err := target.Exec(ctx, q, key, owner); err != nil {
+		return fmt.Errorf("failed to unlock state %s: %w", key, err)
+	}
+	return nil
+}
+
+// Holder returns the current lock owner of key and when its lock expires. An
+// unlocked key yields an empty owner.
+func (s *StateTable) Holder(ctx context.Context, target TargetDB, key string) (string, time.Time, error) {
+	holder, expires, err := s.lockHolder(ctx, target, key)
+	if err != nil || holder == "" {
+		return "", time.Time{}, err
+	}
+	return holder, time.UnixMilli(expires), nil
+}
+
+func (s *StateTable) lockHolder(ctx context.Context, target TargetDB, key string) (string, int64, error) {
+	q := fmt.Sprintf("SELECT lock_owner, lock_expires FROM %s WHERE state_key = %s",
+		QuoteIdentifier(s.dbType, s.tableName), quoteDialectString(s.dbType, key))
+	rows, err := target.Query(ctx, q)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read state lock %s: %w", key, err)
+	}
+	defer rows.Close()
+
+	var holder sql.NullString
+	var expires sql.NullInt64
+	if rows.Next() {
+		if err := rows.Scan(&holder, &expires); err != nil {
+			return "", 0, fmt.Errorf("failed to scan state lock %s: %w", key, err)
+		}
+	}
+	return holder.String, expires.Int64, rows.Err()
+}
+
+// ensureRow inserts an empty row for key. A concurrent insert of the same key
+// fails on the primary key, which is fine as long as the row exists afterwards.
+func (s *StateTable) ensureRow(ctx context.Context, target TargetDB, key string) error {
+	exists, err := s.rowExists(ctx, target, key)
+	if err != nil || exists {
+		return err
+	}
+	q := fmt.Sprintf("INSERT INTO %s (state_key, state_value, version, lock_expires) VALUES (%s, NULL, %s, 0)",
+		QuoteIdentifier(s.dbType, s.tableName), BindPlaceholder(s.dbType, 1), BindPlaceholder(s.dbType, 2))
+	insertErr := target.Exec(ctx, q, key, stateVersionEmpty)
+	if insertErr == nil {
+		return nil
+	}
+	if exists, err := s.rowExists(ctx, target, key); err == nil && exists {
+		return nil
+	}
+	return fmt.Errorf("failed to create state row %s: %w", key, insertErr)
+}
+
+func (s *StateTable) rowExists(ctx context.Context, target TargetDB, key string) (bool, error) {
+	q := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE state_key = %s",
+		QuoteIdentifier(s.dbType, s.tableName), quoteDialectString(s.dbType, key))
+	rows, err := target.Query(ctx, q)
+	if err != nil {
+		return false, fmt.Errorf("failed to read state %s: %w", key, err)
+	}
+	defer rows.Close()
+
+	var count int64
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return false, fmt.Errorf("failed to scan state %s: %w", key, err)
+		}
+	}
+	return count > 0, rows.Err()
+}
+
+func newStateVersion() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate state version: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *StateTable) buildCreateTableSQL() string {
+	table := QuoteIdentifier(s.dbType, s.tableName)
+	switch strings.ToLower(s.dbType) {
+	case config.DatabaseTypePostgreSQL:
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			state_key VARCHAR(512) PRIMARY KEY,
+			state_value TEXT,
+			version VARCHAR(64),
+			lock_owner VARCHAR(255),
+			lock_expires BIGINT
+		)`, table)
+	case config.DatabaseTypeMySQL:
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			state_key VARCHAR(512) PRIMARY KEY,
+			state_value LONGTEXT,
+			version VARCHAR(64),
+			lock_owner VARCHAR(255),
+			lock_expires BIGINT
+		)`, table)
+	case config.DatabaseTypeOracle:
+		return fmt.Sprintf(`BEGIN
+			EXECUTE IMMEDIATE 'CREATE TABLE %s (
+				state_key VARCHAR2(512) PRIMARY KEY,
+				state_value CLOB,
+				version VARCHAR2(64),
+				lock_owner VARCHAR2(255),
+				lock_expires NUMBER(19,0)
+			)';
+		EXCEPTION
+			WHEN OTHERS THEN
+				IF SQLCODE != -955 THEN
+					RAISE;
+				END IF;
+		END;`, table)
+	case config.DatabaseTypeSQLServer:
+		literal := strings.ReplaceAll(table, "'", "''")
+		return fmt.Sprintf(`IF OBJECT_ID(N'%s', 'U') IS NULL
+		CREATE TABLE %s (
+			state_key NVARCHAR(450) PRIMARY KEY,
+			state_value NVARCHAR(MAX),
+			version NVARCHAR(64),
+			lock_owner NVARCHAR(255),
+			lock_expires BIGINT
+		)`, literal, table)
+	case config.DatabaseTypeDuckDB:
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			state_key VARCHAR PRIMARY KEY,
+			state_value VARCHAR,
+			version VARCHAR,
+			lock_owner VARCHAR,
+			lock_expires BIGINT
+		)`, table)
+	default:
+		// SQLite and fallback
+		return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			state_key TEXT PRIMARY KEY,
+			state_value TEXT,
+			version TEXT,
+			lock_owner TEXT,
+			lock_expires INTEGER
+		)`, table)
+	}
+}
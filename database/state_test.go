@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+)
+
+func TestStateTable_SaveCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLiteTarget(t)
+	table := NewStateTable(config.DatabaseTypeSQLite, "ferry_state")
+	if err := table.EnsureTable(ctx, db); err != nil {
+		t.Fatalf("EnsureTable() error = %v", err)
+	}
+
+	data, version, err := table.Load(ctx, db, "a.json")
+	if err != nil || data != nil || version != "" {
+		t.Fatalf("Load() on empty table = %q, %q, %v", data, version, err)
+	}
+
+	v1, err := table.Save(ctx, db, "a.json", []byte(`{"tasks":{"k":"1"}}`), "")
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := table.Save(ctx, db, "a.json", []byte(`{"tasks":{"k":"x"}}`), ""); !errors.Is(err, ErrStateConflict) {
+		t.Fatalf("Save() with stale empty version error = %v, want ErrStateConflict", err)
+	}
+	v2, err := table.Save(ctx, db, "a.json", []byte(`{"tasks":{"k":"2"}}`), v1)
+	if err != nil || v2 == v1 {
+		t.Fatalf("Save() = %q, %v", v2, err)
+	}
+	if _, err := table.Save(ctx, db, "a.json", []byte(`{}`), v1); !errors.Is(err, ErrStateConflict) {
+		t.Fatalf("Save() with stale version error = %v, want ErrStateConflict", err)
+	}
+
+	data, version, err = table.Load(ctx, db, "a.json")
+	if err != nil || string(data) != `{"tasks":{"k":"2"}}` || version != v2 {
+		t.Fatalf("Load() = %q, %q, %v", data, version, err)
+	}
+}
+
+func TestStateTable_Lock(t *testing.T) {
+	ctx := context.Background()
+	db := newTestSQLiteTarget(t)
+	table := NewStateTable(config.DatabaseTypeSQLite, "ferry_state")
+	if err := table.EnsureTable(ctx, db); err != nil {
+		t.Fatalf("EnsureTable() error = %v", err)
+	}
+
+	if err := table.Lock(ctx, db, "a.json", "pod-1", time.Minute); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if err := table.Lock(ctx, db, "a.json", "pod-1", time.Minute); err != nil {
+		t.Fatalf("Lock() renewal error = %v", err)
+	}
+	err := table.Lock(ctx, db, "a.json", "pod-2", time.Minute)
+	if !errors.Is(err, ErrStateLocked) || !strings.Contains(err.Error(), "pod-1") {
+		t.Fatalf("Lock() by second owner error = %v, want ErrStateLocked held by pod-1", err)
+	}
+
+	// 锁过期后可被其他 owner 接管
+	table.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if err := table.Lock(ctx, db, "a.json", "pod-2", time.Minute); err != nil {
+		t.Fatalf("Lock() after expiry error = %v", err)
+	}
+	if err := table.Unlock(ctx, db, "a.json", "pod-1"); err != nil {
+		t.Fatalf("Unlock() by non-holder error = %v", err)
+	}
+	if err := table.Lock(ctx, db, "a.json", "pod-1", time.Minute); !errors.Is(err, ErrStateLocked) {
+		t.Fatalf("Lock() error = %v, want lock still held by pod-2", err)
+	}
+	if err := table.Unlock(ctx, db, "a.json", "pod-2"); err != nil {
+		t.Fatalf("Unlock() error = %v", err)
+	}
+	if err := table.Lock(ctx, db, "a.json", "pod-1", time.Minute); err != nil {
+		t.Fatalf("Lock() after unlock error = %v", err)
+	}
+}
+
+func TestStateTable_BuildCreateTableSQL(t *testing.T) {
+	tests := []struct {
+		dbType string
+		want   string
+	}{
+		{config.DatabaseTypePostgreSQL, "state_value TEXT"},
+		{config.DatabaseTypeMySQL, "state_value LONGTEXT"},
+		{config.DatabaseTypeOracle, "EXECUTE IMMEDIATE"},
+		{config.DatabaseTypeSQLServer, "IF OBJECT_ID"},
+		{config.DatabaseTypeDuckDB, "state_key VARCHAR PRIMARY KEY"},
+		{config.DatabaseTypeSQLite, "state_key TEXT PRIMARY KEY"},
+	}
+	for _, tt := range tests {
+		got := NewStateTable(tt.dbType, "db_ferry_state").buildCreateTableSQL()
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("buildCreateTableSQL(%s) = %s, want it to contain %q", tt.dbType, got, tt.want)
+		}
+	}
+}
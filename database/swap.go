@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"db-ferry/config"
+)
+
+const (
+	stagingTableSuffix = "__dbf_staging"
+	retiredTableSuffix = "__dbf_old"
+)
+
+// StagingTableName returns the name of the staging table used by replace mode.
+func StagingTableName(tableName string) string {
+	return tableName + stagingTableSuffix
+}
+
+func retiredTableName(tableName string) string {
+	return tableName + retiredTableSuffix
+}
+
+// IndexesSurviveSwap reports whether indexes built on the staging table are
+// carried over by SwapTable. DuckDB refuses to rename a table that has
+// indexes, so its indexes are created on the live table after the swap.
+func IndexesSurviveSwap(dbType string) bool {
+	return !strings.EqualFold(dbType, config.DatabaseTypeDuckDB)
+}
+
+// StagingIndexName returns the name an index has while it lives on the staging
+// table. PostgreSQL, SQLite and Oracle scope index names to the schema, so the
+// staging copy gets a temporary name that SwapTable renames once the old table
+// is gone. MySQL, SQL Server and ClickHouse scope index names to the table.
+func StagingIndexName(dbType, name string) string {
+	switch strings.ToLower(dbType) {
+	case config.DatabaseTypeMySQL, config.DatabaseTypeSQLServer, config.DatabaseTypeClickHouse:
+		return name
+	default:
+		return name + stagingTableSuffix
+	}
+}
+
+// StagingIndexes returns indexes renamed for creation on the staging table.
+func StagingIndexes(dbType string, indexes []config.IndexConfig) []config.IndexConfig {
+	staged := make([]config.IndexConfig, len(indexes))
+	for i, idx := range indexes {
+		staged[i] = idx
+		staged[i].Name = StagingIndexName(dbType, idx.Name)
+	}
+	return staged
+}
+
+// BuildSwapTableSQL returns the statements that replace tableName with stagingTable
+// and give the staging indexes their final names. For SQLite, PostgreSQL, DuckDB and
+// SQL Server the statements are meant to run in a single transaction. MySQL and
+// Oracle swap by renaming, see their SwapTable methods. ClickHouse has no
+// transactional DDL and swaps with a single atomic EXCHANGE TABLES.
+func BuildSwapTableSQL(dbType, stagingTable, tableName string, indexes []config.IndexConfig) ([]string, error) {
+	qStaging := QuoteIdentifier(dbType, stagingTable)
+	qTable := QuoteIdentifier(dbType, tableName)
+	var stmts []string
+	switch strings.ToLower(dbType) {
+	case config.DatabaseTypeMySQL:
+		// The empty placeholder lets the same RENAME run on the first load.
+		qRetired := QuoteIdentifier(dbType, retiredTableName(tableName))
+		return []string{
+			BuildDropTableSQL(dbType, retiredTableName(tableName)),
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s LIKE %s", qTable, qStaging),
+			fmt.Sprintf("RENAME TABLE %s TO %s, %s TO %s", qTable, qRetired, qStaging, qTable),
+			BuildDropTableSQL(dbType, retiredTableName(tableName)),
+		}, nil
+	case config.DatabaseTypeClickHouse:
+		// Like MySQL, an empty copy of the staging table stands in on the first load.
+		return []string{
+			fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS %s", qTable, qStaging),
+			fmt.Sprintf("EXCHANGE TABLES %s AND %s", qStaging, qTable),
+			BuildDropTableSQL(dbType, stagingTable),
+		}, nil
+	case config.DatabaseTypeSQLServer:
+		return []string{
+			BuildDropTableSQL(dbType, tableName),
+			fmt.Sprintf("EXEC sp_rename N%s, N%s", quoteSQLString(stagingTable), quoteSQLString(tableName)),
+		}, nil
+	case config.DatabaseTypeOracle:
+		qRetired := QuoteIdentifier(dbType, retiredTableName(tableName))
+		stmts = []string{
+			BuildDropTableSQL(dbType, retiredTableName(tableName)),
+			fmt.Sprintf("BEGIN EXECUTE IMMEDIATE 'ALTER TABLE %s RENAME TO %s'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -942 THEN RAISE; END IF; END;", qTable, qRetired),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", qStaging, qTable),
+			BuildDropTableSQL(dbType, retiredTableName(tableName)),
+		}
+	default:
+		stmts = []string{
+			BuildDropTableSQL(dbType, tableName),
+			fmt.Sprintf("ALTER TABLE %s RENAME TO %s", qStaging, qTable),
+		}
+	}
+
+	if !IndexesSurviveSwap(dbType) {
+		return stmts, nil
+	}
+	for _, idx := range indexes {
+		qStaged := QuoteIdentifier(dbType, StagingIndexName(dbType, idx.Name))
+		if !strings.EqualFold(dbType, config.DatabaseTypeSQLite) {
+			stmts = append(stmts, fmt.Sprintf("ALTER INDEX %s RENAME TO %s", qStaged, QuoteIdentifier(dbType, idx.Name)))
+			continue
+		}
+		// SQLite cannot rename an index; rebuild it under its final name inside
+		// the swap transaction.
+		createSQL, err := BuildCreateIndexSQL(dbType, tableName, idx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build index '%s': %w", idx.Name, err)
+		}
+		stmts = append(stmts, "DROP INDEX IF EXISTS "+qStaged, createSQL)
+	}
+	return stmts, nil
+}
+
+// execInTx runs stmts in a single transaction, rolling back on the first failure.
+func execInTx(db *sql.DB, stmts []string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("failed to execute %q: %w", stmt, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
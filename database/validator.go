@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"crypto/md5"
 	"database/sql"
 	"fmt"
@@ -15,28 +16,28 @@ import (
 
 // queryer abstracts the minimal query capability needed for validation.
 type queryer interface {
-	Query(sql string) (*sql.Rows, error)
+	Query(ctx context.Context, sql string) (*sql.Rows, error)
 }
 
 // ValidateTask runs the configured validation strategy for a completed task.
-func ValidateTask(sourceDB, targetDB queryer, sourceDBType, targetDBType string,
+func ValidateTask(ctx context.Context, sourceDB, targetDB queryer, sourceDBType, targetDBType string,
 	task config.TaskConfig, columns []ColumnMetadata, sourceWrappedSQL string,
 	processedRows int, targetCountBefore int, recorder metrics.Recorder) error {
 
 	switch task.Validate {
 	case config.TaskValidateRowCount:
-		return validateRowCount(targetDB, task, processedRows, targetCountBefore, recorder)
+		return validateRowCount(ctx, targetDB, task, processedRows, targetCountBefore, recorder)
 	case config.TaskValidateChecksum:
-		return validateChecksum(sourceDB, targetDB, sourceDBType, targetDBType, task, columns, sourceWrappedSQL, recorder)
+		return validateChecksum(ctx, sourceDB, targetDB, sourceDBType, targetDBType, task, columns, sourceWrappedSQL, recorder)
 	case config.TaskValidateSample:
-		return validateSample(sourceDB, targetDB, sourceDBType, targetDBType, task, columns, sourceWrappedSQL, recorder)
+		return validateSample(ctx, sourceDB, targetDB, sourceDBType, targetDBType, task, columns, sourceWrappedSQL, recorder)
 	default:
 		return nil
 	}
 }
 
-func validateRowCount(targetDB queryer, task config.TaskConfig, processedRows, targetCountBefore int, recorder metrics.Recorder) error {
-	targetCountAfter, err := getTableRowCount(targetDB, task.TableName, task.TargetDB)
+func validateRowCount(ctx context.Context, targetDB queryer, task config.TaskConfig, processedRows, targetCountBefore int, recorder metrics.Recorder) error {
+	targetCountAfter, err := getTableRowCount(ctx, targetDB, task.TableName, task.TargetDB)
 	if err != nil {
 		return fmt.Errorf("failed to get target row count after insert: %w", err)
 	}
@@ -48,7 +49,7 @@ func validateRowCount(targetDB queryer, task config.TaskConfig, processedRows, t
 	return nil
 }
 
-func getTableRowCount(q queryer, tableName, dbType string) (int, error) {
+func getTableRowCount(ctx context.Context, q queryer, tableName, dbType string) (int, error) {
 	var countSQL string
 	switch dbType {
 	case config.DatabaseTypeSQLite:
@@ -65,7 +66,7 @@ func getTableRowCount(q queryer, tableName, dbType string) (int, error) {
 		countSQL = fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName)
 	}
 	var count int
-	rows, err := q.Query(countSQL)
+	rows, err := q.Query(ctx, countSQL)
 	if err != nil {
 		return 0, err
 	}
@@ -79,16 +80,16 @@ func getTableRowCount(q queryer, tableName, dbType string) (int, error) {
 	return count, rows.Err()
 }
 
-func validateChecksum(sourceDB, targetDB queryer, sourceDBType, targetDBType string,
+func validateChecksum(ctx context.Context, sourceDB, targetDB queryer, sourceDBType, targetDBType string,
 	task config.TaskConfig, columns []ColumnMetadata, sourceWrappedSQL string, recorder metrics.Recorder) error {
 
-	sourceChecksum, err := computeChecksum(sourceDB, sourceDBType, columns, sourceWrappedSQL)
+	sourceChecksum, err := computeChecksum(ctx, sourceDB, sourceDBType, columns, sourceWrappedSQL)
 	if err != nil {
 		return fmt.Errorf("checksum validation failed computing source checksum: %w", err)
 	}
 
 	targetWrappedSQL := fmt.Sprintf("SELECT * FROM %s", QuoteTableName(task.TableName, targetDBType))
-	targetChecksum, err := computeChecksum(targetDB, targetDBType, columns, targetWrappedSQL)
+	targetChecksum, err := computeChecksum(ctx, targetDB, targetDBType, columns, targetWrappedSQL)
 	if err != nil {
 		return fmt.Errorf("checksum validation failed computing target checksum: %w", err)
 	}
@@ -101,13 +102,13 @@ func validateChecksum(sourceDB, targetDB queryer, sourceDBType, targetDBType str
 	return nil
 }
 
-func computeChecksum(q queryer, dbType string, columns []ColumnMetadata, wrappedSQL string) (string, error) {
+func computeChecksum(ctx context.Context, q queryer, dbType string, columns []ColumnMetadata, wrappedSQL string) (string, error) {
 	if dbType == config.DatabaseTypeSQLite {
-		return computeSQLiteChecksum(q, columns, wrappedSQL)
+		return computeSQLiteChecksum(ctx, q, columns, wrappedSQL)
 	}
 
 	sqlText := buildChecksumSQL(dbType, columns, wrappedSQL)
-	rows, err := q.Query(sqlText)
+	rows, err := q.Query(ctx, sqlText)
 	if err != nil {
 		return "", err
 	}
@@ -139,6 +140,11 @@ func buildChecksumSQL(dbType string, columns []ColumnMetadata, wrappedSQL string
 	switch dbType {
 	case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeDuckDB:
 		hashExpr = fmt.Sprintf("MD5(CONCAT_WS('|', %s))", strings.Join(colNames, ", "))
+	case config.DatabaseTypeClickHouse:
+		for i, col := range colNames {
+			colNames[i] = fmt.Sprintf("toString(%s)", col)
+		}
+		hashExpr = fmt.Sprintf("lower(hex(MD5(concatWithSeparator('|', %s))))", strings.Join(colNames, ", "))
 	case config.DatabaseTypeSQLServer:
 		concatExpr := strings.Join(colNames, " + '|' + ")
 		hashExpr = fmt.Sprintf("CONVERT(VARCHAR(32), HASHBYTES('MD5', %s), 2)", concatExpr)
@@ -161,13 +167,13 @@ func buildChecksumSQL(dbType string, columns []ColumnMetadata, wrappedSQL string
 	return fmt.Sprintf("SELECT %s FROM %s ORDER BY 1", hashExpr, fromClause)
 }
 
-func computeSQLiteChecksum(q queryer, columns []ColumnMetadata, wrappedSQL string) (string, error) {
+func computeSQLiteChecksum(ctx context.Context, q queryer, columns []ColumnMetadata, wrappedSQL string) (string, error) {
 	colNames := make([]string, len(columns))
 	for i, col := range columns {
 		colNames[i] = fmt.Sprintf("\"%s\"", col.Name)
 	}
 	sqlText := fmt.Sprintf("SELECT %s FROM (%s)", strings.Join(colNames, ", "), wrappedSQL)
-	rows, err := q.Query(sqlText)
+	rows, err := q.Query(ctx, sqlText)
 	if err != nil {
 		return "", err
 	}
@@ -213,7 +219,7 @@ func aggregateHashes(hashes []string) string {
 	return fmt.Sprintf("%x", md5.Sum([]byte(strings.Join(hashes, "\n"))))
 }
 
-func validateSample(sourceDB, targetDB queryer, sourceDBType, targetDBType string,
+func validateSample(ctx context.Context, sourceDB, targetDB queryer, sourceDBType, targetDBType string,
 	task config.TaskConfig, columns []ColumnMetadata, sourceWrappedSQL string, recorder metrics.Recorder) error {
 
 	sampleSize := task.ValidateSampleSize
@@ -222,7 +228,7 @@ func validateSample(sourceDB, targetDB queryer, sourceDBType, targetDBType strin
 	}
 
 	sqlText := buildSampleSQL(sourceDBType, sourceWrappedSQL, sampleSize)
-	rows, err := sourceDB.Query(sqlText)
+	rows, err := sourceDB.Query(ctx, sqlText)
 	if err != nil {
 		return fmt.Errorf("sample validation failed querying source: %w", err)
 	}
@@ -247,7 +253,7 @@ func validateSample(sourceDB, targetDB queryer, sourceDBType, targetDBType strin
 			}
 		}
 
-		targetRow, found, err := findTargetRow(targetDB, targetDBType, task.TableName, columns, values)
+		targetRow, found, err := findTargetRow(ctx, targetDB, targetDBType, task.TableName, columns, values)
 		if err != nil {
 			return fmt.Errorf("sample validation failed querying target for row %d: %w", rowNum, err)
 		}
@@ -292,6 +298,8 @@ func buildSampleSQL(dbType, wrappedSQL string, limit int) string {
 		return fmt.Sprintf("SELECT * FROM (%s) AS t ORDER BY RAND() LIMIT %d", wrappedSQL, limit)
 	case config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLite, config.DatabaseTypeDuckDB:
 		return fmt.Sprintf("SELECT * FROM (%s) AS t ORDER BY RANDOM() LIMIT %d", wrappedSQL, limit)
+	case config.DatabaseTypeClickHouse:
+		return fmt.Sprintf("SELECT * FROM (%s) AS t ORDER BY rand() LIMIT %d", wrappedSQL, limit)
 	case config.DatabaseTypeSQLServer:
 		return fmt.Sprintf("SELECT TOP %d * FROM (%s) AS t ORDER BY NEWID()", limit, wrappedSQL)
 	case config.DatabaseTypeOracle:
@@ -301,9 +309,9 @@ func buildSampleSQL(dbType, wrappedSQL string, limit int) string {
 	}
 }
 
-func findTargetRow(targetDB queryer, dbType, tableName string, columns []ColumnMetadata, values []any) ([]any, bool, error) {
+func findTargetRow(ctx context.Context, targetDB queryer, dbType, tableName string, columns []ColumnMetadata, values []any) ([]any, bool, error) {
 	sqlText := buildMatchSQL(dbType, tableName, columns, values)
-	rows, err := targetDB.Query(sqlText)
+	rows, err := targetDB.Query(ctx, sqlText)
 	if err != nil {
 		return nil, false, err
 	}
@@ -348,7 +356,7 @@ func buildMatchSQL(dbType, tableName string, columns []ColumnMetadata, values []
 
 	var limitClause string
 	switch dbType {
-	case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLite, config.DatabaseTypeDuckDB:
+	case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLite, config.DatabaseTypeDuckDB, config.DatabaseTypeClickHouse:
 		limitClause = "LIMIT 1"
 	case config.DatabaseTypeSQLServer:
 		limitClause = "TOP 1"
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -19,7 +20,7 @@ type mockQueryer struct {
 	db *sql.DB
 }
 
-func (m *mockQueryer) Query(sql string) (*sql.Rows, error) {
+func (m *mockQueryer) Query(_ context.Context, sql string) (*sql.Rows, error) {
 	return m.db.Query(sql)
 }
 
@@ -265,7 +266,7 @@ func TestGetTableRowCount(t *testing.T) {
 	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM `users`").
 		WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(42))
 
-	cnt, err := getTableRowCount(mq, "users", config.DatabaseTypeMySQL)
+	cnt, err := getTableRowCount(context.Background(), mq, "users", config.DatabaseTypeMySQL)
 	if err != nil {
 		t.Fatalf("getTableRowCount() error = %v", err)
 	}
@@ -277,7 +278,7 @@ func TestGetTableRowCount(t *testing.T) {
 	mq2, mock2 := newMockQueryer(t)
 	mock2.ExpectQuery(`SELECT COUNT\(\*\) FROM "USERS"`).
 		WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(7))
-	cnt, err = getTableRowCount(mq2, "users", config.DatabaseTypeOracle)
+	cnt, err = getTableRowCount(context.Background(), mq2, "users", config.DatabaseTypeOracle)
 	if err != nil {
 		t.Fatalf("getTableRowCount(oracle) error = %v", err)
 	}
@@ -292,7 +293,7 @@ func TestValidateRowCount(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(12))
 
 	task := config.TaskConfig{TableName: "users", TargetDB: "db1"}
-	if err := validateRowCount(mq, task, 10, 2, metrics.NewNoopRecorder()); err != nil {
+	if err := validateRowCount(context.Background(), mq, task, 10, 2, metrics.NewNoopRecorder()); err != nil {
 		t.Fatalf("validateRowCount() error = %v", err)
 	}
 
@@ -300,7 +301,7 @@ func TestValidateRowCount(t *testing.T) {
 	mq2, mock2 := newMockQueryer(t)
 	mock2.ExpectQuery("SELECT COUNT\\(\\*\\) FROM").
 		WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(15))
-	if err := validateRowCount(mq2, task, 10, 2, metrics.NewNoopRecorder()); err == nil {
+	if err := validateRowCount(context.Background(), mq2, task, 10, 2, metrics.NewNoopRecorder()); err == nil {
 		t.Fatal("expected row count mismatch error")
 	}
 }
@@ -314,7 +315,7 @@ func TestValidateChecksumWithMock(t *testing.T) {
 	tgtMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"h"}).AddRow("abc"))
 
 	task := config.TaskConfig{TableName: "users"}
-	err := validateChecksum(src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder())
+	err := validateChecksum(context.Background(), src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder())
 	if err != nil {
 		t.Fatalf("validateChecksum() error = %v", err)
 	}
@@ -324,7 +325,7 @@ func TestValidateChecksumWithMock(t *testing.T) {
 	tgt2, tgtMock2 := newMockQueryer(t)
 	srcMock2.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"h"}).AddRow("abc"))
 	tgtMock2.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"h"}).AddRow("def"))
-	err = validateChecksum(src2, tgt2, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder())
+	err = validateChecksum(context.Background(), src2, tgt2, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder())
 	if err == nil {
 		t.Fatal("expected checksum mismatch error")
 	}
@@ -345,7 +346,7 @@ func TestComputeSQLiteChecksum(t *testing.T) {
 
 	mq := &mockQueryer{db: db}
 	columns := []ColumnMetadata{{Name: "id"}, {Name: "name"}}
-	checksum, err := computeSQLiteChecksum(mq, columns, "SELECT * FROM t")
+	checksum, err := computeSQLiteChecksum(context.Background(), mq, columns, "SELECT * FROM t")
 	if err != nil {
 		t.Fatalf("computeSQLiteChecksum() error = %v", err)
 	}
@@ -354,7 +355,7 @@ func TestComputeSQLiteChecksum(t *testing.T) {
 	}
 
 	// deterministic
-	checksum2, err := computeSQLiteChecksum(mq, columns, "SELECT * FROM t")
+	checksum2, err := computeSQLiteChecksum(context.Background(), mq, columns, "SELECT * FROM t")
 	if err != nil {
 		t.Fatalf("computeSQLiteChecksum() second error = %v", err)
 	}
@@ -376,7 +377,7 @@ func TestValidateSampleWithMock(t *testing.T) {
 	)
 
 	task := config.TaskConfig{TableName: "users", ValidateSampleSize: 1}
-	err := validateSample(src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder())
+	err := validateSample(context.Background(), src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder())
 	if err != nil {
 		t.Fatalf("validateSample() error = %v", err)
 	}
@@ -395,7 +396,7 @@ func TestValidateSampleRowNotFound(t *testing.T) {
 	)
 
 	task := config.TaskConfig{TableName: "users", ValidateSampleSize: 1}
-	if err := validateSample(src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder()); err == nil {
+	if err := validateSample(context.Background(), src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder()); err == nil {
 		t.Fatal("expected not found error")
 	}
 }
@@ -413,7 +414,7 @@ func TestValidateSampleValueMismatch(t *testing.T) {
 	)
 
 	task := config.TaskConfig{TableName: "users", ValidateSampleSize: 1}
-	if err := validateSample(src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder()); err == nil {
+	if err := validateSample(context.Background(), src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", metrics.NewNoopRecorder()); err == nil {
 		t.Fatal("expected mismatch error")
 	}
 }
@@ -423,19 +424,19 @@ func TestValidateTask(t *testing.T) {
 	tgtMock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM").WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(12))
 
 	task := config.TaskConfig{Validate: config.TaskValidateRowCount, TableName: "users", TargetDB: "db1"}
-	if err := ValidateTask(nil, tgt, "", config.DatabaseTypeMySQL, task, nil, "", 10, 2, metrics.NewNoopRecorder()); err != nil {
+	if err := ValidateTask(context.Background(), nil, tgt, "", config.DatabaseTypeMySQL, task, nil, "", 10, 2, metrics.NewNoopRecorder()); err != nil {
 		t.Fatalf("ValidateTask(row_count) error = %v", err)
 	}
 
 	// none -> nil
 	task.Validate = config.TaskValidateNone
-	if err := ValidateTask(nil, nil, "", "", task, nil, "", 0, 0, metrics.NewNoopRecorder()); err != nil {
+	if err := ValidateTask(context.Background(), nil, nil, "", "", task, nil, "", 0, 0, metrics.NewNoopRecorder()); err != nil {
 		t.Fatalf("ValidateTask(none) error = %v", err)
 	}
 
 	// unknown -> nil
 	task.Validate = "unknown"
-	if err := ValidateTask(nil, nil, "", "", task, nil, "", 0, 0, metrics.NewNoopRecorder()); err != nil {
+	if err := ValidateTask(context.Background(), nil, nil, "", "", task, nil, "", 0, 0, metrics.NewNoopRecorder()); err != nil {
 		t.Fatalf("ValidateTask(unknown) error = %v", err)
 	}
 }
@@ -449,7 +450,7 @@ func TestValidateTaskChecksumAndSample(t *testing.T) {
 	tgtMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows([]string{"h"}).AddRow("abc"))
 
 	task := config.TaskConfig{Validate: config.TaskValidateChecksum, TableName: "users"}
-	if err := ValidateTask(src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", 0, 0, metrics.NewNoopRecorder()); err != nil {
+	if err := ValidateTask(context.Background(), src, tgt, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", 0, 0, metrics.NewNoopRecorder()); err != nil {
 		t.Fatalf("ValidateTask(checksum) error = %v", err)
 	}
 
@@ -460,7 +461,7 @@ func TestValidateTaskChecksumAndSample(t *testing.T) {
 
 	task.Validate = config.TaskValidateSample
 	task.ValidateSampleSize = 1
-	if err := ValidateTask(src2, tgt2, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", 0, 0, metrics.NewNoopRecorder()); err != nil {
+	if err := ValidateTask(context.Background(), src2, tgt2, config.DatabaseTypeMySQL, config.DatabaseTypeMySQL, task, columns, "SELECT 1", 0, 0, metrics.NewNoopRecorder()); err != nil {
 		t.Fatalf("ValidateTask(sample) error = %v", err)
 	}
 }
@@ -480,7 +481,7 @@ func TestComputeChecksumWithRealSQLite(t *testing.T) {
 
 	mq := &mockQueryer{db: db}
 	columns := []ColumnMetadata{{Name: "id"}, {Name: "name"}}
-	checksum, err := computeChecksum(mq, config.DatabaseTypeSQLite, columns, "SELECT * FROM t")
+	checksum, err := computeChecksum(context.Background(), mq, config.DatabaseTypeSQLite, columns, "SELECT * FROM t")
 	if err != nil {
 		t.Fatalf("computeChecksum(sqlite) error = %v", err)
 	}
@@ -504,7 +505,7 @@ func TestFindTargetRow(t *testing.T) {
 
 	mq := &mockQueryer{db: db}
 	columns := []ColumnMetadata{{Name: "id", DatabaseType: "INTEGER"}, {Name: "name", DatabaseType: "TEXT"}}
-	row, found, err := findTargetRow(mq, config.DatabaseTypeSQLite, "users", columns, []any{1, "alice"})
+	row, found, err := findTargetRow(context.Background(), mq, config.DatabaseTypeSQLite, "users", columns, []any{1, "alice"})
 	if err != nil {
 		t.Fatalf("findTargetRow() error = %v", err)
 	}
@@ -516,7 +517,7 @@ func TestFindTargetRow(t *testing.T) {
 	}
 
 	// not found
-	_, found, err = findTargetRow(mq, config.DatabaseTypeSQLite, "users", columns, []any{999, "nobody"})
+	_, found, err = findTargetRow(context.Background(), mq, config.DatabaseTypeSQLite, "users", columns, []any{999, "nobody"})
 	if err != nil {
 		t.Fatalf("findTargetRow(not found) error = %v", err)
 	}
@@ -528,7 +529,7 @@ func TestFindTargetRow(t *testing.T) {
 func TestGetTableRowCountNoRow(t *testing.T) {
 	mq, mock := newMockQueryer(t)
 	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM").WillReturnRows(sqlmock.NewRows([]string{"cnt"}))
-	_, err := getTableRowCount(mq, "users", config.DatabaseTypeMySQL)
+	_, err := getTableRowCount(context.Background(), mq, "users", config.DatabaseTypeMySQL)
 	if err == nil {
 		t.Fatal("expected no row error")
 	}
@@ -537,7 +538,7 @@ func TestGetTableRowCountNoRow(t *testing.T) {
 func TestGetTableRowCountQueryError(t *testing.T) {
 	mq, mock := newMockQueryer(t)
 	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM").WillReturnError(fmt.Errorf("boom"))
-	_, err := getTableRowCount(mq, "users", config.DatabaseTypeMySQL)
+	_, err := getTableRowCount(context.Background(), mq, "users", config.DatabaseTypeMySQL)
 	if err == nil {
 		t.Fatal("expected query error")
 	}
@@ -547,7 +548,7 @@ func TestValidateRowCountQueryError(t *testing.T) {
 	mq, mock := newMockQueryer(t)
 	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM").WillReturnError(fmt.Errorf("boom"))
 	task := config.TaskConfig{TableName: "users", TargetDB: "db1"}
-	if err := validateRowCount(mq, task, 10, 2, metrics.NewNoopRecorder()); err == nil {
+	if err := validateRowCount(context.Background(), mq, task, 10, 2, metrics.NewNoopRecorder()); err == nil {
 		t.Fatal("expected error")
 	}
 }
@@ -0,0 +1,311 @@
+package diff
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// checksumNull stands in for NULL when rows are rendered to text for hashing.
+const checksumNull = "#null#"
+
+// bucketSum is the row count and checksum of one key range on one side.
+type bucketSum struct {
+	count int64
+	sum   string
+}
+
+// checksumRun splits the first diff key into ranges, compares per-range checksums
+// computed by each database, and only merge-compares the rows of ranges that differ.
+type checksumRun struct {
+	source, target diffSide
+	columns        []database.ColumnMetadata
+	keys           []string
+	where          string
+	buckets        int64
+	bucketRows     int64
+	// hashed is false when either side cannot hash rows in SQL; differing buckets are then compared row by row
+	hashed      bool
+	sourceHash  string
+	targetHash  string
+	result      *Result
+	sourceTotal int
+	targetTotal int
+}
+
+func checksumDiff(ctx context.Context, source, target diffSide, columns []database.ColumnMetadata, keys []string, opts Options) (*Result, error) {
+	run := &checksumRun{
+		source:     source,
+		target:     target,
+		columns:    columns,
+		keys:       keys,
+		where:      opts.Where,
+		buckets:    int64(opts.Buckets),
+		bucketRows: int64(opts.BucketRows),
+		result:     newResult(),
+	}
+	run.sourceHash = rowHashSumExpr(source.dbType, columns)
+	run.targetHash = rowHashSumExpr(target.dbType, columns)
+	run.hashed = run.sourceHash != "" && run.targetHash != ""
+	if !run.hashed {
+		log.Printf("Warning: checksums are not available for %s/%s; differing buckets are compared row by row", source.dbType, target.dbType)
+	}
+
+	lo, hi, ok, err := run.keyBounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if err := run.compareRange(ctx, lo, hi, true); err != nil {
+			return nil, err
+		}
+	}
+
+	run.result.Summary.SourceTotal = run.sourceTotal
+	run.result.Summary.TargetTotal = run.targetTotal
+	run.result.summarize()
+	return run.result, nil
+}
+
+// keyBounds returns the half-open range [lo, hi) covering the first diff key on both sides.
+func (r *checksumRun) keyBounds(ctx context.Context) (int64, int64, bool, error) {
+	var lo, hi int64
+	found := false
+	for _, side := range []diffSide{r.source, r.target} {
+		key := database.QuoteIdentifier(side.dbType, r.keys[0])
+		sqlText := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", key, key, side.relation)
+		if r.where != "" {
+			sqlText += " WHERE " + r.where
+		}
+		var minValue, maxValue any
+		if err := queryRow(ctx, side.db, sqlText, &minValue, &maxValue); err != nil {
+			return 0, 0, false, fmt.Errorf("failed to read %s key range: %w", side.name, err)
+		}
+		if minValue == nil || maxValue == nil {
+			continue
+		}
+		minKey, okMin := toInt64(minValue)
+		maxKey, okMax := toInt64(maxValue)
+		if !okMin || !okMax {
+			return 0, 0, false, fmt.Errorf("checksum mode requires an integer first diff key, %s has %v..%v for %q; use -mode stream", side.name, minValue, maxValue, r.keys[0])
+		}
+		if !found || minKey < lo {
+			lo = minKey
+		}
+		if !found || maxKey > hi {
+			hi = maxKey
+		}
+		found = true
+	}
+	if !found {
+		return 0, 0, false, nil
+	}
+	if hi == math.MaxInt64 {
+		return 0, 0, false, fmt.Errorf("diff key %q reaches the maximum int64 value; use -mode stream", r.keys[0])
+	}
+	return lo, hi + 1, true, nil
+}
+
+// compareRange splits [lo, hi) into buckets and drills into the ones whose checksums differ.
+func (r *checksumRun) compareRange(ctx context.Context, lo, hi int64, top bool) error {
+	width := (hi - lo + r.buckets - 1) / r.buckets
+	if width < 1 {
+		width = 1
+	}
+	for start := lo; start < hi; start += width {
+		end := start + width
+		if end > hi {
+			end = hi
+		}
+		src, tgt, err := r.bucketSums(ctx, start, end)
+		if err != nil {
+			return err
+		}
+		if top {
+			r.sourceTotal += int(src.count)
+			r.targetTotal += int(tgt.count)
+		}
+		if src.count == tgt.count && (src.count == 0 || (r.hashed && src.sum == tgt.sum)) {
+			continue
+		}
+		if !r.hashed || end-start <= 1 || max(src.count, tgt.count) <= r.bucketRows {
+			sourceWhere := combineWhere(r.where, r.rangeCondition(r.source.dbType, start, end))
+			targetWhere := combineWhere(r.where, r.rangeCondition(r.target.dbType, start, end))
+			if _, _, err := mergeRange(ctx, r.source, r.target, r.columns, r.keys, sourceWhere, targetWhere, 0, r.result); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.compareRange(ctx, start, end, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bucketSums reads the count and checksum of one key range from both sides concurrently.
+func (r *checksumRun) bucketSums(ctx context.Context, lo, hi int64) (bucketSum, bucketSum, error) {
+	type sumResult struct {
+		sum bucketSum
+		err error
+	}
+	targetCh := make(chan sumResult, 1)
+	go func() {
+		sum, err := r.bucketSum(ctx, r.target, r.targetHash, lo, hi)
+		targetCh <- sumResult{sum, err}
+	}()
+	src, err := r.bucketSum(ctx, r.source, r.sourceHash, lo, hi)
+	tgt := <-targetCh
+	if err != nil {
+		return bucketSum{}, bucketSum{}, err
+	}
+	if tgt.err != nil {
+		return bucketSum{}, bucketSum{}, tgt.err
+	}
+	return src, tgt.sum, nil
+}
+
+func (r *checksumRun) bucketSum(ctx context.Context, side diffSide, hashExpr string, lo, hi int64) (bucketSum, error) {
+	where := combineWhere(r.where, r.rangeCondition(side.dbType, lo, hi))
+	var countValue, sumValue any
+	if r.hashed {
+		sqlText := fmt.Sprintf("SELECT COUNT(*), %s FROM %s WHERE %s", hashExpr, side.relation, where)
+		if err := queryRow(ctx, side.db, sqlText, &countValue, &sumValue); err != nil {
+			return bucketSum{}, fmt.Errorf("failed to checksum %s rows: %w", side.name, err)
+		}
+	} else {
+		sqlText := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", side.relation, where)
+		if err := queryRow(ctx, side.db, sqlText, &countValue); err != nil {
+			return bucketSum{}, fmt.Errorf("failed to count %s rows: %w", side.name, err)
+		}
+	}
+	count, ok := toInt64(countValue)
+	if !ok {
+		return bucketSum{}, fmt.Errorf("unexpected %s row count %v", side.name, countValue)
+	}
+	return bucketSum{count: count, sum: numericText(sumValue)}, nil
+}
+
+func (r *checksumRun) rangeCondition(dbType string, lo, hi int64) string {
+	key := database.QuoteIdentifier(dbType, r.keys[0])
+	return fmt.Sprintf("%s >= %d AND %s < %d", key, lo, key, hi)
+}
+
+// rowHashSumExpr returns an aggregate summing a 32-bit MD5 prefix of every row rendered as text,
+// or "" when the dialect has no MD5 function. The rendering is the same across dialects only
+// for values whose text form matches (integers, text, most dates); other rows still compare
+// correctly, their buckets simply always drill down.
+func rowHashSumExpr(dbType string, columns []database.ColumnMetadata) string {
+	parts := make([]string, len(columns))
+	switch dbType {
+	case config.DatabaseTypePostgreSQL:
+		for i, col := range columns {
+			parts[i] = fmt.Sprintf("COALESCE(CAST(%s AS TEXT), '%s')", database.QuoteIdentifier(dbType, col.Name), checksumNull)
+		}
+		return fmt.Sprintf("SUM(('x' || substr(md5(%s), 1, 8))::bit(32)::bigint)", strings.Join(parts, " || '|' || "))
+	case config.DatabaseTypeMySQL:
+		for i, col := range columns {
+			parts[i] = fmt.Sprintf("COALESCE(CAST(%s AS CHAR), '%s')", database.QuoteIdentifier(dbType, col.Name), checksumNull)
+		}
+		return fmt.Sprintf("SUM(CAST(CONV(SUBSTRING(MD5(CONCAT_WS('|', %s)), 1, 8), 16, 10) AS UNSIGNED))", strings.Join(parts, ", "))
+	case config.DatabaseTypeSQLServer:
+		for i, col := range columns {
+			parts[i] = fmt.Sprintf("COALESCE(CAST(%s AS VARCHAR(MAX)), '%s')", database.QuoteIdentifier(dbType, col.Name), checksumNull)
+		}
+		return fmt.Sprintf("SUM(CAST(CAST(SUBSTRING(HASHBYTES('MD5', %s), 1, 4) AS BINARY(4)) AS BIGINT))", strings.Join(parts, " + '|' + "))
+	case config.DatabaseTypeOracle:
+		for i, col := range columns {
+			parts[i] = fmt.Sprintf("NVL(TO_CHAR(%s), '%s')", database.QuoteIdentifier(dbType, col.Name), checksumNull)
+		}
+		return fmt.Sprintf("SUM(TO_NUMBER(SUBSTR(RAWTOHEX(STANDARD_HASH(%s, 'MD5')), 1, 8), 'XXXXXXXX'))", strings.Join(parts, " || '|' || "))
+	case config.DatabaseTypeClickHouse:
+		for i, col := range columns {
+			parts[i] = fmt.Sprintf("ifNull(toString(%s), '%s')", database.QuoteIdentifier(dbType, col.Name), checksumNull)
+		}
+		// MD5 returns raw bytes; reverse the first four so the little-endian reinterpret reads them big-endian.
+		return fmt.Sprintf("sum(toUInt64(reinterpretAsUInt32(reverse(substring(MD5(concatWithSeparator('|', %s)), 1, 4)))))", strings.Join(parts, ", "))
+	case config.DatabaseTypeDuckDB:
+		for i, col := range columns {
+			parts[i] = fmt.Sprintf("COALESCE(CAST(%s AS VARCHAR), '%s')", database.QuoteIdentifier(dbType, col.Name), checksumNull)
+		}
+		return fmt.Sprintf("SUM(CAST(('0x' || substr(md5(%s), 1, 8)) AS BIGINT))", strings.Join(parts, " || '|' || "))
+	default:
+		return ""
+	}
+}
+
+// queryRow scans the single row returned by an aggregate query.
+func queryRow(ctx context.Context, db querier, sqlText string, dest ...any) error {
+	rows, err := db.Query(ctx, sqlText)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	case uint64:
+		if n > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case float64:
+		if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(n), true
+	case *big.Int:
+		if !n.IsInt64() {
+			return 0, false
+		}
+		return n.Int64(), true
+	case []byte:
+		i, err := strconv.ParseInt(strings.TrimSpace(string(n)), 10, 64)
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// numericText renders an aggregate result so sums from different drivers compare as text.
+func numericText(v any) string {
+	switch n := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return strings.TrimSpace(string(n))
+	case *big.Int:
+		return n.String()
+	case float64:
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", n)
+	}
+}
@@ -1,6 +1,7 @@
 package diff
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
@@ -15,6 +16,21 @@ import (
 	"db-ferry/database"
 )
 
+// Diff modes.
+const (
+	// ModeMemory loads the source side into memory keyed by the diff keys.
+	ModeMemory = "memory"
+	// ModeStream reads both sides ordered by the diff keys and merge-compares them.
+	ModeStream = "stream"
+	// ModeChecksum compares checksums of key ranges and only reads rows of ranges that differ.
+	ModeChecksum = "checksum"
+)
+
+const (
+	defaultBuckets    = 32
+	defaultBucketRows = 10000
+)
+
 // Options configures the diff command.
 type Options struct {
 	TaskName string
@@ -23,6 +39,19 @@ type Options struct {
 	Where    string
 	Limit    int
 	Keys     []string
+	Mode     string
+	// Buckets is the number of key ranges each checksum level splits into.
+	Buckets int
+	// BucketRows is the size below which a differing bucket is compared row by row instead of split again.
+	BucketRows int
+	// Fix writes a SQL repair script for the target instead of the diff report.
+	Fix bool
+	// Apply repairs the target directly after writing the diff report.
+	Apply bool
+	// DryRun, with Apply, writes the repair script instead of changing the target.
+	DryRun bool
+	// Version is recorded in the history table for applied repairs.
+	Version string
 }
 
 // Row is a single row represented as column-name -> value.
@@ -54,10 +83,41 @@ type Result struct {
 }
 
 // Run executes the diff command for a single task.
-func Run(cfg *config.Config, opts Options, stdout io.Writer) error {
+func Run(ctx context.Context, cfg *config.Config, opts Options, stdout io.Writer) error {
 	if opts.TaskName == "" {
 		return fmt.Errorf("-task is required")
 	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = ModeMemory
+	case ModeMemory, ModeStream, ModeChecksum:
+	default:
+		return fmt.Errorf("unsupported diff mode %q (expected memory, stream or checksum)", opts.Mode)
+	}
+	if opts.Fix && opts.Apply {
+		return fmt.Errorf("-fix and -apply cannot be used together; use -apply -dry-run to preview the repair")
+	}
+	if opts.DryRun && !opts.Apply {
+		return fmt.Errorf("-dry-run requires -apply")
+	}
+	if (opts.Fix || opts.Apply) && opts.Limit > 0 {
+		// A truncated comparison would report target rows outside the window as extra rows.
+		return fmt.Errorf("-limit cannot be combined with -fix or -apply")
+	}
+	if opts.Mode == ModeChecksum {
+		if opts.Limit > 0 {
+			return fmt.Errorf("-limit is not supported with -mode checksum")
+		}
+		if opts.Buckets == 0 {
+			opts.Buckets = defaultBuckets
+		}
+		if opts.Buckets < 2 {
+			return fmt.Errorf("-buckets must be >= 2")
+		}
+		if opts.BucketRows <= 0 {
+			opts.BucketRows = defaultBucketRows
+		}
+	}
 
 	var task config.TaskConfig
 	found := false
@@ -71,6 +131,10 @@ func Run(cfg *config.Config, opts Options, stdout io.Writer) error {
 	if !found {
 		return fmt.Errorf("task %q not found in configuration", opts.TaskName)
 	}
+	if (opts.Fix || opts.Apply) && (len(task.Masking) > 0 || task.Plugin.Engine != "") {
+		// 修复语句取自原始源行，会把未脱敏或未经插件转换的值写入目标；随机脱敏规则也无法在比对前复现
+		return fmt.Errorf("-fix and -apply are not supported for task %q because it uses masking or a plugin", task.TableName)
+	}
 
 	sourceDBCfg, ok := cfg.GetDatabase(task.SourceDB)
 	if !ok {
@@ -98,8 +162,9 @@ func Run(cfg *config.Config, opts Options, stdout io.Writer) error {
 	}
 
 	// Extract column metadata from source query.
-	sourceSQL := buildLimitedSQL(task.SQL, sourceDBCfg.Type, opts.Where, opts.Limit)
-	rows, err := sourceDB.Query(sourceSQL)
+	sourceType := sourceDBCfg.SQLDialect()
+	sourceSQL := buildLimitedSQL(task.SQL, sourceType, opts.Where, opts.Limit)
+	rows, err := sourceDB.Query(ctx, sourceSQL)
 	if err != nil {
 		return fmt.Errorf("failed to query source: %w", err)
 	}
@@ -114,21 +179,52 @@ func Run(cfg *config.Config, opts Options, stdout io.Writer) error {
 		return err
 	}
 
-	sourceMap, sourceTotal, err := loadSourceData(sourceDB, sourceSQL, columnsMeta, keys)
-	if err != nil {
-		return fmt.Errorf("failed to load source data: %w", err)
+	targetType := targetDBCfg.SQLDialect()
+	source := diffSide{name: "source", db: sourceDB, dbType: sourceType, relation: sourceRelation(task.SQL, sourceType)}
+	target := diffSide{name: "target", db: targetDB, dbType: targetType, relation: database.QuoteTableName(task.TableName, targetType)}
+
+	var result *Result
+	switch opts.Mode {
+	case ModeStream:
+		result, err = streamDiff(ctx, source, target, columnsMeta, keys, opts.Where, opts.Limit)
+		if err != nil {
+			return fmt.Errorf("failed to stream diff: %w", err)
+		}
+	case ModeChecksum:
+		result, err = checksumDiff(ctx, source, target, columnsMeta, keys, opts)
+		if err != nil {
+			return fmt.Errorf("failed to run checksum diff: %w", err)
+		}
+	default:
+		sourceMap, sourceTotal, err := loadSourceData(ctx, sourceDB, sourceSQL, columnsMeta, keys)
+		if err != nil {
+			return fmt.Errorf("failed to load source data: %w", err)
+		}
+
+		targetSQL := buildTargetSQL(task.TableName, targetType, opts.Where, opts.Limit)
+		result, err = compareWithTarget(ctx, targetDB, targetSQL, columnsMeta, keys, sourceMap, sourceTotal)
+		if err != nil {
+			return fmt.Errorf("failed to compare with target: %w", err)
+		}
 	}
 
-	targetSQL := buildTargetSQL(task.TableName, targetDBCfg.Type, opts.Where, opts.Limit)
-	result, err := compareWithTarget(targetDB, targetSQL, columnsMeta, keys, sourceMap, sourceTotal)
-	if err != nil {
-		return fmt.Errorf("failed to compare with target: %w", err)
+	plan := repairPlan{dbType: targetType, table: task.TableName, columns: columnsMeta, keys: keys, result: result}
+	if opts.Fix || opts.DryRun {
+		if err := writeOutput(opts.Output, stdout, plan.writeScript); err != nil {
+			return fmt.Errorf("failed to write repair script: %w", err)
+		}
+		return nil
 	}
 
 	if err := writeReport(result, columnsMeta, opts.Format, opts.Output, stdout); err != nil {
 		return fmt.Errorf("failed to write report: %w", err)
 	}
 
+	if opts.Apply {
+		if err := applyRepair(ctx, cfg, task, targetDB, plan, opts.Version); err != nil {
+			return fmt.Errorf("failed to apply repair: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -182,8 +278,8 @@ func extractColumnMetadata(rows *sql.Rows) ([]database.ColumnMetadata, error) {
 	return metadata, nil
 }
 
-func loadSourceData(sourceDB database.SourceDB, sqlText string, columns []database.ColumnMetadata, keys []string) (map[string]Row, int, error) {
-	rows, err := sourceDB.Query(sqlText)
+func loadSourceData(ctx context.Context, sourceDB database.SourceDB, sqlText string, columns []database.ColumnMetadata, keys []string) (map[string]Row, int, error) {
+	rows, err := sourceDB.Query(ctx, sqlText)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -240,18 +336,14 @@ func rowKey(row Row, keys []string) string {
 	return strings.Join(parts, "\x00|\x00")
 }
 
-func compareWithTarget(targetDB database.TargetDB, sqlText string, columns []database.ColumnMetadata, keys []string, sourceMap map[string]Row, sourceTotal int) (*Result, error) {
-	rows, err := targetDB.Query(sqlText)
+func compareWithTarget(ctx context.Context, targetDB database.TargetDB, sqlText string, columns []database.ColumnMetadata, keys []string, sourceMap map[string]Row, sourceTotal int) (*Result, error) {
+	rows, err := targetDB.Query(ctx, sqlText)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	result := &Result{
-		SourceOnly: make([]Row, 0),
-		TargetOnly: make([]Row, 0),
-		Mismatch:   make([]MismatchRow, 0),
-	}
+	result := newResult()
 
 	targetTotal := 0
 	for rows.Next() {
@@ -268,23 +360,8 @@ func compareWithTarget(targetDB database.TargetDB, sqlText string, columns []dat
 		}
 		delete(sourceMap, k)
 
-		var diffCols []string
-		for _, col := range columns {
-			if !database.CompareValues(sourceRow[col.Name], row[col.Name]) {
-				diffCols = append(diffCols, col.Name)
-			}
-		}
-		if len(diffCols) > 0 {
-			keyMap := make(map[string]any, len(keys))
-			for _, key := range keys {
-				keyMap[key] = row[key]
-			}
-			result.Mismatch = append(result.Mismatch, MismatchRow{
-				Key:      keyMap,
-				Source:   sourceRow,
-				Target:   row,
-				DiffCols: diffCols,
-			})
+		if mismatch, ok := compareRows(sourceRow, row, columns, keys); ok {
+			result.Mismatch = append(result.Mismatch, mismatch)
 		}
 	}
 	if err := rows.Err(); err != nil {
@@ -305,46 +382,64 @@ func compareWithTarget(targetDB database.TargetDB, sqlText string, columns []dat
 	return result, nil
 }
 
-func buildLimitedSQL(baseSQL, dbType, where string, limit int) string {
-	sqlText := fmt.Sprintf("SELECT * FROM (%s)", trimSQL(baseSQL))
-	if dbType != config.DatabaseTypeOracle {
-		sqlText += " AS t"
-	} else {
-		sqlText += " t"
+// compareRows reports whether two rows with the same key differ, and in which columns.
+func compareRows(sourceRow, targetRow Row, columns []database.ColumnMetadata, keys []string) (MismatchRow, bool) {
+	var diffCols []string
+	for _, col := range columns {
+		if !database.CompareValues(sourceRow[col.Name], targetRow[col.Name]) {
+			diffCols = append(diffCols, col.Name)
+		}
 	}
-	if where != "" {
-		sqlText += " WHERE " + where
+	if len(diffCols) == 0 {
+		return MismatchRow{}, false
 	}
-	if limit > 0 {
-		switch dbType {
-		case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLite, config.DatabaseTypeDuckDB:
-			sqlText += fmt.Sprintf(" LIMIT %d", limit)
-		case config.DatabaseTypeSQLServer:
-			// For SQL Server, TOP must go before SELECT. We rewrite the whole query.
-			prefix := "TOP " + fmt.Sprintf("%d ", limit)
-			// Replace the leading SELECT * with SELECT TOP N *
-			// This is a best-effort approach for wrapped subqueries.
-			sqlText = strings.Replace(sqlText, "SELECT *", "SELECT "+prefix+"*", 1)
-		case config.DatabaseTypeOracle:
-			sqlText += fmt.Sprintf(" FETCH FIRST %d ROWS ONLY", limit)
-		default:
-			sqlText += fmt.Sprintf(" LIMIT %d", limit)
-		}
+	keyMap := make(map[string]any, len(keys))
+	for _, key := range keys {
+		keyMap[key] = targetRow[key]
 	}
-	return sqlText
+	return MismatchRow{
+		Key:      keyMap,
+		Source:   sourceRow,
+		Target:   targetRow,
+		DiffCols: diffCols,
+	}, true
+}
+
+func buildLimitedSQL(baseSQL, dbType, where string, limit int) string {
+	return buildSelectSQL(sourceRelation(baseSQL, dbType), dbType, where, nil, limit)
 }
 
 func buildTargetSQL(tableName, dbType, where string, limit int) string {
-	quotedTable := database.QuoteTableName(tableName, dbType)
-	sqlText := fmt.Sprintf("SELECT * FROM %s", quotedTable)
+	return buildSelectSQL(database.QuoteTableName(tableName, dbType), dbType, where, nil, limit)
+}
+
+// sourceRelation wraps the task SQL as a derived table so WHERE/ORDER BY/LIMIT can be applied to it.
+func sourceRelation(baseSQL, dbType string) string {
+	if dbType == config.DatabaseTypeOracle {
+		return fmt.Sprintf("(%s) t", trimSQL(baseSQL))
+	}
+	return fmt.Sprintf("(%s) AS t", trimSQL(baseSQL))
+}
+
+// buildSelectSQL selects every column from relation, optionally ordered by the given columns.
+func buildSelectSQL(relation, dbType, where string, orderBy []string, limit int) string {
+	sqlText := "SELECT * FROM " + relation
 	if where != "" {
 		sqlText += " WHERE " + where
 	}
+	if len(orderBy) > 0 {
+		quoted := make([]string, len(orderBy))
+		for i, col := range orderBy {
+			quoted[i] = database.QuoteIdentifier(dbType, col)
+		}
+		sqlText += " ORDER BY " + strings.Join(quoted, ", ")
+	}
 	if limit > 0 {
 		switch dbType {
 		case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLite, config.DatabaseTypeDuckDB:
 			sqlText += fmt.Sprintf(" LIMIT %d", limit)
 		case config.DatabaseTypeSQLServer:
+			// For SQL Server, TOP must go right after SELECT.
 			sqlText = strings.Replace(sqlText, "SELECT *", fmt.Sprintf("SELECT TOP %d *", limit), 1)
 		case config.DatabaseTypeOracle:
 			sqlText += fmt.Sprintf(" FETCH FIRST %d ROWS ONLY", limit)
@@ -355,6 +450,18 @@ func buildTargetSQL(tableName, dbType, where string, limit int) string {
 	return sqlText
 }
 
+// combineWhere joins the user filter and an extra condition with AND.
+func combineWhere(where, cond string) string {
+	switch {
+	case where == "":
+		return cond
+	case cond == "":
+		return where
+	default:
+		return "(" + where + ") AND " + cond
+	}
+}
+
 func trimSQL(sqlText string) string {
 	trimmed := strings.TrimSpace(sqlText)
 	for strings.HasSuffix(trimmed, ";") {
@@ -364,6 +471,20 @@ func trimSQL(sqlText string) string {
 }
 
 func writeReport(result *Result, columns []database.ColumnMetadata, format, output string, stdout io.Writer) error {
+	return writeOutput(output, stdout, func(w io.Writer) error {
+		switch strings.ToLower(format) {
+		case "csv":
+			return writeCSV(w, result, columns)
+		case "html":
+			return writeHTML(w, result, columns)
+		default:
+			return writeJSON(w, result)
+		}
+	})
+}
+
+// writeOutput runs write against the output file, or stdout when output is empty.
+func writeOutput(output string, stdout io.Writer, write func(w io.Writer) error) error {
 	w := stdout
 	if output != "" {
 		f, err := os.Create(output)
@@ -373,15 +494,7 @@ func writeReport(result *Result, columns []database.ColumnMetadata, format, outp
 		defer f.Close()
 		w = f
 	}
-
-	switch strings.ToLower(format) {
-	case "csv":
-		return writeCSV(w, result, columns)
-	case "html":
-		return writeHTML(w, result, columns)
-	default:
-		return writeJSON(w, result)
-	}
+	return write(w)
 }
 
 func writeJSON(w io.Writer, result *Result) error {
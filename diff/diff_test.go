@@ -2,6 +2,7 @@ package diff
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -92,10 +93,14 @@ func TestBuildTargetSQL(t *testing.T) {
 }
 
 func TestDiffEndToEnd(t *testing.T) {
-	testWithFileDB(t)
+	for _, mode := range []string{ModeMemory, ModeStream, ModeChecksum} {
+		t.Run(mode, func(t *testing.T) {
+			testWithFileDB(t, mode)
+		})
+	}
 }
 
-func testWithFileDB(t *testing.T) {
+func testWithFileDB(t *testing.T, mode string) {
 	t.Helper()
 
 	dir := t.TempDir()
@@ -152,8 +157,9 @@ func testWithFileDB(t *testing.T) {
 	opts := Options{
 		TaskName: "orders",
 		Format:   "json",
+		Mode:     mode,
 	}
-	if err := Run(cfg, opts, &buf); err != nil {
+	if err := Run(context.Background(), cfg, opts, &buf); err != nil {
 		t.Fatalf("Run() error = %v", err)
 	}
 
@@ -256,7 +262,7 @@ func TestDiffWithWhereAndLimit(t *testing.T) {
 		Where:    "id > 1",
 		Limit:    2,
 	}
-	if err := Run(cfg, opts, &buf); err != nil {
+	if err := Run(context.Background(), cfg, opts, &buf); err != nil {
 		t.Fatalf("Run() error = %v", err)
 	}
 
@@ -339,7 +345,7 @@ func TestDiffNoKeysUsesCLI(t *testing.T) {
 		Format:   "json",
 		Keys:     []string{"id"},
 	}
-	if err := Run(cfg, opts, &buf); err != nil {
+	if err := Run(context.Background(), cfg, opts, &buf); err != nil {
 		t.Fatalf("Run() error = %v", err)
 	}
 
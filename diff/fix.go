@@ -0,0 +1,220 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// fixDeleteMaxParams bounds the bind arguments per DELETE statement when applying a repair,
+// staying well below SQL Server's limit of 2100 parameters.
+const fixDeleteMaxParams = 1000
+
+// repairMode is recorded in the history table for applied repairs.
+const repairMode = "diff-fix"
+
+// repairPlan lists the statements that make the target match the source for one diff result.
+type repairPlan struct {
+	dbType  string
+	table   string
+	columns []database.ColumnMetadata
+	keys    []string
+	result  *Result
+}
+
+func (p repairPlan) inserts() int { return len(p.result.SourceOnly) }
+func (p repairPlan) updates() int { return len(p.result.Mismatch) }
+func (p repairPlan) deletes() int { return len(p.result.TargetOnly) }
+
+// writeScript writes the repair as a transaction of DELETE, UPDATE and INSERT statements.
+func (p repairPlan) writeScript(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- db-ferry diff repair for %s (%s): %d inserts, %d updates, %d deletes\n",
+		p.table, p.dbType, p.inserts(), p.updates(), p.deletes())
+	if p.inserts()+p.updates()+p.deletes() == 0 {
+		b.WriteString("-- target already matches source\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	if begin := beginTransactionSQL(p.dbType); begin != "" {
+		b.WriteString(begin + ";\n")
+	}
+	for _, row := range p.result.TargetOnly {
+		b.WriteString(p.deleteSQL(row) + ";\n")
+	}
+	for _, m := range p.result.Mismatch {
+		if stmt := p.updateSQL(m); stmt != "" {
+			b.WriteString(stmt + ";\n")
+		}
+	}
+	for _, row := range p.result.SourceOnly {
+		b.WriteString(p.insertSQL(row) + ";\n")
+	}
+	b.WriteString("COMMIT;\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func beginTransactionSQL(dbType string) string {
+	switch dbType {
+	case config.DatabaseTypeMySQL:
+		return "START TRANSACTION"
+	case config.DatabaseTypeSQLServer:
+		return "BEGIN TRANSACTION"
+	case config.DatabaseTypeOracle:
+		// Oracle opens a transaction implicitly with the first statement.
+		return ""
+	default:
+		return "BEGIN"
+	}
+}
+
+func (p repairPlan) column(name string) database.ColumnMetadata {
+	for _, col := range p.columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return database.ColumnMetadata{Name: name}
+}
+
+func (p repairPlan) literal(name string, value any) string {
+	return database.SQLLiteral(p.dbType, p.column(name), value)
+}
+
+func (p repairPlan) keyPredicate(row Row) string {
+	conds := make([]string, len(p.keys))
+	for i, key := range p.keys {
+		col := database.QuoteIdentifier(p.dbType, key)
+		if row[key] == nil {
+			conds[i] = col + " IS NULL"
+			continue
+		}
+		conds[i] = col + " = " + p.literal(key, row[key])
+	}
+	return strings.Join(conds, " AND ")
+}
+
+func (p repairPlan) deleteSQL(row Row) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", database.QuoteTableName(p.table, p.dbType), p.keyPredicate(row))
+}
+
+// updateSQL sets only the differing non-key columns to the source values.
+func (p repairPlan) updateSQL(m MismatchRow) string {
+	var sets []string
+	for _, col := range m.DiffCols {
+		if containsString(p.keys, col) {
+			continue
+		}
+		sets = append(sets, database.QuoteIdentifier(p.dbType, col)+" = "+p.literal(col, m.Source[col]))
+	}
+	if len(sets) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("UPDATE %s SET %s WHERE %s",
+		database.QuoteTableName(p.table, p.dbType), strings.Join(sets, ", "), p.keyPredicate(m.Target))
+}
+
+func (p repairPlan) insertSQL(row Row) string {
+	names := make([]string, len(p.columns))
+	values := make([]string, len(p.columns))
+	for i, col := range p.columns {
+		names[i] = database.QuoteIdentifier(p.dbType, col.Name)
+		values[i] = database.SQLLiteral(p.dbType, col, row[col.Name])
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		database.QuoteTableName(p.table, p.dbType), strings.Join(names, ", "), strings.Join(values, ", "))
+}
+
+// apply deletes target-only rows and upserts source-only and mismatched rows by the diff keys.
+// It returns the number of rows upserted and deleted.
+func (p repairPlan) apply(ctx context.Context, target database.TargetDB) (int64, int64, error) {
+	var deleted int64
+	if n := p.deletes(); n > 0 {
+		keys := make([][]any, n)
+		for i, row := range p.result.TargetOnly {
+			keys[i] = rowValues(row, p.keys)
+		}
+		chunkSize := max(1, fixDeleteMaxParams/len(p.keys))
+		for start := 0; start < n; start += chunkSize {
+			end := min(start+chunkSize, n)
+			stmt, args := database.BuildDeleteByKeysSQL(p.dbType, p.table, p.keys, keys[start:end])
+			if err := target.Exec(ctx, stmt, args...); err != nil {
+				return 0, deleted, fmt.Errorf("failed to delete target-only rows: %w", err)
+			}
+			deleted += int64(end - start)
+		}
+	}
+
+	names := make([]string, len(p.columns))
+	for i, col := range p.columns {
+		names[i] = col.Name
+	}
+	values := make([][]any, 0, p.inserts()+p.updates())
+	for _, row := range p.result.SourceOnly {
+		values = append(values, rowValues(row, names))
+	}
+	for _, m := range p.result.Mismatch {
+		values = append(values, rowValues(m.Source, names))
+	}
+	if len(values) > 0 {
+		if err := target.UpsertData(ctx, p.table, p.columns, values, p.keys); err != nil {
+			return 0, deleted, fmt.Errorf("failed to upsert source rows: %w", err)
+		}
+	}
+	return int64(len(values)), deleted, nil
+}
+
+func rowValues(row Row, names []string) []any {
+	values := make([]any, len(names))
+	for i, name := range names {
+		values[i] = row[name]
+	}
+	return values
+}
+
+// applyRepair applies the plan and records it in the history table when history is enabled.
+func applyRepair(ctx context.Context, cfg *config.Config, task config.TaskConfig, target database.TargetDB, plan repairPlan, version string) error {
+	var recorder *database.HistoryRecorder
+	var historyID string
+	if cfg.History.Enabled {
+		recorder = database.NewHistoryRecorder(plan.dbType, cfg.History.Table())
+		if err := recorder.EnsureTable(ctx, target); err != nil {
+			log.Printf("Warning: failed to ensure history table: %v", err)
+			recorder = nil
+		} else {
+			rec := &database.MigrationRecord{
+				TaskName: task.TableName,
+				SourceDB: task.SourceDB,
+				TargetDB: task.TargetDB,
+				Mode:     repairMode,
+				Version:  version,
+			}
+			if historyID, err = recorder.Start(ctx, target, rec); err != nil {
+				log.Printf("Warning: %v", err)
+				recorder = nil
+			}
+		}
+	}
+
+	upserted, deleted, err := plan.apply(ctx, target)
+	if recorder != nil {
+		validationResult, errMsg := "success", ""
+		if err != nil {
+			validationResult, errMsg = "failed", err.Error()
+		}
+		if finishErr := recorder.Finish(context.WithoutCancel(ctx), target, historyID, upserted, 0, deleted, validationResult, errMsg); finishErr != nil {
+			log.Printf("Warning: failed to finish history record: %v", finishErr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Repaired table %s: upserted %d rows, deleted %d rows", plan.table, upserted, deleted)
+	return nil
+}
@@ -0,0 +1,171 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+
+	"db-ferry/config"
+)
+
+// setupRepairFixture creates source and target SQLite orders tables that differ by one
+// source-only row (id 1), one mismatched row (id 2) and one target-only row (id 4).
+func setupRepairFixture(t *testing.T) (*config.Config, *sql.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	srcPath := dir + "/source.db"
+	dstPath := dir + "/target.db"
+
+	for path, rows := range map[string]string{
+		srcPath: `(1, 100, 'it''s new'), (2, 200, 'pending'), (3, 300, 'done')`,
+		dstPath: `(2, 200, 'shipped'), (3, 300, 'done'), (4, 400, 'pending')`,
+	} {
+		db, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatalf("open db error = %v", err)
+		}
+		if _, err := db.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, amount INTEGER, status TEXT)`); err != nil {
+			t.Fatalf("create table error = %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO orders VALUES ` + rows); err != nil {
+			t.Fatalf("insert rows error = %v", err)
+		}
+		db.Close()
+	}
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: "sqlite", Path: srcPath},
+			{Name: "dst", Type: "sqlite", Path: dstPath},
+		},
+		Tasks: []config.TaskConfig{{
+			TableName: "orders",
+			SQL:       "SELECT id, amount, status FROM orders",
+			SourceDB:  "src",
+			TargetDB:  "dst",
+			MergeKeys: []string{"id"},
+			Mode:      "merge",
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validate config error = %v", err)
+	}
+
+	dst, err := sql.Open("sqlite3", dstPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	t.Cleanup(func() { dst.Close() })
+	return cfg, dst
+}
+
+func assertNoDifferences(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Run(context.Background(), cfg, Options{TaskName: "orders", Format: "json"}, &buf); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	var result Result
+	if err := decodeJSON(bytes.NewReader(buf.Bytes()), &result); err != nil {
+		t.Fatalf("decode json error = %v", err)
+	}
+	if s := result.Summary; s.SourceOnly+s.TargetOnly+s.Mismatch != 0 {
+		t.Fatalf("expected no differences after repair, got %+v", s)
+	}
+}
+
+func TestDiffFixWritesRepairScript(t *testing.T) {
+	cfg, dst := setupRepairFixture(t)
+
+	var buf bytes.Buffer
+	if err := Run(context.Background(), cfg, Options{TaskName: "orders", Mode: ModeStream, Fix: true}, &buf); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	script := buf.String()
+	for _, want := range []string{
+		"1 inserts, 1 updates, 1 deletes",
+		`DELETE FROM "orders" WHERE "id" = 4;`,
+		`UPDATE "orders" SET "status" = 'pending' WHERE "id" = 2;`,
+		`INSERT INTO "orders" ("id", "amount", "status") VALUES (1, 100, 'it''s new');`,
+		"COMMIT;",
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("repair script missing %q:\n%s", want, script)
+		}
+	}
+
+	if _, err := dst.Exec(script); err != nil {
+		t.Fatalf("executing repair script error = %v", err)
+	}
+	assertNoDifferences(t, cfg)
+}
+
+func TestDiffApplyRepairsTargetAndRecordsHistory(t *testing.T) {
+	cfg, dst := setupRepairFixture(t)
+	cfg.History = config.HistoryConfig{Enabled: true}
+
+	var buf bytes.Buffer
+	opts := Options{TaskName: "orders", Apply: true, DryRun: true}
+	if err := Run(context.Background(), cfg, opts, &buf); err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "DELETE FROM") {
+		t.Fatalf("expected dry run to print the repair script, got %s", buf.String())
+	}
+	var count int
+	if err := dst.QueryRow(`SELECT COUNT(*) FROM orders WHERE id = 4`).Scan(&count); err != nil || count != 1 {
+		t.Fatalf("dry run changed the target (count=%d, err=%v)", count, err)
+	}
+
+	buf.Reset()
+	opts.DryRun = false
+	if err := Run(context.Background(), cfg, opts, &buf); err != nil {
+		t.Fatalf("apply error = %v", err)
+	}
+	assertNoDifferences(t, cfg)
+
+	var mode string
+	var processed, deleted int64
+	if err := dst.QueryRow(`SELECT mode, rows_processed, rows_deleted FROM db_ferry_migrations`).Scan(&mode, &processed, &deleted); err != nil {
+		t.Fatalf("query history error = %v", err)
+	}
+	if mode != repairMode || processed != 2 || deleted != 1 {
+		t.Fatalf("history = (%s, %d, %d), want (%s, 2, 1)", mode, processed, deleted, repairMode)
+	}
+}
+
+func TestDiffFixRejectsLimit(t *testing.T) {
+	err := Run(context.Background(), &config.Config{}, Options{TaskName: "orders", Fix: true, Limit: 10}, nil)
+	if err == nil || !strings.Contains(err.Error(), "-limit cannot be combined") {
+		t.Fatalf("expected limit error, got %v", err)
+	}
+}
+
+func TestDiffRepairRejectsMaskedTask(t *testing.T) {
+	cfg, _ := setupRepairFixture(t)
+	cfg.Tasks[0].Masking = []config.MaskingConfig{{Column: "status", Rule: config.MaskRuleHash}}
+
+	for _, opts := range []Options{
+		{TaskName: "orders", Fix: true},
+		{TaskName: "orders", Apply: true},
+	} {
+		var out bytes.Buffer
+		err := Run(context.Background(), cfg, opts, &out)
+		if err == nil || !strings.Contains(err.Error(), "masking or a plugin") {
+			t.Fatalf("expected masked task to be refused for %+v, got %v", opts, err)
+		}
+		if out.Len() != 0 {
+			t.Fatalf("expected no repair output, got %q", out.String())
+		}
+	}
+
+	cfg.Tasks[0].Masking = nil
+	cfg.Tasks[0].Plugin = config.PluginConfig{Engine: config.PluginEngineLua, Script: "function transform(row) return row end"}
+	err := Run(context.Background(), cfg, Options{TaskName: "orders", Apply: true}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "masking or a plugin") {
+		t.Fatalf("expected plugin task to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package diff
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"db-ferry/database"
+)
+
+// querier is the read side shared by SourceDB and TargetDB.
+type querier interface {
+	Query(ctx context.Context, sql string) (*sql.Rows, error)
+}
+
+// diffSide describes one side of a diff: where its rows come from and in which dialect.
+type diffSide struct {
+	name     string
+	db       querier
+	dbType   string
+	relation string
+}
+
+// orderedSQL selects the side's rows ordered by the diff keys.
+func (s diffSide) orderedSQL(where string, keys []string, limit int) string {
+	return buildSelectSQL(s.relation, s.dbType, where, keys, limit)
+}
+
+// rowCursor reads one side row by row and checks that the database returned the rows
+// in the same order the merge compares keys in.
+type rowCursor struct {
+	side    string
+	rows    *sql.Rows
+	columns []database.ColumnMetadata
+	keys    []string
+	textual []bool
+	prev    Row
+	count   int
+}
+
+func openCursor(ctx context.Context, side diffSide, sqlText string, columns []database.ColumnMetadata, keys []string) (*rowCursor, error) {
+	rows, err := side.db.Query(ctx, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", side.name, err)
+	}
+	return &rowCursor{side: side.name, rows: rows, columns: columns, keys: keys, textual: keyTextual(columns, keys)}, nil
+}
+
+// next returns the next row, or nil once the side is exhausted.
+func (c *rowCursor) next() (Row, error) {
+	if !c.rows.Next() {
+		if err := c.rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s rows: %w", c.side, err)
+		}
+		return nil, nil
+	}
+	row, err := scanRow(c.rows, c.columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s row: %w", c.side, err)
+	}
+	if c.prev != nil && compareKeys(c.prev, row, c.keys, c.textual) > 0 {
+		return nil, fmt.Errorf("%s rows are not ordered by the diff keys the way db-ferry compares them (key %s after %s); check the key collation or use -mode memory",
+			c.side, rowKeyText(row, c.keys), rowKeyText(c.prev, c.keys))
+	}
+	c.prev = row
+	c.count++
+	return row, nil
+}
+
+func (c *rowCursor) close() {
+	c.rows.Close()
+}
+
+// streamDiff merge-compares both sides read in key order, holding only the differences in memory.
+func streamDiff(ctx context.Context, source, target diffSide, columns []database.ColumnMetadata, keys []string, where string, limit int) (*Result, error) {
+	result := newResult()
+	sourceTotal, targetTotal, err := mergeRange(ctx, source, target, columns, keys, where, where, limit, result)
+	if err != nil {
+		return nil, err
+	}
+	result.Summary.SourceTotal = sourceTotal
+	result.Summary.TargetTotal = targetTotal
+	result.summarize()
+	return result, nil
+}
+
+// mergeRange merge-compares the rows matching each side's filter and appends the differences to result.
+func mergeRange(ctx context.Context, source, target diffSide, columns []database.ColumnMetadata, keys []string, sourceWhere, targetWhere string, limit int, result *Result) (int, int, error) {
+	src, err := openCursor(ctx, source, source.orderedSQL(sourceWhere, keys, limit), columns, keys)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.close()
+	tgt, err := openCursor(ctx, target, target.orderedSQL(targetWhere, keys, limit), columns, keys)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tgt.close()
+
+	s, err := src.next()
+	if err != nil {
+		return 0, 0, err
+	}
+	t, err := tgt.next()
+	if err != nil {
+		return 0, 0, err
+	}
+	for s != nil || t != nil {
+		cmp := 0
+		switch {
+		case t == nil:
+			cmp = -1
+		case s == nil:
+			cmp = 1
+		default:
+			cmp = compareKeys(s, t, keys, src.textual)
+		}
+
+		switch {
+		case cmp < 0:
+			result.SourceOnly = append(result.SourceOnly, s)
+			s, err = src.next()
+		case cmp > 0:
+			result.TargetOnly = append(result.TargetOnly, t)
+			t, err = tgt.next()
+		default:
+			if mismatch, ok := compareRows(s, t, columns, keys); ok {
+				result.Mismatch = append(result.Mismatch, mismatch)
+			}
+			if s, err = src.next(); err != nil {
+				return 0, 0, err
+			}
+			t, err = tgt.next()
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return src.count, tgt.count, nil
+}
+
+func newResult() *Result {
+	return &Result{
+		SourceOnly: make([]Row, 0),
+		TargetOnly: make([]Row, 0),
+		Mismatch:   make([]MismatchRow, 0),
+	}
+}
+
+// summarize fills the difference counts from the collected rows.
+func (r *Result) summarize() {
+	r.Summary.SourceOnly = len(r.SourceOnly)
+	r.Summary.TargetOnly = len(r.TargetOnly)
+	r.Summary.Mismatch = len(r.Mismatch)
+}
+
+func keyTextual(columns []database.ColumnMetadata, keys []string) []bool {
+	textual := make([]bool, len(keys))
+	for i, key := range keys {
+		for _, col := range columns {
+			if col.Name == key {
+				textual[i] = database.IsTextualColumn(col)
+				break
+			}
+		}
+	}
+	return textual
+}
+
+// compareKeys orders two rows by the diff keys.
+func compareKeys(a, b Row, keys []string, textual []bool) int {
+	for i, key := range keys {
+		if c := compareKeyValue(a[key], b[key], textual[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareKeyValue orders two key values: NULL first, then times and numbers by value,
+// and everything else (including textual columns) by bytes.
+func compareKeyValue(a, b any, textual bool) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+	if !textual {
+		if ia, ok := a.(int64); ok {
+			if ib, ok := b.(int64); ok {
+				switch {
+				case ia < ib:
+					return -1
+				case ia > ib:
+					return 1
+				}
+				return 0
+			}
+		}
+		if na, ok := toRat(a); ok {
+			if nb, ok := toRat(b); ok {
+				return na.Cmp(nb)
+			}
+		}
+	}
+	return strings.Compare(keyText(a), keyText(b))
+}
+
+func toRat(v any) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case int32:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(n) == nil {
+			return nil, false
+		}
+		return r, true
+	case float32:
+		r := new(big.Rat)
+		if r.SetFloat64(float64(n)) == nil {
+			return nil, false
+		}
+		return r, true
+	case *big.Int:
+		return new(big.Rat).SetInt(n), true
+	case []byte:
+		return new(big.Rat).SetString(strings.TrimSpace(string(n)))
+	case string:
+		return new(big.Rat).SetString(strings.TrimSpace(n))
+	}
+	return nil, false
+}
+
+func keyText(v any) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func rowKeyText(row Row, keys []string) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = keyText(row[key])
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
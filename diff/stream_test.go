@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+
+	_ "github.com/duckdb/duckdb-go/v2"
+)
+
+func TestCompareKeyValue(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		a, b    any
+		textual bool
+		want    int
+	}{
+		{nil, nil, false, 0},
+		{nil, int64(1), false, -1},
+		{int64(9), int64(10), false, -1},
+		{[]byte("9"), []byte("10"), false, -1},
+		{int64(2), float64(1.5), false, 1},
+		{"9", "10", true, 1},
+		{"abc", "abd", true, -1},
+		{now, now.Add(time.Second), false, -1},
+	}
+	for _, tc := range cases {
+		if got := compareKeyValue(tc.a, tc.b, tc.textual); got != tc.want {
+			t.Errorf("compareKeyValue(%v, %v, %v) = %d, want %d", tc.a, tc.b, tc.textual, got, tc.want)
+		}
+	}
+}
+
+func TestRunRejectsUnknownMode(t *testing.T) {
+	err := Run(context.Background(), &config.Config{}, Options{TaskName: "orders", Mode: "fast"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unsupported diff mode") {
+		t.Fatalf("expected unsupported mode error, got %v", err)
+	}
+	err = Run(context.Background(), &config.Config{}, Options{TaskName: "orders", Mode: ModeChecksum, Limit: 10}, nil)
+	if err == nil || !strings.Contains(err.Error(), "-limit is not supported") {
+		t.Fatalf("expected checksum limit error, got %v", err)
+	}
+}
+
+// countingQuerier counts the row-level (ordered) queries issued against a side.
+type countingQuerier struct {
+	db      *sql.DB
+	ordered int
+}
+
+func (q *countingQuerier) Query(ctx context.Context, sqlText string) (*sql.Rows, error) {
+	if strings.Contains(sqlText, "ORDER BY") {
+		q.ordered++
+	}
+	return q.db.QueryContext(ctx, sqlText)
+}
+
+func TestChecksumDiffDrillsOnlyDifferingBuckets(t *testing.T) {
+	open := func() *sql.DB {
+		db, err := sql.Open("duckdb", "")
+		if err != nil {
+			t.Fatalf("open duckdb error = %v", err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+		if _, err := db.Exec(`CREATE TABLE orders (id BIGINT, amount BIGINT, status VARCHAR)`); err != nil {
+			t.Fatalf("create table error = %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO orders SELECT i, i * 10, 'ok' FROM range(1, 1001) r(i)`); err != nil {
+			t.Fatalf("insert rows error = %v", err)
+		}
+		return db
+	}
+	srcDB, dstDB := open(), open()
+	if _, err := dstDB.Exec(`UPDATE orders SET status = 'changed' WHERE id = 500`); err != nil {
+		t.Fatalf("update target error = %v", err)
+	}
+	if _, err := dstDB.Exec(`DELETE FROM orders WHERE id = 20`); err != nil {
+		t.Fatalf("delete target error = %v", err)
+	}
+
+	src := &countingQuerier{db: srcDB}
+	dst := &countingQuerier{db: dstDB}
+	source := diffSide{name: "source", db: src, dbType: config.DatabaseTypeDuckDB, relation: sourceRelation("SELECT id, amount, status FROM orders", config.DatabaseTypeDuckDB)}
+	target := diffSide{name: "target", db: dst, dbType: config.DatabaseTypeDuckDB, relation: database.QuoteTableName("orders", config.DatabaseTypeDuckDB)}
+	columns := []database.ColumnMetadata{
+		{Name: "id", DatabaseType: "BIGINT"},
+		{Name: "amount", DatabaseType: "BIGINT"},
+		{Name: "status", DatabaseType: "VARCHAR"},
+	}
+
+	result, err := checksumDiff(context.Background(), source, target, columns, []string{"id"}, Options{Buckets: 4, BucketRows: 10})
+	if err != nil {
+		t.Fatalf("checksumDiff() error = %v", err)
+	}
+	if result.Summary.SourceTotal != 1000 || result.Summary.TargetTotal != 999 {
+		t.Fatalf("totals = %d/%d, want 1000/999", result.Summary.SourceTotal, result.Summary.TargetTotal)
+	}
+	if len(result.SourceOnly) != 1 || fmt.Sprint(result.SourceOnly[0]["id"]) != "20" {
+		t.Fatalf("source only = %v, want id 20", result.SourceOnly)
+	}
+	if len(result.Mismatch) != 1 || fmt.Sprint(result.Mismatch[0].Key["id"]) != "500" {
+		t.Fatalf("mismatch = %v, want id 500", result.Mismatch)
+	}
+	if len(result.TargetOnly) != 0 {
+		t.Fatalf("target only = %v, want none", result.TargetOnly)
+	}
+	// Each difference lands in its own leaf bucket, so only those two ranges are read row by row.
+	if src.ordered != 2 || dst.ordered != 2 {
+		t.Fatalf("row-level queries = %d/%d, want 2/2", src.ordered, dst.ordered)
+	}
+}
@@ -1,6 +1,7 @@
 package doctor
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -10,11 +11,15 @@ import (
 
 	"db-ferry/config"
 	"db-ferry/database"
+	"db-ferry/pii"
 
 	"github.com/BurntSushi/toml"
 	"golang.org/x/term"
 )
 
+// doctorPIISampleSize 为检查未脱敏个人信息时每个任务抽样的行数
+const doctorPIISampleSize = 20
+
 // Status represents the result of a diagnostic check.
 type Status int
 
@@ -73,7 +78,12 @@ func New(tomlPath string) *Doctor {
 // Run executes all diagnostic checks and writes formatted results to stdout.
 // It returns an exit code: 0 if no failures, 1 otherwise.
 func (d *Doctor) Run(stdout io.Writer) int {
-	results := d.RunChecks()
+	return d.RunContext(context.Background(), stdout)
+}
+
+// RunContext is like Run; cancelling ctx interrupts the queries the checks run.
+func (d *Doctor) RunContext(ctx context.Context, stdout io.Writer) int {
+	results := d.RunChecksContext(ctx)
 	d.printResults(stdout, results)
 
 	for _, r := range results {
@@ -87,10 +97,15 @@ func (d *Doctor) Run(stdout io.Writer) int {
 // RunChecks executes all diagnostic checks and returns the raw results.
 // This is useful for programmatic consumption (e.g., web dashboard API).
 func (d *Doctor) RunChecks() []CheckResult {
-	return d.runChecks()
+	return d.RunChecksContext(context.Background())
+}
+
+// RunChecksContext is like RunChecks; cancelling ctx interrupts the queries the checks run.
+func (d *Doctor) RunChecksContext(ctx context.Context) []CheckResult {
+	return d.runChecks(ctx)
 }
 
-func (d *Doctor) runChecks() []CheckResult {
+func (d *Doctor) runChecks(ctx context.Context) []CheckResult {
 	var results []CheckResult
 
 	// 1. TOML syntax
@@ -134,17 +149,23 @@ func (d *Doctor) runChecks() []CheckResult {
 
 	connected := make(map[string]bool)
 	for _, dbCfg := range cfg.Databases {
-		_, err := manager.GetSource(dbCfg.Name)
+		src, err := manager.GetSource(dbCfg.Name)
 		status := statusFromErr(err)
 		msg := errMsg(err)
 		if status == StatusPass {
 			connected[dbCfg.Name] = true
 			msg = fmt.Sprintf("type=%s", dbCfg.Type)
+			if dbCfg.Dialect != "" {
+				msg += fmt.Sprintf(" dialect=%s", dbCfg.Dialect)
+			}
 			// Also explicitly verify GetTarget for future source-only/target-only support.
 			if _, err := manager.GetTarget(dbCfg.Name); err != nil {
 				status = StatusFail
 				msg = err.Error()
 				connected[dbCfg.Name] = false
+			} else if warn := checkDialect(ctx, src, dbCfg); warn != "" {
+				status = StatusWarn
+				msg = warn
 			}
 		}
 		results = append(results, CheckResult{
@@ -168,7 +189,7 @@ func (d *Doctor) runChecks() []CheckResult {
 		sourceOK := connected[task.SourceDB]
 		var sourceErr error
 		if sourceOK {
-			sourceErr = checkSourceSQL(manager, task)
+			sourceErr = checkSourceSQL(ctx, manager, task)
 		}
 
 		results = append(results, CheckResult{
@@ -184,7 +205,7 @@ func (d *Doctor) runChecks() []CheckResult {
 
 		// 6. Column existence
 		if sourceOK && sourceErr == nil {
-			err := checkColumns(manager, task)
+			err := checkColumns(ctx, manager, task)
 			results = append(results, CheckResult{
 				Name:    fmt.Sprintf("Column existence: %s", task.TableName),
 				Status:  statusFromErr(err),
@@ -198,10 +219,14 @@ func (d *Doctor) runChecks() []CheckResult {
 			})
 		}
 
-		// 7. Target permission
-		if connected[task.TargetDB] && !targetPermissionChecked[task.TargetDB] {
-			targetPermissionChecked[task.TargetDB] = true
-			err := checkTargetPermissions(manager, task, cfg)
+		// 7. Target permission (merge tasks additionally check upsert, so they are tracked apart)
+		permissionKey := task.TargetDB
+		if task.Mode == config.TaskModeMerge {
+			permissionKey += "\x00merge"
+		}
+		if connected[task.TargetDB] && !targetPermissionChecked[permissionKey] {
+			targetPermissionChecked[permissionKey] = true
+			err := checkTargetPermissions(ctx, manager, task, cfg)
 			results = append(results, CheckResult{
 				Name:    fmt.Sprintf("Target permission: %s", task.TableName),
 				Status:  statusFromErr(err),
@@ -209,7 +234,7 @@ func (d *Doctor) runChecks() []CheckResult {
 			})
 		} else {
 			reason := "skipped because target database connection failed"
-			if targetPermissionChecked[task.TargetDB] {
+			if targetPermissionChecked[permissionKey] {
 				reason = "skipped because target permission already checked"
 			}
 			results = append(results, CheckResult{
@@ -231,7 +256,7 @@ func (d *Doctor) runChecks() []CheckResult {
 
 		// 9. Disk space for file-based DBs
 		targetDBCfg, _ := cfg.GetDatabase(task.TargetDB)
-		if connected[task.TargetDB] && (targetDBCfg.Type == config.DatabaseTypeSQLite || targetDBCfg.Type == config.DatabaseTypeDuckDB) {
+		if connected[task.TargetDB] && (targetDBCfg.Type == config.DatabaseTypeSQLite || targetDBCfg.Type == config.DatabaseTypeDuckDB || targetDBCfg.IsFileDatabase()) {
 			if !diskSpaceChecked[task.TargetDB] {
 				diskSpaceChecked[task.TargetDB] = true
 				err := checkDiskSpace(targetDBCfg.Path)
@@ -242,6 +267,38 @@ func (d *Doctor) runChecks() []CheckResult {
 				})
 			}
 		}
+
+		// 10. Transactional load
+		if task.Transaction.Enabled {
+			if warning := transactionWarning(targetDBCfg.Type); warning != "" {
+				results = append(results, CheckResult{
+					Name:    fmt.Sprintf("Transactional load: %s", task.TableName),
+					Status:  StatusWarn,
+					Message: warning,
+				})
+			}
+		}
+
+		// 11. ClickHouse upsert engine
+		if connected[task.TargetDB] && targetDBCfg.Type == config.DatabaseTypeClickHouse && task.Mode == config.TaskModeMerge {
+			status, msg := checkClickHouseUpsert(manager, task, targetDBCfg)
+			results = append(results, CheckResult{
+				Name:    fmt.Sprintf("ClickHouse upsert: %s", task.TableName),
+				Status:  status,
+				Message: msg,
+			})
+		}
+
+		// 12. Unmasked personal data
+		if sourceOK && sourceErr == nil && !task.IsFederated() {
+			if msg := checkUnmaskedPII(ctx, cfg, manager, task); msg != "" {
+				results = append(results, CheckResult{
+					Name:    fmt.Sprintf("Unmasked PII: %s", task.TableName),
+					Status:  StatusWarn,
+					Message: msg,
+				})
+			}
+		}
 	}
 
 	return results
@@ -295,28 +352,28 @@ func parseTOML(path string) (*config.Config, error) {
 	return cfg, nil
 }
 
-func checkSourceSQL(manager *database.ConnectionManager, task config.TaskConfig) error {
+func checkSourceSQL(ctx context.Context, manager *database.ConnectionManager, task config.TaskConfig) error {
 	sourceDB, err := manager.GetSource(task.SourceDB)
 	if err != nil {
 		return err
 	}
 
 	wrapped := fmt.Sprintf("SELECT * FROM (%s) db_ferry_check WHERE 1=0", trimSQL(task.SQL))
-	rows, err := sourceDB.Query(wrapped)
+	rows, err := sourceDB.Query(ctx, wrapped)
 	if err != nil {
 		return err
 	}
 	return rows.Close()
 }
 
-func checkColumns(manager *database.ConnectionManager, task config.TaskConfig) error {
+func checkColumns(ctx context.Context, manager *database.ConnectionManager, task config.TaskConfig) error {
 	sourceDB, err := manager.GetSource(task.SourceDB)
 	if err != nil {
 		return err
 	}
 
 	wrapped := fmt.Sprintf("SELECT * FROM (%s) db_ferry_check WHERE 1=0", trimSQL(task.SQL))
-	rows, err := sourceDB.Query(wrapped)
+	rows, err := sourceDB.Query(ctx, wrapped)
 	if err != nil {
 		return err
 	}
@@ -332,9 +389,9 @@ func checkColumns(manager *database.ConnectionManager, task config.TaskConfig) e
 		columns[i] = ct.Name()
 	}
 
-	if task.ResumeKey != "" {
-		if !containsStringFold(columns, task.ResumeKey) {
-			return fmt.Errorf("resume_key '%s' not found in query columns", task.ResumeKey)
+	for _, key := range task.ResumeKeys() {
+		if !containsStringFold(columns, key) {
+			return fmt.Errorf("resume_key '%s' not found in query columns", key)
 		}
 	}
 
@@ -360,7 +417,7 @@ func checkColumns(manager *database.ConnectionManager, task config.TaskConfig) e
 	return nil
 }
 
-func checkTargetPermissions(manager *database.ConnectionManager, task config.TaskConfig, cfg *config.Config) error {
+func checkTargetPermissions(ctx context.Context, manager *database.ConnectionManager, task config.TaskConfig, cfg *config.Config) error {
 	targetDB, err := manager.GetTarget(task.TargetDB)
 	if err != nil {
 		return err
@@ -368,9 +425,17 @@ func checkTargetPermissions(manager *database.ConnectionManager, task config.Tas
 
 	targetDBCfg, _ := cfg.GetDatabase(task.TargetDB)
 	tempTable := fmt.Sprintf("db_ferry_doctor_test_%d", time.Now().UnixNano())
+	drop := func() {
+		if dropper, ok := targetDB.(database.TableDropper); ok {
+			_ = dropper.DropTable(tempTable)
+			return
+		}
+		// 检查被取消时仍需删除临时表
+		_ = targetDB.Exec(context.WithoutCancel(ctx), dropTableSQL(targetDBCfg.Type, tempTable))
+	}
 
 	// Pre-cleanup in case a previous interrupted run left the table behind.
-	_ = targetDB.Exec(dropTableSQL(targetDBCfg.Type, tempTable))
+	drop()
 
 	columns := []database.ColumnMetadata{
 		{Name: "doctor_value", DatabaseType: "INT", GoType: "int"},
@@ -380,30 +445,129 @@ func checkTargetPermissions(manager *database.ConnectionManager, task config.Tas
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
-	if err := targetDB.InsertData(tempTable, columns, [][]any{{1}}); err != nil {
-		_ = targetDB.Exec(dropTableSQL(targetDBCfg.Type, tempTable))
+	if err := targetDB.InsertData(ctx, tempTable, columns, [][]any{{1}}); err != nil {
+		drop()
 		return fmt.Errorf("failed to insert data: %w", err)
 	}
 
+	// File targets have no indexes; reading the row count back writes and reads a data file instead.
+	if targetDBCfg.IsFileDatabase() {
+		_, err := targetDB.GetTableRowCount(tempTable)
+		drop()
+		if err != nil {
+			return fmt.Errorf("failed to write data file: %w", err)
+		}
+		return nil
+	}
+
+	// Merge tasks also exercise the target's upsert statement (ON DUPLICATE KEY UPDATE,
+	// ON CONFLICT or UPSERT depending on the dialect), which needs a unique index on the key.
+	merge := task.Mode == config.TaskModeMerge && targetDBCfg.Type != config.DatabaseTypeClickHouse
 	indexes := []config.IndexConfig{
-		{Name: "idx_doctor_test", Columns: []string{"doctor_value"}},
+		{Name: "idx_doctor_test", Columns: []string{"doctor_value"}, Unique: merge},
 	}
 	for i := range indexes {
 		if err := indexes[i].ParseColumns(); err != nil {
-			_ = targetDB.Exec(dropTableSQL(targetDBCfg.Type, tempTable))
+			drop()
 			return err
 		}
 	}
 
 	if err := targetDB.CreateIndexes(tempTable, indexes); err != nil {
-		_ = targetDB.Exec(dropTableSQL(targetDBCfg.Type, tempTable))
+		drop()
 		return fmt.Errorf("failed to create index: %w", err)
 	}
 
-	_ = targetDB.Exec(dropTableSQL(targetDBCfg.Type, tempTable))
+	if merge {
+		if err := targetDB.UpsertData(ctx, tempTable, columns, [][]any{{1}}, []string{"doctor_value"}); err != nil {
+			drop()
+			return fmt.Errorf("failed to upsert data: %w", err)
+		}
+	}
+
+	drop()
 	return nil
 }
 
+// checkDialect compares the configured dialect with the engine the server reports,
+// so DDL and upsert SQL match the engine actually behind a mysql/postgresql alias.
+func checkDialect(ctx context.Context, src database.SourceDB, dbCfg config.DatabaseConfig) string {
+	detected, err := database.DetectDialect(ctx, src, dbCfg.Type)
+	if err != nil {
+		return fmt.Sprintf("could not verify dialect: %v", err)
+	}
+	if detected == dbCfg.Dialect {
+		return ""
+	}
+	if detected == "" {
+		return fmt.Sprintf("dialect %q is configured but the server reports plain %s", dbCfg.Dialect, dbCfg.Type)
+	}
+	return fmt.Sprintf("server reports %s; set dialect = %q so DDL and upsert SQL match the engine", detected, detected)
+}
+
+// checkClickHouseUpsert 检查 merge 任务的 ClickHouse 目标表能否按 upsert_strategy 去重。
+func checkClickHouseUpsert(manager *database.ConnectionManager, task config.TaskConfig, dbCfg config.DatabaseConfig) (Status, string) {
+	if dbCfg.UpsertStrategy == config.ClickHouseUpsertDeleteInsert {
+		return StatusWarn, "upsert_strategy delete_insert deletes the batch keys before inserting; the two steps are not atomic, so readers may briefly miss those rows"
+	}
+	targetDB, err := manager.GetTarget(task.TargetDB)
+	if err != nil {
+		return StatusFail, err.Error()
+	}
+	ch, ok := targetDB.(*database.ClickHouseDB)
+	if !ok {
+		return StatusSkip, "target is not a clickhouse connection"
+	}
+	engine, err := ch.TableEngine(task.TableName)
+	switch {
+	case err != nil:
+		return StatusFail, err.Error()
+	case engine == "":
+		return StatusPass, fmt.Sprintf("table will be created as ReplacingMergeTree ORDER BY (%s)", strings.Join(task.MergeKeys, ", "))
+	case strings.Contains(engine, "ReplacingMergeTree"):
+		return StatusPass, fmt.Sprintf("engine=%s; older versions of a row are removed by background merges, read with FINAL for deduplicated results", engine)
+	default:
+		return StatusFail, fmt.Sprintf("table uses engine %s; upsert_strategy replacing requires a ReplacingMergeTree ordered by the merge keys, or set upsert_strategy = \"delete_insert\"", engine)
+	}
+}
+
+// checkUnmaskedPII 抽样任务查询，返回疑似个人信息但未配置脱敏的列；没有时返回空字符串。
+// 抽样失败不单独报错，SQL 问题已由 Source permission 检查覆盖。
+func checkUnmaskedPII(ctx context.Context, cfg *config.Config, manager *database.ConnectionManager, task config.TaskConfig) string {
+	sourceCfg, ok := cfg.GetDatabase(task.SourceDB)
+	if !ok {
+		return ""
+	}
+	sourceDB, err := manager.GetSource(task.SourceDB)
+	if err != nil {
+		return ""
+	}
+	findings, err := pii.ScanTask(ctx, sourceDB, sourceCfg.Type, task, doctorPIISampleSize)
+	if err != nil {
+		return ""
+	}
+	unmasked := pii.Unmasked(findings)
+	if len(unmasked) == 0 {
+		return ""
+	}
+	columns := make([]string, len(unmasked))
+	for i, f := range unmasked {
+		columns[i] = fmt.Sprintf("%s (%s)", f.Column, f.Category)
+	}
+	return fmt.Sprintf("%s look sensitive but have no masking rule; run db-ferry scan-pii for suggested [[tasks.masking]] entries", strings.Join(columns, ", "))
+}
+
+// transactionWarning 描述目标库在 transaction 模式下的限制，没有限制时返回空字符串。
+func transactionWarning(dbType string) string {
+	switch dbType {
+	case config.DatabaseTypeDuckDB:
+		return "duckdb has no savepoints: a failed batch rolls back the whole transaction, so max_retries and the row-by-row DLQ fallback do not apply"
+	case config.DatabaseTypeMySQL:
+		return "mysql rolls back only transactional engines such as InnoDB; MyISAM tables keep rows written before a failure"
+	}
+	return ""
+}
+
 func checkTLS(dbCfg config.DatabaseConfig) error {
 	if err := database.ValidateTLSConfig(dbCfg); err != nil {
 		return err
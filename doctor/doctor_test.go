@@ -2,6 +2,7 @@ package doctor
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"os"
 	"path/filepath"
@@ -224,6 +225,56 @@ func TestDoctorSourceSQLFail(t *testing.T) {
 	}
 }
 
+func TestDoctorRunChecksContextCancelled(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	cfgPath := filepath.Join(dir, "task.toml")
+
+	srcDB, err := sql.Open("sqlite3", srcPath)
+	if err != nil {
+		t.Fatalf("open source db error = %v", err)
+	}
+	defer srcDB.Close()
+	if _, err := srcDB.Exec(`CREATE TABLE src_users (id INTEGER PRIMARY KEY)`); err != nil {
+		t.Fatalf("create source table error = %v", err)
+	}
+
+	content := strings.Join([]string{
+		"[[databases]]",
+		`name = "src"`,
+		`type = "sqlite"`,
+		`path = "` + srcPath + `"`,
+		"",
+		"[[databases]]",
+		`name = "dst"`,
+		`type = "sqlite"`,
+		`path = "` + targetPath + `"`,
+		"",
+		"[[tasks]]",
+		`table_name = "dst_users"`,
+		`sql = "SELECT * FROM src_users"`,
+		`source_db = "src"`,
+		`target_db = "dst"`,
+		`mode = "replace"`,
+	}, "\n")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range New(cfgPath).RunChecksContext(ctx) {
+		if r.Name == "Source permission: dst_users" {
+			if r.Status != StatusFail || !strings.Contains(r.Message, "context canceled") {
+				t.Fatalf("expected the cancelled context to stop the source query, got %+v", r)
+			}
+			return
+		}
+	}
+	t.Fatal("expected a source permission result")
+}
+
 func TestDoctorColumnExistenceFail(t *testing.T) {
 	dir := t.TempDir()
 	srcPath := filepath.Join(dir, "source.db")
@@ -327,6 +378,64 @@ func TestDoctorSameDBMigrationWarning(t *testing.T) {
 	}
 }
 
+func TestDoctorUnmaskedPIIWarning(t *testing.T) {
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	cfgPath := filepath.Join(dir, "task.toml")
+
+	srcDB, err := sql.Open("sqlite3", srcPath)
+	if err != nil {
+		t.Fatalf("open source db error = %v", err)
+	}
+	defer srcDB.Close()
+
+	if _, err := srcDB.Exec(`CREATE TABLE customers (id INTEGER PRIMARY KEY, email TEXT, mobile TEXT)`); err != nil {
+		t.Fatalf("create source table error = %v", err)
+	}
+	if _, err := srcDB.Exec(`INSERT INTO customers(id, email, mobile) VALUES (1, 'alice@example.com', '13800138000')`); err != nil {
+		t.Fatalf("insert source rows error = %v", err)
+	}
+
+	content := strings.Join([]string{
+		"[[databases]]",
+		`name = "src"`,
+		`type = "sqlite"`,
+		`path = "` + srcPath + `"`,
+		"",
+		"[[databases]]",
+		`name = "dst"`,
+		`type = "sqlite"`,
+		`path = "` + targetPath + `"`,
+		"",
+		"[[tasks]]",
+		`table_name = "customers"`,
+		`sql = "SELECT id, email, mobile FROM customers"`,
+		`source_db = "src"`,
+		`target_db = "dst"`,
+		`mode = "replace"`,
+		"[[tasks.masking]]",
+		`column = "email"`,
+		`rule = "email"`,
+	}, "\n")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+
+	var out bytes.Buffer
+	code := New(cfgPath).Run(&out)
+	if code != 0 {
+		t.Fatalf("expected exit code 0 (warning only), got %d\noutput:\n%s", code, out.String())
+	}
+	output := out.String()
+	if !strings.Contains(output, "[WARN] Unmasked PII: customers: mobile (phone)") {
+		t.Fatalf("expected unmasked PII warning for mobile, got:\n%s", output)
+	}
+	if strings.Contains(output, "email (email)") {
+		t.Fatalf("expected masked email column not to be reported, got:\n%s", output)
+	}
+}
+
 func TestDoctorDiskSpaceFail(t *testing.T) {
 	dir := t.TempDir()
 	srcPath := filepath.Join(dir, "source.db")
@@ -519,6 +628,18 @@ func TestDropTableSQL(t *testing.T) {
 	}
 }
 
+func TestTransactionWarning(t *testing.T) {
+	if got := transactionWarning("duckdb"); !strings.Contains(got, "no savepoints") {
+		t.Fatalf("unexpected duckdb warning: %s", got)
+	}
+	if got := transactionWarning("mysql"); !strings.Contains(got, "InnoDB") {
+		t.Fatalf("unexpected mysql warning: %s", got)
+	}
+	if got := transactionWarning("postgresql"); got != "" {
+		t.Fatalf("expected no postgresql warning, got %s", got)
+	}
+}
+
 func TestStatusColor(t *testing.T) {
 	cases := []struct {
 		status Status
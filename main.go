@@ -23,6 +23,7 @@ import (
 	mcpserver "db-ferry/mcp"
 	"db-ferry/metrics"
 	"db-ferry/notify"
+	"db-ferry/pii"
 	"db-ferry/processor"
 	"db-ferry/sse"
 	"db-ferry/web"
@@ -39,6 +40,8 @@ const (
 	mcpServeCommand    = "serve"
 	daemonCommandName  = "daemon"
 	webCommandName     = "web"
+	runCommandName     = "run"
+	scanPIICommandName = "scan-pii"
 )
 
 var configTemplateTarget = "task.toml"
@@ -69,11 +72,32 @@ func run(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
 		dryRun               = flags.Bool("dry-run", false, "Preview the migration plan without executing")
 		federatedMemoryLimit = flags.Int("federated-memory-limit", 1000000, "Max rows per source for federated in-memory JOIN")
 		ssePort              = flags.String("sse-port", "", "SSE server listen address (e.g., :8080) for real-time progress streaming")
+		taskNames            = flags.String("tasks", "", "Comma-separated tasks (table_name) to run together with their upstream dependencies")
+		fromTasks            = flags.String("from", "", "Comma-separated tasks (table_name) to rerun together with their downstream tasks")
+		onlyFailed           = flags.Bool("only-failed", false, "Rerun the tasks whose last history record failed, together with their downstream tasks")
 	)
 
 	if err := flags.Parse(args); err != nil {
 		return 2, err
 	}
+	// run 子命令与默认行为相同，其后的参数仍按顶层 flag 解析
+	if remainingArgs := flags.Args(); len(remainingArgs) > 0 && remainingArgs[0] == runCommandName {
+		if err := flags.Parse(remainingArgs[1:]); err != nil {
+			return 2, err
+		}
+		if len(flags.Args()) > 0 {
+			return 2, fmt.Errorf("unknown run argument: %s", flags.Args()[0])
+		}
+	}
+	selectors := 0
+	for _, set := range []bool{*taskNames != "", *fromTasks != "", *onlyFailed} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors > 1 {
+		return 2, fmt.Errorf("-tasks, -from and -only-failed are mutually exclusive")
+	}
 
 	if *showVersion {
 		fmt.Fprintf(stdout, "db-ferry %s\n", version)
@@ -99,6 +123,15 @@ func run(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
 
 	log.Printf("Loaded %d tasks from configuration", len(cfg.Tasks))
 
+	selected, err := selectTasks(cfg, *taskNames, *fromTasks, *onlyFailed)
+	if err != nil {
+		return 1, err
+	}
+	if !selected {
+		fmt.Fprintln(stdout, "No failed tasks in the last run.")
+		return 0, nil
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -186,8 +219,21 @@ func run(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
 		}
 	}()
 
+	// SIGINT/SIGTERM 取消 ctx：正在执行的查询（含 dry-run 的计划查询）与批量写入会被中断
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	go func() {
+		select {
+		case <-sigCh:
+			log.Println("Received shutdown signal, cancelling running tasks...")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	if *dryRun {
-		if err := proc.PlanAllTasks(stdout); err != nil {
+		if err := proc.PlanAllTasksContext(ctx, stdout); err != nil {
 			return 1, fmt.Errorf("failed to generate plan: %w", err)
 		}
 		return 0, nil
@@ -198,13 +244,6 @@ func run(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
 
 	if hasCDCTasks(cfg) {
 		// CDC mode: run initial sync then poll continuously.
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-		go func() {
-			<-sigCh
-			log.Println("Received shutdown signal, stopping CDC polling...")
-			cancel()
-		}()
 		processErr = proc.ProcessCDCTasksContext(ctx)
 	} else {
 		processErr = proc.ProcessAllTasksContext(ctx)
@@ -230,6 +269,109 @@ func run(args []string, stdout io.Writer, stderr io.Writer) (int, error) {
 	return 0, nil
 }
 
+// selectTasks 按 -tasks / -from / -only-failed 缩小本次执行的任务范围；
+// -only-failed 且上次没有失败任务时返回 false。
+func selectTasks(cfg *config.Config, taskNames, fromTasks string, onlyFailed bool) (bool, error) {
+	switch {
+	case taskNames != "":
+		names := splitTaskNames(taskNames)
+		if err := cfg.SelectTasks(names, true, false); err != nil {
+			return false, fmt.Errorf("invalid -tasks: %w", err)
+		}
+	case fromTasks != "":
+		names := splitTaskNames(fromTasks)
+		if err := cfg.SelectTasks(names, false, true); err != nil {
+			return false, fmt.Errorf("invalid -from: %w", err)
+		}
+	case onlyFailed:
+		names, err := lastFailedTasks(cfg)
+		if err != nil {
+			return false, err
+		}
+		if len(names) == 0 {
+			return false, nil
+		}
+		log.Printf("Rerunning tasks that failed in the last run: %s", strings.Join(names, ", "))
+		if err := cfg.SelectTasks(names, false, true); err != nil {
+			return false, err
+		}
+	default:
+		return true, nil
+	}
+
+	var kept []string
+	for _, task := range cfg.Tasks {
+		if !task.Ignore {
+			kept = append(kept, task.TableName)
+		}
+	}
+	log.Printf("Selected %d tasks: %s", len(kept), strings.Join(kept, ", "))
+	return true, nil
+}
+
+func splitTaskNames(v string) []string {
+	var names []string
+	for _, name := range strings.Split(v, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lastFailedTasks 读取各任务最近一条迁移历史，返回失败或未完成的任务。
+func lastFailedTasks(cfg *config.Config) ([]string, error) {
+	if !cfg.History.Enabled {
+		return nil, fmt.Errorf("-only-failed requires [history] to be enabled")
+	}
+
+	byTarget := make(map[string][]string)
+	var aliases []string
+	for _, task := range cfg.Tasks {
+		if task.Ignore {
+			continue
+		}
+		if _, ok := byTarget[task.TargetDB]; !ok {
+			aliases = append(aliases, task.TargetDB)
+		}
+		byTarget[task.TargetDB] = append(byTarget[task.TargetDB], task.TableName)
+	}
+
+	manager := database.NewConnectionManager(cfg)
+	defer func() { _ = manager.CloseAll() }()
+
+	failed := make(map[string]bool)
+	for _, alias := range aliases {
+		targetDB, err := manager.GetTarget(alias)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to target %s: %w", alias, err)
+		}
+		dbCfg, ok := cfg.GetDatabase(alias)
+		if !ok {
+			continue
+		}
+		recorder := database.NewHistoryRecorder(dbCfg.Type, cfg.History.Table())
+		latest, err := recorder.Latest(context.Background(), targetDB, byTarget[alias])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history from %s: %w", alias, err)
+		}
+		for name, rec := range latest {
+			if rec.Failed() {
+				failed[name] = true
+			}
+		}
+	}
+
+	var names []string
+	for _, task := range cfg.Tasks {
+		if !task.Ignore && failed[task.TableName] {
+			failed[task.TableName] = false
+			names = append(names, task.TableName)
+		}
+	}
+	return names, nil
+}
+
 func hasCDCTasks(cfg *config.Config) bool {
 	for _, task := range cfg.Tasks {
 		if !task.Ignore && task.CDC.Enabled {
@@ -255,6 +397,8 @@ func runCommand(args []string, tomlPath string, stdout io.Writer) (int, error) {
 		return runDaemonCommand(args[1:], tomlPath, stdout)
 	case webCommandName:
 		return runWebCommand(args[1:], tomlPath, stdout)
+	case scanPIICommandName:
+		return runScanPIICommand(args[1:], tomlPath, stdout)
 	default:
 		return 2, fmt.Errorf("unknown command: %s", args[0])
 	}
@@ -278,8 +422,10 @@ func runDoctorCommand(args []string, tomlPath string, stdout io.Writer) (int, er
 		return 2, fmt.Errorf("unknown doctor argument: %s", args[0])
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	doc := doctor.New(tomlPath)
-	return doc.Run(stdout), nil
+	return doc.RunContext(ctx, stdout), nil
 }
 
 func runHistoryCommand(args []string, tomlPath string, stdout io.Writer) (int, error) {
@@ -324,7 +470,7 @@ func runHistoryCommand(args []string, tomlPath string, stdout io.Writer) (int, e
 			continue
 		}
 		recorder := database.NewHistoryRecorder(dbCfg.Type, cfg.History.Table())
-		records, err := recorder.List(targetDB, *limit)
+		records, err := recorder.List(context.Background(), targetDB, *limit)
 		if err != nil {
 			continue
 		}
@@ -371,6 +517,12 @@ func runDiffCommand(args []string, tomlPath string, stdout io.Writer) (int, erro
 	where := flags.String("where", "", "Optional WHERE clause applied to both sides")
 	limit := flags.Int("limit", 0, "Max rows to compare on each side (0 = unlimited)")
 	keys := flags.String("keys", "", "Comma-separated diff keys (overrides merge_keys)")
+	mode := flags.String("mode", diff.ModeMemory, "Compare mode: memory, stream (merge by key order) or checksum (bucketed key-range checksums)")
+	buckets := flags.Int("buckets", 32, "Key ranges per checksum level (checksum mode)")
+	bucketRows := flags.Int("bucket-rows", 10000, "Compare a differing bucket row by row once it holds at most this many rows (checksum mode)")
+	fix := flags.Bool("fix", false, "Write a SQL repair script for the target instead of the diff report")
+	apply := flags.Bool("apply", false, "Apply the repair to the target (upsert missing/mismatched rows, delete extra rows)")
+	dryRun := flags.Bool("dry-run", false, "With -apply, print the repair script without changing the target")
 
 	if err := flags.Parse(args); err != nil {
 		return 2, err
@@ -398,15 +550,62 @@ func runDiffCommand(args []string, tomlPath string, stdout io.Writer) (int, erro
 	}
 
 	opts := diff.Options{
-		TaskName: *taskName,
-		Output:   *output,
-		Format:   *format,
-		Where:    *where,
-		Limit:    *limit,
-		Keys:     keyList,
+		TaskName:   *taskName,
+		Output:     *output,
+		Format:     *format,
+		Where:      *where,
+		Limit:      *limit,
+		Keys:       keyList,
+		Mode:       strings.ToLower(strings.TrimSpace(*mode)),
+		Buckets:    *buckets,
+		BucketRows: *bucketRows,
+		Fix:        *fix,
+		Apply:      *apply,
+		DryRun:     *dryRun,
+		Version:    version,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := diff.Run(ctx, cfg, opts, stdout); err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+func runScanPIICommand(args []string, tomlPath string, stdout io.Writer) (int, error) {
+	flags := flag.NewFlagSet("scan-pii", flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	taskNames := flags.String("tasks", "", "Comma-separated tasks (table_name) to scan (default: all tasks)")
+	sampleSize := flags.Int("sample", pii.DefaultSampleSize, "Rows sampled per task")
+	if err := flags.Parse(args); err != nil {
+		return 2, err
+	}
+	if len(flags.Args()) > 0 {
+		return 2, fmt.Errorf("scan-pii does not accept positional arguments")
 	}
 
-	if err := diff.Run(cfg, opts, stdout); err != nil {
+	cfg, err := config.LoadConfig(tomlPath)
+	if err != nil {
+		return 1, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if *taskNames != "" {
+		if err := cfg.SelectTasks(splitTaskNames(*taskNames), false, false); err != nil {
+			return 1, fmt.Errorf("invalid -tasks: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	findings, err := pii.Scan(ctx, cfg, *sampleSize)
+	if err != nil {
+		return 1, err
+	}
+	if len(pii.Unmasked(findings)) == 0 {
+		fmt.Fprintln(stdout, "No unmasked PII columns found.")
+		return 0, nil
+	}
+	if err := pii.WriteTOML(stdout, findings); err != nil {
 		return 1, err
 	}
 	return 0, nil
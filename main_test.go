@@ -280,6 +280,88 @@ func TestRunDoctorRejectsExtraArgs(t *testing.T) {
 	}
 }
 
+func TestRunScanPIICommand(t *testing.T) {
+	oldWriter := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(oldWriter)
+
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "db.sqlite")
+	cfgPath := filepath.Join(dir, "task.toml")
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open db error = %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, mobile TEXT)`); err != nil {
+		t.Fatalf("create table error = %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO users VALUES (1, 'alice@example.com', '13800138000')`); err != nil {
+		t.Fatalf("insert rows error = %v", err)
+	}
+
+	content := strings.Join([]string{
+		"[[databases]]",
+		`name = "src"`,
+		`type = "sqlite"`,
+		`path = "` + dbPath + `"`,
+		"",
+		"[[databases]]",
+		`name = "dst"`,
+		`type = "sqlite"`,
+		`path = "` + filepath.Join(dir, "target.db") + `"`,
+		"",
+		"[[tasks]]",
+		`table_name = "users"`,
+		`sql = "SELECT id, email, mobile FROM users"`,
+		`source_db = "src"`,
+		`target_db = "dst"`,
+		`mode = "replace"`,
+		"[[tasks.masking]]",
+		`column = "mobile"`,
+		`rule = "phone_cn"`,
+		"",
+		"[[tasks]]",
+		`table_name = "user_ids"`,
+		`sql = "SELECT id FROM users"`,
+		`source_db = "src"`,
+		`target_db = "dst"`,
+		`mode = "replace"`,
+	}, "\n")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+
+	var out bytes.Buffer
+	var errOut bytes.Buffer
+	code, runErr := run([]string{"-config", cfgPath, "scan-pii", "-sample", "10"}, &out, &errOut)
+	if runErr != nil || code != 0 {
+		t.Fatalf("run() = %d, %v", code, runErr)
+	}
+	got := out.String()
+	if !strings.Contains(got, "# task: users (source_db = \"src\")") || !strings.Contains(got, "column = \"email\"\nrule = \"email\"") {
+		t.Fatalf("expected email masking suggestion, got:\n%s", got)
+	}
+	if strings.Contains(got, "mobile") {
+		t.Fatalf("expected already masked column to be left out, got:\n%s", got)
+	}
+
+	out.Reset()
+	code, runErr = run([]string{"-config", cfgPath, "scan-pii", "-tasks", "user_ids"}, &out, &errOut)
+	if runErr != nil || code != 0 {
+		t.Fatalf("run() = %d, %v", code, runErr)
+	}
+	if !strings.Contains(out.String(), "No unmasked PII columns found.") {
+		t.Fatalf("expected no findings for user_ids, got:\n%s", out.String())
+	}
+
+	if code, runErr = run([]string{"-config", cfgPath, "scan-pii", "-tasks", "missing"}, &out, &errOut); runErr == nil || code != 1 {
+		t.Fatalf("expected unknown task error, got %d, %v", code, runErr)
+	}
+}
+
 func TestRunMCPMissingSubcommand(t *testing.T) {
 	var out bytes.Buffer
 	var errOut bytes.Buffer
@@ -1244,3 +1326,171 @@ func chdirForTest(t *testing.T, dir string) {
 		}
 	})
 }
+
+// writeSelectorConfig 写入三个任务的配置：b 依赖 a，c 独立；bSource 为 b 读取的源表。
+func writeSelectorConfig(t *testing.T, dir, bSource string) (string, string) {
+	t.Helper()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	cfgPath := filepath.Join(dir, "task.toml")
+
+	sourceDB, err := sql.Open("sqlite3", sourcePath)
+	if err != nil {
+		t.Fatalf("open source db error = %v", err)
+	}
+	defer sourceDB.Close()
+	if _, err := sourceDB.Exec(`CREATE TABLE IF NOT EXISTS src_users (id INTEGER PRIMARY KEY, name TEXT)`); err != nil {
+		t.Fatalf("create source table error = %v", err)
+	}
+
+	// 串行执行，b 失败后 c 不会启动
+	lines := []string{"max_concurrent_tasks = 1", ""}
+	lines = append(lines,
+		"[[databases]]", `name = "src"`, `type = "sqlite"`, `path = "`+sourcePath+`"`, "",
+		"[[databases]]", `name = "dst"`, `type = "sqlite"`, `path = "`+targetPath+`"`, "",
+	)
+	for _, task := range []struct{ name, source, dep string }{
+		{"a", "src_users", ""},
+		{"b", bSource, "a"},
+		{"c", "src_users", ""},
+	} {
+		lines = append(lines,
+			"[[tasks]]",
+			`table_name = "`+task.name+`"`,
+			`sql = "SELECT id, name FROM `+task.source+`"`,
+			`source_db = "src"`,
+			`target_db = "dst"`,
+			`mode = "replace"`,
+		)
+		if task.dep != "" {
+			lines = append(lines, `depends_on = ["`+task.dep+`"]`)
+		}
+		lines = append(lines, "")
+	}
+	lines = append(lines, "[history]", "enabled = true")
+	if err := os.WriteFile(cfgPath, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatalf("write config error = %v", err)
+	}
+	return cfgPath, targetPath
+}
+
+func targetTables(t *testing.T, targetPath string) string {
+	t.Helper()
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name IN ('a', 'b', 'c') ORDER BY name`)
+	if err != nil {
+		t.Fatalf("list target tables error = %v", err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("scan table name error = %v", err)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRunTaskSelectors(t *testing.T) {
+	oldWriter := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(oldWriter)
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"tasks with upstream", []string{"run", "-tasks", "b"}, "a,b"},
+		{"from with downstream", []string{"-from", "a"}, "a,b"},
+		{"independent task", []string{"run", "-tasks", "c"}, "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgPath, targetPath := writeSelectorConfig(t, t.TempDir(), "src_users")
+			var out, errOut bytes.Buffer
+			code, err := run(append([]string{"-config", cfgPath}, tt.args...), &out, &errOut)
+			if err != nil || code != 0 {
+				t.Fatalf("run() = %d, %v", code, err)
+			}
+			if got := targetTables(t, targetPath); got != tt.want {
+				t.Fatalf("target tables = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunTaskSelectorErrors(t *testing.T) {
+	oldWriter := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(oldWriter)
+
+	cfgPath, _ := writeSelectorConfig(t, t.TempDir(), "src_users")
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantErr  string
+	}{
+		{"mutually exclusive", []string{"-tasks", "a", "-only-failed"}, 2, "mutually exclusive"},
+		{"unknown task", []string{"run", "-tasks", "missing"}, 1, "not found"},
+		{"extra run argument", []string{"run", "extra"}, 2, "unknown run argument"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			code, err := run(append([]string{"-config", cfgPath}, tt.args...), &out, &errOut)
+			if code != tt.wantCode || err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("run() = %d, %v; want %d and error containing %q", code, err, tt.wantCode, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunOnlyFailed(t *testing.T) {
+	oldWriter := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(oldWriter)
+
+	dir := t.TempDir()
+	cfgPath, targetPath := writeSelectorConfig(t, dir, "missing_table")
+	var out, errOut bytes.Buffer
+	if code, err := run([]string{"-config", cfgPath}, &out, &errOut); code != 1 || err == nil {
+		t.Fatalf("first run() = %d, %v; want failure", code, err)
+	}
+
+	// 修复 b 的源表并删除目标中的 a、c：补跑只应重建 b
+	writeSelectorConfig(t, dir, "src_users")
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	for _, table := range []string{"a", "c"} {
+		if _, err := db.Exec(`DROP TABLE IF EXISTS "` + table + `"`); err != nil {
+			t.Fatalf("drop table error = %v", err)
+		}
+	}
+	_ = db.Close()
+
+	out.Reset()
+	if code, err := run([]string{"-config", cfgPath, "run", "-only-failed"}, &out, &errOut); code != 0 || err != nil {
+		t.Fatalf("only-failed run() = %d, %v", code, err)
+	}
+	if got := targetTables(t, targetPath); got != "b" {
+		t.Fatalf("target tables = %q, want b", got)
+	}
+
+	out.Reset()
+	if code, err := run([]string{"-config", cfgPath, "-only-failed"}, &out, &errOut); code != 0 || err != nil {
+		t.Fatalf("second only-failed run() = %d, %v", code, err)
+	}
+	if !strings.Contains(out.String(), "No failed tasks") {
+		t.Fatalf("expected no failed tasks message, got %q", out.String())
+	}
+}
@@ -39,7 +39,7 @@ func (s *Server) registerTools() {
 		),
 		mcp.WithString("database.type",
 			mcp.Required(),
-			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle"),
+			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle, clickhouse; a \"dialect\" of mariadb or tidb (mysql) or cockroachdb (postgresql) selects a compatible engine"),
 		),
 		mcp.WithString("database.host",
 			mcp.Description("Database host (for network databases)"),
@@ -73,7 +73,7 @@ func (s *Server) registerTools() {
 		),
 		mcp.WithString("database.type",
 			mcp.Required(),
-			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle"),
+			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle, clickhouse; a \"dialect\" of mariadb or tidb (mysql) or cockroachdb (postgresql) selects a compatible engine"),
 		),
 		mcp.WithString("database.host",
 			mcp.Description("Database host (for network databases)"),
@@ -111,7 +111,7 @@ func (s *Server) registerTools() {
 		),
 		mcp.WithString("source_db.type",
 			mcp.Required(),
-			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle"),
+			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle, clickhouse; a \"dialect\" of mariadb or tidb (mysql) or cockroachdb (postgresql) selects a compatible engine"),
 		),
 		mcp.WithString("source_db.host",
 			mcp.Description("Database host (for network databases)"),
@@ -140,7 +140,7 @@ func (s *Server) registerTools() {
 		),
 		mcp.WithString("target_db.type",
 			mcp.Required(),
-			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle"),
+			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle, clickhouse; a \"dialect\" of mariadb or tidb (mysql) or cockroachdb (postgresql) selects a compatible engine"),
 		),
 		mcp.WithString("target_db.host",
 			mcp.Description("Database host (for network databases)"),
@@ -195,7 +195,7 @@ func (s *Server) registerTools() {
 		),
 		mcp.WithString("source_db.type",
 			mcp.Required(),
-			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle"),
+			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle, clickhouse; a \"dialect\" of mariadb or tidb (mysql) or cockroachdb (postgresql) selects a compatible engine"),
 		),
 		mcp.WithString("source_db.host",
 			mcp.Description("Database host (for network databases)"),
@@ -226,7 +226,7 @@ func (s *Server) registerTools() {
 			mcp.Description("Target database connection configuration (optional, used for throughput hints)"),
 		),
 		mcp.WithString("target_db.type",
-			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle"),
+			mcp.Description("Database type: mysql, postgresql, sqlite, duckdb, sqlserver, oracle, clickhouse; a \"dialect\" of mariadb or tidb (mysql) or cockroachdb (postgresql) selects a compatible engine"),
 		),
 		mcp.WithString("target_db.host",
 			mcp.Description("Database host (for network databases)"),
@@ -250,6 +250,23 @@ func (s *Server) registerTools() {
 			mcp.Description("Oracle service name"),
 		),
 	), handleEstimateMigration)
+
+	s.mcpServer.AddTool(mcp.NewTool(
+		"db_ferry_scan_pii",
+		mcp.WithDescription("Sample the source columns of each task, flag likely personal data (emails, phone numbers, ID cards, names, card numbers, addresses) and suggest [[tasks.masking]] entries for columns that are not masked yet"),
+		mcp.WithString("config_path",
+			mcp.Description("Path to the task.toml file"),
+		),
+		mcp.WithString("config_content",
+			mcp.Description("Raw TOML configuration content"),
+		),
+		mcp.WithString("tasks",
+			mcp.Description("Comma-separated tasks (table_name) to scan (default: all tasks)"),
+		),
+		mcp.WithNumber("sample_size",
+			mcp.Description("Rows sampled per task (default: 100)"),
+		),
+	), handleScanPII)
 }
 
 func extractDatabaseConfig(req mcp.CallToolRequest, prefix string) (map[string]any, error) {
@@ -8,6 +8,7 @@ import (
 
 	"db-ferry/config"
 	"db-ferry/database"
+	"db-ferry/pii"
 
 	"github.com/BurntSushi/toml"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -23,6 +24,7 @@ func parseDatabaseConfigFromMap(m map[string]any) config.DatabaseConfig {
 		Password: getString(m, "password"),
 		Path:     getString(m, "path"),
 		Service:  getString(m, "service"),
+		Dialect:  getString(m, "dialect"),
 	}
 }
 
@@ -67,13 +69,13 @@ func handleGetSchema(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 	}
 	defer src.Close()
 
-	columns, err := database.GetTableSchema(src, tableName)
+	columns, err := database.GetTableSchema(ctx, src, tableName)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to get table schema: %v", err)), nil
 	}
 
-	pk, _ := database.GetTablePrimaryKey(src, dbCfg.Type, tableName)
-	indexes, _ := database.GetTableIndexes(src, dbCfg.Type, tableName)
+	pk, _ := database.GetTablePrimaryKey(ctx, src, dbCfg.Type, tableName)
+	indexes, _ := database.GetTableIndexes(ctx, src, dbCfg.Type, tableName)
 
 	return mcp.NewToolResultJSON(map[string]any{
 		"columns":     columns,
@@ -112,7 +114,7 @@ func handleGenerateTask(ctx context.Context, req mcp.CallToolRequest) (*mcp.Call
 		src, err := database.OpenSource(sourceCfg)
 		if err == nil {
 			defer src.Close()
-			pk, _ := database.GetTablePrimaryKey(src, sourceCfg.Type, tableName)
+			pk, _ := database.GetTablePrimaryKey(ctx, src, sourceCfg.Type, tableName)
 			if len(pk) > 0 {
 				mergeKeys = pk
 			}
@@ -256,7 +258,7 @@ func handleEstimateMigration(ctx context.Context, req mcp.CallToolRequest) (*mcp
 	}
 	defer src.Close()
 
-	rowCount, err := src.GetRowCount(sqlText)
+	rowCount, err := src.GetRowCount(ctx, sqlText)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to get row count: %v", err)), nil
 	}
@@ -266,7 +268,8 @@ func handleEstimateMigration(ctx context.Context, req mcp.CallToolRequest) (*mcp
 	if targetMap, ok := req.GetArguments()["target_db"].(map[string]any); ok {
 		targetType := strings.ToLower(getString(targetMap, "type"))
 		if targetType == config.DatabaseTypePostgreSQL || targetType == config.DatabaseTypeMySQL ||
-			targetType == config.DatabaseTypeSQLServer || targetType == config.DatabaseTypeOracle {
+			targetType == config.DatabaseTypeSQLServer || targetType == config.DatabaseTypeOracle ||
+			targetType == config.DatabaseTypeClickHouse {
 			secondsPerK = 0.02
 		}
 	}
@@ -279,3 +282,54 @@ func handleEstimateMigration(ctx context.Context, req mcp.CallToolRequest) (*mcp
 		"estimated_minutes": fmt.Sprintf("%.1f", estimatedSeconds/60.0),
 	})
 }
+
+func handleScanPII(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	configPath := mcp.ParseString(req, "config_path", "")
+	configContent := mcp.ParseString(req, "config_content", "")
+	if configPath == "" && configContent == "" {
+		return mcp.NewToolResultError("either config_path or config_content is required"), nil
+	}
+
+	var cfg *config.Config
+	var err error
+	if configContent != "" {
+		cfg = &config.Config{}
+		if _, err = toml.Decode(configContent, cfg); err == nil {
+			err = cfg.Validate()
+		}
+	} else {
+		cfg, err = config.LoadConfig(configPath)
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to load configuration: %v", err)), nil
+	}
+
+	if taskNames := mcp.ParseString(req, "tasks", ""); taskNames != "" {
+		var names []string
+		for _, name := range strings.Split(taskNames, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if err := cfg.SelectTasks(names, false, false); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	findings, err := pii.Scan(ctx, cfg, mcp.ParseInt(req, "sample_size", pii.DefaultSampleSize))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to scan for PII: %v", err)), nil
+	}
+	var buf bytes.Buffer
+	if err := pii.WriteTOML(&buf, findings); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if findings == nil {
+		findings = []pii.Finding{}
+	}
+
+	return mcp.NewToolResultJSON(map[string]any{
+		"findings": findings,
+		"masking":  buf.String(),
+	})
+}
@@ -18,10 +18,10 @@ func createTestSQLiteDB(t *testing.T, path string) database.SourceDB {
 	if err != nil {
 		t.Fatalf("NewSQLiteDB() error = %v", err)
 	}
-	if err := src.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`); err != nil {
+	if err := src.Exec(context.Background(), `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`); err != nil {
 		t.Fatalf("create table error = %v", err)
 	}
-	if err := src.Exec(`INSERT INTO users(id, name) VALUES (1, 'alice'), (2, 'bob')`); err != nil {
+	if err := src.Exec(context.Background(), `INSERT INTO users(id, name) VALUES (1, 'alice'), (2, 'bob')`); err != nil {
 		t.Fatalf("insert rows error = %v", err)
 	}
 	return src
@@ -694,6 +694,81 @@ func TestHandleEstimateMigrationInvalidDB(t *testing.T) {
 	}
 }
 
+func TestHandleScanPII(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "src.db")
+	db, err := database.NewSQLiteDB(dbPath, 0, 0, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteDB() error = %v", err)
+	}
+	if err := db.Exec(context.Background(), `CREATE TABLE contacts (id INTEGER PRIMARY KEY, email TEXT)`); err != nil {
+		t.Fatalf("create table error = %v", err)
+	}
+	if err := db.Exec(context.Background(), `INSERT INTO contacts(id, email) VALUES (1, 'alice@example.com')`); err != nil {
+		t.Fatalf("insert rows error = %v", err)
+	}
+	db.Close()
+
+	content := `
+[[databases]]
+name = "src"
+type = "sqlite"
+path = "` + dbPath + `"
+
+[[tasks]]
+table_name = "contacts_copy"
+sql = "SELECT id, email FROM contacts"
+source_db = "src"
+target_db = "src"
+allow_same_table = true
+mode = "replace"
+
+[[tasks]]
+table_name = "orders_copy"
+sql = "SELECT * FROM orders"
+source_db = "src"
+target_db = "src"
+allow_same_table = true
+mode = "replace"
+`
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{
+				"config_content": content,
+				"tasks":          "contacts_copy",
+				"sample_size":    10,
+			},
+		},
+	}
+
+	res, err := handleScanPII(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handleScanPII() error = %v", err)
+	}
+	if res.IsError {
+		t.Fatalf("handleScanPII() returned error result: %v", res.Content)
+	}
+	text := getTextFromResult(t, res)
+	if !strings.Contains(text, `"column":"email"`) || !strings.Contains(text, `rule = \"email\"`) {
+		t.Fatalf("expected email finding and masking suggestion, got %s", text)
+	}
+}
+
+func TestHandleScanPIIMissingArgs(t *testing.T) {
+	req := mcp.CallToolRequest{
+		Params: mcp.CallToolParams{
+			Arguments: map[string]any{},
+		},
+	}
+
+	res, err := handleScanPII(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handleScanPII() error = %v", err)
+	}
+	if !res.IsError {
+		t.Fatalf("expected error result for missing args")
+	}
+}
+
 func getTextFromResult(t *testing.T, res *mcp.CallToolResult) string {
 	t.Helper()
 	for _, c := range res.Content {
@@ -20,6 +20,9 @@ type Recorder interface {
 	RecordBatch(taskName, sourceDB, targetDB string, success bool)
 	RecordBatchDuration(taskName, sourceDB, targetDB string, ms float64)
 	RecordDLQRows(taskName, sourceDB, targetDB string, count int64)
+	RecordDeletedRows(taskName, sourceDB, targetDB string, count int64)
+	RecordFilteredRows(taskName, sourceDB, targetDB string, count int64)
+	RecordSkippedRows(taskName, sourceDB, targetDB string, count int64)
 	RecordValidationMismatch(taskName, sourceDB, targetDB, validateType string)
 	RecordTaskDuration(taskName, sourceDB, targetDB string, ms float64)
 	ServeHTTP(listenAddr string) error
@@ -36,6 +39,9 @@ func (n *NoopRecorder) RecordRowsProcessed(_, _, _ string, _ int64)   {}
 func (n *NoopRecorder) RecordBatch(_, _, _ string, _ bool)            {}
 func (n *NoopRecorder) RecordBatchDuration(_, _, _ string, _ float64) {}
 func (n *NoopRecorder) RecordDLQRows(_, _, _ string, _ int64)         {}
+func (n *NoopRecorder) RecordDeletedRows(_, _, _ string, _ int64)     {}
+func (n *NoopRecorder) RecordFilteredRows(_, _, _ string, _ int64)    {}
+func (n *NoopRecorder) RecordSkippedRows(_, _, _ string, _ int64)     {}
 func (n *NoopRecorder) RecordValidationMismatch(_, _, _, _ string)    {}
 func (n *NoopRecorder) RecordTaskDuration(_, _, _ string, _ float64)  {}
 func (n *NoopRecorder) ServeHTTP(_ string) error                      { return nil }
@@ -53,6 +59,9 @@ type PrometheusRecorder struct {
 	rowsProcessed        sync.Map // string -> *atomic.Int64
 	batchesTotal         sync.Map // string -> *atomic.Int64
 	dlqRows              sync.Map // string -> *atomic.Int64
+	deletedRows          sync.Map // string -> *atomic.Int64
+	filteredRows         sync.Map // string -> *atomic.Int64
+	skippedRows          sync.Map // string -> *atomic.Int64
 	validationMismatches sync.Map // string -> *atomic.Int64
 
 	batchDurationMu sync.RWMutex
@@ -173,6 +182,24 @@ func (r *PrometheusRecorder) RecordDLQRows(taskName, sourceDB, targetDB string,
 	r.getCounter(&r.dlqRows, k).Add(count)
 }
 
+// RecordDeletedRows increments the counter of target rows deleted or flagged by CDC delete detection.
+func (r *PrometheusRecorder) RecordDeletedRows(taskName, sourceDB, targetDB string, count int64) {
+	k := r.key(taskName, sourceDB, targetDB)
+	r.getCounter(&r.deletedRows, k).Add(count)
+}
+
+// RecordFilteredRows increments the counter of source rows dropped by a plugin.
+func (r *PrometheusRecorder) RecordFilteredRows(taskName, sourceDB, targetDB string, count int64) {
+	k := r.key(taskName, sourceDB, targetDB)
+	r.getCounter(&r.filteredRows, k).Add(count)
+}
+
+// RecordSkippedRows increments the counter of source rows skipped by a plugin when no DLQ is configured.
+func (r *PrometheusRecorder) RecordSkippedRows(taskName, sourceDB, targetDB string, count int64) {
+	k := r.key(taskName, sourceDB, targetDB)
+	r.getCounter(&r.skippedRows, k).Add(count)
+}
+
 // RecordValidationMismatch records a validation mismatch.
 func (r *PrometheusRecorder) RecordValidationMismatch(taskName, sourceDB, targetDB, validateType string) {
 	k := r.key(taskName, sourceDB, targetDB) + "\x00" + validateType
@@ -235,6 +262,9 @@ func (r *PrometheusRecorder) writePrometheus(w io.Writer) {
 	r.writeCounterMetrics(w, "db_ferry_task_rows_processed", "Total rows processed per task.", &r.rowsProcessed)
 	r.writeCounterMetrics(w, "db_ferry_task_batches_total", "Total batches processed per task.", &r.batchesTotal)
 	r.writeCounterMetrics(w, "db_ferry_task_dlq_rows_total", "Total DLQ rows per task.", &r.dlqRows)
+	r.writeCounterMetrics(w, "db_ferry_task_deleted_rows_total", "Total target rows deleted or flagged by CDC delete detection per task.", &r.deletedRows)
+	r.writeCounterMetrics(w, "db_ferry_task_filtered_rows_total", "Total source rows dropped by the task plugin.", &r.filteredRows)
+	r.writeCounterMetrics(w, "db_ferry_task_skipped_rows_total", "Total source rows skipped by the task plugin without a DLQ.", &r.skippedRows)
 	r.writeCounterMetrics(w, "db_ferry_task_validation_mismatches_total", "Total validation mismatches per task.", &r.validationMismatches)
 
 	r.writeHistogramMetrics(w, "db_ferry_task_batch_duration_ms", "Batch insert duration in milliseconds.", r.batchDuration, &r.batchDurationMu)
@@ -18,6 +18,9 @@ func TestNoopRecorder(t *testing.T) {
 	n.RecordBatch("t", "s", "d", true)
 	n.RecordBatchDuration("t", "s", "d", 1.0)
 	n.RecordDLQRows("t", "s", "d", 1)
+	n.RecordDeletedRows("t", "s", "d", 1)
+	n.RecordFilteredRows("t", "s", "d", 1)
+	n.RecordSkippedRows("t", "s", "d", 1)
 	n.RecordValidationMismatch("t", "s", "d", "row_count")
 	n.RecordTaskDuration("t", "s", "d", 1.0)
 	if err := n.ServeHTTP(":0"); err != nil {
@@ -80,6 +83,9 @@ func TestPrometheusTextFormat(t *testing.T) {
 	r.RecordBatch("users", "src", "dst", false)
 	r.RecordBatchDuration("users", "src", "dst", 50.0)
 	r.RecordDLQRows("users", "src", "dst", 3)
+	r.RecordDeletedRows("users", "src", "dst", 2)
+	r.RecordFilteredRows("users", "src", "dst", 4)
+	r.RecordSkippedRows("users", "src", "dst", 5)
 	r.RecordValidationMismatch("users", "src", "dst", "row_count")
 	r.RecordTaskDuration("users", "src", "dst", 1000.0)
 
@@ -92,6 +98,9 @@ func TestPrometheusTextFormat(t *testing.T) {
 		`db_ferry_task_batches_total{source_db="src",status="success",target_db="dst",task_name="users",version="v1"} 1`,
 		`db_ferry_task_batches_total{source_db="src",status="failure",target_db="dst",task_name="users",version="v1"} 1`,
 		`db_ferry_task_dlq_rows_total{source_db="src",target_db="dst",task_name="users",version="v1"} 3`,
+		`db_ferry_task_deleted_rows_total{source_db="src",target_db="dst",task_name="users",version="v1"} 2`,
+		`db_ferry_task_filtered_rows_total{source_db="src",target_db="dst",task_name="users",version="v1"} 4`,
+		`db_ferry_task_skipped_rows_total{source_db="src",target_db="dst",task_name="users",version="v1"} 5`,
 		`db_ferry_task_validation_mismatches_total{source_db="src",target_db="dst",task_name="users",validate_type="row_count",version="v1"} 1`,
 		`db_ferry_task_batch_duration_ms_bucket{le="50",source_db="src",target_db="dst",task_name="users",version="v1"} 1`,
 		`db_ferry_task_batch_duration_ms_sum{source_db="src",target_db="dst",task_name="users",version="v1"} 50`,
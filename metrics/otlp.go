@@ -99,6 +99,27 @@ func (r *PrometheusRecorder) buildOTLPMetrics(now string) []otlpMetric {
 		return true
 	})
 
+	r.deletedRows.Range(func(key, value any) bool {
+		parts := strings.Split(key.(string), "\x00")
+		v := value.(*atomic.Int64).Load()
+		metrics = append(metrics, r.newSumMetric("db_ferry_task_deleted_rows_total", now, float64(v), parts...))
+		return true
+	})
+
+	r.filteredRows.Range(func(key, value any) bool {
+		parts := strings.Split(key.(string), "\x00")
+		v := value.(*atomic.Int64).Load()
+		metrics = append(metrics, r.newSumMetric("db_ferry_task_filtered_rows_total", now, float64(v), parts...))
+		return true
+	})
+
+	r.skippedRows.Range(func(key, value any) bool {
+		parts := strings.Split(key.(string), "\x00")
+		v := value.(*atomic.Int64).Load()
+		metrics = append(metrics, r.newSumMetric("db_ferry_task_skipped_rows_total", now, float64(v), parts...))
+		return true
+	})
+
 	r.validationMismatches.Range(func(key, value any) bool {
 		parts := strings.Split(key.(string), "\x00")
 		v := value.(*atomic.Int64).Load()
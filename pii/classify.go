@@ -0,0 +1,288 @@
+package pii
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"db-ferry/config"
+)
+
+// Categories of personal data recognised by the scanner.
+const (
+	CategoryEmail      = "email"
+	CategoryPhone      = "phone"
+	CategoryIDCard     = "id_card"
+	CategoryName       = "name"
+	CategoryCardNumber = "card_number"
+	CategoryAddress    = "address"
+)
+
+// Confidence levels of a finding.
+const (
+	// ConfidenceHigh means both the column name and the sampled values look like the category.
+	ConfidenceHigh = "high"
+	// ConfidenceMedium means the sampled values match but the column name gives no hint.
+	ConfidenceMedium = "medium"
+	// ConfidenceLow means only the column name matches, e.g. the table is empty.
+	ConfidenceLow = "low"
+)
+
+// matchRatio 为按取值判定时样本需要命中的最低比例。
+const matchRatio = 0.8
+
+var (
+	emailRegex   = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}$`)
+	phoneCNRegex = regexp.MustCompile(`^(\+?86[\- ]?)?1[3-9]\d{9}$`)
+	phoneUSRegex = regexp.MustCompile(`^(\+?1[\-. ]?)?(\([2-9]\d{2}\)|[2-9]\d{2})[\-. ]?\d{3}[\-. ]?\d{4}$`)
+	idCardRegex  = regexp.MustCompile(`^\d{17}[\dXx]$`)
+	cardRegex    = regexp.MustCompile(`^\d{13,19}$`)
+)
+
+// Classification is the detected category of a column and the masking rule suggested for it.
+type Classification struct {
+	Category   string `json:"category"`
+	Rule       string `json:"rule"`
+	Confidence string `json:"confidence"`
+	// Matched is the number of sampled values that look like the category.
+	Matched int `json:"matched"`
+	// Sampled is the number of non-empty string values inspected.
+	Sampled int `json:"sampled"`
+}
+
+// Classify inspects a column name and sampled values and reports whether the column
+// likely holds personal data. Only string values are inspected, so integer keys are
+// never mistaken for phone or card numbers.
+func Classify(column string, samples []any) (Classification, bool) {
+	values := sampleStrings(samples)
+	byName := categoryFromName(column)
+
+	// 按取值判定：身份证号带校验位，优先于同为 18 位数字的卡号
+	hits := map[string]int{}
+	phoneCN, phoneUS, han := 0, 0, 0
+	for _, v := range values {
+		compact := strings.NewReplacer(" ", "", "-", "").Replace(v)
+		switch {
+		case emailRegex.MatchString(v):
+			hits[CategoryEmail]++
+		case validIDCardCN(compact):
+			hits[CategoryIDCard]++
+		case phoneCNRegex.MatchString(compact):
+			hits[CategoryPhone]++
+			phoneCN++
+		case phoneUSRegex.MatchString(v):
+			hits[CategoryPhone]++
+			phoneUS++
+		case cardRegex.MatchString(compact) && luhnValid(compact):
+			hits[CategoryCardNumber]++
+		}
+		if containsHan(v) {
+			han++
+		}
+	}
+
+	byValue := ""
+	if len(values) > 0 {
+		for _, category := range []string{CategoryEmail, CategoryIDCard, CategoryPhone, CategoryCardNumber} {
+			if float64(hits[category]) >= matchRatio*float64(len(values)) {
+				byValue = category
+				break
+			}
+		}
+	}
+
+	var c Classification
+	switch {
+	case byValue != "" && byValue == byName:
+		c = Classification{Category: byValue, Confidence: ConfidenceHigh}
+	case byValue != "":
+		c = Classification{Category: byValue, Confidence: ConfidenceMedium}
+	case byName != "":
+		c = Classification{Category: byName, Confidence: ConfidenceLow}
+		// 姓名与地址无法仅凭取值识别，列名命中且有样本时视为高置信度
+		if (byName == CategoryName || byName == CategoryAddress) && len(values) > 0 {
+			c.Confidence = ConfidenceHigh
+		}
+	default:
+		return Classification{}, false
+	}
+	c.Sampled = len(values)
+	c.Matched = hits[c.Category]
+	if c.Category == CategoryName || c.Category == CategoryAddress {
+		c.Matched = len(values)
+	}
+
+	switch c.Category {
+	case CategoryEmail:
+		c.Rule = config.MaskRuleEmail
+	case CategoryPhone:
+		c.Rule = config.MaskRulePhoneCN
+		if phoneUS > phoneCN {
+			c.Rule = config.MaskRulePhoneUS
+		}
+	case CategoryIDCard:
+		c.Rule = config.MaskRuleIDCardCN
+	case CategoryName:
+		c.Rule = config.MaskRuleFakeName
+		if han > 0 {
+			c.Rule = config.MaskRuleNameCN
+		}
+	case CategoryCardNumber:
+		c.Rule = config.MaskRuleHash
+	case CategoryAddress:
+		c.Rule = config.MaskRuleFakeAddress
+	}
+	return c, true
+}
+
+// sampleStrings 取出非空的字符串样本并去掉首尾空白。
+func sampleStrings(samples []any) []string {
+	var out []string
+	for _, s := range samples {
+		var v string
+		switch val := s.(type) {
+		case string:
+			v = val
+		case []byte:
+			v = string(val)
+		default:
+			continue
+		}
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+var (
+	// 单独的 name 列常见于商品、部门等实体，只有带人员相关前缀时才视为姓名
+	personNamePrefixes = map[string]struct{}{
+		"full": {}, "real": {}, "first": {}, "last": {}, "sur": {}, "given": {}, "family": {},
+		"middle": {}, "nick": {}, "user": {}, "customer": {}, "contact": {}, "employee": {}, "person": {},
+		"patient": {}, "owner": {}, "holder": {}, "cardholder": {}, "account": {}, "member": {},
+		"receiver": {}, "recipient": {}, "buyer": {}, "consignee": {},
+	}
+	// 这些词出现时 address 指网络地址而不是住址
+	nonPostalAddress = []string{"ip", "mac", "email", "mail", "url", "web", "wallet", "host"}
+)
+
+// categoryFromName 依据列名猜测类别，未命中时返回空字符串。
+func categoryFromName(column string) string {
+	tokens := nameTokens(column)
+	joined := strings.Join(tokens, "")
+	has := func(words ...string) bool {
+		for _, t := range tokens {
+			for _, w := range words {
+				if t == w {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	contains := func(parts ...string) bool {
+		for _, p := range parts {
+			if strings.Contains(joined, p) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case contains("email") || has("mail"):
+		return CategoryEmail
+	case contains("idcard", "idno", "idnumber", "identity", "nationalid", "citizenid", "passport", "shenfenzheng") || has("ssn", "sfz"):
+		return CategoryIDCard
+	case contains("phone", "mobile", "msisdn") || has("tel", "cell"):
+		return CategoryPhone
+	case contains("cardno", "cardnum", "creditcard", "debitcard", "bankcard", "bankaccount", "iban") || has("pan"):
+		return CategoryCardNumber
+	case contains("address") || has("addr", "street"):
+		for _, t := range tokens {
+			for _, w := range nonPostalAddress {
+				if t == w {
+					return ""
+				}
+			}
+		}
+		return CategoryAddress
+	case strings.HasSuffix(joined, "name"):
+		if _, ok := personNamePrefixes[strings.TrimSuffix(joined, "name")]; ok {
+			return CategoryName
+		}
+	}
+	return ""
+}
+
+// nameTokens 按下划线、短横线等分隔符与驼峰边界拆分列名，结果为小写。
+func nameTokens(column string) []string {
+	var tokens []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			tokens = append(tokens, strings.ToLower(string(cur)))
+			cur = cur[:0]
+		}
+	}
+	runes := []rune(column)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			flush()
+			cur = append(cur, r)
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// validIDCardCN 校验 18 位居民身份证号的格式与校验位。
+func validIDCardCN(s string) bool {
+	if !idCardRegex.MatchString(s) {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	check := "10X98765432"[sum%11]
+	last := s[17]
+	if last == 'x' {
+		last = 'X'
+	}
+	return last == check
+}
+
+// luhnValid 按 Luhn 算法校验银行卡号。
+func luhnValid(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
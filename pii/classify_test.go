@@ -0,0 +1,89 @@
+package pii
+
+import (
+	"testing"
+
+	"db-ferry/config"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		name       string
+		column     string
+		samples    []any
+		category   string
+		rule       string
+		confidence string
+	}{
+		{"email by name and value", "contact_email", []any{"alice@example.com", "bob@example.org", nil}, CategoryEmail, config.MaskRuleEmail, ConfidenceHigh},
+		{"email by value only", "login", []any{"alice@example.com", []byte("bob@example.org")}, CategoryEmail, config.MaskRuleEmail, ConfidenceMedium},
+		{"cn phone", "mobile", []any{"13800138000", "+86 139-1234-5678"}, CategoryPhone, config.MaskRulePhoneCN, ConfidenceHigh},
+		{"us phone", "phoneNumber", []any{"(415) 555-2671", "212-555-0100"}, CategoryPhone, config.MaskRulePhoneUS, ConfidenceHigh},
+		{"id card", "id_card", []any{"11010519491231002X"}, CategoryIDCard, config.MaskRuleIDCardCN, ConfidenceHigh},
+		{"card number", "card_no", []any{"4111 1111 1111 1111", "5500-0000-0000-0004"}, CategoryCardNumber, config.MaskRuleHash, ConfidenceHigh},
+		{"chinese name", "real_name", []any{"张伟", "李娜"}, CategoryName, config.MaskRuleNameCN, ConfidenceHigh},
+		{"english name", "CustomerName", []any{"Alice Smith"}, CategoryName, config.MaskRuleFakeName, ConfidenceHigh},
+		{"address", "shipping_address", []any{"1 Main St"}, CategoryAddress, config.MaskRuleFakeAddress, ConfidenceHigh},
+		{"name only, empty table", "phone", nil, CategoryPhone, config.MaskRulePhoneCN, ConfidenceLow},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Classify(tc.column, tc.samples)
+			if !ok {
+				t.Fatalf("Classify(%q) found nothing", tc.column)
+			}
+			if got.Category != tc.category || got.Rule != tc.rule || got.Confidence != tc.confidence {
+				t.Fatalf("Classify(%q) = %+v, want %s/%s/%s", tc.column, got, tc.category, tc.rule, tc.confidence)
+			}
+		})
+	}
+}
+
+func TestClassifyIgnoresNonPII(t *testing.T) {
+	cases := []struct {
+		column  string
+		samples []any
+	}{
+		{"id", []any{int64(13800138000), int64(4111111111111111)}},
+		{"name", []any{"widget"}},
+		{"table_name", []any{"orders"}},
+		{"product_name", []any{"widget"}},
+		{"ip_address", []any{"10.0.0.1"}},
+		{"status", []any{"已完成", "处理中"}},
+		{"order_no", []any{"20260101000001", "20260101000002"}},
+	}
+	for _, tc := range cases {
+		if got, ok := Classify(tc.column, tc.samples); ok {
+			t.Fatalf("Classify(%q) = %+v, want no finding", tc.column, got)
+		}
+	}
+}
+
+func TestClassifyRequiresMostValuesToMatch(t *testing.T) {
+	samples := []any{"alice@example.com", "n/a", "unknown", "bob@example.com"}
+	if got, ok := Classify("remark", samples); ok {
+		t.Fatalf("Classify() = %+v, want no finding when few values match", got)
+	}
+}
+
+func TestNameTokens(t *testing.T) {
+	cases := map[string]string{
+		"customer_email": "customer|email",
+		"CustomerEmail":  "customer|email",
+		"userIDCard":     "user|id|card",
+		"PHONE":          "phone",
+	}
+	for in, want := range cases {
+		tokens := nameTokens(in)
+		got := ""
+		for i, tok := range tokens {
+			if i > 0 {
+				got += "|"
+			}
+			got += tok
+		}
+		if got != want {
+			t.Fatalf("nameTokens(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package pii
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// DefaultSampleSize is the number of rows sampled per task when none is given.
+const DefaultSampleSize = 100
+
+// Finding is a task column that likely holds personal data.
+type Finding struct {
+	Task     string `json:"task"`
+	SourceDB string `json:"source_db"`
+	Column   string `json:"column"`
+	Classification
+	// Masked is true when the task already has a masking rule for the column.
+	Masked bool `json:"masked"`
+}
+
+// Scan samples the query of every task that is not ignored and classifies its columns.
+// Federated tasks are skipped because their query runs on the in-memory join engine.
+func Scan(ctx context.Context, cfg *config.Config, sampleSize int) ([]Finding, error) {
+	manager := database.NewConnectionManager(cfg)
+	defer func() { _ = manager.CloseAll() }()
+
+	var findings []Finding
+	for _, task := range cfg.Tasks {
+		if task.Ignore || task.IsFederated() {
+			continue
+		}
+		srcCfg, ok := cfg.GetDatabase(task.SourceDB)
+		if !ok {
+			return nil, fmt.Errorf("task '%s': source_db %q not found", task.TableName, task.SourceDB)
+		}
+		src, err := manager.GetSource(task.SourceDB)
+		if err != nil {
+			return nil, fmt.Errorf("task '%s': %w", task.TableName, err)
+		}
+		taskFindings, err := ScanTask(ctx, src, srcCfg.Type, task, sampleSize)
+		if err != nil {
+			return nil, fmt.Errorf("task '%s': %w", task.TableName, err)
+		}
+		findings = append(findings, taskFindings...)
+	}
+	return findings, nil
+}
+
+// ScanTask reads the result columns of the task query with database.GetTableSchema,
+// samples up to sampleSize rows and classifies each column. dbType is the source
+// database type and selects the dialect of the row limit.
+func ScanTask(ctx context.Context, src database.SourceDB, dbType string, task config.TaskConfig, sampleSize int) ([]Finding, error) {
+	if sampleSize <= 0 {
+		sampleSize = DefaultSampleSize
+	}
+	query := fmt.Sprintf("(%s) db_ferry_pii", trimSQL(task.SQL))
+	columns, err := database.GetTableSchema(ctx, src, query)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := sampleColumns(ctx, src, sampleSQL(dbType, query, sampleSize), len(columns), sampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	masked := make(map[string]bool, len(task.Masking))
+	for _, m := range task.Masking {
+		masked[strings.ToLower(m.Column)] = true
+	}
+
+	var findings []Finding
+	for i, col := range columns {
+		c, ok := Classify(col.Name, samples[i])
+		if !ok {
+			continue
+		}
+		findings = append(findings, Finding{
+			Task:           task.TableName,
+			SourceDB:       task.SourceDB,
+			Column:         col.Name,
+			Classification: c,
+			Masked:         masked[strings.ToLower(col.Name)],
+		})
+	}
+	return findings, nil
+}
+
+// sampleSQL 在 SQL 中限制抽样行数：驱动关闭结果集时会读完剩余的行，只停止调用 Next 并不能避免全表传输。
+func sampleSQL(dbType, relation string, limit int) string {
+	switch dbType {
+	case config.DatabaseTypeSQLServer:
+		return fmt.Sprintf("SELECT TOP %d * FROM %s", limit, relation)
+	case config.DatabaseTypeOracle:
+		return fmt.Sprintf("SELECT * FROM %s FETCH FIRST %d ROWS ONLY", relation, limit)
+	default:
+		return fmt.Sprintf("SELECT * FROM %s LIMIT %d", relation, limit)
+	}
+}
+
+// sampleColumns 执行抽样查询并按列返回至多 limit 行样本；关闭结果集前先取消查询，不等待剩余结果。
+func sampleColumns(ctx context.Context, src database.SourceDB, sqlText string, width, limit int) ([][]any, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rows, err := src.Query(ctx, sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample rows: %w", err)
+	}
+	defer func() {
+		cancel()
+		_ = rows.Close()
+	}()
+
+	samples := make([][]any, width)
+	for n := 0; n < limit && rows.Next(); n++ {
+		values := make([]any, width)
+		ptrs := make([]any, width)
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, fmt.Errorf("failed to sample rows: %w", err)
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			samples[i] = append(samples[i], v)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to sample rows: %w", err)
+	}
+	return samples, nil
+}
+
+// Unmasked returns the findings whose column has no masking rule yet.
+func Unmasked(findings []Finding) []Finding {
+	var out []Finding
+	for _, f := range findings {
+		if !f.Masked {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// WriteTOML writes ready-to-paste [[tasks.masking]] blocks for the unmasked findings,
+// grouped under a comment naming the task they belong to.
+func WriteTOML(w io.Writer, findings []Finding) error {
+	task := ""
+	for i, f := range Unmasked(findings) {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if i == 0 || f.Task != task {
+			task = f.Task
+			if _, err := fmt.Fprintf(w, "# task: %s (source_db = %q)\n", f.Task, f.SourceDB); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "[[tasks.masking]]\n# %s, %s confidence, %d/%d sampled values matched\ncolumn = %q\nrule = %q\n",
+			f.Category, f.Confidence, f.Matched, f.Sampled, f.Column, f.Rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func trimSQL(sqlText string) string {
+	trimmed := strings.TrimSpace(sqlText)
+	for strings.HasSuffix(trimmed, ";") {
+		trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, ";"))
+	}
+	return trimmed
+}
@@ -0,0 +1,131 @@
+package pii
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func createCustomersDB(t *testing.T, path string) {
+	t.Helper()
+	db, err := database.NewSQLiteDB(path, 0, 0, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteDB() error = %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.Exec(ctx, `CREATE TABLE customers (id INTEGER PRIMARY KEY, full_name TEXT, email TEXT, mobile TEXT, note TEXT)`); err != nil {
+		t.Fatalf("create table error = %v", err)
+	}
+	if err := db.Exec(ctx, `INSERT INTO customers VALUES
+		(1, '张伟', 'zhang@example.com', '13800138000', 'vip'),
+		(2, '李娜', 'li@example.com', '13912345678', NULL)`); err != nil {
+		t.Fatalf("insert rows error = %v", err)
+	}
+}
+
+func TestScanTask(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "src.db")
+	createCustomersDB(t, path)
+	src, err := database.NewSQLiteDB(path, 0, 0, "")
+	if err != nil {
+		t.Fatalf("NewSQLiteDB() error = %v", err)
+	}
+	defer src.Close()
+
+	task := config.TaskConfig{
+		TableName: "customers",
+		SQL:       "SELECT * FROM customers;",
+		SourceDB:  "src",
+		Masking:   []config.MaskingConfig{{Column: "EMAIL", Rule: config.MaskRuleEmail}},
+	}
+	findings, err := ScanTask(context.Background(), src, config.DatabaseTypeSQLite, task, 10)
+	if err != nil {
+		t.Fatalf("ScanTask() error = %v", err)
+	}
+
+	got := make(map[string]Finding)
+	for _, f := range findings {
+		got[f.Column] = f
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected findings for full_name, email and mobile, got %+v", findings)
+	}
+	if f := got["full_name"]; f.Rule != config.MaskRuleNameCN || f.Masked {
+		t.Fatalf("full_name finding = %+v", f)
+	}
+	if f := got["email"]; !f.Masked {
+		t.Fatalf("expected email to be reported as already masked, got %+v", f)
+	}
+	if f := got["mobile"]; f.Rule != config.MaskRulePhoneCN || f.Matched != 2 || f.Sampled != 2 {
+		t.Fatalf("mobile finding = %+v", f)
+	}
+}
+
+func TestScanSkipsIgnoredTasks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "src.db")
+	createCustomersDB(t, path)
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{{Name: "src", Type: config.DatabaseTypeSQLite, Path: path}},
+		Tasks: []config.TaskConfig{
+			{TableName: "customers_copy", SQL: "SELECT id, mobile FROM customers", SourceDB: "src", TargetDB: "src", AllowSameTable: true},
+			{TableName: "ignored", SQL: "SELECT * FROM missing", SourceDB: "src", TargetDB: "src", AllowSameTable: true, Ignore: true},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	findings, err := Scan(context.Background(), cfg, 0)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(findings) != 1 || findings[0].Task != "customers_copy" || findings[0].Column != "mobile" {
+		t.Fatalf("Scan() = %+v", findings)
+	}
+}
+
+func TestWriteTOML(t *testing.T) {
+	findings := []Finding{
+		{Task: "customers", SourceDB: "src", Column: "email", Classification: Classification{Category: CategoryEmail, Rule: config.MaskRuleEmail, Confidence: ConfidenceHigh, Matched: 2, Sampled: 2}},
+		{Task: "customers", SourceDB: "src", Column: "mobile", Classification: Classification{Category: CategoryPhone, Rule: config.MaskRulePhoneCN}, Masked: true},
+		{Task: "orders", SourceDB: "src", Column: "card_no", Classification: Classification{Category: CategoryCardNumber, Rule: config.MaskRuleHash, Confidence: ConfidenceLow}},
+	}
+	var buf bytes.Buffer
+	if err := WriteTOML(&buf, findings); err != nil {
+		t.Fatalf("WriteTOML() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# task: customers (source_db = \"src\")\n[[tasks.masking]]\n",
+		"column = \"email\"\nrule = \"email\"\n",
+		"# task: orders (source_db = \"src\")\n",
+		"column = \"card_no\"\nrule = \"hash\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("WriteTOML() output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "mobile") {
+		t.Fatalf("expected masked columns to be left out:\n%s", out)
+	}
+}
+
+func TestSampleSQL(t *testing.T) {
+	relation := "(SELECT * FROM customers) db_ferry_pii"
+	cases := map[string]string{
+		config.DatabaseTypeMySQL:      "SELECT * FROM (SELECT * FROM customers) db_ferry_pii LIMIT 20",
+		config.DatabaseTypePostgreSQL: "SELECT * FROM (SELECT * FROM customers) db_ferry_pii LIMIT 20",
+		config.DatabaseTypeSQLServer:  "SELECT TOP 20 * FROM (SELECT * FROM customers) db_ferry_pii",
+		config.DatabaseTypeOracle:     "SELECT * FROM (SELECT * FROM customers) db_ferry_pii FETCH FIRST 20 ROWS ONLY",
+	}
+	for dbType, want := range cases {
+		if got := sampleSQL(dbType, relation, 20); got != want {
+			t.Fatalf("sampleSQL(%s) = %q, want %q", dbType, got, want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// cdcDeleteMaxParams bounds the bind arguments per DELETE/UPDATE statement,
+// staying well below SQL Server's limit of 2100 parameters.
+const cdcDeleteMaxParams = 1000
+
+// reconcileCDCDeletes detects rows deleted at the source and deletes or flags
+// them in the target table. It returns the number of affected target rows.
+func (p *Processor) reconcileCDCDeletes(ctx context.Context, sourceDB database.SourceDB, sourceType string, targetDB database.TargetDB, targetType string, task config.TaskConfig, mergeKeys []string) (int, error) {
+	cdc := task.CDC
+	if cdc.DeleteAction == config.CDCDeleteActionFlag {
+		flagCol := []database.ColumnMetadata{{Name: cdc.DeleteFlagColumn, DatabaseType: "INTEGER"}}
+		if err := database.SyncSchema(ctx, targetDB, targetType, task.TableName, flagCol); err != nil {
+			return 0, fmt.Errorf("failed to ensure delete flag column: %w", err)
+		}
+	}
+
+	targetKeys, err := loadTargetKeys(ctx, targetDB, targetType, task, mergeKeys, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load target keys: %w", err)
+	}
+	// Flagged rows whose key is live again at the source get the flag cleared,
+	// since the merge upsert only writes the source columns.
+	var flaggedKeys map[string][]any
+	if cdc.DeleteAction == config.CDCDeleteActionFlag {
+		flaggedKeys, err = loadTargetKeys(ctx, targetDB, targetType, task, mergeKeys, true)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load flagged target keys: %w", err)
+		}
+	}
+	if len(targetKeys) == 0 && len(flaggedKeys) == 0 {
+		return 0, nil
+	}
+
+	sourceKeys, err := queryKeySet(ctx, sourceDB, buildSourceKeysSQL(sourceType, task, mergeKeys))
+	if err != nil {
+		return 0, fmt.Errorf("failed to load source keys: %w", err)
+	}
+
+	softDelete := cdc.DeleteStrategy == config.CDCDeleteStrategySoftDelete
+	// key_set: gone from the source; soft_delete: marked deleted at the source.
+	deletedAtSource := func(k string) bool {
+		_, inSource := sourceKeys[k]
+		return inSource == softDelete
+	}
+	var deleted, revived [][]any
+	for k, values := range targetKeys {
+		if deletedAtSource(k) {
+			deleted = append(deleted, values)
+		}
+	}
+	for k, values := range flaggedKeys {
+		if !deletedAtSource(k) {
+			revived = append(revived, values)
+		}
+	}
+
+	if len(revived) > 0 {
+		log.Printf("[cdc] Clearing %s on %d rows of table %s that are live again at the source", cdc.DeleteFlagColumn, len(revived), task.TableName)
+		if err := execByKeyChunks(ctx, targetDB, revived, mergeKeys, func(chunk [][]any) (string, []any) {
+			return database.BuildFlagByKeysSQL(targetType, task.TableName, cdc.DeleteFlagColumn, 0, mergeKeys, chunk)
+		}); err != nil {
+			return 0, fmt.Errorf("failed to clear %s for revived rows: %w", cdc.DeleteFlagColumn, err)
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	// The merge above has just written every source row, so a key_set round in
+	// which no target key matches a live source key means the two sides encode
+	// the keys differently rather than that everything was deleted.
+	if !softDelete && len(sourceKeys) > 0 && len(deleted) == len(targetKeys) {
+		return 0, fmt.Errorf("refusing to %s all %d target rows: none of the %d source keys matched a target key; check that the merge key columns have compatible types on both sides", cdc.DeleteAction, len(deleted), len(sourceKeys))
+	}
+
+	log.Printf("[cdc] Detected %d deleted rows for table %s (%s), applying %s", len(deleted), task.TableName, cdc.DeleteStrategy, cdc.DeleteAction)
+	err = execByKeyChunks(ctx, targetDB, deleted, mergeKeys, func(chunk [][]any) (string, []any) {
+		if cdc.DeleteAction == config.CDCDeleteActionFlag {
+			return database.BuildFlagByKeysSQL(targetType, task.TableName, cdc.DeleteFlagColumn, 1, mergeKeys, chunk)
+		}
+		return database.BuildDeleteByKeysSQL(targetType, task.TableName, mergeKeys, chunk)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply %s for deleted rows: %w", cdc.DeleteAction, err)
+	}
+
+	return len(deleted), nil
+}
+
+// execByKeyChunks runs the statement built for each chunk of keys, keeping the
+// bind arguments per statement under cdcDeleteMaxParams.
+func execByKeyChunks(ctx context.Context, targetDB database.TargetDB, keys [][]any, mergeKeys []string, build func(chunk [][]any) (string, []any)) error {
+	chunkSize := max(1, cdcDeleteMaxParams/len(mergeKeys))
+	for start := 0; start < len(keys); start += chunkSize {
+		stmt, args := build(keys[start:min(start+chunkSize, len(keys))])
+		if err := targetDB.Exec(ctx, stmt, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildSourceKeysSQL selects the merge keys of live rows (key_set) or of
+// soft-deleted rows (soft_delete) from the unfiltered task query.
+func buildSourceKeysSQL(sourceType string, task config.TaskConfig, mergeKeys []string) string {
+	cols := make([]string, len(mergeKeys))
+	for i, key := range mergeKeys {
+		cols[i] = sourceColumnFor(task.Columns, key)
+	}
+	// The task SQL is used without the cursor filter (config rejects
+	// {{.LastValue}} here) so the key set covers every row the task owns,
+	// including rows at or below cdc.initial_cursor.
+	keysSQL := fmt.Sprintf("SELECT %s FROM (%s) __cdc_src", strings.Join(cols, ", "), trimSQL(task.SQL))
+
+	if task.CDC.DeleteStrategy == config.CDCDeleteStrategySoftDelete {
+		column := database.QuoteIdentifier(sourceType, task.CDC.SoftDeleteColumn)
+		if task.CDC.SoftDeleteValue != "" {
+			keysSQL += fmt.Sprintf(" WHERE %s = %s", column, task.CDC.SoftDeleteValue)
+		} else {
+			keysSQL += fmt.Sprintf(" WHERE %s IS NOT NULL", column)
+		}
+	}
+	return keysSQL
+}
+
+// loadTargetKeys returns the merge keys of the target rows; with the flag
+// action it returns either the unflagged or the flagged rows.
+func loadTargetKeys(ctx context.Context, targetDB database.TargetDB, targetType string, task config.TaskConfig, mergeKeys []string, flagged bool) (map[string][]any, error) {
+	cols := make([]string, len(mergeKeys))
+	for i, key := range mergeKeys {
+		cols[i] = database.QuoteIdentifier(targetType, key)
+	}
+	keysSQL := fmt.Sprintf("SELECT %s FROM %s", strings.Join(cols, ", "), database.QuoteIdentifier(targetType, task.TableName))
+	if task.CDC.DeleteAction == config.CDCDeleteActionFlag {
+		flag := database.QuoteIdentifier(targetType, task.CDC.DeleteFlagColumn)
+		if flagged {
+			keysSQL += fmt.Sprintf(" WHERE %s = 1", flag)
+		} else {
+			keysSQL += fmt.Sprintf(" WHERE %s IS NULL OR %s = 0", flag, flag)
+		}
+	}
+	return queryKeySet(ctx, targetDB, keysSQL)
+}
+
+type keyQueryer interface {
+	Query(ctx context.Context, sql string) (*sql.Rows, error)
+}
+
+func queryKeySet(ctx context.Context, q keyQueryer, keysSQL string) (map[string][]any, error) {
+	rows, err := q.Query(ctx, keysSQL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]string, len(colTypes))
+	for i, ct := range colTypes {
+		cols[i] = strings.ToUpper(ct.DatabaseTypeName())
+	}
+
+	keys := make(map[string][]any)
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		keys[typedKeySignature(values, cols)] = values
+	}
+	return keys, rows.Err()
+}
+
+func keySignature(values []any) string {
+	return typedKeySignature(values, nil)
+}
+
+// typedKeySignature joins the normalized key values; typeNames holds the
+// upper-cased database type of each key column and may be nil.
+func typedKeySignature(values []any, typeNames []string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		typeName := ""
+		if i < len(typeNames) {
+			typeName = typeNames[i]
+		}
+		parts[i] = keyValueSignature(v, typeName)
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// keyValueSignature normalizes one key value so that source and target agree
+// on it even when their drivers return different Go types: numbers (int64,
+// float64, or DECIMAL text) become their exact decimal value, DATE values
+// their calendar date and other times a UTC instant. Zone-less DATETIME text
+// is read as UTC, the zone db-ferry writes times in.
+func keyValueSignature(v any, typeName string) string {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	switch val := v.(type) {
+	case nil:
+		return "\x01"
+	case time.Time:
+		if typeName == "DATE" {
+			return val.Format(time.DateOnly)
+		}
+		return val.UTC().Format(time.RFC3339Nano)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(val)
+	case float32:
+		return canonicalDecimal(strconv.FormatFloat(float64(val), 'f', -1, 32))
+	case float64:
+		return canonicalDecimal(strconv.FormatFloat(val, 'f', -1, 64))
+	case string:
+		switch {
+		case isNumericKeyType(typeName):
+			return canonicalDecimal(strings.TrimSpace(val))
+		case typeName == "DATE":
+			if t, err := time.Parse(time.DateOnly, strings.TrimSpace(val)); err == nil {
+				return t.Format(time.DateOnly)
+			}
+		case strings.Contains(typeName, "TIME"):
+			for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999"} {
+				if t, err := time.Parse(layout, strings.TrimSpace(val)); err == nil {
+					return t.UTC().Format(time.RFC3339Nano)
+				}
+			}
+		}
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func isNumericKeyType(typeName string) bool {
+	for _, marker := range []string{"INT", "DEC", "NUM", "FLOAT", "DOUBLE", "REAL", "MONEY"} {
+		if strings.Contains(typeName, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// canonicalDecimal returns the exact value of a decimal string, so "10",
+// "10.0" and "1e1" agree; other strings are returned unchanged.
+func canonicalDecimal(s string) string {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return s
+	}
+	return r.RatString()
+}
+
+// sourceColumnFor maps a target column name back to its source column.
+func sourceColumnFor(mappings []config.ColumnMapping, target string) string {
+	for _, m := range mappings {
+		if strings.EqualFold(m.Target, target) {
+			return m.Source
+		}
+	}
+	return target
+}
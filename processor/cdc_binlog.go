@@ -0,0 +1,630 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// processBinlogCDCTask replays MySQL binlog row events for the task. The first
+// run records the current binlog position and takes a snapshot through the
+// regular task path; later runs apply the events written since the saved
+// position and persist the new position ("file:pos") in state_file.
+func (p *Processor) processBinlogCDCTask(ctx context.Context, task config.TaskConfig, silent bool) (err error) {
+	sourceDB, err := p.manager.GetSource(task.SourceDB)
+	if err != nil {
+		return err
+	}
+
+	state, err := p.loadStateFile(task.StateFile)
+	if err != nil {
+		return err
+	}
+	key := p.taskKey(task)
+	if state.Tasks[key] == "" {
+		return p.snapshotBinlogCDCTask(ctx, sourceDB, task, silent)
+	}
+
+	start := time.Now()
+	applied, deleted := 0, 0
+	defer func() {
+		p.metrics.RecordTaskDuration(task.TableName, task.SourceDB, task.TargetDB, float64(time.Since(start).Milliseconds()))
+		status, errMsg := "success", ""
+		if err != nil {
+			status, errMsg = "failed", err.Error()
+		}
+		p.recordTaskResult(TaskResult{
+			Name:    task.TableName,
+			Rows:    applied,
+			Deleted: deleted,
+			Status:  status,
+			Error:   errMsg,
+		})
+	}()
+
+	pos, err := database.ParseBinlogPosition(state.Tasks[key])
+	if err != nil {
+		return fmt.Errorf("invalid binlog position in state file for table %s: %w", task.TableName, err)
+	}
+
+	sourceDBCfg, ok := p.config.GetDatabase(task.SourceDB)
+	if !ok {
+		return fmt.Errorf("source_db '%s' is not defined", task.SourceDB)
+	}
+	targetDB, err := p.manager.GetTarget(task.TargetDB)
+	if err != nil {
+		return err
+	}
+	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
+	if !ok {
+		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
+	}
+	targetCols, err := targetDB.GetTableColumns(task.TableName)
+	if err != nil {
+		return fmt.Errorf("failed to read target columns for table %s: %w", task.TableName, err)
+	}
+
+	checksum, err := checkBinlogSettings(ctx, sourceDB)
+	if err != nil {
+		return err
+	}
+	loc, err := querySessionLocation(ctx, sourceDB)
+	if err != nil {
+		return fmt.Errorf("failed to read source time_zone: %w", err)
+	}
+
+	applier := &binlogApplier{
+		cdcRowWriter: newCDCRowWriter(ctx, p, targetDB, targetDBCfg.Type, task, targetCols, "set binlog_row_image = FULL or use primary key columns as merge_keys"),
+		sourceDB:     sourceDB,
+		parser:       database.NewBinlogParser(checksum),
+		file:         pos.File,
+	}
+	applier.parser.SetLocation(loc)
+	applier.schema, applier.table = sourceDBCfg.Database, task.CDC.SourceTable
+	if schema, table, ok := strings.Cut(task.CDC.SourceTable, "."); ok {
+		applier.schema, applier.table = schema, table
+	}
+
+	conn, err := database.DialBinlog(sourceDBCfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, conn.Interrupt)
+	defer stop()
+	if err := conn.Exec("SET @master_binlog_checksum = @@global.binlog_checksum"); err != nil {
+		return fmt.Errorf("failed to prepare binlog stream: %w", err)
+	}
+	if err := conn.StartDump(pos); err != nil {
+		return fmt.Errorf("failed to request binlog from %s: %w", pos, err)
+	}
+
+	batchSize := task.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	// Positions are saved only after the rows before them have been flushed. A
+	// crash in between replays those transactions, which upserts and deletes
+	// by key make harmless.
+	checkpoint := pos
+	save := func() error {
+		if err := applier.flush(); err != nil {
+			return err
+		}
+		if checkpoint == pos {
+			return nil
+		}
+		state.Tasks[key] = checkpoint.String()
+		if err := p.saveStateFile(task.StateFile, state); err != nil {
+			return fmt.Errorf("failed to save state file %s: %w", task.StateFile, err)
+		}
+		pos = checkpoint
+		return nil
+	}
+
+	for {
+		data, err := conn.ReadEvent()
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			return fmt.Errorf("failed to read binlog after %s: %w", checkpoint, err)
+		}
+		if data == nil {
+			break
+		}
+		next, ok, err := applier.handle(data)
+		applied, deleted = applier.upserted, applier.deleted
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		checkpoint = next
+		if len(applier.pending) >= batchSize {
+			if err := save(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := save(); err != nil {
+		return err
+	}
+	applied, deleted = applier.upserted, applier.deleted
+
+	p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, int64(applied))
+	p.metrics.RecordDeletedRows(task.TableName, task.SourceDB, task.TargetDB, int64(deleted))
+	if applier.gtid != "" {
+		log.Printf("[cdc] Applied %d upserts and %d deletes for table %s from binlog (position %s, gtid %s)",
+			applied, deleted, task.TableName, checkpoint, applier.gtid)
+	} else {
+		log.Printf("[cdc] Applied %d upserts and %d deletes for table %s from binlog (position %s)",
+			applied, deleted, task.TableName, checkpoint)
+	}
+	return nil
+}
+
+// snapshotBinlogCDCTask records the binlog position before copying the table
+// so that no change committed during the snapshot is lost.
+func (p *Processor) snapshotBinlogCDCTask(ctx context.Context, sourceDB database.SourceDB, task config.TaskConfig, silent bool) error {
+	if _, err := checkBinlogSettings(ctx, sourceDB); err != nil {
+		return err
+	}
+	pos, err := queryBinlogPosition(ctx, sourceDB)
+	if err != nil {
+		return fmt.Errorf("failed to read binlog position: %w", err)
+	}
+	log.Printf("[cdc] Taking initial snapshot of %s at binlog position %s", task.TableName, pos)
+
+	snapshot := task
+	snapshot.CDC = config.CDCConfig{}
+	if err := p.processTaskInternal(ctx, snapshot, silent); err != nil {
+		return err
+	}
+
+	state, err := p.loadStateFile(task.StateFile)
+	if err != nil {
+		return err
+	}
+	state.Tasks[p.taskKey(task)] = pos.String()
+	if err := p.saveStateFile(task.StateFile, state); err != nil {
+		return fmt.Errorf("failed to save state file %s: %w", task.StateFile, err)
+	}
+	return nil
+}
+
+// checkBinlogSettings verifies that the source writes row-based binlogs and
+// reports whether events carry CRC32 checksums.
+func checkBinlogSettings(ctx context.Context, sourceDB database.SourceDB) (bool, error) {
+	rows, err := sourceDB.Query(ctx, "SELECT @@global.binlog_format, @@global.binlog_checksum")
+	if err != nil {
+		return false, fmt.Errorf("failed to read binlog settings: %w", err)
+	}
+	defer rows.Close()
+	var format, checksum string
+	if rows.Next() {
+		if err := rows.Scan(&format, &checksum); err != nil {
+			return false, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(format, "ROW") {
+		return false, fmt.Errorf("cdc.mode %q requires binlog_format = ROW, source uses %q", config.CDCModeBinlog, format)
+	}
+	return strings.EqualFold(checksum, "CRC32"), nil
+}
+
+// querySessionLocation returns the time_zone the snapshot query session uses,
+// so that TIMESTAMP values read from the binlog are rendered as a SELECT
+// renders them. Zones missing from the local tz database fall back to the
+// offset the server currently applies.
+func querySessionLocation(ctx context.Context, sourceDB database.SourceDB) (*time.Location, error) {
+	rows, err := sourceDB.Query(ctx, "SELECT @@session.time_zone, @@global.system_time_zone, TIMESTAMPDIFF(SECOND, UTC_TIMESTAMP(), NOW())")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var zone, systemZone string
+	var offset int
+	if rows.Next() {
+		if err := rows.Scan(&zone, &systemZone, &offset); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return resolveMySQLTimeZone(zone, systemZone, offset), nil
+}
+
+func resolveMySQLTimeZone(zone, systemZone string, offset int) *time.Location {
+	if strings.EqualFold(zone, "SYSTEM") {
+		zone = systemZone
+	}
+	// Offsets are written as "+08:00" or "-05:30".
+	if h, m, ok := strings.Cut(zone, ":"); ok && (zone[0] == '+' || zone[0] == '-') {
+		hours, herr := strconv.Atoi(h[1:])
+		minutes, merr := strconv.Atoi(m)
+		if herr == nil && merr == nil {
+			secs := hours*3600 + minutes*60
+			if zone[0] == '-' {
+				secs = -secs
+			}
+			return time.FixedZone(zone, secs)
+		}
+	}
+	if strings.EqualFold(zone, "UTC") {
+		return time.UTC
+	}
+	// Abbreviations such as "CST" are ambiguous and not loadable.
+	if loc, err := time.LoadLocation(zone); err == nil && strings.Contains(zone, "/") {
+		return loc
+	}
+	return time.FixedZone(zone, offset)
+}
+
+func queryBinlogPosition(ctx context.Context, sourceDB database.SourceDB) (database.BinlogPosition, error) {
+	rows, err := sourceDB.Query(ctx, "SHOW MASTER STATUS")
+	if err != nil {
+		// MySQL 8.4 removed SHOW MASTER STATUS in favour of SHOW BINARY LOG STATUS.
+		rows, err = sourceDB.Query(ctx, "SHOW BINARY LOG STATUS")
+		if err != nil {
+			return database.BinlogPosition{}, err
+		}
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return database.BinlogPosition{}, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return database.BinlogPosition{}, err
+		}
+		return database.BinlogPosition{}, fmt.Errorf("binary logging is not enabled on the source")
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]any, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return database.BinlogPosition{}, err
+	}
+	if len(values) < 2 {
+		return database.BinlogPosition{}, fmt.Errorf("unexpected binlog status columns %v", cols)
+	}
+	return database.ParseBinlogPosition(values[0].String + ":" + values[1].String)
+}
+
+// binlogColumn is a source column as described by information_schema.
+type binlogColumn struct {
+	meta     database.ColumnMetadata
+	unsigned bool
+	text     bool
+	// labels holds ENUM/SET members, which the binlog sends as indexes.
+	labels []string
+	set    bool
+}
+
+// binlogApplier applies binlog row events of one source table to the target.
+type binlogApplier struct {
+	*cdcRowWriter
+	sourceDB database.SourceDB
+	parser   *database.BinlogParser
+	schema   string
+	table    string
+	// columns is loaded on the first TABLE_MAP of the table and dropped after
+	// DDL so that it is read again.
+	columns []binlogColumn
+
+	file string
+	gtid string
+	txn  []*database.BinlogEvent
+	// savepoints maps savepoint names to the length of txn when they were set.
+	savepoints map[string]int
+}
+
+// handle processes one raw event. When the event ends a transaction, or is a
+// statement committed on its own, it returns the position after it: a safe
+// point to resume from.
+func (a *binlogApplier) handle(data []byte) (database.BinlogPosition, bool, error) {
+	ev, err := a.parser.Parse(data)
+	if err != nil {
+		return database.BinlogPosition{}, false, err
+	}
+	switch {
+	case ev.Type == database.BinlogRotateEvent:
+		a.file = ev.NextFile
+		if len(a.txn) == 0 {
+			return database.BinlogPosition{File: a.file, Pos: ev.NextPos}, true, nil
+		}
+	case ev.Type == database.BinlogGTIDEvent:
+		a.gtid = ev.GTID
+	case ev.Type == database.BinlogTableMapEvent:
+		if a.matches(ev.TableMap) {
+			if err := a.describe(ev.TableMap); err != nil {
+				return database.BinlogPosition{}, false, err
+			}
+		}
+	case ev.Type == database.BinlogXIDEvent:
+		return a.commit(ev)
+	case ev.Type == database.BinlogXAPrepareEvent:
+		// The rows of a prepared XA transaction are only final once a later
+		// XA COMMIT arrives, possibly after other transactions.
+		if len(a.txn) > 0 {
+			return database.BinlogPosition{}, false, fmt.Errorf("two-phase XA transactions on %s.%s are not supported by cdc.mode %q", a.schema, a.table, config.CDCModeBinlog)
+		}
+	case ev.Type == database.BinlogQueryEvent:
+		return a.handleQuery(ev)
+	case ev.IsRowsEvent():
+		if ev.Table != nil && ev.Rows != nil && a.matches(ev.Table) {
+			a.txn = append(a.txn, ev)
+		}
+	}
+	return database.BinlogPosition{}, false, nil
+}
+
+// handleQuery handles QUERY events. Under binlog_format = ROW these carry
+// transaction control and DDL; only COMMIT, a one-phase XA COMMIT and DDL end a
+// transaction.
+func (a *binlogApplier) handleQuery(ev *database.BinlogEvent) (database.BinlogPosition, bool, error) {
+	words := strings.Fields(strings.ToUpper(stripLeadingSQLComments(ev.Query)))
+	if len(words) == 0 {
+		return database.BinlogPosition{}, false, nil
+	}
+	switch words[0] {
+	case "BEGIN":
+		a.txn, a.savepoints = a.txn[:0], nil
+	case "COMMIT":
+		return a.commit(ev)
+	case "ROLLBACK":
+		if len(words) > 1 && words[1] == "TO" {
+			name := strings.Trim(words[len(words)-1], "`")
+			if n, ok := a.savepoints[name]; ok && n <= len(a.txn) {
+				a.txn = a.txn[:n]
+			}
+			return database.BinlogPosition{}, false, nil
+		}
+		a.txn, a.savepoints = a.txn[:0], nil
+	case "SAVEPOINT":
+		if len(words) > 1 {
+			if a.savepoints == nil {
+				a.savepoints = make(map[string]int)
+			}
+			a.savepoints[strings.Trim(words[1], "`")] = len(a.txn)
+		}
+	case "RELEASE":
+		// RELEASE SAVEPOINT keeps the rows.
+	case "XA":
+		if len(words) < 2 {
+			return database.BinlogPosition{}, false, nil
+		}
+		switch words[1] {
+		case "START", "BEGIN":
+			a.txn, a.savepoints = a.txn[:0], nil
+		case "COMMIT":
+			// One-phase commits follow the rows directly; a two-phase commit
+			// has nothing pending here because XA_PREPARE rejected its rows.
+			return a.commit(ev)
+		case "ROLLBACK":
+			a.txn, a.savepoints = a.txn[:0], nil
+		}
+	case "CREATE", "ALTER", "DROP", "RENAME", "TRUNCATE":
+		// DDL commits implicitly and may change the table definition.
+		a.columns = nil
+		return a.commit(ev)
+	}
+	return database.BinlogPosition{}, false, nil
+}
+
+// stripLeadingSQLComments removes /* ... */ comments MySQL may write before a
+// logged statement.
+func stripLeadingSQLComments(query string) string {
+	query = strings.TrimSpace(query)
+	for strings.HasPrefix(query, "/*") {
+		end := strings.Index(query, "*/")
+		if end < 0 {
+			return query
+		}
+		query = strings.TrimSpace(query[end+2:])
+	}
+	return query
+}
+
+func (a *binlogApplier) matches(tm *database.BinlogTableMap) bool {
+	return strings.EqualFold(tm.Schema, a.schema) && strings.EqualFold(tm.Table, a.table)
+}
+
+func (a *binlogApplier) commit(ev *database.BinlogEvent) (database.BinlogPosition, bool, error) {
+	txn := a.txn
+	a.txn, a.savepoints = nil, nil
+	for _, rowsEv := range txn {
+		if err := a.apply(rowsEv); err != nil {
+			return database.BinlogPosition{}, false, err
+		}
+	}
+	if ev.NextPos == 0 {
+		return database.BinlogPosition{}, false, nil
+	}
+	return database.BinlogPosition{File: a.file, Pos: ev.NextPos}, true, nil
+}
+
+// describe loads the column names of the table, which TABLE_MAP events do not
+// carry, and attaches them to the table map so its rows get decoded.
+func (a *binlogApplier) describe(tm *database.BinlogTableMap) error {
+	if a.columns == nil {
+		query := fmt.Sprintf(
+			"SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = %s AND TABLE_NAME = %s ORDER BY ORDINAL_POSITION",
+			quoteSQLString(tm.Schema), quoteSQLString(tm.Table))
+		rows, err := a.sourceDB.Query(a.ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to describe %s.%s: %w", tm.Schema, tm.Table, err)
+		}
+		defer rows.Close()
+		var columns []binlogColumn
+		for rows.Next() {
+			var name, dataType, columnType string
+			if err := rows.Scan(&name, &dataType, &columnType); err != nil {
+				return fmt.Errorf("failed to describe %s.%s: %w", tm.Schema, tm.Table, err)
+			}
+			columns = append(columns, newBinlogColumn(name, dataType, columnType))
+		}
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to describe %s.%s: %w", tm.Schema, tm.Table, err)
+		}
+		a.columns = columns
+	}
+	if len(a.columns) != len(tm.Types) {
+		return fmt.Errorf("table %s.%s has %d columns but its binlog events have %d; the table definition changed after the saved position",
+			tm.Schema, tm.Table, len(a.columns), len(tm.Types))
+	}
+	tm.Columns = make([]string, len(a.columns))
+	tm.Unsigned = make([]bool, len(a.columns))
+	for i, col := range a.columns {
+		tm.Columns[i] = col.meta.Name
+		tm.Unsigned[i] = col.unsigned
+	}
+	return nil
+}
+
+func newBinlogColumn(name, dataType, columnType string) binlogColumn {
+	dataType = strings.ToLower(dataType)
+	col := binlogColumn{
+		meta:     database.ColumnMetadata{Name: name, DatabaseType: strings.ToUpper(dataType)},
+		unsigned: strings.Contains(strings.ToLower(columnType), "unsigned"),
+	}
+	switch dataType {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		col.text = true
+	case "enum", "set":
+		col.set = dataType == "set"
+		if start, end := strings.IndexByte(columnType, '('), strings.LastIndexByte(columnType, ')'); start >= 0 && end > start {
+			for _, label := range strings.Split(columnType[start+1:end], ",") {
+				label = strings.TrimSuffix(strings.TrimPrefix(label, "'"), "'")
+				col.labels = append(col.labels, strings.ReplaceAll(label, "''", "'"))
+			}
+		}
+	}
+	return col
+}
+
+// value converts a decoded binlog value to the form the regular query path
+// would produce for the column.
+func (c binlogColumn) value(v any) any {
+	switch x := v.(type) {
+	case []byte:
+		if c.text {
+			return string(x)
+		}
+	case int64:
+		if c.labels == nil {
+			return v
+		}
+		if !c.set {
+			if x >= 1 && int(x) <= len(c.labels) {
+				return c.labels[x-1]
+			}
+			return ""
+		}
+		var members []string
+		for i, label := range c.labels {
+			if x&(1<<i) != 0 {
+				members = append(members, label)
+			}
+		}
+		return strings.Join(members, ",")
+	}
+	return v
+}
+
+// image builds the source columns and values present in a row image.
+func (a *binlogApplier) image(values []any, present []bool) ([]database.ColumnMetadata, []any) {
+	var cols []database.ColumnMetadata
+	var out []any
+	for i, col := range a.columns {
+		if i >= len(values) || (present != nil && !present[i]) {
+			continue
+		}
+		cols = append(cols, col.meta)
+		out = append(out, col.value(values[i]))
+	}
+	return cols, out
+}
+
+func (a *binlogApplier) apply(ev *database.BinlogEvent) error {
+	for _, r := range ev.Rows {
+		switch {
+		case ev.IsInsert():
+			cols, row, mergeKeys, err := a.mapRow(a.image(r.After, ev.AfterPresent))
+			if err != nil {
+				return err
+			}
+			if err := a.upsert(cols, row, mergeKeys); err != nil {
+				return err
+			}
+		case ev.IsDelete():
+			cols, row, mergeKeys, err := a.mapRow(a.image(r.Before, ev.BeforePresent))
+			if err != nil {
+				return err
+			}
+			if err := a.delete(cols, row, mergeKeys); err != nil {
+				return err
+			}
+		case ev.IsUpdate():
+			if err := a.applyUpdate(ev, r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyUpdate upserts the after image. Columns missing from it (unchanged
+// values under binlog_row_image = MINIMAL or NOBLOB) are taken from the before
+// image. A changed merge key deletes the old row first.
+func (a *binlogApplier) applyUpdate(ev *database.BinlogEvent, r database.BinlogRow) error {
+	beforeCols, beforeRow, mergeKeys, err := a.mapRow(a.image(r.Before, ev.BeforePresent))
+	if err != nil {
+		return err
+	}
+	values := append([]any(nil), r.After...)
+	present := append([]bool(nil), ev.AfterPresent...)
+	for i := range present {
+		if !present[i] && i < len(r.Before) && ev.BeforePresent[i] {
+			values[i], present[i] = r.Before[i], true
+		}
+	}
+	cols, row, afterKeys, err := a.mapRow(a.image(values, present))
+	if err != nil {
+		return err
+	}
+
+	if keySignature(mergeKeyValues(beforeCols, beforeRow, mergeKeys)) != keySignature(mergeKeyValues(cols, row, afterKeys)) {
+		if err := a.delete(beforeCols, beforeRow, mergeKeys); err != nil {
+			return err
+		}
+	}
+	return a.upsert(cols, row, afterKeys)
+}
+
+func mergeKeyValues(cols []database.ColumnMetadata, row []any, mergeKeys []string) []any {
+	key := make([]any, len(mergeKeys))
+	for i, k := range mergeKeys {
+		if idx := findColumnIndex(cols, k); idx >= 0 {
+			key[i] = row[idx]
+		}
+	}
+	return key
+}
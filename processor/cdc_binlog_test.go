@@ -0,0 +1,225 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// blEvent wraps an event body in a binlog v4 header.
+func blEvent(typ byte, nextPos uint32, body []byte) []byte {
+	ev := make([]byte, 19, 19+len(body))
+	ev[4] = typ
+	binary.LittleEndian.PutUint32(ev[9:], uint32(19+len(body)))
+	binary.LittleEndian.PutUint32(ev[13:], nextPos)
+	return append(ev, body...)
+}
+
+// blTableMap announces app.<table>(id INT, name VARCHAR(25)) under tableID.
+func blTableMap(tableID byte, table string) []byte {
+	body := []byte{tableID, 0, 0, 0, 0, 0, 1, 0, 3}
+	body = append(body, "app\x00"...)
+	body = append(body, byte(len(table)))
+	body = append(append(body, table...), 0)
+	body = append(body, 2, 3, 15, 2, 100, 0, 0x02)
+	return blEvent(database.BinlogTableMapEvent, 0, body)
+}
+
+// blRows builds a v2 rows event; bitmaps holds one (insert/delete) or two
+// (update) column-present bitmaps.
+func blRows(typ, tableID byte, bitmaps []byte, rows ...[]byte) []byte {
+	body := []byte{tableID, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2}
+	body = append(body, bitmaps...)
+	for _, r := range rows {
+		body = append(body, r...)
+	}
+	return blEvent(typ, 0, body)
+}
+
+// blRow encodes the present columns of a row image: uint32 ids and string names.
+func blRow(values ...any) []byte {
+	row := []byte{0}
+	for _, v := range values {
+		switch x := v.(type) {
+		case uint32:
+			row = binary.LittleEndian.AppendUint32(row, x)
+		case string:
+			row = append(append(row, byte(len(x))), x...)
+		}
+	}
+	return row
+}
+
+func blQuery(query string, nextPos uint32) []byte {
+	body := make([]byte, 8, 13+4+len(query))
+	body = append(body, 3, 0, 0, 0, 0)
+	body = append(body, "app\x00"...)
+	return blEvent(database.BinlogQueryEvent, nextPos, append(body, query...))
+}
+
+func TestBinlogApplierAppliesCommittedTransactions(t *testing.T) {
+	targetPath := filepath.Join(t.TempDir(), "target.db")
+	setupSQLiteSource(t, targetPath, `CREATE TABLE dst_users (user_id INTEGER PRIMARY KEY, name TEXT)`)
+	setupSQLiteExec(t, targetPath, `INSERT INTO dst_users(user_id, name) VALUES (2, 'b')`)
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath}},
+		Tasks:     []config.TaskConfig{{TableName: "dst_copy", SQL: "SELECT 1", SourceDB: "dst", TargetDB: "dst", AllowSameTable: true}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+	targetDB, err := p.manager.GetTarget("dst")
+	if err != nil {
+		t.Fatalf("GetTarget() error = %v", err)
+	}
+
+	task := config.TaskConfig{
+		TableName: "dst_users",
+		TargetDB:  "dst",
+		Mode:      config.TaskModeMerge,
+		MergeKeys: []string{"user_id"},
+		Columns:   []config.ColumnMapping{{Source: "id", Target: "user_id"}, {Source: "name", Target: "name"}},
+		CDC:       config.CDCConfig{Enabled: true, Mode: config.CDCModeBinlog, SourceTable: "app.users"},
+	}
+	targetCols := []database.ColumnMetadata{{Name: "user_id"}, {Name: "name"}}
+	applier := &binlogApplier{
+		cdcRowWriter: newCDCRowWriter(context.Background(), p, targetDB, config.DatabaseTypeSQLite, task, targetCols, ""),
+		parser:       database.NewBinlogParser(false),
+		schema:       "app",
+		table:        "users",
+		columns:      []binlogColumn{newBinlogColumn("id", "int", "int"), newBinlogColumn("name", "varchar", "varchar(25)")},
+		file:         "mysql-bin.000004",
+	}
+
+	events := [][]byte{
+		blQuery("BEGIN", 200),
+		blTableMap(7, "users"),
+		blTableMap(8, "orders"),
+		blRows(database.BinlogWriteRowsEventV2, 7, []byte{0x03}, blRow(uint32(1), "a"), blRow(uint32(3), "c")),
+		blRows(database.BinlogWriteRowsEventV2, 8, []byte{0x03}, blRow(uint32(9), "other")),
+		// Minimal row image: primary key before, changed column after.
+		blRows(database.BinlogUpdateRowsV2, 7, []byte{0x01, 0x02}, blRow(uint32(3)), blRow("C")),
+		// Primary key change.
+		blRows(database.BinlogUpdateRowsV2, 7, []byte{0x03, 0x03}, blRow(uint32(1), "a"), blRow(uint32(5), "a")),
+		blRows(database.BinlogDeleteRowsV2, 7, []byte{0x03}, blRow(uint32(2), "b")),
+	}
+	for i, ev := range events {
+		if _, ok, err := applier.handle(ev); err != nil || ok {
+			t.Fatalf("handle(%d) = %v, %v; expected no checkpoint inside the transaction", i, ok, err)
+		}
+	}
+	pos, ok, err := applier.handle(blEvent(database.BinlogXIDEvent, 900, make([]byte, 8)))
+	if err != nil {
+		t.Fatalf("handle(xid) error = %v", err)
+	}
+	if !ok || pos.String() != "mysql-bin.000004:900" {
+		t.Fatalf("unexpected checkpoint %v (%v)", pos, ok)
+	}
+	if err := applier.flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+	if applier.upserted != 4 || applier.deleted != 2 {
+		t.Fatalf("expected 4 upserts and 2 deletes, got %d/%d", applier.upserted, applier.deleted)
+	}
+
+	// A rolled back transaction is dropped; DDL forgets the cached columns.
+	for _, ev := range [][]byte{
+		blQuery("BEGIN", 950),
+		blRows(database.BinlogDeleteRowsV2, 7, []byte{0x03}, blRow(uint32(3), "C")),
+		blQuery("ROLLBACK", 1000),
+	} {
+		if _, _, err := applier.handle(ev); err != nil {
+			t.Fatalf("handle(rollback txn) error = %v", err)
+		}
+	}
+	// Savepoint statements are not commit points; ROLLBACK TO drops the rows after the savepoint.
+	for _, ev := range [][]byte{
+		blQuery("BEGIN", 1010),
+		blRows(database.BinlogWriteRowsEventV2, 7, []byte{0x03}, blRow(uint32(6), "f")),
+		blQuery("SAVEPOINT `sp1`", 1020),
+		blRows(database.BinlogWriteRowsEventV2, 7, []byte{0x03}, blRow(uint32(7), "g")),
+		blQuery("ROLLBACK TO SAVEPOINT `sp1`", 1030),
+		blQuery("XA END 'x1'", 1040),
+	} {
+		if _, ok, err := applier.handle(ev); err != nil || ok {
+			t.Fatalf("handle(savepoint txn) = %v, %v; expected no checkpoint", ok, err)
+		}
+	}
+	if pos, ok, err := applier.handle(blQuery("COMMIT", 1050)); err != nil || !ok || pos.Pos != 1050 {
+		t.Fatalf("expected COMMIT checkpoint, got %v %v %v", pos, ok, err)
+	}
+	if err := applier.flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	// A prepared XA transaction touching the table cannot be applied safely.
+	applier.handle(blQuery("XA START 'x2'", 1060))
+	applier.handle(blRows(database.BinlogWriteRowsEventV2, 7, []byte{0x03}, blRow(uint32(8), "h")))
+	if _, _, err := applier.handle(blEvent(database.BinlogXAPrepareEvent, 1070, make([]byte, 16))); err == nil {
+		t.Fatalf("expected two-phase XA error")
+	}
+	applier.txn = nil
+
+	if pos, ok, err := applier.handle(blQuery("ALTER TABLE users ADD COLUMN age INT", 1100)); err != nil || !ok || pos.Pos != 1100 {
+		t.Fatalf("expected DDL checkpoint, got %v %v %v", pos, ok, err)
+	}
+	if applier.columns != nil {
+		t.Fatalf("expected DDL to reset cached columns")
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+	var rows string
+	if err := db.QueryRow(`SELECT group_concat(user_id || ':' || name) FROM (SELECT * FROM dst_users ORDER BY user_id)`).Scan(&rows); err != nil {
+		t.Fatalf("query dst_users error = %v", err)
+	}
+	if rows != "3:C,5:a,6:f" {
+		t.Fatalf("unexpected target rows %q", rows)
+	}
+}
+
+func TestBinlogColumnValue(t *testing.T) {
+	status := newBinlogColumn("status", "enum", "enum('new','it''s done')")
+	if v := status.value(int64(2)); v != "it's done" {
+		t.Fatalf("enum value = %#v", v)
+	}
+	tags := newBinlogColumn("tags", "set", "set('a','b','c')")
+	if v := tags.value(int64(5)); v != "a,c" {
+		t.Fatalf("set value = %#v", v)
+	}
+	body := newBinlogColumn("body", "text", "text")
+	if v := body.value([]byte("hi")); v != "hi" {
+		t.Fatalf("text value = %#v", v)
+	}
+	if !newBinlogColumn("n", "int", "int(10) unsigned").unsigned {
+		t.Fatalf("expected unsigned column")
+	}
+}
+
+func TestResolveMySQLTimeZone(t *testing.T) {
+	if _, offset := time.Now().In(resolveMySQLTimeZone("+05:30", "", 0)).Zone(); offset != 5*3600+1800 {
+		t.Fatalf("offset zone = %d", offset)
+	}
+	if _, offset := time.Now().In(resolveMySQLTimeZone("-03:00", "", 0)).Zone(); offset != -3*3600 {
+		t.Fatalf("negative offset zone = %d", offset)
+	}
+	if loc := resolveMySQLTimeZone("SYSTEM", "UTC", 0); loc != time.UTC {
+		t.Fatalf("SYSTEM/UTC zone = %v", loc)
+	}
+	// Ambiguous abbreviations use the offset the server reports.
+	if _, offset := time.Now().In(resolveMySQLTimeZone("SYSTEM", "CST", 8*3600)).Zone(); offset != 8*3600 {
+		t.Fatalf("abbreviated zone offset = %d", offset)
+	}
+}
@@ -0,0 +1,320 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// processLogicalCDCTask consumes a PostgreSQL logical replication slot for the task.
+// The first run creates the slot and takes a snapshot through the regular task
+// path; later runs apply the decoded pgoutput changes and persist the slot
+// position (LSN) in state_file.
+func (p *Processor) processLogicalCDCTask(ctx context.Context, task config.TaskConfig, silent bool) (err error) {
+	sourceDB, err := p.manager.GetSource(task.SourceDB)
+	if err != nil {
+		return err
+	}
+
+	state, err := p.loadStateFile(task.StateFile)
+	if err != nil {
+		return err
+	}
+	key := p.taskKey(task)
+	if state.Tasks[key] == "" {
+		return p.snapshotLogicalCDCTask(ctx, sourceDB, task, silent)
+	}
+
+	start := time.Now()
+	applied, deleted := 0, 0
+	defer func() {
+		p.metrics.RecordTaskDuration(task.TableName, task.SourceDB, task.TargetDB, float64(time.Since(start).Milliseconds()))
+		status, errMsg := "success", ""
+		if err != nil {
+			status, errMsg = "failed", err.Error()
+		}
+		p.recordTaskResult(TaskResult{
+			Name:    task.TableName,
+			Rows:    applied,
+			Deleted: deleted,
+			Status:  status,
+			Error:   errMsg,
+		})
+	}()
+
+	lastLSN, err := database.ParsePgLSN(state.Tasks[key])
+	if err != nil {
+		return fmt.Errorf("invalid slot position in state file for table %s: %w", task.TableName, err)
+	}
+
+	targetDB, err := p.manager.GetTarget(task.TargetDB)
+	if err != nil {
+		return err
+	}
+	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
+	if !ok {
+		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
+	}
+	targetCols, err := targetDB.GetTableColumns(task.TableName)
+	if err != nil {
+		return fmt.Errorf("failed to read target columns for table %s: %w", task.TableName, err)
+	}
+
+	applier := &logicalApplier{
+		cdcRowWriter: newCDCRowWriter(ctx, p, targetDB, targetDBCfg.Type, task, targetCols, "set REPLICA IDENTITY to cover merge_keys"),
+		relations:    make(map[uint32]*database.PgRelation),
+	}
+	applier.namespace, applier.relname = splitSourceTable(task.CDC.SourceTable)
+
+	batchSize := task.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	for {
+		changes, err := peekLogicalChanges(ctx, sourceDB, task.CDC, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read replication slot %s: %w", task.CDC.SlotName, err)
+		}
+		commitLSN, err := applier.applyChanges(changes, lastLSN)
+		applied, deleted = applier.upserted, applier.deleted
+		if err != nil {
+			return err
+		}
+		if commitLSN <= lastLSN {
+			break
+		}
+
+		// Persist the position before advancing the slot: a crash in between
+		// only re-reads transactions that are then skipped by LSN.
+		lastLSN = commitLSN
+		state.Tasks[key] = database.FormatPgLSN(lastLSN)
+		if err := p.saveStateFile(task.StateFile, state); err != nil {
+			return fmt.Errorf("failed to save state file %s: %w", task.StateFile, err)
+		}
+		if err := advanceLogicalSlot(ctx, sourceDB, task.CDC.SlotName, lastLSN); err != nil {
+			return fmt.Errorf("failed to advance replication slot %s: %w", task.CDC.SlotName, err)
+		}
+	}
+
+	p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, int64(applied))
+	p.metrics.RecordDeletedRows(task.TableName, task.SourceDB, task.TargetDB, int64(deleted))
+	log.Printf("[cdc] Applied %d upserts and %d deletes for table %s from slot %s (lsn %s)",
+		applied, deleted, task.TableName, task.CDC.SlotName, database.FormatPgLSN(lastLSN))
+	return nil
+}
+
+// snapshotLogicalCDCTask creates the replication slot before copying the table
+// so that no change committed during the snapshot is lost.
+func (p *Processor) snapshotLogicalCDCTask(ctx context.Context, sourceDB database.SourceDB, task config.TaskConfig, silent bool) error {
+	lsn, err := ensureLogicalSlot(ctx, sourceDB, task.CDC.SlotName)
+	if err != nil {
+		return fmt.Errorf("failed to prepare replication slot %s: %w", task.CDC.SlotName, err)
+	}
+	log.Printf("[cdc] Taking initial snapshot of %s from slot %s at %s", task.TableName, task.CDC.SlotName, lsn)
+
+	snapshot := task
+	snapshot.CDC = config.CDCConfig{}
+	if err := p.processTaskInternal(ctx, snapshot, silent); err != nil {
+		return err
+	}
+
+	state, err := p.loadStateFile(task.StateFile)
+	if err != nil {
+		return err
+	}
+	state.Tasks[p.taskKey(task)] = lsn
+	if err := p.saveStateFile(task.StateFile, state); err != nil {
+		return fmt.Errorf("failed to save state file %s: %w", task.StateFile, err)
+	}
+	return nil
+}
+
+type logicalChange struct {
+	lsn  uint64
+	data []byte
+}
+
+func ensureLogicalSlot(ctx context.Context, sourceDB database.SourceDB, slot string) (string, error) {
+	lsn, found, err := queryLogicalSlotLSN(ctx, sourceDB, slot)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return lsn, nil
+	}
+
+	rows, err := sourceDB.Query(ctx, fmt.Sprintf("SELECT lsn::text FROM pg_create_logical_replication_slot(%s, 'pgoutput')", quoteSQLString(slot)))
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("pg_create_logical_replication_slot returned no rows")
+	}
+	if err := rows.Scan(&lsn); err != nil {
+		return "", err
+	}
+	log.Printf("[cdc] Created replication slot %s at %s", slot, lsn)
+	return lsn, rows.Err()
+}
+
+func queryLogicalSlotLSN(ctx context.Context, sourceDB database.SourceDB, slot string) (string, bool, error) {
+	rows, err := sourceDB.Query(ctx, fmt.Sprintf("SELECT COALESCE(confirmed_flush_lsn, restart_lsn)::text FROM pg_replication_slots WHERE slot_name = %s", quoteSQLString(slot)))
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return "", false, rows.Err()
+	}
+	var lsn string
+	if err := rows.Scan(&lsn); err != nil {
+		return "", false, err
+	}
+	return lsn, true, rows.Err()
+}
+
+func peekLogicalChanges(ctx context.Context, sourceDB database.SourceDB, cdc config.CDCConfig, limit int) ([]logicalChange, error) {
+	query := fmt.Sprintf(
+		"SELECT lsn::text, data FROM pg_logical_slot_peek_binary_changes(%s, NULL, %d, 'proto_version', '1', 'publication_names', %s)",
+		quoteSQLString(cdc.SlotName), limit, quoteSQLString(cdc.Publication))
+	rows, err := sourceDB.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []logicalChange
+	for rows.Next() {
+		var lsnText string
+		var data []byte
+		if err := rows.Scan(&lsnText, &data); err != nil {
+			return nil, err
+		}
+		lsn, err := database.ParsePgLSN(lsnText)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, logicalChange{lsn: lsn, data: data})
+	}
+	return changes, rows.Err()
+}
+
+func advanceLogicalSlot(ctx context.Context, sourceDB database.SourceDB, slot string, lsn uint64) error {
+	rows, err := sourceDB.Query(ctx, fmt.Sprintf("SELECT pg_replication_slot_advance(%s, '%s'::pg_lsn)", quoteSQLString(slot), database.FormatPgLSN(lsn)))
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func splitSourceTable(name string) (string, string) {
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		return schema, table
+	}
+	return "public", name
+}
+
+// logicalApplier applies decoded pgoutput changes of one source table to the target.
+type logicalApplier struct {
+	*cdcRowWriter
+	namespace string
+	relname   string
+	relations map[uint32]*database.PgRelation
+}
+
+// applyChanges applies every transaction committed after lastLSN and returns
+// the LSN of the last commit seen.
+func (a *logicalApplier) applyChanges(changes []logicalChange, lastLSN uint64) (uint64, error) {
+	commitLSN := lastLSN
+	var txn []*database.PgOutputMessage
+	for _, change := range changes {
+		msg, err := database.DecodePgOutput(change.data)
+		if err != nil {
+			return commitLSN, err
+		}
+		switch msg.Type {
+		case database.PgOutputBegin:
+			txn = txn[:0]
+		case database.PgOutputRelation:
+			a.relations[msg.Relation.ID] = msg.Relation
+		case database.PgOutputInsert, database.PgOutputUpdate, database.PgOutputDelete, database.PgOutputTruncate:
+			txn = append(txn, msg)
+		case database.PgOutputCommit:
+			if change.lsn > lastLSN {
+				for _, m := range txn {
+					if err := a.apply(m); err != nil {
+						return commitLSN, err
+					}
+				}
+				if err := a.flush(); err != nil {
+					return commitLSN, err
+				}
+			}
+			commitLSN = change.lsn
+			txn = txn[:0]
+		}
+	}
+	return commitLSN, nil
+}
+
+func (a *logicalApplier) apply(msg *database.PgOutputMessage) error {
+	if msg.Type == database.PgOutputTruncate {
+		for _, id := range msg.RelationIDs {
+			if rel, ok := a.relations[id]; ok && a.matches(rel) {
+				log.Printf("[cdc] Applying TRUNCATE of %s.%s to table %s", rel.Namespace, rel.Name, a.task.TableName)
+				return a.truncate()
+			}
+		}
+		return nil
+	}
+
+	rel, ok := a.relations[msg.RelationID]
+	if !ok {
+		return fmt.Errorf("pgoutput change references unknown relation %d", msg.RelationID)
+	}
+	if !a.matches(rel) {
+		return nil
+	}
+
+	tuple := msg.NewTuple
+	if msg.Type == database.PgOutputDelete {
+		tuple = msg.OldTuple
+	}
+	// Unchanged TOAST values are not sent and are left out of the row.
+	var sourceCols []database.ColumnMetadata
+	var values []any
+	for i, col := range rel.Columns {
+		if i >= len(tuple) || tuple[i].Unchanged {
+			continue
+		}
+		sourceCols = append(sourceCols, database.ColumnMetadata{Name: col.Name, DatabaseType: database.PgTypeName(col.TypeOID)})
+		if tuple[i].Null {
+			values = append(values, nil)
+		} else {
+			values = append(values, database.PgTextValue(col.TypeOID, tuple[i].Text))
+		}
+	}
+
+	cols, row, mergeKeys, err := a.mapRow(sourceCols, values)
+	if err != nil {
+		return err
+	}
+	if msg.Type == database.PgOutputDelete {
+		return a.delete(cols, row, mergeKeys)
+	}
+	return a.upsert(cols, row, mergeKeys)
+}
+
+func (a *logicalApplier) matches(rel *database.PgRelation) bool {
+	return strings.EqualFold(rel.Namespace, a.namespace) && strings.EqualFold(rel.Name, a.relname)
+}
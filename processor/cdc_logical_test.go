@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// pgMsg builds a binary pgoutput message from raw bytes, strings (NUL-terminated)
+// and uint32 values.
+func pgMsg(parts ...any) []byte {
+	var out []byte
+	for _, part := range parts {
+		switch v := part.(type) {
+		case byte:
+			out = append(out, v)
+		case string:
+			out = append(append(out, v...), 0)
+		case uint32:
+			out = binary.BigEndian.AppendUint32(out, v)
+		case []byte:
+			out = append(out, v...)
+		}
+	}
+	return out
+}
+
+// pgTuple encodes text values; nil becomes a NULL column.
+func pgTuple(values ...any) []byte {
+	out := binary.BigEndian.AppendUint16(nil, uint16(len(values)))
+	for _, v := range values {
+		if v == nil {
+			out = append(out, 'n')
+			continue
+		}
+		s := v.(string)
+		out = append(out, 't')
+		out = binary.BigEndian.AppendUint32(out, uint32(len(s)))
+		out = append(out, s...)
+	}
+	return out
+}
+
+func TestLogicalApplierAppliesCommittedChanges(t *testing.T) {
+	targetPath := filepath.Join(t.TempDir(), "target.db")
+	setupSQLiteSource(t, targetPath, `CREATE TABLE dst_users (user_id INTEGER PRIMARY KEY, name TEXT)`)
+	setupSQLiteExec(t, targetPath, `INSERT INTO dst_users(user_id, name) VALUES (1, 'a'), (2, 'b')`)
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath}},
+		Tasks:     []config.TaskConfig{{TableName: "dst_copy", SQL: "SELECT 1", SourceDB: "dst", TargetDB: "dst", AllowSameTable: true}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+	targetDB, err := p.manager.GetTarget("dst")
+	if err != nil {
+		t.Fatalf("GetTarget() error = %v", err)
+	}
+
+	task := config.TaskConfig{
+		TableName: "dst_users",
+		TargetDB:  "dst",
+		Mode:      config.TaskModeMerge,
+		MergeKeys: []string{"user_id"},
+		Columns:   []config.ColumnMapping{{Source: "id", Target: "user_id"}, {Source: "name", Target: "name"}},
+		CDC:       config.CDCConfig{Enabled: true, Mode: config.CDCModeLogical, SourceTable: "users"},
+	}
+	targetCols := []database.ColumnMetadata{{Name: "user_id"}, {Name: "name"}}
+	applier := &logicalApplier{
+		cdcRowWriter: newCDCRowWriter(context.Background(), p, targetDB, config.DatabaseTypeSQLite, task, targetCols, ""),
+		namespace:    "public",
+		relname:      "users",
+		relations:    make(map[uint32]*database.PgRelation),
+	}
+
+	relation := func(id uint32, table string) []byte {
+		return pgMsg(byte('R'), id, "public", table, byte('d'), []byte{0, 3},
+			byte(1), "id", uint32(23), uint32(0xFFFFFFFF),
+			byte(0), "name", uint32(25), uint32(0xFFFFFFFF),
+			byte(0), "secret", uint32(25), uint32(0xFFFFFFFF))
+	}
+	changes := []logicalChange{
+		// Already applied transaction: must be skipped.
+		{lsn: 0x100, data: pgMsg(byte('B'))},
+		{lsn: 0x100, data: relation(1, "users")},
+		{lsn: 0x100, data: pgMsg(byte('I'), uint32(1), byte('N'), pgTuple("9", "stale", "x"))},
+		{lsn: 0x110, data: pgMsg(byte('C'))},
+
+		{lsn: 0x200, data: pgMsg(byte('B'))},
+		{lsn: 0x200, data: relation(2, "orders")},
+		{lsn: 0x200, data: pgMsg(byte('I'), uint32(1), byte('N'), pgTuple("3", "c", "x"))},
+		{lsn: 0x200, data: pgMsg(byte('U'), uint32(1), byte('N'), pgTuple("1", "A", "x"))},
+		{lsn: 0x200, data: pgMsg(byte('I'), uint32(2), byte('N'), pgTuple("7", "other", "x"))},
+		{lsn: 0x200, data: pgMsg(byte('D'), uint32(1), byte('K'), pgTuple("2", nil, nil))},
+		{lsn: 0x210, data: pgMsg(byte('C'))},
+	}
+
+	commitLSN, err := applier.applyChanges(changes, 0x110)
+	if err != nil {
+		t.Fatalf("applyChanges() error = %v", err)
+	}
+	if commitLSN != 0x210 {
+		t.Fatalf("expected commit lsn 0x210, got %#x", commitLSN)
+	}
+	if applier.upserted != 2 || applier.deleted != 1 {
+		t.Fatalf("expected 2 upserts and 1 delete, got %d/%d", applier.upserted, applier.deleted)
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+	var rows string
+	if err := db.QueryRow(`SELECT group_concat(user_id || ':' || name) FROM (SELECT * FROM dst_users ORDER BY user_id)`).Scan(&rows); err != nil {
+		t.Fatalf("query dst_users error = %v", err)
+	}
+	if rows != "1:A,3:c" {
+		t.Fatalf("unexpected target rows %q", rows)
+	}
+
+	// TRUNCATE empties the target after the changes before it are applied.
+	truncate := []logicalChange{
+		{lsn: 0x300, data: pgMsg(byte('B'))},
+		{lsn: 0x300, data: pgMsg(byte('I'), uint32(1), byte('N'), pgTuple("4", "d", "x"))},
+		{lsn: 0x300, data: pgMsg(byte('T'), uint32(2), byte(0), uint32(2), uint32(1))},
+		{lsn: 0x300, data: pgMsg(byte('I'), uint32(1), byte('N'), pgTuple("5", "e", "x"))},
+		{lsn: 0x310, data: pgMsg(byte('C'))},
+	}
+	if commitLSN, err = applier.applyChanges(truncate, commitLSN); err != nil || commitLSN != 0x310 {
+		t.Fatalf("applyChanges(truncate) = %#x, %v", commitLSN, err)
+	}
+	if err := db.QueryRow(`SELECT group_concat(user_id || ':' || name) FROM (SELECT * FROM dst_users ORDER BY user_id)`).Scan(&rows); err != nil {
+		t.Fatalf("query dst_users error = %v", err)
+	}
+	if rows != "5:e" {
+		t.Fatalf("unexpected target rows after truncate %q", rows)
+	}
+}
+
+func TestSplitSourceTable(t *testing.T) {
+	if schema, table := splitSourceTable("sales.orders"); schema != "sales" || table != "orders" {
+		t.Fatalf("unexpected split %s.%s", schema, table)
+	}
+	if schema, table := splitSourceTable("orders"); schema != "public" || table != "orders" {
+		t.Fatalf("unexpected default schema %s.%s", schema, table)
+	}
+}
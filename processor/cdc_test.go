@@ -0,0 +1,263 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func newCDCDeleteProcessor(t *testing.T, cdc config.CDCConfig, historyEnabled bool) (*Processor, *config.Config, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_events (id INTEGER PRIMARY KEY, name TEXT, updated_at INTEGER, deleted_at TEXT)`)
+	setupSQLiteExec(t, sourcePath, `INSERT INTO src_events(id, name, updated_at) VALUES (1, 'a', 100), (2, 'b', 200), (3, 'c', 300)`)
+	setupSQLiteSource(t, targetPath, `CREATE TABLE dst_events (id INTEGER PRIMARY KEY, name TEXT, updated_at INTEGER, deleted_at TEXT)`)
+
+	cdc.Enabled = true
+	cdc.CursorColumn = "updated_at"
+	cdc.PollInterval = "100ms"
+	cdc.DeleteDetection = true
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{
+			{
+				TableName: "dst_events",
+				SQL:       "SELECT id, name, updated_at, deleted_at FROM src_events",
+				SourceDB:  "src",
+				TargetDB:  "dst",
+				Mode:      config.TaskModeMerge,
+				MergeKeys: []string{"id"},
+				StateFile: filepath.Join(dir, "state.json"),
+				CDC:       cdc,
+			},
+		},
+		History: config.HistoryConfig{Enabled: historyEnabled},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+	return p, cfg, sourcePath, targetPath
+}
+
+func TestCDCDeleteDetectionKeySet(t *testing.T) {
+	p, cfg, sourcePath, targetPath := newCDCDeleteProcessor(t, config.CDCConfig{}, true)
+	ctx := context.Background()
+
+	if err := p.ProcessAllTasksContext(ctx); err != nil {
+		t.Fatalf("initial sync error = %v", err)
+	}
+
+	setupSQLiteExec(t, sourcePath, `DELETE FROM src_events WHERE id IN (1, 3)`)
+	if err := p.runCDCRound(ctx, cfg.Tasks); err != nil {
+		t.Fatalf("cdc round error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+
+	var ids string
+	if err := db.QueryRow(`SELECT group_concat(id) FROM dst_events`).Scan(&ids); err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	if ids != "2" {
+		t.Fatalf("expected only id 2 to remain, got %q", ids)
+	}
+
+	results := p.TaskResults()
+	last := results[len(results)-1]
+	if last.Deleted != 2 {
+		t.Fatalf("expected TaskResult.Deleted = 2, got %d", last.Deleted)
+	}
+
+	var deleted int
+	if err := db.QueryRow(`SELECT rows_deleted FROM db_ferry_migrations ORDER BY id DESC LIMIT 1`).Scan(&deleted); err != nil {
+		t.Fatalf("query history error = %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected history rows_deleted = 2, got %d", deleted)
+	}
+}
+
+func TestCDCDeleteDetectionKeySetKeepsRowsBeforeInitialCursor(t *testing.T) {
+	p, cfg, _, targetPath := newCDCDeleteProcessor(t, config.CDCConfig{InitialCursor: "250"}, false)
+	ctx := context.Background()
+	// Rows at or below initial_cursor were loaded before CDC was enabled.
+	setupSQLiteExec(t, targetPath, `INSERT INTO dst_events(id, name, updated_at) VALUES (1, 'a', 100), (2, 'b', 200), (9, 'gone', 50)`)
+
+	if err := p.ProcessAllTasksContext(ctx); err != nil {
+		t.Fatalf("initial sync error = %v", err)
+	}
+	if err := p.runCDCRound(ctx, cfg.Tasks); err != nil {
+		t.Fatalf("cdc round error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+	var ids string
+	if err := db.QueryRow(`SELECT group_concat(id) FROM (SELECT id FROM dst_events ORDER BY id)`).Scan(&ids); err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	if ids != "1,2,3" {
+		t.Fatalf("expected rows 1,2,3 to remain and 9 to be deleted, got %q", ids)
+	}
+}
+
+func TestCDCDeleteDetectionRefusesToDeleteEveryRow(t *testing.T) {
+	p, cfg, _, targetPath := newCDCDeleteProcessor(t, config.CDCConfig{}, false)
+	ctx := context.Background()
+
+	if err := p.ProcessAllTasksContext(ctx); err != nil {
+		t.Fatalf("initial sync error = %v", err)
+	}
+	// No target key matches a source key any more, as when the two sides
+	// return the keys in incompatible representations.
+	setupSQLiteExec(t, targetPath, `UPDATE dst_events SET id = id + 100`)
+	err := p.runCDCRound(ctx, cfg.Tasks)
+	if err == nil || !strings.Contains(err.Error(), "refusing to delete all 3 target rows") {
+		t.Fatalf("expected the delete guard to abort the round, got %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM dst_events`).Scan(&count); err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected the target rows to be kept, got %d", count)
+	}
+}
+
+func TestCDCDeleteDetectionSoftDeleteFlag(t *testing.T) {
+	p, cfg, sourcePath, targetPath := newCDCDeleteProcessor(t, config.CDCConfig{
+		DeleteStrategy:   config.CDCDeleteStrategySoftDelete,
+		SoftDeleteColumn: "deleted_at",
+		DeleteAction:     config.CDCDeleteActionFlag,
+		DeleteFlagColumn: "is_deleted",
+	}, false)
+	ctx := context.Background()
+
+	if err := p.ProcessAllTasksContext(ctx); err != nil {
+		t.Fatalf("initial sync error = %v", err)
+	}
+
+	setupSQLiteExec(t, sourcePath, `UPDATE src_events SET deleted_at = '2026-01-01', updated_at = 400 WHERE id = 2`)
+	if err := p.runCDCRound(ctx, cfg.Tasks); err != nil {
+		t.Fatalf("cdc round error = %v", err)
+	}
+	// A second round must not flag the same row again.
+	if err := p.runCDCRound(ctx, cfg.Tasks); err != nil {
+		t.Fatalf("second cdc round error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, COALESCE(is_deleted, 0) FROM dst_events ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id, flag int
+		if err := rows.Scan(&id, &flag); err != nil {
+			t.Fatalf("scan error = %v", err)
+		}
+		got = append(got, strings.Repeat("x", flag)+string(rune('0'+id)))
+	}
+	if strings.Join(got, ",") != "1,x2,3" {
+		t.Fatalf("expected only id 2 flagged, got %v", got)
+	}
+
+	results := p.TaskResults()
+	if results[1].Deleted != 1 || results[2].Deleted != 0 {
+		t.Fatalf("unexpected deleted counts: %+v", results)
+	}
+
+	// Clearing the soft-delete marker at the source clears the target flag.
+	setupSQLiteExec(t, sourcePath, `UPDATE src_events SET deleted_at = NULL, updated_at = 500 WHERE id = 2`)
+	if err := p.runCDCRound(ctx, cfg.Tasks); err != nil {
+		t.Fatalf("third cdc round error = %v", err)
+	}
+	var flagged int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM dst_events WHERE is_deleted = 1`).Scan(&flagged); err != nil {
+		t.Fatalf("query flags error = %v", err)
+	}
+	if flagged != 0 {
+		t.Fatalf("expected the flag of id 2 to be cleared, %d rows still flagged", flagged)
+	}
+}
+
+func TestBuildSourceKeysSQL(t *testing.T) {
+	task := config.TaskConfig{
+		SQL:        "SELECT id AS src_id, deleted FROM events;",
+		ResumeKey:  "updated_at",
+		ResumeFrom: "10",
+		Columns:    []config.ColumnMapping{{Source: "src_id", Target: "id"}, {Source: "deleted", Target: "deleted"}},
+		CDC: config.CDCConfig{
+			DeleteStrategy:   config.CDCDeleteStrategySoftDelete,
+			SoftDeleteColumn: "deleted",
+			SoftDeleteValue:  "1",
+		},
+	}
+	got := buildSourceKeysSQL(config.DatabaseTypeMySQL, task, []string{"id"})
+	want := "SELECT src_id FROM (SELECT id AS src_id, deleted FROM events) __cdc_src WHERE `deleted` = 1"
+	if got != want {
+		t.Fatalf("buildSourceKeysSQL() = %q, want %q", got, want)
+	}
+}
+
+func TestKeyValueSignatureAcrossDialects(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	cases := []struct {
+		name       string
+		source     any
+		sourceType string
+		target     any
+		targetType string
+	}{
+		{"decimal bytes vs float", []byte("10.50"), "DECIMAL", float64(10.5), "DOUBLE"},
+		{"decimal scale", "7.000", "NUMERIC", int64(7), "BIGINT"},
+		{"integer vs text", int64(42), "INT", "42", "VARCHAR"},
+		{"integer vs numeric text", int64(42), "BIGINT", []byte("42"), "NUMBER"},
+		{"datetime zones", time.Date(2026, 1, 1, 10, 0, 0, 0, cst), "DATETIME", time.Date(2026, 1, 1, 2, 0, 0, 0, time.UTC), "TIMESTAMP"},
+		{"datetime text", time.Date(2026, 1, 1, 10, 0, 0, 0, cst), "TIMESTAMPTZ", "2026-01-01 02:00:00", "DATETIME"},
+		{"date", time.Date(2026, 1, 1, 0, 0, 0, 0, cst), "DATE", "2026-01-01", "DATE"},
+	}
+	for _, tc := range cases {
+		if got, want := keyValueSignature(tc.source, tc.sourceType), keyValueSignature(tc.target, tc.targetType); got != want {
+			t.Fatalf("%s: source signature %q != target signature %q", tc.name, got, want)
+		}
+	}
+	if keyValueSignature("10.5", "VARCHAR") == keyValueSignature("10.50", "VARCHAR") {
+		t.Fatal("expected text keys to be compared as text")
+	}
+}
@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// cdcRowWriter maps captured source rows onto the task's columns and applies
+// them to the target: inserts and updates as batched merge upserts, deletes by
+// merge_keys. It is shared by the log-based CDC modes.
+type cdcRowWriter struct {
+	// ctx bounds every statement the writer issues for the current round.
+	ctx        context.Context
+	p          *Processor
+	targetDB   database.TargetDB
+	targetType string
+	task       config.TaskConfig
+	targetCols map[string]struct{}
+	// keyHint is appended to the error raised when a delete lacks a merge key.
+	keyHint string
+	// maskers 按输出列集合缓存脱敏引擎，避免每个变更都重建引擎、重置随机源并重复告警缺失列
+	maskers map[string]*maskEngine
+
+	pendingCols []database.ColumnMetadata
+	pendingKeys []string
+	pendingSig  string
+	pending     [][]any
+
+	upserted int
+	deleted  int
+}
+
+func newCDCRowWriter(ctx context.Context, p *Processor, targetDB database.TargetDB, targetType string, task config.TaskConfig, targetCols []database.ColumnMetadata, keyHint string) *cdcRowWriter {
+	w := &cdcRowWriter{
+		ctx:        ctx,
+		p:          p,
+		targetDB:   targetDB,
+		targetType: targetType,
+		task:       task,
+		targetCols: make(map[string]struct{}, len(targetCols)),
+		keyHint:    keyHint,
+		maskers:    make(map[string]*maskEngine),
+	}
+	for _, col := range targetCols {
+		w.targetCols[strings.ToLower(col.Name)] = struct{}{}
+	}
+	return w
+}
+
+// mapRow maps a captured row to target columns, dropping columns the target
+// table does not have. Columns missing from sourceCols (values the change did
+// not carry) are skipped in the column mapping.
+func (w *cdcRowWriter) mapRow(sourceCols []database.ColumnMetadata, values []any) ([]database.ColumnMetadata, []any, []string, error) {
+	mappings := w.task.Columns
+	if len(mappings) > 0 {
+		present := make([]config.ColumnMapping, 0, len(mappings))
+		for _, m := range mappings {
+			if findColumnIndex(sourceCols, m.Source) >= 0 {
+				present = append(present, m)
+			}
+		}
+		mappings = present
+	}
+	cols, indices, err := applyColumnMapping(sourceCols, mappings)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to apply column mapping for table %s: %w", w.task.TableName, err)
+	}
+	row := remapRow(values, indices)
+
+	var outCols []database.ColumnMetadata
+	var outRow []any
+	for i, col := range cols {
+		if _, ok := w.targetCols[strings.ToLower(col.Name)]; ok {
+			outCols = append(outCols, col)
+			outRow = append(outRow, row[i])
+		}
+	}
+	masker, err := w.maskerFor(outCols)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	outRow = masker.apply(outRow, outCols)
+
+	mergeKeys, err := resolveMergeKeys(outCols, w.task.MergeKeys)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return outCols, outRow, mergeKeys, nil
+}
+
+// maskerFor returns the masking engine for a set of output columns, building it
+// on first use. Changes that omit columns (unchanged TOAST values, key-only
+// delete images) get their own engine instead of rebuilding one per row.
+func (w *cdcRowWriter) maskerFor(cols []database.ColumnMetadata) (*maskEngine, error) {
+	if len(w.task.Masking) == 0 {
+		return nil, nil
+	}
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.Name
+	}
+	sig := strings.Join(names, "\x00")
+	if masker, ok := w.maskers[sig]; ok {
+		return masker, nil
+	}
+	masker, err := newMaskEngine(w.task.Masking, cols, w.p.sourceDBType(w.task))
+	if err != nil {
+		return nil, err
+	}
+	w.maskers[sig] = masker
+	return masker, nil
+}
+
+func (w *cdcRowWriter) upsert(cols []database.ColumnMetadata, row []any, mergeKeys []string) error {
+	names := make([]string, len(cols))
+	for i, col := range cols {
+		names[i] = col.Name
+	}
+	sig := strings.Join(names, "\x00")
+	if sig != w.pendingSig {
+		if err := w.flush(); err != nil {
+			return err
+		}
+		w.pendingCols, w.pendingKeys, w.pendingSig = cols, mergeKeys, sig
+	}
+	w.pending = append(w.pending, row)
+	return nil
+}
+
+func (w *cdcRowWriter) delete(cols []database.ColumnMetadata, row []any, mergeKeys []string) error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	key := make([]any, len(mergeKeys))
+	for i, k := range mergeKeys {
+		idx := findColumnIndex(cols, k)
+		if idx < 0 || row[idx] == nil {
+			return fmt.Errorf("merge key %s is missing from the deleted row of %s; %s", k, w.task.CDC.SourceTable, w.keyHint)
+		}
+		key[i] = row[idx]
+	}
+	stmt, args := database.BuildDeleteByKeysSQL(w.targetType, w.task.TableName, mergeKeys, [][]any{key})
+	if err := w.targetDB.Exec(w.ctx, stmt, args...); err != nil {
+		return fmt.Errorf("failed to apply delete for table %s: %w", w.task.TableName, err)
+	}
+	w.deleted++
+	return nil
+}
+
+// truncate removes every row of the target table after applying the changes
+// captured before it.
+func (w *cdcRowWriter) truncate() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	stmt := fmt.Sprintf("DELETE FROM %s", database.QuoteIdentifier(w.targetType, w.task.TableName))
+	if err := w.targetDB.Exec(w.ctx, stmt); err != nil {
+		return fmt.Errorf("failed to apply truncate for table %s: %w", w.task.TableName, err)
+	}
+	return nil
+}
+
+func (w *cdcRowWriter) flush() error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	if _, err := w.p.insertBatchWithRetry(w.ctx, w.targetDB, w.task, w.task.TableName, w.pendingCols, w.pending, w.pendingKeys, nil); err != nil {
+		return fmt.Errorf("failed to apply changes for table %s: %w", w.task.TableName, err)
+	}
+	w.upserted += len(w.pending)
+	w.pending = nil
+	return nil
+}
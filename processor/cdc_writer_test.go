@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func TestCDCRowWriterReusesMaskEngine(t *testing.T) {
+	task := config.TaskConfig{
+		TableName: "dst_users",
+		MergeKeys: []string{"id"},
+		Masking:   []config.MaskingConfig{{Column: "email", Rule: config.MaskRuleEmail}},
+	}
+	targetCols := []database.ColumnMetadata{{Name: "id"}, {Name: "email"}}
+	w := newCDCRowWriter(context.Background(), nil, nil, config.DatabaseTypeSQLite, task, targetCols, "")
+
+	full := []database.ColumnMetadata{{Name: "id"}, {Name: "email"}}
+	keyOnly := []database.ColumnMetadata{{Name: "id"}}
+	for i := 0; i < 3; i++ {
+		_, row, _, err := w.mapRow(full, []any{int64(i), "alice@example.com"})
+		if err != nil {
+			t.Fatalf("mapRow() error = %v", err)
+		}
+		if row[1] == "alice@example.com" {
+			t.Fatalf("expected email to be masked, got %v", row[1])
+		}
+		if _, _, _, err := w.mapRow(keyOnly, []any{int64(i)}); err != nil {
+			t.Fatalf("mapRow(key only) error = %v", err)
+		}
+	}
+	if len(w.maskers) != 2 {
+		t.Fatalf("expected one mask engine per column set, got %d", len(w.maskers))
+	}
+}
@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -25,7 +26,7 @@ type joinResult struct {
 	rows    [][]any
 }
 
-func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) error {
+func (p *Processor) processFederatedTask(ctx context.Context, task config.TaskConfig, silent bool) (err error) {
 	start := time.Now()
 	log.Printf("Executing federated query for table %s with %d sources", task.TableName, len(task.Sources))
 
@@ -53,7 +54,7 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 	var recorder *database.HistoryRecorder
 	if p.config.History.Enabled {
 		recorder = p.getHistoryRecorder(task.TargetDB)
-		if ensureErr := recorder.EnsureTable(targetDB); ensureErr != nil {
+		if ensureErr := recorder.EnsureTable(ctx, targetDB); ensureErr != nil {
 			log.Printf("Warning: failed to ensure history table: %v", ensureErr)
 		} else {
 			var sourceDBs []string
@@ -67,14 +68,14 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 				Mode:     task.Mode,
 				Version:  p.version,
 			}
-			historyID, _ = recorder.Start(targetDB, rec)
+			historyID, _ = recorder.Start(ctx, targetDB, rec)
 		}
 	}
 
 	// Load all source data
 	var sources []*sourceData
 	for _, src := range task.Sources {
-		sd, err := p.loadSourceData(src)
+		sd, err := p.loadSourceData(ctx, src)
 		if err != nil {
 			return fmt.Errorf("failed to load source %q: %w", src.Alias, err)
 		}
@@ -124,26 +125,26 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 		return err
 	}
 
-	// Create target table
-	if task.SkipCreateTable {
-		log.Printf("Skipping table creation for %s", task.TableName)
-	} else {
-		switch task.Mode {
-		case config.TaskModeAppend, config.TaskModeMerge:
-			if err := targetDB.EnsureTable(task.TableName, joinResult.columns); err != nil {
-				return fmt.Errorf("failed to ensure target table: %w", err)
-			}
-		default:
-			if err := targetDB.CreateTable(task.TableName, joinResult.columns); err != nil {
-				return fmt.Errorf("failed to prepare target table: %w", err)
-			}
-		}
+	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
+	if !ok {
+		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
+	}
+
+	// Create target table (replace mode loads into a staging table)
+	loadTable, err := prepareTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, joinResult.columns)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if err != nil {
+			dropStagingTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, loadTable)
+		}
+	}()
 
 	// Pre SQL hooks
 	if len(task.PreSQL) > 0 {
 		log.Printf("Executing %d pre_sql hooks for table %s", len(task.PreSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PreSQL); err != nil {
+		if err := execHookSQLs(ctx, targetDB, task.PreSQL); err != nil {
 			return fmt.Errorf("pre_sql hook failed for table %s: %w", task.TableName, err)
 		}
 		log.Printf("Successfully executed all pre_sql hooks for table %s", task.TableName)
@@ -173,6 +174,12 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 	totalDLQ := 0
 	var batch [][]any
 
+	loadTx, err := p.beginLoadTransaction(ctx, targetDB, task)
+	if err != nil {
+		return err
+	}
+	defer loadTx.rollback()
+
 	for _, row := range joinResult.rows {
 		batch = append(batch, row)
 		processedRows++
@@ -182,7 +189,7 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 		}
 
 		if len(batch) >= batchSize {
-			dlqCount, err := p.insertBatchWithRetry(targetDB, task, joinResult.columns, batch, mergeKeys, dlqw)
+			dlqCount, err := p.insertBatchWithRetry(loadTx.writeContext(ctx), targetDB, task, loadTable, joinResult.columns, batch, mergeKeys, dlqw)
 			if err != nil {
 				return fmt.Errorf("failed to insert batch: %w", err)
 			}
@@ -192,29 +199,26 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 	}
 
 	if len(batch) > 0 {
-		dlqCount, err := p.insertBatchWithRetry(targetDB, task, joinResult.columns, batch, mergeKeys, dlqw)
+		dlqCount, err := p.insertBatchWithRetry(loadTx.writeContext(ctx), targetDB, task, loadTable, joinResult.columns, batch, mergeKeys, dlqw)
 		if err != nil {
 			return fmt.Errorf("failed to insert final batch: %w", err)
 		}
 		totalDLQ += dlqCount
 	}
-
-	// Create indexes
-	if len(task.Indexes) > 0 {
-		log.Printf("Creating %d indexes for table %s", len(task.Indexes), task.TableName)
-		if err := targetDB.CreateIndexes(task.TableName, task.Indexes); err != nil {
-			return fmt.Errorf("failed to create indexes for table %s: %w", task.TableName, err)
-		}
-		log.Printf("Successfully created all indexes for table %s", task.TableName)
+	if err := loadTx.finish(); err != nil {
+		return err
 	}
 
-	// Post SQL hooks
-	if len(task.PostSQL) > 0 {
-		log.Printf("Executing %d post_sql hooks for table %s", len(task.PostSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PostSQL); err != nil {
-			return fmt.Errorf("post_sql hook failed for table %s: %w", task.TableName, err)
-		}
-		log.Printf("Successfully executed all post_sql hooks for table %s", task.TableName)
+	// Index the staging table, swap it into place, then run post SQL hooks
+	staged := loadTable != task.TableName
+	if err := indexStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+		return err
+	}
+	if err := swapStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+		return err
+	}
+	if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, staged); err != nil {
+		return err
 	}
 
 	if historyID != "" && recorder != nil {
@@ -224,7 +228,7 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 			errMsg = err.Error()
 			validationResult = "failed"
 		}
-		if finishErr := recorder.Finish(targetDB, historyID, int64(processedRows), int64(totalDLQ), validationResult, errMsg); finishErr != nil {
+		if finishErr := recorder.Finish(context.WithoutCancel(ctx), targetDB, historyID, int64(processedRows), int64(totalDLQ), 0, validationResult, errMsg); finishErr != nil {
 			log.Printf("Warning: failed to finish history record: %v", finishErr)
 		}
 	}
@@ -246,7 +250,7 @@ func (p *Processor) processFederatedTask(task config.TaskConfig, silent bool) er
 	return nil
 }
 
-func (p *Processor) loadSourceData(source config.SourceConfig) (*sourceData, error) {
+func (p *Processor) loadSourceData(ctx context.Context, source config.SourceConfig) (*sourceData, error) {
 	db, err := p.manager.GetSource(source.DB)
 	if err != nil {
 		return nil, err
@@ -257,7 +261,7 @@ func (p *Processor) loadSourceData(source config.SourceConfig) (*sourceData, err
 	if limit <= 0 {
 		limit = 1000000
 	}
-	count, err := db.GetRowCount(trimSQL(source.SQL))
+	count, err := db.GetRowCount(ctx, trimSQL(source.SQL))
 	if err != nil {
 		return nil, fmt.Errorf("failed to count rows: %w", err)
 	}
@@ -265,7 +269,7 @@ func (p *Processor) loadSourceData(source config.SourceConfig) (*sourceData, err
 		return nil, fmt.Errorf("source %q has %s rows, exceeding --federated-memory-limit (%s). Consider using an ETL engine", source.Alias, formatNumber(count), formatNumber(limit))
 	}
 
-	rows, err := db.Query(source.SQL)
+	rows, err := db.Query(ctx, source.SQL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
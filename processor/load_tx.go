@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// loadTransaction 按 task.transaction 把批次写入包进目标端装载事务。
+// 断点状态推迟到覆盖这些行的事务提交之后才落盘，回滚后重跑不会越过未提交的行。
+type loadTransaction struct {
+	p        *Processor
+	targetDB database.TargetDB
+	task     config.TaskConfig
+	parent   context.Context
+	ctx      context.Context // 携带当前装载事务，仅用于批次写入
+	tx       *database.LoadTx
+	batches  int
+	resume   any
+	pending  bool
+}
+
+// beginLoadTransaction 在任务启用 transaction 时开启装载事务，未启用时返回 nil。
+func (p *Processor) beginLoadTransaction(ctx context.Context, targetDB database.TargetDB, task config.TaskConfig) (*loadTransaction, error) {
+	if !task.Transaction.Enabled {
+		return nil, nil
+	}
+	lt := &loadTransaction{p: p, targetDB: targetDB, task: task, parent: ctx}
+	if err := lt.begin(); err != nil {
+		return nil, err
+	}
+	if task.Transaction.CommitEvery > 0 {
+		log.Printf("Transactional load for %s: committing every %d batches", task.TableName, task.Transaction.CommitEvery)
+	} else {
+		log.Printf("Transactional load for %s: committing once at the end", task.TableName)
+	}
+	return lt, nil
+}
+
+func (lt *loadTransaction) begin() error {
+	tx, ctx, err := database.BeginLoadTx(lt.parent, lt.targetDB)
+	if err != nil {
+		return fmt.Errorf("failed to begin load transaction for table %s: %w", lt.task.TableName, err)
+	}
+	lt.tx, lt.ctx = tx, ctx
+	return nil
+}
+
+// writeContext 返回批次写入应使用的 context。
+func (lt *loadTransaction) writeContext(ctx context.Context) context.Context {
+	if lt == nil {
+		return ctx
+	}
+	return lt.ctx
+}
+
+// batchWritten 记录一个已写入的批次，满 commit_every 个批次时提交并开启下一个事务。
+func (lt *loadTransaction) batchWritten(resumeValue any) error {
+	lt.batches++
+	lt.resume, lt.pending = resumeValue, true
+	every := lt.task.Transaction.CommitEvery
+	if every <= 0 || lt.batches%every != 0 {
+		return nil
+	}
+	if err := lt.commit(); err != nil {
+		return err
+	}
+	return lt.begin()
+}
+
+// commit 提交当前事务并保存其覆盖的断点。
+func (lt *loadTransaction) commit() error {
+	if err := lt.tx.Commit(); err != nil {
+		return fmt.Errorf("table %s: %w", lt.task.TableName, err)
+	}
+	if !lt.pending {
+		return nil
+	}
+	lt.pending = false
+	return lt.p.updateResumeState(lt.task, lt.resume)
+}
+
+// finish 提交最后一个事务。
+func (lt *loadTransaction) finish() error {
+	if lt == nil {
+		return nil
+	}
+	return lt.commit()
+}
+
+// rollback 回滚尚未提交的事务；已提交时不做任何事。
+func (lt *loadTransaction) rollback() {
+	if lt == nil {
+		return
+	}
+	if err := lt.tx.Rollback(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// checkpoint 在批次写入成功后保存断点；启用装载事务时断点随事务提交一起落盘。
+func (p *Processor) checkpoint(lt *loadTransaction, task config.TaskConfig, resumeValue any) error {
+	if lt == nil {
+		return p.updateResumeState(task, resumeValue)
+	}
+	return lt.batchWritten(resumeValue)
+}
@@ -30,28 +30,51 @@ var (
 type maskEngine struct {
 	masks   []config.MaskingConfig
 	indices []int
-	rng     *rand.Rand
+	// keys 为 hmac/fpe/token 规则加载的密钥，与 masks 一一对应
+	keys [][]byte
+	rng  *rand.Rand
+	// dbType 为源库类型，用于判断整数列的取值范围
+	dbType string
 }
 
-func newMaskEngine(masks []config.MaskingConfig, columns []database.ColumnMetadata) *maskEngine {
+func newMaskEngine(masks []config.MaskingConfig, columns []database.ColumnMetadata, dbType string) (*maskEngine, error) {
 	if len(masks) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	indices := make([]int, len(masks))
+	keys := make([][]byte, len(masks))
 	for i, m := range masks {
 		idx := findColumnIndex(columns, m.Column)
 		if idx < 0 {
 			log.Printf("Warning: masking column '%s' not found in query result", m.Column)
 		}
 		indices[i] = idx
+		if isKeyedMaskRule(m.Rule) {
+			key, err := loadMaskKey(m)
+			if err != nil {
+				return nil, fmt.Errorf("masking column '%s': %w", m.Column, err)
+			}
+			keys[i] = key
+		}
 	}
 
 	return &maskEngine{
 		masks:   masks,
 		indices: indices,
+		keys:    keys,
 		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		dbType:  dbType,
+	}, nil
+}
+
+// sourceDBType 返回任务源库的类型，未定义时为空。
+func (p *Processor) sourceDBType(task config.TaskConfig) string {
+	if p == nil || p.config == nil {
+		return ""
 	}
+	dbCfg, _ := p.config.GetDatabase(task.SourceDB)
+	return dbCfg.Type
 }
 
 func (e *maskEngine) apply(row []any, columns []database.ColumnMetadata) []any {
@@ -66,7 +89,7 @@ func (e *maskEngine) apply(row []any, columns []database.ColumnMetadata) []any {
 		if row[idx] == nil {
 			continue
 		}
-		masked, ok := e.maskValue(row[idx], e.masks[i], columns[idx])
+		masked, ok := e.maskValue(row[idx], e.masks[i], e.keys[i], columns[idx])
 		if ok {
 			row[idx] = masked
 		}
@@ -74,7 +97,7 @@ func (e *maskEngine) apply(row []any, columns []database.ColumnMetadata) []any {
 	return row
 }
 
-func (e *maskEngine) maskValue(value any, rule config.MaskingConfig, column database.ColumnMetadata) (any, bool) {
+func (e *maskEngine) maskValue(value any, rule config.MaskingConfig, key []byte, column database.ColumnMetadata) (any, bool) {
 	switch rule.Rule {
 	case config.MaskRulePhoneCN:
 		return maskPhoneCN(toString(value)), true
@@ -94,6 +117,14 @@ func (e *maskEngine) maskValue(value any, rule config.MaskingConfig, column data
 		return parseFixedValue(rule.Value, column), true
 	case config.MaskRuleHash:
 		return hashValue(value), true
+	case config.MaskRuleHMAC:
+		return hmacValue(key, rule.Salt, value, column), true
+	case config.MaskRuleFPE:
+		return fpeValue(key, rule.Salt, value, column, e.dbType), true
+	case config.MaskRuleToken:
+		return tokenValue(key, rule, value, column), true
+	case config.MaskRuleFakeName, config.MaskRuleFakeEmail, config.MaskRuleFakeAddress, config.MaskRuleFakeCompany, config.MaskRuleFakePhone:
+		return e.fakeValue(value, rule, column), true
 	default:
 		log.Printf("Warning: unsupported masking rule '%s' for column '%s'", rule.Rule, column.Name)
 		return value, false
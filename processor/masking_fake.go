@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// fake_* 规则生成仿真的姓名、邮箱、地址、公司名与电话，使 QA 环境的界面与校验仍然可用。
+// 邮箱域名只使用 RFC 2606 保留的 example.* 域名，避免误发邮件。
+
+var (
+	fakeFirstNamesEN = []string{
+		"James", "Mary", "John", "Patricia", "Robert", "Jennifer", "Michael", "Linda", "William", "Elizabeth",
+		"David", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen",
+		"Daniel", "Nancy", "Matthew", "Lisa", "Anthony", "Betty", "Mark", "Sandra", "Steven", "Ashley",
+	}
+	fakeLastNamesEN = []string{
+		"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez",
+		"Hernandez", "Lopez", "Gonzalez", "Wilson", "Anderson", "Thomas", "Taylor", "Moore", "Jackson", "Martin",
+		"Lee", "Thompson", "White", "Harris", "Clark", "Lewis", "Robinson", "Walker", "Young", "Allen",
+	}
+	fakeStreetsEN       = []string{"Main", "Oak", "Pine", "Maple", "Cedar", "Elm", "Washington", "Lake", "Hill", "Park", "Sunset", "River", "Church", "Highland", "Spring"}
+	fakeStreetSuffixEN  = []string{"St", "Ave", "Rd", "Blvd", "Ln", "Dr", "Way", "Ct"}
+	fakeCitiesEN        = []string{"Springfield, IL", "Portland, OR", "Austin, TX", "Columbus, OH", "Denver, CO", "Madison, WI", "Raleigh, NC", "Boise, ID", "Tucson, AZ", "Albany, NY"}
+	fakeCompanyWordsEN  = []string{"Systems", "Holdings", "Logistics", "Foods", "Analytics", "Labs", "Partners", "Industries", "Solutions", "Media"}
+	fakeCompanySuffixEN = []string{"Inc.", "LLC", "Corp.", "Group", "Co."}
+
+	// fakeSurnamePinyin / fakeNamePinyin 与 commonSurnames / commonNames 一一对应，用于生成邮箱
+	fakeSurnamePinyin = []string{
+		"wang", "li", "zhang", "liu", "chen", "yang", "huang", "zhao", "zhou", "wu",
+		"xu", "sun", "ma", "zhu", "hu", "guo", "he", "lin", "luo", "gao",
+	}
+	fakeNamePinyin = []string{
+		"wei", "fang", "na", "min", "jing", "li", "qiang", "lei", "jun", "yang",
+		"yong", "yan", "jie", "juan", "tao", "ming", "chao", "xiu", "xia", "ping",
+		"gang", "gui", "ying", "hua", "jian", "wen", "hui", "ling", "ting", "yu",
+	}
+	fakeDistrictsCN      = []string{"北京市朝阳区", "上海市浦东新区", "广州市天河区", "深圳市南山区", "杭州市西湖区", "成都市武侯区", "南京市鼓楼区", "武汉市洪山区", "西安市雁塔区", "重庆市渝中区"}
+	fakeRoadsCN          = []string{"人民路", "解放路", "中山路", "建设路", "和平路", "长江路", "文化路", "新华路", "青年路", "胜利路"}
+	fakeCompanyCitiesCN  = []string{"北京", "上海", "广州", "深圳", "杭州", "成都", "南京", "武汉"}
+	fakeCompanyWordsCN   = []string{"华信", "恒达", "新元", "博远", "瑞丰", "嘉禾", "天成", "鼎盛", "启明", "同创"}
+	fakeCompanyTradesCN  = []string{"科技", "贸易", "网络", "物流", "信息技术", "餐饮管理"}
+	fakeCompanySuffixCN  = []string{"有限公司", "股份有限公司"}
+	fakeEmailDomains     = []string{"example.com", "example.net", "example.org"}
+	fakePhonePrefixDigit = "3456789"
+)
+
+// fakeRand 是 fake_* 规则使用的随机源；未配置 seed 时为引擎的 *rand.Rand。
+type fakeRand interface {
+	Intn(n int) int
+}
+
+// seededFakeRand 由 seed、规则与输入值派生的 splitmix64 序列，同一输入总是生成同一仿真值。
+type seededFakeRand struct {
+	state uint64
+}
+
+func newSeededFakeRand(seed int64, rule, input string) *seededFakeRand {
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%d\x00%s\x00%s", seed, rule, input)
+	return &seededFakeRand{state: h.Sum64()}
+}
+
+func (r *seededFakeRand) Intn(n int) int {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return int(z % uint64(n))
+}
+
+// fakeValue 按规则与 locale 生成仿真值，并截断到列长度（按字符计）。
+func (e *maskEngine) fakeValue(value any, rule config.MaskingConfig, column database.ColumnMetadata) string {
+	var r fakeRand = e.rng
+	if rule.Seed != nil {
+		r = newSeededFakeRand(*rule.Seed, rule.Rule, canonicalMaskInput(value))
+	}
+	limit := 0
+	if column.LengthValid && column.Length > 0 {
+		limit = int(column.Length)
+	}
+	zh := rule.Locale == config.MaskLocaleZhCN
+
+	var out string
+	switch rule.Rule {
+	case config.MaskRuleFakeName:
+		out = fakeName(r, zh)
+	case config.MaskRuleFakeEmail:
+		return fakeEmail(r, zh, limit)
+	case config.MaskRuleFakeAddress:
+		out = fakeAddress(r, zh)
+	case config.MaskRuleFakeCompany:
+		out = fakeCompany(r, zh)
+	case config.MaskRuleFakePhone:
+		out = fakePhone(r, zh)
+	}
+	return truncateRunes(out, limit)
+}
+
+func pick(r fakeRand, values []string) string {
+	return values[r.Intn(len(values))]
+}
+
+func fakeName(r fakeRand, zh bool) string {
+	if zh {
+		name := pick(r, commonSurnames) + pick(r, commonNames)
+		if r.Intn(10) < 3 {
+			name += pick(r, commonNames)
+		}
+		return name
+	}
+	return pick(r, fakeFirstNamesEN) + " " + pick(r, fakeLastNamesEN)
+}
+
+// fakeEmail 超出列长度时优先缩短 @ 之前的部分。
+func fakeEmail(r fakeRand, zh bool, limit int) string {
+	var local string
+	if zh {
+		local = pick(r, fakeSurnamePinyin) + "." + pick(r, fakeNamePinyin)
+	} else {
+		local = strings.ToLower(pick(r, fakeFirstNamesEN) + "." + pick(r, fakeLastNamesEN))
+	}
+	local += fmt.Sprintf("%d", r.Intn(1000))
+	domain := "@" + pick(r, fakeEmailDomains)
+	if limit > 0 && len(local)+len(domain) > limit {
+		if keep := limit - len(domain); keep >= 1 {
+			local = local[:keep]
+		} else {
+			return truncateRunes(local+domain, limit)
+		}
+	}
+	return local + domain
+}
+
+func fakeAddress(r fakeRand, zh bool) string {
+	if zh {
+		return fmt.Sprintf("%s%s%d号", pick(r, fakeDistrictsCN), pick(r, fakeRoadsCN), 1+r.Intn(999))
+	}
+	return fmt.Sprintf("%d %s %s, %s %05d", 1+r.Intn(9999), pick(r, fakeStreetsEN), pick(r, fakeStreetSuffixEN), pick(r, fakeCitiesEN), 10000+r.Intn(90000))
+}
+
+func fakeCompany(r fakeRand, zh bool) string {
+	if zh {
+		return pick(r, fakeCompanyCitiesCN) + pick(r, fakeCompanyWordsCN) + pick(r, fakeCompanyTradesCN) + pick(r, fakeCompanySuffixCN)
+	}
+	return pick(r, fakeLastNamesEN) + " " + pick(r, fakeCompanyWordsEN) + " " + pick(r, fakeCompanySuffixEN)
+}
+
+// fakePhone 生成格式合法的号码：中国大陆为 11 位手机号，美国为 555-01XX 段的虚构号码。
+func fakePhone(r fakeRand, zh bool) string {
+	if zh {
+		var b strings.Builder
+		b.WriteByte('1')
+		b.WriteByte(fakePhonePrefixDigit[r.Intn(len(fakePhonePrefixDigit))])
+		for i := 0; i < 9; i++ {
+			b.WriteByte(byte('0' + r.Intn(10)))
+		}
+		return b.String()
+	}
+	return fmt.Sprintf("(%d) 555-01%02d", 201+r.Intn(789), r.Intn(100))
+}
+
+func truncateRunes(s string, limit int) string {
+	if limit <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit])
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func TestMaskEngineFakeRules(t *testing.T) {
+	cases := []struct {
+		rule    string
+		locale  string
+		pattern string
+	}{
+		{config.MaskRuleFakeName, config.MaskLocaleEnUS, `^[A-Z][a-z]+ [A-Z][a-z]+$`},
+		{config.MaskRuleFakeName, config.MaskLocaleZhCN, `^\p{Han}{2,3}$`},
+		{config.MaskRuleFakeEmail, config.MaskLocaleEnUS, `^[a-z]+\.[a-z]+\d+@example\.(com|net|org)$`},
+		{config.MaskRuleFakeEmail, config.MaskLocaleZhCN, `^[a-z]+\.[a-z]+\d+@example\.(com|net|org)$`},
+		{config.MaskRuleFakeAddress, config.MaskLocaleEnUS, `^\d+ [A-Za-z]+ [A-Za-z]+, [A-Za-z ]+, [A-Z]{2} \d{5}$`},
+		{config.MaskRuleFakeAddress, config.MaskLocaleZhCN, `^\p{Han}+\d+号$`},
+		{config.MaskRuleFakeCompany, config.MaskLocaleEnUS, `^[A-Z][a-z]+ [A-Z][a-z]+ [A-Z][A-Za-z.]+$`},
+		{config.MaskRuleFakeCompany, config.MaskLocaleZhCN, `有限公司$`},
+		{config.MaskRuleFakePhone, config.MaskLocaleEnUS, `^\(\d{3}\) 555-01\d{2}$`},
+		{config.MaskRuleFakePhone, config.MaskLocaleZhCN, `^1[3-9]\d{9}$`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.rule+"/"+tc.locale, func(t *testing.T) {
+			columns := []database.ColumnMetadata{{Name: "col"}}
+			engine := mustMaskEngine(t, []config.MaskingConfig{{Column: "col", Rule: tc.rule, Locale: tc.locale}}, columns)
+			got := engine.apply([]any{"original"}, columns)[0].(string)
+			if !regexp.MustCompile(tc.pattern).MatchString(got) {
+				t.Fatalf("%s (%s) = %q, want match for %s", tc.rule, tc.locale, got, tc.pattern)
+			}
+		})
+	}
+}
+
+func TestMaskEngineFakeSeeded(t *testing.T) {
+	seed := int64(7)
+	columns := []database.ColumnMetadata{{Name: "name"}}
+	masks := []config.MaskingConfig{{Column: "name", Rule: config.MaskRuleFakeName, Locale: config.MaskLocaleEnUS, Seed: &seed}}
+
+	first := mustMaskEngine(t, masks, columns)
+	second := mustMaskEngine(t, masks, columns)
+	for _, in := range []string{"alice", "bob", "carol"} {
+		a := first.apply([]any{in}, columns)[0]
+		b := second.apply([]any{in}, columns)[0]
+		if a != b {
+			t.Fatalf("seeded fake value for %q differs across engines: %v vs %v", in, a, b)
+		}
+	}
+
+	distinct := make(map[any]bool)
+	for _, in := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		distinct[first.apply([]any{in}, columns)[0]] = true
+	}
+	if len(distinct) < 2 {
+		t.Fatal("expected different inputs to produce different fake values")
+	}
+
+	other := int64(8)
+	masks[0].Seed = &other
+	changed := false
+	for _, in := range []string{"alice", "bob", "carol", "dave"} {
+		if mustMaskEngine(t, masks, columns).apply([]any{in}, columns)[0] != first.apply([]any{in}, columns)[0] {
+			changed = true
+		}
+	}
+	if !changed {
+		t.Fatal("expected a different seed to change the fake values")
+	}
+}
+
+func TestMaskEngineFakeRespectsColumnLength(t *testing.T) {
+	columns := []database.ColumnMetadata{
+		{Name: "email", Length: 20, LengthValid: true},
+		{Name: "address", Length: 8, LengthValid: true},
+		{Name: "company", Length: 5, LengthValid: true},
+	}
+	masks := []config.MaskingConfig{
+		{Column: "email", Rule: config.MaskRuleFakeEmail, Locale: config.MaskLocaleEnUS},
+		{Column: "address", Rule: config.MaskRuleFakeAddress, Locale: config.MaskLocaleEnUS},
+		{Column: "company", Rule: config.MaskRuleFakeCompany, Locale: config.MaskLocaleZhCN},
+	}
+	engine := mustMaskEngine(t, masks, columns)
+	for i := 0; i < 50; i++ {
+		row := engine.apply([]any{"a@b.c", "somewhere", "acme"}, columns)
+		for j, col := range columns {
+			if n := utf8.RuneCountInString(row[j].(string)); int64(n) > col.Length {
+				t.Fatalf("%s = %q exceeds length %d", col.Name, row[j], col.Length)
+			}
+		}
+		if email := row[0].(string); !strings.Contains(email, "@example.") {
+			t.Fatalf("expected the domain to survive truncation, got %q", email)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package processor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// 带密钥的脱敏规则：hmac、fpe（保留格式加密）与 token（确定性令牌）。
+// 相同的密钥、salt 与输入在任何任务、任何一次运行中都得到相同的输出，
+// 因此脱敏后的列仍可以跨表关联；换用不同的 salt 即可切断关联。
+
+const (
+	// fpeRounds 为 Feistel 轮数，与 FF1 相同
+	fpeRounds = 10
+	// maskTokenLength 为 token 规则中摘要部分的默认长度
+	maskTokenLength = 16
+)
+
+var (
+	maskKeyMu sync.Mutex
+	// maskKeyFiles 缓存已读取的密钥文件，CDC 逐行创建脱敏引擎时不必反复读盘
+	maskKeyFiles = make(map[string][]byte)
+
+	maskTokenEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+func isKeyedMaskRule(rule string) bool {
+	switch rule {
+	case config.MaskRuleHMAC, config.MaskRuleFPE, config.MaskRuleToken:
+		return true
+	}
+	return false
+}
+
+// loadMaskKey 读取规则的密钥；密钥文件在进程内只读取一次。
+func loadMaskKey(rule config.MaskingConfig) ([]byte, error) {
+	if rule.KeyFile != "" {
+		maskKeyMu.Lock()
+		defer maskKeyMu.Unlock()
+		if key, ok := maskKeyFiles[rule.KeyFile]; ok {
+			return key, nil
+		}
+		data, err := os.ReadFile(rule.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read masking key file %s: %w", rule.KeyFile, err)
+		}
+		key := bytes.TrimSpace(data)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("masking key file %s is empty", rule.KeyFile)
+		}
+		maskKeyFiles[rule.KeyFile] = key
+		return key, nil
+	}
+	if rule.KeyEnv == "" {
+		return nil, fmt.Errorf("rule '%s' requires key_env or key_file", rule.Rule)
+	}
+	key := os.Getenv(rule.KeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("masking key environment variable %s is not set", rule.KeyEnv)
+	}
+	return []byte(key), nil
+}
+
+// canonicalMaskInput 返回值的规范字符串，使同一值在不同驱动返回不同时区时得到相同结果。
+func canonicalMaskInput(value any) string {
+	if t, ok := value.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	return toString(value)
+}
+
+func maskMAC(key []byte, salt, input string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(salt))
+	mac.Write([]byte{0})
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
+
+// hmacValue 返回 HMAC-SHA256 的十六进制摘要，超出列长度时截断。
+func hmacValue(key []byte, salt string, value any, column database.ColumnMetadata) string {
+	out := hex.EncodeToString(maskMAC(key, salt, canonicalMaskInput(value)))
+	if column.LengthValid && column.Length > 0 && int64(len(out)) > column.Length {
+		out = out[:column.Length]
+	}
+	return out
+}
+
+// tokenValue 返回确定性令牌：整数列为非负 int64，其余为 value 前缀加 base32 摘要。
+// 列长度不足时先缩短摘要部分。
+func tokenValue(key []byte, rule config.MaskingConfig, value any, column database.ColumnMetadata) any {
+	sum := maskMAC(key, rule.Salt, canonicalMaskInput(value))
+	if isIntegerValue(value) || strings.Contains(strings.ToUpper(column.GoType), "INT") {
+		return int64(binary.BigEndian.Uint64(sum[:8]) & math.MaxInt64)
+	}
+
+	prefix := rule.Value
+	body := strings.ToLower(maskTokenEncoding.EncodeToString(sum))
+	n := maskTokenLength
+	if column.LengthValid && column.Length > 0 {
+		limit := int(column.Length)
+		if len(prefix) >= limit {
+			prefix = ""
+		}
+		if avail := limit - len(prefix); avail < n {
+			n = avail
+		}
+	}
+	return prefix + body[:n]
+}
+
+// fpeValue 对数字与字母分别做保留格式加密：数字仍为数字，字母保持大小写，
+// 其他字符（分隔符、符号）原样保留，长度不变。整数输入仍返回整数，且不超出列类型的取值范围。
+func fpeValue(key []byte, salt string, value any, column database.ColumnMetadata, dbType string) any {
+	if isIntegerValue(value) {
+		return fpeInteger(key, salt, value, column, dbType)
+	}
+	return fpeString(key, salt, toString(value))
+}
+
+// fpeInteger 在整数列的取值范围内加密绝对值的各位数字，符号与位数不变。
+// 结果带前导零或超出范围时继续加密（cycle walking）：同位数的合法整数构成置换的一个子集，
+// 从其中出发必然回到其中，因此不同整数不会映射到同一个整数，主外键脱敏后仍一一对应。
+// 范围取列类型与 Go 类型中较小的一个；有符号类型返回 int64，无符号类型返回 uint64。
+func fpeInteger(key []byte, salt string, value any, column database.ColumnMetadata, dbType string) any {
+	var mag, limit uint64
+	negative, unsigned := false, false
+	maxPos, maxNeg, known := integerColumnBounds(column, dbType)
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		mag, limit, unsigned = v.Uint(), math.MaxUint64, true
+	default:
+		n := v.Int()
+		mag, limit = uint64(n), math.MaxInt64
+		if n < 0 {
+			// MinInt64 的绝对值比 MaxInt64 大 1
+			mag, limit, negative = uint64(-(n+1))+1, math.MaxInt64+1, true
+		}
+	}
+	if known {
+		bound := maxPos
+		if negative {
+			bound = maxNeg
+		}
+		// 值超出声明的列类型时类型信息不可信，退回 Go 类型的范围，保证 cycle walking 能结束
+		if bound < limit && mag <= bound {
+			limit = bound
+		}
+	}
+
+	text := strconv.FormatUint(mag, 10)
+	digits := make([]int, len(text))
+	for i := range text {
+		digits[i] = int(text[i] - '0')
+	}
+	var out uint64
+	for {
+		digits = feistelEncrypt(key, salt+"\x00digits", 10, digits)
+		if len(digits) > 1 && digits[0] == 0 {
+			continue
+		}
+		if negative && len(digits) == 1 && digits[0] == 0 {
+			// 0 属于非负数，负数只能映射到负数
+			continue
+		}
+		out = 0
+		overflow := false
+		for _, d := range digits {
+			if out > (math.MaxUint64-uint64(d))/10 {
+				overflow = true
+				break
+			}
+			out = out*10 + uint64(d)
+		}
+		if !overflow && out <= limit {
+			break
+		}
+	}
+
+	switch {
+	case unsigned:
+		return out
+	case negative:
+		return -int64(out-1) - 1
+	default:
+		return int64(out)
+	}
+}
+
+func fpeString(key []byte, salt, s string) string {
+	runes := []rune(s)
+	var digitPos, letterPos []int
+	var digits, letters []int
+	for i, r := range runes {
+		switch {
+		case r >= '0' && r <= '9':
+			digitPos = append(digitPos, i)
+			digits = append(digits, int(r-'0'))
+		case r >= 'a' && r <= 'z':
+			letterPos = append(letterPos, i)
+			letters = append(letters, int(r-'a'))
+		case r >= 'A' && r <= 'Z':
+			letterPos = append(letterPos, i)
+			letters = append(letters, int(r-'A'))
+		}
+	}
+
+	digits = feistelEncrypt(key, salt+"\x00digits", 10, digits)
+	for j, pos := range digitPos {
+		runes[pos] = rune('0' + digits[j])
+	}
+	letters = feistelEncrypt(key, salt+"\x00letters", 26, letters)
+	for j, pos := range letterPos {
+		if runes[pos] >= 'A' && runes[pos] <= 'Z' {
+			runes[pos] = rune('A' + letters[j])
+		} else {
+			runes[pos] = rune('a' + letters[j])
+		}
+	}
+	return string(runes)
+}
+
+// feistelEncrypt 以 FF1 的结构（平衡 Feistel、模 radix^m 加法）加密 radix 进制的数字串，
+// 轮函数为 HMAC-SHA256。结果是同长度数字串上的置换，因此不同输入不会映射到同一输出。
+func feistelEncrypt(key []byte, tweak string, radix int, x []int) []int {
+	n := len(x)
+	switch n {
+	case 0:
+		return x
+	case 1:
+		// 单个字符无法分成两半，按密钥偏移
+		shift := new(big.Int).SetBytes(maskMAC(key, tweak, "1"))
+		shift.Mod(shift, big.NewInt(int64(radix)))
+		return []int{(x[0] + int(shift.Int64())) % radix}
+	}
+
+	u := n / 2
+	v := n - u
+	a := append([]int(nil), x[:u]...)
+	b := append([]int(nil), x[u:]...)
+	bigRadix := big.NewInt(int64(radix))
+	for i := 0; i < fpeRounds; i++ {
+		m := u
+		if i%2 == 1 {
+			m = v
+		}
+		mac := hmac.New(sha256.New, key)
+		fmt.Fprintf(mac, "%s\x00%d\x00%d\x00", tweak, i, n)
+		for _, d := range b {
+			mac.Write([]byte{byte(d)})
+		}
+		y := new(big.Int).SetBytes(mac.Sum(nil))
+
+		c := radixToInt(a, bigRadix)
+		c.Add(c, y)
+		c.Mod(c, new(big.Int).Exp(bigRadix, big.NewInt(int64(m)), nil))
+		a, b = b, intToRadix(c, bigRadix, m)
+	}
+	return append(a, b...)
+}
+
+func radixToInt(x []int, radix *big.Int) *big.Int {
+	out := new(big.Int)
+	for _, d := range x {
+		out.Mul(out, radix)
+		out.Add(out, big.NewInt(int64(d)))
+	}
+	return out
+}
+
+func intToRadix(v *big.Int, radix *big.Int, m int) []int {
+	out := make([]int, m)
+	rem := new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		v.QuoRem(v, radix, rem)
+		out[i] = int(rem.Int64())
+	}
+	return out
+}
+
+// integerColumnBounds 按列类型返回可写入的最大正值与最小负值的绝对值，类型未知时 known 为 false。
+// SQLite 的整数列不论声明为何都是 8 字节；ClickHouse 的 Int8/Int16 等按位数命名，
+// PostgreSQL 的 INT2/INT4/INT8 按字节数命名；SQL Server 的 TINYINT 无符号。
+func integerColumnBounds(column database.ColumnMetadata, dbType string) (maxPos, maxNeg uint64, known bool) {
+	typeName := strings.ToUpper(column.DatabaseType)
+	if strings.HasPrefix(typeName, "NULLABLE(") {
+		typeName = strings.TrimSuffix(strings.TrimPrefix(typeName, "NULLABLE("), ")")
+	}
+	unsigned := strings.Contains(typeName, "UNSIGNED") || strings.HasPrefix(typeName, "UINT")
+	bits := 0
+	switch {
+	case typeName == "":
+		return 0, 0, false
+	case dbType == config.DatabaseTypeSQLite:
+		return 0, 0, false
+	case dbType == config.DatabaseTypeClickHouse:
+		digits := strings.TrimPrefix(strings.TrimPrefix(typeName, "U"), "INT")
+		if n, err := strconv.Atoi(digits); err == nil {
+			bits = n
+		}
+	case strings.Contains(typeName, "BIGINT"), strings.Contains(typeName, "BIGSERIAL"), typeName == "INT8", typeName == "SERIAL8":
+		bits = 64
+	case strings.Contains(typeName, "SMALLINT"), strings.Contains(typeName, "SMALLSERIAL"), typeName == "INT2":
+		bits = 16
+	case strings.Contains(typeName, "TINYINT"):
+		bits = 8
+		unsigned = unsigned || dbType == config.DatabaseTypeSQLServer
+	case strings.Contains(typeName, "MEDIUMINT"):
+		bits = 24
+	case strings.Contains(typeName, "INT"), strings.Contains(typeName, "SERIAL"):
+		bits = 32
+	case strings.Contains(typeName, "NUMBER"), strings.Contains(typeName, "NUMERIC"), strings.Contains(typeName, "DECIMAL"):
+		if column.PrecisionScaleValid && column.Scale == 0 && column.Precision > 0 && column.Precision <= 19 {
+			p := uint64(1)
+			for i := int64(0); i < column.Precision; i++ {
+				p *= 10
+			}
+			return p - 1, p - 1, true
+		}
+	}
+	switch {
+	case bits <= 0 || bits > 64:
+		return 0, 0, false
+	case unsigned && bits == 64:
+		return math.MaxUint64, 0, true
+	case unsigned:
+		return 1<<bits - 1, 0, true
+	default:
+		return 1<<(bits-1) - 1, 1 << (bits - 1), true
+	}
+}
+
+func isIntegerValue(v any) bool {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package processor
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func TestLoadMaskKey(t *testing.T) {
+	t.Setenv("DBF_TEST_MASK_KEY", "env-secret")
+	key, err := loadMaskKey(config.MaskingConfig{Rule: config.MaskRuleHMAC, KeyEnv: "DBF_TEST_MASK_KEY"})
+	if err != nil || string(key) != "env-secret" {
+		t.Fatalf("loadMaskKey(env) = %q, %v", key, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "mask.key")
+	if err := os.WriteFile(path, []byte("file-secret\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	key, err = loadMaskKey(config.MaskingConfig{Rule: config.MaskRuleFPE, KeyFile: path})
+	if err != nil || string(key) != "file-secret" {
+		t.Fatalf("loadMaskKey(file) = %q, %v", key, err)
+	}
+
+	if _, err := loadMaskKey(config.MaskingConfig{Rule: config.MaskRuleHMAC, KeyEnv: "DBF_TEST_MASK_KEY_UNSET"}); err == nil {
+		t.Fatal("expected error for unset key environment variable")
+	}
+	if _, err := loadMaskKey(config.MaskingConfig{Rule: config.MaskRuleHMAC, KeyFile: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("expected error for missing key file")
+	}
+}
+
+func TestMaskEngineMissingKey(t *testing.T) {
+	columns := []database.ColumnMetadata{{Name: "email"}}
+	masks := []config.MaskingConfig{{Column: "email", Rule: config.MaskRuleHMAC, KeyEnv: "DBF_TEST_MASK_KEY_UNSET"}}
+	if _, err := newMaskEngine(masks, columns, ""); err == nil || !strings.Contains(err.Error(), "DBF_TEST_MASK_KEY_UNSET") {
+		t.Fatalf("newMaskEngine() error = %v, want missing key error", err)
+	}
+}
+
+func TestMaskEngineHMAC(t *testing.T) {
+	t.Setenv("DBF_TEST_MASK_KEY", "secret")
+	columns := []database.ColumnMetadata{{Name: "email"}, {Name: "short", Length: 12, LengthValid: true}}
+	masks := []config.MaskingConfig{
+		{Column: "email", Rule: config.MaskRuleHMAC, KeyEnv: "DBF_TEST_MASK_KEY"},
+		{Column: "short", Rule: config.MaskRuleHMAC, KeyEnv: "DBF_TEST_MASK_KEY"},
+	}
+
+	first := mustMaskEngine(t, masks, columns).apply([]any{"alice@example.com", "alice@example.com"}, columns)
+	second := mustMaskEngine(t, masks, columns).apply([]any{"alice@example.com", "alice@example.com"}, columns)
+	if first[0] != second[0] {
+		t.Fatalf("hmac not deterministic across engines: %v vs %v", first[0], second[0])
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(first[0].(string)) {
+		t.Fatalf("unexpected hmac output %q", first[0])
+	}
+	if first[1] != first[0].(string)[:12] {
+		t.Fatalf("expected hmac truncated to column length, got %q", first[1])
+	}
+
+	salted := mustMaskEngine(t, []config.MaskingConfig{{Column: "email", Rule: config.MaskRuleHMAC, KeyEnv: "DBF_TEST_MASK_KEY", Salt: "orders"}}, columns[:1])
+	if got := salted.apply([]any{"alice@example.com"}, columns[:1]); got[0] == first[0] {
+		t.Fatal("expected salt to change the hmac output")
+	}
+
+	t.Setenv("DBF_TEST_MASK_KEY", "other")
+	if got := mustMaskEngine(t, masks, columns).apply([]any{"alice@example.com", "x"}, columns); got[0] == first[0] {
+		t.Fatal("expected a different key to change the hmac output")
+	}
+}
+
+func TestFPEPreservesFormat(t *testing.T) {
+	key := []byte("secret")
+	cases := []string{
+		"13800138000",
+		"110101199003077777",
+		"(415) 555-2671",
+		"AB-1234-cd",
+		"7",
+	}
+	for _, in := range cases {
+		out := fpeString(key, "", in)
+		if len([]rune(out)) != len([]rune(in)) {
+			t.Fatalf("fpe(%q) = %q: length changed", in, out)
+		}
+		for i, r := range []rune(in) {
+			o := []rune(out)[i]
+			switch {
+			case r >= '0' && r <= '9':
+				if o < '0' || o > '9' {
+					t.Fatalf("fpe(%q) = %q: digit at %d became %q", in, out, i, o)
+				}
+			case r >= 'a' && r <= 'z':
+				if o < 'a' || o > 'z' {
+					t.Fatalf("fpe(%q) = %q: lowercase letter at %d became %q", in, out, i, o)
+				}
+			case r >= 'A' && r <= 'Z':
+				if o < 'A' || o > 'Z' {
+					t.Fatalf("fpe(%q) = %q: uppercase letter at %d became %q", in, out, i, o)
+				}
+			default:
+				if o != r {
+					t.Fatalf("fpe(%q) = %q: separator at %d changed", in, out, i)
+				}
+			}
+		}
+		if again := fpeString(key, "", in); again != out {
+			t.Fatalf("fpe(%q) not deterministic: %q vs %q", in, out, again)
+		}
+	}
+	if fpeString(key, "", "13800138000") == fpeString(key, "users", "13800138000") {
+		t.Fatal("expected salt to change the fpe output")
+	}
+}
+
+func TestFPEIsBijective(t *testing.T) {
+	key := []byte("secret")
+	seen := make(map[string]string, 1000)
+	for i := 0; i < 1000; i++ {
+		in := []rune("000")
+		in[0], in[1], in[2] = rune('0'+i/100), rune('0'+i/10%10), rune('0'+i%10)
+		out := fpeString(key, "", string(in))
+		if prev, ok := seen[out]; ok {
+			t.Fatalf("fpe collision: %q and %q both map to %q", prev, string(in), out)
+		}
+		seen[out] = string(in)
+	}
+}
+
+func TestFPEIntegerValue(t *testing.T) {
+	got := fpeValue([]byte("secret"), "", int64(123456789), database.ColumnMetadata{}, "")
+	if _, ok := got.(int64); !ok {
+		t.Fatalf("expected int64 for integer input, got %T", got)
+	}
+}
+
+func TestFPEIntegerIsInjective(t *testing.T) {
+	key := []byte("secret")
+	seen := make(map[int64]int64, 10000)
+	for i := int64(1); i < 10000; i++ {
+		out, ok := fpeValue(key, "", i, database.ColumnMetadata{}, "").(int64)
+		if !ok {
+			t.Fatalf("fpe(%d) is not an int64", i)
+		}
+		if len(strconv.FormatInt(out, 10)) != len(strconv.FormatInt(i, 10)) {
+			t.Fatalf("fpe(%d) = %d: digit count changed", i, out)
+		}
+		if prev, ok := seen[out]; ok {
+			t.Fatalf("fpe collision: %d and %d both map to %d", prev, i, out)
+		}
+		seen[out] = i
+	}
+}
+
+func TestFPEIntegerStaysInRange(t *testing.T) {
+	key := []byte("secret")
+	for _, in := range []int64{0, -7, -1234, math.MaxInt64, math.MaxInt64 - 1, 1000000000000000000, math.MinInt64} {
+		out, ok := fpeValue(key, "", in, database.ColumnMetadata{}, "").(int64)
+		if !ok {
+			t.Fatalf("fpe(%d) is not an int64", in)
+		}
+		if (in < 0) != (out < 0) {
+			t.Fatalf("fpe(%d) = %d: sign changed", in, out)
+		}
+	}
+	if _, ok := fpeValue(key, "", uint64(math.MaxUint64), database.ColumnMetadata{}, "").(uint64); !ok {
+		t.Fatal("expected uint64 for unsigned input")
+	}
+}
+
+func TestFPEIntegerStaysInColumnRange(t *testing.T) {
+	key := []byte("secret")
+	intColumn := database.ColumnMetadata{Name: "id", DatabaseType: "INT"}
+	for _, in := range []int64{math.MaxInt32, math.MaxInt32 - 1, 2000000000, 1000000000, math.MinInt32, -1999999999} {
+		out, ok := fpeValue(key, "", in, intColumn, config.DatabaseTypeMySQL).(int64)
+		if !ok {
+			t.Fatalf("fpe(%d) is not an int64", in)
+		}
+		if out > math.MaxInt32 || out < math.MinInt32 {
+			t.Fatalf("fpe(%d) = %d: outside the INT column range", in, out)
+		}
+		if len(strconv.FormatInt(out, 10)) != len(strconv.FormatInt(in, 10)) {
+			t.Fatalf("fpe(%d) = %d: digit count changed", in, out)
+		}
+	}
+
+	// INT 列的 10 位值全部映射回 INT 范围内且不冲突
+	seen := make(map[int64]int64)
+	for in := int64(math.MaxInt32 - 2000); in <= math.MaxInt32; in++ {
+		out := fpeValue(key, "", in, intColumn, config.DatabaseTypeMySQL).(int64)
+		if out > math.MaxInt32 {
+			t.Fatalf("fpe(%d) = %d: outside the INT column range", in, out)
+		}
+		if prev, ok := seen[out]; ok {
+			t.Fatalf("fpe collision: %d and %d both map to %d", prev, in, out)
+		}
+		seen[out] = in
+	}
+
+	for _, tc := range []struct {
+		column database.ColumnMetadata
+		dbType string
+		in     int64
+		max    int64
+	}{
+		{database.ColumnMetadata{DatabaseType: "INT2"}, config.DatabaseTypePostgreSQL, 32767, math.MaxInt16},
+		{database.ColumnMetadata{DatabaseType: "Int16"}, config.DatabaseTypeClickHouse, 32000, math.MaxInt16},
+		{database.ColumnMetadata{DatabaseType: "TINYINT"}, config.DatabaseTypeMySQL, 127, math.MaxInt8},
+		{database.ColumnMetadata{DatabaseType: "NUMBER", Precision: 5, PrecisionScaleValid: true}, config.DatabaseTypeOracle, 99999, 99999},
+	} {
+		if out := fpeValue(key, "", tc.in, tc.column, tc.dbType).(int64); out > tc.max {
+			t.Fatalf("fpe(%d) on %s = %d, want <= %d", tc.in, tc.column.DatabaseType, out, tc.max)
+		}
+	}
+	// SQLite 的 INTEGER 列为 8 字节，不按 32 位收窄
+	if out := fpeValue(key, "", int64(9000000000), database.ColumnMetadata{DatabaseType: "INTEGER"}, config.DatabaseTypeSQLite).(int64); out < 1000000000 {
+		t.Fatalf("fpe(9000000000) on SQLite INTEGER = %d: digit count changed", out)
+	}
+}
+
+func TestTokenValue(t *testing.T) {
+	key := []byte("secret")
+	rule := config.MaskingConfig{Rule: config.MaskRuleToken, Value: "cus_"}
+
+	got := tokenValue(key, rule, "alice", database.ColumnMetadata{})
+	s, ok := got.(string)
+	if !ok || !strings.HasPrefix(s, "cus_") || len(s) != len("cus_")+maskTokenLength {
+		t.Fatalf("tokenValue() = %v", got)
+	}
+	if again := tokenValue(key, rule, "alice", database.ColumnMetadata{}); again != got {
+		t.Fatalf("token not deterministic: %v vs %v", got, again)
+	}
+
+	short := tokenValue(key, rule, "alice", database.ColumnMetadata{Length: 10, LengthValid: true}).(string)
+	if len(short) != 10 || !strings.HasPrefix(short, "cus_") {
+		t.Fatalf("expected token to fit column length, got %q", short)
+	}
+
+	id, ok := tokenValue(key, rule, int64(42), database.ColumnMetadata{}).(int64)
+	if !ok || id < 0 {
+		t.Fatalf("expected non-negative int64 token, got %v", id)
+	}
+
+	ts := time.Date(2026, 1, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	if tokenValue(key, rule, ts, database.ColumnMetadata{}) != tokenValue(key, rule, ts.UTC(), database.ColumnMetadata{}) {
+		t.Fatal("expected the same instant to tokenize identically across time zones")
+	}
+}
@@ -8,6 +8,15 @@ import (
 	"db-ferry/database"
 )
 
+func mustMaskEngine(t *testing.T, masks []config.MaskingConfig, columns []database.ColumnMetadata) *maskEngine {
+	t.Helper()
+	engine, err := newMaskEngine(masks, columns, "")
+	if err != nil {
+		t.Fatalf("newMaskEngine() error = %v", err)
+	}
+	return engine
+}
+
 func TestMaskPhoneCN(t *testing.T) {
 	cases := []struct {
 		input string
@@ -90,7 +99,7 @@ func TestMaskEngineApply(t *testing.T) {
 		{Column: "phone", Rule: config.MaskRulePhoneCN},
 		{Column: "email", Rule: config.MaskRuleEmail},
 	}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{1, "13800138000", "alice@example.com"}
 	result := engine.apply(row, columns)
@@ -109,7 +118,7 @@ func TestMaskEngineApply(t *testing.T) {
 func TestMaskEngineNilPassthrough(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "phone"}}
 	masks := []config.MaskingConfig{{Column: "phone", Rule: config.MaskRulePhoneCN}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{nil}
 	result := engine.apply(row, columns)
@@ -121,7 +130,7 @@ func TestMaskEngineNilPassthrough(t *testing.T) {
 func TestMaskEngineMissingColumn(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "id"}}
 	masks := []config.MaskingConfig{{Column: "missing", Rule: config.MaskRulePhoneCN}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{1}
 	result := engine.apply(row, columns)
@@ -133,7 +142,7 @@ func TestMaskEngineMissingColumn(t *testing.T) {
 func TestMaskEngineRandomNumeric(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "score"}}
 	masks := []config.MaskingConfig{{Column: "score", Rule: config.MaskRuleRandomNumeric, Range: []float64{0, 100}}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{999}
 	result := engine.apply(row, columns)
@@ -149,7 +158,7 @@ func TestMaskEngineRandomNumeric(t *testing.T) {
 func TestMaskEngineRandomNumericFloat(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "rate"}}
 	masks := []config.MaskingConfig{{Column: "rate", Rule: config.MaskRuleRandomNumeric, Range: []float64{0.5, 1.5}}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{9.99}
 	result := engine.apply(row, columns)
@@ -165,7 +174,7 @@ func TestMaskEngineRandomNumericFloat(t *testing.T) {
 func TestMaskEngineFixedValue(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "status", DatabaseType: "VARCHAR", GoType: "string"}}
 	masks := []config.MaskingConfig{{Column: "status", Rule: config.MaskRuleFixedValue, Value: "MASKED"}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{"active"}
 	result := engine.apply(row, columns)
@@ -177,7 +186,7 @@ func TestMaskEngineFixedValue(t *testing.T) {
 func TestMaskEngineHashDeterministic(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "token"}}
 	masks := []config.MaskingConfig{{Column: "token", Rule: config.MaskRuleHash}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{"secret123"}
 	result1 := engine.apply(append([]any(nil), row...), columns)
@@ -193,7 +202,7 @@ func TestMaskEngineHashDeterministic(t *testing.T) {
 func TestMaskEngineNameCN(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "name"}}
 	masks := []config.MaskingConfig{{Column: "name", Rule: config.MaskRuleNameCN}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{"张三"}
 	result := engine.apply(row, columns)
@@ -206,7 +215,7 @@ func TestMaskEngineNameCN(t *testing.T) {
 func TestMaskEngineRandomDate(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "dob"}}
 	masks := []config.MaskingConfig{{Column: "dob", Rule: config.MaskRuleRandomDate}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{"1990-01-01"}
 	result := engine.apply(row, columns)
@@ -272,7 +281,7 @@ func TestToString(t *testing.T) {
 func TestMaskValueUnsupportedRule(t *testing.T) {
 	columns := []database.ColumnMetadata{{Name: "token"}}
 	masks := []config.MaskingConfig{{Column: "token", Rule: "unsupported_rule"}}
-	engine := newMaskEngine(masks, columns)
+	engine := mustMaskEngine(t, masks, columns)
 
 	row := []any{"secret"}
 	result := engine.apply(row, columns)
@@ -0,0 +1,361 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"db-ferry/config"
+	"db-ferry/database"
+	"db-ferry/utils"
+)
+
+// pipelineBatch 是流水线中按读取顺序编号的一个批次。
+type pipelineBatch struct {
+	seq     int
+	rows    [][]any // 读取阶段为源行，转换后为待写入的行
+	resume  any     // 批次内最后一行的 resume_key 值
+	scanned int     // 读取的源行数
+	dlq     int     // 转换与写入阶段写入 DLQ 的行数
+	// rejected 为转换阶段写入 DLQ 的源行数（已计入 dlq），filtered 为被插件丢弃的源行数，
+	// skipped 为未配置 DLQ 时被插件跳过的源行数
+	rejected int
+	filtered int
+	skipped  int
+	latency  time.Duration
+}
+
+// rowTransform 转换单个源行，输出可为零行、一行或多行。
+type rowTransform func(row []any) (pluginOutcome, error)
+
+// pipelineStages 描述流水线各阶段的行为。
+type pipelineStages struct {
+	// read 读取下一行源数据，读完时返回 nil 行
+	read func() ([]any, error)
+	// resumeValue 返回源行的 resume_key 值，未配置 resume_key 时为 nil
+	resumeValue func(row []any) any
+	// batchSize 返回下一个批次的大小，允许在运行中调整（自适应批量）
+	batchSize func() int
+	// newTransform 为每个转换 worker 创建独立的转换函数（脱敏与插件引擎不能跨 goroutine 共享）
+	newTransform func() (rowTransform, func(), error)
+	// write 写入一个批次，返回写入 DLQ 的行数
+	write func(ctx context.Context, rows [][]any) (int, error)
+	// commit 按读取顺序在调用方 goroutine 上依次调用，负责计数、进度与断点
+	commit func(b *pipelineBatch) error
+}
+
+// runPipeline 以一个读取者、cfg.TransformWorkers 个转换 worker 与 cfg.Writers 个写入 worker
+// 执行迁移。阶段之间以容量为 cfg.QueueSize 的队列相连，下游变慢时上游随之阻塞；
+// 写入可能乱序完成，commit 只在此前所有批次都写入后才处理某个批次，因此断点不会越过未写入的行。
+func runPipeline(ctx context.Context, cfg config.PipelineConfig, st pipelineStages) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	readCh := make(chan *pipelineBatch, cfg.QueueSize)
+	writeCh := make(chan *pipelineBatch, cfg.QueueSize)
+	doneCh := make(chan *pipelineBatch, cfg.QueueSize)
+
+	send := func(ch chan<- *pipelineBatch, b *pipelineBatch) bool {
+		select {
+		case ch <- b:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		defer close(readCh)
+		for seq := 0; ; seq++ {
+			size := st.batchSize()
+			b := &pipelineBatch{seq: seq, rows: make([][]any, 0, size)}
+			for len(b.rows) < size {
+				row, err := st.read()
+				if err != nil {
+					cancel(err)
+					return
+				}
+				if row == nil {
+					break
+				}
+				if v := st.resumeValue(row); v != nil {
+					b.resume = v
+				}
+				b.rows = append(b.rows, row)
+			}
+			b.scanned = len(b.rows)
+			if b.scanned == 0 || !send(readCh, b) || b.scanned < size {
+				return
+			}
+		}
+	}()
+
+	var transformers sync.WaitGroup
+	for i := 0; i < cfg.TransformWorkers; i++ {
+		transform, closeFn, err := st.newTransform()
+		if err != nil {
+			cancel(err)
+			break
+		}
+		transformers.Add(1)
+		go func() {
+			defer transformers.Done()
+			if closeFn != nil {
+				defer closeFn()
+			}
+			for b := range readCh {
+				if ctx.Err() != nil {
+					return
+				}
+				out := make([][]any, 0, len(b.rows))
+				for _, row := range b.rows {
+					outcome, err := transform(row)
+					if err != nil {
+						cancel(err)
+						return
+					}
+					if outcome.dlq {
+						b.dlq++
+						b.rejected++
+					}
+					if outcome.filtered {
+						b.filtered++
+					}
+					if outcome.skipped {
+						b.skipped++
+					}
+					out = append(out, outcome.rows...)
+				}
+				b.rows = out
+				if !send(writeCh, b) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		transformers.Wait()
+		close(writeCh)
+	}()
+
+	var writers sync.WaitGroup
+	for i := 0; i < cfg.Writers; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for b := range writeCh {
+				if ctx.Err() != nil {
+					return
+				}
+				if len(b.rows) > 0 {
+					start := time.Now()
+					dlq, err := st.write(ctx, b.rows)
+					b.latency = time.Since(start)
+					if err != nil {
+						cancel(err)
+						return
+					}
+					b.dlq += dlq
+				}
+				if !send(doneCh, b) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		writers.Wait()
+		close(doneCh)
+	}()
+
+	pending := make(map[int]*pipelineBatch)
+	next := 0
+	for b := range doneCh {
+		if ctx.Err() != nil {
+			continue
+		}
+		pending[b.seq] = b
+		for {
+			nb, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			if err := st.commit(nb); err != nil {
+				cancel(err)
+				break
+			}
+			next++
+		}
+	}
+	<-readerDone
+
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// pipelineTask 汇总以流水线方式迁移一个结果集所需的参数。
+type pipelineTask struct {
+	task        config.TaskConfig
+	rows        *sql.Rows
+	scanColumns []database.ColumnMetadata
+	columns     []database.ColumnMetadata
+	// colIndices 为 nil 时不做列映射
+	colIndices []int
+	// resumeIndexes 为 resume_key 各列在 scanColumns 中的位置
+	resumeIndexes []int
+	masking       bool
+	targetDB      database.TargetDB
+	loadTable     string
+	mergeKeys     []string
+	dlqw          *dlqWriter
+	batchSize     int
+	adaptive      *adaptiveBatchController
+	progress      *utils.ProgressManager
+	totalRows     int
+	// notify 为 true 时每个批次提交后推送 task.progress 事件
+	notify bool
+	// outOfShard 非 nil 且返回 true 时停止读取，该行不再处理
+	outOfShard func(row []any) bool
+	// checkpoint 非 nil 时替代 updateResumeState 保存断点
+	checkpoint func(value any) error
+}
+
+// pipelineCounts 汇总流水线迁移的行数，含义与串行迁移相同：processed 包含写入 DLQ 的行。
+type pipelineCounts struct {
+	scanned   int
+	processed int
+	dlq       int
+	filtered  int
+	skipped   int
+}
+
+// migratePipelined 以流水线方式读取、转换并写入 in.rows，返回各类行数。
+func (p *Processor) migratePipelined(ctx context.Context, in pipelineTask) (pipelineCounts, error) {
+	task := in.task
+	var counts pipelineCounts
+	var size atomic.Int64
+	size.Store(int64(in.batchSize))
+
+	log.Printf("Pipeline enabled for %s: %d transform workers, %d writers, queue size %d",
+		task.TableName, task.Pipeline.TransformWorkers, task.Pipeline.Writers, task.Pipeline.QueueSize)
+
+	stages := pipelineStages{
+		read: func() ([]any, error) {
+			if !in.rows.Next() {
+				if err := in.rows.Err(); err != nil {
+					return nil, fmt.Errorf("error during row iteration: %w", err)
+				}
+				return nil, nil
+			}
+			row, err := p.scanRow(in.rows, in.scanColumns)
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan row: %w", err)
+			}
+			if in.outOfShard != nil && in.outOfShard(row) {
+				return nil, nil
+			}
+			return row, nil
+		},
+		resumeValue: func(row []any) any {
+			return resumeValueOf(row, in.resumeIndexes)
+		},
+		batchSize: func() int {
+			return int(size.Load())
+		},
+		newTransform: func() (rowTransform, func(), error) {
+			var masker *maskEngine
+			if in.masking {
+				var err error
+				if masker, err = newMaskEngine(task.Masking, in.columns, p.sourceDBType(task)); err != nil {
+					return nil, nil, err
+				}
+			}
+			engine, err := newPluginEngine(task.Plugin)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to initialize plugin engine: %w", err)
+			}
+			transform := func(row []any) (pluginOutcome, error) {
+				row = masker.apply(row, in.columns)
+				outcome, err := p.applyPlugin(engine, row, in.columns, in.dlqw, task)
+				if err != nil {
+					return pluginOutcome{}, err
+				}
+				if in.colIndices != nil {
+					for i, out := range outcome.rows {
+						outcome.rows[i] = remapRow(out, in.colIndices)
+					}
+				}
+				return outcome, nil
+			}
+			closeFn := func() {
+				if engine != nil {
+					engine.close()
+				}
+			}
+			return transform, closeFn, nil
+		},
+		write: func(ctx context.Context, rows [][]any) (int, error) {
+			batchStart := time.Now()
+			dlqCount, err := p.insertBatchWithRetry(ctx, in.targetDB, task, in.loadTable, in.columns, rows, in.mergeKeys, in.dlqw)
+			p.metrics.RecordBatchDuration(task.TableName, task.SourceDB, task.TargetDB, float64(time.Since(batchStart).Milliseconds()))
+			p.metrics.RecordBatch(task.TableName, task.SourceDB, task.TargetDB, err == nil)
+			if err != nil {
+				return 0, fmt.Errorf("failed to insert batch: %w", err)
+			}
+			return dlqCount, nil
+		},
+		commit: func(b *pipelineBatch) error {
+			counts.scanned += b.scanned
+			counts.processed += len(b.rows) + b.rejected
+			counts.dlq += b.dlq
+			counts.filtered += b.filtered
+			counts.skipped += b.skipped
+			p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, int64(len(b.rows)))
+			p.metrics.RecordDLQRows(task.TableName, task.SourceDB, task.TargetDB, int64(b.dlq))
+			p.metrics.RecordFilteredRows(task.TableName, task.SourceDB, task.TargetDB, int64(b.filtered))
+			p.metrics.RecordSkippedRows(task.TableName, task.SourceDB, task.TargetDB, int64(b.skipped))
+			if in.progress != nil {
+				in.progress.SetCurrent(int64(counts.scanned))
+			}
+			if in.notify {
+				p.notify(ProgressEvent{
+					Type:      "task.progress",
+					TaskName:  task.TableName,
+					SourceDB:  task.SourceDB,
+					TargetDB:  task.TargetDB,
+					TotalRows: in.totalRows,
+					Processed: counts.scanned,
+				})
+			}
+			save := in.checkpoint
+			if save == nil {
+				save = func(value any) error { return p.updateResumeState(task, value) }
+			}
+			if err := save(b.resume); err != nil {
+				return err
+			}
+			if in.adaptive != nil && len(b.rows) > 0 {
+				in.adaptive.record(b.latency, estimateBatchMemoryMB(b.rows))
+				size.Store(int64(in.adaptive.nextBatchSize(nil)))
+				if in.adaptive.shouldAdjust() {
+					log.Printf("%s for %s", in.adaptive.debugInfo(), task.TableName)
+				}
+			}
+			return nil
+		},
+	}
+
+	if err := runPipeline(ctx, task.Pipeline, stages); err != nil {
+		return counts, err
+	}
+	return counts, nil
+}
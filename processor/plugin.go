@@ -3,6 +3,8 @@ package processor
 import (
 	"context"
 	"fmt"
+	"log"
+	"strconv"
 	"time"
 
 	"db-ferry/config"
@@ -13,11 +15,74 @@ import (
 )
 
 // rowTransformer applies per-row transformations via a scripting engine.
+// A script may return one row, a list of rows, nothing (the row is dropped)
+// or db_ferry.skip(reason) to send the source row to the DLQ.
 type rowTransformer interface {
-	transform(row []any, columns []database.ColumnMetadata) ([]any, error)
+	transform(row []any, columns []database.ColumnMetadata) (pluginResult, error)
 	close()
 }
 
+// pluginResult 为插件处理一个源行的结果：rows 可为零行（过滤）或多行（拆分）；
+// skipped 为 true 时 rows 为空，源行连同 reason 写入 DLQ。
+type pluginResult struct {
+	rows    [][]any
+	skipped bool
+	reason  string
+}
+
+// defaultSkipReason 为脚本调用 db_ferry.skip() 未给出原因时写入 DLQ 的原因。
+const defaultSkipReason = "skipped by plugin"
+
+func skipResult(reason string) pluginResult {
+	if reason == "" {
+		reason = defaultSkipReason
+	}
+	return pluginResult{skipped: true, reason: reason}
+}
+
+// pluginOutcome 为应用插件后一个源行的去向。
+type pluginOutcome struct {
+	rows [][]any
+	// dlq 为 true 表示源行因转换失败或被跳过而写入了 DLQ
+	dlq bool
+	// filtered 为 true 表示脚本返回空结果，源行被丢弃
+	filtered bool
+	// skipped 为 true 表示脚本调用了 db_ferry.skip 且未配置 DLQ，源行被丢弃；
+	// 配置了 DLQ 时跳过的行写入 DLQ，只标记 dlq
+	skipped bool
+}
+
+// applyPlugin 对源行执行插件。转换失败与显式跳过的行在配置了 DLQ 时写入原始源行；
+// 未配置 DLQ 时转换失败返回错误，跳过的行不写入目标并记录原因。engine 为 nil 时原样输出。
+func (p *Processor) applyPlugin(engine rowTransformer, row []any, columns []database.ColumnMetadata, dlqw *dlqWriter, task config.TaskConfig) (pluginOutcome, error) {
+	if engine == nil {
+		return pluginOutcome{rows: [][]any{row}}, nil
+	}
+	res, err := engine.transform(row, columns)
+	reason := ""
+	switch {
+	case err != nil:
+		if dlqw == nil {
+			return pluginOutcome{}, fmt.Errorf("plugin transform failed: %w", err)
+		}
+		reason = "plugin transform failed: " + err.Error()
+	case res.skipped:
+		if dlqw == nil {
+			log.Printf("Plugin skipped a row of table %s without a dlq_path: %s", task.TableName, res.reason)
+			return pluginOutcome{skipped: true}, nil
+		}
+		reason = "plugin skipped row: " + res.reason
+	case len(res.rows) == 0:
+		return pluginOutcome{filtered: true}, nil
+	default:
+		return pluginOutcome{rows: res.rows}, nil
+	}
+	if dlqErr := dlqw.write(row, reason, p.taskKey(task), task.TableName); dlqErr != nil {
+		return pluginOutcome{}, fmt.Errorf("failed to write to DLQ: %w", dlqErr)
+	}
+	return pluginOutcome{dlq: true}, nil
+}
+
 // newPluginEngine creates a rowTransformer based on the task's plugin config.
 // Returns nil when no plugin is configured.
 func newPluginEngine(cfg config.PluginConfig) (rowTransformer, error) {
@@ -42,8 +107,21 @@ type luaTransformer struct {
 	timeout time.Duration
 }
 
+// luaSkip 是 db_ferry.skip(reason) 返回的 userdata 值。
+type luaSkip struct {
+	reason string
+}
+
 func newLuaTransformer(cfg config.PluginConfig) (*luaTransformer, error) {
 	l := lua.NewState()
+	api := l.NewTable()
+	api.RawSetString("skip", l.NewFunction(func(l *lua.LState) int {
+		ud := l.NewUserData()
+		ud.Value = luaSkip{reason: l.OptString(1, "")}
+		l.Push(ud)
+		return 1
+	}))
+	l.SetGlobal("db_ferry", api)
 	if err := l.DoString(cfg.Script); err != nil {
 		l.Close()
 		return nil, fmt.Errorf("failed to compile lua script: %w", err)
@@ -56,7 +134,7 @@ func newLuaTransformer(cfg config.PluginConfig) (*luaTransformer, error) {
 	}, nil
 }
 
-func (t *luaTransformer) transform(row []any, columns []database.ColumnMetadata) ([]any, error) {
+func (t *luaTransformer) transform(row []any, columns []database.ColumnMetadata) (pluginResult, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
 	defer cancel()
 
@@ -68,18 +146,35 @@ func (t *luaTransformer) transform(row []any, columns []database.ColumnMetadata)
 	t.lstate.Push(table)
 
 	if err := t.lstate.PCall(1, 1, nil); err != nil {
-		return nil, fmt.Errorf("lua transform failed: %w", err)
+		return pluginResult{}, fmt.Errorf("lua transform failed: %w", err)
 	}
 
 	result := t.lstate.Get(-1)
 	t.lstate.Pop(1)
 
-	retTable, ok := result.(*lua.LTable)
-	if !ok {
-		return nil, fmt.Errorf("lua transform must return a table, got %T", result)
+	switch ret := result.(type) {
+	case *lua.LNilType:
+		return pluginResult{}, nil
+	case *lua.LUserData:
+		if skip, ok := ret.Value.(luaSkip); ok {
+			return skipResult(skip.reason), nil
+		}
+	case *lua.LTable:
+		// 数组部分的首个元素为表时视为多行，否则整张表为一行（{} 仍为全 NULL 的一行）
+		if _, ok := ret.RawGetInt(1).(*lua.LTable); !ok {
+			return pluginResult{rows: [][]any{t.luaTableToRow(ret, columns)}}, nil
+		}
+		rows := make([][]any, 0, ret.Len())
+		for i := 1; i <= ret.Len(); i++ {
+			item, ok := ret.RawGetInt(i).(*lua.LTable)
+			if !ok {
+				return pluginResult{}, fmt.Errorf("lua transform must return a table of rows, element %d is %s", i, ret.RawGetInt(i).Type())
+			}
+			rows = append(rows, t.luaTableToRow(item, columns))
+		}
+		return pluginResult{rows: rows}, nil
 	}
-
-	return t.luaTableToRow(retTable, columns), nil
+	return pluginResult{}, fmt.Errorf("lua transform must return a table, got %T", result)
 }
 
 func (t *luaTransformer) close() {
@@ -168,8 +263,21 @@ type jsTransformer struct {
 	timeout time.Duration
 }
 
+// jsPrelude 在用户脚本之前执行，提供 db_ferry.skip(reason)。
+const jsPrelude = `var db_ferry = {
+	skip: function (reason) {
+		return { __db_ferry_skip: reason === undefined || reason === null ? "" : String(reason) };
+	}
+};`
+
+// jsSkipProperty 标记 db_ferry.skip 的返回值。
+const jsSkipProperty = "__db_ferry_skip"
+
 func newJSTransformer(cfg config.PluginConfig) (*jsTransformer, error) {
 	vm := otto.New()
+	if _, err := vm.Run(jsPrelude); err != nil {
+		return nil, fmt.Errorf("failed to initialize javascript runtime: %w", err)
+	}
 	if _, err := vm.Run(cfg.Script); err != nil {
 		return nil, fmt.Errorf("failed to compile javascript script: %w", err)
 	}
@@ -181,7 +289,7 @@ func newJSTransformer(cfg config.PluginConfig) (*jsTransformer, error) {
 	}, nil
 }
 
-func (t *jsTransformer) transform(row []any, columns []database.ColumnMetadata) ([]any, error) {
+func (t *jsTransformer) transform(row []any, columns []database.ColumnMetadata) (pluginResult, error) {
 	obj := t.rowToJSObject(row, columns)
 
 	// otto does not support context cancellation; use a goroutine with timeout.
@@ -199,13 +307,59 @@ func (t *jsTransformer) transform(row []any, columns []database.ColumnMetadata)
 
 	select {
 	case <-time.After(t.timeout):
-		return nil, fmt.Errorf("javascript transform timed out after %v", t.timeout)
+		return pluginResult{}, fmt.Errorf("javascript transform timed out after %v", t.timeout)
 	case res := <-done:
 		if res.err != nil {
-			return nil, fmt.Errorf("javascript transform failed: %w", res.err)
+			return pluginResult{}, fmt.Errorf("javascript transform failed: %w", res.err)
 		}
-		return t.jsObjectToRow(res.value, columns)
+		return t.jsResult(res.value, columns)
+	}
+}
+
+// jsResult 解析 transform 的返回值：null/undefined 丢弃该行，数组为多行，
+// db_ferry.skip 的返回值表示跳过，其余对象为一行。
+func (t *jsTransformer) jsResult(val otto.Value, columns []database.ColumnMetadata) (pluginResult, error) {
+	if val.IsNull() || val.IsUndefined() {
+		return pluginResult{}, nil
+	}
+	if !val.IsObject() {
+		return pluginResult{}, fmt.Errorf("javascript transform must return an object, got %s", val.Class())
+	}
+	obj := val.Object()
+	if obj.Class() == "Array" {
+		lengthVal, err := obj.Get("length")
+		if err != nil {
+			return pluginResult{}, fmt.Errorf("failed to get array length: %w", err)
+		}
+		length, err := lengthVal.ToInteger()
+		if err != nil {
+			return pluginResult{}, fmt.Errorf("failed to get array length: %w", err)
+		}
+		rows := make([][]any, 0, length)
+		for i := int64(0); i < length; i++ {
+			item, err := obj.Get(strconv.FormatInt(i, 10))
+			if err != nil {
+				return pluginResult{}, fmt.Errorf("failed to get array element %d: %w", i, err)
+			}
+			if !item.IsObject() {
+				return pluginResult{}, fmt.Errorf("javascript transform must return an array of objects, element %d is %s", i, item.Class())
+			}
+			row, err := t.jsObjectToRow(item, columns)
+			if err != nil {
+				return pluginResult{}, err
+			}
+			rows = append(rows, row)
+		}
+		return pluginResult{rows: rows}, nil
+	}
+	if reason, err := obj.Get(jsSkipProperty); err == nil && reason.IsString() {
+		return skipResult(reason.String()), nil
+	}
+	row, err := t.jsObjectToRow(val, columns)
+	if err != nil {
+		return pluginResult{}, err
 	}
+	return pluginResult{rows: [][]any{row}}, nil
 }
 
 func (t *jsTransformer) close() {}
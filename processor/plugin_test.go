@@ -1,6 +1,8 @@
 package processor
 
 import (
+	"bytes"
+	"log"
 	"strings"
 	"testing"
 	"time"
@@ -28,6 +30,19 @@ func TestNewPluginEngine(t *testing.T) {
 	})
 }
 
+// transformOne 执行插件并要求恰好输出一行。
+func transformOne(t *testing.T, tr rowTransformer, row []any, columns []database.ColumnMetadata) []any {
+	t.Helper()
+	res, err := tr.transform(row, columns)
+	if err != nil {
+		t.Fatalf("transform() error = %v", err)
+	}
+	if res.skipped || len(res.rows) != 1 {
+		t.Fatalf("expected exactly one row, got %+v", res)
+	}
+	return res.rows[0]
+}
+
 func TestLuaTransformer(t *testing.T) {
 	columns := []database.ColumnMetadata{
 		{Name: "id"},
@@ -50,10 +65,7 @@ func TestLuaTransformer(t *testing.T) {
 		defer tr.close()
 
 		row := []any{1, "alice", 10.5}
-		result, err := tr.transform(row, columns)
-		if err != nil {
-			t.Fatalf("transform() error = %v", err)
-		}
+		result := transformOne(t, tr, row, columns)
 		if result[0] != float64(1) {
 			t.Fatalf("expected id unchanged, got %v", result[0])
 		}
@@ -81,10 +93,7 @@ func TestLuaTransformer(t *testing.T) {
 		defer tr.close()
 
 		row := []any{1, nil, 10.5}
-		result, err := tr.transform(row, columns)
-		if err != nil {
-			t.Fatalf("transform() error = %v", err)
-		}
+		result := transformOne(t, tr, row, columns)
 		if result[1] != "default" {
 			t.Fatalf("expected nil replaced, got %v", result[1])
 		}
@@ -171,12 +180,111 @@ func TestLuaTransformer(t *testing.T) {
 		defer tr.close()
 
 		row := []any{1, "alice", 75.0}
-		result, err := tr.transform(row, columns)
+		result := transformOne(t, tr, row, columns)
+		if result[1] != "high" {
+			t.Fatalf("expected high, got %v", result[1])
+		}
+	})
+
+	t.Run("nil return drops row", func(t *testing.T) {
+		script := `
+			function transform(row)
+				if row.value < 0 then
+					return nil
+				end
+				return row
+			end
+		`
+		tr, err := newLuaTransformer(config.PluginConfig{Engine: config.PluginEngineLua, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newLuaTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		res, err := tr.transform([]any{1, "a", -1.0}, columns)
 		if err != nil {
 			t.Fatalf("transform() error = %v", err)
 		}
-		if result[1] != "high" {
-			t.Fatalf("expected high, got %v", result[1])
+		if res.skipped || len(res.rows) != 0 {
+			t.Fatalf("expected row to be dropped, got %+v", res)
+		}
+	})
+
+	t.Run("list return fans out", func(t *testing.T) {
+		script := `
+			function transform(row)
+				local out = {}
+				for i = 1, row.value do
+					out[#out + 1] = {id = row.id * 10 + i, name = row.name, value = i}
+				end
+				return out
+			end
+		`
+		tr, err := newLuaTransformer(config.PluginConfig{Engine: config.PluginEngineLua, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newLuaTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		res, err := tr.transform([]any{1, "a", 3.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if len(res.rows) != 3 {
+			t.Fatalf("expected 3 rows, got %+v", res)
+		}
+		for i, row := range res.rows {
+			if row[0] != float64(11+i) || row[1] != "a" || row[2] != float64(i+1) {
+				t.Fatalf("unexpected row %d: %v", i, row)
+			}
+		}
+	})
+
+	t.Run("list with non-table element errors", func(t *testing.T) {
+		script := `function transform(row) return {row, 42} end`
+		tr, err := newLuaTransformer(config.PluginConfig{Engine: config.PluginEngineLua, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newLuaTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		_, err = tr.transform([]any{1, "a", 1.0}, columns)
+		if err == nil || !strings.Contains(err.Error(), "element 2") {
+			t.Fatalf("expected element error, got %v", err)
+		}
+	})
+
+	t.Run("skip with reason", func(t *testing.T) {
+		script := `
+			function transform(row)
+				if row.name == "" then
+					return db_ferry.skip("empty name")
+				end
+				if row.name == "x" then
+					return db_ferry.skip()
+				end
+				return row
+			end
+		`
+		tr, err := newLuaTransformer(config.PluginConfig{Engine: config.PluginEngineLua, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newLuaTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		res, err := tr.transform([]any{1, "", 1.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if !res.skipped || res.reason != "empty name" || len(res.rows) != 0 {
+			t.Fatalf("expected skip with reason, got %+v", res)
+		}
+		res, err = tr.transform([]any{1, "x", 1.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if !res.skipped || res.reason != defaultSkipReason {
+			t.Fatalf("expected skip with default reason, got %+v", res)
 		}
 	})
 }
@@ -203,10 +311,7 @@ func TestJSTransformer(t *testing.T) {
 		defer tr.close()
 
 		row := []any{1, "alice", 10.5}
-		result, err := tr.transform(row, columns)
-		if err != nil {
-			t.Fatalf("transform() error = %v", err)
-		}
+		result := transformOne(t, tr, row, columns)
 		if result[0] != float64(1) {
 			t.Fatalf("expected id unchanged, got %v", result[0])
 		}
@@ -234,10 +339,7 @@ func TestJSTransformer(t *testing.T) {
 		defer tr.close()
 
 		row := []any{1, nil, 10.5}
-		result, err := tr.transform(row, columns)
-		if err != nil {
-			t.Fatalf("transform() error = %v", err)
-		}
+		result := transformOne(t, tr, row, columns)
 		if result[1] != "default" {
 			t.Fatalf("expected nil replaced, got %v", result[1])
 		}
@@ -322,12 +424,162 @@ func TestJSTransformer(t *testing.T) {
 		defer tr.close()
 
 		row := []any{1, "alice", 75.0}
-		result, err := tr.transform(row, columns)
+		result := transformOne(t, tr, row, columns)
+		if result[1] != "high" {
+			t.Fatalf("expected high, got %v", result[1])
+		}
+	})
+
+	t.Run("null return drops row", func(t *testing.T) {
+		script := `
+			function transform(row) {
+				if (row.value < 0) {
+					return null;
+				}
+				return row;
+			}
+		`
+		tr, err := newJSTransformer(config.PluginConfig{Engine: config.PluginEngineJavaScript, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newJSTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		res, err := tr.transform([]any{1, "a", -1.0}, columns)
 		if err != nil {
 			t.Fatalf("transform() error = %v", err)
 		}
-		if result[1] != "high" {
-			t.Fatalf("expected high, got %v", result[1])
+		if res.skipped || len(res.rows) != 0 {
+			t.Fatalf("expected row to be dropped, got %+v", res)
+		}
+	})
+
+	t.Run("array return fans out", func(t *testing.T) {
+		script := `
+			function transform(row) {
+				return JSON.parse(row.name).map(function (tag, i) {
+					return {id: row.id * 10 + i, name: tag, value: row.value};
+				});
+			}
+		`
+		tr, err := newJSTransformer(config.PluginConfig{Engine: config.PluginEngineJavaScript, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newJSTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		res, err := tr.transform([]any{1, `["red","blue"]`, 5.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if len(res.rows) != 2 {
+			t.Fatalf("expected 2 rows, got %+v", res)
+		}
+		if res.rows[0][1] != "red" || res.rows[1][1] != "blue" || res.rows[1][0] != float64(11) {
+			t.Fatalf("unexpected rows: %v", res.rows)
+		}
+
+		res, err = tr.transform([]any{2, `[]`, 5.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if len(res.rows) != 0 {
+			t.Fatalf("expected empty array to drop the row, got %+v", res)
 		}
 	})
+
+	t.Run("array with non-object element errors", func(t *testing.T) {
+		script := `function transform(row) { return [row, 42]; }`
+		tr, err := newJSTransformer(config.PluginConfig{Engine: config.PluginEngineJavaScript, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newJSTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		_, err = tr.transform([]any{1, "a", 1.0}, columns)
+		if err == nil || !strings.Contains(err.Error(), "element 1") {
+			t.Fatalf("expected element error, got %v", err)
+		}
+	})
+
+	t.Run("skip with reason", func(t *testing.T) {
+		script := `
+			function transform(row) {
+				if (row.name === "") {
+					return db_ferry.skip("empty name");
+				}
+				if (row.name === "x") {
+					return db_ferry.skip();
+				}
+				return row;
+			}
+		`
+		tr, err := newJSTransformer(config.PluginConfig{Engine: config.PluginEngineJavaScript, Script: script, TimeoutMs: 1000})
+		if err != nil {
+			t.Fatalf("newJSTransformer() error = %v", err)
+		}
+		defer tr.close()
+
+		res, err := tr.transform([]any{1, "", 1.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if !res.skipped || res.reason != "empty name" || len(res.rows) != 0 {
+			t.Fatalf("expected skip with reason, got %+v", res)
+		}
+		res, err = tr.transform([]any{1, "x", 1.0}, columns)
+		if err != nil {
+			t.Fatalf("transform() error = %v", err)
+		}
+		if !res.skipped || res.reason != defaultSkipReason {
+			t.Fatalf("expected skip with default reason, got %+v", res)
+		}
+	})
+}
+
+func TestApplyPluginWithoutDLQ(t *testing.T) {
+	columns := []database.ColumnMetadata{{Name: "id"}, {Name: "name"}}
+	script := `
+		function transform(row)
+			if row.id == 1 then return db_ferry.skip("no") end
+			if row.id == 2 then error("boom") end
+			return {row, row}
+		end
+	`
+	tr, err := newLuaTransformer(config.PluginConfig{Engine: config.PluginEngineLua, Script: script, TimeoutMs: 1000})
+	if err != nil {
+		t.Fatalf("newLuaTransformer() error = %v", err)
+	}
+	defer tr.close()
+
+	p := &Processor{}
+	task := config.TaskConfig{TableName: "t"}
+
+	var logs bytes.Buffer
+	oldWriter := log.Writer()
+	log.SetOutput(&logs)
+	defer log.SetOutput(oldWriter)
+
+	outcome, err := p.applyPlugin(tr, []any{1, "a"}, columns, nil, task)
+	if err != nil || !outcome.skipped || outcome.filtered || outcome.dlq || len(outcome.rows) != 0 {
+		t.Fatalf("expected row to be skipped, not filtered, without a DLQ, got %+v, %v", outcome, err)
+	}
+	if !strings.Contains(logs.String(), "table t without a dlq_path: no") {
+		t.Fatalf("expected skip reason to be logged, got %q", logs.String())
+	}
+
+	_, err = p.applyPlugin(tr, []any{2, "b"}, columns, nil, task)
+	if err == nil || !strings.Contains(err.Error(), "plugin transform failed") {
+		t.Fatalf("expected plugin transform error, got %v", err)
+	}
+
+	outcome, err = p.applyPlugin(tr, []any{3, "c"}, columns, nil, task)
+	if err != nil || len(outcome.rows) != 2 {
+		t.Fatalf("expected two rows, got %+v, %v", outcome, err)
+	}
+
+	outcome, err = p.applyPlugin(nil, []any{4, "d"}, columns, nil, task)
+	if err != nil || len(outcome.rows) != 1 || outcome.rows[0][0] != 4 {
+		t.Fatalf("expected row to pass through without a plugin, got %+v, %v", outcome, err)
+	}
 }
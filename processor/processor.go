@@ -3,6 +3,7 @@ package processor
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -19,10 +20,11 @@ import (
 
 // TaskResult captures the outcome of a single migration task.
 type TaskResult struct {
-	Name   string
-	Rows   int
-	Status string
-	Error  string
+	Name    string
+	Rows    int
+	Deleted int
+	Status  string
+	Error   string
 }
 
 // ProgressEvent is emitted during task execution to report real-time progress.
@@ -44,6 +46,7 @@ type Processor struct {
 	manager              *database.ConnectionManager
 	config               *config.Config
 	stateFiles           map[string]*stateFile
+	stateHandles         map[string]*stateHandle
 	stateMu              sync.Mutex
 	historyRecorders     map[string]*database.HistoryRecorder
 	historyMu            sync.Mutex
@@ -56,7 +59,17 @@ type Processor struct {
 	progressNotifier     ProgressNotifier
 }
 
-var sleepFn = time.Sleep
+var sleepFn = sleepContext
+
+// sleepContext waits for d or until ctx is done, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
 
 func (p *Processor) recordTaskResult(r TaskResult) {
 	p.resultsMu.Lock()
@@ -91,6 +104,7 @@ func NewProcessorWithVersion(manager *database.ConnectionManager, cfg *config.Co
 		manager:          manager,
 		config:           cfg,
 		stateFiles:       make(map[string]*stateFile),
+		stateHandles:     make(map[string]*stateHandle),
 		historyRecorders: make(map[string]*database.HistoryRecorder),
 		version:          version,
 		sem:              make(chan struct{}, maxConcurrent),
@@ -141,7 +155,7 @@ func (p *Processor) ProcessAllTasksContext(ctx context.Context) error {
 				continue
 			}
 			log.Printf("Processing task: %s", task.TableName)
-			if err := p.processTask(task); err != nil {
+			if err := p.processTask(ctx, task); err != nil {
 				return fmt.Errorf("failed to process task %s: %w", task.TableName, err)
 			}
 			log.Printf("Successfully completed task: %s", task.TableName)
@@ -252,7 +266,7 @@ func (s *taskScheduler) runTask(idx int) {
 	if task.Shard.Enabled {
 		// 分片任务：内部子任务各自获取 semaphore，避免父任务占坑
 		log.Printf("Processing sharded task: %s", task.TableName)
-		err := s.p.processTaskInternal(task, true)
+		err := s.p.processTaskInternal(s.ctx, task, true)
 		if err != nil {
 			log.Printf("Task %s failed: %v", task.TableName, err)
 			s.cancel()
@@ -272,7 +286,7 @@ func (s *taskScheduler) runTask(idx int) {
 	defer func() { <-s.p.sem }()
 
 	log.Printf("Processing task: %s", task.TableName)
-	err := s.p.processTaskInternal(task, true)
+	err := s.p.processTaskInternal(s.ctx, task, true)
 	if err != nil {
 		log.Printf("Task %s failed: %v", task.TableName, err)
 		s.cancel()
@@ -327,16 +341,29 @@ func (p *Processor) buildTaskGraph() (tasks []config.TaskConfig, taskIndex map[s
 	return
 }
 
-func (p *Processor) processTask(task config.TaskConfig) error {
-	return p.processTaskInternal(task, false)
+func (p *Processor) processTask(ctx context.Context, task config.TaskConfig) error {
+	return p.processTaskInternal(ctx, task, false)
 }
 
-func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (err error) {
+func (p *Processor) processTaskInternal(ctx context.Context, task config.TaskConfig, silent bool) (err error) {
+	if task.Timeout != "" {
+		// 超时作用于整个任务（含分片子任务与 CDC 快照）；CDC 任务每轮重新计时
+		timeout, _ := time.ParseDuration(task.Timeout)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	if task.IsFederated() {
-		return p.processFederatedTask(task, silent)
+		return p.processFederatedTask(ctx, task, silent)
+	}
+	if task.CDC.IsLogical() {
+		return p.processLogicalCDCTask(ctx, task, silent)
+	}
+	if task.CDC.IsBinlog() {
+		return p.processBinlogCDCTask(ctx, task, silent)
 	}
 	if task.Shard.Enabled {
-		return p.processShardedTask(task, silent)
+		return p.processShardedTask(ctx, task, silent)
 	}
 	start := time.Now()
 	defer func() {
@@ -345,6 +372,12 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 
 	processedRows := 0
 	totalDLQ := 0
+	deletedRows := 0
+	// scannedRows 为读取的源行数，filteredRows 为被插件丢弃的源行数，
+	// skippedRows 为未配置 DLQ 时被插件跳过的源行数
+	scannedRows := 0
+	filteredRows := 0
+	skippedRows := 0
 	defer func() {
 		status := "success"
 		errMsg := ""
@@ -357,10 +390,11 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 			rows = 0
 		}
 		p.recordTaskResult(TaskResult{
-			Name:   task.TableName,
-			Rows:   rows,
-			Status: status,
-			Error:  errMsg,
+			Name:    task.TableName,
+			Rows:    rows,
+			Deleted: deletedRows,
+			Status:  status,
+			Error:   errMsg,
 		})
 		if status == "success" {
 			p.notify(ProgressEvent{
@@ -399,7 +433,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 	var recorder *database.HistoryRecorder
 	if p.config.History.Enabled {
 		recorder = p.getHistoryRecorder(task.TargetDB)
-		if ensureErr := recorder.EnsureTable(targetDB); ensureErr != nil {
+		if ensureErr := recorder.EnsureTable(ctx, targetDB); ensureErr != nil {
 			log.Printf("Warning: failed to ensure history table: %v", ensureErr)
 		} else {
 			rec := &database.MigrationRecord{
@@ -409,7 +443,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 				Mode:     task.Mode,
 				Version:  p.version,
 			}
-			historyID, _ = recorder.Start(targetDB, rec)
+			historyID, _ = recorder.Start(ctx, targetDB, rec)
 		}
 	}
 
@@ -428,7 +462,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 		return err
 	}
 
-	querySQL, countSQL := buildTaskSQL(task.SQL, task.ResumeKey, resumeLiteral)
+	querySQL, countSQL := buildTaskSQL(sourceDBCfg.Type, task.SQL, task.ResumeKey, resumeLiteral)
 	if task.ResumeKey != "" {
 		if resumeLiteral != "" {
 			log.Printf("Resume enabled for %s: %s > %s", task.TableName, task.ResumeKey, resumeLiteral)
@@ -437,7 +471,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 		}
 	}
 
-	rows, err := sourceDB.Query(querySQL)
+	rows, err := sourceDB.Query(ctx, querySQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -448,12 +482,9 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 		return fmt.Errorf("failed to extract column metadata: %w", err)
 	}
 
-	resumeIndex := -1
-	if task.ResumeKey != "" {
-		resumeIndex = findColumnIndex(sourceColumnsMeta, task.ResumeKey)
-		if resumeIndex < 0 {
-			return fmt.Errorf("resume_key '%s' not found in query columns for table %s", task.ResumeKey, task.TableName)
-		}
+	resumeIndexes, err := resolveResumeIndexes(sourceColumnsMeta, task)
+	if err != nil {
+		return err
 	}
 
 	columnsMeta, colIndices, err := applyColumnMapping(sourceColumnsMeta, task.Columns)
@@ -466,7 +497,10 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 		return err
 	}
 
-	masker := newMaskEngine(task.Masking, columnsMeta)
+	masker, err := newMaskEngine(task.Masking, columnsMeta, sourceDBCfg.Type)
+	if err != nil {
+		return err
+	}
 	if masker != nil {
 		log.Printf("Applying %d masking rules for table %s", len(task.Masking), task.TableName)
 	}
@@ -492,43 +526,33 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 	if len(task.Assertions) > 0 {
 		assertEngine := assertion.NewEngine(task.Assertions)
 		log.Printf("Running %d pre-migration assertions for table %s", len(task.Assertions), task.TableName)
-		results := assertEngine.RunPreCheck(sourceDB, sourceDBCfg.Type, countSQL)
+		results := assertEngine.RunPreCheck(ctx, sourceDB, sourceDBCfg.SQLDialect(), countSQL)
 		if err := assertEngine.HandleResults(results, sourceColumnsMeta, dlqwWriteFn(dlqw)); err != nil {
 			return fmt.Errorf("pre-migration assertion failed for table %s: %w", task.TableName, err)
 		}
 		for _, res := range results {
 			if res.Rule.Config().OnFail == config.AssertionActionDLQ && !res.Passed() {
-				fromClause := assertion.BuildFromClause(sourceDBCfg.Type, countSQL)
-				if dlqErr := assertEngine.FetchViolations(sourceDB, sourceDBCfg.Type, fromClause, res, sourceColumnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
+				fromClause := assertion.BuildFromClause(sourceDBCfg.SQLDialect(), countSQL)
+				if dlqErr := assertEngine.FetchViolations(ctx, sourceDB, sourceDBCfg.SQLDialect(), fromClause, res, sourceColumnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
 					log.Printf("[ASSERTION DLQ] failed to fetch pre-migration violations: %v", dlqErr)
 				}
 			}
 		}
 	}
 
-	if task.SkipCreateTable {
-		log.Printf("Skipping table creation for %s", task.TableName)
-	} else {
-		switch task.Mode {
-		case config.TaskModeAppend, config.TaskModeMerge:
-			if err := targetDB.EnsureTable(task.TableName, columnsMeta); err != nil {
-				return fmt.Errorf("failed to ensure target table: %w", err)
-			}
-			if task.SchemaEvolution {
-				if err := database.SyncSchema(targetDB, targetDBCfg.Type, task.TableName, columnsMeta); err != nil {
-					return fmt.Errorf("failed to sync schema: %w", err)
-				}
-			}
-		default:
-			if err := targetDB.CreateTable(task.TableName, columnsMeta); err != nil {
-				return fmt.Errorf("failed to prepare target table: %w", err)
-			}
-		}
+	loadTable, err := prepareTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, columnsMeta)
+	if err != nil {
+		return err
 	}
+	defer func() {
+		if err != nil {
+			dropStagingTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, loadTable)
+		}
+	}()
 
 	if len(task.PreSQL) > 0 {
 		log.Printf("Executing %d pre_sql hooks for table %s", len(task.PreSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PreSQL); err != nil {
+		if err := execHookSQLs(ctx, targetDB, task.PreSQL); err != nil {
 			return fmt.Errorf("pre_sql hook failed for table %s: %w", task.TableName, err)
 		}
 		log.Printf("Successfully executed all pre_sql hooks for table %s", task.TableName)
@@ -536,7 +560,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 
 	targetCountBefore := 0
 	if task.Validate == config.TaskValidateRowCount || task.Validate == config.TaskValidateChecksum || task.Validate == config.TaskValidateSample {
-		count, err := targetDB.GetTableRowCount(task.TableName)
+		count, err := targetDB.GetTableRowCount(loadTable)
 		if err != nil {
 			return fmt.Errorf("failed to get target row count before insert: %w", err)
 		}
@@ -544,7 +568,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 	}
 
 	var totalRows int
-	if count, err := sourceDB.GetRowCount(countSQL); err != nil {
+	if count, err := sourceDB.GetRowCount(ctx, countSQL); err != nil {
 		log.Printf("Warning: Could not get row count for progress tracking: %v", err)
 		totalRows = -1
 	} else {
@@ -592,12 +616,46 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 				errMsg = err.Error()
 				validationResult = "failed"
 			}
-			if finishErr := recorder.Finish(targetDB, historyID, int64(processedRows), int64(totalDLQ), validationResult, errMsg); finishErr != nil {
+			if finishErr := recorder.Finish(context.WithoutCancel(ctx), targetDB, historyID, int64(processedRows), int64(totalDLQ), int64(deletedRows), validationResult, errMsg); finishErr != nil {
 				log.Printf("Warning: failed to finish history record: %v", finishErr)
 			}
 		}()
 	}
 
+	loadTx, err := p.beginLoadTransaction(ctx, targetDB, task)
+	if err != nil {
+		return err
+	}
+	defer loadTx.rollback()
+
+	if task.Pipeline.Enabled {
+		// 流水线会读完 rows，其后的串行循环不再取到任何行
+		var counts pipelineCounts
+		counts, err = p.migratePipelined(ctx, pipelineTask{
+			task:          task,
+			rows:          rows,
+			scanColumns:   sourceColumnsMeta,
+			columns:       columnsMeta,
+			colIndices:    colIndices,
+			resumeIndexes: resumeIndexes,
+			masking:       masker != nil,
+			targetDB:      targetDB,
+			loadTable:     loadTable,
+			mergeKeys:     mergeKeys,
+			dlqw:          dlqw,
+			batchSize:     batchSize,
+			adaptive:      adaptive,
+			progress:      progress,
+			totalRows:     totalRows,
+			notify:        true,
+		})
+		processedRows, totalDLQ = counts.processed, counts.dlq
+		scannedRows, filteredRows, skippedRows = counts.scanned, counts.filtered, counts.skipped
+		if err != nil {
+			return err
+		}
+	}
+
 	for rows.Next() {
 		row, err := p.scanRow(rows, sourceColumnsMeta)
 		if err != nil {
@@ -605,32 +663,38 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 		}
 
 		row = masker.apply(row, columnsMeta)
+		scannedRows++
 
-		if pluginEngine != nil {
-			row, err = pluginEngine.transform(row, columnsMeta)
-			if err != nil {
-				if dlqw != nil {
-					if dlqErr := dlqw.write(row, "plugin transform failed: "+err.Error(), p.taskKey(task), task.TableName); dlqErr != nil {
-						return fmt.Errorf("failed to write to DLQ: %w", dlqErr)
-					}
-					totalDLQ++
-					continue
-				}
-				return fmt.Errorf("plugin transform failed: %w", err)
-			}
+		if len(resumeIndexes) > 0 {
+			lastResumeValue = resumeValueOf(row, resumeIndexes)
 		}
 
-		if resumeIndex >= 0 {
-			lastResumeValue = row[resumeIndex]
+		outcome, err := p.applyPlugin(pluginEngine, row, columnsMeta, dlqw, task)
+		if err != nil {
+			return err
 		}
-
-		batch = append(batch, remapRow(row, colIndices))
-		processedRows++
-		p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, 1)
+		if outcome.dlq {
+			processedRows++
+			totalDLQ++
+			p.metrics.RecordDLQRows(task.TableName, task.SourceDB, task.TargetDB, 1)
+		}
+		if outcome.filtered {
+			filteredRows++
+			p.metrics.RecordFilteredRows(task.TableName, task.SourceDB, task.TargetDB, 1)
+		}
+		if outcome.skipped {
+			skippedRows++
+			p.metrics.RecordSkippedRows(task.TableName, task.SourceDB, task.TargetDB, 1)
+		}
+		for _, out := range outcome.rows {
+			batch = append(batch, remapRow(out, colIndices))
+		}
+		processedRows += len(outcome.rows)
+		p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, int64(len(outcome.rows)))
 
 		if progress != nil {
 			if totalRows > 0 {
-				progress.SetCurrent(int64(processedRows))
+				progress.SetCurrent(int64(scannedRows))
 			} else {
 				progress.Increment()
 			}
@@ -638,7 +702,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 
 		if len(batch) >= batchSize {
 			batchStart := time.Now()
-			dlqCount, err := p.insertBatchWithRetry(targetDB, task, columnsMeta, batch, mergeKeys, dlqw)
+			dlqCount, err := p.insertBatchWithRetry(loadTx.writeContext(ctx), targetDB, task, loadTable, columnsMeta, batch, mergeKeys, dlqw)
 			latency := time.Since(batchStart)
 			p.metrics.RecordBatchDuration(task.TableName, task.SourceDB, task.TargetDB, float64(latency.Milliseconds()))
 			p.metrics.RecordBatch(task.TableName, task.SourceDB, task.TargetDB, err == nil)
@@ -653,9 +717,9 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 				SourceDB:  task.SourceDB,
 				TargetDB:  task.TargetDB,
 				TotalRows: totalRows,
-				Processed: processedRows,
+				Processed: scannedRows,
 			})
-			if err := p.updateResumeState(task, lastResumeValue); err != nil {
+			if err := p.checkpoint(loadTx, task, lastResumeValue); err != nil {
 				return err
 			}
 			if adaptive != nil {
@@ -672,7 +736,7 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 
 	if len(batch) > 0 {
 		batchStart := time.Now()
-		dlqCount, err := p.insertBatchWithRetry(targetDB, task, columnsMeta, batch, mergeKeys, dlqw)
+		dlqCount, err := p.insertBatchWithRetry(loadTx.writeContext(ctx), targetDB, task, loadTable, columnsMeta, batch, mergeKeys, dlqw)
 		latency := time.Since(batchStart)
 		p.metrics.RecordBatchDuration(task.TableName, task.SourceDB, task.TargetDB, float64(latency.Milliseconds()))
 		p.metrics.RecordBatch(task.TableName, task.SourceDB, task.TargetDB, err == nil)
@@ -687,9 +751,9 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 			SourceDB:  task.SourceDB,
 			TargetDB:  task.TargetDB,
 			TotalRows: totalRows,
-			Processed: processedRows,
+			Processed: scannedRows,
 		})
-		if err := p.updateResumeState(task, lastResumeValue); err != nil {
+		if err := p.checkpoint(loadTx, task, lastResumeValue); err != nil {
 			return err
 		}
 		if adaptive != nil {
@@ -703,45 +767,60 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 	}
 
 	if progress != nil && totalRows > 0 {
-		progress.SetCurrent(int64(processedRows))
-		if processedRows < totalRows {
-			log.Printf("Warning: processed %d rows but expected %d for table %s", processedRows, totalRows, task.TableName)
+		progress.SetCurrent(int64(scannedRows))
+		if scannedRows < totalRows {
+			log.Printf("Warning: read %d rows but expected %d for table %s", scannedRows, totalRows, task.TableName)
 		}
 		progress.SetCurrent(int64(totalRows))
 	}
+	if filteredRows > 0 {
+		log.Printf("Plugin filtered %d of %d source rows for table %s", filteredRows, scannedRows, task.TableName)
+	}
+	if skippedRows > 0 {
+		log.Printf("Plugin skipped %d of %d source rows for table %s (no dlq_path configured)", skippedRows, scannedRows, task.TableName)
+	}
 
 	if err := rows.Err(); err != nil {
 		return fmt.Errorf("error during row iteration: %w", err)
 	}
+	if err := loadTx.finish(); err != nil {
+		return err
+	}
 
-	if len(task.Indexes) > 0 {
-		log.Printf("Creating %d indexes for table %s", len(task.Indexes), task.TableName)
-		if err := targetDB.CreateIndexes(task.TableName, task.Indexes); err != nil {
-			return fmt.Errorf("failed to create indexes for table %s: %w", task.TableName, err)
+	if task.CDC.Enabled && task.CDC.DeleteDetection {
+		deleted, err := p.reconcileCDCDeletes(ctx, sourceDB, sourceDBCfg.SQLDialect(), targetDB, targetDBCfg.SQLDialect(), task, mergeKeys)
+		deletedRows = deleted
+		p.metrics.RecordDeletedRows(task.TableName, task.SourceDB, task.TargetDB, int64(deleted))
+		if err != nil {
+			return fmt.Errorf("cdc delete detection failed for table %s: %w", task.TableName, err)
+		}
+		if task.CDC.DeleteAction == config.CDCDeleteActionDelete {
+			targetCountBefore -= deleted
 		}
-		log.Printf("Successfully created all indexes for table %s", task.TableName)
 	}
 
-	if len(task.PostSQL) > 0 {
-		log.Printf("Executing %d post_sql hooks for table %s", len(task.PostSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PostSQL); err != nil {
-			return fmt.Errorf("post_sql hook failed for table %s: %w", task.TableName, err)
+	// 暂存表在切换前建好索引；post_sql 在切换后针对正式表执行
+	staged := loadTable != task.TableName
+	if staged {
+		if err := indexStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+			return err
 		}
-		log.Printf("Successfully executed all post_sql hooks for table %s", task.TableName)
+	} else if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, false); err != nil {
+		return err
 	}
 
 	if len(task.Assertions) > 0 {
 		postAssertions := resolvePostAssertions(task.Assertions, task.Columns)
 		assertEngine := assertion.NewEngine(postAssertions)
 		log.Printf("Running %d post-migration assertions for table %s", len(postAssertions), task.TableName)
-		results := assertEngine.RunPostCheck(targetDB, targetDBCfg.Type, task.TableName)
+		results := assertEngine.RunPostCheck(ctx, targetDB, targetDBCfg.SQLDialect(), loadTable)
 		if err := assertEngine.HandleResults(results, columnsMeta, dlqwWriteFn(dlqw)); err != nil {
 			return fmt.Errorf("post-migration assertion failed for table %s: %w", task.TableName, err)
 		}
 		for _, res := range results {
 			if res.Rule.Config().OnFail == config.AssertionActionDLQ && !res.Passed() {
-				fromClause := assertion.BuildTableFromClause(targetDBCfg.Type, task.TableName)
-				if dlqErr := assertEngine.FetchViolations(targetDB, targetDBCfg.Type, fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
+				fromClause := assertion.BuildTableFromClause(targetDBCfg.SQLDialect(), loadTable)
+				if dlqErr := assertEngine.FetchViolations(ctx, targetDB, targetDBCfg.SQLDialect(), fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
 					log.Printf("[ASSERTION DLQ] failed to fetch post-migration violations: %v", dlqErr)
 				}
 			}
@@ -754,14 +833,24 @@ func (p *Processor) processTaskInternal(task config.TaskConfig, silent bool) (er
 	}
 
 	validationTask := task
+	validationTask.TableName = loadTable
 	reportedProcessedRows := processedRows - totalDLQ
 	if reportedProcessedRows < 0 {
 		reportedProcessedRows = 0
 	}
-	if err := database.ValidateTask(sourceDB, targetDB, sourceDBCfg.Type, targetDBCfg.Type, validationTask, columnsMeta, countSQL, reportedProcessedRows, targetCountBefore, p.metrics); err != nil {
+	if err := database.ValidateTask(ctx, sourceDB, targetDB, sourceDBCfg.SQLDialect(), targetDBCfg.SQLDialect(), validationTask, columnsMeta, countSQL, reportedProcessedRows, targetCountBefore, p.metrics); err != nil {
 		return err
 	}
 
+	if staged {
+		if err := swapStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+			return err
+		}
+		if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, true); err != nil {
+			return err
+		}
+	}
+
 	if task.DLQPath != "" {
 		log.Printf("Processed %d rows, %d rows written to DLQ for table %s", processedRows, totalDLQ, task.TableName)
 	} else {
@@ -790,11 +879,17 @@ func (p *Processor) getHistoryRecorder(targetDBAlias string) *database.HistoryRe
 	p.historyRecorders[targetDBAlias] = r
 	return r
 }
-func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.SourceDB, targetDB database.TargetDB,
+func (p *Processor) migrateData(ctx context.Context, task config.TaskConfig, loadTable string, sourceDB database.SourceDB, targetDB database.TargetDB,
 	columnsMeta []database.ColumnMetadata, mergeKeys []string, dlqw *dlqWriter,
-	querySQL, countSQL string, silent bool) (processedRows int, totalDLQ int, err error) {
+	querySQL, countSQL string, silent bool, cursor *shardCursor) (processedRows int, totalDLQ int, err error) {
 
-	rows, err := sourceDB.Query(querySQL)
+	queryCtx, stopQuery := ctx, context.CancelFunc(func() {})
+	if cursor != nil {
+		// 分片上界被重平衡收缩后提前结束查询，不再读取已交给其他 worker 的行
+		queryCtx, stopQuery = context.WithCancel(ctx)
+		defer stopQuery()
+	}
+	rows, err := sourceDB.Query(queryCtx, querySQL)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -809,13 +904,13 @@ func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.Source
 		defer pluginEngine.close()
 	}
 
-	resumeIndex := -1
-	if task.ResumeKey != "" {
-		resumeIndex = findColumnIndex(columnsMeta, task.ResumeKey)
+	resumeIndexes, err := resolveResumeIndexes(columnsMeta, task)
+	if err != nil {
+		return 0, 0, err
 	}
 
 	var totalRows int
-	if count, err := sourceDB.GetRowCount(countSQL); err != nil {
+	if count, err := sourceDB.GetRowCount(ctx, countSQL); err != nil {
 		log.Printf("Warning: Could not get row count for progress tracking: %v", err)
 		totalRows = -1
 	} else {
@@ -839,38 +934,95 @@ func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.Source
 	}
 	var batch [][]any
 	var lastResumeValue any
+	stopped := false
+	outOfShard := func(row []any) bool {
+		if cursor == nil || len(resumeIndexes) == 0 || cursor.admit(resumeValueOf(row, resumeIndexes)) {
+			return false
+		}
+		stopped = true
+		stopQuery()
+		return true
+	}
 
-	for rows.Next() {
+	loadTx, err := p.beginLoadTransaction(ctx, targetDB, task)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer loadTx.rollback()
+	saveCheckpoint := func(value any) error {
+		if cursor != nil && cursor.tracker != nil {
+			// 分片任务的断点按分片保存
+			return cursor.tracker.checkpoint(cursor, value)
+		}
+		return p.checkpoint(loadTx, task, value)
+	}
+
+	// scannedRows 为读取的源行数，filteredRows 为被插件丢弃的源行数，
+	// skippedRows 为未配置 DLQ 时被插件跳过的源行数
+	scannedRows, filteredRows, skippedRows := 0, 0, 0
+	if task.Pipeline.Enabled {
+		// 流水线会读完 rows，其后的串行循环不再取到任何行
+		counts, err := p.migratePipelined(ctx, pipelineTask{
+			task:          task,
+			rows:          rows,
+			scanColumns:   columnsMeta,
+			columns:       columnsMeta,
+			resumeIndexes: resumeIndexes,
+			targetDB:      targetDB,
+			loadTable:     loadTable,
+			mergeKeys:     mergeKeys,
+			dlqw:          dlqw,
+			batchSize:     batchSize,
+			progress:      progress,
+			totalRows:     totalRows,
+			outOfShard:    outOfShard,
+			checkpoint:    saveCheckpoint,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+		processedRows, totalDLQ = counts.processed, counts.dlq
+		scannedRows, filteredRows, skippedRows = counts.scanned, counts.filtered, counts.skipped
+	}
+
+	for !stopped && rows.Next() {
 		row, err := p.scanRow(rows, columnsMeta)
 		if err != nil {
 			return 0, 0, fmt.Errorf("failed to scan row: %w", err)
 		}
-
-		if pluginEngine != nil {
-			row, err = pluginEngine.transform(row, columnsMeta)
-			if err != nil {
-				if dlqw != nil {
-					if dlqErr := dlqw.write(row, "plugin transform failed: "+err.Error(), p.taskKey(task), task.TableName); dlqErr != nil {
-						return 0, 0, fmt.Errorf("failed to write to DLQ: %w", dlqErr)
-					}
-					totalDLQ++
-					continue
-				}
-				return 0, 0, fmt.Errorf("plugin transform failed: %w", err)
-			}
+		if outOfShard(row) {
+			break
 		}
+		scannedRows++
 
-		if resumeIndex >= 0 {
-			lastResumeValue = row[resumeIndex]
+		if len(resumeIndexes) > 0 {
+			lastResumeValue = resumeValueOf(row, resumeIndexes)
 		}
 
-		batch = append(batch, row)
-		processedRows++
-		p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, 1)
+		outcome, err := p.applyPlugin(pluginEngine, row, columnsMeta, dlqw, task)
+		if err != nil {
+			return 0, 0, err
+		}
+		if outcome.dlq {
+			processedRows++
+			totalDLQ++
+			p.metrics.RecordDLQRows(task.TableName, task.SourceDB, task.TargetDB, 1)
+		}
+		if outcome.filtered {
+			filteredRows++
+			p.metrics.RecordFilteredRows(task.TableName, task.SourceDB, task.TargetDB, 1)
+		}
+		if outcome.skipped {
+			skippedRows++
+			p.metrics.RecordSkippedRows(task.TableName, task.SourceDB, task.TargetDB, 1)
+		}
+		batch = append(batch, outcome.rows...)
+		processedRows += len(outcome.rows)
+		p.metrics.RecordRowsProcessed(task.TableName, task.SourceDB, task.TargetDB, int64(len(outcome.rows)))
 
 		if progress != nil {
 			if totalRows > 0 {
-				progress.SetCurrent(int64(processedRows))
+				progress.SetCurrent(int64(scannedRows))
 			} else {
 				progress.Increment()
 			}
@@ -878,7 +1030,7 @@ func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.Source
 
 		if len(batch) >= batchSize {
 			batchStart := time.Now()
-			dlqCount, err := p.insertBatchWithRetry(targetDB, task, columnsMeta, batch, mergeKeys, dlqw)
+			dlqCount, err := p.insertBatchWithRetry(loadTx.writeContext(ctx), targetDB, task, loadTable, columnsMeta, batch, mergeKeys, dlqw)
 			latency := time.Since(batchStart)
 			p.metrics.RecordBatchDuration(task.TableName, task.SourceDB, task.TargetDB, float64(latency.Milliseconds()))
 			p.metrics.RecordBatch(task.TableName, task.SourceDB, task.TargetDB, err == nil)
@@ -887,7 +1039,7 @@ func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.Source
 			}
 			totalDLQ += dlqCount
 			p.metrics.RecordDLQRows(task.TableName, task.SourceDB, task.TargetDB, int64(dlqCount))
-			if err := p.updateResumeState(task, lastResumeValue); err != nil {
+			if err := saveCheckpoint(lastResumeValue); err != nil {
 				return 0, 0, err
 			}
 			batch = batch[:0]
@@ -896,7 +1048,7 @@ func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.Source
 
 	if len(batch) > 0 {
 		batchStart := time.Now()
-		dlqCount, err := p.insertBatchWithRetry(targetDB, task, columnsMeta, batch, mergeKeys, dlqw)
+		dlqCount, err := p.insertBatchWithRetry(loadTx.writeContext(ctx), targetDB, task, loadTable, columnsMeta, batch, mergeKeys, dlqw)
 		latency := time.Since(batchStart)
 		p.metrics.RecordBatchDuration(task.TableName, task.SourceDB, task.TargetDB, float64(latency.Milliseconds()))
 		p.metrics.RecordBatch(task.TableName, task.SourceDB, task.TargetDB, err == nil)
@@ -905,27 +1057,36 @@ func (p *Processor) migrateData(task config.TaskConfig, sourceDB database.Source
 		}
 		totalDLQ += dlqCount
 		p.metrics.RecordDLQRows(task.TableName, task.SourceDB, task.TargetDB, int64(dlqCount))
-		if err := p.updateResumeState(task, lastResumeValue); err != nil {
+		if err := saveCheckpoint(lastResumeValue); err != nil {
 			return 0, 0, err
 		}
 	}
 
 	if progress != nil && totalRows > 0 {
-		progress.SetCurrent(int64(processedRows))
-		if processedRows < totalRows {
-			log.Printf("Warning: processed %d rows but expected %d for table %s", processedRows, totalRows, task.TableName)
+		progress.SetCurrent(int64(scannedRows))
+		if scannedRows < totalRows && !stopped {
+			log.Printf("Warning: read %d rows but expected %d for table %s", scannedRows, totalRows, task.TableName)
 		}
 		progress.SetCurrent(int64(totalRows))
 	}
+	if filteredRows > 0 {
+		log.Printf("Plugin filtered %d of %d source rows for table %s", filteredRows, scannedRows, task.TableName)
+	}
+	if skippedRows > 0 {
+		log.Printf("Plugin skipped %d of %d source rows for table %s (no dlq_path configured)", skippedRows, scannedRows, task.TableName)
+	}
 
-	if err := rows.Err(); err != nil {
+	if err := rows.Err(); err != nil && !stopped {
 		return 0, 0, fmt.Errorf("error during row iteration: %w", err)
 	}
+	if err := loadTx.finish(); err != nil {
+		return 0, 0, err
+	}
 
 	return processedRows, totalDLQ, nil
 }
 
-func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err error) {
+func (p *Processor) processShardedTask(ctx context.Context, task config.TaskConfig, silent bool) (err error) {
 	start := time.Now()
 	defer func() {
 		p.metrics.RecordTaskDuration(task.TableName, task.SourceDB, task.TargetDB, float64(time.Since(start).Milliseconds()))
@@ -970,9 +1131,12 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 		return fmt.Errorf("source_db '%s' is not defined", task.SourceDB)
 	}
 
-	resumeLiteral := task.ResumeFrom
+	resumeLiteral, err := p.resolveResumeLiteral(task)
+	if err != nil {
+		return err
+	}
 
-	baseQuerySQL, baseCountSQL := buildTaskSQL(task.SQL, task.ResumeKey, resumeLiteral)
+	baseQuerySQL, baseCountSQL := buildTaskSQL(sourceDBCfg.Type, task.SQL, task.ResumeKey, resumeLiteral)
 	if task.ResumeKey != "" {
 		if resumeLiteral != "" {
 			log.Printf("Resume enabled for %s: %s > %s", task.TableName, task.ResumeKey, resumeLiteral)
@@ -981,26 +1145,35 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 		}
 	}
 
-	rangeSQL := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM (%s) __range_src", task.ResumeKey, task.ResumeKey, baseCountSQL)
-	rows, err := sourceDB.Query(rangeSQL)
+	// state_file 中留有上次未完成的分片时沿用其区间，不再重新切分
+	tracker := newShardTracker(p, task)
+	resumed, recorded, err := tracker.resume()
 	if err != nil {
-		return fmt.Errorf("failed to execute range query: %w", err)
+		return err
 	}
+
 	var minVal, maxVal any
-	if rows.Next() {
-		err = rows.Scan(&minVal, &maxVal)
-	}
-	rows.Close()
-	if err != nil {
-		return fmt.Errorf("failed to scan range values: %w", err)
-	}
+	if recorded == 0 {
+		rangeSQL := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM (%s) __range_src", task.ResumeKey, task.ResumeKey, baseCountSQL)
+		rows, err := sourceDB.Query(ctx, rangeSQL)
+		if err != nil {
+			return fmt.Errorf("failed to execute range query: %w", err)
+		}
+		if rows.Next() {
+			err = rows.Scan(&minVal, &maxVal)
+		}
+		rows.Close()
+		if err != nil {
+			return fmt.Errorf("failed to scan range values: %w", err)
+		}
 
-	if minVal == nil || maxVal == nil {
-		log.Printf("Table %s is empty, falling back to non-sharded processing", task.TableName)
-		return p.processTaskInternalWithSQL(task, silent, baseQuerySQL, baseCountSQL)
+		if minVal == nil || maxVal == nil {
+			log.Printf("Table %s is empty, falling back to non-sharded processing", task.TableName)
+			return p.processTaskInternalWithSQL(ctx, task, silent, baseQuerySQL, baseCountSQL)
+		}
 	}
 
-	metaRows, err := sourceDB.Query(baseQuerySQL)
+	metaRows, err := sourceDB.Query(ctx, baseQuerySQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute metadata query: %w", err)
 	}
@@ -1010,11 +1183,8 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 		return fmt.Errorf("failed to extract column metadata: %w", err)
 	}
 
-	if task.ResumeKey != "" {
-		resumeIndex := findColumnIndex(columnsMeta, task.ResumeKey)
-		if resumeIndex < 0 {
-			return fmt.Errorf("resume_key '%s' not found in query columns for table %s", task.ResumeKey, task.TableName)
-		}
+	if _, err := resolveResumeIndexes(columnsMeta, task); err != nil {
+		return err
 	}
 
 	mergeKeys, err := resolveMergeKeys(columnsMeta, task.MergeKeys)
@@ -1034,38 +1204,38 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 	if len(task.Assertions) > 0 {
 		assertEngine := assertion.NewEngine(task.Assertions)
 		log.Printf("Running %d pre-migration assertions for table %s", len(task.Assertions), task.TableName)
-		results := assertEngine.RunPreCheck(sourceDB, sourceDBCfg.Type, baseCountSQL)
+		results := assertEngine.RunPreCheck(ctx, sourceDB, sourceDBCfg.SQLDialect(), baseCountSQL)
 		if err := assertEngine.HandleResults(results, columnsMeta, dlqwWriteFn(dlqw)); err != nil {
 			return fmt.Errorf("pre-migration assertion failed for table %s: %w", task.TableName, err)
 		}
 		for _, res := range results {
 			if res.Rule.Config().OnFail == config.AssertionActionDLQ && !res.Passed() {
-				fromClause := assertion.BuildFromClause(sourceDBCfg.Type, baseCountSQL)
-				if dlqErr := assertEngine.FetchViolations(sourceDB, sourceDBCfg.Type, fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
+				fromClause := assertion.BuildFromClause(sourceDBCfg.SQLDialect(), baseCountSQL)
+				if dlqErr := assertEngine.FetchViolations(ctx, sourceDB, sourceDBCfg.SQLDialect(), fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
 					log.Printf("[ASSERTION DLQ] failed to fetch pre-migration violations: %v", dlqErr)
 				}
 			}
 		}
 	}
 
-	if task.SkipCreateTable {
-		log.Printf("Skipping table creation for %s", task.TableName)
-	} else {
-		switch task.Mode {
-		case config.TaskModeAppend, config.TaskModeMerge:
-			if err := targetDB.EnsureTable(task.TableName, columnsMeta); err != nil {
-				return fmt.Errorf("failed to ensure target table: %w", err)
-			}
-		default:
-			if err := targetDB.CreateTable(task.TableName, columnsMeta); err != nil {
-				return fmt.Errorf("failed to prepare target table: %w", err)
-			}
-		}
+	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
+	if !ok {
+		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
 	}
 
+	loadTable, err := prepareTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, columnsMeta)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dropStagingTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, loadTable)
+		}
+	}()
+
 	if len(task.PreSQL) > 0 {
 		log.Printf("Executing %d pre_sql hooks for table %s", len(task.PreSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PreSQL); err != nil {
+		if err := execHookSQLs(ctx, targetDB, task.PreSQL); err != nil {
 			return fmt.Errorf("pre_sql hook failed for table %s: %w", task.TableName, err)
 		}
 		log.Printf("Successfully executed all pre_sql hooks for table %s", task.TableName)
@@ -1073,21 +1243,30 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 
 	targetCountBefore := 0
 	if task.Validate == config.TaskValidateRowCount || task.Validate == config.TaskValidateChecksum || task.Validate == config.TaskValidateSample {
-		count, err := targetDB.GetTableRowCount(task.TableName)
+		count, err := targetDB.GetTableRowCount(loadTable)
 		if err != nil {
 			return fmt.Errorf("failed to get target row count before insert: %w", err)
 		}
 		targetCountBefore = count
 	}
 
-	ranges, err := splitRange(minVal, maxVal, task.Shard.Shards)
-	if err != nil {
-		return fmt.Errorf("failed to split range for table %s: %w", task.TableName, err)
+	cursors := resumed
+	if recorded > 0 {
+		log.Printf("Resuming sharded task %s from %s: %d of %d shards remaining", task.TableName, task.StateFile, len(resumed), recorded)
+	} else {
+		ranges, err := shardRanges(ctx, sourceDB, sourceDBCfg.SQLDialect(), task, baseCountSQL, minVal, maxVal)
+		if err != nil {
+			return fmt.Errorf("failed to split range for table %s: %w", task.TableName, err)
+		}
+		log.Printf("Split %s into %d shards for processing (strategy: %s)", task.TableName, len(ranges), task.Shard.Strategy)
+		for i, r := range ranges {
+			cursors = append(cursors, newShardCursor(r[0], r[1], i == len(ranges)-1))
+		}
+		if err := tracker.begin(cursors); err != nil {
+			return err
+		}
 	}
-	log.Printf("Split %s into %d shards for processing", task.TableName, len(ranges))
 
-	var wg sync.WaitGroup
-	errs := make([]error, len(ranges))
 	var mu sync.Mutex
 	totalProcessed := 0
 	totalDLQ := 0
@@ -1130,72 +1309,47 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 		}
 	}()
 
-	for i, r := range ranges {
-		wg.Add(1)
-		go func(idx int, lower, upper any) {
-			defer wg.Done()
-
-			p.sem <- struct{}{}
-			defer func() { <-p.sem }()
-
-			shardQuerySQL, shardCountSQL := buildShardTaskSQL(task.SQL, task.ResumeKey, resumeLiteral, lower, upper, idx == len(ranges)-1)
-			processed, dlqCount, err := p.migrateData(task, sourceDB, targetDB, columnsMeta, mergeKeys, dlqw, shardQuerySQL, shardCountSQL, silent)
-
-			mu.Lock()
-			if err != nil {
-				errs[idx] = err
-			}
-			totalProcessed += processed
-			totalDLQ += dlqCount
-			mu.Unlock()
-		}(i, r[0], r[1])
-	}
-
-	wg.Wait()
-
-	for _, e := range errs {
-		if e != nil {
-			return e
+	err = p.runShards(ctx, cursors, task.Shard.Rebalance, tracker, func(cursor *shardCursor) error {
+		lower, upper, inclusive, after := cursor.start()
+		shardResume := resumeLiteral
+		if after != nil {
+			shardResume = shardLiteral(after)
 		}
-	}
+		shardQuerySQL, shardCountSQL := buildShardTaskSQL(task.SQL, task.ResumeKey, shardResume, lower, upper, inclusive)
+		processed, dlqCount, err := p.migrateData(ctx, task, loadTable, sourceDB, targetDB, columnsMeta, mergeKeys, dlqw, shardQuerySQL, shardCountSQL, silent, cursor)
 
-	if len(task.Indexes) > 0 {
-		log.Printf("Creating %d indexes for table %s", len(task.Indexes), task.TableName)
-		if err := targetDB.CreateIndexes(task.TableName, task.Indexes); err != nil {
-			return fmt.Errorf("failed to create indexes for table %s: %w", task.TableName, err)
-		}
-		log.Printf("Successfully created all indexes for table %s", task.TableName)
+		mu.Lock()
+		totalProcessed += processed
+		totalDLQ += dlqCount
+		mu.Unlock()
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	if len(task.PostSQL) > 0 {
-		log.Printf("Executing %d post_sql hooks for table %s", len(task.PostSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PostSQL); err != nil {
-			return fmt.Errorf("post_sql hook failed for table %s: %w", task.TableName, err)
+	// 暂存表在切换前建好索引；post_sql 在切换后针对正式表执行
+	staged := loadTable != task.TableName
+	if staged {
+		if err := indexStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+			return err
 		}
-		log.Printf("Successfully executed all post_sql hooks for table %s", task.TableName)
-	}
-
-	sourceDBCfg, ok = p.config.GetDatabase(task.SourceDB)
-	if !ok {
-		return fmt.Errorf("source_db '%s' is not defined", task.SourceDB)
-	}
-	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
-	if !ok {
-		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
+	} else if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, false); err != nil {
+		return err
 	}
 
 	if len(task.Assertions) > 0 {
 		postAssertions := resolvePostAssertions(task.Assertions, task.Columns)
 		assertEngine := assertion.NewEngine(postAssertions)
 		log.Printf("Running %d post-migration assertions for table %s", len(postAssertions), task.TableName)
-		results := assertEngine.RunPostCheck(targetDB, targetDBCfg.Type, task.TableName)
+		results := assertEngine.RunPostCheck(ctx, targetDB, targetDBCfg.SQLDialect(), loadTable)
 		if err := assertEngine.HandleResults(results, columnsMeta, dlqwWriteFn(dlqw)); err != nil {
 			return fmt.Errorf("post-migration assertion failed for table %s: %w", task.TableName, err)
 		}
 		for _, res := range results {
 			if res.Rule.Config().OnFail == config.AssertionActionDLQ && !res.Passed() {
-				fromClause := assertion.BuildTableFromClause(targetDBCfg.Type, task.TableName)
-				if dlqErr := assertEngine.FetchViolations(targetDB, targetDBCfg.Type, fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
+				fromClause := assertion.BuildTableFromClause(targetDBCfg.SQLDialect(), loadTable)
+				if dlqErr := assertEngine.FetchViolations(ctx, targetDB, targetDBCfg.SQLDialect(), fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
 					log.Printf("[ASSERTION DLQ] failed to fetch post-migration violations: %v", dlqErr)
 				}
 			}
@@ -1203,11 +1357,25 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 	}
 
 	validationTask := task
+	validationTask.TableName = loadTable
 	reportedProcessedRows := totalProcessed - totalDLQ
 	if reportedProcessedRows < 0 {
 		reportedProcessedRows = 0
 	}
-	if err := database.ValidateTask(sourceDB, targetDB, sourceDBCfg.Type, targetDBCfg.Type, validationTask, columnsMeta, baseCountSQL, reportedProcessedRows, targetCountBefore, p.metrics); err != nil {
+	if err := database.ValidateTask(ctx, sourceDB, targetDB, sourceDBCfg.SQLDialect(), targetDBCfg.SQLDialect(), validationTask, columnsMeta, baseCountSQL, reportedProcessedRows, targetCountBefore, p.metrics); err != nil {
+		return err
+	}
+
+	if staged {
+		if err := swapStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+			return err
+		}
+		if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, true); err != nil {
+			return err
+		}
+	}
+
+	if err := tracker.complete(); err != nil {
 		return err
 	}
 
@@ -1219,7 +1387,7 @@ func (p *Processor) processShardedTask(task config.TaskConfig, silent bool) (err
 	return nil
 }
 
-func (p *Processor) processTaskInternalWithSQL(task config.TaskConfig, silent bool, querySQL, countSQL string) error {
+func (p *Processor) processTaskInternalWithSQL(ctx context.Context, task config.TaskConfig, silent bool, querySQL, countSQL string) (err error) {
 	start := time.Now()
 	defer func() {
 		p.metrics.RecordTaskDuration(task.TableName, task.SourceDB, task.TargetDB, float64(time.Since(start).Milliseconds()))
@@ -1241,7 +1409,7 @@ func (p *Processor) processTaskInternalWithSQL(task config.TaskConfig, silent bo
 		return fmt.Errorf("source_db '%s' is not defined", task.SourceDB)
 	}
 
-	rows, err := sourceDB.Query(querySQL)
+	rows, err := sourceDB.Query(ctx, querySQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -1252,11 +1420,8 @@ func (p *Processor) processTaskInternalWithSQL(task config.TaskConfig, silent bo
 		return fmt.Errorf("failed to extract column metadata: %w", err)
 	}
 
-	if task.ResumeKey != "" {
-		resumeIndex := findColumnIndex(columnsMeta, task.ResumeKey)
-		if resumeIndex < 0 {
-			return fmt.Errorf("resume_key '%s' not found in query columns for table %s", task.ResumeKey, task.TableName)
-		}
+	if _, err := resolveResumeIndexes(columnsMeta, task); err != nil {
+		return err
 	}
 
 	mergeKeys, err := resolveMergeKeys(columnsMeta, task.MergeKeys)
@@ -1276,38 +1441,38 @@ func (p *Processor) processTaskInternalWithSQL(task config.TaskConfig, silent bo
 	if len(task.Assertions) > 0 {
 		assertEngine := assertion.NewEngine(task.Assertions)
 		log.Printf("Running %d pre-migration assertions for table %s", len(task.Assertions), task.TableName)
-		results := assertEngine.RunPreCheck(sourceDB, sourceDBCfg.Type, countSQL)
+		results := assertEngine.RunPreCheck(ctx, sourceDB, sourceDBCfg.SQLDialect(), countSQL)
 		if err := assertEngine.HandleResults(results, columnsMeta, dlqwWriteFn(dlqw)); err != nil {
 			return fmt.Errorf("pre-migration assertion failed for table %s: %w", task.TableName, err)
 		}
 		for _, res := range results {
 			if res.Rule.Config().OnFail == config.AssertionActionDLQ && !res.Passed() {
-				fromClause := assertion.BuildFromClause(sourceDBCfg.Type, countSQL)
-				if dlqErr := assertEngine.FetchViolations(sourceDB, sourceDBCfg.Type, fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
+				fromClause := assertion.BuildFromClause(sourceDBCfg.SQLDialect(), countSQL)
+				if dlqErr := assertEngine.FetchViolations(ctx, sourceDB, sourceDBCfg.SQLDialect(), fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
 					log.Printf("[ASSERTION DLQ] failed to fetch pre-migration violations: %v", dlqErr)
 				}
 			}
 		}
 	}
 
-	if task.SkipCreateTable {
-		log.Printf("Skipping table creation for %s", task.TableName)
-	} else {
-		switch task.Mode {
-		case config.TaskModeAppend, config.TaskModeMerge:
-			if err := targetDB.EnsureTable(task.TableName, columnsMeta); err != nil {
-				return fmt.Errorf("failed to ensure target table: %w", err)
-			}
-		default:
-			if err := targetDB.CreateTable(task.TableName, columnsMeta); err != nil {
-				return fmt.Errorf("failed to prepare target table: %w", err)
-			}
-		}
+	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
+	if !ok {
+		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
 	}
 
+	loadTable, err := prepareTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, columnsMeta)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			dropStagingTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, loadTable)
+		}
+	}()
+
 	if len(task.PreSQL) > 0 {
 		log.Printf("Executing %d pre_sql hooks for table %s", len(task.PreSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PreSQL); err != nil {
+		if err := execHookSQLs(ctx, targetDB, task.PreSQL); err != nil {
 			return fmt.Errorf("pre_sql hook failed for table %s: %w", task.TableName, err)
 		}
 		log.Printf("Successfully executed all pre_sql hooks for table %s", task.TableName)
@@ -1315,51 +1480,40 @@ func (p *Processor) processTaskInternalWithSQL(task config.TaskConfig, silent bo
 
 	targetCountBefore := 0
 	if task.Validate == config.TaskValidateRowCount || task.Validate == config.TaskValidateChecksum || task.Validate == config.TaskValidateSample {
-		count, err := targetDB.GetTableRowCount(task.TableName)
+		count, err := targetDB.GetTableRowCount(loadTable)
 		if err != nil {
 			return fmt.Errorf("failed to get target row count before insert: %w", err)
 		}
 		targetCountBefore = count
 	}
 
-	processedRows, totalDLQ, err := p.migrateData(task, sourceDB, targetDB, columnsMeta, mergeKeys, dlqw, querySQL, countSQL, silent)
+	processedRows, totalDLQ, err := p.migrateData(ctx, task, loadTable, sourceDB, targetDB, columnsMeta, mergeKeys, dlqw, querySQL, countSQL, silent, nil)
 	if err != nil {
 		return err
 	}
 
-	if len(task.Indexes) > 0 {
-		log.Printf("Creating %d indexes for table %s", len(task.Indexes), task.TableName)
-		if err := targetDB.CreateIndexes(task.TableName, task.Indexes); err != nil {
-			return fmt.Errorf("failed to create indexes for table %s: %w", task.TableName, err)
-		}
-		log.Printf("Successfully created all indexes for table %s", task.TableName)
-	}
-
-	if len(task.PostSQL) > 0 {
-		log.Printf("Executing %d post_sql hooks for table %s", len(task.PostSQL), task.TableName)
-		if err := execHookSQLs(targetDB, task.PostSQL); err != nil {
-			return fmt.Errorf("post_sql hook failed for table %s: %w", task.TableName, err)
+	// 暂存表在切换前建好索引；post_sql 在切换后针对正式表执行
+	staged := loadTable != task.TableName
+	if staged {
+		if err := indexStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+			return err
 		}
-		log.Printf("Successfully executed all post_sql hooks for table %s", task.TableName)
-	}
-
-	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
-	if !ok {
-		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
+	} else if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, false); err != nil {
+		return err
 	}
 
 	if len(task.Assertions) > 0 {
 		postAssertions := resolvePostAssertions(task.Assertions, task.Columns)
 		assertEngine := assertion.NewEngine(postAssertions)
 		log.Printf("Running %d post-migration assertions for table %s", len(postAssertions), task.TableName)
-		results := assertEngine.RunPostCheck(targetDB, targetDBCfg.Type, task.TableName)
+		results := assertEngine.RunPostCheck(ctx, targetDB, targetDBCfg.SQLDialect(), loadTable)
 		if err := assertEngine.HandleResults(results, columnsMeta, dlqwWriteFn(dlqw)); err != nil {
 			return fmt.Errorf("post-migration assertion failed for table %s: %w", task.TableName, err)
 		}
 		for _, res := range results {
 			if res.Rule.Config().OnFail == config.AssertionActionDLQ && !res.Passed() {
-				fromClause := assertion.BuildTableFromClause(targetDBCfg.Type, task.TableName)
-				if dlqErr := assertEngine.FetchViolations(targetDB, targetDBCfg.Type, fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
+				fromClause := assertion.BuildTableFromClause(targetDBCfg.SQLDialect(), loadTable)
+				if dlqErr := assertEngine.FetchViolations(ctx, targetDB, targetDBCfg.SQLDialect(), fromClause, res, columnsMeta, dlqwWriteFn(dlqw)); dlqErr != nil {
 					log.Printf("[ASSERTION DLQ] failed to fetch post-migration violations: %v", dlqErr)
 				}
 			}
@@ -1367,14 +1521,24 @@ func (p *Processor) processTaskInternalWithSQL(task config.TaskConfig, silent bo
 	}
 
 	validationTask := task
+	validationTask.TableName = loadTable
 	reportedProcessedRows := processedRows - totalDLQ
 	if reportedProcessedRows < 0 {
 		reportedProcessedRows = 0
 	}
-	if err := database.ValidateTask(sourceDB, targetDB, sourceDBCfg.Type, targetDBCfg.Type, validationTask, columnsMeta, countSQL, reportedProcessedRows, targetCountBefore, p.metrics); err != nil {
+	if err := database.ValidateTask(ctx, sourceDB, targetDB, sourceDBCfg.SQLDialect(), targetDBCfg.SQLDialect(), validationTask, columnsMeta, countSQL, reportedProcessedRows, targetCountBefore, p.metrics); err != nil {
 		return err
 	}
 
+	if staged {
+		if err := swapStagingTable(targetDB, targetDBCfg.SQLDialect(), task, loadTable); err != nil {
+			return err
+		}
+		if err := finalizeTargetTable(ctx, targetDB, targetDBCfg.SQLDialect(), task, true); err != nil {
+			return err
+		}
+	}
+
 	if task.DLQPath != "" {
 		log.Printf("Processed %d rows, %d rows written to DLQ for table %s", processedRows, totalDLQ, task.TableName)
 	} else {
@@ -1457,6 +1621,13 @@ func splitRange(minVal, maxVal any, shards int) ([][2]any, error) {
 			ranges = append(ranges, [2]any{lower, upper})
 		}
 		return ranges, nil
+	case string, []byte:
+		minS, minOK := shardKeyString(minV)
+		maxS, maxOK := shardKeyString(maxVal)
+		if !minOK || !maxOK {
+			return nil, fmt.Errorf("unsupported resume_key values for range sharding: %T and %T; use shard.strategy %q or %q", minVal, maxVal, config.ShardStrategyQuantile, config.ShardStrategyNTile)
+		}
+		return splitStringRange(minS, maxS, shards), nil
 	default:
 		return nil, fmt.Errorf("unsupported resume_key type for sharding: %T", minVal)
 	}
@@ -1489,20 +1660,20 @@ func (p *Processor) resolveResumeLiteral(task config.TaskConfig) (string, error)
 	if task.ResumeKey == "" {
 		return "", nil
 	}
-	if task.StateFile == "" {
-		return task.ResumeFrom, nil
+	literal := task.ResumeFrom
+	if task.StateFile != "" {
+		state, err := p.loadStateFile(task.StateFile)
+		if err != nil {
+			return "", err
+		}
+		if saved, ok := state.Tasks[p.taskKey(task)]; ok && saved != "" {
+			literal = saved
+		}
 	}
-
-	state, err := p.loadStateFile(task.StateFile)
-	if err != nil {
+	if err := checkResumeLiteral(task, literal); err != nil {
 		return "", err
 	}
-
-	if literal, ok := state.Tasks[p.taskKey(task)]; ok && literal != "" {
-		return literal, nil
-	}
-
-	return task.ResumeFrom, nil
+	return literal, nil
 }
 
 func (p *Processor) runCDCRound(ctx context.Context, cdcTasks []config.TaskConfig) error {
@@ -1514,7 +1685,7 @@ func (p *Processor) runCDCRound(ctx context.Context, cdcTasks []config.TaskConfi
 		}
 
 		log.Printf("[cdc] Polling task: %s", task.TableName)
-		if err := p.processTaskInternal(task, true); err != nil {
+		if err := p.processTaskInternal(ctx, task, true); err != nil {
 			return fmt.Errorf("cdc task %s failed: %w", task.TableName, err)
 		}
 		log.Printf("[cdc] Task %s polled successfully", task.TableName)
@@ -1578,6 +1749,13 @@ func (p *Processor) updateResumeState(task config.TaskConfig, value any) error {
 	if value == nil {
 		return fmt.Errorf("resume_key '%s' value is nil for table %s", task.ResumeKey, task.TableName)
 	}
+	if tuple, ok := value.(resumeTuple); ok {
+		for i, v := range tuple {
+			if v == nil {
+				return fmt.Errorf("resume_key '%s' value is nil for table %s", task.ResumeKeys()[i], task.TableName)
+			}
+		}
+	}
 
 	literal, err := formatResumeLiteral(value)
 	if err != nil {
@@ -1596,23 +1774,33 @@ func (p *Processor) updateResumeState(task config.TaskConfig, value any) error {
 	return nil
 }
 
-func (p *Processor) insertBatchWithRetry(targetDB database.TargetDB, task config.TaskConfig, columns []database.ColumnMetadata, batch [][]any, mergeKeys []string, dlqw *dlqWriter) (int, error) {
+// tableName is the table rows are written to; it differs from task.TableName while
+// replace mode loads into a staging table.
+func (p *Processor) insertBatchWithRetry(ctx context.Context, targetDB database.TargetDB, task config.TaskConfig, tableName string, columns []database.ColumnMetadata, batch [][]any, mergeKeys []string, dlqw *dlqWriter) (int, error) {
 	var lastErr error
 	attempts := task.MaxRetries + 1
 	for attempt := 1; attempt <= attempts; attempt++ {
 		switch task.Mode {
 		case config.TaskModeMerge:
-			lastErr = targetDB.UpsertData(task.TableName, columns, batch, mergeKeys)
+			lastErr = targetDB.UpsertData(ctx, tableName, columns, batch, mergeKeys)
 		default:
-			lastErr = targetDB.InsertData(task.TableName, columns, batch)
+			lastErr = insertRows(ctx, targetDB, task, tableName, columns, batch)
 		}
 		if lastErr == nil {
 			return 0, nil
 		}
+		// 任务被取消或超时：不再重试，也不逐行回退到 DLQ
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf("batch write interrupted: %w (last error: %v)", ctx.Err(), lastErr)
+		}
+		// 装载事务无法只回滚本批次时，失败已使整个事务失效
+		if ltx := database.LoadTxFromContext(ctx); ltx != nil && !ltx.Savepoints() {
+			return 0, fmt.Errorf("batch failed inside a load transaction without savepoint support: %w", lastErr)
+		}
 		if attempt < attempts {
 			wait := time.Duration(attempt) * time.Second
 			log.Printf("Insert batch failed (attempt %d/%d): %v; retrying in %s", attempt, attempts, lastErr, wait)
-			sleepFn(wait)
+			sleepFn(ctx, wait)
 		}
 	}
 
@@ -1627,9 +1815,9 @@ func (p *Processor) insertBatchWithRetry(targetDB database.TargetDB, task config
 		var rowErr error
 		switch task.Mode {
 		case config.TaskModeMerge:
-			rowErr = targetDB.UpsertData(task.TableName, columns, [][]any{row}, mergeKeys)
+			rowErr = targetDB.UpsertData(ctx, tableName, columns, [][]any{row}, mergeKeys)
 		default:
-			rowErr = targetDB.InsertData(task.TableName, columns, [][]any{row})
+			rowErr = targetDB.InsertData(ctx, tableName, columns, [][]any{row})
 		}
 		if rowErr != nil {
 			if err := dlqw.write(row, rowErr.Error(), taskKey, task.TableName); err != nil {
@@ -1644,16 +1832,29 @@ func (p *Processor) insertBatchWithRetry(targetDB database.TargetDB, task config
 	return dlqCount, nil
 }
 
-func execHookSQLs(targetDB database.TargetDB, sqls []string) error {
+// insertRows 写入一个批次；load_method = "bulk" 时优先走目标库的原生批量装载，不可用时回退到 INSERT。
+func insertRows(ctx context.Context, targetDB database.TargetDB, task config.TaskConfig, tableName string, columns []database.ColumnMetadata, rows [][]any) error {
+	if task.LoadMethod == config.LoadMethodBulk {
+		if loader, ok := targetDB.(database.BulkLoader); ok {
+			err := loader.BulkInsertData(ctx, tableName, columns, rows)
+			if !errors.Is(err, database.ErrBulkLoadUnsupported) {
+				return err
+			}
+		}
+	}
+	return targetDB.InsertData(ctx, tableName, columns, rows)
+}
+
+func execHookSQLs(ctx context.Context, targetDB database.TargetDB, sqls []string) error {
 	for _, sqlText := range sqls {
-		if err := targetDB.Exec(sqlText); err != nil {
+		if err := targetDB.Exec(ctx, sqlText); err != nil {
 			return fmt.Errorf("failed to execute hook sql %q: %w", sqlText, err)
 		}
 	}
 	return nil
 }
 
-func buildTaskSQL(baseSQL, resumeKey, resumeLiteral string) (string, string) {
+func buildTaskSQL(dbType, baseSQL, resumeKey, resumeLiteral string) (string, string) {
 	normalized := trimSQL(baseSQL)
 
 	// Support {{.LastValue}} template in user SQL.
@@ -1679,7 +1880,7 @@ func buildTaskSQL(baseSQL, resumeKey, resumeLiteral string) (string, string) {
 
 	wrapped := fmt.Sprintf("SELECT * FROM (%s) src", normalized)
 	if resumeLiteral != "" {
-		wrapped = fmt.Sprintf("%s WHERE %s > %s", wrapped, resumeKey, resumeLiteral)
+		wrapped = fmt.Sprintf("%s WHERE %s", wrapped, buildResumeCondition(dbType, resumeKey, resumeLiteral))
 	}
 
 	dataSQL := fmt.Sprintf("%s ORDER BY %s", wrapped, resumeKey)
@@ -1736,6 +1937,18 @@ func formatResumeLiteral(value any) (string, error) {
 		return quoteSQLString(string(v)), nil
 	case string:
 		return quoteSQLString(v), nil
+	case sqlLiteral:
+		return string(v), nil
+	case resumeTuple:
+		literals := make([]string, len(v))
+		for i, item := range v {
+			literal, err := formatResumeLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			literals[i] = literal
+		}
+		return strings.Join(literals, ", "), nil
 	default:
 		return quoteSQLString(fmt.Sprint(value)), nil
 	}
@@ -1926,10 +2139,21 @@ func isTextualColumn(column database.ColumnMetadata) bool {
 }
 
 func (p *Processor) Close() error {
-	return p.manager.CloseAll()
+	// 状态锁可能保存在目标库中，先于关闭连接释放
+	stateErr := p.releaseStateLocks()
+	if err := p.manager.CloseAll(); err != nil {
+		return err
+	}
+	return stateErr
 }
 
 func (p *Processor) PlanAllTasks(w io.Writer) error {
+	return p.PlanAllTasksContext(context.Background(), w)
+}
+
+// PlanAllTasksContext writes the dry-run plan of every task; cancelling ctx
+// interrupts the source queries the plan runs.
+func (p *Processor) PlanAllTasksContext(ctx context.Context, w io.Writer) error {
 	totalTasks := 0
 	for _, task := range p.config.Tasks {
 		if !task.Ignore {
@@ -1943,7 +2167,7 @@ func (p *Processor) PlanAllTasks(w io.Writer) error {
 			continue
 		}
 		taskIndex++
-		if err := p.planTask(w, taskIndex, totalTasks, i+1, task); err != nil {
+		if err := p.planTask(ctx, w, taskIndex, totalTasks, i+1, task); err != nil {
 			return fmt.Errorf("failed to plan task %s: %w", task.TableName, err)
 		}
 	}
@@ -1951,7 +2175,7 @@ func (p *Processor) PlanAllTasks(w io.Writer) error {
 	return nil
 }
 
-func (p *Processor) planTask(w io.Writer, taskIndex, totalTasks, overallIndex int, task config.TaskConfig) error {
+func (p *Processor) planTask(ctx context.Context, w io.Writer, taskIndex, totalTasks, overallIndex int, task config.TaskConfig) error {
 	targetDBCfg, ok := p.config.GetDatabase(task.TargetDB)
 	if !ok {
 		return fmt.Errorf("target_db '%s' is not defined", task.TargetDB)
@@ -1971,9 +2195,10 @@ func (p *Processor) planTask(w io.Writer, taskIndex, totalTasks, overallIndex in
 		return err
 	}
 
-	querySQL, countSQL := buildTaskSQL(task.SQL, task.ResumeKey, resumeLiteral)
+	sourceDBCfg, _ := p.config.GetDatabase(task.SourceDB)
+	querySQL, countSQL := buildTaskSQL(sourceDBCfg.Type, task.SQL, task.ResumeKey, resumeLiteral)
 
-	rows, err := sourceDB.Query(querySQL)
+	rows, err := sourceDB.Query(ctx, querySQL)
 	if err != nil {
 		return fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -1984,11 +2209,8 @@ func (p *Processor) planTask(w io.Writer, taskIndex, totalTasks, overallIndex in
 		return fmt.Errorf("failed to extract column metadata: %w", err)
 	}
 
-	if task.ResumeKey != "" {
-		resumeIndex := findColumnIndex(sourceColumnsMeta, task.ResumeKey)
-		if resumeIndex < 0 {
-			return fmt.Errorf("resume_key '%s' not found in query columns for table %s", task.ResumeKey, task.TableName)
-		}
+	if _, err := resolveResumeIndexes(sourceColumnsMeta, task); err != nil {
+		return err
 	}
 
 	columnsMeta, _, err := applyColumnMapping(sourceColumnsMeta, task.Columns)
@@ -2001,7 +2223,7 @@ func (p *Processor) planTask(w io.Writer, taskIndex, totalTasks, overallIndex in
 	}
 
 	var rowCount int
-	if count, err := sourceDB.GetRowCount(countSQL); err != nil {
+	if count, err := sourceDB.GetRowCount(ctx, countSQL); err != nil {
 		rowCount = -1
 	} else {
 		rowCount = count
@@ -2012,7 +2234,7 @@ func (p *Processor) planTask(w io.Writer, taskIndex, totalTasks, overallIndex in
 		batchSize = 1000
 	}
 
-	ddlStmts, err := database.GeneratePlanDDL(targetDBCfg.Type, task.TableName, columnsMeta, task.Mode, task.SkipCreateTable, task.Indexes)
+	ddlStmts, err := database.GeneratePlanDDL(targetDBCfg.SQLDialect(), task.TableName, columnsMeta, task.Mode, task.SkipCreateTable, task.Indexes)
 	if err != nil {
 		return fmt.Errorf("failed to generate DDL: %w", err)
 	}
@@ -2034,7 +2256,24 @@ func (p *Processor) planTask(w io.Writer, taskIndex, totalTasks, overallIndex in
 		fmt.Fprintln(w, "  Rows:    (unknown)")
 	}
 	if task.Shard.Enabled {
-		fmt.Fprintf(w, "  Shards:  %d\n", task.Shard.Shards)
+		strategy := task.Shard.Strategy
+		if strategy == "" {
+			strategy = config.ShardStrategyRange
+		}
+		if task.Shard.Rebalance {
+			strategy += ", rebalance"
+		}
+		fmt.Fprintf(w, "  Shards:  %d (%s)\n", task.Shard.Shards, strategy)
+		if task.StateFile != "" {
+			state, err := p.peekStateFile(task.StateFile)
+			if err != nil {
+				return err
+			}
+			p.stateMu.Lock()
+			shards := copyShardStatus(state.Shards[p.taskKey(task)])
+			p.stateMu.Unlock()
+			writeShardStatus(w, task.ResumeKey, shards)
+		}
 	}
 	if task.AdaptiveBatch.Enabled {
 		adaptive := newAdaptiveBatchController(task.AdaptiveBatch, batchSize)
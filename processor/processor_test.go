@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -32,7 +34,7 @@ func (m *retryTarget) CreateTable(string, []database.ColumnMetadata) error { ret
 
 func (m *retryTarget) EnsureTable(string, []database.ColumnMetadata) error { return nil }
 
-func (m *retryTarget) InsertData(string, []database.ColumnMetadata, [][]any) error {
+func (m *retryTarget) InsertData(context.Context, string, []database.ColumnMetadata, [][]any) error {
 	if m.insertCall < len(m.insertErrs) {
 		err := m.insertErrs[m.insertCall]
 		m.insertCall++
@@ -42,7 +44,7 @@ func (m *retryTarget) InsertData(string, []database.ColumnMetadata, [][]any) err
 	return nil
 }
 
-func (m *retryTarget) UpsertData(string, []database.ColumnMetadata, [][]any, []string) error {
+func (m *retryTarget) UpsertData(context.Context, string, []database.ColumnMetadata, [][]any, []string) error {
 	if m.upsertCall < len(m.upsertErrs) {
 		err := m.upsertErrs[m.upsertCall]
 		m.upsertCall++
@@ -54,11 +56,13 @@ func (m *retryTarget) UpsertData(string, []database.ColumnMetadata, [][]any, []s
 
 func (m *retryTarget) GetTableRowCount(string) (int, error) { return 0, nil }
 
-func (m *retryTarget) Query(string) (*sql.Rows, error) { return nil, nil }
+func (m *retryTarget) Query(context.Context, string) (*sql.Rows, error) { return nil, nil }
 
 func (m *retryTarget) CreateIndexes(string, []config.IndexConfig) error { return nil }
 
-func (m *retryTarget) Exec(string) error { return nil }
+func (m *retryTarget) Exec(context.Context, string, ...any) error { return nil }
+
+func (m *retryTarget) SwapTable(string, string, []config.IndexConfig) error { return nil }
 
 func (m *retryTarget) GetTableColumns(string) ([]database.ColumnMetadata, error) { return nil, nil }
 
@@ -100,7 +104,7 @@ func TestProcessorHelpers(t *testing.T) {
 		t.Fatalf("trimSQL() = %q, want %q", got, "SELECT 1")
 	}
 
-	dataSQL, countSQL := buildTaskSQL("SELECT * FROM t;", "id", "10")
+	dataSQL, countSQL := buildTaskSQL(config.DatabaseTypeSQLite, "SELECT * FROM t;", "id", "10")
 	if !strings.Contains(dataSQL, "WHERE id > 10") || !strings.Contains(dataSQL, "ORDER BY id") {
 		t.Fatalf("unexpected data SQL: %s", dataSQL)
 	}
@@ -303,7 +307,7 @@ func TestExtractColumnMetadataAndScanRow(t *testing.T) {
 func TestInsertBatchWithRetry(t *testing.T) {
 	origSleep := sleepFn
 	sleepCalls := 0
-	sleepFn = func(time.Duration) { sleepCalls++ }
+	sleepFn = func(context.Context, time.Duration) { sleepCalls++ }
 	t.Cleanup(func() { sleepFn = origSleep })
 
 	p := &Processor{}
@@ -312,8 +316,10 @@ func TestInsertBatchWithRetry(t *testing.T) {
 	}
 
 	dlqCount, err := p.insertBatchWithRetry(
+		context.Background(),
 		target,
 		config.TaskConfig{Mode: config.TaskModeReplace, MaxRetries: 2, TableName: "t"},
+		"t",
 		[]database.ColumnMetadata{{Name: "id"}},
 		[][]any{{1}},
 		nil,
@@ -336,8 +342,10 @@ func TestInsertBatchWithRetry(t *testing.T) {
 		upsertErrs: []error{errors.New("fail"), errors.New("fail")},
 	}
 	dlqCount, err = p.insertBatchWithRetry(
+		context.Background(),
 		mergeTarget,
 		config.TaskConfig{Mode: config.TaskModeMerge, MaxRetries: 1, TableName: "t"},
+		"t",
 		[]database.ColumnMetadata{{Name: "id"}},
 		[][]any{{1}},
 		[]string{"id"},
@@ -367,14 +375,15 @@ func (m *selectiveTarget) EnsureTable(string, []database.ColumnMetadata) error
 func (m *selectiveTarget) GetTableColumns(string) ([]database.ColumnMetadata, error) { return nil, nil }
 func (m *selectiveTarget) GetTableRowCount(string) (int, error)                      { return 0, nil }
 func (m *selectiveTarget) CreateIndexes(string, []config.IndexConfig) error          { return nil }
-func (m *selectiveTarget) Query(string) (*sql.Rows, error)                           { return nil, nil }
+func (m *selectiveTarget) Query(context.Context, string) (*sql.Rows, error)          { return nil, nil }
+func (m *selectiveTarget) SwapTable(string, string, []config.IndexConfig) error      { return nil }
 
-func (m *selectiveTarget) InsertData(string, []database.ColumnMetadata, [][]any) error {
+func (m *selectiveTarget) InsertData(context.Context, string, []database.ColumnMetadata, [][]any) error {
 	m.insertCall++
 	return nil
 }
 
-func (m *selectiveTarget) UpsertData(string, []database.ColumnMetadata, [][]any, []string) error {
+func (m *selectiveTarget) UpsertData(context.Context, string, []database.ColumnMetadata, [][]any, []string) error {
 	m.upsertCall++
 	return nil
 }
@@ -383,7 +392,7 @@ type failingBatchTarget struct {
 	selectiveTarget
 }
 
-func (m *failingBatchTarget) InsertData(_ string, _ []database.ColumnMetadata, values [][]any) error {
+func (m *failingBatchTarget) InsertData(_ context.Context, _ string, _ []database.ColumnMetadata, values [][]any) error {
 	m.insertCall++
 	if len(values) > 1 || m.failBatch {
 		m.failBatch = false
@@ -397,11 +406,61 @@ func (m *failingBatchTarget) InsertData(_ string, _ []database.ColumnMetadata, v
 	return nil
 }
 
-func (m *failingBatchTarget) Exec(string) error { return nil }
+func (m *failingBatchTarget) Exec(context.Context, string, ...any) error { return nil }
+
+// bulkTarget 记录批次走的是原生批量装载还是 INSERT 回退
+type bulkTarget struct {
+	retryTarget
+	bulkErr   error
+	bulkCalls int
+}
+
+func (m *bulkTarget) BulkInsertData(context.Context, string, []database.ColumnMetadata, [][]any) error {
+	m.bulkCalls++
+	return m.bulkErr
+}
+
+func TestInsertRowsBulkLoad(t *testing.T) {
+	cols := []database.ColumnMetadata{{Name: "id"}}
+	rows := [][]any{{1}, {2}}
+	bulkTask := config.TaskConfig{TableName: "t", LoadMethod: config.LoadMethodBulk}
+
+	target := &bulkTarget{}
+	if err := insertRows(context.Background(), target, bulkTask, "t", cols, rows); err != nil {
+		t.Fatalf("insertRows() error = %v", err)
+	}
+	if target.bulkCalls != 1 || target.insertCall != 0 {
+		t.Fatalf("expected bulk path only, got bulk=%d insert=%d", target.bulkCalls, target.insertCall)
+	}
+
+	target = &bulkTarget{bulkErr: database.ErrBulkLoadUnsupported}
+	if err := insertRows(context.Background(), target, bulkTask, "t", cols, rows); err != nil {
+		t.Fatalf("insertRows() fallback error = %v", err)
+	}
+	if target.bulkCalls != 1 || target.insertCall != 1 {
+		t.Fatalf("expected fallback to INSERT, got bulk=%d insert=%d", target.bulkCalls, target.insertCall)
+	}
+
+	target = &bulkTarget{bulkErr: errors.New("copy failed")}
+	if err := insertRows(context.Background(), target, bulkTask, "t", cols, rows); err == nil || !strings.Contains(err.Error(), "copy failed") {
+		t.Fatalf("expected bulk error to surface, got %v", err)
+	}
+	if target.insertCall != 0 {
+		t.Fatalf("bulk failure must not fall back to INSERT")
+	}
+
+	target = &bulkTarget{}
+	if err := insertRows(context.Background(), target, config.TaskConfig{TableName: "t", LoadMethod: config.LoadMethodInsert}, "t", cols, rows); err != nil {
+		t.Fatalf("insertRows() insert error = %v", err)
+	}
+	if target.bulkCalls != 0 || target.insertCall != 1 {
+		t.Fatalf("expected INSERT path, got bulk=%d insert=%d", target.bulkCalls, target.insertCall)
+	}
+}
 
 func TestInsertBatchWithRetryDLQ(t *testing.T) {
 	origSleep := sleepFn
-	sleepFn = func(time.Duration) {}
+	sleepFn = func(context.Context, time.Duration) {}
 	t.Cleanup(func() { sleepFn = origSleep })
 
 	dir := t.TempDir()
@@ -419,8 +478,10 @@ func TestInsertBatchWithRetryDLQ(t *testing.T) {
 	target.failKeys = map[any]struct{}{2: {}}
 
 	dlqCount, err := p.insertBatchWithRetry(
+		context.Background(),
 		target,
 		config.TaskConfig{Mode: config.TaskModeReplace, MaxRetries: 1, TableName: "t"},
+		"t",
 		[]database.ColumnMetadata{{Name: "id"}, {Name: "name"}},
 		[][]any{{1, "a"}, {2, "b"}, {3, "c"}},
 		nil,
@@ -453,9 +514,87 @@ func TestInsertBatchWithRetryDLQ(t *testing.T) {
 	}
 }
 
+func TestInsertBatchWithRetryCancelled(t *testing.T) {
+	origSleep := sleepFn
+	sleepCalls := 0
+	sleepFn = func(context.Context, time.Duration) { sleepCalls++ }
+	t.Cleanup(func() { sleepFn = origSleep })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &Processor{}
+	target := &retryTarget{insertErrs: []error{context.Canceled, nil}}
+	dlqw, err := newDLQWriter(filepath.Join(t.TempDir(), "dlq.jsonl"), config.DLQFormatJSONL, []database.ColumnMetadata{{Name: "id"}})
+	if err != nil {
+		t.Fatalf("newDLQWriter() error = %v", err)
+	}
+	defer dlqw.close()
+
+	dlqCount, err := p.insertBatchWithRetry(
+		ctx,
+		target,
+		config.TaskConfig{Mode: config.TaskModeReplace, MaxRetries: 3, TableName: "t"},
+		"t",
+		[]database.ColumnMetadata{{Name: "id"}},
+		[][]any{{1}},
+		nil,
+		dlqw,
+	)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if dlqCount != 0 || target.insertCall != 1 || sleepCalls != 0 {
+		t.Fatalf("expected no retry or DLQ fallback, got dlq=%d calls=%d sleeps=%d", dlqCount, target.insertCall, sleepCalls)
+	}
+}
+
+func TestProcessTaskTimeoutInterruptsQuery(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_a (id INTEGER)`)
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{{
+			TableName: "dst_a",
+			// 一个足够慢的递归查询，只有被中断才会结束
+			SQL:      "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) AS c FROM n",
+			SourceDB: "src",
+			TargetDB: "dst",
+			Timeout:  "200ms",
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+
+	start := time.Now()
+	err := p.ProcessAllTasks()
+	if err == nil {
+		t.Fatalf("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("task was not interrupted in time (%s): %v", elapsed, err)
+	}
+
+	// A cancelled context stops ProcessAllTasksContext before any task runs.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.ProcessAllTasksContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
 func TestInsertBatchWithRetryDLQCSV(t *testing.T) {
 	origSleep := sleepFn
-	sleepFn = func(time.Duration) {}
+	sleepFn = func(context.Context, time.Duration) {}
 	t.Cleanup(func() { sleepFn = origSleep })
 
 	dir := t.TempDir()
@@ -473,8 +612,10 @@ func TestInsertBatchWithRetryDLQCSV(t *testing.T) {
 	target.failKeys = map[any]struct{}{"bad": {}}
 
 	dlqCount, err := p.insertBatchWithRetry(
+		context.Background(),
 		target,
 		config.TaskConfig{Mode: config.TaskModeReplace, MaxRetries: 0, TableName: "users"},
+		"users",
 		[]database.ColumnMetadata{{Name: "id"}, {Name: "name"}},
 		[][]any{{"ok", "alice"}, {"bad", "bob"}},
 		nil,
@@ -540,7 +681,7 @@ func TestProcessTaskWithDLQ(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -615,7 +756,7 @@ func TestProcessTaskWithAssertions(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -669,7 +810,7 @@ func TestProcessTaskReplaceAndStateFile(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -700,6 +841,255 @@ func TestProcessTaskReplaceAndStateFile(t *testing.T) {
 	}
 }
 
+func TestProcessTaskTransactionRollsBackUncommittedBatches(t *testing.T) {
+	cases := []struct {
+		name        string
+		commitEvery int
+		wantRows    int
+		wantState   string
+	}{
+		{name: "single transaction", commitEvery: 0, wantRows: 1, wantState: ""},
+		{name: "commit every batch", commitEvery: 1, wantRows: 3, wantState: "2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sourcePath := filepath.Join(dir, "source.db")
+			targetPath := filepath.Join(dir, "target.db")
+			statePath := filepath.Join(dir, "resume.json")
+
+			setupSQLiteSource(t, sourcePath, `CREATE TABLE src_users (id INTEGER PRIMARY KEY)`)
+			setupSQLiteExec(t, sourcePath, `INSERT INTO src_users(id) VALUES (1), (2), (3), (4), (5)`)
+			// 目标端已有 id=4，第二个批次 [3, 4] 会因主键冲突失败
+			setupSQLiteSource(t, targetPath, `CREATE TABLE dst_users (id INTEGER PRIMARY KEY)`)
+			setupSQLiteExec(t, targetPath, `INSERT INTO dst_users(id) VALUES (4)`)
+
+			cfg := &config.Config{
+				Databases: []config.DatabaseConfig{
+					{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+					{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+				},
+				Tasks: []config.TaskConfig{{
+					TableName:       "dst_users",
+					SQL:             "SELECT id FROM src_users",
+					SourceDB:        "src",
+					TargetDB:        "dst",
+					Mode:            config.TaskModeAppend,
+					BatchSize:       2,
+					ResumeKey:       "id",
+					StateFile:       statePath,
+					SkipCreateTable: true,
+					Transaction:     config.TransactionConfig{Enabled: true, CommitEvery: tc.commitEvery},
+				}},
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+			t.Cleanup(func() { _ = p.Close() })
+
+			if err := p.processTask(context.Background(), cfg.Tasks[0]); err == nil {
+				t.Fatalf("expected duplicate key failure")
+			}
+
+			targetDB, err := sql.Open("sqlite3", targetPath)
+			if err != nil {
+				t.Fatalf("open target db error = %v", err)
+			}
+			defer targetDB.Close()
+			var count int
+			if err := targetDB.QueryRow(`SELECT COUNT(*) FROM dst_users`).Scan(&count); err != nil {
+				t.Fatalf("query target count error = %v", err)
+			}
+			if count != tc.wantRows {
+				t.Fatalf("target row count = %d, want %d", count, tc.wantRows)
+			}
+
+			state, err := p.loadStateFile(statePath)
+			if err != nil {
+				t.Fatalf("loadStateFile() error = %v", err)
+			}
+			if got := state.Tasks["src:dst:dst_users"]; got != tc.wantState {
+				t.Fatalf("resume value = %q, want %q", got, tc.wantState)
+			}
+		})
+	}
+}
+
+func TestRunPipelineCommitsInReadOrder(t *testing.T) {
+	next := 0
+	var mu sync.Mutex
+	var written [][]any
+	var committed []int
+	var resumes []any
+	rejected, filtered, skipped := 0, 0, 0
+	st := pipelineStages{
+		read: func() ([]any, error) {
+			if next >= 10 {
+				return nil, nil
+			}
+			next++
+			return []any{int64(next)}, nil
+		},
+		resumeValue: func(row []any) any { return row[0] },
+		batchSize:   func() int { return 2 },
+		newTransform: func() (rowTransform, func(), error) {
+			return func(row []any) (pluginOutcome, error) {
+				// id=3 被跳过，id=5 进入 DLQ，id=7 被过滤，id=8 拆分为两行
+				switch row[0].(int64) {
+				case 3:
+					return pluginOutcome{skipped: true}, nil
+				case 5:
+					return pluginOutcome{dlq: true}, nil
+				case 7:
+					return pluginOutcome{filtered: true}, nil
+				case 8:
+					return pluginOutcome{rows: [][]any{row, {int64(80)}}}, nil
+				}
+				return pluginOutcome{rows: [][]any{row}}, nil
+			}, nil, nil
+		},
+		write: func(ctx context.Context, rows [][]any) (int, error) {
+			// 先读到的批次写得更慢，迫使写入乱序完成
+			time.Sleep(time.Duration(10-rows[0][0].(int64)) * 3 * time.Millisecond)
+			mu.Lock()
+			written = append(written, rows...)
+			mu.Unlock()
+			return 0, nil
+		},
+		commit: func(b *pipelineBatch) error {
+			committed = append(committed, b.seq)
+			resumes = append(resumes, b.resume)
+			rejected += b.rejected
+			filtered += b.filtered
+			skipped += b.skipped
+			return nil
+		},
+	}
+
+	cfg := config.PipelineConfig{Enabled: true, TransformWorkers: 2, Writers: 3, QueueSize: 2}
+	if err := runPipeline(context.Background(), cfg, st); err != nil {
+		t.Fatalf("runPipeline() error = %v", err)
+	}
+	if len(written) != 8 {
+		t.Fatalf("written rows = %d, want 8", len(written))
+	}
+	if rejected != 1 || filtered != 1 || skipped != 1 {
+		t.Fatalf("rejected = %d, filtered = %d, skipped = %d, want 1, 1 and 1", rejected, filtered, skipped)
+	}
+	if !reflect.DeepEqual(committed, []int{0, 1, 2, 3, 4}) {
+		t.Fatalf("commit order = %v", committed)
+	}
+	want := []any{int64(2), int64(4), int64(6), int64(8), int64(10)}
+	if !reflect.DeepEqual(resumes, want) {
+		t.Fatalf("resume values = %v, want %v", resumes, want)
+	}
+}
+
+func TestRunPipelineStopsOnWriteError(t *testing.T) {
+	next := 0
+	var committed []int
+	st := pipelineStages{
+		read: func() ([]any, error) {
+			next++
+			return []any{int64(next)}, nil
+		},
+		resumeValue: func(row []any) any { return row[0] },
+		batchSize:   func() int { return 1 },
+		newTransform: func() (rowTransform, func(), error) {
+			return func(row []any) (pluginOutcome, error) { return pluginOutcome{rows: [][]any{row}}, nil }, nil, nil
+		},
+		write: func(ctx context.Context, rows [][]any) (int, error) {
+			if rows[0][0].(int64) == 3 {
+				return 0, errors.New("boom")
+			}
+			return 0, nil
+		},
+		commit: func(b *pipelineBatch) error {
+			committed = append(committed, b.seq)
+			return nil
+		},
+	}
+
+	cfg := config.PipelineConfig{Enabled: true, TransformWorkers: 1, Writers: 1, QueueSize: 1}
+	err := runPipeline(context.Background(), cfg, st)
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("expected write error, got %v", err)
+	}
+	for _, seq := range committed {
+		if seq >= 2 {
+			t.Fatalf("batch %d committed after a failed batch: %v", seq, committed)
+		}
+	}
+}
+
+func TestProcessTaskWithPipeline(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	statePath := filepath.Join(dir, "resume.json")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_users (id INTEGER PRIMARY KEY, name TEXT)`)
+	for i := 1; i <= 25; i++ {
+		setupSQLiteExec(t, sourcePath, fmt.Sprintf(`INSERT INTO src_users(id, name) VALUES (%d, 'user%d')`, i, i))
+	}
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{{
+			TableName: "dst_users",
+			SQL:       "SELECT id, name FROM src_users ORDER BY id",
+			SourceDB:  "src",
+			TargetDB:  "dst",
+			Mode:      config.TaskModeReplace,
+			BatchSize: 4,
+			ResumeKey: "id",
+			StateFile: statePath,
+			Masking:   []config.MaskingConfig{{Column: "name", Rule: config.MaskRuleFixedValue, Value: "***"}},
+			Pipeline:  config.PipelineConfig{Enabled: true, Writers: 1},
+		}},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+
+	targetDB, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer targetDB.Close()
+	var count, unmasked int
+	if err := targetDB.QueryRow(`SELECT COUNT(*) FROM dst_users`).Scan(&count); err != nil {
+		t.Fatalf("query target count error = %v", err)
+	}
+	if count != 25 {
+		t.Fatalf("target row count = %d, want 25", count)
+	}
+	if err := targetDB.QueryRow(`SELECT COUNT(*) FROM dst_users WHERE name LIKE 'user%'`).Scan(&unmasked); err != nil {
+		t.Fatalf("query masked rows error = %v", err)
+	}
+	if unmasked != 0 {
+		t.Fatalf("expected all names masked, %d rows unmasked", unmasked)
+	}
+
+	state, err := p.loadStateFile(statePath)
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	if got := state.Tasks["src:dst:dst_users"]; got != "25" {
+		t.Fatalf("resume value = %q, want %q", got, "25")
+	}
+}
+
 func TestProcessTaskWithAdaptiveBatch(t *testing.T) {
 	dir := t.TempDir()
 	sourcePath := filepath.Join(dir, "source.db")
@@ -739,7 +1129,7 @@ func TestProcessTaskWithAdaptiveBatch(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -796,7 +1186,7 @@ func TestProcessTaskMergeRowCountValidationFails(t *testing.T) {
 	t.Cleanup(func() { _ = p.Close() })
 
 	// Merge processed 2 rows but only 1 net new row was inserted.
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), "row count validation failed") {
 		t.Fatalf("expected row count validation failure, got %v", err)
 	}
@@ -854,7 +1244,7 @@ func TestProcessTaskAppendWithValidationAndIndexes(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() append error = %v", err)
 	}
 
@@ -913,7 +1303,7 @@ func TestProcessTaskChecksumValidation(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() checksum error = %v", err)
 	}
 
@@ -965,7 +1355,7 @@ func TestProcessTaskSampleValidation(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() sample error = %v", err)
 	}
 
@@ -1016,7 +1406,7 @@ func TestProcessTaskMissingResumeColumnFailsAtQuery(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), "no such column: id") {
 		t.Fatalf("expected query error for missing resume column, got %v", err)
 	}
@@ -1137,7 +1527,7 @@ func TestProcessTaskWithColumnMappingAndTransform(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -1377,6 +1767,43 @@ func TestPlanAllTasksAppendMode(t *testing.T) {
 	}
 }
 
+func TestPlanAllTasksContextCancelled(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_users (id INTEGER PRIMARY KEY)`)
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypePostgreSQL, Host: "localhost", User: "u", Password: "p", Database: "db"},
+		},
+		Tasks: []config.TaskConfig{
+			{
+				TableName:       "dst_users",
+				SQL:             "SELECT id FROM src_users",
+				SourceDB:        "src",
+				TargetDB:        "dst",
+				Mode:            config.TaskModeAppend,
+				SkipCreateTable: true,
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	if err := p.PlanAllTasksContext(ctx, &buf); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled plan to stop its source query, got %v", err)
+	}
+}
+
 func TestPlanAllTasksResumeKey(t *testing.T) {
 	dir := t.TempDir()
 	sourcePath := filepath.Join(dir, "source.db")
@@ -1520,7 +1947,7 @@ func TestProcessTaskPreSQLAndPostSQL(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -1595,7 +2022,7 @@ func TestProcessTaskPreSQLFailure(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), "pre_sql hook failed") {
 		t.Fatalf("expected pre_sql hook failure, got %v", err)
 	}
@@ -1607,11 +2034,11 @@ func TestProcessTaskPreSQLFailure(t *testing.T) {
 	defer targetDB.Close()
 
 	var count int
-	if err := targetDB.QueryRow(`SELECT COUNT(*) FROM "dst_users"`).Scan(&count); err != nil {
-		t.Fatalf("query dst_users count error = %v", err)
+	if err := targetDB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('dst_users', 'dst_users__dbf_staging')`).Scan(&count); err != nil {
+		t.Fatalf("query sqlite_master error = %v", err)
 	}
 	if count != 0 {
-		t.Fatalf("dst_users should be empty after pre_sql failure, got %d", count)
+		t.Fatalf("replace mode should leave no live or staging table after pre_sql failure, got %d", count)
 	}
 }
 
@@ -1647,7 +2074,7 @@ func TestProcessTaskPostSQLFailure(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), "post_sql hook failed") {
 		t.Fatalf("expected post_sql hook failure, got %v", err)
 	}
@@ -1967,7 +2394,7 @@ func TestProcessTaskWithMasking(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -1996,6 +2423,152 @@ func TestProcessTaskWithMasking(t *testing.T) {
 		t.Fatalf("expected balance in [0,100], got %f", balance)
 	}
 }
+func TestProcessTaskFromCSVSource(t *testing.T) {
+	dir := t.TempDir()
+	targetPath := filepath.Join(dir, "target.db")
+	dlqPath := filepath.Join(dir, "dlq.jsonl")
+	for name, content := range map[string]string{
+		"users-1.csv": "id,phone,email\n1,13800138000,alice@example.com\n2,13900139000,not-an-email\n",
+		"users-2.csv": "id,phone,email\n3,13700137000,carol@example.com\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write csv: %v", err)
+		}
+	}
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "drop", Type: config.DatabaseTypeCSV, Path: filepath.Join(dir, "users-*.csv")},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{
+			{
+				TableName: "dst_users",
+				SQL:       "SELECT id, phone, email FROM drop WHERE id <> 3 ORDER BY id",
+				SourceDB:  "drop",
+				TargetDB:  "dst",
+				Mode:      config.TaskModeReplace,
+				Validate:  config.TaskValidateRowCount,
+				DLQPath:   dlqPath,
+				Masking: []config.MaskingConfig{
+					{Column: "phone", Rule: config.MaskRulePhoneCN},
+				},
+				Assertions: []config.AssertionConfig{
+					{Column: "email", Rule: config.AssertionRuleRegex, Pattern: ".*@.*", OnFail: config.AssertionActionDLQ},
+				},
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	manager := database.NewConnectionManager(cfg)
+	p := NewProcessor(manager, cfg)
+	t.Cleanup(func() { _ = p.Close() })
+
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+
+	targetDB, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer targetDB.Close()
+
+	var count int
+	if err := targetDB.QueryRow(`SELECT COUNT(*) FROM "dst_users"`).Scan(&count); err != nil {
+		t.Fatalf("query target count error = %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("target row count = %d, want 2", count)
+	}
+	var phone string
+	if err := targetDB.QueryRow(`SELECT phone FROM "dst_users" WHERE id = 1`).Scan(&phone); err != nil {
+		t.Fatalf("query target row error = %v", err)
+	}
+	if phone != "138****8000" {
+		t.Fatalf("expected masked phone, got %q", phone)
+	}
+
+	dlq, err := os.ReadFile(dlqPath)
+	if err != nil {
+		t.Fatalf("read dlq: %v", err)
+	}
+	if !strings.Contains(string(dlq), "not-an-email") {
+		t.Fatalf("expected assertion violation in DLQ, got %s", dlq)
+	}
+}
+
+func TestProcessTaskToParquetTarget(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	exportDir := filepath.Join(dir, "export")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_users (id INTEGER, phone TEXT, region TEXT)`)
+	setupSQLiteExec(t, sourcePath, `INSERT INTO src_users(id, phone, region) VALUES (1, '13800138000', 'eu'), (2, '13900139000', 'us'), (3, '13700137000', 'eu')`)
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "export", Type: config.DatabaseTypeParquet, Path: exportDir, PartitionBy: []string{"region"}},
+		},
+		Tasks: []config.TaskConfig{
+			{
+				TableName: "users",
+				SQL:       "SELECT id, phone, region FROM src_users ORDER BY id",
+				SourceDB:  "src",
+				TargetDB:  "export",
+				Mode:      config.TaskModeReplace,
+				BatchSize: 2,
+				Validate:  config.TaskValidateRowCount,
+				Masking: []config.MaskingConfig{
+					{Column: "phone", Rule: config.MaskRulePhoneCN},
+				},
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	manager := database.NewConnectionManager(cfg)
+	p := NewProcessor(manager, cfg)
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(exportDir, "users", database.FileSchemaName)); err != nil {
+		t.Fatalf("expected schema file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, database.StagingTableName("users"))); !os.IsNotExist(err) {
+		t.Fatalf("expected staging directory to be swapped away, stat err = %v", err)
+	}
+
+	readBack := database.NewFileSource(config.DatabaseConfig{Name: "users", Type: config.DatabaseTypeParquet, Path: filepath.Join(exportDir, "users", "*", "*.parquet")})
+	defer readBack.Close()
+	rows, err := readBack.Query(context.Background(), "SELECT phone FROM users ORDER BY phone")
+	if err != nil {
+		t.Fatalf("read back export: %v", err)
+	}
+	defer rows.Close()
+	var phones []string
+	for rows.Next() {
+		var phone string
+		if err := rows.Scan(&phone); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		phones = append(phones, phone)
+	}
+	if strings.Join(phones, ",") != "137****7000,138****8000,139****9000" {
+		t.Fatalf("unexpected exported phones %v", phones)
+	}
+}
+
 func TestSplitRange(t *testing.T) {
 	t.Run("int64 even split", func(t *testing.T) {
 		ranges, err := splitRange(int64(0), int64(99), 4)
@@ -2057,8 +2630,50 @@ func TestSplitRange(t *testing.T) {
 		}
 	})
 
-	t.Run("string rejected", func(t *testing.T) {
-		_, err := splitRange("a", "z", 4)
+	t.Run("string range", func(t *testing.T) {
+		ranges, err := splitRange("order-a", "order-z", 4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(ranges) != 4 {
+			t.Fatalf("expected 4 ranges, got %d: %v", len(ranges), ranges)
+		}
+		if ranges[0][0] != "order-a" || ranges[3][1] != "order-z" {
+			t.Fatalf("unexpected outer bounds: %v", ranges)
+		}
+		for i := 1; i < len(ranges); i++ {
+			cut := ranges[i][0].(string)
+			if ranges[i-1][1] != cut || !strings.HasPrefix(cut, "order-") || cut <= ranges[i-1][0].(string) {
+				t.Fatalf("unexpected cut %d: %v", i, ranges)
+			}
+		}
+	})
+
+	t.Run("uuid range", func(t *testing.T) {
+		ranges, err := splitRange([]byte("00000000-0000-4000-8000-000000000000"), []byte("ffffffff-ffff-4fff-bfff-ffffffffffff"), 4)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"40000000-0000-03ff-0000-000000000000", "7fffffff-ffff-c7ff-0000-000000000000", "bfffffff-ffff-8bff-0000-000000000000"}
+		if len(ranges) != 4 {
+			t.Fatalf("expected 4 ranges, got %v", ranges)
+		}
+		for i, cut := range want {
+			if ranges[i+1][0] != cut {
+				t.Fatalf("cut %d: expected %s, got %v", i, cut, ranges[i+1][0])
+			}
+		}
+	})
+
+	t.Run("binary rejected", func(t *testing.T) {
+		_, err := splitRange([]byte{0xff, 0x00}, []byte{0xff, 0x10}, 4)
+		if err == nil || !strings.Contains(err.Error(), "use shard.strategy") {
+			t.Fatalf("expected binary key error, got %v", err)
+		}
+	})
+
+	t.Run("unsupported type rejected", func(t *testing.T) {
+		_, err := splitRange(true, false, 4)
 		if err == nil || !strings.Contains(err.Error(), "unsupported resume_key type") {
 			t.Fatalf("expected unsupported type error, got %v", err)
 		}
@@ -2128,7 +2743,7 @@ func TestProcessShardedTask(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2349,7 +2964,7 @@ func TestProcessTaskWithHistory(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2454,7 +3069,7 @@ func TestProcessTaskWithSkipCreateTable(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2507,7 +3122,7 @@ func TestProcessShardedTaskEmptyTable(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2562,7 +3177,7 @@ func TestProcessTaskWithLuaPlugin(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2629,7 +3244,7 @@ func TestProcessTaskWithJSPlugin(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2697,7 +3312,7 @@ func TestProcessTaskWithPluginDLQ(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2730,6 +3345,112 @@ func TestProcessTaskWithPluginDLQ(t *testing.T) {
 	if !strings.Contains(entry["error"].(string), "plugin transform failed") {
 		t.Fatalf("expected plugin transform failed error, got %v", entry["error"])
 	}
+	if row, ok := entry["row"].([]any); !ok || len(row) != 2 || row[1] != "bob" {
+		t.Fatalf("expected the source row in the DLQ, got %v", entry["row"])
+	}
+}
+
+func TestProcessTaskWithPluginFanOutAndSkip(t *testing.T) {
+	script := `
+		function transform(row)
+			if row.tags == "blocked" then
+				return db_ferry.skip("blocked tag")
+			end
+			if row.tags == "" then
+				return nil
+			end
+			local out = {}
+			for tag in string.gmatch(row.tags, "[^,]+") do
+				out[#out + 1] = {id = row.id, tags = tag}
+			end
+			return out
+		end
+	`
+	for _, pipeline := range []bool{false, true} {
+		t.Run(fmt.Sprintf("pipeline=%v", pipeline), func(t *testing.T) {
+			dir := t.TempDir()
+			sourcePath := filepath.Join(dir, "source.db")
+			targetPath := filepath.Join(dir, "target.db")
+			dlqPath := filepath.Join(dir, "dlq", "failed.jsonl")
+
+			setupSQLiteSource(t, sourcePath, `CREATE TABLE src_posts (id INTEGER, tags TEXT)`)
+			setupSQLiteExec(t, sourcePath, `INSERT INTO src_posts(id, tags) VALUES (1, 'go,sql'), (2, ''), (3, 'blocked'), (4, 'lua')`)
+
+			cfg := &config.Config{
+				Databases: []config.DatabaseConfig{
+					{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+					{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+				},
+				Tasks: []config.TaskConfig{{
+					TableName: "post_tags",
+					SQL:       "SELECT id, tags FROM src_posts ORDER BY id",
+					SourceDB:  "src",
+					TargetDB:  "dst",
+					Mode:      config.TaskModeReplace,
+					Validate:  config.TaskValidateRowCount,
+					DLQPath:   dlqPath,
+					Pipeline:  config.PipelineConfig{Enabled: pipeline, Writers: 1},
+					Plugin:    config.PluginConfig{Engine: config.PluginEngineLua, Script: script},
+				}},
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			manager := database.NewConnectionManager(cfg)
+			p := NewProcessor(manager, cfg)
+			t.Cleanup(func() { _ = p.Close() })
+
+			if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+				t.Fatalf("processTask() error = %v", err)
+			}
+
+			targetDB, err := sql.Open("sqlite3", targetPath)
+			if err != nil {
+				t.Fatalf("open target db error = %v", err)
+			}
+			defer targetDB.Close()
+
+			rows, err := targetDB.Query(`SELECT id, tags FROM "post_tags" ORDER BY id, tags`)
+			if err != nil {
+				t.Fatalf("query target error = %v", err)
+			}
+			defer rows.Close()
+			var got []string
+			for rows.Next() {
+				var id int
+				var tag string
+				if err := rows.Scan(&id, &tag); err != nil {
+					t.Fatalf("scan target row error = %v", err)
+				}
+				got = append(got, fmt.Sprintf("%d:%s", id, tag))
+			}
+			if want := []string{"1:go", "1:sql", "4:lua"}; !reflect.DeepEqual(got, want) {
+				t.Fatalf("target rows = %v, want %v", got, want)
+			}
+
+			data, err := os.ReadFile(dlqPath)
+			if err != nil {
+				t.Fatalf("ReadFile(DLQ) error = %v", err)
+			}
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("expected 1 DLQ line, got %d", len(lines))
+			}
+			var entry map[string]any
+			if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+				t.Fatalf("unmarshal DLQ line error = %v", err)
+			}
+			if entry["error"] != "plugin skipped row: blocked tag" {
+				t.Fatalf("unexpected DLQ error: %v", entry["error"])
+			}
+
+			results := p.TaskResults()
+			if len(results) != 1 || results[0].Rows != 3 {
+				t.Fatalf("task results = %+v, want 3 rows", results)
+			}
+		})
+	}
 }
 
 func TestProcessTaskWithPluginErrorNoDLQ(t *testing.T) {
@@ -2767,7 +3488,7 @@ func TestProcessTaskWithPluginErrorNoDLQ(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), "plugin transform failed") {
 		t.Fatalf("expected plugin transform error, got %v", err)
 	}
@@ -2831,7 +3552,7 @@ func TestProcessFederatedTaskInnerJoin(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2902,7 +3623,7 @@ func TestProcessFederatedTaskLeftJoin(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -2971,7 +3692,7 @@ func TestProcessFederatedTaskRightJoin(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -3044,7 +3765,7 @@ func TestProcessFederatedTaskCompositeJoinKey(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -3105,7 +3826,7 @@ func TestProcessFederatedTaskColumnConflict(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), `column "name" conflicts`) {
 		t.Fatalf("expected column conflict error, got %v", err)
 	}
@@ -3156,7 +3877,7 @@ func TestProcessFederatedTaskMemoryLimit(t *testing.T) {
 	p.SetFederatedMemoryLimit(5)
 	t.Cleanup(func() { _ = p.Close() })
 
-	err := p.processTask(cfg.Tasks[0])
+	err := p.processTask(context.Background(), cfg.Tasks[0])
 	if err == nil || !strings.Contains(err.Error(), "exceeding --federated-memory-limit") {
 		t.Fatalf("expected memory limit error, got %v", err)
 	}
@@ -3251,7 +3972,7 @@ func TestProcessShardedTaskWithResumeFrom(t *testing.T) {
 	p := NewProcessor(manager, cfg)
 	t.Cleanup(func() { _ = p.Close() })
 
-	if err := p.processTask(cfg.Tasks[0]); err != nil {
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
 		t.Fatalf("processTask() error = %v", err)
 	}
 
@@ -3669,7 +4390,7 @@ func TestTaskResultsShardedTask(t *testing.T) {
 
 func TestBuildTaskSQLLastValueTemplate(t *testing.T) {
 	t.Run("replaces template with resume literal", func(t *testing.T) {
-		dataSQL, countSQL := buildTaskSQL("SELECT id, name FROM users WHERE updated_at > {{.LastValue}}", "updated_at", "'2024-01-01'")
+		dataSQL, countSQL := buildTaskSQL(config.DatabaseTypeSQLite, "SELECT id, name FROM users WHERE updated_at > {{.LastValue}}", "updated_at", "'2024-01-01'")
 		wantData := "SELECT id, name FROM users WHERE updated_at > '2024-01-01' ORDER BY updated_at"
 		wantCount := "SELECT * FROM (SELECT id, name FROM users WHERE updated_at > '2024-01-01') __tmpl"
 		if dataSQL != wantData {
@@ -3681,7 +4402,7 @@ func TestBuildTaskSQLLastValueTemplate(t *testing.T) {
 	})
 
 	t.Run("replaces template with 0 when no resume literal", func(t *testing.T) {
-		dataSQL, countSQL := buildTaskSQL("SELECT id FROM logs WHERE ts >= {{.LastValue}}", "ts", "")
+		dataSQL, countSQL := buildTaskSQL(config.DatabaseTypeSQLite, "SELECT id FROM logs WHERE ts >= {{.LastValue}}", "ts", "")
 		wantData := "SELECT id FROM logs WHERE ts >= 0 ORDER BY ts"
 		wantCount := "SELECT * FROM (SELECT id FROM logs WHERE ts >= 0) __tmpl"
 		if dataSQL != wantData {
@@ -3693,7 +4414,7 @@ func TestBuildTaskSQLLastValueTemplate(t *testing.T) {
 	})
 
 	t.Run("does not add ORDER BY when already present", func(t *testing.T) {
-		dataSQL, countSQL := buildTaskSQL("SELECT id FROM events WHERE id > {{.LastValue}} ORDER BY id DESC", "id", "10")
+		dataSQL, countSQL := buildTaskSQL(config.DatabaseTypeSQLite, "SELECT id FROM events WHERE id > {{.LastValue}} ORDER BY id DESC", "id", "10")
 		wantData := "SELECT id FROM events WHERE id > 10 ORDER BY id DESC"
 		wantCount := "SELECT * FROM (SELECT id FROM events WHERE id > 10 ORDER BY id DESC) __tmpl"
 		if dataSQL != wantData {
@@ -3705,7 +4426,7 @@ func TestBuildTaskSQLLastValueTemplate(t *testing.T) {
 	})
 
 	t.Run("does not add ORDER BY when resume key empty", func(t *testing.T) {
-		dataSQL, countSQL := buildTaskSQL("SELECT id FROM events WHERE id > {{.LastValue}}", "", "10")
+		dataSQL, countSQL := buildTaskSQL(config.DatabaseTypeSQLite, "SELECT id FROM events WHERE id > {{.LastValue}}", "", "10")
 		wantData := "SELECT id FROM events WHERE id > 10"
 		wantCount := "SELECT * FROM (SELECT id FROM events WHERE id > 10) __tmpl"
 		if dataSQL != wantData {
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// resumeTuple 是复合 resume_key 一行的键值，格式化为逗号分隔的字面量列表。
+type resumeTuple []any
+
+// resolveResumeIndexes 返回 resume_key 各列在查询列中的位置；未配置 resume_key 时返回 nil。
+func resolveResumeIndexes(columns []database.ColumnMetadata, task config.TaskConfig) ([]int, error) {
+	keys := task.ResumeKeys()
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		idx := findColumnIndex(columns, key)
+		if idx < 0 {
+			return nil, fmt.Errorf("resume_key '%s' not found in query columns for table %s", key, task.TableName)
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, nil
+}
+
+// resumeValueOf 返回行的 resume_key 值：单列时为列值，复合键时为 resumeTuple。
+func resumeValueOf(row []any, indexes []int) any {
+	switch len(indexes) {
+	case 0:
+		return nil
+	case 1:
+		return row[indexes[0]]
+	}
+	tuple := make(resumeTuple, len(indexes))
+	for i, idx := range indexes {
+		tuple[i] = row[idx]
+	}
+	return tuple
+}
+
+// splitResumeLiteral 按顶层逗号拆分复合断点字面量，忽略单引号字符串内的逗号。
+func splitResumeLiteral(literal string) []string {
+	var parts []string
+	var b strings.Builder
+	inQuote := false
+	for i := 0; i < len(literal); i++ {
+		ch := literal[i]
+		switch {
+		case ch == '\'':
+			inQuote = !inQuote
+			b.WriteByte(ch)
+		case ch == ',' && !inQuote:
+			parts = append(parts, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return append(parts, strings.TrimSpace(b.String()))
+}
+
+// checkResumeLiteral 校验断点字面量的值个数与 resume_key 列数一致。
+func checkResumeLiteral(task config.TaskConfig, literal string) error {
+	keys := task.ResumeKeys()
+	if literal == "" || len(keys) < 2 {
+		return nil
+	}
+	if n := len(splitResumeLiteral(literal)); n != len(keys) {
+		return fmt.Errorf("resume value %q for table %s has %d values but resume_key has %d columns", literal, task.TableName, n, len(keys))
+	}
+	return nil
+}
+
+// supportsRowValueComparison 报告数据库能否直接比较行值 (a, b) > (x, y)。
+func supportsRowValueComparison(dbType string) bool {
+	switch dbType {
+	case config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLite,
+		config.DatabaseTypeDuckDB, config.DatabaseTypeClickHouse:
+		return true
+	default:
+		return false
+	}
+}
+
+// buildResumeCondition 生成 resume_key 大于断点的条件。复合键在支持行值比较的数据库上
+// 使用 (a, b) > (x, y)，其余数据库展开为等价的 a > x OR (a = x AND b > y)。
+func buildResumeCondition(dbType, resumeKey, literal string) string {
+	keys := strings.Split(resumeKey, ",")
+	for i, key := range keys {
+		keys[i] = strings.TrimSpace(key)
+	}
+	if len(keys) == 1 {
+		return fmt.Sprintf("%s > %s", keys[0], literal)
+	}
+	values := splitResumeLiteral(literal)
+	if supportsRowValueComparison(dbType) {
+		return fmt.Sprintf("(%s) > (%s)", strings.Join(keys, ", "), strings.Join(values, ", "))
+	}
+	terms := make([]string, 0, len(keys))
+	for i := range keys {
+		conds := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conds = append(conds, fmt.Sprintf("%s = %s", keys[j], values[j]))
+		}
+		conds = append(conds, fmt.Sprintf("%s > %s", keys[i], values[i]))
+		if len(conds) == 1 {
+			terms = append(terms, conds[0])
+		} else {
+			terms = append(terms, "("+strings.Join(conds, " AND ")+")")
+		}
+	}
+	return "(" + strings.Join(terms, " OR ") + ")"
+}
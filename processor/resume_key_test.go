@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func TestSplitResumeLiteral(t *testing.T) {
+	got := splitResumeLiteral("'acme, inc', 42, '2024-01-01 00:00:00'")
+	want := []string{"'acme, inc'", "42", "'2024-01-01 00:00:00'"}
+	if len(got) != len(want) {
+		t.Fatalf("splitResumeLiteral() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("part %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if got := splitResumeLiteral("'o''h', 1"); len(got) != 2 || got[0] != "'o''h'" {
+		t.Fatalf("escaped quote split = %v", got)
+	}
+}
+
+func TestBuildTaskSQLCompositeResumeKey(t *testing.T) {
+	t.Run("row value comparison", func(t *testing.T) {
+		dataSQL, countSQL := buildTaskSQL(config.DatabaseTypePostgreSQL, "SELECT * FROM orders", "tenant_id, id", "'acme', 10")
+		if !strings.Contains(dataSQL, "WHERE (tenant_id, id) > ('acme', 10) ORDER BY tenant_id, id") {
+			t.Fatalf("unexpected data SQL: %s", dataSQL)
+		}
+		if strings.Contains(countSQL, "ORDER BY") {
+			t.Fatalf("count SQL should not contain ORDER BY: %s", countSQL)
+		}
+	})
+
+	t.Run("expanded comparison", func(t *testing.T) {
+		for _, dbType := range []string{config.DatabaseTypeSQLServer, config.DatabaseTypeOracle} {
+			dataSQL, _ := buildTaskSQL(dbType, "SELECT * FROM orders", "tenant_id, region, id", "'acme', 'eu', 10")
+			want := "WHERE (tenant_id > 'acme' OR (tenant_id = 'acme' AND region > 'eu') OR (tenant_id = 'acme' AND region = 'eu' AND id > 10)) ORDER BY tenant_id, region, id"
+			if !strings.Contains(dataSQL, want) {
+				t.Fatalf("%s: unexpected data SQL: %s", dbType, dataSQL)
+			}
+		}
+	})
+
+	t.Run("without resume literal", func(t *testing.T) {
+		dataSQL, _ := buildTaskSQL(config.DatabaseTypeMySQL, "SELECT * FROM orders", "tenant_id, id", "")
+		if strings.Contains(dataSQL, "WHERE") || !strings.HasSuffix(dataSQL, "ORDER BY tenant_id, id") {
+			t.Fatalf("unexpected data SQL: %s", dataSQL)
+		}
+	})
+}
+
+func TestFormatResumeLiteralTuple(t *testing.T) {
+	lit, err := formatResumeLiteral(resumeTuple{"o'h", int64(7)})
+	if err != nil || lit != "'o''h', 7" {
+		t.Fatalf("formatResumeLiteral(tuple) = %q, %v", lit, err)
+	}
+}
+
+func TestCheckResumeLiteral(t *testing.T) {
+	task := config.TaskConfig{TableName: "orders", ResumeKey: "tenant_id, id"}
+	if err := checkResumeLiteral(task, "'acme', 1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := checkResumeLiteral(task, "'acme'")
+	if err == nil || !strings.Contains(err.Error(), "has 1 values but resume_key has 2 columns") {
+		t.Fatalf("expected value count error, got %v", err)
+	}
+}
+
+func TestProcessTaskCompositeResumeKey(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	statePath := filepath.Join(dir, "resume.json")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE orders (tenant TEXT, id INTEGER, amount INTEGER)`)
+	setupSQLiteExec(t, sourcePath, `INSERT INTO orders(tenant, id, amount) VALUES ('a', 1, 10), ('a', 2, 20), ('b', 1, 30)`)
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{
+			{
+				TableName: "orders",
+				SQL:       "SELECT tenant, id, amount FROM orders",
+				SourceDB:  "src",
+				TargetDB:  "dst",
+				Mode:      config.TaskModeAppend,
+				BatchSize: 2,
+				ResumeKey: "tenant,id",
+				StateFile: statePath,
+			},
+		},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	manager := database.NewConnectionManager(cfg)
+	p := NewProcessor(manager, cfg)
+	t.Cleanup(func() { _ = p.Close() })
+
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+
+	readState := func() string {
+		data, err := os.ReadFile(statePath)
+		if err != nil {
+			t.Fatalf("ReadFile(state) error = %v", err)
+		}
+		var state stateFile
+		if err := json.Unmarshal(data, &state); err != nil {
+			t.Fatalf("unmarshal state error = %v", err)
+		}
+		return state.Tasks["src:dst:orders"]
+	}
+	if got := readState(); got != "'b', 1" {
+		t.Fatalf("unexpected resume value: %q", got)
+	}
+
+	// 新增行中只有 ('b', 2) 排在断点 ('b', 1) 之后
+	setupSQLiteExec(t, sourcePath, `INSERT INTO orders(tenant, id, amount) VALUES ('a', 3, 40), ('b', 0, 50), ('b', 2, 60)`)
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("second processTask() error = %v", err)
+	}
+
+	targetDB, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer targetDB.Close()
+
+	var count, total int
+	if err := targetDB.QueryRow(`SELECT COUNT(*), SUM(amount) FROM "orders"`).Scan(&count, &total); err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	if count != 4 || total != 120 {
+		t.Fatalf("target rows = %d (sum %d), want 4 (sum 120)", count, total)
+	}
+	if got := readState(); got != "'b', 2" {
+		t.Fatalf("unexpected resume value after second run: %q", got)
+	}
+}
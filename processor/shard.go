@@ -0,0 +1,733 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// shardRanges 按 shard.strategy 计算分片边界。quantile 与 ntile 依据数据分布切分，
+// 数据倾斜时各分片的行数比等宽切分更均匀。
+func shardRanges(ctx context.Context, sourceDB database.SourceDB, dbType string, task config.TaskConfig, countSQL string, minVal, maxVal any) ([][2]any, error) {
+	var cutSQL string
+	switch task.Shard.Strategy {
+	case config.ShardStrategyQuantile:
+		cutSQL = buildShardSampleSQL(dbType, task.ResumeKey, countSQL, task.Shard.SampleSize)
+	case config.ShardStrategyNTile:
+		cutSQL = buildShardNTileSQL(task.ResumeKey, countSQL, task.Shard.Shards)
+	default:
+		return splitRange(minVal, maxVal, task.Shard.Shards)
+	}
+
+	values, err := queryShardKeys(ctx, sourceDB, cutSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s shard boundaries: %w", task.Shard.Strategy, err)
+	}
+
+	var cuts []any
+	if task.Shard.Strategy == config.ShardStrategyQuantile {
+		cuts = quantileCuts(values, task.Shard.Shards)
+	} else if len(values) > 1 {
+		// 每个桶的最小值即为分片下界，第一个桶的下界就是 MIN(resume_key)
+		cuts = values[1:]
+	}
+	return rangesFromCuts(minVal, maxVal, cuts), nil
+}
+
+// buildShardSampleSQL 随机抽取 limit 个 resume_key 值并按键值排序。
+func buildShardSampleSQL(dbType, resumeKey, countSQL string, limit int) string {
+	switch dbType {
+	case config.DatabaseTypeMySQL:
+		return fmt.Sprintf("SELECT %[1]s FROM (SELECT %[1]s FROM (%[2]s) shard_src ORDER BY RAND() LIMIT %[3]d) shard_sample ORDER BY %[1]s", resumeKey, countSQL, limit)
+	case config.DatabaseTypeClickHouse:
+		return fmt.Sprintf("SELECT %[1]s FROM (SELECT %[1]s FROM (%[2]s) shard_src ORDER BY rand() LIMIT %[3]d) shard_sample ORDER BY %[1]s", resumeKey, countSQL, limit)
+	case config.DatabaseTypeSQLServer:
+		return fmt.Sprintf("SELECT %[1]s FROM (SELECT TOP %[3]d %[1]s FROM (%[2]s) shard_src ORDER BY NEWID()) shard_sample ORDER BY %[1]s", resumeKey, countSQL, limit)
+	case config.DatabaseTypeOracle:
+		return fmt.Sprintf("SELECT %[1]s FROM (SELECT %[1]s FROM (%[2]s) shard_src ORDER BY DBMS_RANDOM.VALUE FETCH FIRST %[3]d ROWS ONLY) shard_sample ORDER BY %[1]s", resumeKey, countSQL, limit)
+	default:
+		return fmt.Sprintf("SELECT %[1]s FROM (SELECT %[1]s FROM (%[2]s) shard_src ORDER BY RANDOM() LIMIT %[3]d) shard_sample ORDER BY %[1]s", resumeKey, countSQL, limit)
+	}
+}
+
+// buildShardNTileSQL 由源库用 NTILE 把 resume_key 分成 shards 个等行数的桶，返回每个桶的最小键值。
+func buildShardNTileSQL(resumeKey, countSQL string, shards int) string {
+	return fmt.Sprintf("SELECT MIN(%[1]s) FROM (SELECT %[1]s, NTILE(%[3]d) OVER (ORDER BY %[1]s) AS shard_bucket FROM (%[2]s) shard_src WHERE %[1]s IS NOT NULL) shard_tiles GROUP BY shard_bucket ORDER BY shard_bucket",
+		resumeKey, countSQL, shards)
+}
+
+func queryShardKeys(ctx context.Context, sourceDB database.SourceDB, sqlText string) ([]any, error) {
+	rows, err := sourceDB.Query(ctx, sqlText)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []any
+	for rows.Next() {
+		var v any
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		if b, ok := v.([]byte); ok {
+			v = string(b)
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// quantileCuts 从已排序的样本中取 shards-1 个分位点。
+func quantileCuts(sorted []any, shards int) []any {
+	if len(sorted) == 0 {
+		return nil
+	}
+	cuts := make([]any, 0, shards-1)
+	for i := 1; i < shards; i++ {
+		cuts = append(cuts, sorted[i*len(sorted)/shards])
+	}
+	return cuts
+}
+
+// rangesFromCuts 把有序切分点转换为 [lower, upper) 区间，最后一个区间包含 maxVal。
+// 重复的切分点（高频键值）会被合并，因此返回的分片数可能少于配置值。
+func rangesFromCuts(minVal, maxVal any, cuts []any) [][2]any {
+	bounds := []any{minVal}
+	prev, _ := formatResumeLiteral(minVal)
+	maxLit, _ := formatResumeLiteral(maxVal)
+	for _, cut := range cuts {
+		lit, _ := formatResumeLiteral(cut)
+		if lit == prev || lit == maxLit {
+			continue
+		}
+		if cmp, ok := compareShardKeys(cut, bounds[len(bounds)-1]); ok && cmp <= 0 {
+			continue
+		}
+		bounds = append(bounds, cut)
+		prev = lit
+	}
+	bounds = append(bounds, maxVal)
+
+	ranges := make([][2]any, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		ranges = append(ranges, [2]any{bounds[i], bounds[i+1]})
+	}
+	return ranges
+}
+
+// shardCursor 记录一个分片的区间和已读到的 resume_key；重平衡时收缩其上界，
+// 把尚未读取的后半段交给空闲 worker。
+type shardCursor struct {
+	mu        sync.Mutex
+	lower     any
+	upper     any
+	inclusive bool
+	last      any
+	running   bool
+	// narrowed 为 true 表示上界已被收缩，越过上界的行交给了其他分片
+	narrowed bool
+	// status 非 nil 时分片进度经 tracker 写入 state_file
+	status  *ShardStatus
+	tracker *shardTracker
+}
+
+func newShardCursor(lower, upper any, inclusive bool) *shardCursor {
+	return &shardCursor{lower: lower, upper: upper, inclusive: inclusive}
+}
+
+// start 标记分片开始执行，返回当前区间以及续传时已写入的最后一个键值（无则为 nil）。
+func (c *shardCursor) start() (lower, upper any, inclusive bool, after any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = true
+	return c.lower, c.upper, c.inclusive, c.last
+}
+
+func (c *shardCursor) finish() {
+	c.mu.Lock()
+	c.running = false
+	c.mu.Unlock()
+}
+
+// admit 记录读到的键值；键值越过收缩后的上界时返回 false，调用方应停止读取。
+func (c *shardCursor) admit(key any) bool {
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.narrowed {
+		if cmp, ok := compareShardKeys(key, c.upper); ok && cmp >= 0 {
+			return false
+		}
+	}
+	if key != nil {
+		c.last = key
+	}
+	return true
+}
+
+// remaining 返回尚未读取部分的跨度，无法计算时返回 false。
+func (c *shardCursor) remaining() (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return 0, false
+	}
+	from := c.lower
+	if c.last != nil {
+		from = c.last
+	}
+	return shardKeySpan(from, c.upper)
+}
+
+// split 把未读取部分从中点一分为二：当前分片只读到中点，返回的新分片负责其余部分。
+func (c *shardCursor) split() *shardCursor {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return nil
+	}
+	from := c.lower
+	if c.last != nil {
+		from = c.last
+	}
+	mid, ok := shardKeyMidpoint(from, c.upper)
+	if !ok {
+		return nil
+	}
+	tail := newShardCursor(mid, c.upper, c.inclusive)
+	c.upper = mid
+	c.inclusive = false
+	c.narrowed = true
+	return tail
+}
+
+// runShards 以最多 len(cursors) 个 worker 执行分片，每个 worker 占用一个 semaphore。
+// rebalance 为 true 时，空闲 worker 会从剩余跨度最大的运行中分片切走后半段。
+func (p *Processor) runShards(ctx context.Context, cursors []*shardCursor, rebalance bool, tracker *shardTracker, run func(*shardCursor) error) error {
+	var (
+		mu      sync.Mutex
+		queue   = append([]*shardCursor(nil), cursors...)
+		active  = append([]*shardCursor(nil), cursors...)
+		firstEr error
+		wg      sync.WaitGroup
+	)
+
+	next := func() *shardCursor {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstEr != nil || ctx.Err() != nil {
+			return nil
+		}
+		if len(queue) > 0 {
+			c := queue[0]
+			queue = queue[1:]
+			return c
+		}
+		if !rebalance {
+			return nil
+		}
+		var slowest *shardCursor
+		best := 0.0
+		for _, c := range active {
+			if span, ok := c.remaining(); ok && span > best {
+				slowest, best = c, span
+			}
+		}
+		if slowest == nil {
+			return nil
+		}
+		tail := slowest.split()
+		if tail == nil {
+			return nil
+		}
+		if err := tracker.split(slowest, tail); err != nil {
+			firstEr = err
+			return nil
+		}
+		active = append(active, tail)
+		log.Printf("Rebalancing shard: idle worker takes over range starting at %v", tail.lower)
+		return tail
+	}
+
+	for range cursors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			p.sem <- struct{}{}
+			defer func() { <-p.sem }()
+
+			for c := next(); c != nil; c = next() {
+				err := run(c)
+				c.finish()
+				if err == nil {
+					err = tracker.finish(c)
+				}
+				if err != nil {
+					mu.Lock()
+					if firstEr == nil {
+						firstEr = err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstEr != nil {
+		return firstEr
+	}
+	return ctx.Err()
+}
+
+// shardTracker 把各分片的区间与断点保存到 state_file：重跑时跳过已完成的分片，
+// 未完成的分片从最后写入的键值之后继续；全部完成后分片进度并入任务断点。
+type shardTracker struct {
+	p    *Processor
+	path string
+	key  string
+}
+
+func newShardTracker(p *Processor, task config.TaskConfig) *shardTracker {
+	if task.StateFile == "" {
+		return nil
+	}
+	return &shardTracker{p: p, path: task.StateFile, key: p.taskKey(task)}
+}
+
+// update 在 stateMu 保护下修改状态并写回 state_file。
+func (t *shardTracker) update(fn func(state *stateFile)) error {
+	state, err := t.p.loadStateFile(t.path)
+	if err != nil {
+		return err
+	}
+	t.p.stateMu.Lock()
+	if state.Shards == nil {
+		state.Shards = make(map[string][]*ShardStatus)
+	}
+	fn(state)
+	t.p.stateMu.Unlock()
+	if err := t.p.saveStateFile(t.path, state); err != nil {
+		return fmt.Errorf("failed to save state file %s: %w", t.path, err)
+	}
+	return nil
+}
+
+// resume 返回上次运行未完成的分片；没有记录时返回 nil。
+func (t *shardTracker) resume() ([]*shardCursor, int, error) {
+	if t == nil {
+		return nil, 0, nil
+	}
+	state, err := t.p.loadStateFile(t.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	t.p.stateMu.Lock()
+	defer t.p.stateMu.Unlock()
+	saved := state.Shards[t.key]
+	if len(saved) == 0 {
+		return nil, 0, nil
+	}
+	cursors := make([]*shardCursor, 0, len(saved))
+	for _, status := range saved {
+		if status.Done {
+			continue
+		}
+		c := newShardCursor(parseShardLiteral(status.Lower), parseShardLiteral(status.Upper), status.Inclusive)
+		if status.Last != "" {
+			c.last = parseShardLiteral(status.Last)
+		}
+		c.status, c.tracker = status, t
+		cursors = append(cursors, c)
+	}
+	return cursors, len(saved), nil
+}
+
+// begin 记录新一轮分片的区间。
+func (t *shardTracker) begin(cursors []*shardCursor) error {
+	if t == nil {
+		return nil
+	}
+	statuses := make([]*ShardStatus, 0, len(cursors))
+	for _, c := range cursors {
+		c.status = &ShardStatus{Lower: shardLiteral(c.lower), Upper: shardLiteral(c.upper), Inclusive: c.inclusive}
+		c.tracker = t
+		statuses = append(statuses, c.status)
+	}
+	return t.update(func(state *stateFile) {
+		state.Shards[t.key] = statuses
+	})
+}
+
+// checkpoint 在分片的批次写入后保存断点。
+func (t *shardTracker) checkpoint(c *shardCursor, value any) error {
+	if t == nil || c.status == nil || value == nil {
+		return nil
+	}
+	return t.update(func(*stateFile) {
+		c.status.Last = shardLiteral(value)
+	})
+}
+
+func (t *shardTracker) finish(c *shardCursor) error {
+	if t == nil || c.status == nil {
+		return nil
+	}
+	return t.update(func(*stateFile) {
+		c.status.Done = true
+	})
+}
+
+// split 记录重平衡：原分片上界收缩到 tail 的下界，tail 作为新分片加入。
+func (t *shardTracker) split(c, tail *shardCursor) error {
+	if t == nil || c.status == nil {
+		return nil
+	}
+	tail.status = &ShardStatus{Lower: shardLiteral(tail.lower), Upper: c.status.Upper, Inclusive: c.status.Inclusive}
+	tail.tracker = t
+	return t.update(func(state *stateFile) {
+		c.status.Upper = tail.status.Lower
+		c.status.Inclusive = false
+		state.Shards[t.key] = append(state.Shards[t.key], tail.status)
+	})
+}
+
+// complete 在所有分片完成后清除分片进度，并把任务断点推进到最后一个分片的上界。
+func (t *shardTracker) complete() error {
+	if t == nil {
+		return nil
+	}
+	return t.update(func(state *stateFile) {
+		for _, status := range state.Shards[t.key] {
+			if status.Inclusive {
+				state.Tasks[t.key] = status.Upper
+			}
+		}
+		delete(state.Shards, t.key)
+	})
+}
+
+// writeShardStatus 输出 state_file 中未完成的分片运行记录。
+func writeShardStatus(w io.Writer, resumeKey string, shards []ShardStatus) {
+	if len(shards) == 0 {
+		return
+	}
+	done := 0
+	for _, s := range shards {
+		if s.Done {
+			done++
+		}
+	}
+	fmt.Fprintf(w, "  Resume:  %d/%d shards done\n", done, len(shards))
+	for i, s := range shards {
+		op := "<"
+		if s.Inclusive {
+			op = "<="
+		}
+		status := "pending"
+		switch {
+		case s.Done:
+			status = "done"
+		case s.Last != "":
+			status = "resume after " + s.Last
+		}
+		fmt.Fprintf(w, "    shard %d: %s >= %s AND %s %s %s (%s)\n", i+1, resumeKey, s.Lower, resumeKey, op, s.Upper, status)
+	}
+}
+
+// sqlLiteral 是已格式化的 SQL 字面量，formatResumeLiteral 原样输出。
+type sqlLiteral string
+
+func shardLiteral(value any) string {
+	lit, _ := formatResumeLiteral(value)
+	return lit
+}
+
+// parseShardLiteral 把 state_file 中的数值字面量还原为数值，使续传的分片仍可参与重平衡；
+// 其他字面量原样用于 SQL。
+func parseShardLiteral(lit string) any {
+	if n, err := strconv.ParseInt(lit, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(lit, 64); err == nil {
+		return f
+	}
+	return sqlLiteral(lit)
+}
+
+func shardKeyInt(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		if n <= math.MaxInt64 {
+			return int64(n), true
+		}
+	}
+	return 0, false
+}
+
+func shardKeyFloat(v any) (float64, bool) {
+	if n, ok := shardKeyInt(v); ok {
+		return float64(n), true
+	}
+	switch n := v.(type) {
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func compareShardKeys(a, b any) (int, bool) {
+	if x, ok := shardKeyInt(a); ok {
+		if y, ok := shardKeyInt(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if x, ok := shardKeyFloat(a); ok {
+		if y, ok := shardKeyFloat(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if x, ok := a.(time.Time); ok {
+		if y, ok := b.(time.Time); ok {
+			return x.Compare(y), true
+		}
+	}
+	return 0, false
+}
+
+func shardKeySpan(from, to any) (float64, bool) {
+	if x, ok := shardKeyFloat(from); ok {
+		if y, ok := shardKeyFloat(to); ok {
+			return y - x, true
+		}
+	}
+	if x, ok := from.(time.Time); ok {
+		if y, ok := to.(time.Time); ok {
+			return float64(y.Sub(x)), true
+		}
+	}
+	return 0, false
+}
+
+// shardKeyMidpoint 返回严格位于 (from, to) 之间的中点，区间过窄或类型不支持时返回 false。
+func shardKeyMidpoint(from, to any) (any, bool) {
+	if x, ok := shardKeyInt(from); ok {
+		if y, ok := shardKeyInt(to); ok {
+			if y <= x {
+				return nil, false
+			}
+			mid := x + int64(uint64(y-x)/2)
+			return mid, mid > x && mid < y
+		}
+	}
+	if x, ok := shardKeyFloat(from); ok {
+		if y, ok := shardKeyFloat(to); ok {
+			mid := x + (y-x)/2
+			return mid, mid > x && mid < y
+		}
+	}
+	if x, ok := from.(time.Time); ok {
+		if y, ok := to.(time.Time); ok {
+			// 边界以秒精度写入 SQL，中点截断到秒以免字面量与实际切分点不一致
+			mid := x.Add(y.Sub(x) / 2).Truncate(time.Second)
+			return mid, mid.After(x) && mid.Before(y)
+		}
+	}
+	return nil, false
+}
+
+// shardKeyString 把文本类型的键值转为字符串；二进制值（如 SQL Server uniqueidentifier）返回 false。
+func shardKeyString(v any) (string, bool) {
+	switch s := v.(type) {
+	case string:
+		return s, true
+	case []byte:
+		return string(s), utf8.Valid(s)
+	}
+	return "", false
+}
+
+// shardStringWidth 为字符串切分点在公共前缀之后的长度。
+const shardStringWidth = 8
+
+// splitStringRange 在 [minVal, maxVal] 内为字符串键插值生成分片边界。规范格式的 UUID
+// 按前 16 个十六进制位插值并保持 UUID 格式；其他字符串跳过公共前缀后按 0-9a-z 的 36 进制插值。
+// 切分点只含数字和小写字母且长度固定，在常见排序规则下的先后顺序与字节序一致，因此各区间不会重叠。
+func splitStringRange(minVal, maxVal string, shards int) [][2]any {
+	if minVal >= maxVal {
+		return [][2]any{{minVal, maxVal}}
+	}
+
+	var cuts []any
+	if isCanonicalUUID(minVal) && isCanonicalUUID(maxVal) {
+		cuts = uuidCuts(minVal, maxVal, shards)
+	} else {
+		cuts = base36Cuts(minVal, maxVal, shards)
+	}
+
+	// 丢弃不在 (minVal, maxVal) 之内或未严格递增的切分点
+	filtered := cuts[:0]
+	prev := minVal
+	for _, cut := range cuts {
+		s := cut.(string)
+		if s <= prev || s >= maxVal {
+			continue
+		}
+		filtered = append(filtered, s)
+		prev = s
+	}
+	return rangesFromCuts(minVal, maxVal, filtered)
+}
+
+func isCanonicalUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func uuidCuts(minVal, maxVal string, shards int) []any {
+	prefix := func(s string) *big.Int {
+		v, _ := new(big.Int).SetString(strings.ReplaceAll(s, "-", "")[:16], 16)
+		return v
+	}
+	lo, hi := prefix(minVal), prefix(maxVal)
+	upper := strings.ToUpper(minVal) == minVal && strings.ToUpper(maxVal) == maxVal
+
+	span := new(big.Int).Sub(hi, lo)
+	cuts := make([]any, 0, shards-1)
+	for i := 1; i < shards; i++ {
+		v := new(big.Int).Mul(span, big.NewInt(int64(i)))
+		v.Quo(v, big.NewInt(int64(shards)))
+		v.Add(v, lo)
+		hex := fmt.Sprintf("%016x", v) + "0000000000000000"
+		cut := hex[:8] + "-" + hex[8:12] + "-" + hex[12:16] + "-" + hex[16:20] + "-" + hex[20:]
+		if upper {
+			cut = strings.ToUpper(cut)
+		}
+		cuts = append(cuts, cut)
+	}
+	return cuts
+}
+
+func base36Cuts(minVal, maxVal string, shards int) []any {
+	n := 0
+	for n < len(minVal) && n < len(maxVal) && minVal[n] == maxVal[n] {
+		n++
+	}
+	// 公共前缀不能截断多字节字符
+	for n > 0 && n < len(maxVal) && !utf8.RuneStart(maxVal[n]) {
+		n--
+	}
+	prefix := maxVal[:n]
+	lo, hi := base36Value(minVal[n:]), base36Value(maxVal[n:])
+	if hi <= lo {
+		return nil
+	}
+
+	cuts := make([]any, 0, shards-1)
+	for i := 1; i < shards; i++ {
+		v := lo + (hi-lo)*int64(i)/int64(shards)
+		cuts = append(cuts, prefix+base36String(v))
+	}
+	return cuts
+}
+
+// base36Value 把字符串前 shardStringWidth 个字节映射为 36 进制数；字母不区分大小写，
+// 小于 '0' 的字节记为 0，其他非字母数字的字节记为 35。
+func base36Value(s string) int64 {
+	var v int64
+	for i := 0; i < shardStringWidth; i++ {
+		var d int64
+		if i < len(s) {
+			switch c := s[i]; {
+			case c >= '0' && c <= '9':
+				d = int64(c - '0')
+			case c >= 'a' && c <= 'z':
+				d = int64(c-'a') + 10
+			case c >= 'A' && c <= 'Z':
+				d = int64(c-'A') + 10
+			case c < '0':
+				d = 0
+			default:
+				d = 35
+			}
+		}
+		v = v*36 + d
+	}
+	return v
+}
+
+func base36String(v int64) string {
+	const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+	buf := make([]byte, shardStringWidth)
+	for i := shardStringWidth - 1; i >= 0; i-- {
+		buf[i] = digits[v%36]
+		v /= 36
+	}
+	return string(buf)
+}
@@ -0,0 +1,414 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func TestRangesFromCuts(t *testing.T) {
+	ranges := rangesFromCuts(int64(1), int64(100), []any{int64(1), int64(2), int64(2), int64(3), int64(100)})
+	want := [][2]any{{int64(1), int64(2)}, {int64(2), int64(3)}, {int64(3), int64(100)}}
+	if fmt.Sprint(ranges) != fmt.Sprint(want) {
+		t.Fatalf("rangesFromCuts() = %v, want %v", ranges, want)
+	}
+
+	if got := rangesFromCuts("a", "z", nil); len(got) != 1 || got[0] != [2]any{"a", "z"} {
+		t.Fatalf("rangesFromCuts() without cuts = %v", got)
+	}
+}
+
+func TestQuantileCuts(t *testing.T) {
+	sample := []any{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8)}
+	if got := fmt.Sprint(quantileCuts(sample, 4)); got != "[3 5 7]" {
+		t.Fatalf("quantileCuts() = %s, want [3 5 7]", got)
+	}
+	if got := quantileCuts(nil, 4); got != nil {
+		t.Fatalf("quantileCuts(nil) = %v, want nil", got)
+	}
+}
+
+func TestBuildShardBoundarySQL(t *testing.T) {
+	got := buildShardSampleSQL(config.DatabaseTypeSQLServer, "id", "SELECT * FROM t", 100)
+	want := "SELECT id FROM (SELECT TOP 100 id FROM (SELECT * FROM t) shard_src ORDER BY NEWID()) shard_sample ORDER BY id"
+	if got != want {
+		t.Fatalf("buildShardSampleSQL() = %q, want %q", got, want)
+	}
+
+	got = buildShardNTileSQL("id", "SELECT * FROM t", 4)
+	if !strings.Contains(got, "NTILE(4) OVER (ORDER BY id)") || !strings.HasSuffix(got, "GROUP BY shard_bucket ORDER BY shard_bucket") {
+		t.Fatalf("buildShardNTileSQL() = %q", got)
+	}
+}
+
+func TestShardCursorSplit(t *testing.T) {
+	c := newShardCursor(int64(0), int64(100), true)
+	if c.split() != nil {
+		t.Fatalf("split() of an idle shard should return nil")
+	}
+	c.start()
+	if !c.admit(int64(10)) {
+		t.Fatalf("admit(10) = false before split")
+	}
+
+	tail := c.split()
+	if tail == nil {
+		t.Fatalf("split() returned nil")
+	}
+	lower, upper, inclusive, _ := tail.start()
+	if lower != int64(55) || upper != int64(100) || !inclusive {
+		t.Fatalf("tail = [%v, %v] inclusive=%v, want [55, 100] inclusive", lower, upper, inclusive)
+	}
+	if !c.admit(int64(54)) || c.admit(int64(55)) {
+		t.Fatalf("narrowed shard should admit 54 and reject 55")
+	}
+
+	narrow := newShardCursor(int64(7), int64(8), false)
+	narrow.start()
+	if narrow.split() != nil {
+		t.Fatalf("split() of a range without a midpoint should return nil")
+	}
+}
+
+func TestRunShardsRebalance(t *testing.T) {
+	p := &Processor{sem: make(chan struct{}, 2)}
+	cursors := []*shardCursor{
+		newShardCursor(int64(0), int64(100), false),
+		newShardCursor(int64(100), int64(101), true),
+	}
+
+	var mu sync.Mutex
+	var ran [][2]any
+	slowStarted := make(chan struct{})
+	tailDone := make(chan struct{})
+	var tailOnce sync.Once
+	err := p.runShards(context.Background(), cursors, true, nil, func(c *shardCursor) error {
+		lower, upper, _, _ := c.start()
+		mu.Lock()
+		ran = append(ran, [2]any{lower, upper})
+		mu.Unlock()
+
+		switch lower {
+		case int64(0):
+			c.admit(int64(10))
+			close(slowStarted)
+			<-tailDone
+			if c.admit(int64(60)) {
+				return fmt.Errorf("slow shard admitted a key handed to another worker")
+			}
+		case int64(100):
+			// 等慢分片读到 10 后再空闲，使切分点确定为 (10+100)/2
+			<-slowStarted
+		default:
+			tailOnce.Do(func() { close(tailDone) })
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runShards() error = %v", err)
+	}
+
+	got := fmt.Sprint(ran)
+	if !strings.Contains(got, "[55 100]") {
+		t.Fatalf("expected the slow shard to be split at 55, ran %s", got)
+	}
+}
+
+func TestProcessShardedTaskSkewStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		strategy  string
+		rebalance bool
+	}{
+		{config.ShardStrategyQuantile, false},
+		{config.ShardStrategyNTile, false},
+		{config.ShardStrategyRange, true},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			dir := t.TempDir()
+			sourcePath := filepath.Join(dir, "source.db")
+			targetPath := filepath.Join(dir, "target.db")
+
+			// 绝大部分键值集中在区间开头，等宽切分时第一个分片承担几乎所有行
+			setupSQLiteSource(t, sourcePath, `CREATE TABLE src_events (id INTEGER PRIMARY KEY, name TEXT)`)
+			setupSQLiteExec(t, sourcePath, `WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 200)
+				INSERT INTO src_events(id, name) SELECT n, 'event_' || n FROM seq`)
+			setupSQLiteExec(t, sourcePath, `INSERT INTO src_events(id, name) VALUES (1000000, 'outlier')`)
+
+			cfg := &config.Config{
+				Databases: []config.DatabaseConfig{
+					{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+					{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+				},
+				Tasks: []config.TaskConfig{
+					{
+						TableName:  "dst_events",
+						SQL:        "SELECT id, name FROM src_events",
+						SourceDB:   "src",
+						TargetDB:   "dst",
+						Mode:       config.TaskModeAppend,
+						Validate:   config.TaskValidateRowCount,
+						ResumeKey:  "id",
+						ResumeFrom: "0",
+						BatchSize:  10,
+						Shard:      config.ShardConfig{Enabled: true, Shards: 4, Strategy: tc.strategy, Rebalance: tc.rebalance},
+					},
+				},
+				MaxConcurrentTasks: 4,
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if tc.strategy != config.ShardStrategyRange {
+				manager := database.NewConnectionManager(cfg)
+				t.Cleanup(func() { _ = manager.CloseAll() })
+				sourceDB, err := manager.GetSource("src")
+				if err != nil {
+					t.Fatalf("GetSource() error = %v", err)
+				}
+				ranges, err := shardRanges(context.Background(), sourceDB, config.DatabaseTypeSQLite, cfg.Tasks[0], "SELECT id, name FROM src_events", int64(1), int64(1000000))
+				if err != nil {
+					t.Fatalf("shardRanges() error = %v", err)
+				}
+				if len(ranges) < 3 {
+					t.Fatalf("expected skew-aware ranges, got %v", ranges)
+				}
+				if upper, _ := shardKeyInt(ranges[0][1]); upper > 150 {
+					t.Fatalf("first shard upper bound = %v, want it inside the dense range", ranges[0][1])
+				}
+			}
+
+			manager := database.NewConnectionManager(cfg)
+			p := NewProcessor(manager, cfg)
+			t.Cleanup(func() { _ = p.Close() })
+
+			if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+				t.Fatalf("processTask() error = %v", err)
+			}
+
+			targetDB, err := sql.Open("sqlite3", targetPath)
+			if err != nil {
+				t.Fatalf("open target db error = %v", err)
+			}
+			defer targetDB.Close()
+
+			var count, distinct int
+			if err := targetDB.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT id) FROM "dst_events"`).Scan(&count, &distinct); err != nil {
+				t.Fatalf("query target count error = %v", err)
+			}
+			if count != 201 || distinct != 201 {
+				t.Fatalf("target rows = %d (distinct %d), want 201", count, distinct)
+			}
+		})
+	}
+}
+
+func TestProcessShardedTaskStringKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		insert string
+	}{
+		{"uuid", `INSERT INTO src_events(id, name) SELECT lower(substr(h, 1, 8) || '-' || substr(h, 9, 4) || '-' || substr(h, 13, 4) || '-' || substr(h, 17, 4) || '-' || substr(h, 21)), 'event_' || n
+			FROM (SELECT n, hex(randomblob(16)) AS h FROM seq)`},
+		{"text", `INSERT INTO src_events(id, name) SELECT printf('order-%04d', n), 'event_' || n FROM seq`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sourcePath := filepath.Join(dir, "source.db")
+			targetPath := filepath.Join(dir, "target.db")
+
+			setupSQLiteSource(t, sourcePath, `CREATE TABLE src_events (id TEXT PRIMARY KEY, name TEXT)`)
+			setupSQLiteExec(t, sourcePath, `WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 300) `+tc.insert)
+
+			cfg := &config.Config{
+				Databases: []config.DatabaseConfig{
+					{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+					{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+				},
+				Tasks: []config.TaskConfig{
+					{
+						TableName:  "dst_events",
+						SQL:        "SELECT id, name FROM src_events",
+						SourceDB:   "src",
+						TargetDB:   "dst",
+						Mode:       config.TaskModeAppend,
+						Validate:   config.TaskValidateRowCount,
+						ResumeKey:  "id",
+						ResumeFrom: "''",
+						BatchSize:  25,
+						Shard:      config.ShardConfig{Enabled: true, Shards: 4, Rebalance: true},
+					},
+				},
+				MaxConcurrentTasks: 4,
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			manager := database.NewConnectionManager(cfg)
+			p := NewProcessor(manager, cfg)
+			t.Cleanup(func() { _ = p.Close() })
+
+			if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+				t.Fatalf("processTask() error = %v", err)
+			}
+
+			targetDB, err := sql.Open("sqlite3", targetPath)
+			if err != nil {
+				t.Fatalf("open target db error = %v", err)
+			}
+			defer targetDB.Close()
+
+			var count, distinct int
+			if err := targetDB.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT id) FROM "dst_events"`).Scan(&count, &distinct); err != nil {
+				t.Fatalf("query target count error = %v", err)
+			}
+			if count != 300 || distinct != 300 {
+				t.Fatalf("target rows = %d (distinct %d), want 300", count, distinct)
+			}
+		})
+	}
+}
+
+func TestProcessShardedTaskResumesFromStateFile(t *testing.T) {
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+	statePath := filepath.Join(dir, "state.json")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_events (id INTEGER PRIMARY KEY, name TEXT)`)
+	setupSQLiteExec(t, sourcePath, `WITH RECURSIVE seq(n) AS (SELECT 1 UNION ALL SELECT n + 1 FROM seq WHERE n < 100)
+		INSERT INTO src_events(id, name) SELECT n, 'event_' || n FROM seq`)
+
+	// 上次运行在第二个分片写到 70 时中断
+	state := `{"tasks": {}, "shards": {"src:dst:dst_events": [
+		{"lower": "1", "upper": "50", "done": true},
+		{"lower": "50", "upper": "100", "inclusive": true, "last": "70", "done": false}
+	]}}`
+	if err := os.WriteFile(statePath, []byte(state), 0o644); err != nil {
+		t.Fatalf("write state file: %v", err)
+	}
+
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{
+			{
+				TableName: "dst_events",
+				SQL:       "SELECT id, name FROM src_events",
+				SourceDB:  "src",
+				TargetDB:  "dst",
+				Mode:      config.TaskModeAppend,
+				Validate:  config.TaskValidateRowCount,
+				ResumeKey: "id",
+				StateFile: statePath,
+				BatchSize: 10,
+				Shard:     config.ShardConfig{Enabled: true, Shards: 2},
+			},
+		},
+		MaxConcurrentTasks: 2,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	shards, err := ReadShardStatus(cfg, cfg.Tasks[0])
+	if err != nil || len(shards) != 2 || !shards[0].Done || shards[1].Last != "70" {
+		t.Fatalf("ReadShardStatus() = %+v, %v", shards, err)
+	}
+
+	manager := database.NewConnectionManager(cfg)
+	p := NewProcessor(manager, cfg)
+	t.Cleanup(func() { _ = p.Close() })
+
+	var plan bytes.Buffer
+	if err := p.PlanAllTasks(&plan); err != nil {
+		t.Fatalf("PlanAllTasks() error = %v", err)
+	}
+	if !strings.Contains(plan.String(), "Resume:  1/2 shards done") || !strings.Contains(plan.String(), "id >= 50 AND id <= 100 (resume after 70)") {
+		t.Fatalf("expected shard status in plan output, got:\n%s", plan.String())
+	}
+
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+
+	targetDB, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer targetDB.Close()
+
+	var count, minID int
+	if err := targetDB.QueryRow(`SELECT COUNT(*), MIN(id) FROM "dst_events"`).Scan(&count, &minID); err != nil {
+		t.Fatalf("query target error = %v", err)
+	}
+	if count != 30 || minID != 71 {
+		t.Fatalf("target rows = %d starting at %d, want 30 starting at 71", count, minID)
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	var saved stateFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatalf("parse state file: %v", err)
+	}
+	if len(saved.Shards) != 0 || saved.Tasks["src:dst:dst_events"] != "100" {
+		t.Fatalf("state after completion = %s", data)
+	}
+}
+
+func TestShardTrackerRecordsProgress(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	p := &Processor{stateFiles: make(map[string]*stateFile)}
+	tracker := &shardTracker{p: p, path: statePath, key: "k"}
+
+	cursors := []*shardCursor{newShardCursor(int64(0), int64(100), false), newShardCursor(int64(100), int64(200), true)}
+	if err := tracker.begin(cursors); err != nil {
+		t.Fatalf("begin() error = %v", err)
+	}
+	cursors[1].start()
+	cursors[1].admit(int64(120))
+	if err := tracker.checkpoint(cursors[1], int64(120)); err != nil {
+		t.Fatalf("checkpoint() error = %v", err)
+	}
+	tail := cursors[1].split()
+	if err := tracker.split(cursors[1], tail); err != nil {
+		t.Fatalf("split() error = %v", err)
+	}
+	if err := tracker.finish(cursors[0]); err != nil {
+		t.Fatalf("finish() error = %v", err)
+	}
+
+	// 新的 Processor 从文件读取，模拟重跑
+	rerun := &shardTracker{p: &Processor{stateFiles: make(map[string]*stateFile)}, path: statePath, key: "k"}
+	resumed, recorded, err := rerun.resume()
+	if err != nil {
+		t.Fatalf("resume() error = %v", err)
+	}
+	if recorded != 3 || len(resumed) != 2 {
+		t.Fatalf("resume() = %d cursors of %d recorded, want 2 of 3", len(resumed), recorded)
+	}
+	lower, upper, inclusive, after := resumed[0].start()
+	if lower != int64(100) || upper != int64(160) || inclusive || after != int64(120) {
+		t.Fatalf("narrowed shard = [%v, %v) inclusive=%v after=%v", lower, upper, inclusive, after)
+	}
+	lower, upper, inclusive, after = resumed[1].start()
+	if lower != int64(160) || upper != int64(200) || !inclusive || after != nil {
+		t.Fatalf("tail shard = [%v, %v] inclusive=%v after=%v", lower, upper, inclusive, after)
+	}
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+// prepareTargetTable creates or ensures the target table and returns the name
+// of the table rows should be loaded into. Replace mode loads into a staging
+// table so the live table stays intact until swapStagingTable succeeds.
+func prepareTargetTable(ctx context.Context, targetDB database.TargetDB, targetType string, task config.TaskConfig, columnsMeta []database.ColumnMetadata) (string, error) {
+	if task.SkipCreateTable {
+		log.Printf("Skipping table creation for %s", task.TableName)
+		return task.TableName, nil
+	}
+
+	switch task.Mode {
+	case config.TaskModeAppend, config.TaskModeMerge:
+		ensure := targetDB.EnsureTable
+		if keyed, ok := targetDB.(database.KeyedTableEnsurer); ok && task.Mode == config.TaskModeMerge {
+			ensure = func(tableName string, columns []database.ColumnMetadata) error {
+				return keyed.EnsureKeyedTable(tableName, columns, task.MergeKeys)
+			}
+		}
+		if err := ensure(task.TableName, columnsMeta); err != nil {
+			return "", fmt.Errorf("failed to ensure target table: %w", err)
+		}
+		if task.SchemaEvolution {
+			if err := database.SyncSchema(ctx, targetDB, targetType, task.TableName, columnsMeta); err != nil {
+				return "", fmt.Errorf("failed to sync schema: %w", err)
+			}
+		}
+		return task.TableName, nil
+	default:
+		staging := database.StagingTableName(task.TableName)
+		if err := targetDB.CreateTable(staging, columnsMeta); err != nil {
+			return "", fmt.Errorf("failed to prepare staging table: %w", err)
+		}
+		log.Printf("Loading table %s through staging table %s", task.TableName, staging)
+		return staging, nil
+	}
+}
+
+// indexStagingTable builds the task indexes on the staging table before the
+// swap so the live table is never visible without them. The indexes use their
+// staging names and get their final names in SwapTable.
+func indexStagingTable(targetDB database.TargetDB, targetType string, task config.TaskConfig, loadTable string) error {
+	if loadTable == task.TableName || len(task.Indexes) == 0 || !database.IndexesSurviveSwap(targetType) {
+		return nil
+	}
+	log.Printf("Creating %d indexes on staging table %s", len(task.Indexes), loadTable)
+	if err := targetDB.CreateIndexes(loadTable, database.StagingIndexes(targetType, task.Indexes)); err != nil {
+		return fmt.Errorf("failed to create indexes on staging table %s: %w", loadTable, err)
+	}
+	return nil
+}
+
+// swapStagingTable atomically replaces the live table with the loaded staging table.
+func swapStagingTable(targetDB database.TargetDB, targetType string, task config.TaskConfig, loadTable string) error {
+	if loadTable == task.TableName {
+		return nil
+	}
+	var indexes []config.IndexConfig
+	if database.IndexesSurviveSwap(targetType) {
+		indexes = task.Indexes
+	}
+	if err := targetDB.SwapTable(loadTable, task.TableName, indexes); err != nil {
+		return fmt.Errorf("failed to swap staging table into %s: %w", task.TableName, err)
+	}
+	log.Printf("Swapped staging table %s into %s", loadTable, task.TableName)
+	return nil
+}
+
+// dropStagingTable removes a staging table left behind by a failed run. It
+// also runs when the task was cancelled, so it ignores ctx cancellation.
+func dropStagingTable(ctx context.Context, targetDB database.TargetDB, targetType string, task config.TaskConfig, loadTable string) {
+	if loadTable == "" || loadTable == task.TableName {
+		return
+	}
+	var err error
+	if dropper, ok := targetDB.(database.TableDropper); ok {
+		err = dropper.DropTable(loadTable)
+	} else {
+		err = targetDB.Exec(context.WithoutCancel(ctx), database.BuildDropTableSQL(targetType, loadTable))
+	}
+	if err != nil {
+		log.Printf("Warning: failed to drop staging table %s: %v", loadTable, err)
+	}
+}
+
+// finalizeTargetTable creates indexes and runs post_sql hooks on the live table.
+// After a swap the indexes normally came over from the staging table; post_sql
+// always runs on the live table because hooks refer to it by name.
+func finalizeTargetTable(ctx context.Context, targetDB database.TargetDB, targetType string, task config.TaskConfig, staged bool) error {
+	if len(task.Indexes) > 0 && !(staged && database.IndexesSurviveSwap(targetType)) {
+		log.Printf("Creating %d indexes for table %s", len(task.Indexes), task.TableName)
+		if err := targetDB.CreateIndexes(task.TableName, task.Indexes); err != nil {
+			return fmt.Errorf("failed to create indexes for table %s: %w", task.TableName, err)
+		}
+		log.Printf("Successfully created all indexes for table %s", task.TableName)
+	}
+
+	if len(task.PostSQL) > 0 {
+		log.Printf("Executing %d post_sql hooks for table %s", len(task.PostSQL), task.TableName)
+		if err := execHookSQLs(ctx, targetDB, task.PostSQL); err != nil {
+			return fmt.Errorf("post_sql hook failed for table %s: %w", task.TableName, err)
+		}
+		log.Printf("Successfully executed all post_sql hooks for table %s", task.TableName)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package processor
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func newReplaceProcessor(t *testing.T, task config.TaskConfig) (*Processor, *config.Config, string) {
+	t.Helper()
+	dir := t.TempDir()
+	sourcePath := filepath.Join(dir, "source.db")
+	targetPath := filepath.Join(dir, "target.db")
+
+	setupSQLiteSource(t, sourcePath, `CREATE TABLE src_users (id INTEGER, name TEXT)`)
+	setupSQLiteExec(t, sourcePath, `INSERT INTO src_users(id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c')`)
+	setupSQLiteSource(t, targetPath, `CREATE TABLE dst_users (id INTEGER, name TEXT)`)
+	setupSQLiteExec(t, targetPath, `INSERT INTO dst_users(id, name) VALUES (100, 'old')`)
+
+	task.TableName = "dst_users"
+	task.SQL = "SELECT id, name FROM src_users"
+	task.SourceDB = "src"
+	task.TargetDB = "dst"
+	task.Mode = config.TaskModeReplace
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "src", Type: config.DatabaseTypeSQLite, Path: sourcePath},
+			{Name: "dst", Type: config.DatabaseTypeSQLite, Path: targetPath},
+		},
+		Tasks: []config.TaskConfig{task},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	p := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = p.Close() })
+	return p, cfg, targetPath
+}
+
+func queryTargetTables(t *testing.T, targetPath string) (ids string, tables string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+
+	if err := db.QueryRow(`SELECT group_concat(id) FROM (SELECT id FROM dst_users ORDER BY id)`).Scan(&ids); err != nil {
+		t.Fatalf("query dst_users error = %v", err)
+	}
+	if err := db.QueryRow(`SELECT group_concat(name) FROM (SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name)`).Scan(&tables); err != nil {
+		t.Fatalf("query sqlite_master error = %v", err)
+	}
+	return ids, tables
+}
+
+func TestReplaceModeSwapsStagingTable(t *testing.T) {
+	p, cfg, targetPath := newReplaceProcessor(t, config.TaskConfig{
+		Validate: config.TaskValidateRowCount,
+		Indexes:  []config.IndexConfig{{Name: "idx_dst_users_id", Columns: []string{"id"}}},
+		PostSQL:  []string{`UPDATE dst_users SET name = upper(name)`},
+	})
+	// The live table already carries the index from an earlier run.
+	setupSQLiteExec(t, targetPath, `CREATE INDEX idx_dst_users_id ON dst_users (id)`)
+
+	if err := p.processTask(context.Background(), cfg.Tasks[0]); err != nil {
+		t.Fatalf("processTask() error = %v", err)
+	}
+
+	ids, tables := queryTargetTables(t, targetPath)
+	if ids != "1,2,3" {
+		t.Fatalf("expected live table to hold new rows, got %q", ids)
+	}
+	if tables != "dst_users" {
+		t.Fatalf("expected staging table to be swapped away, got tables %q", tables)
+	}
+
+	db, err := sql.Open("sqlite3", targetPath)
+	if err != nil {
+		t.Fatalf("open target db error = %v", err)
+	}
+	defer db.Close()
+	var name, index string
+	if err := db.QueryRow(`SELECT name FROM dst_users WHERE id = 1`).Scan(&name); err != nil {
+		t.Fatalf("query name error = %v", err)
+	}
+	if name != "A" {
+		t.Fatalf("expected post_sql to run against the live table, got %q", name)
+	}
+	if err := db.QueryRow(`SELECT tbl_name FROM sqlite_master WHERE type = 'index' AND name = 'idx_dst_users_id'`).Scan(&index); err != nil {
+		t.Fatalf("query index error = %v", err)
+	}
+	if index != "dst_users" {
+		t.Fatalf("expected index on live table, got %q", index)
+	}
+	var indexCount int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'index'`).Scan(&indexCount); err != nil {
+		t.Fatalf("count indexes error = %v", err)
+	}
+	if indexCount != 1 {
+		t.Fatalf("expected the staging index to be renamed, found %d indexes", indexCount)
+	}
+}
+
+func TestReplaceModeKeepsLiveTableOnIndexFailure(t *testing.T) {
+	p, cfg, targetPath := newReplaceProcessor(t, config.TaskConfig{
+		// Every row gets the same name, so the unique index cannot be built.
+		Plugin: config.PluginConfig{
+			Engine:    config.PluginEngineLua,
+			Script:    `function transform(row) row.name = "dup" return row end`,
+			TimeoutMs: 1000,
+		},
+		Indexes: []config.IndexConfig{{Name: "idx_dst_users_name", Columns: []string{"name"}, Unique: true}},
+	})
+
+	err := p.processTask(context.Background(), cfg.Tasks[0])
+	if err == nil || !strings.Contains(err.Error(), "failed to create indexes on staging table") {
+		t.Fatalf("expected staging index failure, got %v", err)
+	}
+
+	ids, tables := queryTargetTables(t, targetPath)
+	if ids != "100" {
+		t.Fatalf("expected live table to be untouched, got %q", ids)
+	}
+	if tables != "dst_users" {
+		t.Fatalf("expected staging table to be dropped, got tables %q", tables)
+	}
+}
+
+func TestReplaceModeKeepsLiveTableOnPostAssertionFailure(t *testing.T) {
+	p, cfg, targetPath := newReplaceProcessor(t, config.TaskConfig{
+		// The plugin clears every name so only the post-migration check fails.
+		Plugin: config.PluginConfig{
+			Engine:    config.PluginEngineLua,
+			Script:    `function transform(row) row.name = nil return row end`,
+			TimeoutMs: 1000,
+		},
+		Assertions: []config.AssertionConfig{
+			{Column: "name", Rule: config.AssertionRuleNotNull, OnFail: config.AssertionActionAbort},
+		},
+	})
+
+	err := p.processTask(context.Background(), cfg.Tasks[0])
+	if err == nil || !strings.Contains(err.Error(), "post-migration assertion failed") {
+		t.Fatalf("expected post-migration assertion failure, got %v", err)
+	}
+
+	ids, tables := queryTargetTables(t, targetPath)
+	if ids != "100" {
+		t.Fatalf("expected live table to be untouched, got %q", ids)
+	}
+	if tables != "dst_users" {
+		t.Fatalf("expected staging table to be dropped, got tables %q", tables)
+	}
+}
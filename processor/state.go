@@ -2,17 +2,237 @@ package processor
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"db-ferry/config"
+	"db-ferry/database"
 )
 
 type stateFile struct {
 	Tasks map[string]string `json:"tasks"`
+	// Shards 保存分片任务未完成时各分片的进度，全部完成后并入 Tasks
+	Shards map[string][]*ShardStatus `json:"shards,omitempty"`
+}
+
+// ShardStatus is the progress of one shard of a sharded task as recorded in
+// its state_file. Bounds and the last key are stored as SQL literals.
+type ShardStatus struct {
+	Lower     string `json:"lower"`
+	Upper     string `json:"upper"`
+	Inclusive bool   `json:"inclusive,omitempty"`
+	Last      string `json:"last,omitempty"`
+	Done      bool   `json:"done"`
+}
+
+// ReadShardStatus returns the shard progress recorded in the task's state_file,
+// or nil when the task has no unfinished sharded run. It reads the document
+// from the configured state backend without taking the state lock.
+func ReadShardStatus(cfg *config.Config, task config.TaskConfig) ([]ShardStatus, error) {
+	if !task.Shard.Enabled || task.StateFile == "" {
+		return nil, nil
+	}
+	manager := database.NewConnectionManager(cfg)
+	defer manager.CloseAll()
+
+	store, err := newStateStore(cfg, task.StateFile, manager.GetTarget)
+	if err != nil {
+		return nil, err
+	}
+	state, _, err := readStateDocument(context.Background(), store)
+	if err != nil {
+		return nil, err
+	}
+	return copyShardStatus(state.Shards[taskStateKey(task)]), nil
+}
+
+func copyShardStatus(shards []*ShardStatus) []ShardStatus {
+	if len(shards) == 0 {
+		return nil
+	}
+	out := make([]ShardStatus, 0, len(shards))
+	for _, s := range shards {
+		out = append(out, *s)
+	}
+	return out
+}
+
+// stateStore 是 state_file 文档的存储后端。load 返回文档与版本，文档不存在时版本为空；
+// save 仅在存储中的版本仍为 version 时写入，否则返回 database.ErrStateConflict；
+// lock 获取或续期锁，被其他 owner 持有时返回 database.ErrStateLocked。
+type stateStore interface {
+	describe() string
+	load(ctx context.Context) ([]byte, string, error)
+	save(ctx context.Context, data []byte, version string) (string, error)
+	lock(ctx context.Context, owner string, ttl time.Duration) error
+	unlock(ctx context.Context, owner string) error
+}
+
+// newStateStore 按 state.backend 为 state_file 路径创建存储后端。
+func newStateStore(cfg *config.Config, path string, target func(alias string) (database.TargetDB, error)) (stateStore, error) {
+	if cfg == nil {
+		return newLocalStateStore(path), nil
+	}
+	switch cfg.State.Backend {
+	case config.StateBackendDatabase:
+		dbCfg, ok := cfg.GetDatabase(cfg.State.Database)
+		if !ok {
+			return nil, fmt.Errorf("state.database '%s' is not defined", cfg.State.Database)
+		}
+		targetDB, err := target(cfg.State.Database)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state database %s: %w", cfg.State.Database, err)
+		}
+		return newDBStateStore(targetDB, dbCfg.Type, cfg.State.Database, cfg.State.Table(), path), nil
+	case config.StateBackendS3:
+		return newS3StateStore(cfg.State, path)
+	default:
+		return newLocalStateStore(path), nil
+	}
+}
+
+func readStateDocument(ctx context.Context, store stateStore) (*stateFile, string, error) {
+	data, version, err := store.load(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	state := &stateFile{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, "", fmt.Errorf("failed to parse state file %s: %w", store.describe(), err)
+		}
+	}
+	if state.Tasks == nil {
+		state.Tasks = make(map[string]string)
+	}
+	return state, version, nil
+}
+
+// stateHandle 记录 Processor 已锁定的状态文档：最近一次读写的版本与续期锁的后台协程。
+type stateHandle struct {
+	store   stateStore
+	version string
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+var (
+	stateOwnerOnce sync.Once
+	stateOwner     string
+)
+
+// stateLockOwner 返回本进程的锁 owner，格式为 host:pid:token。同一进程内的
+// Processor 共用 owner，守护进程的每轮运行因此可以直接续用上一轮的锁。
+func stateLockOwner() string {
+	stateOwnerOnce.Do(func() {
+		buf := make([]byte, 8)
+		_, _ = rand.Read(buf)
+		stateOwner = fmt.Sprintf("%s:%d:%s", stateLockHost(), os.Getpid(), hex.EncodeToString(buf))
+	})
+	return stateOwner
+}
+
+func stateLockHost() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "localhost"
+	}
+	// owner 以冒号分隔
+	return strings.ReplaceAll(host, ":", "_")
+}
+
+func (p *Processor) newStateStore(path string) (stateStore, error) {
+	return newStateStore(p.config, path, func(alias string) (database.TargetDB, error) {
+		if p.manager == nil {
+			return nil, fmt.Errorf("no connection manager for state database %s", alias)
+		}
+		return p.manager.GetTarget(alias)
+	})
+}
+
+func (p *Processor) stateLockTTL() time.Duration {
+	if p.config == nil {
+		var s config.StateConfig
+		return s.LockTTLDuration()
+	}
+	return p.config.State.LockTTLDuration()
+}
+
+// openStateHandle 打开 path 对应的存储、获取锁并读取文档，调用方需持有 stateMu。
+func (p *Processor) openStateHandle(path string) (*stateHandle, *stateFile, error) {
+	if p.stateHandles == nil {
+		p.stateHandles = make(map[string]*stateHandle)
+	}
+	if h, ok := p.stateHandles[path]; ok {
+		return h, nil, nil
+	}
+
+	store, err := p.newStateStore(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	ttl := p.stateLockTTL()
+	if err := store.lock(ctx, stateLockOwner(), ttl); err != nil {
+		return nil, nil, fmt.Errorf("failed to lock state %s: %w", store.describe(), err)
+	}
+	state, version, err := readStateDocument(ctx, store)
+	if err != nil {
+		_ = store.unlock(ctx, stateLockOwner())
+		return nil, nil, err
+	}
+
+	h := &stateHandle{store: store, version: version, stop: make(chan struct{}), done: make(chan struct{})}
+	go h.renew(ttl)
+	p.stateHandles[path] = h
+	return h, state, nil
+}
+
+// renew 每隔 ttl/3 续期一次锁，直到 release。
+func (h *stateHandle) renew(ttl time.Duration) {
+	defer close(h.done)
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			if err := h.store.lock(context.Background(), stateLockOwner(), ttl); err != nil {
+				log.Printf("Warning: failed to renew state lock %s: %v", h.store.describe(), err)
+			}
+		}
+	}
+}
+
+func (h *stateHandle) release() error {
+	close(h.stop)
+	<-h.done
+	return h.store.unlock(context.Background(), stateLockOwner())
+}
+
+// releaseStateLocks 释放本 Processor 持有的全部状态锁。
+func (p *Processor) releaseStateLocks() error {
+	p.stateMu.Lock()
+	defer p.stateMu.Unlock()
+
+	var firstErr error
+	for path, h := range p.stateHandles {
+		if err := h.release(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(p.stateHandles, path)
+	}
+	return firstErr
 }
 
 func (p *Processor) loadStateFile(path string) (*stateFile, error) {
@@ -26,29 +246,41 @@ func (p *Processor) loadStateFile(path string) (*stateFile, error) {
 		return state, nil
 	}
 
-	state := &stateFile{Tasks: make(map[string]string)}
-	data, err := os.ReadFile(path)
+	h, state, err := p.openStateHandle(path)
 	if err != nil {
-		if os.IsNotExist(err) {
-			p.stateFiles[path] = state
-			return state, nil
-		}
-		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+		return nil, err
 	}
-
-	if len(bytes.TrimSpace(data)) > 0 {
-		if err := json.Unmarshal(data, state); err != nil {
-			return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	if state == nil {
+		// 锁已由 saveStateFile 获取，重新读取文档
+		var version string
+		state, version, err = readStateDocument(context.Background(), h.store)
+		if err != nil {
+			return nil, err
 		}
-	}
-	if state.Tasks == nil {
-		state.Tasks = make(map[string]string)
+		h.version = version
 	}
 
 	p.stateFiles[path] = state
 	return state, nil
 }
 
+// peekStateFile 返回 path 的文档而不获取锁，供 plan 等只读场景使用。
+func (p *Processor) peekStateFile(path string) (*stateFile, error) {
+	p.stateMu.Lock()
+	if state, ok := p.stateFiles[path]; ok {
+		p.stateMu.Unlock()
+		return state, nil
+	}
+	p.stateMu.Unlock()
+
+	store, err := p.newStateStore(path)
+	if err != nil {
+		return nil, err
+	}
+	state, _, err := readStateDocument(context.Background(), store)
+	return state, err
+}
+
 func (p *Processor) saveStateFile(path string, state *stateFile) error {
 	p.stateMu.Lock()
 	defer p.stateMu.Unlock()
@@ -57,25 +289,27 @@ func (p *Processor) saveStateFile(path string, state *stateFile) error {
 		return nil
 	}
 
-	dir := filepath.Dir(path)
-	if dir != "." && dir != "" {
-		if err := os.MkdirAll(dir, 0o755); err != nil {
-			return fmt.Errorf("failed to create state directory %s: %w", dir, err)
-		}
+	h, _, err := p.openStateHandle(path)
+	if err != nil {
+		return err
 	}
-
 	data, err := json.MarshalIndent(state, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to encode state file %s: %w", path, err)
 	}
-	if err := os.WriteFile(path, data, 0o644); err != nil {
-		return fmt.Errorf("failed to write state file %s: %w", path, err)
+	version, err := h.store.save(context.Background(), data, h.version)
+	if err != nil {
+		return err
 	}
-
+	h.version = version
 	return nil
 }
 
 func (p *Processor) taskKey(task config.TaskConfig) string {
+	return taskStateKey(task)
+}
+
+func taskStateKey(task config.TaskConfig) string {
 	if task.IsFederated() {
 		var dbs []string
 		for _, s := range task.Sources {
@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"db-ferry/database"
+)
+
+// dbStateStore 把状态文档保存在 state.database 的状态表中，以 state_file 作为行键。
+type dbStateStore struct {
+	table    *database.StateTable
+	target   database.TargetDB
+	database string
+	key      string
+	// ensure 保证每个 store 只执行一次建表语句
+	ensure    sync.Once
+	ensureErr error
+}
+
+func newDBStateStore(target database.TargetDB, dbType, dbName, tableName, key string) *dbStateStore {
+	return &dbStateStore{
+		table:    database.NewStateTable(dbType, tableName),
+		target:   target,
+		database: dbName,
+		key:      key,
+	}
+}
+
+func (s *dbStateStore) describe() string {
+	return fmt.Sprintf("%s:%s", s.database, s.key)
+}
+
+func (s *dbStateStore) ensureTable(ctx context.Context) error {
+	s.ensure.Do(func() {
+		s.ensureErr = s.table.EnsureTable(ctx, s.target)
+	})
+	return s.ensureErr
+}
+
+func (s *dbStateStore) load(ctx context.Context) ([]byte, string, error) {
+	if err := s.ensureTable(ctx); err != nil {
+		return nil, "", err
+	}
+	return s.table.Load(ctx, s.target, s.key)
+}
+
+func (s *dbStateStore) save(ctx context.Context, data []byte, version string) (string, error) {
+	if err := s.ensureTable(ctx); err != nil {
+		return "", err
+	}
+	return s.table.Save(ctx, s.target, s.key, data, version)
+}
+
+func (s *dbStateStore) lock(ctx context.Context, owner string, ttl time.Duration) error {
+	if err := s.ensureTable(ctx); err != nil {
+		return err
+	}
+	return s.table.Lock(ctx, s.target, s.key, owner, ttl)
+}
+
+func (s *dbStateStore) unlock(ctx context.Context, owner string) error {
+	return s.table.Unlock(ctx, s.target, s.key, owner)
+}
@@ -0,0 +1,204 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"db-ferry/database"
+)
+
+// localStateStore 把状态文档保存为本地 JSON 文件。版本为文件内容的 SHA-256，
+// 写入先落到临时文件再 rename，进程崩溃不会留下半个文件；锁为同目录下的 <path>.lock。
+type localStateStore struct {
+	path string
+}
+
+func newLocalStateStore(path string) *localStateStore {
+	return &localStateStore{path: path}
+}
+
+func (s *localStateStore) describe() string {
+	return s.path
+}
+
+func (s *localStateStore) load(_ context.Context) ([]byte, string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to read state file %s: %w", s.path, err)
+	}
+	return data, contentVersion(data), nil
+}
+
+func (s *localStateStore) save(ctx context.Context, data []byte, version string) (string, error) {
+	_, current, err := s.load(ctx)
+	if err != nil {
+		return "", err
+	}
+	if current != version {
+		return "", fmt.Errorf("%w: %s", database.ErrStateConflict, s.path)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return "", fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	return contentVersion(data), nil
+}
+
+func (s *localStateStore) lock(_ context.Context, owner string, ttl time.Duration) error {
+	lockPath := s.path + ".lock"
+	dir := filepath.Dir(s.path)
+	if dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create state directory %s: %w", dir, err)
+		}
+	}
+
+	data, err := json.Marshal(stateLock{Owner: owner, Expires: time.Now().Add(ttl).UnixMilli()})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err == nil {
+		_, werr := f.Write(data)
+		if cerr := f.Close(); werr == nil {
+			werr = cerr
+		}
+		if werr != nil {
+			return fmt.Errorf("failed to write state lock %s: %w", lockPath, werr)
+		}
+		return nil
+	}
+	if !os.IsExist(err) {
+		return fmt.Errorf("failed to create state lock %s: %w", lockPath, err)
+	}
+
+	held, err := readLocalStateLock(lockPath)
+	if err != nil {
+		return err
+	}
+	if held.Owner != owner && !held.expired() && !held.orphaned() {
+		return fmt.Errorf("%w: %s is held by %s until %s", database.ErrStateLocked, s.path, held.Owner,
+			time.UnixMilli(held.Expires).UTC().Format(time.RFC3339))
+	}
+	if err := writeFileAtomic(lockPath, data); err != nil {
+		return fmt.Errorf("failed to write state lock %s: %w", lockPath, err)
+	}
+	// 两个进程同时接管过期锁时只有最后一次 rename 生效，回读确认锁归自己所有
+	held, err = readLocalStateLock(lockPath)
+	if err != nil {
+		return err
+	}
+	if held.Owner != owner {
+		return fmt.Errorf("%w: %s is held by %s", database.ErrStateLocked, s.path, held.Owner)
+	}
+	return nil
+}
+
+func (s *localStateStore) unlock(_ context.Context, owner string) error {
+	lockPath := s.path + ".lock"
+	held, err := readLocalStateLock(lockPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if held.Owner != owner {
+		return nil
+	}
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state lock %s: %w", lockPath, err)
+	}
+	return nil
+}
+
+// stateLock 为文件与对象存储锁的内容。
+type stateLock struct {
+	Owner   string `json:"owner"`
+	Expires int64  `json:"expires"`
+}
+
+func (l stateLock) expired() bool {
+	return time.Now().UnixMilli() >= l.Expires
+}
+
+// orphaned 报告锁是否由本机已退出的进程持有，这样崩溃后重跑无需等待锁过期。
+func (l stateLock) orphaned() bool {
+	parts := strings.Split(l.Owner, ":")
+	if len(parts) != 3 || parts[0] != stateLockHost() {
+		return false
+	}
+	pid, err := strconv.Atoi(parts[1])
+	if err != nil || pid == os.Getpid() {
+		return false
+	}
+	return !processAlive(pid)
+}
+
+func readLocalStateLock(lockPath string) (stateLock, error) {
+	var held stateLock
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return held, fmt.Errorf("failed to read state lock %s: %w", lockPath, err)
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(data), &held); err != nil {
+		// 无法解析的锁文件视为已过期
+		return stateLock{}, nil
+	}
+	return held, nil
+}
+
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeFileAtomic 先写同目录临时文件再 rename 覆盖目标文件。
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, 0o644); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
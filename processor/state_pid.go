@@ -0,0 +1,14 @@
+//go:build !windows
+
+package processor
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive 报告本机 pid 对应的进程是否仍在运行。
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
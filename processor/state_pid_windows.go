@@ -0,0 +1,8 @@
+//go:build windows
+
+package processor
+
+// processAlive 在 Windows 上无法廉价探测进程，保守地认为进程仍在运行，锁只能等待过期。
+func processAlive(int) bool {
+	return true
+}
@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+type s3StateAPI interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// s3StateStore 把状态文档保存为 S3 兼容存储中的对象，版本为对象 ETag。
+// 写入使用条件请求（If-Match / If-None-Match），锁为同名的 .lock 对象。
+type s3StateStore struct {
+	client s3StateAPI
+	bucket string
+	key    string
+}
+
+func newS3StateStore(cfg config.StateConfig, statePath string) (*s3StateStore, error) {
+	ctx := context.Background()
+	var opts []func(*awsconfig.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+	return newS3StateStoreWithClient(client, cfg.Bucket, s3StateKey(cfg.Prefix, statePath)), nil
+}
+
+func newS3StateStoreWithClient(client s3StateAPI, bucket, key string) *s3StateStore {
+	return &s3StateStore{client: client, bucket: bucket, key: key}
+}
+
+// s3StateKey 把 state_file 路径映射为 prefix 下的对象键。
+func s3StateKey(prefix, statePath string) string {
+	key := path.Clean(filepath.ToSlash(statePath))
+	key = strings.TrimLeft(strings.TrimPrefix(key, "./"), "/")
+	if prefix != "" {
+		key = path.Join(strings.Trim(prefix, "/"), key)
+	}
+	return key
+}
+
+func (s *s3StateStore) describe() string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.key)
+}
+
+func (s *s3StateStore) load(ctx context.Context) ([]byte, string, error) {
+	return s.get(ctx, s.key)
+}
+
+func (s *s3StateStore) save(ctx context.Context, data []byte, version string) (string, error) {
+	etag, err := s.put(ctx, s.key, data, version)
+	if err != nil {
+		if isS3PreconditionFailed(err) {
+			return "", fmt.Errorf("%w: %s", database.ErrStateConflict, s.describe())
+		}
+		return "", fmt.Errorf("failed to write state %s: %w", s.describe(), err)
+	}
+	return etag, nil
+}
+
+func (s *s3StateStore) lock(ctx context.Context, owner string, ttl time.Duration) error {
+	lockKey := s.key + ".lock"
+	data, etag, err := s.get(ctx, lockKey)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		var held stateLock
+		if json.Unmarshal(data, &held) == nil && held.Owner != owner && !held.expired() {
+			return fmt.Errorf("%w: %s is held by %s until %s", database.ErrStateLocked, s.describe(), held.Owner,
+				time.UnixMilli(held.Expires).UTC().Format(time.RFC3339))
+		}
+	}
+
+	body, err := json.Marshal(stateLock{Owner: owner, Expires: time.Now().Add(ttl).UnixMilli()})
+	if err != nil {
+		return err
+	}
+	if _, err := s.put(ctx, lockKey, body, etag); err != nil {
+		if isS3PreconditionFailed(err) {
+			return fmt.Errorf("%w: %s was locked concurrently", database.ErrStateLocked, s.describe())
+		}
+		return fmt.Errorf("failed to write state lock %s.lock: %w", s.describe(), err)
+	}
+	return nil
+}
+
+func (s *s3StateStore) unlock(ctx context.Context, owner string) error {
+	lockKey := s.key + ".lock"
+	data, etag, err := s.get(ctx, lockKey)
+	if err != nil || etag == "" {
+		return err
+	}
+	var held stateLock
+	if json.Unmarshal(data, &held) == nil && held.Owner != owner {
+		return nil
+	}
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket:  &s.bucket,
+		Key:     &lockKey,
+		IfMatch: aws.String(etag),
+	})
+	if err != nil && !isS3PreconditionFailed(err) {
+		return fmt.Errorf("failed to remove state lock %s.lock: %w", s.describe(), err)
+	}
+	return nil
+}
+
+// get 返回对象内容与 ETag，对象不存在时返回空 ETag。
+func (s *s3StateStore) get(ctx context.Context, key string) ([]byte, string, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{Bucket: &s.bucket, Key: &key})
+	if err != nil {
+		var noKey *types.NoSuchKey
+		var notFound *types.NotFound
+		if errors.As(err, &noKey) || errors.As(err, &notFound) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read s3://%s/%s: %w", s.bucket, key, err)
+	}
+	return data, aws.ToString(out.ETag), nil
+}
+
+// put 在对象 ETag 仍为 etag 时写入；etag 为空表示对象必须尚不存在。
+func (s *s3StateStore) put(ctx context.Context, key string, data []byte, etag string) (string, error) {
+	input := &s3.PutObjectInput{
+		Bucket:      &s.bucket,
+		Key:         &key,
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if etag == "" {
+		input.IfNoneMatch = aws.String("*")
+	} else {
+		input.IfMatch = aws.String(etag)
+	}
+	out, err := s.client.PutObject(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+func isS3PreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "PreconditionFailed", "ConditionalRequestConflict":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"db-ferry/config"
+	"db-ferry/database"
+)
+
+func TestLocalStateStoreCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	store := newLocalStateStore(filepath.Join(t.TempDir(), "state", "resume.json"))
+
+	data, version, err := store.load(ctx)
+	if err != nil || data != nil || version != "" {
+		t.Fatalf("load() on missing file = %q, %q, %v", data, version, err)
+	}
+	v1, err := store.save(ctx, []byte(`{"tasks":{}}`), "")
+	if err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	if _, err := store.save(ctx, []byte(`{"tasks":{"k":"1"}}`), ""); !errors.Is(err, database.ErrStateConflict) {
+		t.Fatalf("save() with stale version error = %v, want ErrStateConflict", err)
+	}
+	v2, err := store.save(ctx, []byte(`{"tasks":{"k":"1"}}`), v1)
+	if err != nil || v2 == v1 {
+		t.Fatalf("save() = %q, %v", v2, err)
+	}
+	data, version, err = store.load(ctx)
+	if err != nil || string(data) != `{"tasks":{"k":"1"}}` || version != v2 {
+		t.Fatalf("load() = %q, %q, %v", data, version, err)
+	}
+}
+
+func TestLocalStateStoreLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "resume.json")
+	store := newLocalStateStore(path)
+
+	if err := store.lock(ctx, "host-a:1:x", time.Minute); err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+	if err := store.lock(ctx, "host-a:1:x", time.Minute); err != nil {
+		t.Fatalf("lock() renewal error = %v", err)
+	}
+	if err := store.lock(ctx, "host-b:2:y", time.Minute); !errors.Is(err, database.ErrStateLocked) {
+		t.Fatalf("lock() by second owner error = %v, want ErrStateLocked", err)
+	}
+	if err := store.unlock(ctx, "host-b:2:y"); err != nil {
+		t.Fatalf("unlock() by non-holder error = %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("lock file removed by non-holder: %v", err)
+	}
+	if err := store.unlock(ctx, "host-a:1:x"); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+	if err := store.lock(ctx, "host-b:2:y", time.Minute); err != nil {
+		t.Fatalf("lock() after unlock error = %v", err)
+	}
+
+	// 过期的锁可被接管
+	expired, _ := json.Marshal(stateLock{Owner: "host-b:2:y", Expires: time.Now().Add(-time.Second).UnixMilli()})
+	if err := os.WriteFile(path+".lock", expired, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if err := store.lock(ctx, "host-a:1:x", time.Minute); err != nil {
+		t.Fatalf("lock() over expired lock error = %v", err)
+	}
+}
+
+func TestStateLockOrphaned(t *testing.T) {
+	self := stateLock{Owner: stateLockOwner(), Expires: time.Now().Add(time.Hour).UnixMilli()}
+	if self.orphaned() {
+		t.Fatal("lock of the running process reported as orphaned")
+	}
+	remote := stateLock{Owner: "some-other-host:1:x", Expires: self.Expires}
+	if remote.orphaned() {
+		t.Fatal("lock of another host reported as orphaned")
+	}
+	if !strings.HasPrefix(stateLockOwner(), stateLockHost()+":") {
+		t.Fatalf("stateLockOwner() = %q, want host prefix %q", stateLockOwner(), stateLockHost())
+	}
+}
+
+func TestProcessorStateLockLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resume.json")
+	p := &Processor{stateFiles: make(map[string]*stateFile)}
+
+	state, err := p.loadStateFile(path)
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("expected state lock after load: %v", err)
+	}
+	state.Tasks["k"] = "1"
+	if err := p.saveStateFile(path, state); err != nil {
+		t.Fatalf("saveStateFile() error = %v", err)
+	}
+
+	// 其他进程在两次写入之间改写了文档
+	if err := os.WriteFile(path, []byte(`{"tasks":{"k":"other"}}`), 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	state.Tasks["k"] = "2"
+	if err := p.saveStateFile(path, state); !errors.Is(err, database.ErrStateConflict) {
+		t.Fatalf("saveStateFile() after concurrent write error = %v, want ErrStateConflict", err)
+	}
+
+	if err := p.releaseStateLocks(); err != nil {
+		t.Fatalf("releaseStateLocks() error = %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("expected state lock to be removed, stat error = %v", err)
+	}
+
+	held, _ := json.Marshal(stateLock{Owner: "some-other-host:1:x", Expires: time.Now().Add(time.Hour).UnixMilli()})
+	lockedPath := filepath.Join(dir, "locked.json")
+	if err := os.WriteFile(lockedPath+".lock", held, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	if _, err := p.loadStateFile(lockedPath); !errors.Is(err, database.ErrStateLocked) {
+		t.Fatalf("loadStateFile() of locked state error = %v, want ErrStateLocked", err)
+	}
+	if _, err := p.peekStateFile(lockedPath); err != nil {
+		t.Fatalf("peekStateFile() of locked state error = %v", err)
+	}
+}
+
+func TestProcessorDatabaseStateBackend(t *testing.T) {
+	cfg := &config.Config{
+		Databases: []config.DatabaseConfig{
+			{Name: "state", Type: config.DatabaseTypeSQLite, Path: filepath.Join(t.TempDir(), "state.db")},
+		},
+		State: config.StateConfig{Backend: config.StateBackendDatabase, Database: "state"},
+	}
+	manager := database.NewConnectionManager(cfg)
+	p := NewProcessor(manager, cfg)
+
+	state, err := p.loadStateFile("jobs/resume.json")
+	if err != nil {
+		t.Fatalf("loadStateFile() error = %v", err)
+	}
+	state.Tasks["src:dst:users"] = "42"
+	if err := p.saveStateFile("jobs/resume.json", state); err != nil {
+		t.Fatalf("saveStateFile() error = %v", err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	rerun := NewProcessor(database.NewConnectionManager(cfg), cfg)
+	t.Cleanup(func() { _ = rerun.Close() })
+	loaded, err := rerun.loadStateFile("jobs/resume.json")
+	if err != nil {
+		t.Fatalf("loadStateFile() on rerun error = %v", err)
+	}
+	if loaded.Tasks["src:dst:users"] != "42" {
+		t.Fatalf("state on rerun = %#v", loaded.Tasks)
+	}
+}
+
+// fakeS3State 在内存中模拟支持条件写入的 S3 存储。
+type fakeS3State struct {
+	objects map[string][]byte
+	etags   map[string]string
+	seq     int
+}
+
+func newFakeS3State() *fakeS3State {
+	return &fakeS3State{objects: make(map[string][]byte), etags: make(map[string]string)}
+}
+
+func (f *fakeS3State) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := f.objects[aws.ToString(in.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data)), ETag: aws.String(f.etags[aws.ToString(in.Key)])}, nil
+}
+
+func (f *fakeS3State) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	etag, exists := f.etags[key]
+	if (in.IfNoneMatch != nil && exists) || (in.IfMatch != nil && aws.ToString(in.IfMatch) != etag) {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	data, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.seq++
+	f.objects[key] = data
+	f.etags[key] = fmt.Sprintf(`"%d"`, f.seq)
+	return &s3.PutObjectOutput{ETag: aws.String(f.etags[key])}, nil
+}
+
+func (f *fakeS3State) DeleteObject(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	key := aws.ToString(in.Key)
+	if in.IfMatch != nil && aws.ToString(in.IfMatch) != f.etags[key] {
+		return nil, &smithy.GenericAPIError{Code: "PreconditionFailed"}
+	}
+	delete(f.objects, key)
+	delete(f.etags, key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3StateStore(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeS3State()
+	key := s3StateKey("/ferry/", "./state/resume.json")
+	if key != "ferry/state/resume.json" {
+		t.Fatalf("s3StateKey() = %q", key)
+	}
+	store := newS3StateStoreWithClient(client, "bucket", key)
+
+	v1, err := store.save(ctx, []byte(`{"tasks":{}}`), "")
+	if err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	if _, err := store.save(ctx, []byte(`{}`), ""); !errors.Is(err, database.ErrStateConflict) {
+		t.Fatalf("save() of existing object without version error = %v, want ErrStateConflict", err)
+	}
+	v2, err := store.save(ctx, []byte(`{"tasks":{"k":"1"}}`), v1)
+	if err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	data, version, err := store.load(ctx)
+	if err != nil || string(data) != `{"tasks":{"k":"1"}}` || version != v2 {
+		t.Fatalf("load() = %q, %q, %v", data, version, err)
+	}
+
+	if err := store.lock(ctx, "pod-1", time.Minute); err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+	if err := store.lock(ctx, "pod-2", time.Minute); !errors.Is(err, database.ErrStateLocked) {
+		t.Fatalf("lock() by second owner error = %v, want ErrStateLocked", err)
+	}
+	if err := store.unlock(ctx, "pod-1"); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+	if err := store.lock(ctx, "pod-2", time.Minute); err != nil {
+		t.Fatalf("lock() after unlock error = %v", err)
+	}
+}
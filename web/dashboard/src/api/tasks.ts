@@ -12,3 +12,7 @@ export async function fetchTask(name: string): Promise<TaskResponse> {
 export async function triggerTask(): Promise<{ status: string }> {
   return apiPost<{ status: string }>('/api/tasks/trigger');
 }
+
+export async function cancelTasks(): Promise<{ status: string }> {
+  return apiPost<{ status: string }>('/api/tasks/cancel');
+}
@@ -1,8 +1,9 @@
 import { useQuery, useMutation } from '@tanstack/react-query';
 import { useSSE } from '../hooks/useSSE';
 import { useStore } from '../state/store';
-import { fetchTasks, triggerTask } from '../api/tasks';
+import { cancelTasks, fetchTasks, triggerTask } from '../api/tasks';
 import { fetchDaemonStatus } from '../api/daemon';
+import type { ShardStatus } from '../types';
 
 function StatusBadge({ status }: { status: string }) {
   const colors: Record<string, string> = {
@@ -29,6 +30,30 @@ function ProgressBar({ percentage }: { percentage: number }) {
   );
 }
 
+function ShardProgress({ shards }: { shards: ShardStatus[] }) {
+  const done = shards.filter((s) => s.done).length;
+  return (
+    <div className="mt-3 space-y-1">
+      <div className="text-xs text-text-secondary">
+        Resumable shards: {done}/{shards.length} done
+      </div>
+      <div className="flex flex-wrap gap-1">
+        {shards.map((s, i) => (
+          <span
+            key={i}
+            title={`${s.lower} .. ${s.upper}${s.inclusive ? ' (inclusive)' : ''}`}
+            className={`px-2 py-0.5 rounded text-xs ${
+              s.done ? 'bg-success/20 text-success' : s.last ? 'bg-info/20 text-info' : 'bg-text-muted/20 text-text-muted'
+            }`}
+          >
+            #{i + 1} {s.done ? 'done' : s.last ? `after ${s.last}` : 'pending'}
+          </span>
+        ))}
+      </div>
+    </div>
+  );
+}
+
 export default function Dashboard() {
   useSSE();
   const taskStates = useStore((s) => s.taskStates);
@@ -48,6 +73,10 @@ export default function Dashboard() {
     mutationFn: triggerTask,
   });
 
+  const cancelMutation = useMutation({
+    mutationFn: cancelTasks,
+  });
+
   if (isLoading) {
     return <div className="text-text-secondary">Loading tasks...</div>;
   }
@@ -82,6 +111,13 @@ export default function Dashboard() {
           >
             {triggerMutation.isPending ? 'Triggering...' : 'Trigger Round'}
           </button>
+          <button
+            onClick={() => cancelMutation.mutate()}
+            disabled={cancelMutation.isPending || !daemonStatus?.running}
+            className="px-4 py-2 border border-danger text-danger rounded-md text-sm font-medium hover:bg-danger/10 disabled:opacity-50 transition-colors"
+          >
+            {cancelMutation.isPending ? 'Cancelling...' : 'Cancel Round'}
+          </button>
         </div>
       </div>
 
@@ -104,6 +140,7 @@ export default function Dashboard() {
               <span>Target: {task.target_db}</span>
               {task.duration_ms > 0 && <span>Duration: {(task.duration_ms / 1000).toFixed(1)}s</span>}
             </div>
+            {task.shard_status?.length > 0 && <ShardProgress shards={task.shard_status} />}
             {task.status === 'error' && taskStates[task.table_name]?.error && (
               <div className="mt-2 text-xs text-danger">{taskStates[task.table_name]?.error}</div>
             )}
@@ -23,6 +23,22 @@ export interface TaskConfig {
   pre_sql: string[];
   post_sql: string[];
   depends_on: string[];
+  shard: ShardConfig;
+}
+
+export interface ShardConfig {
+  enabled: boolean;
+  shards: number;
+  strategy: string;
+  rebalance: boolean;
+}
+
+export interface ShardStatus {
+  lower: string;
+  upper: string;
+  inclusive?: boolean;
+  last?: string;
+  done: boolean;
 }
 
 export interface IndexConfig {
@@ -36,6 +52,8 @@ export interface MaskingConfig {
 }
 
 export interface TaskResponse extends TaskConfig {
+  shard_status: ShardStatus[];
+  shard_status_error?: string;
   status: string;
   processed: number;
   percentage: number;
@@ -129,14 +129,14 @@ func (s *Server) handleGetTableSchema(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	columns, err := database.GetTableSchema(src, table)
+	columns, err := database.GetTableSchema(r.Context(), src, table)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	pk, _ := database.GetTablePrimaryKey(src, dbCfg.Type, table)
-	indexes, _ := database.GetTableIndexes(src, dbCfg.Type, table)
+	pk, _ := database.GetTablePrimaryKey(r.Context(), src, dbCfg.Type, table)
+	indexes, _ := database.GetTableIndexes(r.Context(), src, dbCfg.Type, table)
 
 	schema := database.TableSchema{
 		Columns:    columns,
@@ -173,7 +173,7 @@ func (s *Server) handleGetTableIndexes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	indexes, err := database.GetTableIndexes(src, dbCfg.Type, table)
+	indexes, err := database.GetTableIndexes(r.Context(), src, dbCfg.Type, table)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -9,7 +9,7 @@ import (
 
 func (s *Server) handleRunDoctor(w http.ResponseWriter, r *http.Request) {
 	d := doctor.New(s.configPath)
-	results := d.RunChecks()
+	results := d.RunChecksContext(r.Context())
 
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(results)
@@ -45,7 +45,7 @@ func (s *Server) handleGetHistory(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		recorder := database.NewHistoryRecorder(dbCfg.Type, cfg.History.Table())
-		records, err := recorder.List(targetDB, limit)
+		records, err := recorder.List(r.Context(), targetDB, limit)
 		if err != nil {
 			continue
 		}
@@ -91,7 +91,7 @@ func (s *Server) handleCompareHistory(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		recorder := database.NewHistoryRecorder(dbCfg.Type, cfg.History.Table())
-		records, err := recorder.List(targetDB, 1000)
+		records, err := recorder.List(r.Context(), targetDB, 1000)
 		if err != nil {
 			continue
 		}
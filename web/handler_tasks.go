@@ -5,12 +5,13 @@ import (
 	"net/http"
 
 	"db-ferry/config"
+	"db-ferry/processor"
 	"db-ferry/sse"
 
 	"github.com/go-chi/chi/v5"
 )
 
-func taskToMap(t config.TaskConfig) map[string]interface{} {
+func taskToMap(cfg *config.Config, t config.TaskConfig) map[string]interface{} {
 	indexes := make([]map[string]interface{}, 0, len(t.Indexes))
 	for _, idx := range t.Indexes {
 		indexes = append(indexes, map[string]interface{}{
@@ -25,7 +26,7 @@ func taskToMap(t config.TaskConfig) map[string]interface{} {
 			"rule":   m.Rule,
 		})
 	}
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"table_name":           t.TableName,
 		"sql":                  t.SQL,
 		"source_db":            t.SourceDB,
@@ -50,7 +51,28 @@ func taskToMap(t config.TaskConfig) map[string]interface{} {
 		"pre_sql":              t.PreSQL,
 		"post_sql":             t.PostSQL,
 		"depends_on":           t.DependsOn,
+		"shard": map[string]interface{}{
+			"enabled":   t.Shard.Enabled,
+			"shards":    t.Shard.Shards,
+			"strategy":  t.Shard.Strategy,
+			"rebalance": t.Shard.Rebalance,
+		},
 	}
+	addShardStatus(m, cfg, t)
+	return m
+}
+
+// addShardStatus reports the per-shard progress of an unfinished sharded run
+// recorded in the task's state_file.
+func addShardStatus(m map[string]interface{}, cfg *config.Config, t config.TaskConfig) {
+	shards, err := processor.ReadShardStatus(cfg, t)
+	if err != nil {
+		m["shard_status_error"] = err.Error()
+	}
+	if shards == nil {
+		shards = []processor.ShardStatus{}
+	}
+	m["shard_status"] = shards
 }
 
 func (s *Server) handleGetTasks(w http.ResponseWriter, r *http.Request) {
@@ -67,7 +89,7 @@ func (s *Server) handleGetTasks(w http.ResponseWriter, r *http.Request) {
 
 	tasks := make([]map[string]interface{}, 0, len(cfg.Tasks))
 	for _, t := range cfg.Tasks {
-		m := taskToMap(t)
+		m := taskToMap(cfg, t)
 		if state, ok := states[t.TableName]; ok {
 			m["processed"] = state.Processed
 			m["percentage"] = state.Percentage
@@ -114,7 +136,7 @@ func (s *Server) handleGetTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	m := taskToMap(found)
+	m := taskToMap(cfg, found)
 	if s.sseServer != nil {
 		states := s.sseServer.GetStates()
 		if state, ok := states[name]; ok {
@@ -148,3 +170,18 @@ func (s *Server) handleTriggerTask(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "triggered"})
 }
+
+func (s *Server) handleCancelTasks(w http.ResponseWriter, r *http.Request) {
+	if s.daemon == nil || !s.daemon.IsRunning() {
+		http.Error(w, "daemon not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	if !s.daemon.CancelRound() {
+		http.Error(w, "no migration round running", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+}
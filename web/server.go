@@ -69,6 +69,7 @@ func (s *Server) Start(addr string) error {
 		r.Get("/tasks", s.handleGetTasks)
 		r.Get("/tasks/{name}", s.handleGetTask)
 		r.Post("/tasks/trigger", s.handleTriggerTask)
+		r.Post("/tasks/cancel", s.handleCancelTasks)
 		r.Get("/config", s.handleGetConfig)
 		r.Put("/config", s.handlePutConfig)
 		r.Post("/config/validate", s.handleValidateConfig)
@@ -191,6 +191,46 @@ allow_same_table = true
 	}
 }
 
+func TestHandleGetTaskShardStatus(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	state := `{"tasks": {}, "shards": {"src:src:users": [{"lower": "1", "upper": "50", "done": true}, {"lower": "50", "upper": "99", "inclusive": true, "last": "70", "done": false}]}}`
+	if err := os.WriteFile(statePath, []byte(state), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	content := `
+[[databases]]
+name = "src"
+type = "sqlite"
+path = "test.db"
+
+[[tasks]]
+table_name = "users"
+sql = "SELECT 1 AS id"
+source_db = "src"
+target_db = "src"
+mode = "append"
+allow_same_table = true
+resume_key = "id"
+state_file = "` + filepath.ToSlash(statePath) + `"
+
+[tasks.shard]
+enabled = true
+shards = 2
+`
+	srv, _ := newTestServer(t, content)
+	req := withChiParams(httptest.NewRequest(http.MethodGet, "/api/tasks/users", nil), "name", "users")
+	rec := httptest.NewRecorder()
+	srv.handleGetTask(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, `"shard_status":[{"lower":"1","upper":"50","done":true},{"lower":"50","upper":"99","inclusive":true,"last":"70","done":false}]`) {
+		t.Fatalf("expected shard status in response, got %q", body)
+	}
+}
+
 func TestHandleTriggerTask(t *testing.T) {
 	srv, _ := newTestServer(t, "")
 
@@ -203,6 +243,17 @@ func TestHandleTriggerTask(t *testing.T) {
 	}
 }
 
+func TestHandleCancelTasks(t *testing.T) {
+	srv, _ := newTestServer(t, "")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tasks/cancel", nil)
+	rec := httptest.NewRecorder()
+	srv.handleCancelTasks(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+}
+
 func TestHandleGetDatabases(t *testing.T) {
 	content := `
 [[databases]]
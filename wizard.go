@@ -155,13 +155,21 @@ func collectSourceDB(state *wizardState) error {
 			huh.NewOption("Oracle", config.DatabaseTypeOracle),
 			huh.NewOption("MySQL", config.DatabaseTypeMySQL),
 			huh.NewOption("PostgreSQL", config.DatabaseTypePostgreSQL),
+			huh.NewOption("MariaDB", dialectChoice(config.DatabaseTypeMySQL, config.DialectMariaDB)),
+			huh.NewOption("TiDB", dialectChoice(config.DatabaseTypeMySQL, config.DialectTiDB)),
+			huh.NewOption("CockroachDB", dialectChoice(config.DatabaseTypePostgreSQL, config.DialectCockroachDB)),
 			huh.NewOption("SQL Server", config.DatabaseTypeSQLServer),
+			huh.NewOption("ClickHouse", config.DatabaseTypeClickHouse),
 			huh.NewOption("SQLite", config.DatabaseTypeSQLite),
 			huh.NewOption("DuckDB", config.DatabaseTypeDuckDB),
+			huh.NewOption("CSV files", config.DatabaseTypeCSV),
+			huh.NewOption("JSONL files", config.DatabaseTypeJSONL),
+			huh.NewOption("Parquet files", config.DatabaseTypeParquet),
 		).
 		Value(&dbType)); err != nil {
 		return err
 	}
+	dbType, state.SourceDB.Dialect = splitDialectChoice(dbType)
 	state.SourceDB.Type = dbType
 	state.SourceDB.Name = "source_db"
 
@@ -181,7 +189,7 @@ func collectSourceDB(state *wizardState) error {
 	if needsHostPort(dbType) {
 		fields = append(fields,
 			huh.NewInput().Title("Host").Value(&state.SourceDB.Host).Validate(nonEmpty("host is required")),
-			huh.NewInput().Title("Port").Value(&state.SourceDB.Port).Placeholder(defaultPort(dbType)).Validate(nonEmpty("port is required")),
+			huh.NewInput().Title("Port").Value(&state.SourceDB.Port).Placeholder(defaultPort(dbType, state.SourceDB.Dialect)).Validate(nonEmpty("port is required")),
 		)
 		if dbType == config.DatabaseTypeOracle {
 			fields = append(fields, huh.NewInput().Title("Service Name").Value(&state.SourceDB.Service).Validate(nonEmpty("service is required")))
@@ -190,7 +198,7 @@ func collectSourceDB(state *wizardState) error {
 		}
 		fields = append(fields,
 			huh.NewInput().Title("User").Value(&state.SourceDB.User).Validate(nonEmpty("user is required")),
-			huh.NewInput().Title("Password").Value(&state.SourceDB.Password).Validate(nonEmpty("password is required")),
+			huh.NewInput().Title("Password").Value(&state.SourceDB.Password).Validate(passwordValidator(dbType, state.SourceDB.Dialect)),
 		)
 	} else {
 		fields = append(fields, huh.NewInput().Title("File Path").Value(&state.SourceDB.Path).Validate(nonEmpty("path is required")))
@@ -207,13 +215,21 @@ func collectTargetDB(state *wizardState) error {
 			huh.NewOption("Oracle", config.DatabaseTypeOracle),
 			huh.NewOption("MySQL", config.DatabaseTypeMySQL),
 			huh.NewOption("PostgreSQL", config.DatabaseTypePostgreSQL),
+			huh.NewOption("MariaDB", dialectChoice(config.DatabaseTypeMySQL, config.DialectMariaDB)),
+			huh.NewOption("TiDB", dialectChoice(config.DatabaseTypeMySQL, config.DialectTiDB)),
+			huh.NewOption("CockroachDB", dialectChoice(config.DatabaseTypePostgreSQL, config.DialectCockroachDB)),
 			huh.NewOption("SQL Server", config.DatabaseTypeSQLServer),
+			huh.NewOption("ClickHouse", config.DatabaseTypeClickHouse),
 			huh.NewOption("SQLite", config.DatabaseTypeSQLite),
 			huh.NewOption("DuckDB", config.DatabaseTypeDuckDB),
+			huh.NewOption("CSV files", config.DatabaseTypeCSV),
+			huh.NewOption("JSONL files", config.DatabaseTypeJSONL),
+			huh.NewOption("Parquet files", config.DatabaseTypeParquet),
 		).
 		Value(&dbType)); err != nil {
 		return err
 	}
+	dbType, state.TargetDB.Dialect = splitDialectChoice(dbType)
 	state.TargetDB.Type = dbType
 	state.TargetDB.Name = "target_db"
 
@@ -236,7 +252,7 @@ func collectTargetDB(state *wizardState) error {
 	if needsHostPort(dbType) {
 		fields = append(fields,
 			huh.NewInput().Title("Host").Value(&state.TargetDB.Host).Validate(nonEmpty("host is required")),
-			huh.NewInput().Title("Port").Value(&state.TargetDB.Port).Placeholder(defaultPort(dbType)).Validate(nonEmpty("port is required")),
+			huh.NewInput().Title("Port").Value(&state.TargetDB.Port).Placeholder(defaultPort(dbType, state.TargetDB.Dialect)).Validate(nonEmpty("port is required")),
 		)
 		if dbType == config.DatabaseTypeOracle {
 			fields = append(fields, huh.NewInput().Title("Service Name").Value(&state.TargetDB.Service).Validate(nonEmpty("service is required")))
@@ -245,7 +261,7 @@ func collectTargetDB(state *wizardState) error {
 		}
 		fields = append(fields,
 			huh.NewInput().Title("User").Value(&state.TargetDB.User).Validate(nonEmpty("user is required")),
-			huh.NewInput().Title("Password").Value(&state.TargetDB.Password).Validate(nonEmpty("password is required")),
+			huh.NewInput().Title("Password").Value(&state.TargetDB.Password).Validate(passwordValidator(dbType, state.TargetDB.Dialect)),
 		)
 	} else {
 		fields = append(fields, huh.NewInput().Title("File Path").Value(&state.TargetDB.Path).Validate(nonEmpty("path is required")))
@@ -369,6 +385,21 @@ func collectAdvancedOptions(state *wizardState) error {
 		state.ResumeKey = strings.TrimSpace(resumeKey)
 	}
 
+	if state.Mode == config.TaskModeMerge && state.TargetDB.Type == config.DatabaseTypeClickHouse {
+		strategy := config.ClickHouseUpsertReplacing
+		if err := runHuhSelect(huh.NewSelect[string]().
+			Title("ClickHouse upsert strategy").
+			Description("replacing = ReplacingMergeTree deduplicated by background merges; delete_insert = delete matching keys, then insert").
+			Options(
+				huh.NewOption("replacing", config.ClickHouseUpsertReplacing),
+				huh.NewOption("delete_insert", config.ClickHouseUpsertDeleteInsert),
+			).
+			Value(&strategy)); err != nil {
+			return err
+		}
+		state.TargetDB.UpsertStrategy = strategy
+	}
+
 	if state.Mode == config.TaskModeMerge {
 		keysStr := ""
 		if err := runHuhInput(huh.NewInput().
@@ -420,6 +451,12 @@ func writeDatabase(b *strings.Builder, db config.DatabaseConfig) {
 		}
 		fmt.Fprintf(b, "user = %q\n", db.User)
 		fmt.Fprintf(b, "password = %q\n", db.Password)
+		if db.Dialect != "" {
+			fmt.Fprintf(b, "dialect = %q\n", db.Dialect)
+		}
+		if db.UpsertStrategy != "" {
+			fmt.Fprintf(b, "upsert_strategy = %q\n", db.UpsertStrategy)
+		}
 	} else {
 		fmt.Fprintf(b, "path = %q\n", db.Path)
 	}
@@ -454,28 +491,56 @@ func writeTask(b *strings.Builder, table string, state *wizardState) {
 
 func needsHostPort(dbType string) bool {
 	switch dbType {
-	case config.DatabaseTypeOracle, config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLServer:
+	case config.DatabaseTypeOracle, config.DatabaseTypeMySQL, config.DatabaseTypePostgreSQL, config.DatabaseTypeSQLServer, config.DatabaseTypeClickHouse:
 		return true
 	default:
 		return false
 	}
 }
 
-func defaultPort(dbType string) string {
+// dialectChoice encodes a compatible engine as a single select value, e.g. "mysql/tidb".
+func dialectChoice(dbType, dialect string) string {
+	return dbType + "/" + dialect
+}
+
+// splitDialectChoice reverses dialectChoice; plain types have no dialect.
+func splitDialectChoice(choice string) (string, string) {
+	dbType, dialect, _ := strings.Cut(choice, "/")
+	return dbType, dialect
+}
+
+func defaultPort(dbType, dialect string) string {
 	switch dbType {
 	case config.DatabaseTypeOracle:
 		return "1521"
 	case config.DatabaseTypeMySQL:
+		if dialect == config.DialectTiDB {
+			return "4000"
+		}
 		return "3306"
 	case config.DatabaseTypePostgreSQL:
+		if dialect == config.DialectCockroachDB {
+			return "26257"
+		}
 		return "5432"
 	case config.DatabaseTypeSQLServer:
 		return "1433"
+	case config.DatabaseTypeClickHouse:
+		return "8123"
 	default:
 		return ""
 	}
 }
 
+// passwordValidator requires a password except for ClickHouse and TiDB, whose default
+// users have none, and CockroachDB, which may authenticate with certificates.
+func passwordValidator(dbType, dialect string) func(string) error {
+	if dbType == config.DatabaseTypeClickHouse || dialect == config.DialectTiDB || dialect == config.DialectCockroachDB {
+		return func(string) error { return nil }
+	}
+	return nonEmpty("password is required")
+}
+
 func nonEmpty(msg string) func(string) error {
 	return func(s string) error {
 		if strings.TrimSpace(s) == "" {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
@@ -191,18 +192,21 @@ func TestNeedsHostPortAndDefaultPort(t *testing.T) {
 	}
 
 	cases := []struct {
-		typ  string
-		want string
+		choice string
+		want   string
 	}{
 		{"oracle", "1521"},
 		{"mysql", "3306"},
 		{"postgresql", "5432"},
 		{"sqlserver", "1433"},
 		{"sqlite", ""},
+		{dialectChoice("mysql", "tidb"), "4000"},
+		{dialectChoice("postgresql", "cockroachdb"), "26257"},
 	}
 	for _, tc := range cases {
-		if got := defaultPort(tc.typ); got != tc.want {
-			t.Fatalf("defaultPort(%q) = %q, want %q", tc.typ, got, tc.want)
+		typ, dialect := splitDialectChoice(tc.choice)
+		if got := defaultPort(typ, dialect); got != tc.want {
+			t.Fatalf("defaultPort(%q, %q) = %q, want %q", typ, dialect, got, tc.want)
 		}
 	}
 }
@@ -353,7 +357,7 @@ func TestTestSourceAndListTables_Success(t *testing.T) {
 		t.Fatalf("NewSQLiteDB() error = %v", err)
 	}
 	defer s.Close()
-	if err := s.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY)"); err != nil {
+	if err := s.Exec(context.Background(), "CREATE TABLE users (id INTEGER PRIMARY KEY)"); err != nil {
 		t.Fatalf("create table error = %v", err)
 	}
 